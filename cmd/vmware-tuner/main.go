@@ -2,13 +2,15 @@ package main
 
 import (
 	"bufio"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"os/exec"
 	"path/filepath"
-	"sort"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/fatih/color"
 	"github.com/spf13/cobra"
@@ -19,15 +21,201 @@ import (
 var (
 	version      = "1.1.0-enterprise"
 	dryRun       bool
-	noGrub       bool
-	noSysctl     bool
-	noFstab      bool
-	noIO         bool
-	noNet        bool
-	installTools bool
-	doDebloat    bool
+	auditProfile string
+	configPath   string
+	exclusions   string
+	role         string
+	overrideRole bool
+	reportDir    string
+	reportPDF    bool
+	reportBench  bool
+	pkgDir       string
+	noNotify     bool
+	bundleRel    string
+	bundleOut    string
+	statsWatch   bool
+	statsEvery   time.Duration
+	profileName  string
+	rollbackOnly []string
+	benchDir     string
+	benchSizeMB  int64
+	benchRuntime time.Duration
+	benchBS      []string
+	benchJSON    bool
+	backupDest   string
+	s3Endpoint   string
+	doctorOut    string
+	themeName    string
+	redactOutput bool
+	expertBoot   []string
+	assumeYes    bool
+	forceRun     bool
+	verifyJSON   bool
+	postBoot     bool
+	verifyReboot bool
+	showStats    bool
+	logSince     string
+	logSeverity  string
+	logLimit     int
+	logOutput    string
+	usageDepth   int
+	usageTop     int
+	netRxRing    int
+	netTxRing    int
+	netRxUsecs   int
+	netTxUsecs   int
+	netAffinity  string
+	nicNames     []string
+	nicDrivers   []string
+	noPager      bool
+	helpLang     string
+	ipv6Mode     string
+
+	modulesMarkdown bool
+	diffAgainst     string
+	diffRemoteBin   string
+	balloonWatch    bool
+	balloonEvery    time.Duration
+	stealEvery      time.Duration
+	stealJSON       bool
+
+	// Set by VMWARE_TUNER_BACKUP_DIR and VMWARE_TUNER_PROXY, over the config file
+	envBackupDir string
+	envProxy     string
+
+	// pkgMount is where --pkg-dir image.iso is mounted, released on exit
+	pkgMount string
+	// pkgBundle is where --pkg-dir bundle.tar.gz is extracted, removed on exit
+	pkgBundle string
+
+	// pipelineFlags hold the root flags of the pipeline modules, by module
+	pipelineFlags = make(map[string]*bool)
 )
 
+// The menu entries leaving the menu (tuning pipeline) or restoring backups
+// live here; the tuners register theirs
+func init() {
+	tuner.Register(tuner.Module{
+		Name:        "tune",
+		Label:       "Optimize this VM (Tuning)",
+		Description: "Run the tuning pipeline",
+		Category:    tuner.CategoryTuning,
+		Menu:        1,
+		RequireRoot: true,
+	})
+	tuner.Register(tuner.Module{
+		Name:        "rollback",
+		Label:       "Restore a backup (Rollback)",
+		Description: "Restore the files of a backup session",
+		Category:    tuner.CategoryMaintenance,
+		Menu:        2,
+		RequireRoot: true,
+		Ungated:     true,
+		Run: func(ctx *tuner.ModuleContext) error {
+			return runRollbackInteractive()
+		},
+	})
+}
+
+// pipelineEnabled reports whether the flag of a pipeline module enables it
+func pipelineEnabled(m *tuner.Module) bool {
+	return m.Flag.Enables(*pipelineFlags[m.Name])
+}
+
+// enablePipeline turns a pipeline module on or off
+func enablePipeline(m *tuner.Module, on bool) {
+	*pipelineFlags[m.Name] = on != m.Flag.Negated
+}
+
+// envOptions are the VMWARE_TUNER_* variables, for containers and image
+// builds (Packer) that cannot write a config file: they preset the options,
+// a flag on the command line wins
+var envOptions = []struct {
+	Name  string
+	Usage string
+	Set   func(value string) error
+}{
+	{"VMWARE_TUNER_CONFIG", "configuration file (--config)", setString(&configPath)},
+	{"VMWARE_TUNER_EXCLUSIONS", "exclusions file (--exclusions)", setString(&exclusions)},
+	{"VMWARE_TUNER_PROFILE", "tuning profile (--profile)", setString(&profileName)},
+	{"VMWARE_TUNER_ROLE", "role (--role)", setString(&role)},
+	{"VMWARE_TUNER_YES", "apply without confirmation (--yes)", setBool(&assumeYes)},
+	{"VMWARE_TUNER_PKG_DIR", "package directory, ISO image or bundle (--pkg-dir)", setString(&pkgDir)},
+	{"VMWARE_TUNER_BACKUP_DIR", "backup directory (tuning.backup_dir)", func(value string) error {
+		if !filepath.IsAbs(value) {
+			return fmt.Errorf("must be an absolute path")
+		}
+		envBackupDir = value
+		return nil
+	}},
+	{"VMWARE_TUNER_PROXY", "HTTP and HTTPS proxy (proxy.http, proxy.https)", setString(&envProxy)},
+	{"VMWARE_TUNER_OUTPUT", "text or json (--json of verify, diskbench and schedbench)", func(value string) error {
+		switch value {
+		case "text":
+		case "json":
+			verifyJSON, benchJSON = true, true
+		default:
+			return fmt.Errorf("must be text or json")
+		}
+		return nil
+	}},
+	{"VMWARE_TUNER_THEME", "output theme (--theme)", setString(&themeName)},
+	{"VMWARE_TUNER_EXPLAIN", "explain each change (--explain)", setBool(&tuner.Explain)},
+	{"VMWARE_TUNER_PRODUCTION", "production VM (--production)", setBool(&tuner.ForceProduction)},
+}
+
+func setString(p *string) func(string) error {
+	return func(value string) error {
+		*p = value
+		return nil
+	}
+}
+
+func setBool(p *bool) func(string) error {
+	return func(value string) error {
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("expected true or false, got %q", value)
+		}
+		*p = b
+		return nil
+	}
+}
+
+// applyEnvOptions presets the options from the VMWARE_TUNER_* variables.
+// It runs once the flags are defined (their defaults are set) and before
+// they are parsed. Unknown variables are reported: a typo would go unnoticed.
+func applyEnvOptions() error {
+	known := make(map[string]bool)
+	for _, opt := range envOptions {
+		known[opt.Name] = true
+		value, ok := os.LookupEnv(opt.Name)
+		if !ok || value == "" {
+			continue
+		}
+		if err := opt.Set(value); err != nil {
+			return fmt.Errorf("%s: %w", opt.Name, err)
+		}
+	}
+	for _, kv := range os.Environ() {
+		name, _, _ := strings.Cut(kv, "=")
+		if strings.HasPrefix(name, "VMWARE_TUNER_") && !known[name] {
+			tuner.PrintWarning("Unknown variable %s ignored", name)
+		}
+	}
+	return nil
+}
+
+// envOptionsHelp lists the variables for the root help
+func envOptionsHelp() string {
+	var b strings.Builder
+	b.WriteString("\nEnvironment (a flag on the command line wins, the variables win over the config file):\n")
+	for _, opt := range envOptions {
+		fmt.Fprintf(&b, "  %-24s %s\n", opt.Name, opt.Usage)
+	}
+	return b.String()
+}
+
 func main() {
 	var rootCmd = &cobra.Command{
 		Use:   "vmware-tuner",
@@ -45,6 +233,17 @@ Features:
 `,
 		Version: version,
 		RunE:    runTuner,
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			if err := loadOptions(cmd); err != nil {
+				return err
+			}
+			// resume sets the package directory up once, from the options
+			// of the interrupted run it replays
+			if cmd.Name() == "resume" {
+				return nil
+			}
+			return setupPackageDir()
+		},
 	}
 
 	var showCmd = &cobra.Command{
@@ -61,20 +260,393 @@ Features:
 		RunE:  verifyConfig,
 	}
 
+	var auditCmd = &cobra.Command{
+		Use:   "audit",
+		Short: "Audit the system and print an optimization score",
+		Long:  "Run the optimization audit. Use --profile latency for the latency-sensitive VM checklist",
+		RunE:  runAudit,
+	}
+	auditCmd.Flags().StringVar(&auditProfile, "profile", "standard", "Audit profile (standard, latency)")
+	profileHelp := "Tuning profile (" + strings.Join(tuner.ProfileNames(), ", ") + "; default: tuning.profile or default)"
+	rootCmd.Flags().StringVar(&profileName, "profile", "", profileHelp)
+	showCmd.Flags().StringVar(&profileName, "profile", "", profileHelp)
+	verifyCmd.Flags().StringVar(&profileName, "profile", "", profileHelp)
+	verifyCmd.Flags().BoolVar(&verifyJSON, "json", false, "Print the checks as JSON")
+	verifyCmd.Flags().BoolVar(&postBoot, "post-boot", false, "Record the result in the state directory and notify (run by vmware-tuner-verify.service)")
+
+	rootCmd.PersistentFlags().StringVar(&configPath, "config", tuner.DefaultConfigPath, "Path to the configuration file")
+	rootCmd.PersistentFlags().StringVar(&exclusions, "exclusions", tuner.DefaultExclusionsPath, "Mounts, services, interfaces and sysctl keys never touched (vendor-managed components)")
+	rootCmd.PersistentFlags().StringVar(&themeName, "theme", "", "Output theme: "+strings.Join(tuner.ThemeNames(), ", ")+" (ASCII tags instead of symbols for log collectors)")
+	rootCmd.PersistentFlags().StringVar(&role, "role", "", "Apply only the modules sanctioned for this role (defined in the config file)")
+	rootCmd.PersistentFlags().BoolVar(&overrideRole, "override-role", false, "Allow modules outside the selected role")
+	rootCmd.PersistentFlags().StringVar(&pkgDir, "pkg-dir", "", "Install packages from this directory of .deb/.rpm files, a mounted distribution ISO, an .iso image or a bundle (air-gapped)")
+	rootCmd.PersistentFlags().BoolVar(&tuner.ForceProduction, "production", false, "Treat this VM as production (risky modules need typed confirmation)")
+	rootCmd.PersistentFlags().BoolVar(&tuner.Explain, "explain", false, "Print each command and file edit with the reason before it is made")
+	rootCmd.PersistentFlags().StringSliceVar(&nicNames, "interfaces", nil, "Network interfaces to tune, names or patterns (ens192,eno1,enp*); default: the ethernet names")
+	rootCmd.PersistentFlags().StringSliceVar(&nicDrivers, "nic-driver", nil, "Tune the network interfaces of these drivers (vmxnet3)")
+
+	var reportCmd = &cobra.Command{
+		Use:   "report",
+		Short: "Generate an HTML/PDF tuning report",
+		Long:  "Compile system info, hardware inspection, audit results, benchmark numbers and applied changes into a timestamped report",
+		RunE:  runReport,
+	}
+	reportCmd.Flags().StringVar(&reportDir, "output", ".", "Directory where the report is written")
+	reportCmd.Flags().BoolVar(&reportPDF, "pdf", false, "Also produce a PDF (requires wkhtmltopdf or chromium)")
+	reportCmd.Flags().BoolVar(&reportBench, "benchmark", false, "Include the 100MB download speed test and a short disk benchmark")
+	reportCmd.Flags().BoolVar(&redactOutput, "redact", false, "Hide host names, IP addresses, user names and serials (redaction: section)")
+
+	var complianceCmd = &cobra.Command{
+		Use:   "compliance",
+		Short: "Run audit+verify and notify differences since the previous run",
+		Long:  "Compliance check used by the weekly cron job: compares audit and verify results with the previous run and emails/webhooks only the differences (notify: section of the config file)",
+		RunE:  runCompliance,
+	}
+	complianceCmd.Flags().BoolVar(&noNotify, "no-notify", false, "Only print the differences")
+
+	var bundleCmd = &cobra.Command{
+		Use:   "bundle",
+		Short: "Manage air-gapped package bundles",
+	}
+	var bundleCreateCmd = &cobra.Command{
+		Use:   "create",
+		Short: "Download the packages the tool may install into a transferable archive",
+		Long:  "Run on a connected machine of the target distribution: downloads open-vm-tools, ethtool, growpart, chrony, fio and iperf3 with their dependencies into an archive consumed by --pkg-dir on air-gapped VMs",
+		RunE:  runBundleCreate,
+	}
+	bundleCreateCmd.Flags().StringVar(&bundleRel, "release", "", "Target release (VERSION_ID, default: this machine's; RHEL family only can differ)")
+	bundleCreateCmd.Flags().StringVar(&bundleOut, "output", ".", "Directory where the archive is written")
+	bundleCmd.AddCommand(bundleCreateCmd)
+
+	var netcheckCmd = &cobra.Command{
+		Use:   "netcheck",
+		Short: "Diagnose connectivity (DNS, gateway, proxy, repositories, NTP, vCenter)",
+		Long:  "Test each network dependency and print a pass/fail matrix with latency, to find out why the VM is considered offline. The vCenter endpoint comes from the vcenter: section of the config file",
+		RunE:  runNetcheck,
+		// A failed check is a diagnosis, not a usage error
+		SilenceUsage: true,
+	}
+
+	var netstatsCmd = &cobra.Command{
+		Use:   "netstats",
+		Short: "Show packet drops and errors since the last run",
+		Long:  "Compare the interface drop/error counters (sysfs and the ethtool -S driver statistics) with the baseline saved by the previous run, or print per-second rates with --watch",
+		RunE:  runNetstats,
+	}
+	netstatsCmd.Flags().BoolVar(&statsWatch, "watch", false, "Print drop/error rates until interrupted")
+	netstatsCmd.Flags().DurationVar(&statsEvery, "interval", 2*time.Second, "Sampling interval in watch mode")
+
+	var balloonCmd = &cobra.Command{
+		Use:   "balloon",
+		Short: "Tell host memory reclaim (ballooning) from ordinary swap use",
+		Long:  "Correlate the balloon size and host swap (vmware-toolbox-cmd stat), the swap-in rate (/proc/vmstat) and the memory stalls (PSI) over an interval. Ballooning-induced swap thrash is reported with the actions to take in the guest and on the vSphere side; --watch prints the values at each interval",
+		RunE:  runBalloon,
+	}
+	balloonCmd.Flags().BoolVar(&balloonWatch, "watch", false, "Print the balloon and swap activity until interrupted")
+	balloonCmd.Flags().DurationVar(&balloonEvery, "interval", 5*time.Second, "Sampling interval")
+
+	var stealCmd = &cobra.Command{
+		Use:   "steal",
+		Short: "Measure the CPU steal time of each vCPU",
+		Long:  "Sample the steal time of /proc/stat over an interval and report the share of time the ESXi host took from each vCPU. 5% or more flags building contention, 10% or more host CPU contention",
+		RunE:  runSteal,
+	}
+	stealCmd.Flags().DurationVar(&stealEvery, "interval", 5*time.Second, "Sampling interval")
+	stealCmd.Flags().BoolVar(&stealJSON, "json", false, "Print the steal time per vCPU as JSON")
+
+	var inventoryCmd = &cobra.Command{
+		Use:   "inventory",
+		Short: "Print the system and virtual hardware inventory as JSON",
+		Long:  "Print OS, NICs, PCI devices, storage controller and decoded DMI/SMBIOS data (firmware, ESXi era, hardware generation hints) as JSON. Serial and UUID need root",
+		RunE:  runInventory,
+	}
+	inventoryCmd.Flags().BoolVar(&redactOutput, "redact", false, "Hide host names, IP addresses, user names and serials (redaction: section)")
+
+	var diffCmd = &cobra.Command{
+		Use:   "diff [inventory.json]",
+		Short: "Compare this VM (or an inventory) with a baseline inventory or another VM",
+		Long:  "Compare two inventories (vmware-tuner inventory: OS, virtual hardware, kernel command line, sysctl values, schedulers, applied profile) and print the settings that differ, e.g. a misbehaving clone against its golden template. --against takes an inventory file or a host, whose inventory is collected over SSH (key authentication). Without an argument the left side is this VM. Host names, addresses, serials and timestamps are not compared",
+		Args:  cobra.MaximumNArgs(1),
+		RunE:  runDiff,
+	}
+	diffCmd.Flags().StringVar(&diffAgainst, "against", "", "Baseline: an inventory JSON file or a host reachable over SSH")
+	diffCmd.Flags().StringVar(&diffRemoteBin, "remote-bin", "/usr/local/bin/vmware-tuner", "Path of vmware-tuner on the --against host")
+	diffCmd.MarkFlagRequired("against")
+
+	var rollbackCmd = &cobra.Command{
+		Use:   "rollback [timestamp]",
+		Short: "Restore a backup, or only some of its files",
+		Long:  "Restore the files recorded in a backup manifest. --only restores the listed files and triggers only their reloads (update-grub, sysctl --system, systemctl daemon-reload). Without a timestamp, the backup and its files are chosen interactively",
+		Args:  cobra.MaximumNArgs(1),
+		RunE:  runRollback,
+	}
+	rollbackCmd.Flags().StringSliceVar(&rollbackOnly, "only", nil, "Comma-separated files to restore (default: all files of the backup)")
+
+	var diskbenchCmd = &cobra.Command{
+		Use:   "diskbench",
+		Short: "Measure disk throughput, IOPS and latency without fio",
+		Long:  "Sequential and random read/write tests with O_DIRECT at queue depth 1 on a temporary file (removed afterwards). Works on air-gapped VMs without fio",
+		RunE:  runDiskbench,
+	}
+	defaults := tuner.DefaultDiskBenchOptions()
+	diskbenchCmd.Flags().StringVar(&benchDir, "dir", defaults.Dir, "Directory of the test file (on the disk to measure)")
+	diskbenchCmd.Flags().Int64Var(&benchSizeMB, "size", defaults.FileSize>>20, "Test file size in MB")
+	diskbenchCmd.Flags().DurationVar(&benchRuntime, "runtime", defaults.Runtime, "Duration of each test")
+	diskbenchCmd.Flags().StringSliceVar(&benchBS, "bs", nil, "Block sizes, e.g. 4k,64k,1m (default: 1m sequential, 4k random)")
+	diskbenchCmd.Flags().BoolVar(&benchJSON, "json", false, "Print the results as JSON")
+
+	var schedbenchCmd = &cobra.Command{
+		Use:   "schedbench",
+		Short: "Measure the wakeup latency of the process scheduler",
+		Long:  "Two threads pass a byte back and forth over pipes, like perf bench sched pipe. Run it before and after the scheduler knobs of the throughput and database profiles to check their impact on this VM",
+		RunE:  runSchedbench,
+	}
+	schedbenchCmd.Flags().DurationVar(&benchRuntime, "runtime", defaults.Runtime, "Duration of the test")
+	schedbenchCmd.Flags().BoolVar(&benchJSON, "json", false, "Print the result as JSON")
+
+	var wizardCmd = &cobra.Command{
+		Use:   "wizard",
+		Short: "Answer a few questions to generate a config file and a tuning plan",
+		Long:  "Quick start: asks about the workload, latency vs throughput, memory, production vs lab and the reboot window, then writes a config file with a tuning profile and a role to apply with --role wizard",
+		RunE:  runWizard,
+	}
+
+	var backupsCmd = &cobra.Command{
+		Use:   "backups",
+		Short: "List, export and import backups",
+	}
+	var backupsListCmd = &cobra.Command{
+		Use:   "list",
+		Short: "List the backups with their files",
+		RunE:  runBackupsList,
+	}
+	var backupsExportCmd = &cobra.Command{
+		Use:   "export <timestamp>",
+		Short: "Copy a backup and its manifest off the VM",
+		Long:  "Archive a backup session and copy it to scp://[user@]host[:port]/path, s3://bucket/prefix (aws CLI, --endpoint for S3-compatible storage) or a local directory, so it survives reprovisioning",
+		Args:  cobra.ExactArgs(1),
+		RunE:  runBackupsExport,
+	}
+	backupsExportCmd.Flags().StringVar(&backupDest, "dest", "", "Destination: scp://host/path, s3://bucket/prefix or a directory")
+	backupsExportCmd.MarkFlagRequired("dest")
+	var backupsImportCmd = &cobra.Command{
+		Use:   "import <archive>",
+		Short: "Fetch an exported backup so rollback can restore it",
+		Long:  "Fetch an archive made by backups export (scp://host/path/file.tar.gz, s3://bucket/key or a local file) into the backup directory",
+		Args:  cobra.ExactArgs(1),
+		RunE:  runBackupsImport,
+	}
+	backupsCmd.PersistentFlags().StringVar(&s3Endpoint, "endpoint", "", "S3-compatible endpoint URL (MinIO, Ceph, ...)")
+	backupsCmd.AddCommand(backupsListCmd, backupsExportCmd, backupsImportCmd)
+
+	var doctorCmd = &cobra.Command{
+		Use:   "doctor",
+		Short: "Collect a support bundle with every diagnostic",
+		Long:  "Run info, hardware, audit, log doctor, failed units, filesystem health, netcheck and the packet-drop check, and package their outputs with raw command outputs (dmesg, journal, sysctl -a), the config file and the tool logs into a tarball to attach to tickets. Nothing is changed on the system",
+		RunE:  runDoctor,
+	}
+	doctorCmd.Flags().StringVar(&doctorOut, "output", ".", "Directory where the archive is written")
+	doctorCmd.Flags().BoolVar(&redactOutput, "redact", false, "Hide host names, IP addresses, user names and serials (redaction: section)")
+
+	var logdoctorCmd = &cobra.Command{
+		Use:   "logdoctor",
+		Short: "Scan the kernel log and syslog (or the journal) for errors",
+		Long:  "Look for OOM kills, I/O and SCSI errors, hung tasks and other known problems in the kernel log and syslog, or the journal when there is no syslog file. Findings are paged on a terminal and all of them are written to a findings file whose path is printed at the end. Nothing is changed on the system",
+		RunE:  runLogdoctor,
+	}
+	logdoctorCmd.Flags().StringVar(&logSince, "since", "", "Only messages since a duration ago (24h) or a date (2006-01-02 15:04); default: this boot")
+	logdoctorCmd.Flags().StringVar(&logSeverity, "severity", tuner.LogSeverities[0], "Lowest severity reported: "+strings.Join(tuner.LogSeverities, ", "))
+	logdoctorCmd.Flags().IntVar(&logLimit, "limit", tuner.DefaultLogDoctorLimit, "Findings shown per log, the most recent ones (0 for all)")
+	logdoctorCmd.Flags().StringVar(&logOutput, "output", "", "Findings file (default: in the state directory)")
+	logdoctorCmd.Flags().BoolVar(&noPager, "no-pager", false, "Print the findings without paging")
+
+	var unitsCmd = &cobra.Command{
+		Use:   "units",
+		Short: "List failed systemd units and repair them",
+		Long:  "List the failed units (systemctl --failed) with the last lines of their journal, and offer to restart, disable or reset-failed each one. Without root or a terminal the units are only listed",
+		RunE:  runUnits,
+	}
+
+	var fshealthCmd = &cobra.Command{
+		Use:   "fshealth",
+		Short: "Check the ext4 and XFS error counters and the I/O errors of their disks",
+		Long:  "Report the errors recorded by each ext4 and XFS filesystem (superblock error count, XFS health), the kernel I/O errors of the disks below them and the last fsck, with how to schedule a check or what to send to the storage admins. Nothing is changed on the system",
+		RunE:  runFSHealth,
+	}
+
+	var diskusageCmd = &cobra.Command{
+		Use:   "diskusage [dir...]",
+		Short: "Find filesystems out of inodes and the largest directories",
+		Long:  "Show the inode usage of every filesystem (\"disk full\" while df shows free space) and the directories using the most space and the most files under /var and /home, or the given directories. The walk stays on the filesystem of each directory. Nothing is changed on the system",
+		RunE:  runDiskusage,
+	}
+	diskusageCmd.Flags().IntVar(&usageDepth, "depth", tuner.DefaultDiskUsageDepth, "Directory levels reported below each directory")
+	diskusageCmd.Flags().IntVar(&usageTop, "top", tuner.DefaultDiskUsageTop, "Directories listed per ranking")
+
+	var netApplyCmd = &cobra.Command{
+		Use:    "net-apply",
+		Short:  "Configure the vmxnet3 NICs (run at boot by network-tuning.service)",
+		Long:   "Set the ring buffers, turn on gso/gro/tso and set the interrupt coalescing of every vmxnet3 interface, then place the interrupts and RPS/XPS masks of their queues on the vCPUs. Other drivers are left alone. Run at every boot by network-tuning.service",
+		Hidden: true,
+		RunE:   runNetApply,
+	}
+	netApplyCmd.Flags().IntVar(&netRxRing, "rx-ring", 0, "RX ring size (default: from the config file)")
+	netApplyCmd.Flags().IntVar(&netTxRing, "tx-ring", 0, "TX ring size (default: from the config file)")
+	netApplyCmd.Flags().IntVar(&netRxUsecs, "rx-usecs", 0, "RX interrupt coalescing in microseconds, 0 for none (default: from the config file)")
+	netApplyCmd.Flags().IntVar(&netTxUsecs, "tx-usecs", 0, "TX interrupt coalescing in microseconds, 0 for none (default: from the config file)")
+	netApplyCmd.Flags().StringVar(&netAffinity, "affinity", "", "Queue placement: spread or pin (default: from the config file)")
+
+	var grubCmd = &cobra.Command{
+		Use:   "grub",
+		Short: "Manage the GRUB boot parameters",
+	}
+	var grubResetCmd = &cobra.Command{
+		Use:   "reset",
+		Short: "Remove the boot parameters added by vmware-tuner",
+		Long:  "Remove from GRUB_CMDLINE_LINUX_DEFAULT only the parameters vmware-tuner added, restore the values it replaced and regenerate the GRUB configuration. Parameters added or changed by admins are kept. The grub file is backed up first",
+		RunE:  runGrubReset,
+	}
+	grubResetCmd.Flags().BoolVar(&dryRun, "dry-run", false, "Show the new cmdline without changing anything")
+	grubCmd.AddCommand(grubResetCmd)
+
+	var debloatCmd = &cobra.Command{
+		Use:   "debloat",
+		Short: "Server Slim: disable unused services, or enable them again",
+	}
+	var debloatUndoCmd = &cobra.Command{
+		Use:   "undo [timestamp]",
+		Short: "Restore the services disabled by Server Slim to their recorded state",
+		Long:  "Read the services disabled by a backup session, and their state before (enabled, disabled or masked, running or stopped), from its manifest and restore that state. Without a timestamp, the most recent session that disabled services is used (vmware-tuner backups lists them)",
+		Args:  cobra.MaximumNArgs(1),
+		RunE:  runDebloatUndo,
+	}
+	debloatUndoCmd.Flags().BoolVar(&dryRun, "dry-run", false, "List the services without enabling them")
+	debloatCmd.AddCommand(debloatUndoCmd)
+
+	var statusCmd = &cobra.Command{
+		Use:   "status",
+		Short: "Show the tuning state of this VM",
+		Long:  "Show the applied profile, the backups and the last post-reboot verification. --stats adds the local usage statistics (modules run, durations, failures), recorded when stats.enabled is set in the config file; nothing leaves the VM",
+		RunE:  runStatus,
+	}
+	statusCmd.Flags().BoolVar(&showStats, "stats", false, "Show the usage statistics")
+
+	var exclusionsCmd = &cobra.Command{
+		Use:   "exclusions",
+		Short: "Show the components the tool never touches",
+		Long:  "List the mounts, services, interfaces and sysctl keys of the exclusions file (--exclusions, default " + tuner.DefaultExclusionsPath + "). Every module skips them and reports the skip at the end of the run and in the action log",
+		RunE:  runExclusions,
+	}
+
+	// One run subcommand per registered module
+	var runCmd = &cobra.Command{
+		Use:   "run <module>",
+		Short: "Run one menu module without the menu",
+		Long:  "Run one module of the interactive menu directly, with the same role, environment, distribution and root checks. vmware-tuner modules lists them",
+	}
+	for _, m := range tuner.Modules() {
+		if m.Run == nil {
+			continue
+		}
+		runCmd.AddCommand(&cobra.Command{
+			Use:   m.Name,
+			Short: m.Description,
+			Args:  cobra.NoArgs,
+			RunE:  runModuleCommand(m),
+		})
+	}
+
+	var modulesCmd = &cobra.Command{
+		Use:   "modules",
+		Short: "List the modules: menu entry, category, root, distributions and flag",
+		RunE:  runModules,
+	}
+	modulesCmd.Flags().BoolVar(&modulesMarkdown, "markdown", false, "Print a Markdown table (README)")
+
+	var helpCmd = &cobra.Command{
+		Use:   "help [command | module]",
+		Short: "Help about a command, or what a module changes",
+		Long:  "Help about any command. With a module name (grub, sysctl, network...), show what the module changes, the files it touches, its risks and how to roll it back. The language follows LC_ALL/LC_MESSAGES/LANG, --lang overrides it (en, fr)",
+		RunE:  runHelp,
+	}
+	helpCmd.Flags().StringVar(&helpLang, "lang", "", "Language of the module pages (en, fr)")
+	rootCmd.SetHelpCommand(helpCmd)
+
+	var applyAllCmd = &cobra.Command{
+		Use:   "apply-all",
+		Short: "Apply everything recommended in one run (golden images)",
+		Long:  "Apply the full recommended set: boot parameters, sysctl, mount options, I/O scheduler, network, VMware Tools, the Server Slim catalog, a swapfile when no swap is active, time synchronization (after VMware Tools, which provides the offline host sync) and periodic TRIM. The plan of every module is printed first and confirmed once; --yes skips the confirmation for unattended image builds",
+		RunE:  runApplyAll,
+	}
+	applyAllCmd.Flags().StringVar(&profileName, "profile", "", profileHelp)
+	applyAllCmd.Flags().BoolVar(&dryRun, "dry-run", false, "Only print the plan")
+	applyAllCmd.Flags().BoolVarP(&assumeYes, "yes", "y", false, "Apply without asking for confirmation")
+	applyAllCmd.Flags().BoolVar(&forceRun, "force", false, "Start a new run even if an interrupted one could be resumed")
+
+	var resumeCmd = &cobra.Command{
+		Use:   "resume",
+		Short: "Continue an interrupted tuning run",
+		Long:  "Continue a tuning run interrupted by a crash, an SSH drop or a power off (plan and progress in " + tuner.RunStatePath + "): the options of the first run are replayed and the pipeline goes on from the next pending module, saving into the same backup session instead of starting a second one",
+		RunE:  runResume,
+	}
+	resumeCmd.Flags().BoolVarP(&assumeYes, "yes", "y", false, "Resume without asking for confirmation")
+
 	// Root command flags
 	rootCmd.Flags().BoolVar(&dryRun, "dry-run", false, "Show what would be done without making changes")
-	rootCmd.Flags().BoolVar(&noGrub, "no-grub", false, "Skip GRUB boot parameter tuning")
-	rootCmd.Flags().BoolVar(&noSysctl, "no-sysctl", false, "Skip sysctl parameter tuning")
-	rootCmd.Flags().BoolVar(&noFstab, "no-fstab", false, "Skip fstab optimization")
-	rootCmd.Flags().BoolVar(&noIO, "no-io", false, "Skip I/O scheduler tuning")
-	rootCmd.Flags().BoolVar(&noNet, "no-network", false, "Skip network tuning")
-	rootCmd.Flags().BoolVar(&installTools, "install-tools", true, "Install open-vm-tools if missing")
-	rootCmd.Flags().BoolVar(&doDebloat, "debloat", false, "Disable unnecessary services (Server Slim)")
+	rootCmd.Flags().BoolVar(&forceRun, "force", false, "Start a new run even if an interrupted one could be resumed")
+	rootCmd.Flags().StringArrayVar(&expertBoot, "expert-boot", nil, "Also set a lab/benchmark boot parameter (repeatable): mitigations=off, transparent_hugepage=never, nohz_full=<cpus>, isolcpus=<cpus>")
+	for _, m := range tuner.PipelineModules() {
+		pipelineFlags[m.Name] = rootCmd.Flags().Bool(m.Flag.Name, m.Flag.Default, m.Flag.Usage)
+	}
+	rootCmd.Flags().StringVar(&ipv6Mode, "ipv6-mode", "", "IPv6 mode of --with-ipv6-limit: prefer-ipv4 (default), disable, disable-boot (ipv6.disable=1, reboot)")
+	rootCmd.Flags().BoolVar(&verifyReboot, "verify-after-reboot", false, "Run 'verify' once on the next boot and record (and notify) the result")
 
 	rootCmd.AddCommand(showCmd)
 	rootCmd.AddCommand(verifyCmd)
-
-	if err := rootCmd.Execute(); err != nil {
+	rootCmd.AddCommand(auditCmd)
+	rootCmd.AddCommand(reportCmd)
+	rootCmd.AddCommand(complianceCmd)
+	rootCmd.AddCommand(bundleCmd)
+	rootCmd.AddCommand(netcheckCmd)
+	rootCmd.AddCommand(netstatsCmd)
+	rootCmd.AddCommand(balloonCmd)
+	rootCmd.AddCommand(stealCmd)
+	rootCmd.AddCommand(inventoryCmd)
+	rootCmd.AddCommand(diffCmd)
+	rootCmd.AddCommand(rollbackCmd)
+	rootCmd.AddCommand(diskbenchCmd)
+	rootCmd.AddCommand(schedbenchCmd)
+	rootCmd.AddCommand(wizardCmd)
+	rootCmd.AddCommand(backupsCmd)
+	rootCmd.AddCommand(doctorCmd)
+	rootCmd.AddCommand(logdoctorCmd)
+	rootCmd.AddCommand(unitsCmd)
+	rootCmd.AddCommand(fshealthCmd)
+	rootCmd.AddCommand(diskusageCmd)
+	rootCmd.AddCommand(netApplyCmd)
+	rootCmd.AddCommand(grubCmd)
+	rootCmd.AddCommand(debloatCmd)
+	rootCmd.AddCommand(applyAllCmd)
+	rootCmd.AddCommand(resumeCmd)
+	rootCmd.AddCommand(statusCmd)
+	rootCmd.AddCommand(exclusionsCmd)
+	rootCmd.AddCommand(runCmd)
+	rootCmd.AddCommand(modulesCmd)
+	addModuleCommands(rootCmd)
+	rootCmd.Long += envOptionsHelp()
+
+	if err := applyEnvOptions(); err != nil {
+		tuner.PrintError("%v", err)
+		os.Exit(1)
+	}
+	err := rootCmd.Execute()
+	if pkgMount != "" {
+		tuner.UnmountISO(pkgMount)
+	}
+	if pkgBundle != "" {
+		os.RemoveAll(pkgBundle)
+	}
+	if err != nil {
 		os.Exit(1)
 	}
 }
@@ -82,26 +654,14 @@ Features:
 func runTuner(cmd *cobra.Command, args []string) error {
 	tuner.Banner()
 
-	// 1. Check Connectivity
-	tuner.PrintStep("Connectivity Check")
-	hasInternet := tuner.CheckConnectivity()
-	if hasInternet {
-		tuner.PrintSuccess("Mode: Connecté (Internet accessible)")
-	} else {
-		tuner.PrintWarning("Mode: Hors-Ligne (Pas d'accès Internet détecté)")
-		tuner.PrintInfo("Certaines fonctionnalités nécessitant internet seront désactivées.")
+	cfg, gate, env, err := loadRunContext()
+	if err != nil {
+		return err
 	}
-	fmt.Println()
+	hasInternet := checkConnectivity()
 
 	// Check if running interactively (no flags)
-	if !cmd.Flags().Changed("dry-run") &&
-		!cmd.Flags().Changed("no-grub") &&
-		!cmd.Flags().Changed("no-sysctl") &&
-		!cmd.Flags().Changed("no-fstab") &&
-		!cmd.Flags().Changed("no-io") &&
-		!cmd.Flags().Changed("no-network") &&
-		!cmd.Flags().Changed("install-tools") &&
-		!cmd.Flags().Changed("debloat") {
+	if interactiveRun(cmd) {
 
 		// Initialize distro manager for all interactive commands
 		distro, err := tuner.NewDistroManager()
@@ -110,64 +670,96 @@ func runTuner(cmd *cobra.Command, args []string) error {
 			distro = &tuner.DistroManager{Type: tuner.DistroUnknown}
 		}
 
-		// Define Menu Options
-		type MenuOption struct {
-			Label       string
-			Action      func() error
-			RequireRoot bool
-		}
-
-		menu := map[int]MenuOption{
-			1: {"Optimize this VM (Tuning)", func() error {
-				return fmt.Errorf("EXIT_TO_TUNE") // Special signal to break loop and continue to tuning
-			}, true},
-			2: {"Restore a backup (Rollback)", runRollbackInteractive, true},
-			3: {"Audit System (Score)", func() error { return tuner.NewAuditTuner(distro).RunAudit() }, true},
-			4: {"Expand Disk", func() error { return tuner.NewDiskTuner(distro).ExpandRoot(hasInternet) }, true},
-			5: {"Fix Time Sync", func() error { return tuner.NewTimeSyncTuner(distro).Run(hasInternet) }, true},
-			6: {"Clean System", func() error { return tuner.NewCleanerTuner(distro).Run() }, true},
-			7: {"Secure SSH", func() error {
-				backup := tuner.NewBackupManager()
-				if err := backup.Initialize(); err != nil {
-					return err
+		// Suggest a tuning profile from the workloads found on the VM
+		if suggested := tuner.DetectProfile(distro); suggested != "" {
+			if tuner.Tuning.Profile == "" {
+				if tuner.AskUser(fmt.Sprintf("Use the %s profile for this session?", suggested)) {
+					tuner.Tuning.Profile = suggested
+				}
+			} else if tuner.Tuning.Profile != suggested {
+				tuner.PrintInfo("Keeping the selected profile: %s", tuner.Tuning.Profile)
+			}
+		}
+
+		// The menu lists the registered modules by number
+		menu := make(map[int]*tuner.Module)
+		for _, m := range tuner.MenuModules() {
+			menu[m.Menu] = m
+		}
+		base := tuner.ModuleContext{Distro: distro, Config: cfg, HasInternet: hasInternet, Version: version}
+
+		// runOption runs a menu entry after the gating checks, then offers
+		// the modules fixing what it found. It returns true for the tuning
+		// pipeline, which runs after the menu.
+		var runOption func(m *tuner.Module, caps tuner.Capabilities) bool
+		runOption = func(m *tuner.Module, caps tuner.Capabilities) bool {
+			// Continue to the tuning pipeline below
+			if m.Run == nil {
+				if err := checkModule(m, caps, gate, env, distro); err != nil {
+					tuner.PrintError("%v", err)
+					return false
+				}
+				return true
+			}
+
+			ctx := base
+			if err := runModule(m, &ctx, caps, gate, env); err != nil {
+				tuner.PrintError("%v", err)
+			}
+
+			// Jump from the findings to the module fixing them
+			offered := make(map[int]tuner.Remediation)
+			for _, r := range tuner.Remediations(tuner.TakeFindings()) {
+				target, ok := tuner.LookupModule(r.Module)
+				if !ok || target.Menu == 0 || r.Module == m.Name || caps.Check(r.Module) != nil || env.Check(r.Module) != nil {
+					continue
+				}
+				if len(offered) == 0 {
+					fmt.Println()
+					tuner.PrintInfo("Modules fixing these findings:")
 				}
-				return tuner.NewSSHTuner(backup).Run()
-			}, true},
-			8:  {"Schedule Maintenance", func() error { return tuner.NewCronTuner().Run() }, true},
-			9:  {"System Info", func() error { return tuner.NewInfoTuner().Run() }, false},
-			10: {"Network Benchmark", func() error { return tuner.NewBenchmarkTuner().Run(hasInternet) }, false},
-			11: {"Seal VM for Template (Expert)", func() error { return tuner.NewTemplateTuner().Run() }, true},
-			12: {"Check Virtual Hardware", func() error { return tuner.NewHardwareTuner(distro).Run() }, false},
-			13: {"Manage Swap", func() error { return tuner.NewSwapTuner().Run() }, true},
-			14: {"Scan Logs for Errors", func() error { return tuner.NewLogDoctorTuner(distro).Run() }, true},
-			// 15 is dynamic (Docker)
-			// 16 Updated for connectivity awareness
-			16: {"Safe System Update", func() error {
-				return tuner.NewUpdateTuner(distro).Run(hasInternet)
-			}, true},
-		}
-
-		// Add Docker option if installed
-		if _, err := exec.LookPath("docker"); err == nil {
-			menu[15] = MenuOption{"Optimize Docker", func() error { return tuner.NewDockerTuner().Run() }, true}
+				offered[target.Menu] = r
+				fmt.Printf("  [%d] %s: %s\n", target.Menu, target.Label, r.Reason)
+				if r.Context != "" {
+					fmt.Printf("       %s\n", r.Context)
+				}
+			}
+			if len(offered) == 0 {
+				return false
+			}
+			fmt.Print("Open one now (number, Enter to skip): ")
+			input, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+			k, err := strconv.Atoi(strings.TrimSpace(input))
+			r, ok := offered[k]
+			if err != nil || !ok {
+				return false
+			}
+			fmt.Println()
+			r.Prefill()
+			return runOption(menu[k], caps)
 		}
 
 		for {
 			tuner.Banner()
 			fmt.Println("What do you want to do?")
 
-			// Print menu items in order
-			var keys []int
-			for k := range menu {
-				keys = append(keys, k)
-			}
-			sort.Ints(keys)
+			// Conditional entries follow what is installed right now
+			caps := tuner.ProbeCapabilities()
 
-			for _, k := range keys {
-				fmt.Printf("  [%d] %s\n", k, menu[k].Label)
-			}
-			if _, err := exec.LookPath("docker"); err != nil {
-				color.Red("  [15] Optimize Docker (Not Installed)")
+			for _, m := range tuner.MenuModules() {
+				if err := caps.Check(m.Name); err != nil {
+					color.Red("  [%d] %s (%v)", m.Menu, m.Label, err)
+					continue
+				}
+				if env.Check(m.Name) != nil {
+					color.Yellow("  [%d] %s (Not available in %s)", m.Menu, m.Label, env)
+					continue
+				}
+				if m.CheckDistro(distro) != nil {
+					color.Yellow("  [%d] %s (Not supported on %s)", m.Menu, m.Label, distro)
+					continue
+				}
+				fmt.Printf("  [%d] %s\n", m.Menu, m.Label)
 			}
 			fmt.Println("  [0]  Exit")
 			fmt.Println()
@@ -196,23 +788,8 @@ func runTuner(cmd *cobra.Command, args []string) error {
 				continue
 			}
 
-			if option.RequireRoot {
-				if err := tuner.CheckRoot(); err != nil {
-					tuner.PrintError("%v", err)
-					tuner.Pause()
-					continue
-				}
-			}
-
-			err = option.Action()
-
-			// Check for special exit signal
-			if err != nil && err.Error() == "EXIT_TO_TUNE" {
-				break // Break loop and continue to main tuning logic
-			}
-
-			if err != nil {
-				tuner.PrintError("%v", err)
+			if runOption(option, caps) {
+				break
 			}
 
 			tuner.Pause()
@@ -223,297 +800,1577 @@ func runTuner(cmd *cobra.Command, args []string) error {
 	}
 
 	// --- TUNING LOGIC ---
-
-	// Check if running as root
-	if !dryRun {
-		if err := tuner.CheckRoot(); err != nil {
-			tuner.PrintError("%v", err)
-			return err
-		}
+	distro, proceed, err := prepareTuning(cmd, gate, env)
+	if err != nil || !proceed {
+		return err
 	}
 
-	// Check if running on VMware
-	isVMware, err := tuner.IsVMware("")
-	if err != nil {
-		tuner.PrintWarning("Could not determine if running on VMware: %v", err)
-	} else if !isVMware {
-		tuner.PrintWarning("This system does not appear to be a VMware VM")
-		tuner.PrintWarning("Tuning parameters are optimized for VMware environments")
-		fmt.Print("\nContinue anyway? (yes/no): ")
+	if dryRun {
+		tuner.PrintInfo("DRY RUN MODE - No changes will be made")
+		fmt.Println()
+	} else {
+		fmt.Print("Continue with tuning? (yes/no): ")
 		var response string
 		fmt.Scanln(&response)
 		if response != "yes" {
 			tuner.PrintInfo("Tuning cancelled")
 			return nil
 		}
-	} else {
-		tuner.PrintSuccess("Detected VMware virtual machine")
 	}
 
-	// Initialize distro manager
-	distro, err := tuner.NewDistroManager()
+	rebootRequired, summary, err := runPipeline(distro, gate, env, hasInternet, nil)
 	if err != nil {
-		tuner.PrintWarning("Could not detect distribution: %v", err)
-		// Continue with default/unknown
-		distro = &tuner.DistroManager{Type: tuner.DistroUnknown}
-	} else {
-		tuner.PrintSuccess("Detected distribution: %s", distro.Name)
+		return err
 	}
-
-	// Check and install dependencies
-	if !dryRun && !noNet {
-		if err := distro.InstallPackage("ethtool"); err != nil {
-			tuner.PrintWarning("Failed to install ethtool: %v", err)
-			tuner.PrintWarning("Network tuning might fail")
-		}
+	summary.Print()
+	tuner.PrintExclusionSkips(tuner.TakeExclusionSkips())
+	if !dryRun {
+		tuner.RecordUsage(summary)
+		tuner.PublishGuestInfo(tuner.NewGuestInfoStatus(distro, version, summary))
 	}
+	finishTuning(rebootRequired)
 
-	// Determine what will be tuned
-	var modules []string
-	if !noGrub {
-		modules = append(modules, "GRUB boot parameters")
+	// Failed modules are reported above, not usage errors
+	cmd.SilenceUsage = true
+	return summary.Err()
+}
+
+func runExclusions(cmd *cobra.Command, args []string) error {
+	tuner.ReadOnly = true
+	tuner.Banner()
+	tuner.ShowExclusions()
+	return nil
+}
+
+func runStatus(cmd *cobra.Command, args []string) error {
+	tuner.ReadOnly = true
+	tuner.Banner()
+	tuner.ShowStatus()
+	if !showStats {
+		return nil
 	}
-	if !noSysctl {
-		modules = append(modules, "Sysctl kernel parameters")
+
+	tuner.PrintStep("Usage Statistics")
+	stats, err := tuner.LoadUsageStats(tuner.StatsPath)
+	if err != nil {
+		return err
 	}
-	if !noFstab {
-		modules = append(modules, "Filesystem mount options")
+	stats.Print()
+	return nil
+}
+
+// runHelp shows the page of a module, else the help of a command
+func runHelp(cmd *cobra.Command, args []string) error {
+	lang, err := tuner.HelpLanguage(helpLang)
+	if err != nil {
+		return err
 	}
-	if !noIO {
-		modules = append(modules, "I/O scheduler configuration")
+	if len(args) == 1 {
+		if doc, ok := tuner.LookupModuleDoc(args[0]); ok {
+			tuner.RenderModuleDoc(os.Stdout, args[0], doc, lang)
+			return nil
+		}
 	}
-	if !noNet {
-		modules = append(modules, "Network interface optimization")
+
+	target, _, err := cmd.Root().Find(args)
+	if err != nil || (len(args) > 0 && target == cmd.Root()) {
+		return fmt.Errorf("unknown help topic %q: not a command or a module (%s)", strings.Join(args, " "), strings.Join(tuner.DocumentedModules(), ", "))
 	}
-	if installTools {
-		modules = append(modules, "VMware Tools verification/installation")
+	if err := target.Help(); err != nil {
+		return err
 	}
-	if doDebloat {
-		modules = append(modules, "Server Slim (disable unused services)")
+	if len(args) == 0 {
+		fmt.Printf("\nModule pages (vmware-tuner help <module>):\n  %s\n", strings.Join(tuner.DocumentedModules(), ", "))
 	}
+	return nil
+}
 
-	if len(modules) == 0 {
-		tuner.PrintError("No tuning modules selected")
-		return fmt.Errorf("nothing to do")
+// interactiveRun reports whether no dry-run, role or pipeline flag was given
+func interactiveRun(cmd *cobra.Command) bool {
+	if cmd.Flags().Changed("dry-run") || cmd.Flags().Changed("role") {
+		return false
 	}
-
-	tuner.Summary(modules)
-
-	if dryRun {
-		tuner.PrintInfo("DRY RUN MODE - No changes will be made")
-		fmt.Println()
-	} else {
-		fmt.Print("Continue with tuning? (yes/no): ")
-		var response string
-		fmt.Scanln(&response)
-		if response != "yes" {
-			tuner.PrintInfo("Tuning cancelled")
-			return nil
+	for _, m := range tuner.PipelineModules() {
+		if cmd.Flags().Changed(m.Flag.Name) {
+			return false
 		}
 	}
+	return true
+}
 
-	// Initialize backup manager
-	backup := tuner.NewBackupManager()
-	if !dryRun {
-		if err := backup.Initialize(); err != nil {
-			tuner.PrintError("Failed to initialize backup: %v", err)
+// checkModule runs the gating checks of a module: capabilities, role,
+// environment, distribution and root
+func checkModule(m *tuner.Module, caps tuner.Capabilities, gate *tuner.RoleGate, env tuner.Environment, distro *tuner.DistroManager) error {
+	if err := caps.Check(m.Name); err != nil {
+		return fmt.Errorf("%s: %w", m.Label, err)
+	}
+	if err := gate.Check(m.Name); err != nil {
+		return err
+	}
+	if err := env.Check(m.Name); err != nil {
+		return err
+	}
+	if err := m.CheckDistro(distro); err != nil {
+		return err
+	}
+	if m.RequireRoot {
+		if err := tuner.CheckRoot(); err != nil {
+			return err
+		}
+		if err := env.CheckWritable(); err != nil {
 			return err
 		}
-		tuner.PrintSuccess("Backup directory created: %s", backup.BackupDir)
 	}
+	return nil
+}
 
-	rebootRequired := false
+// runModule runs a module after the gating checks, read-only for inspection
+// modules, and records its usage and, for those changing the system, the
+// action log
+func runModule(m *tuner.Module, ctx *tuner.ModuleContext, caps tuner.Capabilities, gate *tuner.RoleGate, env tuner.Environment) error {
+	if err := checkModule(m, caps, gate, env, ctx.Distro); err != nil {
+		return err
+	}
 
-	// Apply GRUB tuning
-	if !noGrub {
-		grub := tuner.NewGrubTuner(dryRun, distro)
-		if err := grub.Apply(backup); err != nil {
-			tuner.PrintError("GRUB tuning failed: %v", err)
-		} else {
-			rebootRequired = true
-		}
+	// Inspection modules must not install or change anything
+	tuner.ReadOnly = m.ReadOnly
+	tuner.TakeFindings()
+	usage := tuner.NewRunSummary()
+	err := m.Run(ctx)
+	usage.Record(m.Name, err)
+	tuner.ReadOnly = false
+	tuner.RecordUsage(usage)
+
+	if tuner.ModifiesSystem(m.Name) {
+		logModule(m.Name, "run", err)
 	}
+	return err
+}
 
-	// Apply sysctl tuning
-	if !noSysctl {
-		sysctl := tuner.NewSysctlTuner(dryRun)
-		if err := sysctl.Apply(backup); err != nil {
-			tuner.PrintError("Sysctl tuning failed: %v", err)
+// runModuleCommand runs a module from its run subcommand
+func runModuleCommand(m *tuner.Module) func(cmd *cobra.Command, args []string) error {
+	return func(cmd *cobra.Command, args []string) error {
+		cfg, gate, env, err := loadRunContext()
+		if err != nil {
+			return err
+		}
+		distro, err := tuner.NewDistroManager()
+		if err != nil {
+			distro = &tuner.DistroManager{Type: tuner.DistroUnknown}
 		}
+		ctx := &tuner.ModuleContext{Distro: distro, Config: cfg, HasInternet: tuner.CheckConnectivity(), Version: version}
+		cmd.SilenceUsage = true
+		return withRemediations(runModule(m, ctx, tuner.ProbeCapabilities(), gate, env))
 	}
+}
 
-	// Apply fstab tuning
-	if !noFstab {
-		fstab := tuner.NewFstabTuner(dryRun)
-		if err := fstab.Apply(backup); err != nil {
-			tuner.PrintError("Fstab tuning failed: %v", err)
-		}
+// addModuleCommands adds the apply, show, verify and rollback verbs of each
+// tuning module under its own command (vmware-tuner network verify), joining
+// an existing command of the same name (grub)
+func addModuleCommands(rootCmd *cobra.Command) {
+	existing := make(map[string]*cobra.Command)
+	for _, c := range rootCmd.Commands() {
+		existing[c.Name()] = c
 	}
+	for _, m := range tuner.PipelineModules() {
+		m := m
+		parent, ok := existing[m.Name]
+		if !ok {
+			parent = &cobra.Command{
+				Use:   m.Name,
+				Short: m.Description + " (apply, show, verify, rollback)",
+			}
+			rootCmd.AddCommand(parent)
+		}
 
-	// Apply I/O scheduler tuning
-	if !noIO {
-		scheduler := tuner.NewSchedulerTuner(dryRun)
-		if err := scheduler.Apply(backup); err != nil {
-			tuner.PrintError("I/O scheduler tuning failed: %v", err)
+		applyCmd := &cobra.Command{
+			Use:   "apply",
+			Short: "Apply this module only, without the pipeline or the menu",
+			RunE:  runModuleApply(m),
+		}
+		applyCmd.Flags().BoolVar(&dryRun, "dry-run", false, "Show what would change without changing anything")
+		parent.AddCommand(applyCmd)
+
+		if m.Show != nil {
+			parent.AddCommand(&cobra.Command{
+				Use:   "show",
+				Short: "Show the current state",
+				RunE:  runModuleShow(m),
+			})
 		}
+		if m.Verify != nil {
+			parent.AddCommand(&cobra.Command{
+				Use:   "verify",
+				Short: "Check that the tuning is in effect (exit status 1 when it is not)",
+				RunE:  runModuleVerify(m),
+			})
+		}
+		parent.AddCommand(&cobra.Command{
+			Use:   "rollback",
+			Short: "Restore the files of this module from its latest backup",
+			RunE:  runModuleRollback(m),
+		})
 	}
+}
 
-	// Apply network tuning
-	if !noNet {
-		network := tuner.NewNetworkTuner(dryRun)
-		if err := network.Apply(backup); err != nil {
-			tuner.PrintError("Network tuning failed: %v", err)
-		}
+// moduleContext builds the context of a module subcommand
+func moduleContext(cfg *tuner.Config) *tuner.ModuleContext {
+	distro, err := tuner.NewDistroManager()
+	if err != nil {
+		distro = &tuner.DistroManager{Type: tuner.DistroUnknown}
 	}
+	return &tuner.ModuleContext{DryRun: dryRun, Distro: distro, Config: cfg, Version: version}
+}
+
+// runModuleApply applies one tuning module, with the gating checks of the
+// pipeline and a backup session of its own
+func runModuleApply(m *tuner.Module) func(cmd *cobra.Command, args []string) error {
+	return func(cmd *cobra.Command, args []string) error {
+		cfg, gate, env, err := loadRunContext()
+		if err != nil {
+			return err
+		}
+		cmd.SilenceUsage = true
+		ctx := moduleContext(cfg)
+		ctx.HasInternet = tuner.CheckConnectivity()
+
+		// A dry run changes nothing: no root needed, as for the pipeline
+		checked := *m
+		checked.RequireRoot = m.RequireRoot && !dryRun
+		if err := checkModule(&checked, tuner.ProbeCapabilities(), gate, env, ctx.Distro); err != nil {
+			return err
+		}
+		if _, err := ctx.BackupSession(); err != nil {
+			return err
+		}
 
-	// Apply VM Tools
-	if installTools {
-		tools := tuner.NewVMToolsTuner(dryRun, distro)
-		// Pass connectivity status to Apply
-		if err := tools.Apply(hasInternet); err != nil {
-			tuner.PrintError("VM Tools tuning failed: %v", err)
+		usage := tuner.NewRunSummary()
+		err = m.Apply(ctx)
+		usage.Record(m.Name, err)
+		tuner.PrintExclusionSkips(tuner.TakeExclusionSkips())
+		tuner.RecordUsage(usage)
+		logModule(m.Name, "apply", err)
+		if err != nil {
+			return err
+		}
+		if ctx.RebootRequired && !dryRun {
+			tuner.PrintWarning("Reboot required for %s to take effect", m.Name)
 		}
+		return nil
 	}
+}
 
-	// Apply Debloat (Interactive or Flag)
-	debloat := tuner.NewDebloatTuner(dryRun)
-	if doDebloat {
-		// Flag provided: do it automatically
-		if err := debloat.Apply(backup); err != nil {
-			tuner.PrintError("Debloat failed: %v", err)
+// runModuleShow prints the current state of one module, read-only
+func runModuleShow(m *tuner.Module) func(cmd *cobra.Command, args []string) error {
+	return func(cmd *cobra.Command, args []string) error {
+		cfg, err := tuner.LoadConfig(configPath)
+		if err != nil {
+			return err
 		}
+		cmd.SilenceUsage = true
+		tuner.ReadOnly = true
+		defer func() { tuner.ReadOnly = false }()
+		return m.Show(moduleContext(cfg))
+	}
+}
+
+// runModuleVerify checks one module; a module that does not apply here
+// passes
+func runModuleVerify(m *tuner.Module) func(cmd *cobra.Command, args []string) error {
+	return func(cmd *cobra.Command, args []string) error {
+		cfg, err := tuner.LoadConfig(configPath)
+		if err != nil {
+			return err
+		}
+		cmd.SilenceUsage = true
+		tuner.ReadOnly = true
+		defer func() { tuner.ReadOnly = false }()
+
+		tuner.PrintStep("Verifying " + m.Description)
+		err = m.Verify(moduleContext(cfg))
+		switch {
+		case errors.Is(err, tuner.ErrVerifySkipped):
+			tuner.PrintInfo("%v", err)
+			return nil
+		case err != nil:
+			tuner.PrintError("%v", err)
+			return fmt.Errorf("%s: tuning not in effect", m.Name)
+		}
+		tuner.PrintSuccess("%s verified", m.Name)
+		return nil
+	}
+}
+
+// runModuleRollback restores the files of one module from the latest backup
+// session holding them
+func runModuleRollback(m *tuner.Module) func(cmd *cobra.Command, args []string) error {
+	return func(cmd *cobra.Command, args []string) error {
+		cmd.SilenceUsage = true
+		if err := tuner.CheckRoot(); err != nil {
+			return err
+		}
+		session, files, err := tuner.LatestBackupOf(m.Files())
+		if err != nil {
+			return err
+		}
+		if session == "" {
+			hint := ""
+			if doc, ok := tuner.LookupModuleDoc(m.Name); ok {
+				hint = ": " + doc.Rollback.In("en")
+			}
+			return fmt.Errorf("no backup holds the files of %s%s", m.Name, hint)
+		}
+		tuner.PrintInfo("Restoring %s from %s: %s", m.Name, session, strings.Join(files, ", "))
+		err = restoreBackup(session, files, false)
+		logModule(m.Name, "rollback", err)
+		return err
+	}
+}
+
+// runModules lists the registered modules by category
+func runModules(cmd *cobra.Command, args []string) error {
+	if modulesMarkdown {
+		fmt.Println("| Module | Menu | Category | Root | Distributions | Flag | Description |")
+		fmt.Println("|---|---|---|---|---|---|---|")
+	}
+	for _, category := range tuner.Categories {
+		if !modulesMarkdown {
+			tuner.PrintStep(category)
+		}
+		for _, m := range tuner.Modules() {
+			if m.Category != category {
+				continue
+			}
+			menu, flag, root := "-", "-", "no"
+			if m.Menu != 0 {
+				menu = strconv.Itoa(m.Menu)
+			}
+			if m.Flag != nil {
+				flag = "--" + m.Flag.Name
+			}
+			if m.RequireRoot {
+				root = "yes"
+			}
+			description := m.Description
+			if m.ReadOnly {
+				description += " (read-only)"
+			}
+			if modulesMarkdown {
+				fmt.Printf("| `%s` | %s | %s | %s | %s | `%s` | %s |\n", m.Name, menu, m.Category, root, m.DistroNames(), flag, description)
+				continue
+			}
+			fmt.Printf("  %-14s [%2s] root: %-3s  %s\n", m.Name, menu, root, description)
+			if m.Flag != nil || len(m.Distros) > 0 {
+				fmt.Printf("  %-14s      flag: %s, distributions: %s\n", "", flag, m.DistroNames())
+			}
+		}
+	}
+	return nil
+}
+
+// runApplyAll enables every recommended module, prints the plan as a dry run
+// of the pipeline and applies it after a single confirmation
+func runApplyAll(cmd *cobra.Command, args []string) error {
+	tuner.Banner()
+
+	_, gate, env, err := loadRunContext()
+	if err != nil {
+		return err
+	}
+	hasInternet := checkConnectivity()
+
+	// Everything recommended: the opt-in modules too, except the limits,
+	// PVSCSI and shared folders ones left to the profile and the IPv6 limits
+	// left to the network
+	for _, m := range tuner.PipelineModules() {
+		if m.Name != "limits" && m.Name != "pvscsi" && m.Name != "hgfs" && m.Name != "ipv6" {
+			enablePipeline(m, true)
+		}
+	}
+
+	distro, proceed, err := prepareTuning(cmd, gate, env)
+	if err != nil || !proceed {
+		return err
+	}
+
+	// The plan is a dry run of the same pipeline
+	tuner.PrintStep("Plan")
+	planOnly := dryRun
+	dryRun = true
+	_, plan, err := runPipeline(distro, gate, env, hasInternet, nil)
+	if err != nil {
+		return err
+	}
+	plan.Print()
+	if lang, err := tuner.HelpLanguage(""); err == nil {
+		fmt.Println()
+		tuner.PrintInfo("Files and rollback (vmware-tuner help <module> for details):")
+		tuner.PrintModuleDocs(plan.Succeeded, lang)
+	}
+	dryRun = planOnly
+	if dryRun {
+		finishTuning(false)
+		return nil
+	}
+
+	fmt.Println()
+	if !assumeYes {
+		fmt.Print("Apply this plan? (yes/no): ")
+		var response string
+		fmt.Scanln(&response)
+		if response != "yes" {
+			tuner.PrintInfo("Tuning cancelled")
+			return nil
+		}
+	}
+
+	rebootRequired, summary, err := runPipeline(distro, gate, env, hasInternet, nil)
+	if err != nil {
+		return err
+	}
+	summary.Print()
+	tuner.PrintExclusionSkips(tuner.TakeExclusionSkips())
+	tuner.RecordUsage(summary)
+	tuner.PublishGuestInfo(tuner.NewGuestInfoStatus(distro, version, summary))
+	finishTuning(rebootRequired)
+
+	// Failed modules are reported above, not usage errors
+	cmd.SilenceUsage = true
+	return summary.Err()
+}
+
+// loadOptions applies the config file and the global options to the tuners
+func loadOptions(cmd *cobra.Command) error {
+	// A proxy from the config file applies to every download and package install,
+	// tuning values to every tuner. An invalid file stops the run rather than
+	// tuning with the defaults.
+	cfg, err := tuner.LoadConfig(configPath)
+	if err != nil {
+		cmd.SilenceUsage = true
+		return err
+	}
+	proxy := cfg.Proxy
+	cfg.Tuning.Activate()
+	tuner.StatsEnabled = cfg.Stats.Enabled
+	tuner.SetTheme(cfg.UI.Theme)
+	if envProxy != "" {
+		proxy.HTTP, proxy.HTTPS = envProxy, envProxy
+	}
+	proxy.Resolve().Export()
+	if envBackupDir != "" {
+		tuner.BackupRoot = envBackupDir
+	}
+	if err := tuner.SetTheme(themeName); err != nil {
+		return err
+	}
+	if profileName != "" {
+		if _, err := tuner.LookupProfile(profileName); err != nil {
+			return err
+		}
+		tuner.Tuning.Profile = profileName
+	}
+	if len(expertBoot) > 0 {
+		tuner.Tuning.ExpertBoot = expertBoot
+	}
+	if ipv6Mode != "" {
+		if err := tuner.ValidIPv6Mode(ipv6Mode); err != nil {
+			return err
+		}
+		tuner.Tuning.IPv6 = ipv6Mode
+	}
+	if len(nicNames) > 0 || len(nicDrivers) > 0 {
+		selection := tuner.InterfaceSelection{Names: nicNames, Drivers: nicDrivers}
+		if err := selection.Validate(); err != nil {
+			return err
+		}
+		tuner.Tuning.Interfaces = selection
+	}
+	// A broken exclusions file stops the run: it protects what must not be touched
+	if err := tuner.LoadExclusions(exclusions); err != nil {
+		return err
+	}
+	return nil
+}
+
+// setupPackageDir points the package installs at --pkg-dir: a directory, an
+// ISO image (mounted) or a bundle archive (extracted)
+func setupPackageDir() error {
+	if pkgDir == "" {
+		return nil
+	}
+	abs, err := filepath.Abs(pkgDir)
+	if err != nil {
+		return err
+	}
+	info, err := os.Stat(abs)
+	if err != nil {
+		return fmt.Errorf("package directory not found: %s", pkgDir)
+	}
+	// An ISO image is mounted, a bundle archive (vmware-tuner bundle
+	// create) is unpacked first
+	switch {
+	case info.IsDir():
+	case strings.EqualFold(filepath.Ext(abs), ".iso"):
+		if abs, err = tuner.MountISO(abs); err != nil {
+			return err
+		}
+		pkgMount = abs
+	default:
+		if abs, err = tuner.ExtractBundle(abs); err != nil {
+			return err
+		}
+		pkgBundle = abs
+	}
+	tuner.PackageDir = abs
+	return nil
+}
+
+// runResume continues an interrupted tuning run from its next pending module,
+// with the options and the backup session of the first run
+func runResume(cmd *cobra.Command, args []string) error {
+	tuner.Banner()
+
+	state, err := tuner.LoadRunState(tuner.RunStatePath)
+	if err != nil {
+		tuner.PrintError("%v", err)
+		return err
+	}
+	if state == nil {
+		tuner.PrintInfo("No interrupted run to resume")
+		return nil
+	}
+	tuner.PrintStep("Interrupted Run")
+	tuner.PrintRunState(state)
+	pending := state.Pending()
+	if len(pending) == 0 {
+		// Interrupted after its last module
+		tuner.PrintSuccess("Every module of the plan has run")
+		return tuner.ClearRunState(tuner.RunStatePath)
+	}
+
+	// The options of the first run: config, profile, interfaces, exclusions...
+	root := cmd.Root()
+	first, rest, err := root.Find(state.Args)
+	if err == nil {
+		err = first.ParseFlags(rest)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to replay the options of the interrupted run: %w", err)
+	}
+	if err := loadOptions(first); err != nil {
+		return err
+	}
+	if err := setupPackageDir(); err != nil {
+		return err
+	}
+	dryRun = false
+
+	_, gate, env, err := loadRunContext()
+	if err != nil {
+		return err
+	}
+	if err := tuner.CheckRoot(); err != nil {
+		tuner.PrintError("%v", err)
+		return err
+	}
+	if err := env.CheckWritable(); err != nil {
+		tuner.PrintError("%v", err)
+		return err
+	}
+	hasInternet := checkConnectivity()
+
+	distro, err := tuner.NewDistroManager()
+	if err != nil {
+		tuner.PrintWarning("Could not detect distribution: %v", err)
+		distro = &tuner.DistroManager{Type: tuner.DistroUnknown}
+	}
+
+	// Only the pending modules of the plan, whatever the replayed flags enable
+	var modules []string
+	for _, m := range tuner.PipelineModules() {
+		on := false
+		for _, name := range pending {
+			if name == m.Name {
+				on = true
+			}
+		}
+		enablePipeline(m, on)
+		if on {
+			modules = append(modules, m.Description)
+		}
+	}
+	tuner.PrintInfo("Tuning profile: %s", tuner.Tuning.ActiveProfile().Name)
+	tuner.Summary(modules)
+
+	if !assumeYes {
+		fmt.Print("Resume this run? (yes/no): ")
+		var response string
+		fmt.Scanln(&response)
+		if response != "yes" {
+			tuner.PrintInfo("Resume cancelled, vmware-tuner resume can be run again later")
+			return nil
+		}
+	}
+
+	rebootRequired, summary, err := runPipeline(distro, gate, env, hasInternet, state)
+	if err != nil {
+		return err
+	}
+	summary.Print()
+	tuner.PrintExclusionSkips(tuner.TakeExclusionSkips())
+	tuner.RecordUsage(summary)
+	tuner.PublishGuestInfo(tuner.NewGuestInfoStatus(distro, version, summary))
+	finishTuning(rebootRequired)
+
+	cmd.SilenceUsage = true
+	return summary.Err()
+}
+
+// loadRunContext loads the configuration, the role and the environment of a
+// tuning run
+func loadRunContext() (*tuner.Config, *tuner.RoleGate, tuner.Environment, error) {
+	cfg, err := tuner.LoadConfig(configPath)
+	if err != nil {
+		return nil, nil, tuner.Environment{}, err
+	}
+	gate, err := tuner.NewRoleGate(cfg, role, overrideRole)
+	if err != nil {
+		tuner.PrintError("%v", err)
+		return nil, nil, tuner.Environment{}, err
+	}
+	if gate != nil {
+		tuner.PrintInfo("Role: %s (modules: %s)", gate.Role, strings.Join(gate.Modules(), ", "))
+	}
+
+	env := tuner.DetectEnvironment("")
+	switch env.Kind {
+	case tuner.EnvMachine:
+	case tuner.EnvRescue:
+		tuner.PrintWarning("Running in %s: only file changes (GRUB, fstab) are available, services and live settings need a normal boot", env)
+	default:
+		tuner.PrintWarning("Running in %s: modules that need a real VM are disabled", env)
+	}
+	return cfg, gate, env, nil
+}
+
+// checkConnectivity prints whether the VM reaches the Internet
+func checkConnectivity() bool {
+	tuner.PrintStep("Connectivity Check")
+	hasInternet := tuner.CheckConnectivity()
+	if hasInternet {
+		tuner.PrintSuccess("Mode: Connecté (Internet accessible)")
+	} else {
+		tuner.PrintWarning("Mode: Hors-Ligne (Pas d'accès Internet détecté)")
+		tuner.PrintInfo("Certaines fonctionnalités nécessitant internet seront désactivées.")
+		tuner.PrintInfo("Diagnostic détaillé : vmware-tuner netcheck")
+	}
+	fmt.Println()
+	return hasInternet
+}
+
+// prepareTuning runs the checks of the tuning pipeline and prints the modules
+// it will apply. proceed is false when the user cancelled.
+func prepareTuning(cmd *cobra.Command, gate *tuner.RoleGate, env tuner.Environment) (distro *tuner.DistroManager, proceed bool, err error) {
+	// Check if running as root
+	if !dryRun {
+		if err := tuner.CheckRoot(); err != nil {
+			tuner.PrintError("%v", err)
+			return nil, false, err
+		}
+		if err := env.CheckWritable(); err != nil {
+			tuner.PrintError("%v", err)
+			return nil, false, err
+		}
+		// An interrupted run goes on with resume: starting over would replace
+		// its state, so only with --force
+		if state, err := tuner.LoadRunState(tuner.RunStatePath); err == nil && state != nil {
+			tuner.PrintWarning("A previous run was interrupted before: %s", strings.Join(state.Pending(), ", "))
+			if !forceRun {
+				tuner.PrintInfo("-> vmware-tuner resume continues it in its backup session %s", state.Backup)
+				tuner.PrintInfo("-> --force starts a new run instead (the interrupted one can no longer be resumed)")
+				err := fmt.Errorf("an interrupted run can be resumed (vmware-tuner resume), use --force to start a new one")
+				tuner.PrintError("%v", err)
+				cmd.SilenceUsage = true
+				return nil, false, err
+			}
+			tuner.PrintInfo("--force: this run starts a new backup session, %s can no longer be resumed", state.Backup)
+		}
+	}
+
+	// High-IOPS profiles include the PVSCSI options unless --with-pvscsi=false
+	if pvscsi, ok := tuner.LookupModule("pvscsi"); ok && tuner.Tuning.ActiveProfile().PVSCSI && !cmd.Flags().Changed(pvscsi.Flag.Name) {
+		enablePipeline(pvscsi, true)
+	}
+	// So does the developer profile with the shared folders, unless --with-hgfs=false
+	if hgfs, ok := tuner.LookupModule("hgfs"); ok && tuner.Tuning.ActiveProfile().HGFS && !cmd.Flags().Changed(hgfs.Flag.Name) {
+		enablePipeline(hgfs, true)
+	}
+	if err := applyRoleGate(cmd, gate); err != nil {
+		tuner.PrintError("%v", err)
+		return nil, false, err
+	}
+	applyEnvironment(env)
+
+	// Check if running on VMware
+	isVMware, err := tuner.IsVMware("")
+	if err != nil {
+		tuner.PrintWarning("Could not determine if running on VMware: %v", err)
+	} else if !isVMware {
+		tuner.PrintWarning("This system does not appear to be a VMware VM")
+		tuner.PrintWarning("Tuning parameters are optimized for VMware environments")
+		if !assumeYes {
+			fmt.Print("\nContinue anyway? (yes/no): ")
+			var response string
+			fmt.Scanln(&response)
+			if response != "yes" {
+				tuner.PrintInfo("Tuning cancelled")
+				return nil, false, nil
+			}
+		}
+	} else {
+		tuner.PrintSuccess("Detected VMware virtual machine")
+	}
+
+	// Initialize distro manager
+	distro, err = tuner.NewDistroManager()
+	if err != nil {
+		tuner.PrintWarning("Could not detect distribution: %v", err)
+		// Continue with default/unknown
+		distro = &tuner.DistroManager{Type: tuner.DistroUnknown}
+	} else {
+		tuner.PrintSuccess("Detected distribution: %s", distro)
+	}
+
+	// Modules needing a package manager or boot tooling the distribution lacks
+	for _, m := range tuner.PipelineModules() {
+		if !pipelineEnabled(m) {
+			continue
+		}
+		if err := m.CheckDistro(distro); err != nil {
+			tuner.PrintWarning("%v (skipped)", err)
+			enablePipeline(m, false)
+		}
+	}
+
+	// Determine what will be tuned
+	var modules []string
+	for _, m := range tuner.PipelineModules() {
+		if pipelineEnabled(m) {
+			modules = append(modules, m.Description)
+		}
+	}
+
+	if len(modules) == 0 {
+		tuner.PrintError("No tuning modules selected")
+		return nil, false, fmt.Errorf("nothing to do")
+	}
+
+	tuner.PrintInfo("Tuning profile: %s", tuner.Tuning.ActiveProfile().Name)
+	tuner.Summary(modules)
+	return distro, true, nil
+}
+
+// runPipeline applies the enabled tuning modules in order and reports whether
+// a reboot is needed. A failed module does not stop the others: the summary
+// lists them at the end. The plan and progress are saved in RunStatePath so
+// an interrupted run can be resumed; resume continues one with its backup
+// session.
+func runPipeline(distro *tuner.DistroManager, gate *tuner.RoleGate, env tuner.Environment, hasInternet bool, resume *tuner.RunState) (bool, *tuner.RunSummary, error) {
+	// Initialize backup manager
+	backup := tuner.NewBackupManager()
+	state := resume
+	if resume != nil {
+		var err error
+		if backup, err = tuner.OpenBackupSession(resume.Backup); err != nil {
+			tuner.PrintError("%v", err)
+			return false, nil, err
+		}
+		tuner.PrintSuccess("Backup session resumed: %s", backup.BackupDir)
 	} else if !dryRun {
-		// No flag: ask interactively
-		services := debloat.GetBloatServices()
-		if len(services) > 0 {
-			tuner.PrintStep("Server Slim Mode (Optional)")
-			tuner.PrintInfo("Found %d services that are usually unnecessary on servers:", len(services))
-			for _, svc := range services {
-				fmt.Printf("  - %s: %s\n", svc.Name, svc.Description)
+		if err := backup.Initialize(); err != nil {
+			tuner.PrintError("Failed to initialize backup: %v", err)
+			return false, nil, err
+		}
+		tuner.PrintSuccess("Backup directory created: %s", backup.BackupDir)
+
+		var plan []string
+		for _, m := range tuner.PipelineModules() {
+			if pipelineEnabled(m) {
+				plan = append(plan, m.Name)
 			}
-			fmt.Println()
-			fmt.Print("Do you want to disable these services? (y/n): ")
+		}
+		state = tuner.NewRunState(version, os.Args[1:], backup.BackupDir, plan)
+	}
+	saveState := func() {
+		if state == nil {
+			return
+		}
+		// Best-effort: without it the run only cannot be resumed
+		if err := state.Save(tuner.RunStatePath); err != nil {
+			tuner.PrintWarning("Run state not saved, an interruption could not be resumed: %v", err)
+		}
+	}
+	saveState()
+
+	summary := tuner.NewRunSummary()
+	ctx := &tuner.ModuleContext{DryRun: dryRun, Distro: distro, HasInternet: hasInternet, Version: version, Backup: backup}
+	if resume != nil {
+		ctx.RebootRequired = resume.RebootRequired
+	}
+
+	for _, m := range tuner.PipelineModules() {
+		if !pipelineEnabled(m) {
+			// Without its flag a module may still be offered interactively,
+			// by the first run only
+			if m.Offer != nil && !dryRun && resume == nil && gate.Allows(m.Name) && env.Check(m.Name) == nil && m.CheckDistro(distro) == nil {
+				applied, err := m.Offer(ctx)
+				if err != nil {
+					tuner.PrintError("%s failed: %v", m.Description, err)
+				}
+				if applied {
+					summary.Record(m.Name, err)
+					logModule(m.Name, "apply", err)
+				}
+			}
+			continue
+		}
+
+		err := m.Apply(ctx)
+		if err != nil {
+			tuner.PrintError("%s failed: %v", m.Description, err)
+		}
+		summary.Record(m.Name, err)
+		logModule(m.Name, "apply", err)
+		if state != nil {
+			state.Record(m.Name, err, ctx.RebootRequired)
+			saveState()
+		}
+	}
+	rebootRequired := ctx.RebootRequired
+
+	// Boot-time changes (GRUB, udev, fstab) are only proven by the next boot
+	if verifyReboot {
+		// Notifications are sent by the post-boot run, which reads the config then
+		err := tuner.NewPostBootVerifier(dryRun, tuner.NotifyConfig{}).Install(backup)
+		if err != nil {
+			tuner.PrintError("Post-reboot verification setup failed: %v", err)
+		}
+		summary.Record("verify", err)
+		logModule("verify", "install-post-boot", err)
+	}
+
+	if state != nil {
+		if err := tuner.ClearRunState(tuner.RunStatePath); err != nil {
+			tuner.PrintWarning("%v", err)
+		}
+	}
+
+	// Create rollback script (REMOVED - using manifest)
+	// if !dryRun {
+	// 	if err := backup.CreateRollbackScript(); err != nil {
+	// 		tuner.PrintWarning("Failed to create rollback script: %v", err)
+	// 	}
+	// }
+	return rebootRequired, summary, nil
+}
+
+// finishTuning prints the completion message and offers to reboot
+func finishTuning(rebootRequired bool) {
+	if !dryRun {
+		tuner.CompletionMessage(rebootRequired)
+
+		if rebootRequired && assumeYes {
+			// Unattended image builds reboot or seal the VM themselves
+			tuner.PrintInfo("Please remember to reboot later")
+		} else if rebootRequired {
+			fmt.Print("Do you want to reboot now? (y/n): ")
 			var response string
 			fmt.Scanln(&response)
 			if response == "y" || response == "yes" {
-				if err := debloat.DisableServices(services, backup); err != nil {
-					tuner.PrintError("Debloat failed: %v", err)
-				}
+				tuner.PrintInfo("Rebooting system...")
+				exec.Command("reboot").Run()
 			} else {
-				tuner.PrintInfo("Skipping Server Slim optimization")
+				tuner.PrintInfo("Please remember to reboot later")
+			}
+		}
+	} else {
+		fmt.Println()
+		tuner.PrintInfo("DRY RUN completed - no changes were made")
+		tuner.PrintInfo("Run without --dry-run to apply changes")
+	}
+}
+
+// logModule records the outcome of a module in the action log and journal.
+// Dry runs change nothing and are not logged.
+func logModule(module, action string, err error) {
+	if dryRun {
+		return
+	}
+	if err != nil {
+		tuner.LogAction(module, action, tuner.ResultFailed, err.Error())
+		return
+	}
+	tuner.LogAction(module, action, tuner.ResultSuccess, "")
+}
+
+// applyEnvironment disables the tuning modules that cannot run in a container,
+// WSL or a rescue environment
+func applyEnvironment(env tuner.Environment) {
+	var skipped []string
+	wasSkipped := make(map[string]bool)
+	for _, m := range tuner.PipelineModules() {
+		if !pipelineEnabled(m) {
+			continue // already disabled
+		}
+		if err := env.Check(m.Name); err != nil {
+			tuner.PrintWarning("%v (skipped)", err)
+			enablePipeline(m, false)
+			skipped = append(skipped, m.Name)
+			wasSkipped[m.Name] = true
+		}
+	}
+
+	// From a chroot the VM boots normally afterwards: say what is left to do
+	if env.Kind == tuner.EnvRescue && len(skipped) > 0 {
+		var flags []string
+		for _, m := range tuner.PipelineModules() {
+			switch {
+			case pipelineEnabled(m) && m.Flag.Negated:
+				flags = append(flags, "--"+m.Flag.Name) // done now
+			case pipelineEnabled(m):
+				flags = append(flags, "--"+m.Flag.Name+"=false")
+			case !m.Flag.Negated && wasSkipped[m.Name]:
+				flags = append(flags, "--"+m.Flag.Name) // opt-in module to run later
+			}
+		}
+		tuner.PrintInfo("After booting the VM normally, apply the skipped modules (%s) with:", strings.Join(skipped, ", "))
+		tuner.PrintInfo("  sudo vmware-tuner %s", strings.Join(flags, " "))
+	}
+}
+
+// applyRoleGate restricts the tuning pipeline to the modules sanctioned by the role.
+// Sanctioned modules are enabled unless explicitly disabled by a flag; explicitly
+// requested modules outside the role fail unless --override-role is set.
+func applyRoleGate(cmd *cobra.Command, gate *tuner.RoleGate) error {
+	if gate == nil {
+		return nil
+	}
+
+	for _, m := range tuner.PipelineModules() {
+		changed := cmd.Flags().Changed(m.Flag.Name)
+		requested := pipelineEnabled(m)
+
+		if gate.Allows(m.Name) {
+			if !changed {
+				enablePipeline(m, true)
+			}
+			continue
+		}
+
+		if changed && requested {
+			if err := gate.Check(m.Name); err != nil {
+				return err
 			}
+			continue
+		}
+		enablePipeline(m, false)
+	}
+
+	return nil
+}
+
+func showConfig(cmd *cobra.Command, args []string) error {
+	tuner.ReadOnly = true
+	tuner.Banner()
+	tuner.PrintInfo("Current System Configuration")
+	fmt.Println()
+
+	// Initialize distro manager for config paths
+	distro, _ := tuner.NewDistroManager()
+
+	tuner.ShowProfile()
+
+	// Show GRUB config
+	grub := tuner.NewGrubTuner(false, distro)
+	if err := grub.ShowCurrent(); err != nil {
+		tuner.PrintWarning("Could not show GRUB config: %v", err)
+	}
+
+	// Show sysctl config
+	sysctl := tuner.NewSysctlTuner(false)
+	if err := sysctl.ShowCurrent(); err != nil {
+		tuner.PrintWarning("Could not show sysctl config: %v", err)
+	}
+
+	// Show fstab config
+	fstab := tuner.NewFstabTuner(false)
+	if err := fstab.ShowCurrent(); err != nil {
+		tuner.PrintWarning("Could not show fstab config: %v", err)
+	}
+
+	// Show I/O scheduler config
+	scheduler := tuner.NewSchedulerTuner(false)
+	if err := scheduler.ShowCurrent(); err != nil {
+		tuner.PrintWarning("Could not show I/O scheduler config: %v", err)
+	}
+
+	// Show network config
+	network := tuner.NewNetworkTuner(false)
+	if err := network.ShowCurrent(); err != nil {
+		tuner.PrintWarning("Could not show network config: %v", err)
+	}
+	if err := tuner.NewIPv6Tuner(false, distro).ShowCurrent(); err != nil {
+		tuner.PrintWarning("Could not show the IPv6 state: %v", err)
+	}
+
+	return nil
+}
+
+func verifyConfig(cmd *cobra.Command, args []string) error {
+	tuner.ReadOnly = true
+	distro, err := tuner.NewDistroManager()
+	if err != nil {
+		distro = &tuner.DistroManager{Type: tuner.DistroUnknown}
+	}
+
+	if verifyJSON || postBoot {
+		return verifyReport(distro)
+	}
+
+	tuner.Banner()
+	tuner.PrintStep("Verifying tuning configuration")
+
+	allGood := true
+	for _, check := range tuner.CollectVerifyChecks(distro) {
+		if check.Skipped {
+			tuner.PrintInfo("%s: skipped (%s)", check.Name, check.Message)
+		}
+		if !check.OK {
+			tuner.PrintWarning("%s: %s", check.Name, check.Message)
+			allGood = false
+		}
+	}
+
+	fmt.Println()
+	if allGood {
+		tuner.PrintSuccess("All tuning configurations are present")
+	} else {
+		tuner.PrintWarning("Some tuning configurations are missing")
+		tuner.PrintInfo("Run 'vmware-tuner' to apply tuning")
+	}
+
+	return nil
+}
+
+// verifyReport prints the verify checks as JSON; with --post-boot the result
+// is also recorded for the post-reboot verification unit
+func verifyReport(distro *tuner.DistroManager) error {
+	// Check details go to stderr so stdout stays valid JSON
+	stdout, colorOut := os.Stdout, color.Output
+	os.Stdout, color.Output = os.Stderr, os.Stderr
+	report := tuner.NewVerifyReport(distro)
+	os.Stdout, color.Output = stdout, colorOut
+
+	if postBoot {
+		cfg, err := tuner.LoadConfig(configPath)
+		if err != nil {
+			return err
+		}
+		if err := tuner.NewPostBootVerifier(false, cfg.Notify).Record(report); err != nil {
+			return err
+		}
+	}
+
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(data))
+	return nil
+}
+
+func runAudit(cmd *cobra.Command, args []string) error {
+	tuner.ReadOnly = true
+	tuner.Banner()
+
+	distro, err := tuner.NewDistroManager()
+	if err != nil {
+		distro = &tuner.DistroManager{Type: tuner.DistroUnknown}
+	}
+	audit := tuner.NewAuditTuner(distro)
+
+	switch auditProfile {
+	case "standard":
+		return audit.RunAudit()
+	case "latency":
+		return audit.RunLatencyAudit()
+	default:
+		return fmt.Errorf("unknown audit profile: %s (use standard or latency)", auditProfile)
+	}
+}
+
+func runReport(cmd *cobra.Command, args []string) error {
+	tuner.ReadOnly = true
+	tuner.Banner()
+
+	distro, err := tuner.NewDistroManager()
+	if err != nil {
+		distro = &tuner.DistroManager{Type: tuner.DistroUnknown}
+	}
+
+	cfg, err := tuner.LoadConfig(configPath)
+	if err != nil {
+		return err
+	}
+
+	hasInternet := false
+	if reportBench {
+		hasInternet = tuner.CheckConnectivity()
+	}
+
+	rt := tuner.NewReportTuner(distro, version)
+	rt.Redactor = outputRedactor(cfg)
+	return rt.Generate(reportDir, reportBench, hasInternet, reportPDF)
+}
+
+func runCompliance(cmd *cobra.Command, args []string) error {
+	tuner.ReadOnly = true
+
+	cfg, err := tuner.LoadConfig(configPath)
+	if err != nil {
+		return err
+	}
+
+	distro, err := tuner.NewDistroManager()
+	if err != nil {
+		distro = &tuner.DistroManager{Type: tuner.DistroUnknown}
+	}
+
+	return tuner.NewComplianceTuner(distro, cfg.Notify).Check(!noNotify)
+}
+
+func runNetcheck(cmd *cobra.Command, args []string) error {
+	tuner.ReadOnly = true
+	tuner.Banner()
+
+	cfg, err := tuner.LoadConfig(configPath)
+	if err != nil {
+		return err
+	}
+	distro, err := tuner.NewDistroManager()
+	if err != nil {
+		distro = &tuner.DistroManager{Type: tuner.DistroUnknown}
+	}
+	return tuner.NewNetCheckTuner(distro, cfg.VCenter).Run()
+}
+
+func runNetstats(cmd *cobra.Command, args []string) error {
+	tuner.Banner()
+
+	network := tuner.NewNetworkTuner(false)
+	if statsWatch {
+		if statsEvery <= 0 {
+			return fmt.Errorf("--interval must be positive")
 		}
+		return network.WatchPacketDrops(statsEvery)
 	}
+	return withRemediations(network.CheckPacketDrops())
+}
 
-	// Create rollback script (REMOVED - using manifest)
-	// if !dryRun {
-	// 	if err := backup.CreateRollbackScript(); err != nil {
-	// 		tuner.PrintWarning("Failed to create rollback script: %v", err)
-	// 	}
-	// }
+func runBalloon(cmd *cobra.Command, args []string) error {
+	tuner.ReadOnly = true
+	tuner.Banner()
 
-	if !dryRun {
-		tuner.CompletionMessage(rebootRequired)
+	if balloonEvery <= 0 {
+		return fmt.Errorf("--interval must be positive")
+	}
+	bt := tuner.NewBalloonTuner()
+	bt.Interval = balloonEvery
+	if balloonWatch {
+		return bt.Watch()
+	}
+	return bt.Run()
+}
 
-		if rebootRequired {
-			fmt.Print("Do you want to reboot now? (y/n): ")
-			var response string
-			fmt.Scanln(&response)
-			if response == "y" || response == "yes" {
-				tuner.PrintInfo("Rebooting system...")
-				exec.Command("reboot").Run()
-			} else {
-				tuner.PrintInfo("Please remember to reboot later")
-			}
+func runSteal(cmd *cobra.Command, args []string) error {
+	tuner.ReadOnly = true
+
+	if stealEvery <= 0 {
+		return fmt.Errorf("--interval must be positive")
+	}
+	st := tuner.NewStealTuner()
+	st.Interval = stealEvery
+	if !stealJSON {
+		tuner.Banner()
+		return st.Run()
+	}
+
+	usage, err := st.Measure()
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(usage, "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(data))
+	return nil
+}
+
+func runBackupsList(cmd *cobra.Command, args []string) error {
+	backups, err := tuner.ListBackups()
+	if err != nil {
+		return fmt.Errorf("failed to list backups: %w", err)
+	}
+	if len(backups) == 0 {
+		tuner.PrintInfo("No backups in %s", tuner.BackupRoot)
+		return nil
+	}
+	for _, backup := range backups {
+		manifest, err := tuner.LoadManifest(filepath.Join(tuner.BackupRoot, backup))
+		if err != nil {
+			fmt.Printf("%s  (no manifest)\n", backup)
+			continue
 		}
-	} else {
-		fmt.Println()
-		tuner.PrintInfo("DRY RUN completed - no changes were made")
-		tuner.PrintInfo("Run without --dry-run to apply changes")
+		fmt.Printf("%s  %d file(s)\n", backup, len(manifest.Entries))
+		for _, entry := range manifest.Entries {
+			fmt.Printf("    %s\n", entry.OriginalPath)
+		}
+	}
+	return nil
+}
+
+func runBackupsExport(cmd *cobra.Command, args []string) error {
+	location, err := tuner.ExportBackup(args[0], backupDest, s3Endpoint)
+	if err != nil {
+		return err
 	}
+	tuner.PrintSuccess("Backup %s exported to %s", args[0], location)
+	return nil
+}
 
+func runBackupsImport(cmd *cobra.Command, args []string) error {
+	if err := tuner.CheckRoot(); err != nil {
+		return err
+	}
+	timestamp, err := tuner.ImportBackup(args[0], s3Endpoint)
+	if err != nil {
+		return err
+	}
+	tuner.PrintSuccess("Backup %s imported into %s", timestamp, tuner.BackupRoot)
+	tuner.PrintInfo("Restore it with: vmware-tuner rollback %s", timestamp)
 	return nil
 }
 
-func showConfig(cmd *cobra.Command, args []string) error {
+func runWizard(cmd *cobra.Command, args []string) error {
 	tuner.Banner()
-	tuner.PrintInfo("Current System Configuration")
-	fmt.Println()
 
-	// Initialize distro manager for config paths
-	distro, _ := tuner.NewDistroManager()
+	distro, err := tuner.NewDistroManager()
+	if err != nil {
+		distro = &tuner.DistroManager{Type: tuner.DistroUnknown}
+	}
+	return tuner.NewWizardTuner(configPath, distro).Run()
+}
 
-	// Show GRUB config
-	grub := tuner.NewGrubTuner(false, distro)
-	if err := grub.ShowCurrent(); err != nil {
-		tuner.PrintWarning("Could not show GRUB config: %v", err)
+func runDiskbench(cmd *cobra.Command, args []string) error {
+	tuner.ReadOnly = true
+
+	opts := tuner.DiskBenchOptions{Dir: benchDir, FileSize: benchSizeMB << 20, Runtime: benchRuntime}
+	if benchRuntime <= 0 {
+		return fmt.Errorf("--runtime must be positive")
+	}
+	for _, s := range benchBS {
+		bs, err := tuner.ParseBlockSize(s)
+		if err != nil {
+			return err
+		}
+		opts.BlockSizes = append(opts.BlockSizes, bs)
 	}
 
-	// Show sysctl config
-	sysctl := tuner.NewSysctlTuner(false)
-	if err := sysctl.ShowCurrent(); err != nil {
-		tuner.PrintWarning("Could not show sysctl config: %v", err)
+	if !benchJSON {
+		tuner.Banner()
+		return tuner.NewDiskBenchTuner(opts).Run()
 	}
 
-	// Show fstab config
-	fstab := tuner.NewFstabTuner(false)
-	if err := fstab.ShowCurrent(); err != nil {
-		tuner.PrintWarning("Could not show fstab config: %v", err)
+	// Progress goes to stderr so stdout stays valid JSON
+	stdout, colorOut := os.Stdout, color.Output
+	os.Stdout, color.Output = os.Stderr, os.Stderr
+	results, err := tuner.RunDiskBenchmark(opts)
+	os.Stdout, color.Output = stdout, colorOut
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(results, "", "  ")
+	if err != nil {
+		return err
 	}
+	fmt.Println(string(data))
+	return nil
+}
 
-	// Show I/O scheduler config
-	scheduler := tuner.NewSchedulerTuner(false)
-	if err := scheduler.ShowCurrent(); err != nil {
-		tuner.PrintWarning("Could not show I/O scheduler config: %v", err)
+func runSchedbench(cmd *cobra.Command, args []string) error {
+	tuner.ReadOnly = true
+
+	if benchRuntime <= 0 {
+		return fmt.Errorf("--runtime must be positive")
+	}
+	if !benchJSON {
+		tuner.Banner()
+		return tuner.NewSchedBenchTuner(benchRuntime).Run()
 	}
 
-	// Show network config
-	network := tuner.NewNetworkTuner(false)
-	if err := network.ShowCurrent(); err != nil {
-		tuner.PrintWarning("Could not show network config: %v", err)
+	result, err := tuner.RunSchedBenchmark(benchRuntime)
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return err
 	}
+	fmt.Println(string(data))
+	return nil
+}
+
+func runInventory(cmd *cobra.Command, args []string) error {
+	tuner.ReadOnly = true
 
+	cfg, err := tuner.LoadConfig(configPath)
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(tuner.CollectInventory(version), "", "  ")
+	if err != nil {
+		return err
+	}
+	out := string(data)
+	if r := outputRedactor(cfg); r != nil {
+		out = r.Redact(out)
+	}
+	fmt.Println(out)
 	return nil
 }
 
-func verifyConfig(cmd *cobra.Command, args []string) error {
+// outputRedactor returns the redactor of reports, inventories and bundles
+// (nil unless --redact or redaction.always)
+func outputRedactor(cfg *tuner.Config) *tuner.Redactor {
+	if !redactOutput && !cfg.Redaction.Always {
+		return nil
+	}
+	return cfg.Redactor()
+}
+
+func runDoctor(cmd *cobra.Command, args []string) error {
+	tuner.ReadOnly = true
 	tuner.Banner()
-	tuner.PrintStep("Verifying tuning configuration")
 
-	allGood := true
+	if os.Geteuid() != 0 {
+		tuner.PrintWarning("Not running as root: dmesg, the journal and DMI serials may be missing from the bundle")
+	}
+	cfg, err := tuner.LoadConfig(configPath)
+	if err != nil {
+		return err
+	}
+	distro, err := tuner.NewDistroManager()
+	if err != nil {
+		distro = &tuner.DistroManager{Type: tuner.DistroUnknown}
+	}
+	_, err = tuner.NewDoctorTuner(distro, cfg.VCenter, configPath, version, doctorOut, outputRedactor(cfg)).Run()
+	return err
+}
 
-	// Verify sysctl
-	sysctl := tuner.NewSysctlTuner(false)
-	if err := sysctl.Verify(); err != nil {
-		tuner.PrintWarning("Sysctl: %v", err)
-		allGood = false
+func runLogdoctor(cmd *cobra.Command, args []string) error {
+	tuner.ReadOnly = true
+	tuner.Banner()
+
+	distro, err := tuner.NewDistroManager()
+	if err != nil {
+		distro = &tuner.DistroManager{Type: tuner.DistroUnknown}
 	}
+	ld := tuner.NewLogDoctorTuner(distro)
+	if logSince != "" {
+		if ld.Since, err = tuner.ParseSince(logSince, time.Now()); err != nil {
+			return err
+		}
+	}
+	if logLimit < 0 {
+		return fmt.Errorf("invalid --limit %d: use 0 for all the findings", logLimit)
+	}
+	ld.Severity = logSeverity
+	ld.Limit = logLimit
+	if logOutput != "" {
+		ld.OutputPath = logOutput
+	}
+	tuner.PagerDisabled = noPager
+	return withRemediations(ld.Run())
+}
 
-	// Verify I/O scheduler
-	scheduler := tuner.NewSchedulerTuner(false)
-	if err := scheduler.Verify(); err != nil {
-		tuner.PrintWarning("I/O Scheduler: %v", err)
-		allGood = false
+func runUnits(cmd *cobra.Command, args []string) error {
+	tuner.Banner()
+	if os.Geteuid() != 0 {
+		tuner.ReadOnly = true
+		tuner.PrintWarning("Not running as root: failed units are only listed")
 	}
+	ctx := &tuner.ModuleContext{}
+	return tuner.NewUnitDoctorTuner().Run(ctx.BackupSession)
+}
 
-	// Verify network
-	network := tuner.NewNetworkTuner(false)
-	if err := network.Verify(); err != nil {
-		tuner.PrintWarning("Network: %v", err)
-		allGood = false
+func runFSHealth(cmd *cobra.Command, args []string) error {
+	tuner.ReadOnly = true
+	tuner.Banner()
+	return withRemediations(tuner.NewFSHealthTuner().Run())
+}
+
+func runDiskusage(cmd *cobra.Command, args []string) error {
+	tuner.ReadOnly = true
+	tuner.Banner()
+
+	if usageDepth < 1 || usageTop < 1 {
+		return fmt.Errorf("--depth and --top must be at least 1")
+	}
+	du := tuner.NewDiskUsageTuner()
+	du.Depth, du.Top = usageDepth, usageTop
+	if len(args) > 0 {
+		du.Roots = args
 	}
+	return du.Run()
+}
 
-	fmt.Println()
-	if allGood {
-		tuner.PrintSuccess("All tuning configurations are present")
+// withRemediations prints the modules fixing the findings of a diagnostic
+func withRemediations(err error) error {
+	if err == nil {
+		tuner.PrintRemediations(tuner.Remediations(tuner.TakeFindings()))
+	}
+	return err
+}
+
+func runDiff(cmd *cobra.Command, args []string) error {
+	tuner.ReadOnly = true
+	cmd.SilenceUsage = true
+
+	leftName := "this VM"
+	var left []byte
+	var err error
+	if len(args) == 1 {
+		leftName = args[0]
+		left, err = tuner.LoadInventory(args[0], diffRemoteBin)
 	} else {
-		tuner.PrintWarning("Some tuning configurations are missing")
-		tuner.PrintInfo("Run 'vmware-tuner' to apply tuning")
+		left, err = json.Marshal(tuner.CollectInventory(version))
+	}
+	if err != nil {
+		return err
+	}
+	right, err := tuner.LoadInventory(diffAgainst, diffRemoteBin)
+	if err != nil {
+		return err
 	}
 
+	diffs, err := tuner.DiffInventories(left, right)
+	if err != nil {
+		return err
+	}
+	tuner.PrintStep(fmt.Sprintf("%s vs %s", leftName, diffAgainst))
+	if len(diffs) == 0 {
+		tuner.PrintSuccess("No difference")
+		return nil
+	}
+	for _, d := range diffs {
+		l, r := d.Left, d.Right
+		if l == "" {
+			l = "(missing)"
+		}
+		if r == "" {
+			r = "(missing)"
+		}
+		fmt.Printf("  %s\n    %-30s %s\n    %-30s %s\n", d.Key, leftName+":", l, diffAgainst+":", r)
+	}
+	fmt.Println()
+	tuner.PrintWarning("%d difference(s)", len(diffs))
 	return nil
 }
 
+func runDebloatUndo(cmd *cobra.Command, args []string) error {
+	if !dryRun {
+		if err := tuner.CheckRoot(); err != nil {
+			return err
+		}
+	}
+	timestamp := ""
+	if len(args) == 1 {
+		timestamp = args[0]
+	}
+	cmd.SilenceUsage = true
+	err := tuner.NewDebloatTuner(dryRun).Undo(timestamp)
+	logModule("debloat", "undo", err)
+	return err
+}
+
+func runNetApply(cmd *cobra.Command, args []string) error {
+	if err := tuner.CheckRoot(); err != nil {
+		return err
+	}
+	nic := tuner.Tuning.NICSettings()
+	if netRxRing > 0 {
+		nic.RxRing = netRxRing
+	}
+	if netTxRing > 0 {
+		nic.TxRing = netTxRing
+	}
+	// 0 turns coalescing off on the command line
+	for flag, usecs := range map[string]struct{ value, dest *int }{
+		"rx-usecs": {&netRxUsecs, &nic.RxUsecs},
+		"tx-usecs": {&netTxUsecs, &nic.TxUsecs},
+	} {
+		if !cmd.Flags().Changed(flag) {
+			continue
+		}
+		*usecs.dest = *usecs.value
+		if *usecs.value == 0 {
+			*usecs.dest = tuner.CoalesceOff
+		}
+	}
+	na := tuner.NewNetApplyTuner(nic)
+	na.Selection = tuner.Tuning.Interfaces
+	na.Affinity = tuner.Tuning.AffinityMode()
+	switch netAffinity {
+	case "":
+	case tuner.AffinitySpread, tuner.AffinityPin:
+		na.Affinity = netAffinity
+	default:
+		return fmt.Errorf("invalid --affinity %q (spread or pin)", netAffinity)
+	}
+	return na.Run()
+}
+
+func runGrubReset(cmd *cobra.Command, args []string) error {
+	if err := tuner.CheckRoot(); err != nil {
+		return err
+	}
+	tuner.Banner()
+
+	distro, err := tuner.NewDistroManager()
+	if err != nil {
+		return err
+	}
+	backup := tuner.NewBackupManager()
+	if !dryRun {
+		if err := backup.Initialize(); err != nil {
+			return fmt.Errorf("failed to initialize backup: %w", err)
+		}
+	}
+	return tuner.NewGrubTuner(dryRun, distro).Reset(backup)
+}
+
+func runBundleCreate(cmd *cobra.Command, args []string) error {
+	tuner.Banner()
+
+	distro, err := tuner.NewDistroManager()
+	if err != nil {
+		return err
+	}
+
+	return tuner.NewBundleTuner(distro, bundleRel, bundleOut).Create(tuner.CheckConnectivity())
+}
+
 func runRollbackInteractive() error {
 	tuner.PrintStep("Restore Backup (Native Rollback)")
 
@@ -550,8 +2407,26 @@ func runRollbackInteractive() error {
 		return nil
 	}
 
-	targetBackup := backups[index-1]
-	backupDir := filepath.Join("/root", ".vmware-tuner-backups", targetBackup)
+	return restoreBackup(backups[index-1], nil, true)
+}
+
+func runRollback(cmd *cobra.Command, args []string) error {
+	if err := tuner.CheckRoot(); err != nil {
+		return err
+	}
+	if len(args) == 0 {
+		return runRollbackInteractive()
+	}
+	return restoreBackup(args[0], rollbackOnly, false)
+}
+
+// restoreBackup restores the only files of a backup (all when empty). In
+// interactive mode the files are chosen from the manifest.
+func restoreBackup(targetBackup string, only []string, interactive bool) error {
+	backupDir := filepath.Join(tuner.BackupRoot, targetBackup)
+	if !tuner.FileExists(backupDir) {
+		return fmt.Errorf("backup not found: %s", backupDir)
+	}
 
 	// Create a backup manager instance pointing to this directory
 	bm := &tuner.BackupManager{
@@ -561,6 +2436,9 @@ func runRollbackInteractive() error {
 
 	// Check if manifest exists
 	if !tuner.FileExists(filepath.Join(backupDir, "manifest.json")) {
+		if len(only) > 0 {
+			return fmt.Errorf("no manifest in %s: files cannot be restored selectively", backupDir)
+		}
 		// Fallback to legacy script if manifest is missing (backward compatibility)
 		scriptPath := filepath.Join(backupDir, "rollback.sh")
 		if tuner.FileExists(scriptPath) {
@@ -575,5 +2453,46 @@ func runRollbackInteractive() error {
 		return fmt.Errorf("no manifest or rollback script found in %s", backupDir)
 	}
 
-	return bm.RestoreFromManifest()
+	if interactive {
+		manifest, err := tuner.LoadManifest(backupDir)
+		if err != nil {
+			return err
+		}
+		if only, err = selectManifestFiles(manifest); err != nil {
+			tuner.PrintError("%v", err)
+			return nil
+		}
+	}
+
+	return bm.RestoreEntries(only)
+}
+
+// selectManifestFiles asks which files of the manifest to restore (nil: all)
+func selectManifestFiles(manifest *tuner.Manifest) ([]string, error) {
+	fmt.Println("Files in this backup:")
+	for i, entry := range manifest.Entries {
+		note := ""
+		if entry.Created {
+			note = " (created by vmware-tuner, will be removed)"
+		}
+		fmt.Printf("  [%d] %s%s\n", i+1, entry.OriginalPath, note)
+	}
+	fmt.Println()
+
+	fmt.Print("Files to restore ([a]ll, or numbers such as 1,3): ")
+	var selection string
+	fmt.Scanln(&selection)
+	if selection == "" || selection == "a" || selection == "A" {
+		return nil, nil
+	}
+
+	var files []string
+	for _, field := range strings.Split(selection, ",") {
+		var index int
+		if _, err := fmt.Sscanf(strings.TrimSpace(field), "%d", &index); err != nil || index < 1 || index > len(manifest.Entries) {
+			return nil, fmt.Errorf("invalid selection: %s", field)
+		}
+		files = append(files, manifest.Entries[index-1].OriginalPath)
+	}
+	return files, nil
 }
@@ -0,0 +1,399 @@
+package tuner
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Affinity modes of the NIC queues (tuning.network.affinity or the profile)
+const (
+	AffinitySpread = "spread" // queue i interrupts on vCPU i, RPS over every vCPU
+	AffinityPin    = "pin"    // queue i stays on vCPU i: no RPS hop, irqbalance stopped
+)
+
+// legacyAffinityScript is the boot script of the earlier versions: net-apply
+// now places the queues itself
+const legacyAffinityScript = "/usr/local/sbin/vmware-tuner-affinity"
+
+// nicQueueIRQRe matches the per-queue interrupts of vmxnet3 in /proc/interrupts
+// ("ens192-rxtx-0", or "ens192-rx-0" and "ens192-tx-0" without shared vectors)
+var nicQueueIRQRe = regexp.MustCompile(`^(\S+)-(?:rxtx|rx|tx)-(\d+)$`)
+
+// irqbalanceActive reports whether irqbalance moves the interrupts around
+var irqbalanceActive = func() bool {
+	return exec.Command("systemctl", "is-active", "--quiet", "irqbalance").Run() == nil
+}
+
+// AffinityTuner spreads the interrupts and RPS/XPS masks of the vmxnet3
+// queues across the vCPUs, or pins them for the latency profiles
+type AffinityTuner struct {
+	DryRun    bool
+	Selection InterfaceSelection // empty: every vmxnet3 interface
+	FSRoot    string             // "" for /
+}
+
+// NewAffinityTuner creates a new NIC affinity tuner
+func NewAffinityTuner(dryRun bool) *AffinityTuner {
+	return &AffinityTuner{
+		DryRun:    dryRun,
+		Selection: Tuning.Interfaces,
+	}
+}
+
+// NICQueues is the queues of a vmxnet3 interface and their interrupts
+type NICQueues struct {
+	Interface string
+	RxQueues  []int
+	TxQueues  []int
+	IRQs      map[int][]int // queue number -> IRQs (one per queue, or rx and tx)
+}
+
+// AffinitySetting is one value to write under /proc or /sys
+type AffinitySetting struct {
+	Path  string
+	Value string
+}
+
+// parseCPURanges expands a kernel CPU list ("0-3,6", "" for none)
+func parseCPURanges(list string) ([]int, error) {
+	var cpus []int
+	for _, part := range strings.Split(strings.TrimSpace(list), ",") {
+		if part == "" {
+			continue
+		}
+		first, last, isRange := strings.Cut(part, "-")
+		start, err := strconv.Atoi(first)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CPU list %q", list)
+		}
+		end := start
+		if isRange {
+			if end, err = strconv.Atoi(last); err != nil || end < start {
+				return nil, fmt.Errorf("invalid CPU list %q", list)
+			}
+		}
+		for cpu := start; cpu <= end; cpu++ {
+			cpus = append(cpus, cpu)
+		}
+	}
+	return cpus, nil
+}
+
+// affinityCPUs returns the online vCPUs that are not isolated (isolcpus):
+// isolated vCPUs are kept free of network processing
+func affinityCPUs(fsRoot string) ([]int, error) {
+	data, err := os.ReadFile(filepath.Join(fsRoot, "/sys/devices/system/cpu/online"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the online vCPUs: %w", err)
+	}
+	online, err := parseCPURanges(string(data))
+	if err != nil {
+		return nil, err
+	}
+	isolated := make(map[int]bool)
+	if data, err := os.ReadFile(filepath.Join(fsRoot, "/sys/devices/system/cpu/isolated")); err == nil {
+		cpus, _ := parseCPURanges(string(data))
+		for _, cpu := range cpus {
+			isolated[cpu] = true
+		}
+	}
+
+	var cpus []int
+	for _, cpu := range online {
+		if !isolated[cpu] {
+			cpus = append(cpus, cpu)
+		}
+	}
+	return cpus, nil
+}
+
+// kernelMask renders CPUs as the mask of rps_cpus and xps_cpus: 32-bit
+// groups separated by commas, as the kernel parses and prints them
+func kernelMask(cpus []int) string {
+	hex := CPUMask(cpus)
+	if pad := len(hex) % 8; pad != 0 {
+		hex = strings.Repeat("0", 8-pad) + hex
+	}
+	var groups []string
+	for i := 0; i < len(hex); i += 8 {
+		groups = append(groups, hex[i:i+8])
+	}
+	return strings.Join(groups, ",")
+}
+
+// sameMask compares two masks whatever their width
+func sameMask(a, b string) bool {
+	norm := func(mask string) string {
+		mask = strings.TrimLeft(strings.ReplaceAll(strings.TrimSpace(mask), ",", ""), "0")
+		if mask == "" {
+			return "0"
+		}
+		return strings.ToLower(mask)
+	}
+	return norm(a) == norm(b)
+}
+
+// queueNumbers returns the numbers of the rx-N or tx-N queue directories
+func queueNumbers(dir, prefix string) []int {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil
+	}
+	var queues []int
+	for _, entry := range entries {
+		if n, err := strconv.Atoi(strings.TrimPrefix(entry.Name(), prefix)); err == nil && strings.HasPrefix(entry.Name(), prefix) {
+			queues = append(queues, n)
+		}
+	}
+	sort.Ints(queues)
+	return queues
+}
+
+// ReadNICQueues returns the queues of the vmxnet3 interfaces chosen by the
+// selection (every one when empty), except the excluded ones. fsRoot allows
+// running against a fixture tree (empty string for /).
+func ReadNICQueues(fsRoot string, selection InterfaceSelection) ([]NICQueues, error) {
+	sys := SysFS{Root: fsRoot}
+	names, err := sys.NetDevices()
+	if err != nil {
+		return nil, err
+	}
+
+	irqs := make(map[string]map[int][]int)
+	if data, err := sys.ReadFile("/proc/interrupts"); err == nil {
+		for _, line := range strings.Split(string(data), "\n") {
+			fields := strings.Fields(line)
+			if len(fields) < 2 {
+				continue
+			}
+			irq, err := strconv.Atoi(strings.TrimSuffix(fields[0], ":"))
+			m := nicQueueIRQRe.FindStringSubmatch(fields[len(fields)-1])
+			if err != nil || m == nil {
+				continue
+			}
+			queue, _ := strconv.Atoi(m[2])
+			if irqs[m[1]] == nil {
+				irqs[m[1]] = make(map[int][]int)
+			}
+			irqs[m[1]][queue] = append(irqs[m[1]][queue], irq)
+		}
+	}
+
+	var nics []NICQueues
+	for _, name := range names {
+		dev := sys.Path("/sys/class/net", name)
+		iface := NetInterface{Name: name, Driver: interfaceDriver(fsRoot, name)}
+		if iface.Driver != "vmxnet3" || Excluded.Matches(ExcludeInterface, name) {
+			continue
+		}
+		if !selection.Empty() && !selection.Match(iface) {
+			continue
+		}
+		nics = append(nics, NICQueues{
+			Interface: name,
+			RxQueues:  queueNumbers(filepath.Join(dev, "queues"), "rx-"),
+			TxQueues:  queueNumbers(filepath.Join(dev, "queues"), "tx-"),
+			IRQs:      irqs[name],
+		})
+	}
+	return nics, nil
+}
+
+// Plan returns the values to write for the interface. Queue i goes to the
+// i-th vCPU of cpus. manageIRQs is false when irqbalance places the interrupts.
+func (nq NICQueues) Plan(cpus []int, mode string, manageIRQs bool) []AffinitySetting {
+	if len(cpus) == 0 {
+		return nil
+	}
+	var plan []AffinitySetting
+	if manageIRQs {
+		queues := make([]int, 0, len(nq.IRQs))
+		for queue := range nq.IRQs {
+			queues = append(queues, queue)
+		}
+		sort.Ints(queues)
+		for _, queue := range queues {
+			for _, irq := range nq.IRQs[queue] {
+				plan = append(plan, AffinitySetting{
+					Path:  fmt.Sprintf("/proc/irq/%d/smp_affinity_list", irq),
+					Value: strconv.Itoa(cpus[queue%len(cpus)]),
+				})
+			}
+		}
+	}
+
+	queues := filepath.Join("/sys/class/net", nq.Interface, "queues")
+	rps := kernelMask(cpus)
+	if mode == AffinityPin {
+		rps = kernelMask(nil) // the packets stay on the vCPU of the interrupt
+	}
+	for _, rx := range nq.RxQueues {
+		plan = append(plan, AffinitySetting{Path: filepath.Join(queues, fmt.Sprintf("rx-%d", rx), "rps_cpus"), Value: rps})
+	}
+	for _, tx := range nq.TxQueues {
+		var xps []int
+		if mode == AffinityPin {
+			xps = []int{cpus[tx%len(cpus)]}
+		} else {
+			for i, cpu := range cpus {
+				if i%len(nq.TxQueues) == tx {
+					xps = append(xps, cpu)
+				}
+			}
+		}
+		plan = append(plan, AffinitySetting{Path: filepath.Join(queues, fmt.Sprintf("tx-%d", tx), "xps_cpus"), Value: kernelMask(xps)})
+	}
+	return plan
+}
+
+// manageIRQs reports whether the tuner places the interrupts itself: a
+// running irqbalance would move them again in spread mode
+func (at *AffinityTuner) manageIRQs(mode string) bool {
+	return mode == AffinityPin || !irqbalanceActive()
+}
+
+// Apply places the queues of the current NICs. network-tuning.service places
+// them again at every boot (net-apply --affinity), after the ring settings
+// that reset the adapter.
+func (at *AffinityTuner) Apply(backup *BackupManager) error {
+	PrintStep("Configuring NIC interrupt and RPS/XPS affinity")
+
+	mode := Tuning.AffinityMode()
+	manageIRQs := at.manageIRQs(mode)
+	PrintInfo("Affinity mode: %s", mode)
+	if !manageIRQs {
+		PrintInfo("irqbalance is active: it places the NIC interrupts, only RPS/XPS are set")
+	}
+
+	if at.DryRun {
+		if FileExists(filepath.Join(at.FSRoot, legacyAffinityScript)) {
+			PrintInfo("Would remove %s (replaced by net-apply)", legacyAffinityScript)
+		}
+		if mode == AffinityPin && irqbalanceActive() && !SkipExcluded("network", ExcludeService, "irqbalance") {
+			PrintInfo("Would stop and disable irqbalance")
+		}
+		return at.applyPlan(mode, manageIRQs)
+	}
+
+	if script := filepath.Join(at.FSRoot, legacyAffinityScript); FileExists(script) {
+		if err := backup.BackupFile(script); err != nil {
+			return fmt.Errorf("failed to backup %s: %w", script, err)
+		}
+		ExplainEdit("remove the boot script replaced by net-apply", script)
+		if err := os.Remove(script); err != nil {
+			return fmt.Errorf("failed to remove %s: %w", script, err)
+		}
+		PrintSuccess("Removed %s (replaced by net-apply)", script)
+	}
+
+	if mode == AffinityPin && irqbalanceActive() && !SkipExcluded("network", ExcludeService, "irqbalance") {
+		if err := backup.BackupServices([]string{"irqbalance"}); err != nil {
+			return fmt.Errorf("failed to record the irqbalance service: %w", err)
+		}
+		ExplainCommand("stop irqbalance from moving the pinned interrupts", "systemctl", "disable", "--now", "irqbalance")
+		if out, err := exec.Command("systemctl", "disable", "--now", "irqbalance").CombinedOutput(); err != nil {
+			PrintWarning("Failed to stop irqbalance: %v", err)
+			fmt.Println(string(out))
+		} else {
+			PrintSuccess("Stopped irqbalance")
+		}
+	}
+
+	return at.applyPlan(mode, manageIRQs)
+}
+
+// applyPlan writes the plan of every vmxnet3 interface (or prints it in dry-run)
+func (at *AffinityTuner) applyPlan(mode string, manageIRQs bool) error {
+	nics, err := ReadNICQueues(at.FSRoot, at.Selection)
+	if err != nil {
+		return err
+	}
+	if len(nics) == 0 {
+		PrintInfo("No vmxnet3 interface, network-tuning.service covers NICs added later")
+		return nil
+	}
+	cpus, err := affinityCPUs(at.FSRoot)
+	if err != nil {
+		return err
+	}
+
+	failCount := 0
+	for _, nic := range nics {
+		plan := nic.Plan(cpus, mode, manageIRQs)
+		if at.DryRun {
+			for _, s := range plan {
+				PrintInfo("Would write %s to %s", s.Value, s.Path)
+			}
+			continue
+		}
+		failed := 0
+		for _, s := range plan {
+			path := filepath.Join(at.FSRoot, s.Path)
+			ExplainEdit("place a queue of "+nic.Interface+" on its vCPUs", path)
+			if err := os.WriteFile(path, []byte(s.Value), 0644); err != nil {
+				PrintWarning("Could not write %s: %v", s.Path, err)
+				failed++
+			}
+		}
+		if failed > 0 {
+			failCount++
+			continue
+		}
+		PrintSuccess("Configured %s (%d rx, %d tx queues over %d vCPUs)", nic.Interface, len(nic.RxQueues), len(nic.TxQueues), len(cpus))
+	}
+
+	if failCount > 0 {
+		return fmt.Errorf("failed to configure %d interface(s)", failCount)
+	}
+	return nil
+}
+
+// Verify checks the runtime placement of every queue
+func (at *AffinityTuner) Verify() error {
+	nics, err := ReadNICQueues(at.FSRoot, at.Selection)
+	if err != nil {
+		return err
+	}
+	if len(nics) == 0 {
+		return fmt.Errorf("%w: no vmxnet3 interface", ErrVerifySkipped)
+	}
+	cpus, err := affinityCPUs(at.FSRoot)
+	if err != nil {
+		return err
+	}
+
+	mode := Tuning.AffinityMode()
+	manageIRQs := at.manageIRQs(mode)
+	var problems []string
+	if mode == AffinityPin && irqbalanceActive() {
+		problems = append(problems, "irqbalance is running and moves the pinned interrupts")
+	}
+	for _, nic := range nics {
+		for _, s := range nic.Plan(cpus, mode, manageIRQs) {
+			data, err := os.ReadFile(filepath.Join(at.FSRoot, s.Path))
+			if err != nil {
+				continue
+			}
+			current := strings.TrimSpace(string(data))
+			applied := sameMask(current, s.Value)
+			if strings.HasSuffix(s.Path, "smp_affinity_list") {
+				applied = current == s.Value
+			}
+			if !applied {
+				problems = append(problems, fmt.Sprintf("%s is %s instead of %s", s.Path, current, s.Value))
+			}
+		}
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("NIC affinity not applied: %s", strings.Join(problems, ", "))
+	}
+
+	PrintSuccess("NIC queues placed on %d vCPU(s) (%s) for %d interface(s)", len(cpus), mode, len(nics))
+	return nil
+}
@@ -0,0 +1,179 @@
+package tuner
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// affinityFixture builds a VM with 4 vCPUs, a vmxnet3 NIC with 2 queue pairs
+// and an e1000 NIC
+func affinityFixture(t *testing.T) string {
+	t.Helper()
+	root := t.TempDir()
+	files := map[string]string{
+		"sys/devices/system/cpu/online":             "0-3\n",
+		"sys/devices/system/cpu/isolated":           "\n",
+		"sys/class/net/ens192/queues/rx-0/rps_cpus": "0\n",
+		"sys/class/net/ens192/queues/rx-1/rps_cpus": "0\n",
+		"sys/class/net/ens192/queues/tx-0/xps_cpus": "0\n",
+		"sys/class/net/ens192/queues/tx-1/xps_cpus": "0\n",
+		"sys/class/net/ens160/queues/rx-0/rps_cpus": "0\n",
+		"proc/irq/56/smp_affinity_list":             "0-3\n",
+		"proc/irq/57/smp_affinity_list":             "0-3\n",
+		"proc/irq/58/smp_affinity_list":             "0-3\n",
+		"usr/local/sbin/vmware-tuner-affinity":      "#!/bin/bash\n",
+		"sys/bus/pci/drivers/vmxnet3/.keep":         "",
+		"sys/bus/pci/drivers/e1000/.keep":           "",
+		"proc/interrupts": `           CPU0       CPU1       CPU2       CPU3
+  56:        120          0          0          0   PCI-MSI 1572864-edge      ens192-rxtx-0
+  57:          0         80          0          0   PCI-MSI 1572865-edge      ens192-rxtx-1
+  58:          1          0          0          0   PCI-MSI 1572866-edge      ens192-event-2
+`,
+	}
+	for name, content := range files {
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for iface, driver := range map[string]string{"ens192": "vmxnet3", "ens160": "e1000"} {
+		dev := filepath.Join(root, "sys/class/net", iface, "device")
+		if err := os.MkdirAll(dev, 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Symlink("../../../../bus/pci/drivers/"+driver, filepath.Join(dev, "driver")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return root
+}
+
+func TestKernelMask(t *testing.T) {
+	for _, tc := range []struct {
+		cpus []int
+		want string
+	}{
+		{nil, "00000000"},
+		{[]int{0, 1, 2, 3}, "0000000f"},
+		{[]int{1, 33}, "00000002,00000002"},
+	} {
+		if got := kernelMask(tc.cpus); got != tc.want {
+			t.Errorf("kernelMask(%v) = %s, want %s", tc.cpus, got, tc.want)
+		}
+	}
+	if !sameMask("00000000,0000000f", "f") || sameMask("f", "e") || !sameMask("0", "00000000") {
+		t.Error("masks should be compared whatever their width")
+	}
+}
+
+func TestNICQueuesPlan(t *testing.T) {
+	root := affinityFixture(t)
+	nics, err := ReadNICQueues(root, InterfaceSelection{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(nics) != 1 || nics[0].Interface != "ens192" || len(nics[0].RxQueues) != 2 || len(nics[0].IRQs) != 2 {
+		t.Fatalf("expected ens192 with 2 queues and their 2 interrupts (not the event one), got %+v", nics)
+	}
+	// Only the selected interfaces are placed
+	if others, err := ReadNICQueues(root, InterfaceSelection{Names: []string{"ens160", "eth*"}}); err != nil || len(others) != 0 {
+		t.Errorf("ens192 is not selected, got %+v (%v)", others, err)
+	}
+	if byDriver, _ := ReadNICQueues(root, InterfaceSelection{Drivers: []string{"vmxnet3"}}); len(byDriver) != 1 {
+		t.Errorf("ens192 is selected by its driver, got %+v", byDriver)
+	}
+
+	plan := func(mode string, irqs bool) map[string]string {
+		values := make(map[string]string)
+		for _, s := range nics[0].Plan([]int{0, 1, 2, 3}, mode, irqs) {
+			values[s.Path] = s.Value
+		}
+		return values
+	}
+	spread := plan(AffinitySpread, true)
+	for path, want := range map[string]string{
+		"/proc/irq/56/smp_affinity_list":             "0",
+		"/proc/irq/57/smp_affinity_list":             "1",
+		"/sys/class/net/ens192/queues/rx-1/rps_cpus": "0000000f",
+		"/sys/class/net/ens192/queues/tx-0/xps_cpus": "00000005",
+		"/sys/class/net/ens192/queues/tx-1/xps_cpus": "0000000a",
+	} {
+		if spread[path] != want {
+			t.Errorf("spread: %s = %q, want %q", path, spread[path], want)
+		}
+	}
+	pin := plan(AffinityPin, true)
+	if pin["/sys/class/net/ens192/queues/rx-0/rps_cpus"] != "00000000" || pin["/sys/class/net/ens192/queues/tx-1/xps_cpus"] != "00000002" {
+		t.Errorf("pin: RPS should be off and tx-1 on vCPU 1: %v", pin)
+	}
+	if _, ok := plan(AffinitySpread, false)["/proc/irq/56/smp_affinity_list"]; ok {
+		t.Error("interrupts are left to irqbalance")
+	}
+}
+
+func TestAffinityApplyVerify(t *testing.T) {
+	savedTuning, savedIrqbalance := Tuning, irqbalanceActive
+	defer func() { Tuning, irqbalanceActive = savedTuning, savedIrqbalance }()
+	Tuning = TuningConfig{}
+	irqbalanceActive = func() bool { return false }
+
+	root := affinityFixture(t)
+	// vCPU 3 is isolated: no network processing there
+	if err := os.WriteFile(filepath.Join(root, "sys/devices/system/cpu/isolated"), []byte("3\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	at := NewAffinityTuner(false)
+	at.FSRoot = root
+	if err := at.Verify(); err == nil {
+		t.Fatal("Verify should report the default masks")
+	}
+	if err := at.applyPlan(Tuning.AffinityMode(), true); err != nil {
+		t.Fatal(err)
+	}
+	if err := at.Verify(); err != nil {
+		t.Errorf("Verify after applying: %v", err)
+	}
+	data, _ := os.ReadFile(filepath.Join(root, "sys/class/net/ens192/queues/rx-0/rps_cpus"))
+	if string(data) != "00000007" {
+		t.Errorf("RPS should cover vCPUs 0-2, got %s", data)
+	}
+
+	Tuning = TuningConfig{Profile: "low-latency"}
+	if err := at.Verify(); err == nil || !strings.Contains(err.Error(), "rps_cpus") {
+		t.Errorf("the low-latency profile pins the queues, Verify should fail: %v", err)
+	}
+}
+
+func TestAffinityApplyRemovesLegacyScript(t *testing.T) {
+	savedTuning, savedIrqbalance := Tuning, irqbalanceActive
+	defer func() { Tuning, irqbalanceActive = savedTuning, savedIrqbalance }()
+	Tuning = TuningConfig{}
+	irqbalanceActive = func() bool { return false }
+
+	root := affinityFixture(t)
+	bm := &BackupManager{BackupDir: filepath.Join(t.TempDir(), "backup")}
+	if err := bm.Initialize(); err != nil {
+		t.Fatal(err)
+	}
+	at := NewAffinityTuner(false)
+	at.FSRoot = root
+	if err := at.Apply(bm); err != nil {
+		t.Fatal(err)
+	}
+	// net-apply places the queues at boot: the script would place them twice
+	if FileExists(filepath.Join(root, legacyAffinityScript)) {
+		t.Error("the boot script of the earlier versions should be removed")
+	}
+	manifest, err := LoadManifest(bm.BackupDir)
+	if err != nil || len(manifest.Entries) != 1 || manifest.Entries[0].Created {
+		t.Errorf("the removed script should be backed up: %+v (%v)", manifest, err)
+	}
+	if err := at.Verify(); err != nil {
+		t.Errorf("Verify after applying: %v", err)
+	}
+}
@@ -17,21 +17,70 @@ func NewAuditTuner(distro *DistroManager) *AuditTuner {
 	}
 }
 
-// RunAudit performs the audit and prints the report
-func (at *AuditTuner) RunAudit() error {
-	PrintStep("System Optimization Audit")
+func init() {
+	Register(Module{
+		Name:        "audit",
+		Label:       "Audit System (Score)",
+		Description: "Score the VM against the tuning recommendations",
+		Category:    CategoryDiagnostics,
+		Menu:        3,
+		RequireRoot: true,
+		ReadOnly:    true,
+		Run: func(ctx *ModuleContext) error {
+			return NewAuditTuner(ctx.Distro).RunAudit()
+		},
+	})
+	Register(Module{
+		Name:        "latency-audit",
+		Label:       "Audit for Latency-Sensitive VMs",
+		Description: "Check the settings of latency-sensitive VMs",
+		Category:    CategoryDiagnostics,
+		Menu:        17,
+		ReadOnly:    true,
+		Run: func(ctx *ModuleContext) error {
+			return NewAuditTuner(ctx.Distro).RunLatencyAudit()
+		},
+	})
+}
 
-	score := 0
-	maxScore := 100
+// Audit item status levels
+const (
+	AuditOK   = "ok"
+	AuditWarn = "warn"
+	AuditFail = "fail"
+)
+
+// AuditItem is a single scored audit check
+type AuditItem struct {
+	Name    string   `json:"name"`
+	Status  string   `json:"status"`
+	Points  int      `json:"points"`
+	Max     int      `json:"max"`
+	Message string   `json:"message"`
+	Details []string `json:"details,omitempty"`
+}
+
+// AuditResult is the outcome of a full audit
+type AuditResult struct {
+	Score    int         `json:"score"`
+	MaxScore int         `json:"max_score"`
+	Items    []AuditItem `json:"items"`
+}
+
+// Evaluate runs all audit checks without printing
+func (at *AuditTuner) Evaluate() AuditResult {
+	result := AuditResult{MaxScore: 100}
 
 	// 1. Check VM Tools (30 points)
 	tools := NewVMToolsTuner(true, at.Distro)
 	installed, updateAvailable, days, _ := tools.CheckUpdateStatus()
-	
+
+	item := AuditItem{Name: "vmware-tools", Max: 30}
 	if installed {
 		if !updateAvailable {
-			PrintSuccess("VMware Tools installed and up-to-date (+30)")
-			score += 30
+			item.Status = AuditOK
+			item.Points = 30
+			item.Message = "VMware Tools installed and up-to-date (+30)"
 		} else {
 			// Update available, deduct points based on age
 			points := 25
@@ -42,68 +91,171 @@ func (at *AuditTuner) RunAudit() error {
 			} else if days > 30 {
 				points = 20
 			}
-			PrintWarning("VMware Tools update available (installed %d days ago) (+%d/30)", days, points)
-			PrintInfo("Recommendation: Run 'Safe System Update' or update open-vm-tools")
-			score += points
+			item.Status = AuditWarn
+			item.Points = points
+			item.Message = fmt.Sprintf("VMware Tools update available (installed %d days ago) (+%d/30)", days, points)
+			item.Details = []string{"Recommendation: Run 'Safe System Update' or update open-vm-tools"}
 		}
 	} else {
-		PrintError("VMware Tools missing (0/30)")
+		item.Status = AuditFail
+		item.Message = "VMware Tools missing (0/30)"
 	}
+	result.Items = append(result.Items, item)
+
+	// 2. I/O scheduler in effect (15 points): blk-mq kernels ignore elevator=
+	result.Items = append(result.Items, auditScheduler(Sys.Root))
 
-	// 2. Check GRUB (30 points)
+	// 3. Check GRUB (15 points)
 	grub := NewGrubTuner(true, at.Distro)
 	config, _, err := grub.ParseGrubConfig()
 	if err == nil {
 		cmdline := config["GRUB_CMDLINE_LINUX_DEFAULT"]
-		if strings.Contains(cmdline, "elevator=noop") || strings.Contains(cmdline, "elevator=none") {
-			PrintSuccess("I/O Scheduler optimized (+15)")
-			score += 15
-		} else {
-			PrintWarning("I/O Scheduler not optimized (0/15)")
-		}
-		
+		item = AuditItem{Name: "memory-pages", Max: 15}
 		if strings.Contains(cmdline, "transparent_hugepage=madvise") {
-			PrintSuccess("Memory pages optimized (+15)")
-			score += 15
+			item.Status, item.Points, item.Message = AuditOK, 15, "Memory pages optimized (+15)"
 		} else {
-			PrintWarning("Memory pages not optimized (0/15)")
+			item.Status, item.Message = AuditWarn, "Memory pages not optimized (0/15)"
 		}
+		result.Items = append(result.Items, item)
 	} else {
-		PrintWarning("Could not read GRUB config")
+		result.Items = append(result.Items, AuditItem{Name: "grub", Max: 15, Status: AuditWarn, Message: "Could not read GRUB config"})
 	}
 
-	// 3. Check Bloatware (20 points)
+	// 4. Check Bloatware (10 points)
 	debloat := NewDebloatTuner(true)
 	bloat := debloat.GetBloatServices()
+	item = AuditItem{Name: "bloatware", Max: 10}
 	if len(bloat) == 0 {
-		PrintSuccess("No unnecessary services found (+20)")
-		score += 20
+		item.Status, item.Points, item.Message = AuditOK, 10, "No unnecessary services found (+10)"
 	} else {
-		PrintWarning("Found %d unnecessary services (0/20)", len(bloat))
+		item.Status = AuditWarn
+		item.Message = fmt.Sprintf("Found %d unnecessary services (0/10)", len(bloat))
 		for _, svc := range bloat {
-			fmt.Printf("    - %s\n", svc.Name)
+			item.Details = append(item.Details, "- "+svc.Name)
 		}
 	}
+	result.Items = append(result.Items, item)
+
+	// 5. Check Sysctl (20 points): the values in effect, not just the file
+	result.Items = append(result.Items, auditSysctl(NewSysctlTuner(true), ""))
+
+	// 6. Memory reclaimed by the host (10 points)
+	result.Items = append(result.Items, auditBalloon(Sys.Root))
+
+	// 7. vCPU layout: a finding, not scored
+	result.Items = append(result.Items, auditCPUTopology(ReadCPUTopology(Sys.Root)))
+
+	// 8. vNUMA layout: a finding, not scored
+	numa, _ := ReadNUMA(Sys.Root)
+	result.Items = append(result.Items, auditNUMA(numa))
+
+	// 9. tuned profile against our settings: a finding, not scored
+	result.Items = append(result.Items, auditTuned(ReadTunedStatus(Sys.Root)))
+
+	for _, i := range result.Items {
+		result.Score += i.Points
+	}
+	return result
+}
 
-	// 4. Check Sysctl (20 points)
-	// Simple check for swappiness
-	// In a real implementation we would check actual values
-	// For now, let's assume if the config file exists, it's good
-	if FileExists("/etc/sysctl.d/99-vmware-performance.conf") {
-		PrintSuccess("Sysctl optimizations present (+20)")
-		score += 20
+// auditScheduler scores the runtime I/O scheduler of the disks against the
+// profile (none for NVMe and device-mapper)
+func auditScheduler(fsRoot string) AuditItem {
+	item := AuditItem{Name: "io-scheduler", Max: 15}
+	devices, err := ReadDeviceSchedulers(fsRoot)
+	if err != nil {
+		item.Status, item.Message = AuditWarn, "I/O Scheduler could not be read (0/15)"
+		return item
+	}
+	profile := Tuning.ActiveProfile().Scheduler
+	for _, dev := range devices {
+		if want := dev.Want(profile); !dev.Compliant(want) {
+			item.Details = append(item.Details, fmt.Sprintf("- %s uses %s instead of %s", dev.Device, dev.Current, want))
+		}
+	}
+	if len(item.Details) > 0 {
+		item.Status = AuditWarn
+		item.Message = fmt.Sprintf("I/O Scheduler not optimized on %d of %d device(s) (0/15)", len(item.Details), len(devices))
+		return item
+	}
+	item.Status, item.Points = AuditOK, item.Max
+	item.Message = fmt.Sprintf("I/O Scheduler optimized on %d device(s) (+15)", len(devices))
+	return item
+}
+
+// auditSysctl scores the share of the generated sysctl values in effect
+func auditSysctl(st *SysctlTuner, fsRoot string) AuditItem {
+	item := AuditItem{Name: "sysctl", Max: 20}
+	checks, err := st.CheckRuntime(fsRoot)
+	if err != nil {
+		item.Status, item.Message = AuditWarn, "Sysctl optimizations missing (0/20)"
+		return item
+	}
+
+	inEffect, available := 0, 0
+	for _, c := range checks {
+		if c.Unavailable {
+			continue
+		}
+		available++
+		if c.OK {
+			inEffect++
+			continue
+		}
+		detail := fmt.Sprintf("- %s = %s (want %s)", c.Key, c.Actual, c.Expected)
+		if c.OverriddenBy != "" {
+			detail += ", overridden by " + c.OverriddenBy
+		}
+		item.Details = append(item.Details, detail)
+	}
+	if available == 0 {
+		item.Status, item.Message = AuditWarn, "Sysctl values could not be read (0/20)"
+		return item
+	}
+
+	item.Points = item.Max * inEffect / available
+	if inEffect == available {
+		item.Status = AuditOK
+		item.Message = fmt.Sprintf("Sysctl optimizations in effect, %d values (+%d)", available, item.Points)
 	} else {
-		PrintWarning("Sysctl optimizations missing (0/20)")
+		item.Status = AuditWarn
+		item.Message = fmt.Sprintf("Sysctl optimizations partly in effect: %d/%d values (%d/%d)", inEffect, available, item.Points, item.Max)
+	}
+	return item
+}
+
+// RunAudit performs the audit and prints the report
+func (at *AuditTuner) RunAudit() error {
+	PrintStep("System Optimization Audit")
+
+	result := at.Evaluate()
+
+	for _, item := range result.Items {
+		switch item.Status {
+		case AuditOK:
+			PrintSuccess("%s", item.Message)
+		case AuditWarn:
+			PrintWarning("%s", item.Message)
+		default:
+			PrintError("%s", item.Message)
+		}
+		for _, detail := range item.Details {
+			if strings.HasPrefix(detail, "- ") {
+				fmt.Printf("    %s\n", detail)
+			} else {
+				PrintInfo("%s", detail)
+			}
+		}
 	}
 
 	fmt.Println()
 	PrintStep("Audit Result")
-	
-	fmt.Printf("Final Score: %d/%d\n", score, maxScore)
-	
-	if score == 100 {
+
+	fmt.Printf("Final Score: %d/%d\n", result.Score, result.MaxScore)
+
+	if result.Score == 100 {
 		PrintSuccess("System is fully optimized! 🚀")
-	} else if score >= 70 {
+	} else if result.Score >= 70 {
 		PrintInfo("System is well optimized, but could be better.")
 	} else {
 		PrintWarning("System requires optimization.")
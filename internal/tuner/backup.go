@@ -7,9 +7,47 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"strings"
 	"time"
 )
 
+// BackupRoot is the directory holding one sub-directory per backup session
+// (tuning.backup_dir in the config file)
+var BackupRoot = "/root/.vmware-tuner-backups"
+
+// restoreServices are restarted when rollback touches their configuration.
+// A service whose Config file was created by vmware-tuner is disabled instead,
+// before the file is removed.
+var restoreServices = []struct {
+	Prefix  string
+	Service string
+	Config  string
+}{
+	{"/etc/snmp/", "snmpd", "/etc/snmp/snmpd.conf"},
+	{"/etc/rsyslog.d/", "rsyslog", ""},
+	{"/etc/systemd/journald.conf.d/", "systemd-journald", ""},
+	{nodeExporterUnit, "node_exporter", nodeExporterUnit},
+	{toolsStatsTimer, "vmware-tools-stats.timer", toolsStatsTimer},
+	{"/etc/telegraf/", "telegraf", ""},
+	// tuned reads active_profile and tuned-main.conf when it starts
+	{"/etc/tuned/", "tuned", ""},
+}
+
+// runtimeResets are the kernel values a sysctl file leaves set once it is
+// removed: sysctl --system only loads the remaining files, it never resets
+// a key nobody sets anymore
+var runtimeResets = map[string]map[string]string{
+	"/etc/sysctl.d/99-vmware-tuner-ipv6.conf": {
+		"/proc/sys/net/ipv6/conf/all/disable_ipv6":     "0",
+		"/proc/sys/net/ipv6/conf/default/disable_ipv6": "0",
+	},
+	// The reserved pool stays taken from the memory of the guest
+	"/etc/sysctl.d/99-vmware-tuner-hugepages.conf": {
+		"/sys/kernel/mm/hugepages/hugepages-2048kB/nr_hugepages":    "0",
+		"/sys/kernel/mm/hugepages/hugepages-1048576kB/nr_hugepages": "0",
+	},
+}
+
 // BackupManager handles configuration file backups
 type BackupManager struct {
 	BackupDir string
@@ -21,18 +59,77 @@ type ManifestEntry struct {
 	OriginalPath string      `json:"original_path"`
 	BackupPath   string      `json:"backup_path"`
 	Mode         os.FileMode `json:"mode"`
+	Created      bool        `json:"created,omitempty"` // File did not exist: rollback removes it
 }
 
 // Manifest represents the backup manifest
 type Manifest struct {
 	Timestamp string          `json:"timestamp"`
 	Entries   []ManifestEntry `json:"entries"`
+
+	// Services are the units whose state the session changed (Server Slim),
+	// with their state before: rollback and debloat undo restore it
+	Services []ServiceState `json:"services,omitempty"`
+
+	// DisabledServices is the field of older manifests: the units are read
+	// as enabled and running before the session
+	DisabledServices []string `json:"disabled_services,omitempty"`
+
+	// BootArgs are the kernel options changed by a tool rather than in a
+	// file (grubby on BootLoaderSpec entries), in order: rollback undoes them
+	BootArgs []BootArgsChange `json:"boot_args,omitempty"`
+}
+
+// BootArgsChange is a change of kernel options made with a tool
+type BootArgsChange struct {
+	Tool    string   `json:"tool"` // grubby
+	Added   []string `json:"added,omitempty"`
+	Removed []string `json:"removed,omitempty"` // removed, or replaced by Added
+}
+
+// ServiceState is the state of a systemd unit recorded in a manifest
+type ServiceState struct {
+	Unit   string `json:"unit"`
+	State  string `json:"state"` // enabled, disabled, masked... as systemctl is-enabled prints it
+	Active bool   `json:"active"`
+}
+
+// String describes the state for the messages
+func (s ServiceState) String() string {
+	state := s.State
+	if state == "" {
+		state = "unknown"
+	}
+	if s.Active {
+		return state + ", running"
+	}
+	return state + ", stopped"
+}
+
+// migrate reads the fields of older manifests into the current ones
+func (m *Manifest) migrate() {
+	for _, name := range m.DisabledServices {
+		if m.serviceState(name) == nil {
+			m.Services = append(m.Services, ServiceState{Unit: name, State: "enabled", Active: true})
+		}
+	}
+	m.DisabledServices = nil
+}
+
+// serviceState returns the recorded state of a unit, nil when not recorded
+func (m *Manifest) serviceState(unit string) *ServiceState {
+	for i := range m.Services {
+		if m.Services[i].Unit == unit {
+			return &m.Services[i]
+		}
+	}
+	return nil
 }
 
 // NewBackupManager creates a new backup manager
 func NewBackupManager() *BackupManager {
 	timestamp := time.Now().Format("20060102-150405")
-	backupDir := filepath.Join("/root", ".vmware-tuner-backups", timestamp)
+	backupDir := filepath.Join(BackupRoot, timestamp)
 
 	return &BackupManager{
 		BackupDir: backupDir,
@@ -42,16 +139,31 @@ func NewBackupManager() *BackupManager {
 
 // Initialize creates the backup directory
 func (bm *BackupManager) Initialize() error {
+	// Every change starts with a backup session
+	if ReadOnly {
+		return fmt.Errorf("starting a backup session: %w", ErrReadOnly)
+	}
 	if err := os.MkdirAll(bm.BackupDir, 0700); err != nil {
 		return fmt.Errorf("failed to create backup directory: %w", err)
 	}
 	return nil
 }
 
-// BackupFile creates a backup of the specified file
+// BackupFile creates a backup of the specified file.
+// A file that does not exist yet is recorded so rollback removes it.
+// Only the first call of a session copies the file: later calls, after
+// another module of the same run changed it, keep that copy, so rollback
+// returns to the state before the session rather than to an intermediate one.
 func (bm *BackupManager) BackupFile(filePath string) error {
+	if bm.hasEntry(filePath) {
+		return nil
+	}
+
 	// Check if source file exists
 	if _, err := os.Stat(filePath); os.IsNotExist(err) {
+		if err := bm.appendEntry(ManifestEntry{OriginalPath: filePath, Created: true}); err != nil {
+			PrintWarning("Failed to update manifest: %v", err)
+		}
 		return nil
 	}
 
@@ -86,11 +198,61 @@ func (bm *BackupManager) BackupFile(filePath string) error {
 		}
 	}
 
+	LogAction("backup", "backup-file", ResultSuccess, fmt.Sprintf("file=%s backup=%s", filePath, backupPath))
+
 	return nil
 }
 
+// RecordBootArgs records a change of kernel options made with a tool, so
+// rollback can undo it
+func (bm *BackupManager) RecordBootArgs(change BootArgsChange) error {
+	return bm.updateManifest(func(m *Manifest) {
+		m.BootArgs = append(m.BootArgs, change)
+	})
+}
+
+// WriteRecord saves metadata of the session (not a restorable file) as JSON
+// in the backup directory
+func (bm *BackupManager) WriteRecord(name string, v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal %s: %w", name, err)
+	}
+	return os.WriteFile(filepath.Join(bm.BackupDir, name), data, 0600)
+}
+
 // AddEntry adds a file entry to the manifest.json
 func (bm *BackupManager) AddEntry(original, backupName string, info os.FileInfo) error {
+	return bm.appendEntry(ManifestEntry{
+		OriginalPath: original,
+		BackupPath:   backupName,
+		Mode:         info.Mode(),
+	})
+}
+
+// hasEntry reports whether the file is already recorded in this session's manifest
+func (bm *BackupManager) hasEntry(original string) bool {
+	manifest, err := LoadManifest(bm.BackupDir)
+	if err != nil {
+		return false
+	}
+	for _, entry := range manifest.Entries {
+		if entry.OriginalPath == original {
+			return true
+		}
+	}
+	return false
+}
+
+// appendEntry appends an entry to the manifest.json
+func (bm *BackupManager) appendEntry(entry ManifestEntry) error {
+	return bm.updateManifest(func(m *Manifest) {
+		m.Entries = append(m.Entries, entry)
+	})
+}
+
+// updateManifest applies a change to the manifest.json, created on first use
+func (bm *BackupManager) updateManifest(change func(m *Manifest)) error {
 	manifestPath := filepath.Join(bm.BackupDir, "manifest.json")
 
 	var manifest Manifest
@@ -99,18 +261,13 @@ func (bm *BackupManager) AddEntry(original, backupName string, info os.FileInfo)
 	data, err := os.ReadFile(manifestPath)
 	if err == nil {
 		json.Unmarshal(data, &manifest)
+		manifest.migrate()
 	} else {
 		manifest.Timestamp = bm.Timestamp
 		manifest.Entries = []ManifestEntry{}
 	}
 
-	entry := ManifestEntry{
-		OriginalPath: original,
-		BackupPath:   backupName,
-		Mode:         info.Mode(),
-	}
-
-	manifest.Entries = append(manifest.Entries, entry)
+	change(&manifest)
 
 	newData, err := json.MarshalIndent(manifest, "", "  ")
 	if err != nil {
@@ -120,30 +277,168 @@ func (bm *BackupManager) AddEntry(original, backupName string, info os.FileInfo)
 	return os.WriteFile(manifestPath, newData, 0644)
 }
 
-// RestoreFromManifest restores files based on the manifest.json
-func (bm *BackupManager) RestoreFromManifest() error {
-	manifestPath := filepath.Join(bm.BackupDir, "manifest.json")
+// LoadManifest reads the manifest.json of a backup directory
+func LoadManifest(backupDir string) (*Manifest, error) {
+	manifestPath := filepath.Join(backupDir, "manifest.json")
 	data, err := os.ReadFile(manifestPath)
 	if err != nil {
-		return fmt.Errorf("manifest not found: %w", err)
+		return nil, fmt.Errorf("manifest not found: %w", err)
 	}
 
 	var manifest Manifest
 	if err := json.Unmarshal(data, &manifest); err != nil {
-		return fmt.Errorf("failed to parse manifest: %w", err)
+		return nil, fmt.Errorf("failed to parse manifest: %w", err)
+	}
+	manifest.migrate()
+	return &manifest, nil
+}
+
+// RestoreFromManifest restores files based on the manifest.json
+func (bm *BackupManager) RestoreFromManifest() error {
+	return bm.RestoreEntries(nil)
+}
+
+// SelectEntries returns the manifest entries of the given paths (all entries
+// when paths is empty). A path missing from the manifest is an error.
+func (m *Manifest) SelectEntries(paths []string) ([]ManifestEntry, error) {
+	if len(paths) == 0 {
+		return m.Entries, nil
+	}
+
+	byPath := make(map[string]ManifestEntry)
+	for _, entry := range m.Entries {
+		byPath[entry.OriginalPath] = entry
+	}
+
+	var selected []ManifestEntry
+	for _, path := range paths {
+		entry, ok := byPath[filepath.Clean(path)]
+		if !ok {
+			return nil, fmt.Errorf("%s is not in the backup of %s", path, m.Timestamp)
+		}
+		selected = append(selected, entry)
+	}
+	return selected, nil
+}
+
+// reloadPlan lists what must be reloaded after restoring some entries
+type reloadPlan struct {
+	DaemonReload bool
+	Grub         bool
+	Sysctl       bool
+	Udev         bool
+	Initramfs    bool
+	Trust        bool
+	Kernelstub   bool
+	Swapoff      []string          // created swapfiles turned off before their removal
+	Grubby       [][]string        // grubby calls undoing the changes of the BootLoaderSpec entries
+	Runtime      map[string]string // /proc/sys file -> value written before sysctl --system
+	Restart      map[string]bool   // service -> restart (false: disabled before removal)
+}
+
+// planReloads returns the reloads needed by the restored entries and the
+// kernel options changed by tools in the session (bootArgs)
+func planReloads(entries []ManifestEntry, bootArgs []BootArgsChange) reloadPlan {
+	plan := reloadPlan{Restart: make(map[string]bool), Runtime: make(map[string]string)}
+	for _, entry := range entries {
+		path := entry.OriginalPath
+		for file, value := range runtimeResets[path] {
+			plan.Runtime[file] = value
+		}
+		switch {
+		case path == "/etc/default/grub" || strings.HasPrefix(path, "/etc/default/grub.d/"):
+			plan.Grub = true
+		case path == "/etc/sysctl.conf" || strings.HasPrefix(path, "/etc/sysctl.d/"):
+			plan.Sysctl = true
+		case path == "/etc/fstab" || strings.HasPrefix(path, "/etc/systemd/") || strings.HasSuffix(path, ".service") || strings.HasSuffix(path, ".timer"):
+			// systemd generates mount units from fstab
+			plan.DaemonReload = true
+		case strings.HasPrefix(path, "/etc/udev/rules.d/"):
+			plan.Udev = true
+		case strings.HasPrefix(path, "/etc/modprobe.d/"):
+			// Driver options of the root disk are read from the initramfs
+			plan.Initramfs = true
+		case path == kernelstubConfig:
+			// kernelstub writes the loader entry from its configuration
+			plan.Kernelstub = true
+		case path == swapFilePath && entry.Created:
+			plan.Swapoff = append(plan.Swapoff, path)
+		}
+		for _, dir := range caAnchorDirs {
+			if strings.HasPrefix(path, dir+"/") {
+				plan.Trust = true
+			}
+		}
+		for _, svc := range restoreServices {
+			if !strings.HasPrefix(path, svc.Prefix) {
+				continue
+			}
+			if entry.Created && path == svc.Config {
+				plan.Restart[svc.Service] = false
+			} else if _, seen := plan.Restart[svc.Service]; !seen {
+				plan.Restart[svc.Service] = true
+			}
+		}
+	}
+	// The BootLoaderSpec entries follow /etc/default/grub
+	if plan.Grub {
+		plan.Grubby = inverseGrubbyArgs(bootArgs)
+	}
+	return plan
+}
+
+// RestoreEntries restores the given files from the manifest.json (all files
+// and the recorded service states when only is empty) and triggers only the
+// reloads they need
+func (bm *BackupManager) RestoreEntries(only []string) error {
+	manifest, err := LoadManifest(bm.BackupDir)
+	if err != nil {
+		return err
+	}
+	entries, err := manifest.SelectEntries(only)
+	if err != nil {
+		return err
 	}
 
 	PrintInfo("Restauration du backup du %s...", manifest.Timestamp)
 
-	for _, entry := range manifest.Entries {
+	// Services set up by this session are stopped while their units still exist
+	plan := planReloads(entries, manifest.BootArgs)
+	for service, restart := range plan.Restart {
+		if !restart {
+			PrintInfo("Désactivation de %s (installé par vmware-tuner)", service)
+			exec.Command("systemctl", "disable", "--now", service).Run()
+		}
+	}
+
+	for _, file := range plan.Swapoff {
+		PrintInfo("Désactivation du swap %s", file)
+		if out, err := exec.Command("swapoff", file).CombinedOutput(); err != nil {
+			PrintWarning("swapoff %s a échoué: %v: %s", file, err, strings.TrimSpace(string(out)))
+		}
+	}
+
+	for _, entry := range entries {
 		srcPath := filepath.Join(bm.BackupDir, entry.BackupPath)
 		destPath := entry.OriginalPath
 
+		if entry.Created {
+			PrintInfo("Suppression %s (créé par vmware-tuner)", destPath)
+			if err := os.Remove(destPath); err != nil && !os.IsNotExist(err) {
+				PrintError("Impossible de supprimer %s: %v", destPath, err)
+				LogAction("rollback", "remove-file", ResultFailed, "file="+destPath)
+			} else {
+				LogAction("rollback", "remove-file", ResultSuccess, "file="+destPath)
+			}
+			continue
+		}
+
 		PrintInfo("Restauration %s -> %s", entry.BackupPath, destPath)
 
 		src, err := os.Open(srcPath)
 		if err != nil {
 			PrintError("Impossible d'ouvrir le fichier backup %s: %v", srcPath, err)
+			LogAction("rollback", "restore-file", ResultFailed, "file="+destPath)
 			continue
 		}
 
@@ -152,11 +447,15 @@ func (bm *BackupManager) RestoreFromManifest() error {
 		if err != nil {
 			src.Close()
 			PrintError("Impossible d'écrire sur la destination %s: %v", destPath, err)
+			LogAction("rollback", "restore-file", ResultFailed, "file="+destPath)
 			continue
 		}
 
 		if _, err := io.Copy(dest, src); err != nil {
 			PrintError("Erreur de copie vers %s: %v", destPath, err)
+			LogAction("rollback", "restore-file", ResultFailed, "file="+destPath)
+		} else {
+			LogAction("rollback", "restore-file", ResultSuccess, fmt.Sprintf("file=%s backup=%s", destPath, manifest.Timestamp))
 		}
 
 		dest.Chmod(entry.Mode)
@@ -164,9 +463,11 @@ func (bm *BackupManager) RestoreFromManifest() error {
 		dest.Close()
 	}
 
-	// Trigger system reloads
-	exec.Command("systemctl", "daemon-reload").Run()
-	if _, err := os.Stat("/etc/default/grub"); err == nil {
+	// Trigger the reloads of the restored files
+	if plan.DaemonReload {
+		exec.Command("systemctl", "daemon-reload").Run()
+	}
+	if plan.Grub {
 		if _, err := exec.LookPath("update-grub"); err == nil {
 			exec.Command("update-grub").Run()
 		} else {
@@ -174,7 +475,76 @@ func (bm *BackupManager) RestoreFromManifest() error {
 			exec.Command("grub2-mkconfig", "-o", "/boot/grub2/grub.cfg").Run()
 		}
 	}
-	exec.Command("sysctl", "--system").Run()
+	if plan.Kernelstub {
+		PrintInfo("Régénération de l'entrée de boot avec kernelstub")
+		if out, err := exec.Command("kernelstub").CombinedOutput(); err != nil {
+			PrintError("kernelstub a échoué: %v: %s", err, strings.TrimSpace(string(out)))
+			LogAction("rollback", "kernelstub", ResultFailed, "")
+		} else {
+			LogAction("rollback", "kernelstub", ResultSuccess, "")
+		}
+	}
+	for _, args := range plan.Grubby {
+		PrintInfo("grubby %s", strings.Join(args, " "))
+		if out, err := exec.Command("grubby", args...).CombinedOutput(); err != nil {
+			PrintError("grubby a échoué: %v: %s", err, strings.TrimSpace(string(out)))
+			LogAction("rollback", "grubby", ResultFailed, strings.Join(args, " "))
+		} else {
+			LogAction("rollback", "grubby", ResultSuccess, strings.Join(args, " "))
+		}
+	}
+	for file, value := range plan.Runtime {
+		if !FileExists(file) {
+			// Page size not supported by the CPU, IPv6 module not loaded
+			continue
+		}
+		PrintInfo("Remise à %s de %s", value, file)
+		if err := os.WriteFile(file, []byte(value), 0644); err != nil {
+			PrintError("Impossible d'écrire %s: %v", file, err)
+			LogAction("rollback", "reset-sysctl", ResultFailed, "file="+file)
+		} else {
+			LogAction("rollback", "reset-sysctl", ResultSuccess, fmt.Sprintf("file=%s value=%s", file, value))
+		}
+	}
+	if plan.Sysctl {
+		exec.Command("sysctl", "--system").Run()
+	}
+	if plan.Udev {
+		exec.Command("udevadm", "control", "--reload-rules").Run()
+	}
+	if plan.Initramfs {
+		if _, err := exec.LookPath("update-initramfs"); err == nil {
+			exec.Command("update-initramfs", "-u").Run()
+		} else {
+			exec.Command("dracut", "-f").Run()
+		}
+	}
+	if plan.Trust {
+		for t, dir := range caAnchorDirs {
+			if FileExists(dir) {
+				RefreshTrustStore(t)
+			}
+		}
+	}
+
+	// Unit files are back in place: the units get their previous state
+	if len(only) == 0 {
+		for _, state := range manifest.Services {
+			PrintInfo("Restauration de l'état de %s (%s)", state.Unit, state)
+			if err := RestoreServiceState(state); err != nil {
+				PrintError("%v", err)
+				LogAction("rollback", "restore-service", ResultFailed, "unit="+state.Unit)
+			} else {
+				LogAction("rollback", "restore-service", ResultSuccess, fmt.Sprintf("unit=%s state=%s", state.Unit, state.State))
+			}
+		}
+	}
+
+	for service, needed := range plan.Restart {
+		if needed {
+			exec.Command("systemctl", "try-restart", service).Run()
+		}
+	}
 
 	PrintSuccess("Restauration terminée.")
 	return nil
@@ -186,12 +556,11 @@ func (bm *BackupManager) GetBackupPath(filename string) string {
 
 // ListBackups lists all available backup timestamps
 func ListBackups() ([]string, error) {
-	backupRoot := "/root/.vmware-tuner-backups"
-	if _, err := os.Stat(backupRoot); os.IsNotExist(err) {
+	if _, err := os.Stat(BackupRoot); os.IsNotExist(err) {
 		return []string{}, nil
 	}
 
-	entries, err := os.ReadDir(backupRoot)
+	entries, err := os.ReadDir(BackupRoot)
 	if err != nil {
 		return nil, err
 	}
@@ -205,7 +574,111 @@ func ListBackups() ([]string, error) {
 	return backups, nil
 }
 
+// LatestBackupOf returns the most recent backup session holding some of the
+// files (a trailing slash matches a directory, wildcards are allowed) and the
+// files of the session that match
+func LatestBackupOf(files []string) (string, []string, error) {
+	backups, err := ListBackups()
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to list backups: %w", err)
+	}
+	for i := len(backups) - 1; i >= 0; i-- {
+		manifest, err := LoadManifest(filepath.Join(BackupRoot, backups[i]))
+		if err != nil {
+			continue
+		}
+		var matched []string
+		for _, entry := range manifest.Entries {
+			if matchesAny(entry.OriginalPath, files) {
+				matched = append(matched, entry.OriginalPath)
+			}
+		}
+		if len(matched) > 0 {
+			return backups[i], matched, nil
+		}
+	}
+	return "", nil, nil
+}
+
+// matchesAny reports whether a path is one of the files, directories or patterns
+func matchesAny(path string, files []string) bool {
+	for _, f := range files {
+		if strings.HasSuffix(f, "/") && strings.HasPrefix(path, f) {
+			return true
+		}
+		if ok, _ := filepath.Match(f, path); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// readServiceState reads the enable state of a unit and whether it runs
+var readServiceState = func(unit string) ServiceState {
+	// is-enabled exits non-zero for disabled and masked units but prints the state
+	out, _ := exec.Command("systemctl", "is-enabled", unit).Output()
+	return ServiceState{
+		Unit:   unit,
+		State:  strings.TrimSpace(string(out)),
+		Active: exec.Command("systemctl", "is-active", "--quiet", unit).Run() == nil,
+	}
+}
+
+// BackupServices records the state of services about to be disabled or
+// masked in the manifest, so rollback and debloat undo can restore it. The
+// first state recorded in a session is kept.
 func (bm *BackupManager) BackupServices(services []string) error {
-	// Not used in manifest logic directly but kept for compatibility
+	return bm.updateManifest(func(m *Manifest) {
+		for _, name := range services {
+			if m.serviceState(name) == nil {
+				m.Services = append(m.Services, readServiceState(name))
+			}
+		}
+	})
+}
+
+// LatestServiceStates returns the most recent backup session that changed
+// services, and their recorded states
+func LatestServiceStates() (string, []ServiceState, error) {
+	backups, err := ListBackups()
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to list backups: %w", err)
+	}
+	for i := len(backups) - 1; i >= 0; i-- {
+		manifest, err := LoadManifest(filepath.Join(BackupRoot, backups[i]))
+		if err == nil && len(manifest.Services) > 0 {
+			return backups[i], manifest.Services, nil
+		}
+	}
+	return "", nil, nil
+}
+
+// RestoreServiceState puts a unit back in its recorded state: masked,
+// enabled or disabled, then running or stopped. Other states (static,
+// indirect...) are not changed by systemctl enable: only a mask is removed.
+func RestoreServiceState(s ServiceState) error {
+	var commands [][]string
+	switch {
+	case strings.HasPrefix(s.State, "masked"):
+		commands = append(commands, []string{"mask", s.Unit})
+	case strings.HasPrefix(s.State, "enabled"):
+		commands = append(commands, []string{"unmask", s.Unit}, []string{"enable", s.Unit})
+	case s.State == "disabled":
+		commands = append(commands, []string{"unmask", s.Unit}, []string{"disable", s.Unit})
+	default:
+		commands = append(commands, []string{"unmask", s.Unit})
+	}
+	if s.Active {
+		commands = append(commands, []string{"start", s.Unit})
+	} else {
+		commands = append(commands, []string{"stop", s.Unit})
+	}
+
+	for _, args := range commands {
+		ExplainCommand("restore the recorded state of "+s.Unit+" ("+s.String()+")", "systemctl", args...)
+		if out, err := exec.Command("systemctl", args...).CombinedOutput(); err != nil {
+			return fmt.Errorf("failed to %s %s: %s", args[0], s.Unit, strings.TrimSpace(string(out)))
+		}
+	}
 	return nil
 }
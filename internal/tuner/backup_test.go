@@ -0,0 +1,266 @@
+package tuner
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestSelectEntries(t *testing.T) {
+	m := &Manifest{Timestamp: "20240101-120000", Entries: []ManifestEntry{
+		{OriginalPath: "/etc/fstab", BackupPath: "fstab"},
+		{OriginalPath: "/etc/default/grub", BackupPath: "grub"},
+		{OriginalPath: "/etc/sysctl.d/99-vmware-performance.conf", Created: true},
+	}}
+
+	all, err := m.SelectEntries(nil)
+	if err != nil || len(all) != 3 {
+		t.Fatalf("no filter should select every entry, got %d (%v)", len(all), err)
+	}
+
+	got, err := m.SelectEntries([]string{"/etc/default/grub/", "/etc/fstab"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || got[0].BackupPath != "grub" || got[1].BackupPath != "fstab" {
+		t.Errorf("unexpected selection %+v", got)
+	}
+
+	if _, err := m.SelectEntries([]string{"/etc/hosts"}); err == nil {
+		t.Error("a file outside the manifest should be refused")
+	}
+}
+
+func TestPlanReloads(t *testing.T) {
+	plan := planReloads([]ManifestEntry{{OriginalPath: "/etc/fstab"}}, nil)
+	if !plan.DaemonReload || plan.Grub || plan.Sysctl {
+		t.Errorf("fstab only needs a daemon-reload: %+v", plan)
+	}
+
+	plan = planReloads([]ManifestEntry{
+		{OriginalPath: "/etc/default/grub"},
+		{OriginalPath: "/etc/sysctl.d/99-vmware-performance.conf"},
+		{OriginalPath: "/etc/snmp/snmpd.conf", Created: true},
+	}, nil)
+	if !plan.Grub || !plan.Sysctl || plan.DaemonReload {
+		t.Errorf("unexpected plan %+v", plan)
+	}
+	if restart, ok := plan.Restart["snmpd"]; !ok || restart {
+		t.Errorf("snmpd installed by the tool should be disabled, not restarted: %+v", plan.Restart)
+	}
+
+	// tuned switches back to the restored active_profile when it restarts
+	plan = planReloads([]ManifestEntry{{OriginalPath: "/etc/tuned/vmware-tuner/tuned.conf", Created: true}, {OriginalPath: "/etc/tuned/active_profile"}}, nil)
+	if restart, ok := plan.Restart["tuned"]; !ok || !restart {
+		t.Errorf("tuned should be restarted: %+v", plan.Restart)
+	}
+
+	if plan := planReloads([]ManifestEntry{{OriginalPath: "/etc/modprobe.d/vmware-tuner-pvscsi.conf"}}, nil); !plan.Initramfs {
+		t.Errorf("restoring module options should rebuild the initramfs: %+v", plan)
+	}
+	if plan := planReloads([]ManifestEntry{{OriginalPath: kernelstubConfig}}, nil); !plan.Kernelstub || plan.Grub {
+		t.Errorf("restoring the kernelstub configuration should rerun kernelstub: %+v", plan)
+	}
+
+	plan = planReloads([]ManifestEntry{{OriginalPath: swapFilePath, Created: true}, {OriginalPath: "/etc/fstab"}}, nil)
+	if !reflect.DeepEqual(plan.Swapoff, []string{swapFilePath}) || !plan.DaemonReload {
+		t.Errorf("the created swapfile should be turned off before its removal: %+v", plan)
+	}
+
+	// Removing the IPv6 sysctl file does not re-enable IPv6 by itself
+	plan = planReloads([]ManifestEntry{{OriginalPath: NewIPv6Tuner(true, nil).SysctlPath, Created: true}}, nil)
+	if !plan.Sysctl || plan.Runtime["/proc/sys/net/ipv6/conf/all/disable_ipv6"] != "0" || plan.Runtime["/proc/sys/net/ipv6/conf/default/disable_ipv6"] != "0" {
+		t.Errorf("ipv6 rollback should write disable_ipv6 = 0: %+v", plan)
+	}
+	plan = planReloads([]ManifestEntry{{OriginalPath: NewHugePagesTuner(true, nil).SysctlPath, Created: true}}, nil)
+	if plan.Runtime["/sys/kernel/mm/hugepages/hugepages-2048kB/nr_hugepages"] != "0" {
+		t.Errorf("hugepages rollback should free the pool: %+v", plan.Runtime)
+	}
+	if plan := planReloads([]ManifestEntry{{OriginalPath: "/etc/sysctl.d/99-vmware-performance.conf"}}, nil); len(plan.Runtime) != 0 {
+		t.Errorf("unexpected runtime resets: %v", plan.Runtime)
+	}
+}
+
+func TestLatestBackupOf(t *testing.T) {
+	root := t.TempDir()
+	saved := BackupRoot
+	BackupRoot = root
+	defer func() { BackupRoot = saved }()
+
+	session := func(timestamp string, files ...string) {
+		bm := &BackupManager{BackupDir: filepath.Join(root, timestamp), Timestamp: timestamp}
+		if err := bm.Initialize(); err != nil {
+			t.Fatal(err)
+		}
+		for _, f := range files {
+			if err := bm.BackupFile(f); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	dir := t.TempDir()
+	sysctl := filepath.Join(dir, "sysctl.d", "99-vmware-performance.conf")
+	unit := filepath.Join(dir, "network-tuning.service")
+	session("20240101-120000", sysctl, unit)
+	session("20240102-120000", sysctl)
+
+	got, files, err := LatestBackupOf([]string{unit})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != "20240101-120000" || len(files) != 1 || files[0] != unit {
+		t.Errorf("the unit is only in the first session, got %s %v", got, files)
+	}
+
+	got, files, _ = LatestBackupOf([]string{filepath.Join(dir, "sysctl.d") + "/"})
+	if got != "20240102-120000" || len(files) != 1 {
+		t.Errorf("a directory should match the newest session holding its files, got %s %v", got, files)
+	}
+
+	got, _, _ = LatestBackupOf([]string{filepath.Join(dir, "*.service")})
+	if got != "20240101-120000" {
+		t.Errorf("a pattern should match, got %q", got)
+	}
+
+	if got, _, _ := LatestBackupOf([]string{"/etc/fstab"}); got != "" {
+		t.Errorf("no session holds /etc/fstab, got %q", got)
+	}
+}
+
+func TestBackupServices(t *testing.T) {
+	root := t.TempDir()
+	saved, savedRead := BackupRoot, readServiceState
+	BackupRoot = root
+	readServiceState = func(unit string) ServiceState {
+		return ServiceState{Unit: unit, State: "enabled", Active: unit != "cups"}
+	}
+	defer func() { BackupRoot, readServiceState = saved, savedRead }()
+
+	for _, s := range []struct {
+		timestamp string
+		services  []string
+	}{
+		{"20240101-120000", []string{"cups", "avahi-daemon"}},
+		{"20240102-120000", nil},
+	} {
+		bm := &BackupManager{BackupDir: filepath.Join(root, s.timestamp), Timestamp: s.timestamp}
+		if err := bm.Initialize(); err != nil {
+			t.Fatal(err)
+		}
+		if err := bm.BackupFile(filepath.Join(root, "absent.conf")); err != nil {
+			t.Fatal(err)
+		}
+		for _, name := range s.services {
+			if err := bm.BackupServices([]string{name, "cups"}); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	timestamp, services, err := LatestServiceStates()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []ServiceState{{"cups", "enabled", false}, {"avahi-daemon", "enabled", true}}
+	if timestamp != "20240101-120000" || !reflect.DeepEqual(services, want) {
+		t.Errorf("got %s %v, want the first session with cups and avahi-daemon once each", timestamp, services)
+	}
+	manifest, err := LoadManifest(filepath.Join(root, timestamp))
+	if err != nil || len(manifest.Entries) != 1 {
+		t.Errorf("the file entries should be kept: %+v (%v)", manifest, err)
+	}
+}
+
+func TestManifestMigratesDisabledServices(t *testing.T) {
+	dir := t.TempDir()
+	old := `{"timestamp": "20240101-120000", "entries": [], "disabled_services": ["cups", "bluetooth"]}`
+	if err := os.WriteFile(filepath.Join(dir, "manifest.json"), []byte(old), 0644); err != nil {
+		t.Fatal(err)
+	}
+	manifest, err := LoadManifest(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []ServiceState{{"cups", "enabled", true}, {"bluetooth", "enabled", true}}
+	if !reflect.DeepEqual(manifest.Services, want) || manifest.DisabledServices != nil {
+		t.Errorf("services = %+v, disabled = %v, want %+v", manifest.Services, manifest.DisabledServices, want)
+	}
+}
+
+func TestPlanReloadsGrubby(t *testing.T) {
+	before := []string{"quiet", "elevator=deadline"}
+	add := []string{"elevator=noop", "nmi_watchdog=0"}
+	changes := []BootArgsChange{
+		{Tool: "grubby", Added: add, Removed: append([]string{"rhgb"}, replacedParams(before, add)...)},
+		{Tool: "grubby", Added: []string{"hugepages=512"}},
+	}
+
+	// The BootLoaderSpec entries are reverted with /etc/default/grub only
+	if plan := planReloads([]ManifestEntry{{OriginalPath: "/etc/fstab"}}, changes); len(plan.Grubby) != 0 {
+		t.Errorf("grubby run without restoring the grub file: %v", plan.Grubby)
+	}
+	plan := planReloads([]ManifestEntry{{OriginalPath: "/etc/default/grub"}}, changes)
+	want := [][]string{
+		{"--update-kernel=ALL", "--remove-args=hugepages=512"},
+		{"--update-kernel=ALL", "--remove-args=elevator=noop nmi_watchdog=0"},
+		{"--update-kernel=ALL", "--args=rhgb elevator=deadline"},
+	}
+	if !reflect.DeepEqual(plan.Grubby, want) {
+		t.Errorf("grubby calls = %q\nwant %q", plan.Grubby, want)
+	}
+}
+
+func TestRecordBootArgs(t *testing.T) {
+	bm := &BackupManager{BackupDir: t.TempDir(), Timestamp: "20240101-120000"}
+	if err := bm.RecordBootArgs(BootArgsChange{Tool: "grubby", Added: []string{"nmi_watchdog=0"}}); err != nil {
+		t.Fatal(err)
+	}
+	m, err := LoadManifest(bm.BackupDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(m.BootArgs) != 1 || m.BootArgs[0].Added[0] != "nmi_watchdog=0" {
+		t.Errorf("boot args = %+v", m.BootArgs)
+	}
+}
+
+func TestBackupFileKeepsFirstCopy(t *testing.T) {
+	dir := t.TempDir()
+	bm := &BackupManager{BackupDir: filepath.Join(dir, "backup"), Timestamp: "20240101-120000"}
+	if err := bm.Initialize(); err != nil {
+		t.Fatal(err)
+	}
+	conf := filepath.Join(dir, "rsyslog.conf")
+	created := filepath.Join(dir, "50-forward.conf")
+	os.WriteFile(conf, []byte("original\n"), 0644)
+
+	// Two modules of the same run change the same files
+	for _, content := range []string{"first change\n", "second change\n"} {
+		if err := bm.BackupFile(conf); err != nil {
+			t.Fatal(err)
+		}
+		if err := bm.BackupFile(created); err != nil {
+			t.Fatal(err)
+		}
+		os.WriteFile(conf, []byte(content), 0644)
+		os.WriteFile(created, []byte(content), 0644)
+	}
+
+	m, err := LoadManifest(bm.BackupDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(m.Entries) != 2 || m.Entries[0].Created || !m.Entries[1].Created {
+		t.Fatalf("entries = %+v, want one per file", m.Entries)
+	}
+	if err := bm.RestoreEntries(nil); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(conf); string(data) != "original\n" {
+		t.Errorf("rollback restored %q, want the state before the session", data)
+	}
+	if FileExists(created) {
+		t.Error("a file created by the session should be removed even after later changes")
+	}
+}
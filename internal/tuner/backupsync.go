@@ -0,0 +1,282 @@
+package tuner
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// backupTimestampRe matches the backup session directories (NewBackupManager)
+var backupTimestampRe = regexp.MustCompile(`^\d{8}-\d{6}$`)
+
+// BackupTarget is where backups are exported to or imported from:
+// scp://[user@]host[:port]/path, s3://bucket/prefix or a local path
+type BackupTarget struct {
+	Scheme string // scp, s3 or file
+	User   string
+	Host   string
+	Port   string
+	Path   string // remote path, S3 key/prefix (no leading slash) or local path
+}
+
+// ParseBackupTarget parses an export destination or import source
+func ParseBackupTarget(raw string) (BackupTarget, error) {
+	if !strings.Contains(raw, "://") {
+		if raw == "" {
+			return BackupTarget{}, fmt.Errorf("empty backup target")
+		}
+		return BackupTarget{Scheme: "file", Path: raw}, nil
+	}
+
+	u, err := url.Parse(raw)
+	if err != nil {
+		return BackupTarget{}, fmt.Errorf("invalid backup target %q: %w", raw, err)
+	}
+	t := BackupTarget{Scheme: u.Scheme, Host: u.Hostname(), Port: u.Port(), Path: u.Path}
+	if u.User != nil {
+		t.User = u.User.Username()
+	}
+
+	switch t.Scheme {
+	case "file":
+		if t.Path == "" {
+			return BackupTarget{}, fmt.Errorf("file target needs a path")
+		}
+	case "scp":
+		if t.Host == "" || t.Path == "" {
+			return BackupTarget{}, fmt.Errorf("scp target must be scp://[user@]host[:port]/path")
+		}
+	case "s3":
+		if t.Host == "" {
+			return BackupTarget{}, fmt.Errorf("s3 target must be s3://bucket/prefix")
+		}
+		t.Path = strings.TrimPrefix(t.Path, "/")
+	default:
+		return BackupTarget{}, fmt.Errorf("unsupported backup target %q (use scp://, s3:// or a local path)", t.Scheme)
+	}
+	return t, nil
+}
+
+// String returns the target in URL form
+func (t BackupTarget) String() string {
+	switch t.Scheme {
+	case "scp":
+		return "scp://" + t.scpHost() + t.Path
+	case "s3":
+		return "s3://" + t.Host + "/" + t.Path
+	}
+	return t.Path
+}
+
+// scpHost returns [user@]host
+func (t BackupTarget) scpHost() string {
+	if t.User != "" {
+		return t.User + "@" + t.Host
+	}
+	return t.Host
+}
+
+// scpArgs returns the scp options (port, batch mode: no password prompt)
+func (t BackupTarget) scpArgs() []string {
+	args := []string{"-q", "-o", "BatchMode=yes"}
+	if t.Port != "" {
+		args = append(args, "-P", t.Port)
+	}
+	return args
+}
+
+// s3Args returns the aws CLI options for S3-compatible endpoints
+func s3Args(endpoint string) []string {
+	if endpoint == "" {
+		return nil
+	}
+	return []string{"--endpoint-url", endpoint}
+}
+
+// BackupArchiveName is the file name of an exported backup
+func BackupArchiveName(timestamp string) string {
+	host, _ := os.Hostname()
+	return fmt.Sprintf("vmware-tuner-backup-%s-%s.tar.gz", host, timestamp)
+}
+
+// ExportBackup archives a backup session (files and manifest) and copies it
+// to dest. endpoint is the URL of an S3-compatible service (empty: AWS).
+// It returns the location of the archive.
+func ExportBackup(timestamp, dest, endpoint string) (string, error) {
+	if !backupTimestampRe.MatchString(timestamp) {
+		return "", fmt.Errorf("invalid backup timestamp %q", timestamp)
+	}
+	if _, err := LoadManifest(filepath.Join(BackupRoot, timestamp)); err != nil {
+		return "", fmt.Errorf("backup %s: %w", timestamp, err)
+	}
+	target, err := ParseBackupTarget(dest)
+	if err != nil {
+		return "", err
+	}
+
+	tmp, err := os.MkdirTemp("", "vmware-tuner-export")
+	if err != nil {
+		return "", fmt.Errorf("failed to create temporary directory: %w", err)
+	}
+	defer os.RemoveAll(tmp)
+
+	name := BackupArchiveName(timestamp)
+	archive := filepath.Join(tmp, name)
+	if out, err := exec.Command("tar", "-czf", archive, "-C", BackupRoot, timestamp).CombinedOutput(); err != nil {
+		return "", fmt.Errorf("failed to archive %s: %v: %s", timestamp, err, string(out))
+	}
+
+	var cmd *exec.Cmd
+	var location string
+	switch target.Scheme {
+	case "scp":
+		location = "scp://" + target.scpHost() + path.Join(target.Path, name)
+		args := append(target.scpArgs(), archive, target.scpHost()+":"+target.Path+"/")
+		cmd = exec.Command("scp", args...)
+	case "s3":
+		location = "s3://" + target.Host + "/" + path.Join(target.Path, name)
+		args := append([]string{"s3", "cp", "--only-show-errors", archive, location}, s3Args(endpoint)...)
+		cmd = exec.Command("aws", args...)
+	default:
+		if err := os.MkdirAll(target.Path, 0700); err != nil {
+			return "", fmt.Errorf("failed to create %s: %w", target.Path, err)
+		}
+		location = filepath.Join(target.Path, name)
+		if err := copyFile(archive, location, 0600); err != nil {
+			return "", err
+		}
+	}
+
+	if cmd != nil {
+		if err := runTransfer(cmd, target); err != nil {
+			return "", err
+		}
+	}
+	LogAction("backup", "export", ResultSuccess, fmt.Sprintf("backup=%s dest=%s", timestamp, location))
+	return location, nil
+}
+
+// ImportBackup fetches an exported archive from src and unpacks it into
+// BackupRoot, where rollback can use it. It returns the backup timestamp.
+func ImportBackup(src, endpoint string) (string, error) {
+	target, err := ParseBackupTarget(src)
+	if err != nil {
+		return "", err
+	}
+
+	tmp, err := os.MkdirTemp("", "vmware-tuner-import")
+	if err != nil {
+		return "", fmt.Errorf("failed to create temporary directory: %w", err)
+	}
+	defer os.RemoveAll(tmp)
+	archive := filepath.Join(tmp, "backup.tar.gz")
+
+	var cmd *exec.Cmd
+	switch target.Scheme {
+	case "scp":
+		args := append(target.scpArgs(), target.scpHost()+":"+target.Path, archive)
+		cmd = exec.Command("scp", args...)
+	case "s3":
+		args := append([]string{"s3", "cp", "--only-show-errors", target.String(), archive}, s3Args(endpoint)...)
+		cmd = exec.Command("aws", args...)
+	default:
+		if err := copyFile(target.Path, archive, 0600); err != nil {
+			return "", err
+		}
+	}
+	if cmd != nil {
+		if err := runTransfer(cmd, target); err != nil {
+			return "", err
+		}
+	}
+
+	out, err := exec.Command("tar", "-tzf", archive).Output()
+	if err != nil {
+		return "", fmt.Errorf("not a backup archive: %w", err)
+	}
+	timestamp, err := backupArchiveRoot(strings.Split(strings.TrimSpace(string(out)), "\n"))
+	if err != nil {
+		return "", err
+	}
+
+	dest := filepath.Join(BackupRoot, timestamp)
+	if FileExists(dest) {
+		return "", fmt.Errorf("backup %s already exists in %s", timestamp, BackupRoot)
+	}
+	if err := os.MkdirAll(BackupRoot, 0700); err != nil {
+		return "", fmt.Errorf("failed to create %s: %w", BackupRoot, err)
+	}
+	if out, err := exec.Command("tar", "-xzf", archive, "-C", BackupRoot).CombinedOutput(); err != nil {
+		return "", fmt.Errorf("failed to extract backup: %v: %s", err, string(out))
+	}
+	if _, err := LoadManifest(dest); err != nil {
+		os.RemoveAll(dest)
+		return "", fmt.Errorf("imported backup is unusable: %w", err)
+	}
+
+	LogAction("backup", "import", ResultSuccess, fmt.Sprintf("backup=%s src=%s", timestamp, target))
+	return timestamp, nil
+}
+
+// runTransfer runs the scp or aws command copying an archive
+func runTransfer(cmd *exec.Cmd, target BackupTarget) error {
+	tool := filepath.Base(cmd.Args[0])
+	if _, err := exec.LookPath(tool); err != nil {
+		return fmt.Errorf("%s is required for %s transfers", tool, target.Scheme)
+	}
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("transfer with %s failed: %v: %s", tool, err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// backupArchiveRoot checks that every archive member lives in a single backup
+// session directory and returns its timestamp
+func backupArchiveRoot(members []string) (string, error) {
+	root := ""
+	for _, m := range members {
+		m = strings.TrimPrefix(m, "./")
+		if m == "" {
+			continue
+		}
+		if strings.HasPrefix(m, "/") || strings.Contains("/"+m+"/", "/../") {
+			return "", fmt.Errorf("unsafe path in backup archive: %s", m)
+		}
+		top := strings.SplitN(m, "/", 2)[0]
+		if root == "" {
+			root = top
+		}
+		if top != root {
+			return "", fmt.Errorf("backup archive holds more than one backup (%s, %s)", root, top)
+		}
+	}
+	if !backupTimestampRe.MatchString(root) {
+		return "", fmt.Errorf("not a vmware-tuner backup archive")
+	}
+	return root, nil
+}
+
+// copyFile copies src to dst with the given mode
+func copyFile(src, dst string, mode os.FileMode) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", src, err)
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, mode)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", dst, err)
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return fmt.Errorf("failed to copy %s: %w", src, err)
+	}
+	return out.Close()
+}
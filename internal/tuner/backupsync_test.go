@@ -0,0 +1,44 @@
+package tuner
+
+import "testing"
+
+func TestParseBackupTarget(t *testing.T) {
+	tests := []struct {
+		raw  string
+		want BackupTarget
+	}{
+		{"scp://backup@nas.example.com:2222/srv/vm", BackupTarget{Scheme: "scp", User: "backup", Host: "nas.example.com", Port: "2222", Path: "/srv/vm"}},
+		{"s3://tuner-backups/prod/web01", BackupTarget{Scheme: "s3", Host: "tuner-backups", Path: "prod/web01"}},
+		{"/mnt/usb", BackupTarget{Scheme: "file", Path: "/mnt/usb"}},
+	}
+	for _, tt := range tests {
+		got, err := ParseBackupTarget(tt.raw)
+		if err != nil || got != tt.want {
+			t.Errorf("ParseBackupTarget(%q) = %+v, %v; want %+v", tt.raw, got, err, tt.want)
+		}
+	}
+
+	for _, raw := range []string{"", "ftp://host/path", "scp://host", "s3:///key"} {
+		if _, err := ParseBackupTarget(raw); err == nil {
+			t.Errorf("ParseBackupTarget(%q) accepted", raw)
+		}
+	}
+}
+
+func TestBackupArchiveRoot(t *testing.T) {
+	root, err := backupArchiveRoot([]string{"20240101-120000/", "20240101-120000/manifest.json", "20240101-120000/fstab"})
+	if err != nil || root != "20240101-120000" {
+		t.Errorf("got %q, %v", root, err)
+	}
+
+	for _, members := range [][]string{
+		{"20240101-120000/manifest.json", "../etc/passwd"},
+		{"20240101-120000/manifest.json", "20240202-120000/manifest.json"},
+		{"/etc/fstab"},
+		{"bundle/Packages"},
+	} {
+		if _, err := backupArchiveRoot(members); err == nil {
+			t.Errorf("archive %v accepted", members)
+		}
+	}
+}
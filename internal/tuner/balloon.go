@@ -0,0 +1,375 @@
+package tuner
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// Thresholds of the swap thrash caused by ballooning: the balloon is
+// inflated and, at the same time, the guest swaps pages back in and its
+// tasks stall waiting for memory
+const (
+	thrashSwapInPerSec = 100  // pages swapped in per second (400 KiB/s)
+	thrashPSISome      = 10.0 // % of time some task waited for memory (avg10)
+)
+
+// balloonSampleWindow is how long the audit watches the swap activity
+const balloonSampleWindow = time.Second
+
+// vmmemctlStats is the vmw_balloon driver status (debugfs, root only), read
+// when VMware Tools are missing: the driver is part of the kernel
+const vmmemctlStats = "/sys/kernel/debug/vmmemctl"
+
+// BalloonSample is the memory state of the VM at one point in time
+type BalloonSample struct {
+	Time          time.Time
+	BalloonMB     int64   // inflated balloon (vmmemctl), -1 unknown
+	HostSwapMB    int64   // guest memory swapped out by the host, -1 unknown
+	LimitMB       int64   // memory limit of the VM, -1 unknown
+	ReservationMB int64   // memory reservation of the VM, -1 unknown
+	MemoryMB      int64   // memory of the guest (MemTotal)
+	SwapUsedMB    int64   // swap in use inside the guest
+	SwapIn        int64   // pages swapped in since boot (pswpin)
+	SwapOut       int64   // pages swapped out since boot (pswpout)
+	PSISome       float64 // % of the last 10s some task stalled on memory, -1 unknown
+	PSIFull       float64 // % of the last 10s all tasks stalled on memory, -1 unknown
+}
+
+// toolboxStat reads a `vmware-toolbox-cmd stat` counter in MB ("512 MB").
+// Variable so tests can stub it.
+var toolboxStat = func(item string) (int64, bool) {
+	out, err := exec.Command("vmware-toolbox-cmd", "stat", item).Output()
+	if err != nil {
+		return 0, false
+	}
+	fields := strings.Fields(string(out))
+	if len(fields) == 0 {
+		return 0, false
+	}
+	value, err := strconv.ParseInt(fields[0], 10, 64)
+	return value, err == nil
+}
+
+// vmmemctlBalloonMB reads the inflated balloon from the vmw_balloon driver
+// ("current: 1234 pages"), -1 when the statistics are not readable
+func vmmemctlBalloonMB(fsRoot string) int64 {
+	data, err := os.ReadFile(filepath.Join(fsRoot, vmmemctlStats))
+	if err != nil {
+		return -1
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) >= 2 && fields[0] == "current:" {
+			if pages, err := strconv.ParseInt(fields[1], 10, 64); err == nil {
+				return pages * int64(os.Getpagesize()) >> 20
+			}
+		}
+	}
+	return -1
+}
+
+// TakeBalloonSample reads the balloon, limit and reservation from VMware
+// Tools (the vmw_balloon statistics without them) and the memory, swap and
+// pressure counters of the kernel. fsRoot allows running against a fixture
+// tree.
+func TakeBalloonSample(fsRoot string) BalloonSample {
+	s := BalloonSample{Time: time.Now(), BalloonMB: -1, HostSwapMB: -1, LimitMB: -1, ReservationMB: -1, PSISome: -1, PSIFull: -1}
+	for item, value := range map[string]*int64{"balloon": &s.BalloonMB, "swap": &s.HostSwapMB, "memlimit": &s.LimitMB, "memres": &s.ReservationMB} {
+		if mb, ok := toolboxStat(item); ok {
+			*value = mb
+		}
+	}
+	if s.BalloonMB < 0 {
+		s.BalloonMB = vmmemctlBalloonMB(fsRoot)
+	}
+	meminfo := SysFS{Root: fsRoot}.MemInfoMB()
+	s.MemoryMB = meminfo["MemTotal"]
+	s.SwapUsedMB = meminfo["SwapTotal"] - meminfo["SwapFree"]
+
+	if f, err := os.Open(filepath.Join(fsRoot, "/proc/vmstat")); err == nil {
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			fields := strings.Fields(scanner.Text())
+			if len(fields) != 2 {
+				continue
+			}
+			switch fields[0] {
+			case "pswpin":
+				s.SwapIn, _ = strconv.ParseInt(fields[1], 10, 64)
+			case "pswpout":
+				s.SwapOut, _ = strconv.ParseInt(fields[1], 10, 64)
+			}
+		}
+		f.Close()
+	}
+
+	// PSI needs kernel 4.20 and psi=1 on some distributions
+	if data, err := os.ReadFile(filepath.Join(fsRoot, "/proc/pressure/memory")); err == nil {
+		for _, line := range strings.Split(string(data), "\n") {
+			fields := strings.Fields(line)
+			if len(fields) < 2 || !strings.HasPrefix(fields[1], "avg10=") {
+				continue
+			}
+			avg, err := strconv.ParseFloat(strings.TrimPrefix(fields[1], "avg10="), 64)
+			if err != nil {
+				continue
+			}
+			switch fields[0] {
+			case "some":
+				s.PSISome = avg
+			case "full":
+				s.PSIFull = avg
+			}
+		}
+	}
+	return s
+}
+
+// BalloonDiagnosis correlates two samples
+type BalloonDiagnosis struct {
+	BalloonMB    int64
+	HostSwapMB   int64
+	SwapInRate   float64 // pages per second
+	SwapOutRate  float64
+	PSISome      float64
+	Limited      bool     // memory limit below the memory of the VM
+	Thrashing    bool     // the host reclaims memory and the guest pays for it
+	Reclaiming   bool     // balloon inflated, host swapping or limit, no thrash yet
+	GuestActions []string // what to change in the guest
+	HostActions  []string // what to ask the vSphere admins
+}
+
+// DiagnoseBalloon tells ballooning-induced swap thrash from ordinary swap
+// use: the swap-in rate and the memory stalls only point at the host when
+// the balloon is inflated (or the host swaps the VM) at the same time
+func DiagnoseBalloon(prev, cur BalloonSample) BalloonDiagnosis {
+	d := BalloonDiagnosis{BalloonMB: cur.BalloonMB, HostSwapMB: cur.HostSwapMB, PSISome: cur.PSISome}
+	// An unlimited VM reports a huge limit (4294967295 MB)
+	d.Limited = cur.LimitMB > 0 && cur.MemoryMB > 0 && cur.LimitMB < cur.MemoryMB
+	if secs := cur.Time.Sub(prev.Time).Seconds(); secs > 0 {
+		d.SwapInRate = float64(cur.SwapIn-prev.SwapIn) / secs
+		d.SwapOutRate = float64(cur.SwapOut-prev.SwapOut) / secs
+	}
+
+	reclaimed := cur.BalloonMB > 0 || cur.HostSwapMB > 0 || d.Limited
+	if !reclaimed {
+		return d
+	}
+	// Without PSI the swap-in rate alone decides
+	stalled := cur.PSISome < 0 || cur.PSISome >= thrashPSISome
+	d.Thrashing = d.SwapInRate >= thrashSwapInPerSec && stalled
+	d.Reclaiming = !d.Thrashing
+
+	reservation := "ask for a memory reservation for this VM (Reserve all guest memory): a reserved VM is never ballooned"
+	if cur.ReservationMB >= 0 && cur.MemoryMB > 0 && cur.ReservationMB < cur.MemoryMB {
+		reservation += fmt.Sprintf(" (%s of %s reserved today)", FormatMiB(cur.ReservationMB), FormatMiB(cur.MemoryMB))
+	}
+	d.GuestActions = []string{
+		"lower vm.swappiness to 1 (tuning.sysctl, or the database profile): the guest drops cache before swapping out what the balloon left it",
+		reservation,
+	}
+	limit := "remove a memory limit set below the configured memory: the host balloons the VM above the limit"
+	if d.Limited {
+		limit = fmt.Sprintf("remove the memory limit of %s (the VM has %s): the host balloons and swaps the VM above it whatever its own load", FormatMiB(cur.LimitMB), FormatMiB(cur.MemoryMB))
+	}
+	d.HostActions = []string{
+		"check the host memory overcommit: esxtop memory view (MCTLSZ, SWCUR), Ballooned and Swapped memory of the VM in vCenter",
+		limit,
+		"move the VM (DRS, vMotion) to a host with free memory, or add memory to the cluster",
+	}
+	if cur.HostSwapMB > 0 {
+		d.HostActions = append(d.HostActions, fmt.Sprintf("the host swaps %s of this VM to disk: reclaim by the host is past ballooning", FormatMiB(cur.HostSwapMB)))
+	}
+	return d
+}
+
+// Summary describes the diagnosis in one line
+func (d BalloonDiagnosis) Summary() string {
+	var parts []string
+	if d.BalloonMB > 0 {
+		parts = append(parts, "balloon "+FormatMiB(d.BalloonMB))
+	}
+	if d.HostSwapMB > 0 {
+		parts = append(parts, "host swap "+FormatMiB(d.HostSwapMB))
+	}
+	if d.Limited {
+		parts = append(parts, "memory limit below the VM memory")
+	}
+	parts = append(parts, fmt.Sprintf("%.0f pages/s swapped in", d.SwapInRate))
+	if d.PSISome >= 0 {
+		parts = append(parts, fmt.Sprintf("memory stall %.1f%% (PSI some avg10)", d.PSISome))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// BalloonTuner checks whether the host reclaims memory from this VM
+type BalloonTuner struct {
+	FSRoot   string // "" for /
+	Interval time.Duration
+}
+
+// NewBalloonTuner creates a new balloon checker
+func NewBalloonTuner() *BalloonTuner {
+	return &BalloonTuner{Interval: 5 * time.Second}
+}
+
+func init() {
+	Register(Module{
+		Name:        "balloon",
+		Label:       "Memory Balloon & Swap Thrash",
+		Description: "Tell host memory reclaim (ballooning) from ordinary swap use",
+		Category:    CategoryDiagnostics,
+		Menu:        36,
+		ReadOnly:    true,
+		Run: func(ctx *ModuleContext) error {
+			return NewBalloonTuner().Run()
+		},
+	})
+}
+
+// Run samples the swap activity over the interval and prints the diagnosis
+func (bt *BalloonTuner) Run() error {
+	PrintStep("Memory Balloon & Swap Thrash")
+
+	prev := TakeBalloonSample(bt.FSRoot)
+	if prev.BalloonMB < 0 {
+		PrintWarning("Balloon size unknown: vmware-toolbox-cmd not available (install open-vm-tools), %s not readable", vmmemctlStats)
+	}
+	if prev.LimitMB >= 0 && prev.ReservationMB >= 0 {
+		limit := "none"
+		if prev.LimitMB < prev.MemoryMB {
+			limit = FormatMiB(prev.LimitMB)
+		}
+		PrintInfo("VM memory %s, reservation %s, limit %s", FormatMiB(prev.MemoryMB), FormatMiB(prev.ReservationMB), limit)
+	}
+	if prev.SwapUsedMB > 0 {
+		PrintInfo("Swap in use in the guest: %s", FormatMiB(prev.SwapUsedMB))
+	}
+	PrintInfo("Sampling swap activity for %s...", FormatDuration(bt.Interval))
+	time.Sleep(bt.Interval)
+	d := DiagnoseBalloon(prev, TakeBalloonSample(bt.FSRoot))
+	printBalloonDiagnosis(d)
+	return nil
+}
+
+// printBalloonDiagnosis prints the finding with the actions on both sides
+func printBalloonDiagnosis(d BalloonDiagnosis) {
+	switch {
+	case d.Thrashing:
+		PrintError("Host is reclaiming memory from this VM and the guest is swap thrashing: %s", d.Summary())
+	case d.Reclaiming:
+		PrintWarning("Host is reclaiming memory from this VM: %s", d.Summary())
+	default:
+		if d.SwapInRate >= thrashSwapInPerSec {
+			PrintWarning("Heavy swap-in without ballooning: %s", d.Summary())
+			PrintInfo("The guest itself is short of memory: add memory to the VM or look at the largest processes")
+		} else {
+			PrintSuccess("No memory reclaim by the host: %s", d.Summary())
+		}
+		return
+	}
+	PrintInfo("In the guest:")
+	for _, a := range d.GuestActions {
+		fmt.Printf("    - %s\n", a)
+	}
+	PrintInfo("On the vSphere side:")
+	for _, a := range d.HostActions {
+		fmt.Printf("    - %s\n", a)
+	}
+}
+
+// Watch prints the balloon, swap rates and memory stalls at each interval,
+// flagging the intervals where ballooning makes the guest thrash
+func (bt *BalloonTuner) Watch() error {
+	PrintStep("Watching memory balloon and swap activity (Ctrl-C to stop)")
+
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(stop)
+
+	prev := TakeBalloonSample(bt.FSRoot)
+	ticker := time.NewTicker(bt.Interval)
+	defer ticker.Stop()
+
+	var thrash *BalloonDiagnosis
+	fmt.Printf("  %-8s %12s %12s %10s %10s %8s\n", "Time", "Balloon", "Host swap", "In/s", "Out/s", "PSI")
+	for {
+		select {
+		case <-stop:
+			fmt.Println()
+			if thrash != nil {
+				printBalloonDiagnosis(*thrash)
+			}
+			return nil
+		case <-ticker.C:
+		}
+
+		cur := TakeBalloonSample(bt.FSRoot)
+		d := DiagnoseBalloon(prev, cur)
+		prev = cur
+		fmt.Printf("  %-8s %12s %12s %10.0f %10.0f %8s", cur.Time.Format("15:04:05"),
+			formatReclaimMB(cur.BalloonMB), formatReclaimMB(cur.HostSwapMB), d.SwapInRate, d.SwapOutRate, formatPSI(cur.PSISome))
+		if d.Thrashing {
+			thrash = &d
+			fmt.Print("  <- balloon thrash")
+		}
+		fmt.Println()
+	}
+}
+
+// auditBalloon scores the memory pressure from the host: full points when
+// nothing is reclaimed, half when the balloon is inflated, the host swaps
+// the VM or a limit caps it, none when the guest thrashes
+func auditBalloon(fsRoot string) AuditItem {
+	item := AuditItem{Name: "memory-reclaim", Max: 10}
+	prev := TakeBalloonSample(fsRoot)
+	if prev.BalloonMB < 0 {
+		item.Status, item.Points, item.Message = AuditWarn, 5, "Balloon size unknown: VMware Tools missing (+5/10)"
+		return item
+	}
+	time.Sleep(balloonSampleWindow)
+	return balloonAuditItem(item, DiagnoseBalloon(prev, TakeBalloonSample(fsRoot)))
+}
+
+// balloonAuditItem scores a diagnosis
+func balloonAuditItem(item AuditItem, d BalloonDiagnosis) AuditItem {
+	switch {
+	case d.Thrashing:
+		item.Status = AuditFail
+		item.Message = "Host is reclaiming memory from this VM, the guest is swap thrashing: " + d.Summary() + " (0/10)"
+	case d.Reclaiming:
+		item.Status, item.Points = AuditWarn, 5
+		item.Message = "Host is reclaiming memory from this VM: " + d.Summary() + " (+5/10)"
+	default:
+		item.Status, item.Points, item.Message = AuditOK, 10, "No memory reclaimed by the host (+10)"
+		return item
+	}
+	for _, a := range append(d.GuestActions, d.HostActions...) {
+		item.Details = append(item.Details, "- "+a)
+	}
+	return item
+}
+
+// formatReclaimMB prints a Tools counter, "?" when unknown
+func formatReclaimMB(mb int64) string {
+	if mb < 0 {
+		return "?"
+	}
+	return FormatMiB(mb)
+}
+
+// formatPSI prints a pressure average, "-" without PSI
+func formatPSI(avg float64) string {
+	if avg < 0 {
+		return "-"
+	}
+	return fmt.Sprintf("%.1f%%", avg)
+}
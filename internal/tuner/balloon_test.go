@@ -0,0 +1,136 @@
+package tuner
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestTakeBalloonSample(t *testing.T) {
+	saved := toolboxStat
+	defer func() { toolboxStat = saved }()
+	toolboxStat = func(item string) (int64, bool) {
+		if item == "balloon" {
+			return 2048, true
+		}
+		return 0, true
+	}
+
+	root := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(root, "proc/pressure"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{
+		"proc/vmstat":          "nr_free_pages 1000\npswpin 1200\npswpout 3400\n",
+		"proc/pressure/memory": "some avg10=23.50 avg60=10.00 avg300=2.00 total=123\nfull avg10=8.25 avg60=1.00 avg300=0.10 total=45\n",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(root, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	s := TakeBalloonSample(root)
+	if s.BalloonMB != 2048 || s.HostSwapMB != 0 || s.SwapIn != 1200 || s.SwapOut != 3400 || s.PSISome != 23.5 || s.PSIFull != 8.25 {
+		t.Errorf("sample = %+v", s)
+	}
+
+	// Old kernel without PSI, no VMware Tools
+	toolboxStat = func(string) (int64, bool) { return 0, false }
+	os.Remove(filepath.Join(root, "proc/pressure/memory"))
+	if s := TakeBalloonSample(root); s.BalloonMB != -1 || s.PSISome != -1 {
+		t.Errorf("sample without tools and PSI = %+v, want unknown balloon and PSI", s)
+	}
+}
+
+func TestDiagnoseBalloon(t *testing.T) {
+	start := time.Now()
+	sample := func(balloon, hostSwap, swapIn int64, psi float64, at time.Duration) BalloonSample {
+		return BalloonSample{Time: start.Add(at), BalloonMB: balloon, HostSwapMB: hostSwap, SwapIn: swapIn, PSISome: psi, PSIFull: -1}
+	}
+
+	tests := []struct {
+		name       string
+		prev, cur  BalloonSample
+		thrashing  bool
+		reclaiming bool
+	}{
+		{"no balloon, heavy swap", sample(0, 0, 0, 0, 0), sample(0, 0, 5000, 40, 5*time.Second), false, false},
+		{"balloon, heavy swap, stalls", sample(1024, 0, 0, 0, 0), sample(1024, 0, 5000, 25, 5*time.Second), true, false},
+		{"balloon, heavy swap, no stalls", sample(1024, 0, 0, 0, 0), sample(1024, 0, 5000, 2, 5*time.Second), false, true},
+		{"balloon, heavy swap, no PSI", sample(1024, 0, 0, -1, 0), sample(1024, 0, 5000, -1, 5*time.Second), true, false},
+		{"balloon, idle swap", sample(1024, 0, 0, 0, 0), sample(1024, 0, 10, 30, 5*time.Second), false, true},
+		{"host swap only", sample(0, 512, 0, 0, 0), sample(0, 512, 5000, 30, 5*time.Second), true, false},
+	}
+	for _, tt := range tests {
+		d := DiagnoseBalloon(tt.prev, tt.cur)
+		if d.Thrashing != tt.thrashing || d.Reclaiming != tt.reclaiming {
+			t.Errorf("%s: thrashing %v reclaiming %v, want %v %v (%s)", tt.name, d.Thrashing, d.Reclaiming, tt.thrashing, tt.reclaiming, d.Summary())
+		}
+		if (tt.thrashing || tt.reclaiming) && (len(d.GuestActions) == 0 || len(d.HostActions) == 0) {
+			t.Errorf("%s: no guest or host actions", tt.name)
+		}
+	}
+}
+
+func TestBalloonLimitAndDebugfs(t *testing.T) {
+	saved := toolboxStat
+	defer func() { toolboxStat = saved }()
+	toolboxStat = func(string) (int64, bool) { return 0, false }
+
+	root := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(root, "sys/kernel/debug"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(root, "proc"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{
+		"sys/kernel/debug/vmmemctl": "balloon capabilities:   0x1e\nis resetting:           n\ntarget:                 262144 pages\ncurrent:                262144 pages\n",
+		"proc/meminfo":              "MemTotal:        8388608 kB\nMemFree:          524288 kB\nSwapTotal:       2097152 kB\nSwapFree:        1048576 kB\n",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(root, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Without VMware Tools the vmw_balloon driver statistics give the balloon
+	s := TakeBalloonSample(root)
+	if want := int64(262144*os.Getpagesize()) >> 20; s.BalloonMB != want {
+		t.Errorf("balloon = %d MB, want %d", s.BalloonMB, want)
+	}
+	if s.MemoryMB != 8192 || s.SwapUsedMB != 1024 {
+		t.Errorf("memory %d MB, swap used %d MB, want 8192 and 1024", s.MemoryMB, s.SwapUsedMB)
+	}
+
+	// A limit below the VM memory is host pressure even with no balloon yet
+	limited := BalloonSample{Time: time.Now(), BalloonMB: 0, HostSwapMB: 0, LimitMB: 4096, ReservationMB: 0, MemoryMB: 8192, PSISome: -1}
+	d := DiagnoseBalloon(limited, limited)
+	if !d.Limited || !d.Reclaiming {
+		t.Errorf("limit 4096 of 8192 MB: %+v, want limited and reclaiming", d)
+	}
+	unlimited := limited
+	unlimited.LimitMB = 4294967295
+	if d := DiagnoseBalloon(unlimited, unlimited); d.Limited || d.Reclaiming {
+		t.Errorf("unlimited VM reported as limited: %+v", d)
+	}
+}
+
+func TestBalloonAuditItem(t *testing.T) {
+	for _, tt := range []struct {
+		d      BalloonDiagnosis
+		points int
+		status string
+	}{
+		{BalloonDiagnosis{PSISome: -1}, 10, AuditOK},
+		{BalloonDiagnosis{BalloonMB: 512, Reclaiming: true, PSISome: -1}, 5, AuditWarn},
+		{BalloonDiagnosis{BalloonMB: 512, Thrashing: true, PSISome: 30}, 0, AuditFail},
+	} {
+		item := balloonAuditItem(AuditItem{Name: "memory-reclaim", Max: 10}, tt.d)
+		if item.Points != tt.points || item.Status != tt.status {
+			t.Errorf("%+v: %d points %s, want %d %s", tt.d, item.Points, item.Status, tt.points, tt.status)
+		}
+	}
+}
@@ -18,30 +18,35 @@ func NewBenchmarkTuner() *BenchmarkTuner {
 	return &BenchmarkTuner{}
 }
 
+func init() {
+	Register(Module{
+		Name:        "benchmark",
+		Label:       "Network Benchmark",
+		Description: "Measure the gateway latency and the download speed",
+		Category:    CategoryDiagnostics,
+		Menu:        10,
+		ReadOnly:    true,
+		Run: func(ctx *ModuleContext) error {
+			return NewBenchmarkTuner().Run(ctx.HasInternet)
+		},
+	})
+}
+
 // Run performs the benchmark
 func (bt *BenchmarkTuner) Run(hasInternet bool) error {
 	PrintStep("Network Benchmark")
 
 	// 1. Latency Test (Ping Gateway)
 	PrintInfo("Testing latency...")
-	gateway, err := getGateway()
-	if err != nil {
+	gateway, rtt, err := MeasureGatewayLatency()
+	if gateway == "" {
 		PrintWarning("Could not detect gateway: %v", err)
 	} else {
 		PrintInfo("Pinging gateway (%s)...", gateway)
-		// ping -c 4 -i 0.2 <gateway>
-		cmd := exec.Command("ping", "-c", "4", "-i", "0.2", gateway)
-		output, err := cmd.CombinedOutput()
 		if err != nil {
 			PrintWarning("Ping failed: %v", err)
-		} else {
-			// Extract avg
-			lines := strings.Split(string(output), "\n")
-			for _, line := range lines {
-				if strings.Contains(line, "rtt") || strings.Contains(line, "avg") {
-					fmt.Printf("  -> %s\n", strings.TrimSpace(line))
-				}
-			}
+		} else if rtt != "" {
+			fmt.Printf("  -> %s\n", rtt)
 		}
 	}
 
@@ -52,9 +57,54 @@ func (bt *BenchmarkTuner) Run(hasInternet bool) error {
 		return nil
 	}
 
+	if err := CheckPreconditions("benchmark"); err != nil {
+		PrintWarning("Skipping download speed test: %v", err)
+		return nil
+	}
+
 	PrintInfo("Testing download speed...")
 	PrintInfo("Downloading 100MB test file (will be deleted immediately)...")
 
+	written, elapsed, err := MeasureDownload()
+	if err != nil {
+		return err
+	}
+	PrintSuccess("Temporary file deleted")
+
+	speed := float64(written) / elapsed.Seconds()
+	fmt.Printf("  -> Downloaded %s in %s\n", FormatBytes(written), FormatDuration(elapsed))
+	PrintSuccess("Speed: %s (%s)", FormatBitRate(speed), FormatByteRate(speed))
+
+	return nil
+}
+
+// MeasureGatewayLatency pings the default gateway and returns it with the rtt summary line
+func MeasureGatewayLatency() (string, string, error) {
+	gateway, err := getGateway()
+	if err != nil {
+		return "", "", err
+	}
+
+	// ping -c 4 -i 0.2 <gateway>
+	cmd := exec.Command("ping", "-c", "4", "-i", "0.2", gateway)
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return gateway, "", err
+	}
+
+	// Extract avg
+	lines := strings.Split(string(output), "\n")
+	for _, line := range lines {
+		if strings.Contains(line, "rtt") || strings.Contains(line, "avg") {
+			return gateway, strings.TrimSpace(line), nil
+		}
+	}
+	return gateway, "", nil
+}
+
+// MeasureDownload downloads the 100MB test file and returns its size in bytes
+// and the time taken
+func MeasureDownload() (int64, time.Duration, error) {
 	url := "http://speedtest.tele2.net/100MB.zip" // Reliable public speedtest file
 	tmpFile := "/tmp/vmware-tuner-speedtest.tmp"
 
@@ -63,42 +113,29 @@ func (bt *BenchmarkTuner) Run(hasInternet bool) error {
 
 	resp, err := http.Get(url)
 	if err != nil {
-		return fmt.Errorf("download failed: %v", err)
+		return 0, 0, fmt.Errorf("download failed: %v", err)
 	}
 	defer resp.Body.Close()
 
 	out, err := os.Create(tmpFile)
 	if err != nil {
-		return fmt.Errorf("failed to create temp file: %v", err)
+		return 0, 0, fmt.Errorf("failed to create temp file: %v", err)
 	}
 
 	// CRITICAL: Ensure file is deleted
 	defer func() {
 		out.Close()
 		os.Remove(tmpFile)
-		PrintSuccess("Temporary file deleted")
 	}()
 
 	// Copy content
 	written, err := io.Copy(out, resp.Body)
 	if err != nil {
-		return fmt.Errorf("download interrupted: %v", err)
+		return 0, 0, fmt.Errorf("download interrupted: %v", err)
 	}
 
 	// STOP TIMER
-	elapsed := time.Since(start)
-
-	// Calculate speed
-	// written is bytes
-	// elapsed is duration
-	mb := float64(written) / 1024 / 1024
-	seconds := elapsed.Seconds()
-	speed := mb / seconds // MB/s
-
-	fmt.Printf("  -> Downloaded %.2f MB in %.2f seconds\n", mb, seconds)
-	PrintSuccess("Speed: %.2f MB/s (%.2f Mbps)", speed, speed*8)
-
-	return nil
+	return written, time.Since(start), nil
 }
 
 func getGateway() (string, error) {
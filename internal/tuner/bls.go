@@ -0,0 +1,175 @@
+package tuner
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// blsEntriesDir holds the BootLoaderSpec entries GRUB boots on RHEL 8+ and Fedora
+const blsEntriesDir = "/boot/loader/entries"
+
+// blsEnabledRe matches GRUB_ENABLE_BLSCFG=true in /etc/default/grub
+var blsEnabledRe = regexp.MustCompile(`(?m)^\s*GRUB_ENABLE_BLSCFG=["']?true`)
+
+// UsesBLS reports whether GRUB boots BootLoaderSpec entries. Their kernel
+// options are not always regenerated from /etc/default/grub by grub2-mkconfig
+// (RHEL 9 before 9.3), so they are updated with grubby. fsRoot is "" for /.
+func UsesBLS(fsRoot string) bool {
+	entries, _ := filepath.Glob(filepath.Join(fsRoot, blsEntriesDir, "*.conf"))
+	if len(entries) == 0 {
+		return false
+	}
+	data, err := os.ReadFile(filepath.Join(fsRoot, "/etc/default/grub"))
+	return err == nil && blsEnabledRe.Match(data)
+}
+
+// blsMissing returns the params absent from at least one entry. Entries using
+// $kernelopts (RHEL 8) take them from grubenv, which grub2-mkconfig updates.
+func blsMissing(dir string, params []string) ([]string, error) {
+	entries, err := filepath.Glob(filepath.Join(dir, "*.conf"))
+	if err != nil {
+		return nil, err
+	}
+
+	missing := make(map[string]bool)
+	for _, entry := range entries {
+		data, err := os.ReadFile(entry)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", entry, err)
+		}
+		present := make(map[string]bool)
+		usesGrubenv := false
+		for _, line := range strings.Split(string(data), "\n") {
+			fields := strings.Fields(line)
+			if len(fields) == 0 || fields[0] != "options" {
+				continue
+			}
+			for _, option := range fields[1:] {
+				present[option] = true
+				usesGrubenv = usesGrubenv || strings.HasPrefix(option, "$kernelopts")
+			}
+		}
+		if usesGrubenv {
+			continue
+		}
+		for _, param := range params {
+			if !present[param] {
+				missing[param] = true
+			}
+		}
+	}
+
+	var result []string
+	for _, param := range params {
+		if missing[param] {
+			result = append(result, param)
+		}
+	}
+	return result, nil
+}
+
+// removedParams returns the params of before whose key is gone from after
+func removedParams(before, after []string) []string {
+	kept := make(map[string]bool)
+	for _, param := range after {
+		kept[paramKey(param)] = true
+	}
+	var removed []string
+	for _, param := range before {
+		if !kept[paramKey(param)] {
+			removed = append(removed, param)
+		}
+	}
+	return removed
+}
+
+// syncBLS brings the BootLoaderSpec entries of every installed kernel in line
+// with the new cmdline. grubby replaces the value of a key already present.
+// The change is recorded in the manifest of the session: rollback runs the
+// inverse grubby call.
+func (gt *GrubTuner) syncBLS(backup *BackupManager, before, after []string) error {
+	if gt.BLSDir == "" {
+		return nil
+	}
+
+	add, err := blsMissing(gt.BLSDir, after)
+	if err != nil {
+		return err
+	}
+	remove := removedParams(before, after)
+	if len(add) == 0 && len(remove) == 0 {
+		return nil
+	}
+
+	args := []string{"--update-kernel=ALL"}
+	if len(add) > 0 {
+		args = append(args, "--args="+strings.Join(add, " "))
+	}
+	if len(remove) > 0 {
+		args = append(args, "--remove-args="+strings.Join(remove, " "))
+	}
+
+	if gt.DryRun {
+		PrintInfo("Would run: grubby %s", strings.Join(args, " "))
+		return nil
+	}
+	if _, err := exec.LookPath("grubby"); err != nil {
+		return fmt.Errorf("BootLoaderSpec entries in %s need grubby, which is not installed", gt.BLSDir)
+	}
+
+	ExplainCommand("write the parameters into the BootLoaderSpec entry of every installed kernel", "grubby", args...)
+	if out, err := exec.Command("grubby", args...).CombinedOutput(); err != nil {
+		return fmt.Errorf("grubby failed: %v: %s", err, string(out))
+	}
+	PrintSuccess("Updated the BootLoaderSpec entries (grubby)")
+
+	// grubby overwrote the value of the keys already present: the old value
+	// comes back with the removed parameters
+	if backup != nil {
+		change := BootArgsChange{Tool: "grubby", Added: add, Removed: append(remove, replacedParams(before, add)...)}
+		if err := backup.RecordBootArgs(change); err != nil {
+			PrintWarning("Failed to record the grubby change (rollback will not revert it): %v", err)
+		}
+	}
+	return nil
+}
+
+// replacedParams returns the params of before whose key added sets to
+// another value
+func replacedParams(before, added []string) []string {
+	values := make(map[string]string)
+	for _, param := range added {
+		values[paramKey(param)] = param
+	}
+	var replaced []string
+	for _, param := range before {
+		if value, ok := values[paramKey(param)]; ok && value != param {
+			replaced = append(replaced, param)
+		}
+	}
+	return replaced
+}
+
+// inverseGrubbyArgs returns the grubby calls undoing recorded changes, the
+// last change first: its added parameters are removed, then the removed ones
+// put back
+func inverseGrubbyArgs(changes []BootArgsChange) [][]string {
+	var calls [][]string
+	for i := len(changes) - 1; i >= 0; i-- {
+		c := changes[i]
+		if c.Tool != "grubby" {
+			continue
+		}
+		if len(c.Added) > 0 {
+			calls = append(calls, []string{"--update-kernel=ALL", "--remove-args=" + strings.Join(c.Added, " ")})
+		}
+		if len(c.Removed) > 0 {
+			calls = append(calls, []string{"--update-kernel=ALL", "--args=" + strings.Join(c.Removed, " ")})
+		}
+	}
+	return calls
+}
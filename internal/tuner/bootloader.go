@@ -0,0 +1,296 @@
+package tuner
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// Bootloaders whose kernel command line the GRUB tuner manages
+const (
+	BootloaderNone        = ""
+	BootloaderGRUB        = "grub"
+	BootloaderSystemdBoot = "systemd-boot"
+	BootloaderKernelstub  = "kernelstub" // Pop!_OS: systemd-boot entries generated by kernelstub
+)
+
+// systemdBootESPs are the mount points where systemd-boot keeps its loader
+var systemdBootESPs = []string{"/boot/efi", "/efi", "/boot"}
+
+// kernelstubConfig is the configuration kernelstub regenerates the entries from
+const kernelstubConfig = "/etc/kernelstub/configuration"
+
+// kernelCmdlinePath is used by kernel-install for the entries of new kernels
+const kernelCmdlinePath = "/etc/kernel/cmdline"
+
+// ErrVerifySkipped marks verify checks that do not apply to this system
+var ErrVerifySkipped = errors.New("not applicable")
+
+// DetectBootloader returns the bootloader holding the kernel command line and,
+// for systemd-boot, its entries directory. fsRoot is "" for /.
+func DetectBootloader(fsRoot, grubPath string) (string, string) {
+	if FileExists(filepath.Join(fsRoot, kernelstubConfig)) {
+		return BootloaderKernelstub, ""
+	}
+	if FileExists(filepath.Join(fsRoot, grubPath)) {
+		return BootloaderGRUB, ""
+	}
+	for _, esp := range systemdBootESPs {
+		loader := filepath.Join(fsRoot, esp, "loader")
+		if FileExists(filepath.Join(loader, "loader.conf")) && FileExists(filepath.Join(loader, "entries")) {
+			return BootloaderSystemdBoot, filepath.Join(loader, "entries")
+		}
+	}
+	return BootloaderNone, ""
+}
+
+// entryOptions returns the kernel options of a loader entry or /etc/kernel/cmdline
+func entryOptions(content string) []string {
+	var options []string
+	for _, line := range strings.Split(content, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) > 0 && fields[0] == "options" {
+			options = append(options, fields[1:]...)
+		}
+	}
+	return options
+}
+
+// setEntryOptions replaces the options lines of a loader entry with one line
+func setEntryOptions(content string, options []string) string {
+	var lines []string
+	written := false
+	for _, line := range strings.Split(strings.TrimRight(content, "\n"), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) > 0 && fields[0] == "options" {
+			if !written {
+				lines = append(lines, "options "+strings.Join(options, " "))
+				written = true
+			}
+			continue
+		}
+		lines = append(lines, line)
+	}
+	if !written {
+		lines = append(lines, "options "+strings.Join(options, " "))
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
+
+// applyLoaderEntries merges params into every systemd-boot entry and into
+// /etc/kernel/cmdline, which kernel-install uses for the next kernels
+func (gt *GrubTuner) applyLoaderEntries(backup *BackupManager, params []string) error {
+	entries, err := filepath.Glob(filepath.Join(gt.LoaderEntries, "*.conf"))
+	if err != nil || len(entries) == 0 {
+		return fmt.Errorf("no systemd-boot entries in %s", gt.LoaderEntries)
+	}
+	PrintInfo("systemd-boot detected: updating the loader entries in %s", gt.LoaderEntries)
+
+	type update struct {
+		path    string
+		content string
+	}
+	var updates []update
+	var paths, added []string
+	seen := make(map[string]bool)
+	for _, path := range append(entries, kernelCmdlinePath) {
+		data, err := os.ReadFile(path)
+		if os.IsNotExist(err) && path == kernelCmdlinePath {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", path, err)
+		}
+
+		var current []string
+		if path == kernelCmdlinePath {
+			current = strings.Fields(string(data))
+		} else {
+			current = entryOptions(string(data))
+		}
+		merged := gt.mergeParams(current, params)
+		if strings.Join(merged, " ") == strings.Join(current, " ") {
+			continue
+		}
+
+		content := setEntryOptions(string(data), merged)
+		if path == kernelCmdlinePath {
+			content = strings.Join(merged, " ") + "\n"
+		}
+		updates = append(updates, update{path, content})
+		paths = append(paths, path)
+		for _, param := range addedParams(current, merged) {
+			if !seen[param] {
+				seen[param] = true
+				added = append(added, param)
+			}
+		}
+	}
+
+	if len(updates) == 0 {
+		PrintSuccess("Boot parameters already optimized")
+		return nil
+	}
+	if gt.DryRun {
+		for _, u := range updates {
+			PrintInfo("Would update: %s", u.path)
+		}
+		return nil
+	}
+
+	for _, path := range paths {
+		if err := backup.BackupFile(path); err != nil {
+			return fmt.Errorf("failed to backup %s: %w", path, err)
+		}
+	}
+	if err := backup.AddRecoverySteps(backup.loaderRecoverySteps(paths, added)); err != nil {
+		PrintWarning("%v", err)
+	}
+	for _, u := range updates {
+		ExplainEdit("add the VMware boot parameters to the kernel options (used from the next boot)", u.path)
+		if err := os.WriteFile(u.path, []byte(u.content), 0644); err != nil {
+			return fmt.Errorf("failed to write %s: %w", u.path, err)
+		}
+		PrintSuccess("Updated %s", u.path)
+	}
+	PrintWarning("REBOOT REQUIRED for boot parameter changes to take effect")
+	return nil
+}
+
+// kernelstubOptions reads the user kernel options of the kernelstub configuration
+func kernelstubOptions(path string) ([]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	var config struct {
+		User struct {
+			KernelOptions []string `json:"kernel_options"`
+		} `json:"user"`
+	}
+	if err := json.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", path, err)
+	}
+	return config.User.KernelOptions, nil
+}
+
+// applyKernelstub sets params with kernelstub, which rewrites the entries
+// itself (editing them would be undone on the next kernel update)
+func (gt *GrubTuner) applyKernelstub(backup *BackupManager, params []string) error {
+	current, err := kernelstubOptions(kernelstubConfig)
+	if err != nil {
+		return err
+	}
+	PrintInfo("kernelstub detected: boot parameters are set with kernelstub")
+
+	existing := make(map[string]string)
+	for _, option := range current {
+		existing[paramKey(option)] = option
+	}
+	var add, remove []string
+	for _, param := range params {
+		old, ok := existing[paramKey(param)]
+		if ok && old == param {
+			continue
+		}
+		if ok {
+			remove = append(remove, old)
+		}
+		add = append(add, param)
+	}
+	if len(add) == 0 {
+		PrintSuccess("Boot parameters already optimized")
+		return nil
+	}
+
+	var args []string
+	if len(remove) > 0 {
+		args = append(args, "--delete-options", strings.Join(remove, " "))
+	}
+	args = append(args, "--add-options", strings.Join(add, " "))
+	if gt.DryRun {
+		PrintInfo("Would run: kernelstub %s", strings.Join(args, " "))
+		return nil
+	}
+
+	if err := backup.BackupFile(kernelstubConfig); err != nil {
+		return fmt.Errorf("failed to backup %s: %w", kernelstubConfig, err)
+	}
+	if err := backup.AddRecoverySteps(backup.kernelstubRecoverySteps(add, remove)); err != nil {
+		PrintWarning("%v", err)
+	}
+	ExplainCommand("add the VMware boot parameters; kernelstub regenerates the loader entries", "kernelstub", args...)
+	if out, err := exec.Command("kernelstub", args...).CombinedOutput(); err != nil {
+		return fmt.Errorf("kernelstub failed: %v: %s", err, strings.TrimSpace(string(out)))
+	}
+	PrintSuccess("Kernel options updated with kernelstub")
+	PrintWarning("REBOOT REQUIRED for boot parameter changes to take effect")
+	return nil
+}
+
+// configuredParams returns the kernel options set in the bootloader
+// configuration (for systemd-boot, the options every entry shares)
+func (gt *GrubTuner) configuredParams() ([]string, error) {
+	switch gt.Bootloader {
+	case BootloaderGRUB:
+		config, _, err := gt.ParseGrubConfig()
+		if err != nil {
+			return nil, err
+		}
+		return gt.parseParams(config["GRUB_CMDLINE_LINUX_DEFAULT"]), nil
+	case BootloaderKernelstub:
+		return kernelstubOptions(kernelstubConfig)
+	case BootloaderSystemdBoot:
+		entries, _ := filepath.Glob(filepath.Join(gt.LoaderEntries, "*.conf"))
+		counts := make(map[string]int)
+		for _, path := range entries {
+			data, err := os.ReadFile(path)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read %s: %w", path, err)
+			}
+			for _, option := range entryOptions(string(data)) {
+				counts[option]++
+			}
+		}
+		var shared []string
+		for option, n := range counts {
+			if n == len(entries) {
+				shared = append(shared, option)
+			}
+		}
+		return shared, nil
+	}
+	return nil, fmt.Errorf("%w: no supported bootloader (GRUB, systemd-boot, kernelstub) found, boot parameters are not managed", ErrVerifySkipped)
+}
+
+// Verify checks that the bootloader configuration has the boot parameters
+func (gt *GrubTuner) Verify() error {
+	if gt.Distro != nil && gt.Distro.Ostree {
+		return fmt.Errorf("%w: kernel arguments are managed by rpm-ostree", ErrVerifySkipped)
+	}
+	configured, err := gt.configuredParams()
+	if err != nil {
+		return err
+	}
+	present := make(map[string]bool)
+	for _, param := range configured {
+		present[param] = true
+	}
+
+	wanted := append(gt.VMwareBootParams(), gt.ExtraParams...)
+	wanted = append(wanted, Tuning.ExpertBoot...)
+	var missing []string
+	for _, param := range wanted {
+		if !present[param] {
+			missing = append(missing, param)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("missing from the %s configuration: %s", gt.Bootloader, strings.Join(missing, " "))
+	}
+	return nil
+}
@@ -0,0 +1,68 @@
+package tuner
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDetectBootloader(t *testing.T) {
+	root := t.TempDir()
+	if loader, _ := DetectBootloader(root, "/etc/default/grub"); loader != BootloaderNone {
+		t.Errorf("empty root detected as %q", loader)
+	}
+
+	entries := filepath.Join(root, "boot", "efi", "loader", "entries")
+	os.MkdirAll(entries, 0755)
+	os.WriteFile(filepath.Join(root, "boot", "efi", "loader", "loader.conf"), []byte("default ubuntu.conf\n"), 0644)
+	if loader, dir := DetectBootloader(root, "/etc/default/grub"); loader != BootloaderSystemdBoot || dir != entries {
+		t.Errorf("DetectBootloader() = %q, %q; want systemd-boot, %s", loader, dir, entries)
+	}
+
+	os.MkdirAll(filepath.Join(root, "etc", "default"), 0755)
+	os.WriteFile(filepath.Join(root, "etc", "default", "grub"), nil, 0644)
+	if loader, _ := DetectBootloader(root, "/etc/default/grub"); loader != BootloaderGRUB {
+		t.Errorf("GRUB not preferred: %q", loader)
+	}
+
+	os.MkdirAll(filepath.Join(root, "etc", "kernelstub"), 0755)
+	os.WriteFile(filepath.Join(root, kernelstubConfig), []byte("{}"), 0644)
+	if loader, _ := DetectBootloader(root, "/etc/default/grub"); loader != BootloaderKernelstub {
+		t.Errorf("kernelstub not detected: %q", loader)
+	}
+}
+
+func TestLoaderEntryOptions(t *testing.T) {
+	entry := "title Ubuntu\nlinux /vmlinuz\noptions root=UUID=1234 ro\noptions quiet\n"
+	if got, want := entryOptions(entry), []string{"root=UUID=1234", "ro", "quiet"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("entryOptions() = %v, want %v", got, want)
+	}
+
+	updated := setEntryOptions(entry, []string{"root=UUID=1234", "ro", "quiet", "elevator=noop"})
+	if want := "title Ubuntu\nlinux /vmlinuz\noptions root=UUID=1234 ro quiet elevator=noop\n"; updated != want {
+		t.Errorf("setEntryOptions() = %q, want %q", updated, want)
+	}
+}
+
+func TestBootVerify(t *testing.T) {
+	entries := t.TempDir()
+	options := "root=UUID=1234 ro " + strings.Join((&GrubTuner{}).VMwareBootParams(), " ")
+	os.WriteFile(filepath.Join(entries, "a.conf"), []byte("options "+options+"\n"), 0644)
+	os.WriteFile(filepath.Join(entries, "b.conf"), []byte("options root=UUID=1234 ro\n"), 0644)
+
+	gt := &GrubTuner{Bootloader: BootloaderSystemdBoot, LoaderEntries: entries}
+	if err := gt.Verify(); err == nil || !strings.Contains(err.Error(), "elevator=noop") {
+		t.Errorf("entry without the parameters not reported: %v", err)
+	}
+	os.WriteFile(filepath.Join(entries, "b.conf"), []byte("options "+options+"\n"), 0644)
+	if err := gt.Verify(); err != nil {
+		t.Errorf("Verify() = %v", err)
+	}
+
+	if err := (&GrubTuner{}).Verify(); !errors.Is(err, ErrVerifySkipped) {
+		t.Errorf("no bootloader: got %v, want ErrVerifySkipped", err)
+	}
+}
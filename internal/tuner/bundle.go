@@ -0,0 +1,380 @@
+package tuner
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
+)
+
+// bundleManifestName describes the content of an air-gapped bundle
+const bundleManifestName = "bundle.json"
+
+// bundleRepoID is the name of the temporary repository built from a bundle
+const bundleRepoID = "vmware-tuner-bundle"
+
+// BundleManifest is written at the root of a bundle
+type BundleManifest struct {
+	Distro   string   `json:"distro"`
+	Release  string   `json:"release"`
+	Arch     string   `json:"arch"`
+	Created  string   `json:"created"`
+	Packages []string `json:"packages"`
+	// Files maps each file of the bundle (relative path) to its SHA-256
+	Files map[string]string `json:"files,omitempty"`
+}
+
+// BundlePackages returns the packages the tool may install on a distribution
+func BundlePackages(t DistroType) []string {
+	if t == DistroRHEL {
+		return []string{"open-vm-tools", "ethtool", "cloud-utils-growpart", "chrony", "fio", "iperf3"}
+	}
+	return []string{"open-vm-tools", "ethtool", "cloud-guest-utils", "chrony", "fio", "iperf3"}
+}
+
+// bundleFamily is the distribution family a bundle is built for ("" when
+// bundles are not supported)
+func bundleFamily(t DistroType) string {
+	switch t {
+	case DistroDebian:
+		return "debian"
+	case DistroRHEL:
+		return "rhel"
+	}
+	return ""
+}
+
+// bundleTarget returns the family, release and architecture of this VM, the
+// target a bundle must have been built for. Variable so tests can stub it.
+var bundleTarget = func() (family, release, arch string) {
+	if dm, err := NewDistroManager(); err == nil {
+		family = bundleFamily(dm.Type)
+	}
+	return family, osReleaseValue("VERSION_ID"), runtime.GOARCH
+}
+
+// BundleTuner downloads packages and their dependencies into a transferable archive
+type BundleTuner struct {
+	Distro    *DistroManager
+	Release   string
+	OutputDir string
+}
+
+// NewBundleTuner creates a new bundle builder. An empty release targets the local one.
+func NewBundleTuner(distro *DistroManager, release, outputDir string) *BundleTuner {
+	if release == "" {
+		release = osReleaseValue("VERSION_ID")
+	}
+	return &BundleTuner{
+		Distro:    distro,
+		Release:   release,
+		OutputDir: outputDir,
+	}
+}
+
+// Create builds vmware-tuner-bundle-<family>-<release>-<arch>.tar.gz. The archive
+// (or its extracted directory) is consumed with --pkg-dir on the air-gapped VM.
+func (bt *BundleTuner) Create(hasInternet bool) error {
+	PrintStep("Air-Gapped Bundle")
+
+	if !hasInternet {
+		return fmt.Errorf("bundle create must run on a connected machine")
+	}
+
+	family := bundleFamily(bt.Distro.Type)
+	if family == "" {
+		return ErrUnsupportedDistro
+	}
+	if local := osReleaseValue("VERSION_ID"); family == "debian" && bt.Release != local {
+		return fmt.Errorf("Debian/Ubuntu bundles must be built on the target release (%s here, %s requested)", local, bt.Release)
+	}
+
+	packages := BundlePackages(bt.Distro.Type)
+	PrintInfo("Target: %s %s (%s)", family, bt.Release, runtime.GOARCH)
+	PrintInfo("Packages: %s", strings.Join(packages, ", "))
+
+	staging, err := os.MkdirTemp("", "vmware-tuner-bundle")
+	if err != nil {
+		return fmt.Errorf("failed to create staging directory: %w", err)
+	}
+	defer os.RemoveAll(staging)
+
+	if bt.Distro.Type == DistroDebian {
+		err = bt.downloadDebian(staging, packages)
+	} else {
+		err = bt.downloadRHEL(staging, packages)
+	}
+	if err != nil {
+		return err
+	}
+
+	manifest := BundleManifest{
+		Distro:   family,
+		Release:  bt.Release,
+		Arch:     runtime.GOARCH,
+		Created:  time.Now().Format(time.RFC3339),
+		Packages: packages,
+	}
+	if manifest.Files, err = bundleChecksums(staging); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal bundle manifest: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(staging, bundleManifestName), data, 0644); err != nil {
+		return fmt.Errorf("failed to write bundle manifest: %w", err)
+	}
+
+	if err := os.MkdirAll(bt.OutputDir, 0755); err != nil {
+		return fmt.Errorf("failed to create output directory: %w", err)
+	}
+	archive := filepath.Join(bt.OutputDir, fmt.Sprintf("vmware-tuner-bundle-%s-%s-%s.tar.gz", family, bt.Release, runtime.GOARCH))
+	if out, err := exec.Command("tar", "-czf", archive, "-C", staging, ".").CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to create archive: %v: %s", err, string(out))
+	}
+
+	PrintSuccess("Bundle written to %s", archive)
+	PrintInfo("On the air-gapped VM: vmware-tuner --pkg-dir %s", filepath.Base(archive))
+	return nil
+}
+
+// downloadDebian downloads the packages with their full dependency closure
+// and indexes them as a flat apt repository
+func (bt *BundleTuner) downloadDebian(dir string, packages []string) error {
+	args := append([]string{"depends", "--recurse", "--no-recommends", "--no-suggests",
+		"--no-conflicts", "--no-breaks", "--no-replaces", "--no-enhances"}, packages...)
+	out, err := exec.Command("apt-cache", args...).Output()
+	if err != nil {
+		return fmt.Errorf("failed to resolve dependencies: %w", err)
+	}
+
+	seen := make(map[string]bool)
+	var closure []string
+	for _, line := range strings.Split(string(out), "\n") {
+		// Package names are the unindented lines; virtual packages are <bracketed>
+		if line == "" || strings.HasPrefix(line, " ") || strings.HasPrefix(line, "<") || seen[line] {
+			continue
+		}
+		seen[line] = true
+		closure = append(closure, line)
+	}
+
+	PrintInfo("Downloading %d packages (with dependencies)...", len(closure))
+	failed := 0
+	for _, pkg := range closure {
+		cmd := exec.Command("apt-get", "download", pkg)
+		cmd.Dir = dir
+		if err := cmd.Run(); err != nil {
+			failed++
+		}
+	}
+	if failed > 0 {
+		PrintWarning("%d dependencies could not be downloaded (usually virtual or already-essential packages)", failed)
+	}
+
+	cmd := exec.Command("apt-ftparchive", "packages", ".")
+	cmd.Dir = dir
+	index, err := cmd.Output()
+	if err != nil {
+		return fmt.Errorf("failed to index packages (apt-utils required): %w", err)
+	}
+	return os.WriteFile(filepath.Join(dir, "Packages"), index, 0644)
+}
+
+// downloadRHEL downloads the packages with all dependencies for the release
+// and builds the repository metadata
+func (bt *BundleTuner) downloadRHEL(dir string, packages []string) error {
+	args := append([]string{"download", "--resolve", "--alldeps", "--destdir", dir, "--releasever", bt.Release}, packages...)
+	PrintInfo("Downloading packages (with dependencies)...")
+	if out, err := exec.Command("dnf", args...).CombinedOutput(); err != nil {
+		return fmt.Errorf("dnf download failed (dnf-plugins-core required): %v: %s", err, string(out))
+	}
+
+	createrepo := "createrepo_c"
+	if _, err := exec.LookPath(createrepo); err != nil {
+		createrepo = "createrepo"
+	}
+	if out, err := exec.Command(createrepo, dir).CombinedOutput(); err != nil {
+		return fmt.Errorf("%s failed: %v: %s", createrepo, err, string(out))
+	}
+	return nil
+}
+
+// LoadBundleManifest reads the bundle manifest of a package directory
+func LoadBundleManifest(dir string) (*BundleManifest, error) {
+	data, err := os.ReadFile(filepath.Join(dir, bundleManifestName))
+	if err != nil {
+		return nil, err
+	}
+	var manifest BundleManifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("invalid bundle manifest: %w", err)
+	}
+	return &manifest, nil
+}
+
+// bundleChecksums returns the SHA-256 of every file below dir, except the
+// manifest
+func bundleChecksums(dir string) (map[string]string, error) {
+	sums := make(map[string]string)
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || !info.Mode().IsRegular() {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil || rel == bundleManifestName {
+			return err
+		}
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		h := sha256.New()
+		if _, err := io.Copy(h, f); err != nil {
+			return err
+		}
+		sums[filepath.ToSlash(rel)] = hex.EncodeToString(h.Sum(nil))
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to checksum the bundle: %w", err)
+	}
+	return sums, nil
+}
+
+// CheckTarget refuses a bundle built for another distribution family,
+// release or architecture. A RHEL bundle is built for a major release
+// (dnf --releasever), so 9 matches 9.4.
+func (m *BundleManifest) CheckTarget(family, release, arch string) error {
+	sameRelease := m.Release == release
+	if m.Distro == "rhel" {
+		sameRelease = strings.SplitN(m.Release, ".", 2)[0] == strings.SplitN(release, ".", 2)[0]
+	}
+	if m.Distro != family || !sameRelease || m.Arch != arch {
+		return fmt.Errorf("bundle built for %s %s (%s), this VM is %s %s (%s)", m.Distro, m.Release, m.Arch, family, release, arch)
+	}
+	return nil
+}
+
+// Verify checks the files of the extracted bundle against the manifest: same
+// files and checksums, and a package file for each listed package
+func (m *BundleManifest) Verify(dir string) error {
+	if len(m.Files) == 0 {
+		return fmt.Errorf("bundle manifest has no checksums")
+	}
+	sums, err := bundleChecksums(dir)
+	if err != nil {
+		return err
+	}
+	var problems []string
+	for name, want := range m.Files {
+		switch got, ok := sums[name]; {
+		case !ok:
+			problems = append(problems, name+" missing")
+		case got != want:
+			problems = append(problems, name+" altered (checksum mismatch)")
+		}
+	}
+	for name := range sums {
+		if _, ok := m.Files[name]; !ok {
+			problems = append(problems, name+" not in the manifest")
+		}
+	}
+	for _, pkg := range m.Packages {
+		pattern := pkg + "-[0-9]*.rpm"
+		if m.Distro == "debian" {
+			pattern = pkg + "_*.deb"
+		}
+		if matches, _ := filepath.Glob(filepath.Join(dir, pattern)); len(matches) == 0 {
+			problems = append(problems, "no package file for "+pkg)
+		}
+	}
+	if len(problems) > 0 {
+		sort.Strings(problems)
+		return fmt.Errorf("bundle does not match its manifest: %s", strings.Join(problems, ", "))
+	}
+	return nil
+}
+
+// ExtractBundle unpacks a bundle archive into a temporary directory and
+// checks it against its manifest and this VM. The directory is removed on
+// failure; on success the caller removes it when done.
+func ExtractBundle(archive string) (_ string, err error) {
+	dir, err := os.MkdirTemp("", "vmware-tuner-pkgs")
+	if err != nil {
+		return "", fmt.Errorf("failed to create temporary directory: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			os.RemoveAll(dir)
+		}
+	}()
+
+	if out, err := exec.Command("tar", "-xzf", archive, "-C", dir).CombinedOutput(); err != nil {
+		return "", fmt.Errorf("failed to extract %s: %v: %s", archive, err, string(out))
+	}
+	manifest, err := LoadBundleManifest(dir)
+	if err != nil {
+		return "", fmt.Errorf("%s is not a vmware-tuner bundle: %w", archive, err)
+	}
+	if err := manifest.CheckTarget(bundleTarget()); err != nil {
+		return "", fmt.Errorf("%s: %w", archive, err)
+	}
+	if err := manifest.Verify(dir); err != nil {
+		return "", fmt.Errorf("%s: %w", archive, err)
+	}
+	return dir, nil
+}
+
+// installFromBundle installs pkg through a temporary repository built on the
+// bundle, so its dependencies are resolved from the bundle as well
+func (dm *DistroManager) installFromBundle(pkg string) error {
+	var cmd *exec.Cmd
+
+	switch dm.Type {
+	case DistroDebian:
+		list, err := os.CreateTemp("", "vmware-tuner-bundle*.list")
+		if err != nil {
+			return fmt.Errorf("failed to create apt source list: %w", err)
+		}
+		defer os.Remove(list.Name())
+		fmt.Fprintf(list, "deb [trusted=yes] file:%s ./\n", PackageDir)
+		list.Close()
+
+		aptOpts := []string{
+			"-o", "Dir::Etc::SourceList=" + list.Name(),
+			"-o", "Dir::Etc::SourceParts=-",
+			"-o", "APT::Get::List-Cleanup=0",
+		}
+		if out, err := exec.Command("apt-get", append([]string{"update"}, aptOpts...)...).CombinedOutput(); err != nil {
+			return fmt.Errorf("failed to read bundle index: %v\nOutput: %s", err, string(out))
+		}
+		cmd = exec.Command("apt-get", append(append([]string{"install", "-y"}, aptOpts...), pkg)...)
+		cmd.Env = append(os.Environ(), "DEBIAN_FRONTEND=noninteractive")
+	case DistroRHEL:
+		cmd = exec.Command("dnf", "install", "-y", "--disablerepo=*",
+			"--repofrompath="+bundleRepoID+","+PackageDir,
+			"--setopt="+bundleRepoID+".gpgcheck=0", pkg)
+	default:
+		return fmt.Errorf("unknown distribution type")
+	}
+
+	PrintInfo("Installing package %s from the offline bundle...", pkg)
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("failed to install %s: %v\nOutput: %s", pkg, err, string(output))
+	}
+
+	PrintSuccess("Installed %s (offline bundle)", pkg)
+	return nil
+}
@@ -0,0 +1,105 @@
+package tuner
+
+import (
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// bundleArchive packs files and their manifest like bundle create
+func bundleArchive(t *testing.T, files map[string]string, alter func(staging string)) string {
+	t.Helper()
+	staging := t.TempDir()
+	writeFiles(t, staging, files)
+	sums, err := bundleChecksums(staging)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := json.Marshal(BundleManifest{Distro: "debian", Release: "12", Arch: "amd64", Packages: []string{"ethtool"}, Files: sums})
+	writeFiles(t, staging, map[string]string{bundleManifestName: string(data)})
+	if alter != nil {
+		alter(staging)
+	}
+	archive := filepath.Join(t.TempDir(), "bundle.tar.gz")
+	if out, err := exec.Command("tar", "-czf", archive, "-C", staging, ".").CombinedOutput(); err != nil {
+		t.Fatalf("tar: %v: %s", err, out)
+	}
+	return archive
+}
+
+func TestExtractBundle(t *testing.T) {
+	if _, err := exec.LookPath("tar"); err != nil {
+		t.Skip("tar not installed")
+	}
+	defer func(saved func() (string, string, string)) { bundleTarget = saved }(bundleTarget)
+	bundleTarget = func() (string, string, string) { return "debian", "12", "amd64" }
+	tmp := t.TempDir()
+	t.Setenv("TMPDIR", tmp)
+	files := map[string]string{"ethtool_6.1-1_amd64.deb": "deb", "Packages": "Package: ethtool\n"}
+
+	dir, err := ExtractBundle(bundleArchive(t, files, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	os.RemoveAll(dir)
+
+	for name, alter := range map[string]func(string){
+		"altered package": func(staging string) {
+			os.WriteFile(filepath.Join(staging, "ethtool_6.1-1_amd64.deb"), []byte("evil"), 0644)
+		},
+		"added file": func(staging string) {
+			os.WriteFile(filepath.Join(staging, "extra_1.0_amd64.deb"), []byte("deb"), 0644)
+		},
+		"missing manifest": func(staging string) {
+			os.Remove(filepath.Join(staging, bundleManifestName))
+		},
+		"other target": func(staging string) {
+			data, _ := json.Marshal(BundleManifest{Distro: "debian", Release: "11", Arch: "amd64", Packages: []string{"ethtool"}})
+			os.WriteFile(filepath.Join(staging, bundleManifestName), data, 0644)
+		},
+		"no checksums": func(staging string) {
+			data, _ := json.Marshal(BundleManifest{Distro: "debian", Release: "12", Arch: "amd64", Packages: []string{"ethtool"}})
+			os.WriteFile(filepath.Join(staging, bundleManifestName), data, 0644)
+		},
+	} {
+		if _, err := ExtractBundle(bundleArchive(t, files, alter)); err == nil {
+			t.Errorf("%s: bundle accepted", name)
+		}
+		// The temporary directory does not outlive a rejected bundle
+		if left, _ := os.ReadDir(tmp); len(left) != 0 {
+			t.Errorf("%s: %d temporary directories left", name, len(left))
+		}
+	}
+}
+
+func TestBundleManifestVerifyPackages(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"open-vm-tools-12.3.0-1.el9.x86_64.rpm": "rpm"})
+	sums, err := bundleChecksums(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := &BundleManifest{Distro: "rhel", Packages: []string{"open-vm-tools", "ethtool"}, Files: sums}
+	if err := m.Verify(dir); err == nil || !strings.Contains(err.Error(), "no package file for ethtool") || strings.Contains(err.Error(), "open-vm-tools") {
+		t.Errorf("verify = %v, want only ethtool missing", err)
+	}
+}
+
+func TestBundleManifestCheckTarget(t *testing.T) {
+	m := &BundleManifest{Distro: "rhel", Release: "9", Arch: "amd64"}
+	if err := m.CheckTarget("rhel", "9.4", "amd64"); err != nil {
+		t.Errorf("RHEL 9 bundle on 9.4: %v", err)
+	}
+	for _, target := range [][3]string{{"rhel", "8.10", "amd64"}, {"rhel", "9.4", "arm64"}, {"debian", "9", "amd64"}} {
+		if err := m.CheckTarget(target[0], target[1], target[2]); err == nil {
+			t.Errorf("RHEL 9 amd64 bundle accepted on %v", target)
+		}
+	}
+	m = &BundleManifest{Distro: "debian", Release: "22.04", Arch: "amd64"}
+	if err := m.CheckTarget("debian", "22.10", "amd64"); err == nil {
+		t.Error("Ubuntu 22.04 bundle accepted on 22.10")
+	}
+}
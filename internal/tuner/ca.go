@@ -0,0 +1,245 @@
+package tuner
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// caAnchorDirs hold the local CA certificates merged into the system trust store
+var caAnchorDirs = map[DistroType]string{
+	DistroDebian: "/usr/local/share/ca-certificates",
+	DistroRHEL:   "/etc/pki/ca-trust/source/anchors",
+}
+
+// caBundles are the generated bundles used by OpenSSL, curl, apt and dnf
+var caBundles = map[DistroType]string{
+	DistroDebian: "/etc/ssl/certs/ca-certificates.crt",
+	DistroRHEL:   "/etc/pki/tls/certs/ca-bundle.crt",
+}
+
+// CATuner installs an enterprise root CA (TLS-inspecting proxies) in the trust store
+type CATuner struct {
+	Distro    *DistroManager
+	Backup    *BackupManager
+	AnchorDir string
+	Bundle    string
+}
+
+// NewCATuner creates a new CA trust store tuner
+func NewCATuner(distro *DistroManager, backup *BackupManager) *CATuner {
+	return &CATuner{
+		Distro:    distro,
+		Backup:    backup,
+		AnchorDir: caAnchorDirs[distro.Type],
+		Bundle:    caBundles[distro.Type],
+	}
+}
+
+func init() {
+	Register(Module{
+		Name:        "ca",
+		Label:       "Install Enterprise Root CA",
+		Description: "Add a root certificate to the system trust store",
+		Category:    CategoryIntegration,
+		Menu:        27,
+		RequireRoot: true,
+		Distros:     packagedDistros,
+		Run: func(ctx *ModuleContext) error {
+			backup, err := ctx.BackupSession()
+			if err != nil {
+				return err
+			}
+			return NewCATuner(ctx.Distro, backup).Run()
+		},
+	})
+}
+
+// Run asks for the certificate, installs it and checks HTTPS afterwards
+func (ct *CATuner) Run() error {
+	PrintStep("Enterprise Root CA")
+
+	if ct.AnchorDir == "" {
+		return ErrUnsupportedDistro
+	}
+
+	fmt.Print("Path to the root CA certificate (PEM or DER): ")
+	reader := bufio.NewReader(os.Stdin)
+	path, _ := reader.ReadString('\n')
+	path = strings.TrimSpace(path)
+	if path == "" {
+		PrintInfo("Cancelled")
+		return nil
+	}
+
+	if err := ct.Install(path); err != nil {
+		return err
+	}
+
+	fmt.Println()
+	return ct.VerifyHTTPS()
+}
+
+// LoadCertificates reads the certificates of a PEM (one or more blocks) or DER file
+func LoadCertificates(path string) ([]*x509.Certificate, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var certs []*x509.Certificate
+	rest := data
+	for {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("invalid certificate in %s: %w", path, err)
+		}
+		certs = append(certs, cert)
+	}
+	if len(certs) > 0 {
+		return certs, nil
+	}
+
+	cert, err := x509.ParseCertificate(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s is neither a PEM nor a DER certificate", path)
+	}
+	return []*x509.Certificate{cert}, nil
+}
+
+// anchorName derives the file name from the certificate subject
+func anchorName(cert *x509.Certificate) string {
+	name := cert.Subject.CommonName
+	if name == "" {
+		name = fmt.Sprintf("%x", sha256.Sum256(cert.Raw))[:16]
+	}
+	name = regexp.MustCompile(`[^A-Za-z0-9._-]+`).ReplaceAllString(name, "_")
+	// update-ca-certificates only picks up *.crt files
+	return "vmware-tuner-" + strings.Trim(name, "_") + ".crt"
+}
+
+// Install copies the certificates as PEM anchors and rebuilds the trust store
+func (ct *CATuner) Install(path string) error {
+	certs, err := LoadCertificates(path)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(ct.AnchorDir, 0755); err != nil {
+		return fmt.Errorf("failed to create %s: %w", ct.AnchorDir, err)
+	}
+
+	for _, cert := range certs {
+		PrintInfo("Subject:     %s", cert.Subject)
+		PrintInfo("Expires:     %s", cert.NotAfter.Format("2006-01-02"))
+		PrintInfo("SHA-256:     %X", sha256.Sum256(cert.Raw))
+		if !cert.IsCA {
+			PrintWarning("This certificate is not a CA (basicConstraints CA:FALSE): TLS inspection chains will not validate")
+		}
+		if time.Now().After(cert.NotAfter) {
+			return fmt.Errorf("certificate %s expired on %s", cert.Subject.CommonName, cert.NotAfter.Format("2006-01-02"))
+		}
+
+		dest := filepath.Join(ct.AnchorDir, anchorName(cert))
+		if err := ct.Backup.BackupFile(dest); err != nil {
+			return fmt.Errorf("failed to backup %s: %w", dest, err)
+		}
+		data := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})
+		if err := os.WriteFile(dest, data, 0644); err != nil {
+			return fmt.Errorf("failed to write %s: %w", dest, err)
+		}
+		PrintSuccess("Installed %s", dest)
+	}
+
+	if err := RefreshTrustStore(ct.Distro.Type); err != nil {
+		return err
+	}
+	PrintSuccess("System trust store updated")
+	return nil
+}
+
+// RefreshTrustStore regenerates the system CA bundle from the anchors
+func RefreshTrustStore(t DistroType) error {
+	var cmd *exec.Cmd
+	switch t {
+	case DistroDebian:
+		cmd = exec.Command("update-ca-certificates")
+	case DistroRHEL:
+		cmd = exec.Command("update-ca-trust", "extract")
+	default:
+		return ErrUnsupportedDistro
+	}
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("%s failed: %v: %s", cmd.Args[0], err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// VerifyHTTPS connects to public HTTPS endpoints trusting only the regenerated
+// bundle (Go caches the system pool, so the file is read directly)
+func (ct *CATuner) VerifyHTTPS() error {
+	PrintInfo("Checking HTTPS connectivity with the updated trust store...")
+
+	data, err := os.ReadFile(ct.Bundle)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", ct.Bundle, err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(data) {
+		return fmt.Errorf("no certificate found in %s", ct.Bundle)
+	}
+
+	client := &http.Client{
+		Timeout: 10 * time.Second,
+		Transport: &http.Transport{
+			Proxy:           http.ProxyFromEnvironment,
+			TLSClientConfig: &tls.Config{RootCAs: pool},
+		},
+	}
+
+	endpoints := []string{"https://github.com", "https://deb.debian.org"}
+	if ct.Distro.Type == DistroRHEL {
+		endpoints[1] = "https://mirrors.fedoraproject.org"
+	}
+
+	ok := 0
+	for _, url := range endpoints {
+		resp, err := client.Head(url)
+		if err != nil {
+			var unknown x509.UnknownAuthorityError
+			if errors.As(err, &unknown) {
+				PrintError("%s: certificate issued by %q is not trusted", url, unknown.Cert.Issuer.CommonName)
+			} else {
+				PrintWarning("%s: %v", url, err)
+			}
+			continue
+		}
+		resp.Body.Close()
+		PrintSuccess("%s: TLS OK (%s)", url, resp.Status)
+		ok++
+	}
+
+	if ok == 0 {
+		return fmt.Errorf("HTTPS still fails: check that this is the root CA of the inspecting proxy")
+	}
+	return nil
+}
@@ -0,0 +1,62 @@
+package tuner
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoadCertificates(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Corp Inspection Root CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(24 * time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	pemPath := filepath.Join(dir, "root.pem")
+	derPath := filepath.Join(dir, "root.cer")
+	os.WriteFile(pemPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0644)
+	os.WriteFile(derPath, der, 0644)
+
+	for _, path := range []string{pemPath, derPath} {
+		certs, err := LoadCertificates(path)
+		if err != nil {
+			t.Fatalf("LoadCertificates(%s): %v", path, err)
+		}
+		if len(certs) != 1 || !certs[0].IsCA {
+			t.Errorf("%s: unexpected certificates %v", path, certs)
+		}
+	}
+
+	certs, _ := LoadCertificates(pemPath)
+	if name := anchorName(certs[0]); name != "vmware-tuner-Corp_Inspection_Root_CA.crt" {
+		t.Errorf("anchorName = %s", name)
+	}
+
+	bad := filepath.Join(dir, "bad.txt")
+	os.WriteFile(bad, []byte("not a certificate"), 0644)
+	if _, err := LoadCertificates(bad); err == nil || !strings.Contains(err.Error(), "neither") {
+		t.Errorf("expected an error for a non-certificate file, got %v", err)
+	}
+}
@@ -0,0 +1,97 @@
+package tuner
+
+import (
+	"fmt"
+	"os/exec"
+	"sync"
+	"time"
+)
+
+// capabilityProbe detects an optional component enabling conditional modules
+type capabilityProbe struct {
+	Name    string
+	Missing string // shown next to the menu entries needing it
+	Probe   func() bool
+}
+
+// capabilityProbes are evaluated each time the menu is drawn, so installing
+// a component during the session enables its entries
+var capabilityProbes = []capabilityProbe{
+	{"docker", "Not Installed", func() bool { return commandExists("docker") }},
+	{"kubelet", "Not a Kubernetes node", func() bool { return commandExists("kubelet") }},
+	{"database", "No database found", func() bool {
+		for _, d := range DetectWorkloads("", nil) {
+			if d.Profile == "database" {
+				return true
+			}
+		}
+		return false
+	}},
+	{"gpu", "No GPU", func() bool {
+		devices, _ := ListPCIDevices(Sys.Root)
+		for _, dev := range devices {
+			if dev.BaseClass() == pciClassDisplay && !dev.IsVMware() {
+				return true
+			}
+		}
+		return false
+	}},
+}
+
+// moduleCapabilities lists the capability each conditional module needs
+var moduleCapabilities = map[string]string{
+	"docker": "docker",
+}
+
+// probeTimeout bounds each probe: a slow one counts as missing rather than
+// holding up the menu
+const probeTimeout = 500 * time.Millisecond
+
+// Capabilities holds the probe results
+type Capabilities map[string]bool
+
+// ProbeCapabilities runs the probes in parallel
+func ProbeCapabilities() Capabilities {
+	caps := make(Capabilities)
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, p := range capabilityProbes {
+		wg.Add(1)
+		go func(p capabilityProbe) {
+			defer wg.Done()
+			result := make(chan bool, 1)
+			go func() { result <- p.Probe() }()
+
+			found := false
+			select {
+			case found = <-result:
+			case <-time.After(probeTimeout):
+			}
+			mu.Lock()
+			caps[p.Name] = found
+			mu.Unlock()
+		}(p)
+	}
+	wg.Wait()
+	return caps
+}
+
+// Check returns an error when a module needs a capability that is missing
+func (c Capabilities) Check(module string) error {
+	name, ok := moduleCapabilities[module]
+	if !ok || c[name] {
+		return nil
+	}
+	for _, p := range capabilityProbes {
+		if p.Name == name {
+			return fmt.Errorf("%s", p.Missing)
+		}
+	}
+	return fmt.Errorf("%s not available", name)
+}
+
+// commandExists reports whether a command is in the PATH
+func commandExists(name string) bool {
+	_, err := exec.LookPath(name)
+	return err == nil
+}
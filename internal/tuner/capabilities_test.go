@@ -0,0 +1,38 @@
+package tuner
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCapabilitiesCheck(t *testing.T) {
+	caps := Capabilities{"docker": false}
+	if err := caps.Check("docker"); err == nil || err.Error() != "Not Installed" {
+		t.Errorf("docker entry should be unavailable, got %v", err)
+	}
+	caps["docker"] = true
+	if err := caps.Check("docker"); err != nil {
+		t.Errorf("docker entry should be available: %v", err)
+	}
+	if err := caps.Check("sysctl"); err != nil {
+		t.Errorf("unconditional module gated: %v", err)
+	}
+}
+
+func TestProbeCapabilitiesTimeout(t *testing.T) {
+	old := capabilityProbes
+	defer func() { capabilityProbes = old }()
+	capabilityProbes = []capabilityProbe{
+		{"fast", "", func() bool { return true }},
+		{"slow", "", func() bool { time.Sleep(2 * probeTimeout); return true }},
+	}
+
+	start := time.Now()
+	caps := ProbeCapabilities()
+	if elapsed := time.Since(start); elapsed > probeTimeout+200*time.Millisecond {
+		t.Errorf("probes blocked the menu for %s", elapsed)
+	}
+	if !caps["fast"] || caps["slow"] {
+		t.Errorf("unexpected probe results %v", caps)
+	}
+}
@@ -3,6 +3,7 @@ package tuner
 import (
 	"fmt"
 	"os/exec"
+	"time"
 )
 
 // CleanerTuner handles system cleaning
@@ -17,6 +18,21 @@ func NewCleanerTuner(distro *DistroManager) *CleanerTuner {
 	}
 }
 
+func init() {
+	Register(Module{
+		Name:        "cleaner",
+		Label:       "Clean System",
+		Description: "Free disk space: package caches, unused packages, old journal",
+		Category:    CategoryMaintenance,
+		Menu:        6,
+		RequireRoot: true,
+		Distros:     packagedDistros,
+		Run: func(ctx *ModuleContext) error {
+			return NewCleanerTuner(ctx.Distro).Run()
+		},
+	})
+}
+
 // Run performs the cleaning
 func (ct *CleanerTuner) Run() error {
 	PrintStep("System Cleaner")
@@ -27,7 +43,7 @@ func (ct *CleanerTuner) Run() error {
 	PrintInfo("  - Remove old crash dumps")
 	fmt.Println()
 	fmt.Print("Continue? (y/n): ")
-	
+
 	var response string
 	fmt.Scanln(&response)
 	if response != "y" && response != "yes" {
@@ -35,6 +51,9 @@ func (ct *CleanerTuner) Run() error {
 		return nil
 	}
 
+	before, _ := StatFilesystem("/")
+	start := time.Now()
+
 	// 1. Clean Package Cache
 	PrintInfo("Cleaning package cache...")
 	if ct.Distro.Type == DistroDebian {
@@ -60,8 +79,16 @@ func (ct *CleanerTuner) Run() error {
 	}
 
 	// 3. Show Free Space
+	if after, err := StatFilesystem("/"); err == nil && before.TotalMB > 0 {
+		// Other writers may have used space meanwhile
+		freed := after.AvailMB - before.AvailMB
+		if freed < 0 {
+			freed = 0
+		}
+		PrintSuccess("Freed %s on / in %s", FormatMiB(freed), FormatDuration(time.Since(start)))
+	}
 	PrintInfo("Current Disk Usage:")
-	exec.Command("df", "-h", "/").Run()
+	PrintFilesystemUsage("/")
 
 	return nil
 }
@@ -0,0 +1,260 @@
+package tuner
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// StateDir holds data kept between runs (compliance history, baselines)
+const StateDir = "/var/lib/vmware-tuner"
+
+// complianceCronFile schedules the weekly compliance job
+const complianceCronFile = "/etc/cron.d/vmware-tuner-compliance"
+
+// VerifyCheck is the outcome of one `verify` check
+type VerifyCheck struct {
+	Name    string `json:"name"`
+	OK      bool   `json:"ok"`
+	Skipped bool   `json:"skipped,omitempty"` // does not apply to this system
+	Message string `json:"message,omitempty"`
+}
+
+// CollectVerifyChecks runs the checks of the verify command
+func CollectVerifyChecks(distro *DistroManager) []VerifyCheck {
+	checks := []struct {
+		name   string
+		verify func() error
+	}{
+		{"Boot Parameters", NewGrubTuner(false, distro).Verify},
+		{"Sysctl", NewSysctlTuner(false).Verify},
+		{"Profile", VerifyProfile},
+		{"I/O Scheduler", NewSchedulerTuner(false).Verify},
+		{"Block Queues", NewQueueTuner(false).Verify},
+		{"PVSCSI", NewPVSCSITuner(false, distro).Verify},
+		{"Network", NewNetworkTuner(false).Verify},
+		{"NIC Affinity", NewAffinityTuner(false).Verify},
+		{"CPU Isolation", NewCPUIsolationTuner(false, distro).Verify},
+	}
+
+	var results []VerifyCheck
+	for _, c := range checks {
+		result := VerifyCheck{Name: c.name, OK: true}
+		if err := c.verify(); errors.Is(err, ErrVerifySkipped) {
+			result.Skipped = true
+			result.Message = err.Error()
+		} else if err != nil {
+			result.OK = false
+			result.Message = err.Error()
+		}
+		results = append(results, result)
+	}
+	return results
+}
+
+// ComplianceSnapshot is the state recorded by one compliance run
+type ComplianceSnapshot struct {
+	Timestamp string        `json:"timestamp"`
+	Hostname  string        `json:"hostname"`
+	Audit     AuditResult   `json:"audit"`
+	Verify    []VerifyCheck `json:"verify"`
+}
+
+// ComplianceTuner runs the scheduled audit+verify and reports changes
+type ComplianceTuner struct {
+	Distro    *DistroManager
+	Notifier  *Notifier
+	StatePath string
+}
+
+// NewComplianceTuner creates a new compliance tuner
+func NewComplianceTuner(distro *DistroManager, notify NotifyConfig) *ComplianceTuner {
+	return &ComplianceTuner{
+		Distro:    distro,
+		Notifier:  NewNotifier(notify),
+		StatePath: filepath.Join(StateDir, "compliance.json"),
+	}
+}
+
+func init() {
+	Register(Module{
+		Name:        "compliance",
+		Label:       "Weekly Compliance Job",
+		Description: "Schedule the weekly compliance check",
+		Category:    CategoryIntegration,
+		Menu:        24,
+		RequireRoot: true,
+		Run: func(ctx *ModuleContext) error {
+			return NewComplianceTuner(ctx.Distro, ctx.Config.Notify).Schedule()
+		},
+	})
+}
+
+// Snapshot collects the current audit and verify results
+func (ct *ComplianceTuner) Snapshot() ComplianceSnapshot {
+	hostname, _ := os.Hostname()
+	return ComplianceSnapshot{
+		Timestamp: time.Now().Format(time.RFC3339),
+		Hostname:  hostname,
+		Audit:     NewAuditTuner(ct.Distro).Evaluate(),
+		Verify:    CollectVerifyChecks(ct.Distro),
+	}
+}
+
+// DiffCompliance lists the differences between two runs
+func DiffCompliance(prev, cur ComplianceSnapshot) []string {
+	var diffs []string
+
+	if prev.Audit.Score != cur.Audit.Score {
+		diffs = append(diffs, fmt.Sprintf("Audit score: %d -> %d/%d", prev.Audit.Score, cur.Audit.Score, cur.Audit.MaxScore))
+	}
+
+	previous := make(map[string]AuditItem)
+	for _, item := range prev.Audit.Items {
+		previous[item.Name] = item
+	}
+	for _, item := range cur.Audit.Items {
+		old, ok := previous[item.Name]
+		if !ok {
+			diffs = append(diffs, fmt.Sprintf("New audit check %s: %s", item.Name, item.Message))
+			continue
+		}
+		if old.Status != item.Status || old.Points != item.Points {
+			diffs = append(diffs, fmt.Sprintf("Audit %s: %s -> %s (%s)", item.Name, old.Status, item.Status, item.Message))
+		}
+	}
+
+	verified := make(map[string]VerifyCheck)
+	for _, check := range prev.Verify {
+		verified[check.Name] = check
+	}
+	for _, check := range cur.Verify {
+		old, ok := verified[check.Name]
+		if ok && old.OK == check.OK {
+			continue
+		}
+		if check.OK {
+			diffs = append(diffs, fmt.Sprintf("Verify %s: now OK", check.Name))
+		} else {
+			diffs = append(diffs, fmt.Sprintf("Verify %s: FAILED (%s)", check.Name, check.Message))
+		}
+	}
+
+	return diffs
+}
+
+// Check runs audit+verify, compares with the previous run and notifies
+// only when something changed. The first run records the baseline.
+func (ct *ComplianceTuner) Check(notify bool) error {
+	PrintStep("Compliance Check")
+	return ct.record(ct.Snapshot(), notify)
+}
+
+// record compares the snapshot with the previous run and saves it. When the
+// notification fails the previous snapshot is kept, so the next run reports
+// the drift again.
+func (ct *ComplianceTuner) record(cur ComplianceSnapshot, notify bool) error {
+	var prev ComplianceSnapshot
+	data, err := os.ReadFile(ct.StatePath)
+	hasPrevious := err == nil && json.Unmarshal(data, &prev) == nil
+
+	fmt.Println()
+	PrintInfo("Audit score: %d/%d", cur.Audit.Score, cur.Audit.MaxScore)
+
+	if !hasPrevious {
+		if err := ct.save(cur); err != nil {
+			return err
+		}
+		PrintSuccess("Baseline recorded in %s", ct.StatePath)
+		return nil
+	}
+
+	diffs := DiffCompliance(prev, cur)
+	if len(diffs) == 0 {
+		PrintSuccess("No change since %s", prev.Timestamp)
+		return ct.save(cur)
+	}
+
+	PrintWarning("%d change(s) since %s:", len(diffs), prev.Timestamp)
+	for _, d := range diffs {
+		fmt.Printf("    - %s\n", d)
+	}
+
+	if !notify || !ct.Notifier.Config.Enabled() {
+		return ct.save(cur)
+	}
+
+	subject := fmt.Sprintf("[vmware-tuner] %s: compliance changed (score %d/%d)", cur.Hostname, cur.Audit.Score, cur.Audit.MaxScore)
+	body := fmt.Sprintf("Changes on %s since %s:\n\n- %s\n", cur.Hostname, prev.Timestamp, strings.Join(diffs, "\n- "))
+	if err := ct.Notifier.Send(subject, body); err != nil {
+		return fmt.Errorf("%w (the changes are reported again at the next run)", err)
+	}
+	PrintSuccess("Notification sent")
+	return ct.save(cur)
+}
+
+// save writes the snapshot for the next run
+func (ct *ComplianceTuner) save(snapshot ComplianceSnapshot) error {
+	if err := os.MkdirAll(filepath.Dir(ct.StatePath), 0755); err != nil {
+		return fmt.Errorf("failed to create %s: %w", filepath.Dir(ct.StatePath), err)
+	}
+	data, err := json.MarshalIndent(snapshot, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal snapshot: %w", err)
+	}
+	if err := os.WriteFile(ct.StatePath, data, 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", ct.StatePath, err)
+	}
+	return nil
+}
+
+// Schedule installs (or removes) the weekly compliance cron job
+func (ct *ComplianceTuner) Schedule() error {
+	PrintStep("Weekly Compliance Job")
+
+	if FileExists(complianceCronFile) {
+		PrintInfo("The weekly compliance job is currently SCHEDULED.")
+		fmt.Print("Do you want to remove it? (y/n): ")
+		var resp string
+		fmt.Scanln(&resp)
+		if resp == "y" {
+			os.Remove(complianceCronFile)
+			PrintSuccess("Schedule removed")
+		}
+		return nil
+	}
+
+	binPath, err := schedulableBinary()
+	if err != nil {
+		return err
+	}
+
+	if ct.Notifier.Config.Enabled() {
+		var targets []string
+		targets = append(targets, ct.Notifier.Config.Email...)
+		if ct.Notifier.Config.Webhook != "" {
+			targets = append(targets, "webhook")
+		}
+		PrintInfo("Changes will be sent to: %s", strings.Join(targets, ", "))
+	} else {
+		PrintWarning("No notify: section in %s, changes will only be logged", DefaultConfigPath)
+	}
+
+	content := fmt.Sprintf(`# VMware Tuner weekly compliance (audit + verify, notifies differences)
+# Generated by vmware-tuner
+0 6 * * 1 root %s compliance >/dev/null 2>&1
+`, binPath)
+
+	if err := os.WriteFile(complianceCronFile, []byte(content), 0644); err != nil {
+		return fmt.Errorf("failed to write cron file: %w", err)
+	}
+	PrintSuccess("Weekly compliance job scheduled (Monday 06:00 AM)")
+	PrintInfo("Created %s", complianceCronFile)
+
+	// Record the baseline now so the first scheduled run reports differences
+	return ct.Check(false)
+}
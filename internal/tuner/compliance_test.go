@@ -0,0 +1,80 @@
+package tuner
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDiffCompliance(t *testing.T) {
+	prev := ComplianceSnapshot{
+		Audit: AuditResult{Score: 100, Items: []AuditItem{
+			{Name: "sysctl", Status: AuditOK, Points: 20},
+			{Name: "bloatware", Status: AuditOK, Points: 20},
+		}},
+		Verify: []VerifyCheck{{Name: "Sysctl", OK: true}},
+	}
+
+	if diffs := DiffCompliance(prev, prev); len(diffs) != 0 {
+		t.Errorf("identical runs should have no differences, got %v", diffs)
+	}
+
+	cur := ComplianceSnapshot{
+		Audit: AuditResult{Score: 80, MaxScore: 100, Items: []AuditItem{
+			{Name: "sysctl", Status: AuditWarn, Message: "Sysctl optimizations missing (0/20)"},
+			{Name: "bloatware", Status: AuditOK, Points: 20},
+		}},
+		Verify: []VerifyCheck{{Name: "Sysctl", OK: false, Message: "configuration file not found"}},
+	}
+
+	diffs := DiffCompliance(prev, cur)
+	if len(diffs) != 3 {
+		t.Fatalf("expected 3 differences, got %d: %v", len(diffs), diffs)
+	}
+	if !strings.Contains(diffs[0], "100 -> 80") || !strings.Contains(diffs[1], "sysctl: ok -> warn") || !strings.Contains(diffs[2], "FAILED") {
+		t.Errorf("unexpected differences: %v", diffs)
+	}
+}
+
+func TestComplianceKeepsSnapshotUntilNotified(t *testing.T) {
+	status := http.StatusInternalServerError
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	ct := &ComplianceTuner{
+		Notifier:  NewNotifier(NotifyConfig{Webhook: server.URL}),
+		StatePath: filepath.Join(t.TempDir(), "compliance.json"),
+	}
+	saved := func() ComplianceSnapshot {
+		var snapshot ComplianceSnapshot
+		data, _ := os.ReadFile(ct.StatePath)
+		json.Unmarshal(data, &snapshot)
+		return snapshot
+	}
+	baseline := ComplianceSnapshot{Timestamp: "baseline", Audit: AuditResult{Score: 100, MaxScore: 100}}
+	if err := ct.record(baseline, true); err != nil {
+		t.Fatal(err)
+	}
+
+	drift := ComplianceSnapshot{Timestamp: "drift", Audit: AuditResult{Score: 80, MaxScore: 100}}
+	if err := ct.record(drift, true); err == nil {
+		t.Error("failed notification not reported")
+	}
+	if got := saved().Timestamp; got != "baseline" {
+		t.Errorf("snapshot %s saved although the notification failed", got)
+	}
+
+	status = http.StatusOK
+	if err := ct.record(drift, true); err != nil {
+		t.Fatal(err)
+	}
+	if got := saved().Timestamp; got != "drift" {
+		t.Errorf("snapshot %s kept after the notification was sent", got)
+	}
+}
@@ -0,0 +1,421 @@
+package tuner
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// DefaultConfigPath is the system-wide configuration file
+const DefaultConfigPath = "/etc/vmware-tuner/config.yaml"
+
+// Config holds the settings read from the configuration file
+type Config struct {
+	Path      string
+	Roles     map[string]Role
+	Syslog    SyslogConfig
+	Notify    NotifyConfig
+	Proxy     ProxyConfig
+	VCenter   VCenterConfig
+	Tuning    TuningConfig
+	Redaction RedactionConfig
+	UI        UIConfig
+	Stats     StatsConfig
+}
+
+// Role lists the modules sanctioned for a VM role (web, db, k8s-node, template...)
+type Role struct {
+	Modules []string
+}
+
+// LoadConfig reads the configuration file. A missing file yields an empty config.
+func LoadConfig(path string) (*Config, error) {
+	cfg := &Config{
+		Path:  path,
+		Roles: make(map[string]Role),
+	}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return cfg, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config %s: %w", path, err)
+	}
+
+	root, err := parseYAML(data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid config %s: %w", path, err)
+	}
+
+	if err := cfg.decode(root); err != nil {
+		return nil, fmt.Errorf("invalid config %s: %w", path, err)
+	}
+
+	return cfg, nil
+}
+
+// decode maps the parsed YAML tree onto the Config struct
+func (c *Config) decode(root map[string]interface{}) error {
+	if raw, ok := root["roles"]; ok {
+		roles, ok := raw.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("roles: expected a mapping of role names")
+		}
+		for name, rawRole := range roles {
+			fields, ok := rawRole.(map[string]interface{})
+			if !ok {
+				return fmt.Errorf("roles.%s: expected a mapping (modules: [...])", name)
+			}
+			modules, err := yamlStringList(fields["modules"])
+			if err != nil {
+				return fmt.Errorf("roles.%s.modules: %w", name, err)
+			}
+			c.Roles[name] = Role{Modules: modules}
+		}
+	}
+
+	if raw, ok := root["syslog"]; ok {
+		fields, ok := raw.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("syslog: expected a mapping (server, port, protocol, tls, ca_file)")
+		}
+		if err := c.Syslog.decode(fields); err != nil {
+			return fmt.Errorf("syslog.%w", err)
+		}
+	}
+
+	if raw, ok := root["notify"]; ok {
+		fields, ok := raw.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("notify: expected a mapping (email, from, smtp, webhook)")
+		}
+		if err := c.Notify.decode(fields); err != nil {
+			return fmt.Errorf("notify.%w", err)
+		}
+	}
+
+	if raw, ok := root["proxy"]; ok {
+		fields, ok := raw.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("proxy: expected a mapping (http, https, no_proxy)")
+		}
+		if err := c.Proxy.decode(fields); err != nil {
+			return fmt.Errorf("proxy.%w", err)
+		}
+	}
+
+	if raw, ok := root["vcenter"]; ok {
+		fields, ok := raw.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("vcenter: expected a mapping (host, port)")
+		}
+		if err := c.VCenter.decode(fields); err != nil {
+			return fmt.Errorf("vcenter.%w", err)
+		}
+	}
+
+	if raw, ok := root["tuning"]; ok {
+		fields, ok := raw.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("tuning: expected a mapping (grub, sysctl, fstab, network, debloat, backup_dir)")
+		}
+		if err := c.Tuning.decode(fields); err != nil {
+			return fmt.Errorf("tuning.%w", err)
+		}
+	}
+
+	if raw, ok := root["redaction"]; ok {
+		fields, ok := raw.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("redaction: expected a mapping (always, categories, terms)")
+		}
+		if err := c.Redaction.decode(fields); err != nil {
+			return fmt.Errorf("redaction.%w", err)
+		}
+	}
+
+	if raw, ok := root["ui"]; ok {
+		fields, ok := raw.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("ui: expected a mapping (theme)")
+		}
+		if err := c.UI.decode(fields); err != nil {
+			return fmt.Errorf("ui.%w", err)
+		}
+	}
+
+	if raw, ok := root["stats"]; ok {
+		fields, ok := raw.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("stats: expected a mapping (enabled)")
+		}
+		if err := c.Stats.decode(fields); err != nil {
+			return fmt.Errorf("stats.%w", err)
+		}
+	}
+
+	return nil
+}
+
+// RoleNames returns the configured role names, sorted
+func (c *Config) RoleNames() []string {
+	var names []string
+	for name := range c.Roles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// yamlLine is a significant (non-blank, non-comment) line of a YAML document
+type yamlLine struct {
+	num    int
+	indent int
+	text   string
+}
+
+// parseYAML parses the YAML subset used by the config file: nested mappings,
+// block lists ("- item"), flow lists ("[a, b]"), quoted scalars and comments.
+func parseYAML(data []byte) (map[string]interface{}, error) {
+	var lines []yamlLine
+	for i, raw := range strings.Split(string(data), "\n") {
+		raw = strings.TrimRight(stripYAMLComment(raw), " \r")
+		if strings.TrimSpace(raw) == "" || strings.TrimSpace(raw) == "---" {
+			continue
+		}
+		trimmed := strings.TrimLeft(raw, " ")
+		if strings.HasPrefix(trimmed, "\t") {
+			return nil, fmt.Errorf("line %d: tabs are not allowed for indentation", i+1)
+		}
+		lines = append(lines, yamlLine{num: i + 1, indent: len(raw) - len(trimmed), text: trimmed})
+	}
+
+	if len(lines) == 0 {
+		return map[string]interface{}{}, nil
+	}
+
+	value, next, err := parseYAMLBlock(lines, 0, lines[0].indent)
+	if err != nil {
+		return nil, err
+	}
+	if next < len(lines) {
+		return nil, fmt.Errorf("line %d: unexpected indentation", lines[next].num)
+	}
+
+	root, ok := value.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("top level must be a mapping")
+	}
+	return root, nil
+}
+
+func isYAMLListItem(text string) bool {
+	return text == "-" || strings.HasPrefix(text, "- ")
+}
+
+// parseYAMLBlock parses the block starting at lines[i] with the given indentation
+func parseYAMLBlock(lines []yamlLine, i, indent int) (interface{}, int, error) {
+	if isYAMLListItem(lines[i].text) {
+		return parseYAMLList(lines, i, indent)
+	}
+	return parseYAMLMap(lines, i, indent)
+}
+
+func parseYAMLMap(lines []yamlLine, i, indent int) (interface{}, int, error) {
+	result := make(map[string]interface{})
+
+	for i < len(lines) && lines[i].indent == indent {
+		line := lines[i]
+		if isYAMLListItem(line.text) {
+			return nil, i, fmt.Errorf("line %d: list item where a key was expected", line.num)
+		}
+
+		idx := strings.Index(line.text, ":")
+		if idx <= 0 || (idx+1 < len(line.text) && line.text[idx+1] != ' ') {
+			return nil, i, fmt.Errorf("line %d: expected 'key: value'", line.num)
+		}
+		key := unquoteYAML(strings.TrimSpace(line.text[:idx]))
+		rest := strings.TrimSpace(line.text[idx+1:])
+		i++
+
+		if rest != "" {
+			result[key] = parseYAMLScalar(rest)
+			continue
+		}
+
+		// Nested block: deeper indentation, or a list at the same indentation
+		switch {
+		case i < len(lines) && lines[i].indent > indent:
+			value, next, err := parseYAMLBlock(lines, i, lines[i].indent)
+			if err != nil {
+				return nil, next, err
+			}
+			result[key] = value
+			i = next
+		case i < len(lines) && lines[i].indent == indent && isYAMLListItem(lines[i].text):
+			value, next, err := parseYAMLList(lines, i, indent)
+			if err != nil {
+				return nil, next, err
+			}
+			result[key] = value
+			i = next
+		default:
+			result[key] = ""
+		}
+	}
+
+	if i < len(lines) && lines[i].indent > indent {
+		return nil, i, fmt.Errorf("line %d: unexpected indentation", lines[i].num)
+	}
+	return result, i, nil
+}
+
+func parseYAMLList(lines []yamlLine, i, indent int) (interface{}, int, error) {
+	var result []interface{}
+
+	for i < len(lines) && lines[i].indent == indent && isYAMLListItem(lines[i].text) {
+		item := strings.TrimSpace(strings.TrimPrefix(lines[i].text, "-"))
+
+		switch {
+		case item == "":
+			// "-" alone: the item is the following nested block
+			i++
+			if i >= len(lines) || lines[i].indent <= indent {
+				result = append(result, "")
+				continue
+			}
+			value, next, err := parseYAMLBlock(lines, i, lines[i].indent)
+			if err != nil {
+				return nil, next, err
+			}
+			result = append(result, value)
+			i = next
+		case strings.Contains(item, ": ") || strings.HasSuffix(item, ":"):
+			// "- key: value": a mapping whose keys are aligned after the dash
+			lines[i] = yamlLine{num: lines[i].num, indent: indent + 2, text: item}
+			value, next, err := parseYAMLMap(lines, i, indent+2)
+			if err != nil {
+				return nil, next, err
+			}
+			result = append(result, value)
+			i = next
+		default:
+			result = append(result, parseYAMLScalar(item))
+			i++
+		}
+	}
+
+	return result, i, nil
+}
+
+// parseYAMLScalar parses an inline value: flow list or (quoted) string
+func parseYAMLScalar(text string) interface{} {
+	if strings.HasPrefix(text, "[") && strings.HasSuffix(text, "]") {
+		inner := strings.TrimSpace(text[1 : len(text)-1])
+		list := []interface{}{}
+		if inner == "" {
+			return list
+		}
+		for _, item := range strings.Split(inner, ",") {
+			list = append(list, unquoteYAML(strings.TrimSpace(item)))
+		}
+		return list
+	}
+	return unquoteYAML(text)
+}
+
+func unquoteYAML(s string) string {
+	if len(s) >= 2 && (s[0] == '"' && s[len(s)-1] == '"' || s[0] == '\'' && s[len(s)-1] == '\'') {
+		return s[1 : len(s)-1]
+	}
+	return s
+}
+
+// stripYAMLComment removes a trailing comment that is not inside quotes
+func stripYAMLComment(line string) string {
+	var quote byte
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '#' && (i == 0 || line[i-1] == ' ' || line[i-1] == '\t'):
+			return line[:i]
+		}
+	}
+	return line
+}
+
+// yamlStringList converts a parsed value to a list of strings.
+// A single scalar is accepted as a one-element list.
+func yamlStringList(value interface{}) ([]string, error) {
+	switch v := value.(type) {
+	case nil:
+		return nil, nil
+	case string:
+		if v == "" {
+			return nil, nil
+		}
+		return []string{v}, nil
+	case []interface{}:
+		var list []string
+		for _, item := range v {
+			s, ok := item.(string)
+			if !ok {
+				return nil, fmt.Errorf("expected a list of strings")
+			}
+			list = append(list, s)
+		}
+		return list, nil
+	default:
+		return nil, fmt.Errorf("expected a list of strings")
+	}
+}
+
+// yamlString converts a parsed scalar to a string
+func yamlString(value interface{}) (string, error) {
+	switch v := value.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return v, nil
+	default:
+		return "", fmt.Errorf("expected a string")
+	}
+}
+
+// yamlInt converts a parsed scalar to an integer (0 when absent)
+func yamlInt(value interface{}) (int, error) {
+	s, err := yamlString(value)
+	if err != nil || s == "" {
+		return 0, err
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, fmt.Errorf("expected an integer, got %q", s)
+	}
+	return n, nil
+}
+
+// yamlBool converts a parsed scalar to a boolean (false when absent)
+func yamlBool(value interface{}) (bool, error) {
+	s, err := yamlString(value)
+	if err != nil {
+		return false, err
+	}
+	switch strings.ToLower(s) {
+	case "", "false", "no", "off":
+		return false, nil
+	case "true", "yes", "on":
+		return true, nil
+	default:
+		return false, fmt.Errorf("expected a boolean, got %q", s)
+	}
+}
@@ -0,0 +1,382 @@
+package tuner
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseYAML(t *testing.T) {
+	doc := `# vmware-tuner config
+roles:
+  db:
+    modules: [grub, sysctl, "io"]  # inline list
+  web:
+    modules:
+      - sysctl
+      - network
+items:
+- name: first
+  value: 'quoted # not a comment'
+- plain
+`
+	root, err := parseYAML([]byte(doc))
+	if err != nil {
+		t.Fatalf("parseYAML failed: %v", err)
+	}
+
+	roles := root["roles"].(map[string]interface{})
+	db := roles["db"].(map[string]interface{})
+	if got, _ := yamlStringList(db["modules"]); !reflect.DeepEqual(got, []string{"grub", "sysctl", "io"}) {
+		t.Errorf("db modules = %v", got)
+	}
+	web := roles["web"].(map[string]interface{})
+	if got, _ := yamlStringList(web["modules"]); !reflect.DeepEqual(got, []string{"sysctl", "network"}) {
+		t.Errorf("web modules = %v", got)
+	}
+
+	items := root["items"].([]interface{})
+	if len(items) != 2 {
+		t.Fatalf("expected 2 items, got %d", len(items))
+	}
+	first := items[0].(map[string]interface{})
+	if first["value"] != "quoted # not a comment" {
+		t.Errorf("first.value = %q", first["value"])
+	}
+	if items[1] != "plain" {
+		t.Errorf("items[1] = %v", items[1])
+	}
+}
+
+func TestParseYAML_Errors(t *testing.T) {
+	bad := []string{
+		"key: value\n   nested: oops\n",
+		"just a line without colon\n",
+		"roles:\n\t- tab\n",
+	}
+	for _, doc := range bad {
+		if _, err := parseYAML([]byte(doc)); err == nil {
+			t.Errorf("expected error for %q", doc)
+		}
+	}
+}
+
+func TestLoadConfig_Roles(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.yaml")
+
+	// Missing file yields an empty config
+	cfg, err := LoadConfig(path)
+	if err != nil || len(cfg.Roles) != 0 {
+		t.Fatalf("LoadConfig on missing file: %v, %v", cfg, err)
+	}
+
+	content := "roles:\n  db:\n    modules: [grub, sysctl]\n"
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err = LoadConfig(path)
+	if err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+
+	gate, err := NewRoleGate(cfg, "db", false)
+	if err != nil {
+		t.Fatalf("NewRoleGate failed: %v", err)
+	}
+	if !gate.Allows("grub") || gate.Allows("debloat") {
+		t.Errorf("unexpected gate decisions: %v", gate.Allowed)
+	}
+	if gate.Check("disk") == nil {
+		t.Error("disk should be refused without --override-role")
+	}
+	if _, err := NewRoleGate(cfg, "web", false); err == nil {
+		t.Error("unknown role should fail")
+	}
+
+	cfg.Roles["db"] = Role{Modules: []string{"grub", "sysclt"}}
+	if _, err := NewRoleGate(cfg, "db", false); err == nil || !strings.Contains(err.Error(), "sysclt") || !strings.Contains(err.Error(), "sysctl") {
+		t.Errorf("misspelled module: err = %v, want it named with the valid modules", err)
+	}
+}
+
+func TestLoadConfig_Syslog(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+
+	content := "syslog:\n  server: logs.example.com\n  tls: yes\n"
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+
+	sc := cfg.Syslog.withDefaults()
+	if sc.Server != "logs.example.com" || !sc.TLS || sc.Protocol != "tcp" || sc.Port != 6514 {
+		t.Errorf("unexpected syslog config: %+v", sc)
+	}
+
+	content = "syslog:\n  server: logs.example.com\n  protocol: udp\n  tls: true\n"
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadConfig(path); err == nil {
+		t.Error("expected an error for TLS over UDP")
+	}
+}
+
+func TestLoadConfig_Proxy(t *testing.T) {
+	for _, name := range []string{"HTTP_PROXY", "http_proxy", "HTTPS_PROXY", "https_proxy", "NO_PROXY", "no_proxy"} {
+		t.Setenv(name, "")
+	}
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	content := "proxy:\n  http: http://proxy.example.com:3128\n  no_proxy: .example.com,localhost\n"
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+
+	pc := cfg.Proxy.Resolve()
+	if pc.HTTP != "http://proxy.example.com:3128" || pc.HTTPS != pc.HTTP || pc.NoProxy != ".example.com,localhost" {
+		t.Errorf("unexpected proxy config: %+v", pc)
+	}
+}
+
+func TestSetINIValue(t *testing.T) {
+	conf := "[main]\ngpgcheck=1\ninstallonly_limit=3\n\n[other]\nproxy=keep\n"
+	got := setINIValue(conf, "main", "proxy", "http://p:3128")
+	want := "[main]\ngpgcheck=1\ninstallonly_limit=3\nproxy=http://p:3128\n\n[other]\nproxy=keep\n"
+	if got != want {
+		t.Errorf("insert:\n%s\nwant:\n%s", got, want)
+	}
+
+	got = setINIValue(want, "main", "proxy", "http://q:8080")
+	if want := "[main]\ngpgcheck=1\ninstallonly_limit=3\nproxy=http://q:8080\n\n[other]\nproxy=keep\n"; got != want {
+		t.Errorf("replace:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestLoadConfig_VCenter(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte("vcenter:\n  host: vc.example.com\n  port: 8443\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+	if cfg.VCenter.Host != "vc.example.com" || cfg.VCenter.Port != 8443 {
+		t.Errorf("unexpected vcenter config: %+v", cfg.VCenter)
+	}
+}
+
+func TestLoadConfig_Tuning(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	content := `tuning:
+  grub:
+    params: [elevator=none, clocksource=tsc]
+  sysctl:
+    vm.swappiness: 1
+    net.ipv4.tcp_rmem: 4096 131072 33554432
+    kernel.pid_max: 4194304
+  fstab:
+    options: [noatime]
+  network:
+    rx_ring: 2048
+    affinity: pin
+    interfaces: [ens192, "enp*"]
+  queue:
+    read_ahead_kb: 2048
+  debloat:
+    services: [cups, rpcbind]
+  backup_dir: /srv/tuner-backups
+`
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+
+	tc := cfg.Tuning
+	if len(tc.GrubParams) != 2 || tc.BackupDir != "/srv/tuner-backups" || len(tc.DebloatServices) != 2 {
+		t.Errorf("unexpected tuning config: %+v", tc)
+	}
+	if rx, tx := tc.Rings(); rx != 2048 || tx != 4096 {
+		t.Errorf("rings = %d/%d, want 2048/4096 (default tx)", rx, tx)
+	}
+	if tc.AffinityMode() != AffinityPin {
+		t.Errorf("affinity = %s, want pin", tc.AffinityMode())
+	}
+	if !reflect.DeepEqual(tc.Interfaces.Names, []string{"ens192", "enp*"}) || tc.Interfaces.Drivers != nil {
+		t.Errorf("interfaces = %+v, want the names and no driver", tc.Interfaces)
+	}
+	if qs := tc.QueueSettings(); qs != (QueueSettings{ReadAheadKB: 2048, NrRequests: 256, RqAffinity: 1}) {
+		t.Errorf("queue settings = %+v, want the read-ahead override and the defaults", qs)
+	}
+
+	sysctl := applySysctlOverrides(defaultSysctlConfig(), tc.Sysctl, "config.yaml")
+	for _, want := range []string{"\nvm.swappiness = 1\n", "\nnet.ipv4.tcp_rmem = 4096 131072 33554432\n", "\nkernel.pid_max = 4194304\n"} {
+		if !strings.Contains(sysctl, want) {
+			t.Errorf("sysctl config missing %q", strings.TrimSpace(want))
+		}
+	}
+	if strings.Contains(sysctl, "vm.swappiness = 10") {
+		t.Error("overridden default still present")
+	}
+
+	for _, bad := range []string{
+		"tuning:\n  network:\n    rx_ring: 8192\n",
+		"tuning:\n  queue:\n    rq_affinity: 3\n",
+		"tuning:\n  queue:\n    rq_affinity: 0\n",
+		"tuning:\n  queue:\n    read_ahead_kb: 0\n",
+		"tuning:\n  network:\n    affinity: balanced\n",
+		"tuning:\n  network:\n    interfaces: [\"ens 192\"]\n",
+		"tuning:\n  network:\n    drivers: [\"vmx*\"]\n",
+		"tuning:\n  backup_dir: backups\n",
+		"tuning:\n  sysctl:\n    \"vm swappiness\": 1\n",
+	} {
+		if err := os.WriteFile(path, []byte(bad), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := LoadConfig(path); err == nil {
+			t.Errorf("expected an error for %q", bad)
+		}
+	}
+}
+
+func TestLoadConfig_SysctlExclude(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	content := "tuning:\n  sysctl:\n    kernel.pid_max: 4194304\n  sysctl_exclude: [vm.swappiness, \"net.ipv4.tcp_*\"]\n"
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+
+	defer func(saved TuningConfig) { Tuning = saved }(Tuning)
+	Tuning = cfg.Tuning
+	sysctl := NewSysctlTuner(false).GetOptimalConfig()
+	for _, want := range []string{"\n# vm.swappiness = ", "\n# net.ipv4.tcp_congestion_control = bbr\n", "\nkernel.pid_max = 4194304\n", "\nvm.dirty_ratio = "} {
+		if !strings.Contains(sysctl, want) {
+			t.Errorf("sysctl config missing %q", strings.TrimSpace(want))
+		}
+	}
+	for _, line := range strings.Split(sysctl, "\n") {
+		if strings.HasPrefix(line, "vm.swappiness") || strings.HasPrefix(line, "net.ipv4.tcp_") {
+			t.Errorf("excluded key still set: %s", line)
+		}
+	}
+
+	for _, bad := range []string{
+		"tuning:\n  sysctl_exclude: [\"vm swappiness\"]\n",
+		"tuning:\n  sysctl:\n    vm.swappiness: 1\n  sysctl_exclude: [\"vm.*\"]\n",
+	} {
+		if err := os.WriteFile(path, []byte(bad), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := LoadConfig(path); err == nil {
+			t.Errorf("expected an error for %q", bad)
+		}
+	}
+}
+
+func TestLoadConfig_DebloatLists(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	content := "tuning:\n  debloat:\n    extra: [rpcbind, cups]\n    keep: [multipathd, \"snap*\"]\n"
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+
+	defer func(saved TuningConfig) { Tuning = saved }(Tuning)
+	Tuning = cfg.Tuning
+	var names []string
+	for _, svc := range bloatTargets() {
+		names = append(names, svc.Name)
+	}
+	got := strings.Join(names, ",")
+	if !strings.HasPrefix(got, "cups,") || !strings.HasSuffix(got, ",rpcbind") || strings.Count(got, "cups,") != 1 {
+		t.Errorf("targets = %s, want the defaults then rpcbind, cups once", got)
+	}
+	for _, kept := range []string{"multipathd", "snapd"} {
+		if strings.Contains(","+got+",", ","+kept+",") {
+			t.Errorf("%s is in debloat.keep but still a target: %s", kept, got)
+		}
+	}
+	if !Tuning.DebloatKept("multipathd.service") || Tuning.DebloatKept("cups") {
+		t.Error("DebloatKept should match names with or without .service, and only the kept ones")
+	}
+
+	for _, bad := range []string{
+		"tuning:\n  debloat:\n    extra: [rpcbind]\n    keep: [\"rpc*\"]\n",
+		"tuning:\n  debloat:\n    extra: [\"cups*\"]\n",
+		"tuning:\n  debloat:\n    keep: [\"cups browsed\"]\n",
+	} {
+		if err := os.WriteFile(path, []byte(bad), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := LoadConfig(path); err == nil {
+			t.Errorf("expected an error for %q", bad)
+		}
+	}
+}
+
+func TestFindSysctlConflicts(t *testing.T) {
+	root := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(root, "etc/sysctl.d"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{
+		"etc/sysctl.conf":                         "# tuned by hand\nvm.swappiness=60\nkernel.pid_max = 4194304\n",
+		"etc/sysctl.d/99-vmware-performance.conf": "vm.swappiness = 1\n",
+		"etc/sysctl.d/60-app.conf":                "-net.core.somaxconn = 4096\nvm.dirty_ratio = 10\n",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(root, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	st := NewSysctlTuner(false)
+	got := st.FindSysctlConflicts(root, "vm.swappiness = 10\nnet.core.somaxconn = 65535\nvm.dirty_ratio = 10\n")
+	want := []SysctlConflict{
+		{"net.core.somaxconn", "/etc/sysctl.d/60-app.conf", "4096", "65535"},
+		{"vm.swappiness", "/etc/sysctl.conf", "60", "10"},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %+v, want %+v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("conflict %d = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestOptimizeEntry_MountOptions(t *testing.T) {
+	ft := NewFstabTuner(false)
+	entry := FstabEntry{FSType: "ext4", MountPoint: "/", Options: []string{"defaults", "commit=30", "discard"}}
+	if !ft.OptimizeEntry(&entry) {
+		t.Fatal("expected the entry to be modified")
+	}
+	opts := strings.Join(entry.Options, ",")
+	if strings.Contains(opts, "commit=60") || !strings.Contains(opts, "commit=30") {
+		t.Errorf("existing commit= must be kept: %s", opts)
+	}
+	if strings.Contains(opts, "discard") || !strings.Contains(opts, "noatime") {
+		t.Errorf("unexpected options: %s", opts)
+	}
+}
@@ -0,0 +1,142 @@
+package tuner
+
+import (
+	"fmt"
+)
+
+// CPUTopology is the vCPU layout the guest sees, from sysfs
+type CPUTopology struct {
+	CPUs           int      `json:"cpus"`
+	Sockets        int      `json:"sockets"`
+	CoresPerSocket int      `json:"cores_per_socket"`
+	ThreadsPerCore int      `json:"threads_per_core"`
+	NUMANodes      int      `json:"numa_nodes"`
+	Findings       []string `json:"findings,omitempty"`
+}
+
+// Known reports whether sysfs gave the layout
+func (t CPUTopology) Known() bool {
+	return t.Sockets > 0 && t.CoresPerSocket > 0
+}
+
+// String prints the layout as vSphere shows it
+func (t CPUTopology) String() string {
+	s := fmt.Sprintf("%d vCPU: %d socket(s) x %d core(s)", t.CPUs, t.Sockets, t.CoresPerSocket)
+	if t.ThreadsPerCore > 1 {
+		s += fmt.Sprintf(" x %d thread(s)", t.ThreadsPerCore)
+	}
+	if t.NUMANodes > 0 {
+		s += fmt.Sprintf(", %d NUMA node(s)", t.NUMANodes)
+	}
+	return s
+}
+
+// readCPUTopology counts the sockets, cores and threads of the present
+// vCPUs (physical_package_id, core_id) and the NUMA nodes. The sysfs files
+// read the same on every guest, unlike the translated labels of lscpu.
+func readCPUTopology(sys SysFS) (CPUTopology, []int, error) {
+	var t CPUTopology
+	data, err := sys.ReadString("/sys/devices/system/cpu/present")
+	if err != nil {
+		return t, nil, fmt.Errorf("failed to read the vCPUs: %w", err)
+	}
+	present, err := parseCPURanges(data)
+	if err != nil {
+		return t, nil, err
+	}
+	t.CPUs = len(present)
+
+	packages := make(map[string]bool)
+	cores := make(map[string]bool)
+	for _, cpu := range present {
+		topology := fmt.Sprintf("/sys/devices/system/cpu/cpu%d/topology", cpu)
+		pkg, err := sys.ReadString(topology, "physical_package_id")
+		if err != nil {
+			continue
+		}
+		core, _ := sys.ReadString(topology, "core_id")
+		packages[pkg] = true
+		cores[pkg+"/"+core] = true
+	}
+	if len(packages) > 0 {
+		t.Sockets = len(packages)
+		t.CoresPerSocket = len(cores) / len(packages)
+		t.ThreadsPerCore = t.CPUs / len(cores)
+	}
+	// A kernel without CONFIG_NUMA has no node directory
+	t.NUMANodes = len(sys.Glob("/sys/devices/system/node/node[0-9]*"))
+	return t, present, nil
+}
+
+// ReadCPUTopology reads the vCPU layout and flags the pathological ones.
+// fsRoot allows running against a fixture tree.
+func ReadCPUTopology(fsRoot string) CPUTopology {
+	t, _, err := readCPUTopology(SysFS{Root: fsRoot})
+	if err != nil {
+		return CPUTopology{}
+	}
+	t.Findings = topologyFindings(t)
+	return t
+}
+
+// topologyFindings flags the layouts that cost performance. Many one-core
+// sockets come from the "cores per socket = 1" default of older vSphere
+// releases: the guest scheduler sees packages sharing no cache, and per
+// socket licenses count each vCPU.
+func topologyFindings(t CPUTopology) []string {
+	if !t.Known() {
+		return nil
+	}
+	var findings []string
+	switch {
+	case t.Sockets > 1 && t.CoresPerSocket == 1 && t.CPUs >= 4:
+		findings = append(findings, fmt.Sprintf("%d sockets x 1 core: set Cores per socket in the VM CPU settings so the sockets match the host NUMA nodes the VM spans (usually 1 socket, 2 for a VM wider than one NUMA node; automatic on vSphere 8 with hardware version 20)", t.Sockets))
+	case t.Sockets > 2 && t.CoresPerSocket < t.Sockets:
+		findings = append(findings, fmt.Sprintf("%d sockets x %d cores: more sockets than cores per socket, fewer wider sockets match the host NUMA layout better", t.Sockets, t.CoresPerSocket))
+	}
+	if t.NUMANodes > 1 && t.Sockets%t.NUMANodes != 0 && t.NUMANodes%t.Sockets != 0 {
+		finding := fmt.Sprintf("%d sockets on %d NUMA nodes: the sockets do not line up with the virtual NUMA nodes (check numa.vcpu.maxPerVirtualNode and Cores per socket)", t.Sockets, t.NUMANodes)
+		if t.CPUs%t.NUMANodes == 0 {
+			finding += fmt.Sprintf("; Cores per socket: %d gives one socket per node", t.CPUs/t.NUMANodes)
+		}
+		findings = append(findings, finding)
+	}
+	if t.ThreadsPerCore > 1 {
+		findings = append(findings, fmt.Sprintf("%d threads per core: hyper-threads exposed to the guest (vSphere 8 virtual hyper-threading or a desktop hypervisor); sibling vCPUs share a physical core", t.ThreadsPerCore))
+	}
+	return findings
+}
+
+// printCPUTopology prints the layout and its findings
+func printCPUTopology(t CPUTopology) {
+	if !t.Known() {
+		PrintInfo("CPU topology unknown (/sys/devices/system/cpu not readable)")
+		return
+	}
+	if len(t.Findings) == 0 {
+		PrintSuccess("CPU topology: %s", t)
+		return
+	}
+	PrintWarning("CPU topology: %s", t)
+	for _, f := range t.Findings {
+		fmt.Printf("    - %s\n", f)
+	}
+}
+
+// auditCPUTopology reports the layout. Not scored: it is a VM setting the
+// guest cannot change.
+func auditCPUTopology(t CPUTopology) AuditItem {
+	item := AuditItem{Name: "cpu-topology"}
+	switch {
+	case !t.Known():
+		item.Status, item.Message = AuditWarn, "CPU topology unknown (/sys/devices/system/cpu not readable)"
+	case len(t.Findings) > 0:
+		item.Status, item.Message = AuditWarn, "CPU topology to review: "+t.String()
+		for _, f := range t.Findings {
+			item.Details = append(item.Details, "- "+f)
+		}
+	default:
+		item.Status, item.Message = AuditOK, "CPU topology: "+t.String()
+	}
+	return item
+}
@@ -0,0 +1,67 @@
+package tuner
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// topologyFixture builds /sys/devices/system for cpus vCPUs on sockets
+// packages of threads siblings per core, and nodes NUMA nodes
+func topologyFixture(t *testing.T, cpus, sockets, threads, nodes int) string {
+	root := t.TempDir()
+	files := map[string]string{"/sys/devices/system/cpu/present": fmt.Sprintf("0-%d\n", cpus-1)}
+	perSocket := cpus / sockets
+	for cpu := 0; cpu < cpus; cpu++ {
+		dir := fmt.Sprintf("/sys/devices/system/cpu/cpu%d/topology/", cpu)
+		files[dir+"physical_package_id"] = fmt.Sprintf("%d\n", cpu/perSocket)
+		files[dir+"core_id"] = fmt.Sprintf("%d\n", cpu%perSocket/threads)
+	}
+	for n := 0; n < nodes; n++ {
+		files[fmt.Sprintf("/sys/devices/system/node/node%d/cpulist", n)] = "\n"
+	}
+	writeFiles(t, root, files)
+	return root
+}
+
+func TestCPUTopology(t *testing.T) {
+	tests := []struct {
+		name     string
+		root     string
+		findings []string // substrings, one per finding
+	}{
+		{"one socket", topologyFixture(t, 8, 1, 1, 1), nil},
+		{"two NUMA sockets", topologyFixture(t, 32, 2, 1, 2), nil},
+		{"16 sockets x 1 core", topologyFixture(t, 16, 16, 1, 1), []string{"16 sockets x 1 core"}},
+		{"8 sockets x 2 cores", topologyFixture(t, 16, 8, 1, 1), []string{"more sockets than cores"}},
+		{"sockets across NUMA nodes", topologyFixture(t, 24, 3, 1, 2), []string{"do not line up with the virtual NUMA nodes (check numa.vcpu.maxPerVirtualNode and Cores per socket); Cores per socket: 12"}},
+		{"hyper-threads", topologyFixture(t, 8, 1, 2, 1), []string{"hyper-threads"}},
+		{"2 sockets x 1 core", topologyFixture(t, 2, 2, 1, 1), nil},
+	}
+	for _, tt := range tests {
+		topo := ReadCPUTopology(tt.root)
+		if !topo.Known() {
+			t.Errorf("%s: topology not read", tt.name)
+			continue
+		}
+		if len(topo.Findings) != len(tt.findings) {
+			t.Errorf("%s: findings %q, want %d", tt.name, topo.Findings, len(tt.findings))
+			continue
+		}
+		for i, want := range tt.findings {
+			if !strings.Contains(topo.Findings[i], want) {
+				t.Errorf("%s: finding %q, want %q", tt.name, topo.Findings[i], want)
+			}
+		}
+		if item := auditCPUTopology(topo); (item.Status == AuditOK) != (len(tt.findings) == 0) || item.Max != 0 {
+			t.Errorf("%s: audit item %+v", tt.name, item)
+		}
+	}
+
+	if topo := ReadCPUTopology(topologyFixture(t, 8, 1, 2, 1)); topo.CoresPerSocket != 4 || topo.ThreadsPerCore != 2 {
+		t.Errorf("topology = %s, want 1 socket x 4 cores x 2 threads", topo)
+	}
+	if topo := ReadCPUTopology(t.TempDir()); topo.Known() {
+		t.Errorf("topology without sysfs = %+v", topo)
+	}
+}
@@ -14,12 +14,26 @@ func NewCronTuner() *CronTuner {
 	return &CronTuner{}
 }
 
+func init() {
+	Register(Module{
+		Name:        "cron",
+		Label:       "Schedule Maintenance",
+		Description: "Schedule the nightly cleanup and time resynchronization",
+		Category:    CategoryMaintenance,
+		Menu:        8,
+		RequireRoot: true,
+		Run: func(ctx *ModuleContext) error {
+			return NewCronTuner().Run()
+		},
+	})
+}
+
 // Run configures the schedule
 func (ct *CronTuner) Run() error {
 	PrintStep("Schedule Maintenance")
 
 	cronFile := "/etc/cron.d/vmware-tuner"
-	
+
 	// Check if already scheduled
 	if _, err := os.Stat(cronFile); err == nil {
 		PrintInfo("Maintenance is currently SCHEDULED.")
@@ -39,7 +53,7 @@ func (ct *CronTuner) Run() error {
 	PrintInfo("  - Weekly System Cleaning (Sunday 05:00 AM)")
 	fmt.Println()
 	fmt.Print("Enable this schedule? (y/n): ")
-	
+
 	var resp string
 	fmt.Scanln(&resp)
 	if resp != "y" {
@@ -47,16 +61,9 @@ func (ct *CronTuner) Run() error {
 		return nil
 	}
 
-	// Get absolute path of current binary
-	binPath, err := os.Executable()
-	if err != nil {
-		return fmt.Errorf("failed to get binary path: %w", err)
-	}
-	
 	// Verify binary is in a good location
-	if filepath.Dir(binPath) == "/tmp" || filepath.Dir(binPath) == "/var/tmp" {
-		PrintWarning("Running from temporary directory!")
-		PrintWarning("Please move 'vmware-tuner' to /usr/local/bin/ first.")
+	if _, err := schedulableBinary(); err != nil {
+		PrintWarning("%v", err)
 		return nil
 	}
 
@@ -65,7 +72,7 @@ func (ct *CronTuner) Run() error {
 	// Actually, since our tool is interactive, we should probably just schedule the raw commands for safety/simplicity
 	// OR we assume the user will run the tool with flags.
 	// Let's use raw commands for reliability, as the tool might move.
-	
+
 	content := `# VMware Tuner Maintenance
 # Generated by vmware-tuner
 
@@ -85,3 +92,20 @@ func (ct *CronTuner) Run() error {
 
 	return nil
 }
+
+// defaultBinaryPath is where the binary is installed (see README)
+const defaultBinaryPath = "/usr/local/bin/vmware-tuner"
+
+// schedulableBinary returns the path of the running binary for cron jobs.
+// Binaries in temporary directories are refused.
+func schedulableBinary() (string, error) {
+	binPath, err := os.Executable()
+	if err != nil {
+		return "", fmt.Errorf("failed to get binary path: %w", err)
+	}
+
+	if filepath.Dir(binPath) == "/tmp" || filepath.Dir(binPath) == "/var/tmp" {
+		return "", fmt.Errorf("running from a temporary directory, please move 'vmware-tuner' to /usr/local/bin/ first")
+	}
+	return binPath, nil
+}
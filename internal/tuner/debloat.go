@@ -3,11 +3,16 @@ package tuner
 import (
 	"fmt"
 	"os/exec"
+	"path/filepath"
+	"strings"
 )
 
 // DebloatTuner handles disabling unnecessary services
 type DebloatTuner struct {
 	DryRun bool
+	FSRoot string // root of the tree read to detect a desktop, "" for /
+
+	desktop *DesktopSession // detected on first use
 }
 
 // NewDebloatTuner creates a new debloat tuner
@@ -17,6 +22,47 @@ func NewDebloatTuner(dryRun bool) *DebloatTuner {
 	}
 }
 
+func init() {
+	Register(Module{
+		Name:        "debloat",
+		Description: "Server Slim (disable unused services)",
+		Category:    CategoryTuning,
+		Pipeline:    7,
+		RequireRoot: true,
+		Flag:        &ModuleFlag{Name: "debloat", Usage: "Disable unnecessary services (Server Slim)"},
+		Apply: func(ctx *ModuleContext) error {
+			return NewDebloatTuner(ctx.DryRun).Apply(ctx.Backup)
+		},
+		Offer: offerDebloat,
+	})
+}
+
+// offerDebloat lists the Server Slim candidates and disables them when the
+// user agrees
+func offerDebloat(ctx *ModuleContext) (bool, error) {
+	debloat := NewDebloatTuner(ctx.DryRun)
+	services := debloat.GetBloatServices()
+	if len(services) == 0 {
+		return false, nil
+	}
+	PrintStep("Server Slim Mode (Optional)")
+	printDebloatKeep()
+	PrintInfo("Found %d services that are usually unnecessary on servers:", len(services))
+	for _, svc := range services {
+		fmt.Printf("  - %s: %s\n", svc.Name, svc.Description)
+	}
+	debloat.printDesktopNote(services)
+	fmt.Println()
+	fmt.Print("Do you want to disable these services? (y/n): ")
+	var response string
+	fmt.Scanln(&response)
+	if response != "y" && response != "yes" {
+		PrintInfo("Skipping Server Slim optimization")
+		return false, nil
+	}
+	return true, debloat.DisableServices(services, ctx.Backup)
+}
+
 // Service represents a system service
 type Service struct {
 	Name        string
@@ -24,20 +70,86 @@ type Service struct {
 	Active      bool
 }
 
+// riskyServices are bloat candidates that can break production workloads
+// (multipathd is required on SAN/RDM-backed VMs)
+var riskyServices = map[string]bool{
+	"multipathd": true,
+}
+
+// desktopServices are the candidates a desktop session uses (printing,
+// discovery, Bluetooth, Wi-Fi): on a workstation VM they are skipped unless
+// confirmed one by one
+var desktopServices = map[string]bool{
+	"cups":           true,
+	"cups-browsed":   true,
+	"avahi-daemon":   true,
+	"bluetooth":      true,
+	"wpa_supplicant": true,
+	"modemmanager":   true,
+}
+
+// defaultBloatServices are the Server Slim candidates
+var defaultBloatServices = []Service{
+	{Name: "cups", Description: "Printing service (CUPS)"},
+	{Name: "cups-browsed", Description: "Printer discovery"},
+	{Name: "avahi-daemon", Description: "mDNS/DNS-SD (Avahi)"},
+	{Name: "bluetooth", Description: "Bluetooth service"},
+	{Name: "wpa_supplicant", Description: "Wi-Fi security (WPA)"},
+	{Name: "modemmanager", Description: "Modem Manager"},
+	{Name: "snapd", Description: "Snap Package Manager (consumes loop devices)"},
+	{Name: "lxcfs", Description: "LXC File System (if not using containers)"},
+	{Name: "multipathd", Description: "Multipath Device Daemon (unless using SAN)"},
+}
+
+// bloatTargets returns the candidates: tuning.debloat.services of the
+// config file replaces the default list, debloat.extra adds to it and the
+// services of debloat.keep are left out
+func bloatTargets() []Service {
+	var targets []Service
+	if len(Tuning.DebloatServices) == 0 {
+		targets = append(targets, defaultBloatServices...)
+	}
+	for _, name := range append(append([]string{}, Tuning.DebloatServices...), Tuning.DebloatExtra...) {
+		svc := Service{Name: name, Description: "Listed in config.yaml"}
+		for _, known := range defaultBloatServices {
+			if known.Name == name {
+				svc.Description = known.Description
+			}
+		}
+		if !containsService(targets, name) {
+			targets = append(targets, svc)
+		}
+	}
+
+	var kept []Service
+	for _, svc := range targets {
+		if !Tuning.DebloatKept(svc.Name) {
+			kept = append(kept, svc)
+		}
+	}
+	return kept
+}
+
+// printDebloatKeep lists the services the config file protects
+func printDebloatKeep() {
+	if len(Tuning.DebloatKeep) > 0 {
+		PrintInfo("Never disabled (debloat.keep): %s", strings.Join(Tuning.DebloatKeep, ", "))
+	}
+}
+
+// containsService reports whether a service is in the list
+func containsService(services []Service, name string) bool {
+	for _, svc := range services {
+		if svc.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
 // GetBloatServices returns a list of potentially unnecessary services
 func (dt *DebloatTuner) GetBloatServices() []Service {
-	// List of services to check
-	targets := []Service{
-		{Name: "cups", Description: "Printing service (CUPS)"},
-		{Name: "cups-browsed", Description: "Printer discovery"},
-		{Name: "avahi-daemon", Description: "mDNS/DNS-SD (Avahi)"},
-		{Name: "bluetooth", Description: "Bluetooth service"},
-		{Name: "wpa_supplicant", Description: "Wi-Fi security (WPA)"},
-		{Name: "modemmanager", Description: "Modem Manager"},
-		{Name: "snapd", Description: "Snap Package Manager (consumes loop devices)"},
-		{Name: "lxcfs", Description: "LXC File System (if not using containers)"},
-		{Name: "multipathd", Description: "Multipath Device Daemon (unless using SAN)"},
-	}
+	targets := bloatTargets()
 
 	var found []Service
 	for _, svc := range targets {
@@ -60,6 +172,7 @@ func (dt *DebloatTuner) isServiceActive(name string) bool {
 // Apply disables the identified services
 func (dt *DebloatTuner) Apply(backup *BackupManager) error {
 	PrintStep("Checking for unnecessary services (Server Slim Mode)")
+	printDebloatKeep()
 
 	services := dt.GetBloatServices()
 	if len(services) == 0 {
@@ -72,67 +185,203 @@ func (dt *DebloatTuner) Apply(backup *BackupManager) error {
 		fmt.Printf("  - %s: %s\n", svc.Name, svc.Description)
 	}
 
-	if dt.DryRun {
-		PrintInfo("Would disable these services")
-		return nil
-	}
+	dt.printDesktopNote(services)
+	return dt.run(dt.Plan(services), backup)
+}
 
-	// Ask for confirmation if not already confirmed in main
-	// For now, we assume the user opted-in via flag or interactive prompt in main
+// DisableServices disables a specific list of services
+func (dt *DebloatTuner) DisableServices(services []Service, backup *BackupManager) error {
+	return dt.run(dt.Plan(services), backup)
+}
 
-	// Backup services first
-	var serviceNames []string
-	for _, svc := range services {
-		serviceNames = append(serviceNames, svc.Name)
-	}
-	if err := backup.BackupServices(serviceNames); err != nil {
-		PrintWarning("Failed to backup service list: %v", err)
-	}
+// debloatAction is what the plan does with a service
+type debloatAction int
 
+const (
+	debloatDisable debloatAction = iota // disabled
+	debloatAsk                          // desktop service, confirmed one by one
+	debloatGuard                        // risky service, behind the production guard
+	debloatSkip                         // left running
+)
+
+// debloatStep is the decision taken for one service
+type debloatStep struct {
+	Service Service
+	Action  debloatAction
+	Reason  string
+}
+
+// Plan decides once what happens to each service (debloat.keep, exclusions,
+// desktop session, production guard): the dry run prints this plan and the
+// real run follows it
+func (dt *DebloatTuner) Plan(services []Service) []debloatStep {
+	plan := make([]debloatStep, 0, len(services))
 	for _, svc := range services {
-		PrintInfo("Disabling %s...", svc.Name)
-		
-		// Stop
-		exec.Command("systemctl", "stop", svc.Name).Run()
-		
-		// Disable
-		if err := exec.Command("systemctl", "disable", svc.Name).Run(); err != nil {
-			PrintWarning("Failed to disable %s: %v", svc.Name, err)
-		} else {
-			PrintSuccess("Disabled %s", svc.Name)
+		step := debloatStep{Service: svc, Action: debloatDisable}
+		switch {
+		case Tuning.DebloatKept(svc.Name):
+			step.Action, step.Reason = debloatSkip, "listed in debloat.keep"
+		case SkipExcluded("debloat", ExcludeService, svc.Name):
+			step.Action = debloatSkip
+		case desktopServices[svc.Name] && dt.onDesktop() && !isInteractive():
+			step.Action = debloatSkip
+			step.Reason = fmt.Sprintf("desktop session (%s), run interactively to confirm", dt.desktop)
+		case desktopServices[svc.Name] && dt.onDesktop():
+			step.Action, step.Reason = debloatAsk, fmt.Sprintf("desktop session (%s)", dt.desktop)
+		case riskyServices[svc.Name]:
+			step.Action, step.Reason = debloatGuard, "production guard"
 		}
+		plan = append(plan, step)
 	}
+	return plan
+}
 
+// run carries out the plan, or prints it in dry run
+func (dt *DebloatTuner) run(plan []debloatStep, backup *BackupManager) error {
+	for _, step := range plan {
+		name := step.Service.Name
+		if dt.DryRun {
+			switch step.Action {
+			case debloatDisable:
+				PrintInfo("Would disable %s", name)
+			case debloatAsk:
+				PrintInfo("Would ask before disabling %s: %s", name, step.Reason)
+			case debloatGuard:
+				PrintInfo("Would disable %s after the %s", name, step.Reason)
+			case debloatSkip:
+				if step.Reason != "" {
+					PrintInfo("Would skip %s: %s", name, step.Reason)
+				}
+			}
+			continue
+		}
+
+		switch step.Action {
+		case debloatSkip:
+			if step.Reason != "" {
+				PrintInfo("Skipping %s: %s", name, step.Reason)
+			}
+			continue
+		case debloatAsk:
+			if !dt.confirmDesktop(name) {
+				continue
+			}
+		case debloatGuard:
+			if err := GuardDestructive("Disable " + name); err != nil {
+				PrintWarning("Skipping %s: %v", name, err)
+				continue
+			}
+		}
+		if err := dt.disable(step.Service, backup); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
-// DisableServices disables a specific list of services
-func (dt *DebloatTuner) DisableServices(services []Service, backup *BackupManager) error {
-	// Backup services first
-	var serviceNames []string
-	for _, svc := range services {
-		serviceNames = append(serviceNames, svc.Name)
+// disable stops a service and keeps it from starting at boot
+func (dt *DebloatTuner) disable(svc Service, backup *BackupManager) error {
+	// Recorded first: debloat undo enables it again
+	if err := backup.BackupServices([]string{svc.Name}); err != nil {
+		return fmt.Errorf("failed to backup service %s: %w", svc.Name, err)
 	}
-	if err := backup.BackupServices(serviceNames); err != nil {
-		PrintWarning("Failed to backup service list: %v", err)
+
+	PrintInfo("Disabling %s...", svc.Name)
+
+	// Stop
+	ExplainCommand(svc.Description+": not needed on a server VM", "systemctl", "stop", svc.Name)
+	exec.Command("systemctl", "stop", svc.Name).Run()
+
+	// Disable
+	ExplainCommand("keep "+svc.Name+" from starting at boot", "systemctl", "disable", svc.Name)
+	if err := exec.Command("systemctl", "disable", svc.Name).Run(); err != nil {
+		PrintWarning("Failed to disable %s: %v", svc.Name, err)
+	} else {
+		PrintSuccess("Disabled %s", svc.Name)
 	}
+	return nil
+}
 
-	for _, svc := range services {
-		PrintInfo("Disabling %s...", svc.Name)
-		
+// Undo puts the services disabled by a backup session back in their recorded
+// state, the most recent session that changed services when timestamp is empty
+func (dt *DebloatTuner) Undo(timestamp string) error {
+	PrintStep("Restoring the services disabled by Server Slim")
+
+	var services []ServiceState
+	if timestamp == "" {
+		var err error
+		if timestamp, services, err = LatestServiceStates(); err != nil {
+			return err
+		}
+		if timestamp == "" {
+			return fmt.Errorf("no backup session disabled services")
+		}
+	} else {
+		manifest, err := LoadManifest(filepath.Join(BackupRoot, timestamp))
+		if err != nil {
+			return fmt.Errorf("backup %s: %w", timestamp, err)
+		}
+		if len(manifest.Services) == 0 {
+			return fmt.Errorf("backup %s did not disable services", timestamp)
+		}
+		services = manifest.Services
+	}
+	PrintInfo("Backup %s: %d service(s)", timestamp, len(services))
+
+	failed := 0
+	for _, state := range services {
 		if dt.DryRun {
+			PrintInfo("Would restore %s (%s)", state.Unit, state)
 			continue
 		}
-		
-		// Stop
-		exec.Command("systemctl", "stop", svc.Name).Run()
-		
-		// Disable
-		if err := exec.Command("systemctl", "disable", svc.Name).Run(); err != nil {
-			PrintWarning("Failed to disable %s: %v", svc.Name, err)
-		} else {
-			PrintSuccess("Disabled %s", svc.Name)
+		if err := RestoreServiceState(state); err != nil {
+			PrintWarning("%v", err)
+			failed++
+			continue
 		}
+		PrintSuccess("Restored %s (%s)", state.Unit, state)
+	}
+	if failed > 0 {
+		return fmt.Errorf("failed to restore %d service(s)", failed)
 	}
 	return nil
 }
+
+// onDesktop reports whether a display manager or a graphical session was found
+func (dt *DebloatTuner) onDesktop() bool {
+	if dt.desktop == nil {
+		desktop := DetectDesktop(dt.FSRoot)
+		dt.desktop = &desktop
+	}
+	return dt.desktop.Detected()
+}
+
+// printDesktopNote warns that the desktop services of the list need a
+// confirmation of their own
+func (dt *DebloatTuner) printDesktopNote(services []Service) {
+	if !dt.onDesktop() {
+		return
+	}
+	var names []string
+	for _, svc := range services {
+		if desktopServices[svc.Name] {
+			names = append(names, svc.Name)
+		}
+	}
+	if len(names) > 0 {
+		PrintWarning("Desktop session detected (%s)", dt.desktop)
+		PrintWarning("Used by the desktop, confirmed one by one: %s", strings.Join(names, ", "))
+	}
+}
+
+// confirmDesktop asks before disabling a service a desktop session uses
+func (dt *DebloatTuner) confirmDesktop(name string) bool {
+	fmt.Printf("%s is used by the desktop session (%s). Disable it anyway? (y/N): ", name, dt.desktop)
+	var response string
+	fmt.Scanln(&response)
+	if response != "y" && response != "yes" {
+		PrintInfo("Keeping %s", name)
+		return false
+	}
+	return true
+}
@@ -0,0 +1,52 @@
+package tuner
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDebloatPlan(t *testing.T) {
+	savedTuning, savedExcluded := Tuning, Excluded
+	defer func() { Tuning, Excluded = savedTuning, savedExcluded }()
+	defer TakeExclusionSkips()
+	Tuning = TuningConfig{DebloatKeep: []string{"snapd"}}
+	Excluded = Exclusions{Path: DefaultExclusionsPath, Services: []string{"lxcfs"}}
+
+	root := t.TempDir()
+	unitDir := filepath.Join(root, "etc/systemd/system")
+	os.MkdirAll(unitDir, 0755)
+	os.Symlink("/usr/lib/systemd/system/gdm.service", filepath.Join(unitDir, "display-manager.service"))
+
+	dt := NewDebloatTuner(true)
+	dt.FSRoot = root
+	plan := dt.Plan([]Service{{Name: "cups"}, {Name: "snapd"}, {Name: "lxcfs"}, {Name: "multipathd"}, {Name: "modemmanager"}})
+
+	want := map[string]debloatAction{
+		"snapd":      debloatSkip,
+		"lxcfs":      debloatSkip,
+		"multipathd": debloatGuard,
+	}
+	// Desktop services are skipped without a terminal, asked otherwise
+	desktopAction := debloatSkip
+	if isInteractive() {
+		desktopAction = debloatAsk
+	}
+	want["cups"], want["modemmanager"] = desktopAction, desktopAction
+
+	if len(plan) != len(want) {
+		t.Fatalf("plan has %d steps, want %d", len(plan), len(want))
+	}
+	for _, step := range plan {
+		if step.Action != want[step.Service.Name] {
+			t.Errorf("%s: action = %d, want %d", step.Service.Name, step.Action, want[step.Service.Name])
+		}
+	}
+
+	dt.desktop = &DesktopSession{}
+	for _, step := range dt.Plan([]Service{{Name: "cups"}}) {
+		if step.Action != debloatDisable {
+			t.Errorf("cups without a desktop: action = %d, want disable", step.Action)
+		}
+	}
+}
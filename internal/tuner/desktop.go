@@ -0,0 +1,72 @@
+package tuner
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// DesktopSession describes the graphical environment found on the VM
+type DesktopSession struct {
+	DisplayManager string   // unit behind display-manager.service, "" when none
+	Sessions       []string // types of the graphical login sessions (x11, wayland)
+}
+
+// Detected reports whether the VM is used as a workstation
+func (d DesktopSession) Detected() bool {
+	return d.DisplayManager != "" || len(d.Sessions) > 0
+}
+
+// String describes what was found, for the messages
+func (d DesktopSession) String() string {
+	var parts []string
+	if d.DisplayManager != "" {
+		parts = append(parts, "display manager "+d.DisplayManager)
+	}
+	if len(d.Sessions) > 0 {
+		parts = append(parts, fmt.Sprintf("%d graphical session(s): %s", len(d.Sessions), strings.Join(d.Sessions, ", ")))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// graphicalSessionTypes are the logind session types of a desktop
+var graphicalSessionTypes = map[string]bool{"x11": true, "wayland": true, "mir": true}
+
+// DetectDesktop looks for an enabled display manager and for graphical
+// sessions registered by logind. fsRoot allows running against a fixture tree.
+func DetectDesktop(fsRoot string) DesktopSession {
+	var desktop DesktopSession
+	if target, err := os.Readlink(filepath.Join(fsRoot, "/etc/systemd/system/display-manager.service")); err == nil {
+		desktop.DisplayManager = strings.TrimSuffix(filepath.Base(target), ".service")
+	}
+
+	sessionDir := filepath.Join(fsRoot, "/run/systemd/sessions")
+	entries, _ := os.ReadDir(sessionDir)
+	for _, entry := range entries {
+		if entry.IsDir() || strings.Contains(entry.Name(), ".") {
+			continue // .ref pipes
+		}
+		if kind := sessionType(filepath.Join(sessionDir, entry.Name())); graphicalSessionTypes[kind] {
+			desktop.Sessions = append(desktop.Sessions, kind)
+		}
+	}
+	return desktop
+}
+
+// sessionType reads the TYPE of a logind session file
+func sessionType(path string) string {
+	f, err := os.Open(path)
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if line := scanner.Text(); strings.HasPrefix(line, "TYPE=") {
+			return strings.TrimPrefix(line, "TYPE=")
+		}
+	}
+	return ""
+}
@@ -0,0 +1,42 @@
+package tuner
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDetectDesktop(t *testing.T) {
+	root := t.TempDir()
+	if desktop := DetectDesktop(root); desktop.Detected() {
+		t.Fatalf("desktop detected in an empty tree: %s", desktop)
+	}
+
+	unitDir := filepath.Join(root, "etc/systemd/system")
+	sessionDir := filepath.Join(root, "run/systemd/sessions")
+	for _, dir := range []string{unitDir, sessionDir} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Symlink("/lib/systemd/system/gdm3.service", filepath.Join(unitDir, "display-manager.service")); err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range map[string]string{
+		"2":     "UID=1000\nUSER=dev\nTYPE=wayland\nACTIVE=1\n",
+		"3":     "UID=0\nUSER=root\nTYPE=tty\n",
+		"2.ref": "",
+	} {
+		if err := os.WriteFile(filepath.Join(sessionDir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	desktop := DetectDesktop(root)
+	if desktop.DisplayManager != "gdm3" || len(desktop.Sessions) != 1 || desktop.Sessions[0] != "wayland" {
+		t.Errorf("desktop = %+v, want gdm3 and one wayland session", desktop)
+	}
+	if got, want := desktop.String(), "display manager gdm3, 1 graphical session(s): wayland"; got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+}
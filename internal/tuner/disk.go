@@ -20,6 +20,20 @@ func NewDiskTuner(distro *DistroManager) *DiskTuner {
 	}
 }
 
+func init() {
+	Register(Module{
+		Name:        "disk",
+		Label:       "Expand Disk",
+		Description: "Grow the root partition and filesystem after the disk was enlarged",
+		Category:    CategoryMaintenance,
+		Menu:        4,
+		RequireRoot: true,
+		Run: func(ctx *ModuleContext) error {
+			return NewDiskTuner(ctx.Distro).ExpandRoot(ctx.HasInternet)
+		},
+	})
+}
+
 // BlockDevice represents a block device from lsblk JSON
 type BlockDevice struct {
 	Name       string        `json:"name"`
@@ -46,12 +60,16 @@ func (dt *DiskTuner) ExpandRoot(hasInternet bool) error {
 		return nil
 	}
 
+	if err := GuardDestructive("Expand Disk"); err != nil {
+		return err
+	}
+
 	// 1. Check/Install dependencies (growpart)
 	if _, err := exec.LookPath("growpart"); err != nil {
 		PrintWarning("Outil 'growpart' manquant.")
 
-		if !hasInternet {
-			return fmt.Errorf("impossible d'installer 'growpart' en mode Hors-Ligne. Veuillez l'installer manuellement (cloud-guest-utils)")
+		if !dt.Distro.HasPackageSource("cloud-guest-utils", hasInternet) && !dt.Distro.HasPackageSource("cloud-utils-growpart", hasInternet) {
+			return fmt.Errorf("impossible d'installer 'growpart' en mode Hors-Ligne. Veuillez l'installer manuellement (cloud-guest-utils) ou utiliser --pkg-dir: %w", ErrOffline)
 		}
 
 		PrintInfo("Tentative d'installation...")
@@ -134,7 +152,7 @@ func (dt *DiskTuner) ExpandRoot(hasInternet bool) error {
 	PrintSuccess("Système de fichiers étendu avec succès !")
 
 	// Show new size
-	exec.Command("df", "-h", "/").Run()
+	PrintFilesystemUsage("/")
 
 	return nil
 }
@@ -0,0 +1,316 @@
+package tuner
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// DiskBenchOptions configures the native disk benchmark
+type DiskBenchOptions struct {
+	Dir        string        // directory of the test file
+	FileSize   int64         // bytes
+	Runtime    time.Duration // per test
+	BlockSizes []int         // bytes; empty: 1M sequential and 4k random
+}
+
+// DefaultDiskBenchOptions returns the options of the menu and diskbench command
+func DefaultDiskBenchOptions() DiskBenchOptions {
+	return DiskBenchOptions{
+		Dir:      "/var/tmp",
+		FileSize: 1 << 30,
+		Runtime:  10 * time.Second,
+	}
+}
+
+// DiskBenchResult is the outcome of one test, at queue depth 1
+type DiskBenchResult struct {
+	Test      string  `json:"test"` // read, write, randread, randwrite
+	BlockSize int     `json:"block_size"`
+	Ops       int64   `json:"ops"`
+	Bytes     int64   `json:"bytes"`
+	Seconds   float64 `json:"seconds"`
+	MBps      float64 `json:"mbps"`
+	IOPS      float64 `json:"iops"`
+	AvgLatUs  float64 `json:"avg_latency_us"`
+	MaxLatUs  float64 `json:"max_latency_us"`
+	Direct    bool    `json:"direct"` // false: O_DIRECT refused, page cache included
+}
+
+// diskBenchTest is one access pattern at one block size
+type diskBenchTest struct {
+	Name      string
+	Write     bool
+	Random    bool
+	BlockSize int
+}
+
+// diskBenchPlan returns the tests to run: every pattern at each block size,
+// or the usual 1M sequential and 4k random tests
+func diskBenchPlan(blockSizes []int) []diskBenchTest {
+	if len(blockSizes) == 0 {
+		return []diskBenchTest{
+			{"read", false, false, 1 << 20},
+			{"write", true, false, 1 << 20},
+			{"randread", false, true, 4096},
+			{"randwrite", true, true, 4096},
+		}
+	}
+	var tests []diskBenchTest
+	for _, bs := range blockSizes {
+		tests = append(tests,
+			diskBenchTest{"read", false, false, bs},
+			diskBenchTest{"write", true, false, bs},
+			diskBenchTest{"randread", false, true, bs},
+			diskBenchTest{"randwrite", true, true, bs},
+		)
+	}
+	return tests
+}
+
+// ParseBlockSize parses sizes such as 4k, 64K, 1m or 512
+func ParseBlockSize(s string) (int, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	mult := 1
+	switch {
+	case strings.HasSuffix(s, "k"):
+		mult, s = 1<<10, strings.TrimSuffix(s, "k")
+	case strings.HasSuffix(s, "m"):
+		mult, s = 1<<20, strings.TrimSuffix(s, "m")
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("invalid block size %q", s)
+	}
+	size := n * mult
+	// O_DIRECT needs sector-aligned transfers
+	if size%512 != 0 || size > 64<<20 {
+		return 0, fmt.Errorf("block size must be a multiple of 512 bytes, up to 64M")
+	}
+	return size, nil
+}
+
+// alignedBuffer returns a page-aligned buffer, as O_DIRECT requires
+func alignedBuffer(size int) ([]byte, error) {
+	return unix.Mmap(-1, 0, size, unix.PROT_READ|unix.PROT_WRITE, unix.MAP_ANON|unix.MAP_PRIVATE)
+}
+
+// openDirect opens the test file with O_DIRECT, falling back to buffered
+// I/O on filesystems that refuse it (tmpfs)
+func openDirect(path string, flags int) (*os.File, bool, error) {
+	f, err := os.OpenFile(path, flags|unix.O_DIRECT, 0600)
+	if err == nil {
+		return f, true, nil
+	}
+	if !errors.Is(err, unix.EINVAL) {
+		return nil, false, err
+	}
+	f, err = os.OpenFile(path, flags, 0600)
+	return f, false, err
+}
+
+// RunDiskBenchmark measures the disk holding opts.Dir without fio: the test
+// file is filled with random data (zeroes would be optimized away by thin
+// provisioning) then read and written with O_DIRECT. The file is removed.
+func RunDiskBenchmark(opts DiskBenchOptions) ([]DiskBenchResult, error) {
+	if opts.FileSize <= 0 || opts.FileSize%(1<<20) != 0 {
+		return nil, fmt.Errorf("test file size must be a whole number of MB")
+	}
+	tests := diskBenchPlan(opts.BlockSizes)
+	for _, t := range tests {
+		if int64(t.BlockSize) > opts.FileSize {
+			return nil, fmt.Errorf("block size %d larger than the test file", t.BlockSize)
+		}
+	}
+
+	var st unix.Statfs_t
+	if err := unix.Statfs(opts.Dir, &st); err != nil {
+		return nil, fmt.Errorf("failed to stat %s: %w", opts.Dir, err)
+	}
+	if free := int64(st.Bavail) * int64(st.Bsize); free < opts.FileSize*2 {
+		return nil, fmt.Errorf("not enough free space in %s: %s free, %s needed", opts.Dir, FormatBytes(free), FormatBytes(opts.FileSize*2))
+	}
+
+	path := filepath.Join(opts.Dir, fmt.Sprintf("vmware-tuner-diskbench-%d.tmp", os.Getpid()))
+	defer os.Remove(path)
+
+	PrintInfo("Preparing %s test file in %s...", FormatBytes(opts.FileSize), opts.Dir)
+	if err := fillBenchFile(path, opts.FileSize); err != nil {
+		return nil, err
+	}
+
+	var results []DiskBenchResult
+	for _, t := range tests {
+		PrintInfo("Running %s (bs=%s, %s)...", t.Name, formatBlockSize(t.BlockSize), FormatDuration(opts.Runtime))
+		r, err := runBenchTest(path, opts.FileSize, opts.Runtime, t)
+		if err != nil {
+			return results, fmt.Errorf("%s test failed: %w", t.Name, err)
+		}
+		results = append(results, r)
+	}
+	return results, nil
+}
+
+// fillBenchFile writes the test file sequentially
+func fillBenchFile(path string, size int64) error {
+	f, _, err := openDirect(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC)
+	if err != nil {
+		return fmt.Errorf("failed to create test file: %w", err)
+	}
+	defer f.Close()
+
+	buf, err := alignedBuffer(1 << 20)
+	if err != nil {
+		return fmt.Errorf("failed to allocate buffer: %w", err)
+	}
+	defer unix.Munmap(buf)
+	rand.Read(buf)
+
+	for written := int64(0); written < size; {
+		chunk := buf
+		if size-written < int64(len(chunk)) {
+			chunk = chunk[:size-written]
+		}
+		n, err := f.Write(chunk)
+		if err != nil {
+			return fmt.Errorf("failed to write test file: %w", err)
+		}
+		written += int64(n)
+	}
+	return f.Sync()
+}
+
+// runBenchTest runs one pattern for the given time
+func runBenchTest(path string, fileSize int64, runtime time.Duration, t diskBenchTest) (DiskBenchResult, error) {
+	flags := os.O_RDONLY
+	if t.Write {
+		flags = os.O_WRONLY
+	}
+	f, direct, err := openDirect(path, flags)
+	if err != nil {
+		return DiskBenchResult{}, err
+	}
+	defer f.Close()
+
+	buf, err := alignedBuffer(t.BlockSize)
+	if err != nil {
+		return DiskBenchResult{}, err
+	}
+	defer unix.Munmap(buf)
+	rand.Read(buf)
+
+	blocks := fileSize / int64(t.BlockSize)
+	rng := rand.New(rand.NewSource(time.Now().UnixNano()))
+	result := DiskBenchResult{Test: t.Name, BlockSize: t.BlockSize, Direct: direct}
+
+	var next int64
+	var maxLat time.Duration
+	start := time.Now()
+	for time.Since(start) < runtime {
+		block := next
+		if t.Random {
+			block = rng.Int63n(blocks)
+		} else {
+			next = (next + 1) % blocks
+		}
+		offset := block * int64(t.BlockSize)
+
+		opStart := time.Now()
+		if t.Write {
+			_, err = f.WriteAt(buf, offset)
+		} else {
+			_, err = f.ReadAt(buf, offset)
+		}
+		if err != nil {
+			return result, err
+		}
+		if lat := time.Since(opStart); lat > maxLat {
+			maxLat = lat
+		}
+		result.Ops++
+	}
+	if t.Write {
+		if err := f.Sync(); err != nil {
+			return result, err
+		}
+	}
+	elapsed := time.Since(start).Seconds()
+
+	result.Bytes = result.Ops * int64(t.BlockSize)
+	result.Seconds = elapsed
+	result.MBps = float64(result.Bytes) / (1 << 20) / elapsed
+	result.IOPS = float64(result.Ops) / elapsed
+	if result.Ops > 0 {
+		result.AvgLatUs = elapsed * 1e6 / float64(result.Ops)
+	}
+	result.MaxLatUs = float64(maxLat.Microseconds())
+	return result, nil
+}
+
+// formatBlockSize prints 4096 as 4k and 1048576 as 1M
+func formatBlockSize(bs int) string {
+	switch {
+	case bs%(1<<20) == 0:
+		return fmt.Sprintf("%dM", bs>>20)
+	case bs%(1<<10) == 0:
+		return fmt.Sprintf("%dk", bs>>10)
+	}
+	return strconv.Itoa(bs)
+}
+
+// PrintDiskBenchResults prints the results as a table
+func PrintDiskBenchResults(results []DiskBenchResult) {
+	fmt.Printf("  %-10s %6s %10s %10s %12s %12s\n", "Test", "BS", "MiB/s", "IOPS", "Avg lat(us)", "Max lat(us)")
+	direct := true
+	for _, r := range results {
+		fmt.Printf("  %-10s %6s %10.1f %10.0f %12.0f %12.0f\n",
+			r.Test, formatBlockSize(r.BlockSize), r.MBps, r.IOPS, r.AvgLatUs, r.MaxLatUs)
+		direct = direct && r.Direct
+	}
+	if !direct {
+		PrintWarning("O_DIRECT not supported here: numbers include the page cache")
+	}
+}
+
+// DiskBenchTuner runs the native disk benchmark from the menu
+type DiskBenchTuner struct {
+	Options DiskBenchOptions
+}
+
+// NewDiskBenchTuner creates a new disk benchmark tuner
+func NewDiskBenchTuner(opts DiskBenchOptions) *DiskBenchTuner {
+	return &DiskBenchTuner{Options: opts}
+}
+
+func init() {
+	Register(Module{
+		Name:        "diskbench",
+		Label:       "Disk Benchmark (no fio needed)",
+		Description: "Measure the disk throughput, IOPS and latency",
+		Category:    CategoryDiagnostics,
+		Menu:        30,
+		ReadOnly:    true,
+		Run: func(ctx *ModuleContext) error {
+			return NewDiskBenchTuner(DefaultDiskBenchOptions()).Run()
+		},
+	})
+}
+
+// Run performs the benchmark
+func (dt *DiskBenchTuner) Run() error {
+	PrintStep("Disk Benchmark (native, queue depth 1)")
+
+	results, err := RunDiskBenchmark(dt.Options)
+	if len(results) > 0 {
+		fmt.Println()
+		PrintDiskBenchResults(results)
+	}
+	return err
+}
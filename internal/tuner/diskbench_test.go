@@ -0,0 +1,42 @@
+package tuner
+
+import (
+	"os"
+	"testing"
+	"time"
+)
+
+func TestParseBlockSize(t *testing.T) {
+	for in, want := range map[string]int{"4k": 4096, "64K": 65536, "1m": 1 << 20, "512": 512} {
+		if got, err := ParseBlockSize(in); err != nil || got != want {
+			t.Errorf("ParseBlockSize(%q) = %d, %v; want %d", in, got, err, want)
+		}
+	}
+	for _, in := range []string{"", "abc", "1000", "128m", "-4k"} {
+		if _, err := ParseBlockSize(in); err == nil {
+			t.Errorf("ParseBlockSize(%q) accepted", in)
+		}
+	}
+}
+
+func TestRunDiskBenchmark(t *testing.T) {
+	dir := t.TempDir()
+	opts := DiskBenchOptions{Dir: dir, FileSize: 1 << 20, Runtime: 20 * time.Millisecond, BlockSizes: []int{4096}}
+
+	results, err := RunDiskBenchmark(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 4 {
+		t.Fatalf("expected the 4 patterns, got %d", len(results))
+	}
+	for _, r := range results {
+		if r.Ops == 0 || r.IOPS <= 0 || r.Bytes != r.Ops*4096 {
+			t.Errorf("unexpected result %+v", r)
+		}
+	}
+
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Errorf("test file left behind: %v", entries)
+	}
+}
@@ -0,0 +1,280 @@
+package tuner
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// Disk usage analysis defaults
+const (
+	inodeWarnPercent      = 90.0 // inode usage reported as near exhaustion
+	DefaultDiskUsageDepth = 3    // directory levels reported below each root
+	DefaultDiskUsageTop   = 10   // directories listed per ranking
+)
+
+// DiskUsageRoots are scanned by default: logs, spools, caches and user data
+var DiskUsageRoots = []string{"/var", "/home"}
+
+// pseudoFilesystems have no inodes of their own to run out of
+var pseudoFilesystems = map[string]bool{
+	"proc": true, "sysfs": true, "cgroup": true, "cgroup2": true, "devpts": true,
+	"securityfs": true, "debugfs": true, "tracefs": true, "pstore": true, "bpf": true,
+	"configfs": true, "fusectl": true, "mqueue": true, "hugetlbfs": true, "autofs": true,
+	"binfmt_misc": true, "efivarfs": true, "rpc_pipefs": true, "nsfs": true, "squashfs": true,
+}
+
+// InodeUsage is the inode usage of a mounted filesystem
+type InodeUsage struct {
+	MountPoint string
+	FSType     string
+	Total      uint64
+	Free       uint64
+}
+
+// UsedPercent returns the share of inodes in use
+func (u InodeUsage) UsedPercent() float64 {
+	if u.Total == 0 {
+		return 0
+	}
+	return float64(u.Total-u.Free) * 100 / float64(u.Total)
+}
+
+// statInodes reads the inode counters of the filesystem holding path
+var statInodes = func(path string) (uint64, uint64, error) {
+	var st unix.Statfs_t
+	if err := unix.Statfs(path, &st); err != nil {
+		return 0, 0, err
+	}
+	return st.Files, st.Ffree, nil
+}
+
+// ReadInodeUsage returns the inode usage of the mounted filesystems.
+// Filesystems allocating inodes on demand (btrfs) report no total and are skipped.
+func ReadInodeUsage(fsRoot string) ([]InodeUsage, error) {
+	data, err := os.ReadFile(filepath.Join(fsRoot, "/proc/mounts"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read /proc/mounts: %w", err)
+	}
+
+	seen := make(map[string]bool)
+	var usage []InodeUsage
+	for _, line := range strings.Split(string(data), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 3 || pseudoFilesystems[fields[2]] || seen[fields[1]] {
+			continue
+		}
+		seen[fields[1]] = true
+		total, free, err := statInodes(filepath.Join(fsRoot, fields[1]))
+		if err != nil || total == 0 {
+			continue
+		}
+		usage = append(usage, InodeUsage{MountPoint: fields[1], FSType: fields[2], Total: total, Free: free})
+	}
+	return usage, nil
+}
+
+// DirUsage is the space and the inodes used below a directory
+type DirUsage struct {
+	Path  string
+	Bytes int64 // allocated blocks, like du
+	Files int64 // files and directories (inodes)
+}
+
+// ScanLargestDirs walks root without leaving its filesystem and returns the
+// usage of every directory at most depth levels below it, everything deeper
+// counted in its ancestor. Hard links are counted once; unreadable
+// directories are skipped.
+func ScanLargestDirs(root string, depth int) ([]DirUsage, error) {
+	info, err := os.Lstat(root)
+	if err != nil {
+		return nil, err
+	}
+	rootDev := info.Sys().(*syscall.Stat_t).Dev
+	base := strings.Count(filepath.Clean(root), string(filepath.Separator))
+	if filepath.Clean(root) == string(filepath.Separator) {
+		base = 0
+	}
+
+	usage := make(map[string]*DirUsage)
+	linked := make(map[uint64]bool)
+	err = filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if d != nil && d.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		st := info.Sys().(*syscall.Stat_t)
+		if d.IsDir() && st.Dev != rootDev {
+			return fs.SkipDir // other filesystem (bind mount, separate /var/lib/docker)
+		}
+		if !d.IsDir() && st.Nlink > 1 {
+			if linked[st.Ino] {
+				return nil
+			}
+			linked[st.Ino] = true
+		}
+
+		// The directory reporting this entry: its own directory, or the
+		// ancestor at the depth limit
+		dir := path
+		if !d.IsDir() {
+			dir = filepath.Dir(path)
+		}
+		for strings.Count(dir, string(filepath.Separator))-base > depth {
+			dir = filepath.Dir(dir)
+		}
+		u := usage[dir]
+		if u == nil {
+			u = &DirUsage{Path: dir}
+			usage[dir] = u
+		}
+		u.Bytes += st.Blocks * 512
+		u.Files++
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Add each directory to its ancestors up to root
+	dirs := make([]string, 0, len(usage))
+	for dir := range usage {
+		dirs = append(dirs, dir)
+	}
+	sort.Slice(dirs, func(i, j int) bool { return len(dirs[i]) > len(dirs[j]) })
+	totals := make(map[string]*DirUsage)
+	for _, dir := range dirs {
+		own := usage[dir]
+		for p := dir; ; p = filepath.Dir(p) {
+			t := totals[p]
+			if t == nil {
+				t = &DirUsage{Path: p}
+				totals[p] = t
+			}
+			t.Bytes += own.Bytes
+			t.Files += own.Files
+			if p == filepath.Clean(root) || p == filepath.Dir(p) {
+				break
+			}
+		}
+	}
+
+	result := make([]DirUsage, 0, len(totals))
+	for _, t := range totals {
+		result = append(result, *t)
+	}
+	return result, nil
+}
+
+// topDirs returns the n largest directories by less, root excluded
+func topDirs(dirs []DirUsage, root string, n int, less func(a, b DirUsage) bool) []DirUsage {
+	var sorted []DirUsage
+	for _, d := range dirs {
+		if d.Path != filepath.Clean(root) {
+			sorted = append(sorted, d)
+		}
+	}
+	sort.Slice(sorted, func(i, j int) bool { return less(sorted[i], sorted[j]) })
+	if len(sorted) > n {
+		sorted = sorted[:n]
+	}
+	return sorted
+}
+
+// DiskUsageTuner finds filesystems running out of inodes and the
+// directories using the most space and files
+type DiskUsageTuner struct {
+	Roots  []string
+	Depth  int
+	Top    int
+	FSRoot string // "" for /
+}
+
+// NewDiskUsageTuner creates a new disk usage analysis
+func NewDiskUsageTuner() *DiskUsageTuner {
+	return &DiskUsageTuner{
+		Roots: DiskUsageRoots,
+		Depth: DefaultDiskUsageDepth,
+		Top:   DefaultDiskUsageTop,
+	}
+}
+
+func init() {
+	Register(Module{
+		Name:        "diskusage",
+		Label:       "Disk Usage & Inodes",
+		Description: "Show the space and inode usage and the largest directories",
+		Category:    CategoryDiagnostics,
+		Menu:        33,
+		ReadOnly:    true,
+		Run: func(ctx *ModuleContext) error {
+			return NewDiskUsageTuner().Run()
+		},
+	})
+}
+
+// Run prints the inode usage and the largest directories of each root
+func (du *DiskUsageTuner) Run() error {
+	PrintStep("Disk Usage Analysis")
+
+	usage, err := ReadInodeUsage(du.FSRoot)
+	if err != nil {
+		return err
+	}
+	PrintInfo("Inode usage:")
+	exhausted := 0
+	for _, u := range usage {
+		line := fmt.Sprintf("%-24s %-8s %12d inodes, %5.1f%% used", u.MountPoint, u.FSType, u.Total, u.UsedPercent())
+		if u.UsedPercent() >= inodeWarnPercent {
+			PrintWarning("%s", line)
+			exhausted++
+		} else {
+			fmt.Printf("    %s\n", line)
+		}
+	}
+	if exhausted > 0 {
+		PrintWarning("%d filesystem(s) near inode exhaustion: writes fail with \"No space left on device\" while df shows free space", exhausted)
+		PrintInfo("-> Look for the directories with the most files below (session files, mail queues, caches) and remove what is not needed; ext4 cannot add inodes without being recreated")
+	}
+
+	for _, root := range du.Roots {
+		path := filepath.Join(du.FSRoot, root)
+		if !FileExists(path) {
+			continue
+		}
+		fmt.Println()
+		PrintInfo("Scanning %s (%d levels)...", root, du.Depth)
+		dirs, err := ScanLargestDirs(path, du.Depth)
+		if err != nil {
+			PrintWarning("Could not scan %s: %v", root, err)
+			continue
+		}
+
+		display := func(p string) string { return strings.TrimPrefix(p, filepath.Clean(du.FSRoot)) }
+		PrintInfo("Largest directories under %s:", root)
+		for _, d := range topDirs(dirs, path, du.Top, func(a, b DirUsage) bool { return a.Bytes > b.Bytes }) {
+			fmt.Printf("    %10s  %s\n", FormatBytes(d.Bytes), display(d.Path))
+		}
+		PrintInfo("Most files under %s:", root)
+		for _, d := range topDirs(dirs, path, du.Top, func(a, b DirUsage) bool { return a.Files > b.Files }) {
+			fmt.Printf("    %10d  %s\n", d.Files, display(d.Path))
+		}
+	}
+	if os.Geteuid() != 0 {
+		fmt.Println()
+		PrintWarning("Not running as root: unreadable directories were skipped")
+	}
+	return nil
+}
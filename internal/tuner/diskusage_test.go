@@ -0,0 +1,81 @@
+package tuner
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestScanLargestDirs(t *testing.T) {
+	root := t.TempDir()
+	files := map[string]string{
+		"log/syslog":                     string(make([]byte, 1<<20)),
+		"log/journal/abc/system.journal": string(make([]byte, 4<<20)),
+	}
+	for i := 0; i < 50; i++ {
+		files[fmt.Sprintf("lib/php/sessions/sess_%d", i)] = "0123456789"
+	}
+	writeFiles(t, root, files)
+	if err := os.Link(filepath.Join(root, "log/syslog"), filepath.Join(root, "log/syslog.link")); err != nil {
+		t.Fatal(err)
+	}
+
+	dirs, err := ScanLargestDirs(root, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	byPath := make(map[string]DirUsage)
+	for _, d := range dirs {
+		byPath[strings.TrimPrefix(d.Path, root)] = d
+	}
+	if _, ok := byPath["/log/journal"]; ok {
+		t.Error("directories deeper than 1 level are counted in their ancestor")
+	}
+	logDir, lib := byPath["/log"], byPath["/lib"]
+	if logDir.Bytes < 5<<20 || logDir.Bytes > 6<<20 {
+		t.Errorf("/log should hold the 5 MiB once (hard link counted once): %s", FormatBytes(logDir.Bytes))
+	}
+	if lib.Files != 53 { // lib, php, sessions and 50 files
+		t.Errorf("/lib has %d inodes, want 53", lib.Files)
+	}
+	if total := byPath[""]; total.Files != logDir.Files+lib.Files+1 {
+		t.Errorf("root total %d != %d + %d + 1", total.Files, logDir.Files, lib.Files)
+	}
+
+	top := topDirs(dirs, root, 1, func(a, b DirUsage) bool { return a.Files > b.Files })
+	if len(top) != 1 || !strings.HasSuffix(top[0].Path, "/lib") {
+		t.Errorf("most files: %+v", top)
+	}
+}
+
+func TestReadInodeUsage(t *testing.T) {
+	saved := statInodes
+	defer func() { statInodes = saved }()
+	statInodes = func(path string) (uint64, uint64, error) {
+		switch {
+		case strings.HasSuffix(path, "/var"):
+			return 1000, 20, nil
+		case strings.HasSuffix(path, "/btrfs"):
+			return 0, 0, nil
+		}
+		return 1000, 900, nil
+	}
+
+	root := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(root, "proc"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	mounts := "/dev/sda1 / ext4 rw 0 0\nproc /proc proc rw 0 0\n/dev/sdb1 /var ext4 rw 0 0\n/dev/sdc /btrfs btrfs rw 0 0\n"
+	if err := os.WriteFile(filepath.Join(root, "proc/mounts"), []byte(mounts), 0644); err != nil {
+		t.Fatal(err)
+	}
+	usage, err := ReadInodeUsage(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(usage) != 2 || usage[1].MountPoint != "/var" || usage[1].UsedPercent() != 98 || usage[0].UsedPercent() != 10 {
+		t.Errorf("unexpected inode usage: %+v", usage)
+	}
+}
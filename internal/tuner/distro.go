@@ -1,12 +1,27 @@
 package tuner
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"os/exec"
+	"path/filepath"
+	"strconv"
 	"strings"
 )
 
+// PackageDir is a local directory of .deb/.rpm files, a bundle or a mounted
+// installation medium, used before the repositories (air-gapped installs,
+// set by --pkg-dir)
+var PackageDir string
+
+// ReadOnly is set by inspection commands (show, verify, audit, info...):
+// package installs, boot loader updates and backups are refused
+var ReadOnly bool
+
+// ErrReadOnly is returned for system changes attempted in read-only mode
+var ErrReadOnly = errors.New("not allowed in read-only mode")
+
 // DistroType represents the Linux distribution family
 type DistroType int
 
@@ -18,8 +33,14 @@ const (
 
 // DistroManager handles distribution-specific operations
 type DistroManager struct {
-	Type DistroType
-	Name string
+	Type      DistroType
+	Name      string
+	ID        string // os-release ID (ubuntu, rhel, rocky...)
+	VersionID string // os-release VERSION_ID (22.04, 9.3...)
+	Major     int
+	Minor     int
+	Codename  string // jammy, bookworm... (empty on RHEL family)
+	Ostree    bool   // rpm-ostree deployment: no package installs, kargs for boot params
 }
 
 // NewDistroManager creates a new distribution manager
@@ -45,6 +66,16 @@ func (dm *DistroManager) detect() error {
 	content := string(data)
 	contentLower := strings.ToLower(content)
 
+	fields := parseOSRelease(content)
+	dm.ID = fields["ID"]
+	dm.VersionID = fields["VERSION_ID"]
+	dm.Major, dm.Minor = parseVersionID(dm.VersionID)
+	dm.Codename = fields["VERSION_CODENAME"]
+	if dm.Codename == "" {
+		dm.Codename = fields["UBUNTU_CODENAME"]
+	}
+	dm.Ostree = IsOstree("")
+
 	if strings.Contains(contentLower, "debian") || strings.Contains(contentLower, "ubuntu") {
 		dm.Type = DistroDebian
 		dm.Name = "Debian/Ubuntu"
@@ -65,17 +96,87 @@ func (dm *DistroManager) detect() error {
 			dm.Type = DistroRHEL
 			dm.Name = "RHEL-based"
 		} else {
-			return fmt.Errorf("unsupported distribution")
+			return ErrUnsupportedDistro
 		}
 	}
 
 	return nil
 }
 
-// InstallPackage installs a package using the system package manager
+// parseOSRelease parses os-release KEY=value lines (values unquoted)
+func parseOSRelease(content string) map[string]string {
+	fields := make(map[string]string)
+	for _, line := range strings.Split(content, "\n") {
+		key, value, ok := strings.Cut(strings.TrimSpace(line), "=")
+		if !ok || strings.HasPrefix(key, "#") {
+			continue
+		}
+		fields[key] = strings.Trim(value, "\"'")
+	}
+	return fields
+}
+
+// parseVersionID splits a VERSION_ID such as "22.04" or "9" into major/minor
+func parseVersionID(versionID string) (int, int) {
+	var major, minor int
+	parts := strings.SplitN(versionID, ".", 3)
+	if len(parts) > 0 {
+		major, _ = strconv.Atoi(parts[0])
+	}
+	if len(parts) > 1 {
+		minor, _ = strconv.Atoi(parts[1])
+	}
+	return major, minor
+}
+
+// VersionAtLeast reports whether the release is major.minor or newer
+// (false when the version is unknown)
+func (dm *DistroManager) VersionAtLeast(major, minor int) bool {
+	if dm.Major == 0 {
+		return false
+	}
+	return dm.Major > major || dm.Major == major && dm.Minor >= minor
+}
+
+// String returns the family name with the release, e.g. "Debian/Ubuntu 22.04 (jammy)"
+func (dm *DistroManager) String() string {
+	name := dm.Name
+	if dm.VersionID != "" {
+		name += " " + dm.VersionID
+	}
+	if dm.Codename != "" {
+		name += " (" + dm.Codename + ")"
+	}
+	if dm.Ostree {
+		name += " [rpm-ostree]"
+	}
+	return name
+}
+
+// InstallPackage installs a package using the system package manager.
+// A matching file in PackageDir takes precedence over the repositories.
 func (dm *DistroManager) InstallPackage(pkg string) error {
 	var cmd *exec.Cmd
 
+	if ReadOnly {
+		return fmt.Errorf("installing %s: %w", pkg, ErrReadOnly)
+	}
+
+	if dm.Ostree {
+		return errOstreeUnsupported("installing "+pkg, fmt.Sprintf("layer it with 'rpm-ostree install %s' and reboot", pkg))
+	}
+
+	if dm.bundleProvides(pkg) {
+		return dm.installFromBundle(pkg)
+	}
+	if local := dm.FindLocalPackage(pkg); local != "" {
+		// On a distribution medium the dependencies are there too
+		if rpmDirs, aptSources := mediaRepos(PackageDir); dm.Type == DistroRHEL && len(rpmDirs) > 0 || dm.Type == DistroDebian && len(aptSources) > 0 {
+			return dm.installFromMedia(pkg, rpmDirs, aptSources)
+		}
+		return dm.installLocalPackage(pkg, local)
+	}
+
 	switch dm.Type {
 	case DistroDebian:
 		// Update apt cache first? Maybe too slow. Just try install.
@@ -90,10 +191,11 @@ func (dm *DistroManager) InstallPackage(pkg string) error {
 			cmd = exec.Command("yum", "install", "-y", pkg)
 		}
 	default:
-		return fmt.Errorf("unknown distribution type")
+		return fmt.Errorf("installing %s: %w", pkg, ErrUnsupportedDistro)
 	}
 
 	PrintInfo("Installing package %s...", pkg)
+	ExplainCommand("install "+pkg+" from the configured repositories", cmd.Args[0], cmd.Args[1:]...)
 	output, err := cmd.CombinedOutput()
 	if err != nil {
 		return fmt.Errorf("failed to install %s: %v\nOutput: %s", pkg, err, string(output))
@@ -103,10 +205,153 @@ func (dm *DistroManager) InstallPackage(pkg string) error {
 	return nil
 }
 
+// FindLocalPackage returns the package file for pkg in PackageDir or its
+// subdirectories (pool/, Packages/ of a mounted ISO), if any
+func (dm *DistroManager) FindLocalPackage(pkg string) string {
+	if PackageDir == "" {
+		return ""
+	}
+
+	var pattern string
+	switch dm.Type {
+	case DistroDebian:
+		pattern = pkg + "_*.deb"
+	case DistroRHEL:
+		pattern = pkg + "-[0-9]*.rpm"
+	default:
+		return ""
+	}
+
+	return newestPackageFile(findPackageFiles(PackageDir, pattern))
+}
+
+// newestPackageFile returns the file with the highest version, "" for none:
+// by version, not by name, so 1.10 comes after 1.9
+func newestPackageFile(files []string) string {
+	newest := ""
+	for _, file := range files {
+		if newest == "" || compareVersions(filepath.Base(file), filepath.Base(newest)) > 0 {
+			newest = file
+		}
+	}
+	return newest
+}
+
+// compareVersions compares two version strings (or package file names of the
+// same package) run by run: digits as numbers, the rest as text. It returns
+// -1, 0 or 1 as rpm and dpkg order them for plain versions.
+func compareVersions(a, b string) int {
+	for a != "" && b != "" {
+		runA, restA := versionRun(a)
+		runB, restB := versionRun(b)
+		numA, numB := runA[0] >= '0' && runA[0] <= '9', runB[0] >= '0' && runB[0] <= '9'
+		switch {
+		case numA && numB:
+			runA, runB = strings.TrimLeft(runA, "0"), strings.TrimLeft(runB, "0")
+			if len(runA) != len(runB) {
+				return compareInts(len(runA), len(runB))
+			}
+			fallthrough
+		case numA == numB:
+			if runA != runB {
+				if runA < runB {
+					return -1
+				}
+				return 1
+			}
+		case numA:
+			return 1
+		default:
+			return -1
+		}
+		a, b = restA, restB
+	}
+	return compareInts(len(a), len(b))
+}
+
+// versionRun splits the leading run of digits, or of other characters, off s
+func versionRun(s string) (string, string) {
+	digit := s[0] >= '0' && s[0] <= '9'
+	i := 1
+	for i < len(s) && (s[i] >= '0' && s[i] <= '9') == digit {
+		i++
+	}
+	return s[:i], s[i:]
+}
+
+// compareInts returns -1, 0 or 1 as a is lower, equal or greater than b
+func compareInts(a, b int) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// HasPackageSource reports whether pkg can be installed: from PackageDir or,
+// when online, from the repositories
+func (dm *DistroManager) HasPackageSource(pkg string, hasInternet bool) bool {
+	return hasInternet || dm.bundleProvides(pkg) || dm.FindLocalPackage(pkg) != ""
+}
+
+// bundleProvides reports whether PackageDir is a bundle (see `bundle create`) listing pkg
+func (dm *DistroManager) bundleProvides(pkg string) bool {
+	if PackageDir == "" {
+		return false
+	}
+	manifest, err := LoadBundleManifest(PackageDir)
+	if err != nil {
+		return false
+	}
+	for _, p := range manifest.Packages {
+		if p == pkg {
+			return true
+		}
+	}
+	return false
+}
+
+// installLocalPackage installs a package file (dependencies from the repositories)
+func (dm *DistroManager) installLocalPackage(pkg, path string) error {
+	var cmd *exec.Cmd
+
+	switch dm.Type {
+	case DistroDebian:
+		cmd = exec.Command("apt-get", "install", "-y", path)
+		cmd.Env = append(os.Environ(), "DEBIAN_FRONTEND=noninteractive")
+	case DistroRHEL:
+		if _, err := exec.LookPath("dnf"); err == nil {
+			cmd = exec.Command("dnf", "install", "-y", path)
+		} else {
+			cmd = exec.Command("yum", "localinstall", "-y", path)
+		}
+	}
+
+	PrintInfo("Installing package %s from %s...", pkg, filepath.Base(path))
+	ExplainCommand("install "+pkg+" from the local package directory (air-gapped)", cmd.Args[0], cmd.Args[1:]...)
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("failed to install %s: %v\nOutput: %s", path, err, string(output))
+	}
+
+	PrintSuccess("Installed %s (offline package)", pkg)
+	return nil
+}
+
 // UpdateGrub updates the GRUB configuration
 func (dm *DistroManager) UpdateGrub() error {
+	if ReadOnly {
+		return fmt.Errorf("updating GRUB: %w", ErrReadOnly)
+	}
+	if dm.Ostree {
+		return errOstreeUnsupported("grub2-mkconfig", "boot entries are managed by ostree (use rpm-ostree kargs)")
+	}
+
 	switch dm.Type {
 	case DistroDebian:
+		ExplainCommand("regenerate /boot/grub/grub.cfg from /etc/default/grub", "update-grub")
 		cmd := exec.Command("update-grub")
 		output, err := cmd.CombinedOutput()
 		if err != nil {
@@ -118,8 +363,9 @@ func (dm *DistroManager) UpdateGrub() error {
 		// Detect correct output path for grub2-mkconfig
 		outputPath := "/boot/grub2/grub.cfg"
 
-		// Check for UEFI
-		if _, err := os.Stat("/sys/firmware/efi"); err == nil {
+		// Check for UEFI (RHEL 9.3+/Fedora 34+ keep only a stub on the ESP that
+		// sources /boot/grub2/grub.cfg: overwriting the stub breaks boot)
+		if _, err := os.Stat("/sys/firmware/efi"); err == nil && !dm.UnifiedGrubConfig() {
 			// UEFI detected
 			// RHEL 7/8/9 location variations
 			// Common paths: /boot/efi/EFI/redhat/grub.cfg, /boot/efi/EFI/centos/grub.cfg
@@ -151,6 +397,7 @@ func (dm *DistroManager) UpdateGrub() error {
 		}
 
 		PrintInfo("Updating GRUB config at %s...", outputPath)
+		ExplainCommand("regenerate the boot menu from /etc/default/grub", "grub2-mkconfig", "-o", outputPath)
 		cmd := exec.Command("grub2-mkconfig", "-o", outputPath)
 		output, err := cmd.CombinedOutput()
 		if err != nil {
@@ -159,8 +406,54 @@ func (dm *DistroManager) UpdateGrub() error {
 		return nil
 
 	default:
-		return fmt.Errorf("unsupported distribution for GRUB update")
+		return fmt.Errorf("GRUB update: %w", ErrUnsupportedDistro)
+	}
+}
+
+// UpdateInitramfs regenerates the initramfs of the running kernel, so the
+// drivers loaded from it (vmw_pvscsi for the root disk) get the new
+// modprobe.d options
+func (dm *DistroManager) UpdateInitramfs() error {
+	if ReadOnly {
+		return fmt.Errorf("updating the initramfs: %w", ErrReadOnly)
+	}
+	if dm.Ostree {
+		return errOstreeUnsupported("dracut", "track the file with 'rpm-ostree initramfs-etc --track=<file>' and reboot")
+	}
+
+	var args []string
+	switch dm.Type {
+	case DistroDebian:
+		args = []string{"update-initramfs", "-u"}
+	case DistroRHEL:
+		args = []string{"dracut", "-f"}
+	default:
+		return fmt.Errorf("initramfs update: %w", ErrUnsupportedDistro)
+	}
+
+	ExplainCommand("rebuild the initramfs so the driver options apply from the first stage of the boot", args[0], args[1:]...)
+	if output, err := exec.Command(args[0], args[1:]...).CombinedOutput(); err != nil {
+		return fmt.Errorf("%s failed: %v\nOutput: %s", args[0], err, string(output))
+	}
+	return nil
+}
+
+// osReleaseValue returns a field of /etc/os-release (unquoted), or ""
+func osReleaseValue(key string) string {
+	data, err := os.ReadFile("/etc/os-release")
+	if err != nil {
+		return ""
+	}
+	return parseOSRelease(string(data))[key]
+}
+
+// UnifiedGrubConfig reports whether EFI systems use /boot/grub2/grub.cfg
+// (RHEL 9.3+, Fedora 34+)
+func (dm *DistroManager) UnifiedGrubConfig() bool {
+	if dm.ID == "fedora" {
+		return dm.VersionAtLeast(34, 0)
 	}
+	return dm.Type == DistroRHEL && dm.VersionAtLeast(9, 3)
 }
 
 // GetGrubConfigPath returns the path to the GRUB configuration file
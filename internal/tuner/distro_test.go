@@ -0,0 +1,77 @@
+package tuner
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseOSRelease_Version(t *testing.T) {
+	content := `PRETTY_NAME="Ubuntu 22.04.4 LTS"
+NAME="Ubuntu"
+VERSION_ID="22.04"
+VERSION_CODENAME=jammy
+ID=ubuntu
+`
+	fields := parseOSRelease(content)
+	if fields["ID"] != "ubuntu" || fields["VERSION_ID"] != "22.04" || fields["VERSION_CODENAME"] != "jammy" {
+		t.Fatalf("unexpected fields: %v", fields)
+	}
+
+	dm := &DistroManager{Type: DistroDebian}
+	dm.Major, dm.Minor = parseVersionID(fields["VERSION_ID"])
+	if dm.Major != 22 || dm.Minor != 4 {
+		t.Errorf("parseVersionID(22.04) = %d.%d", dm.Major, dm.Minor)
+	}
+	if !dm.VersionAtLeast(20, 4) || !dm.VersionAtLeast(22, 4) || dm.VersionAtLeast(24, 4) {
+		t.Error("VersionAtLeast gives wrong answers for 22.04")
+	}
+
+	rhel := &DistroManager{Type: DistroRHEL, ID: "rhel"}
+	rhel.Major, rhel.Minor = parseVersionID("9.2")
+	if rhel.UnifiedGrubConfig() {
+		t.Error("RHEL 9.2 should not use the unified GRUB config")
+	}
+	rhel.Major, rhel.Minor = parseVersionID("9.3")
+	if !rhel.UnifiedGrubConfig() {
+		t.Error("RHEL 9.3 should use the unified GRUB config")
+	}
+}
+
+func TestIsOstree(t *testing.T) {
+	root := t.TempDir()
+	if IsOstree(root) {
+		t.Fatal("plain root detected as ostree")
+	}
+	os.MkdirAll(filepath.Join(root, "run"), 0755)
+	os.WriteFile(filepath.Join(root, "run", "ostree-booted"), nil, 0644)
+	if !IsOstree(root) {
+		t.Error("/run/ostree-booted not detected")
+	}
+
+	dm := &DistroManager{Type: DistroRHEL, Ostree: true}
+	if err := dm.InstallPackage("ethtool"); err == nil {
+		t.Error("InstallPackage should refuse on rpm-ostree")
+	}
+}
+
+func TestReadOnlyRefusesChanges(t *testing.T) {
+	ReadOnly = true
+	defer func() { ReadOnly = false }()
+
+	dm := &DistroManager{Type: DistroDebian}
+	if err := dm.InstallPackage("ethtool"); !errors.Is(err, ErrReadOnly) {
+		t.Errorf("InstallPackage in read-only mode: got %v, want ErrReadOnly", err)
+	}
+	if err := dm.UpdateGrub(); !errors.Is(err, ErrReadOnly) {
+		t.Errorf("UpdateGrub in read-only mode: got %v, want ErrReadOnly", err)
+	}
+	bm := &BackupManager{BackupDir: filepath.Join(t.TempDir(), "backup")}
+	if err := bm.Initialize(); !errors.Is(err, ErrReadOnly) {
+		t.Errorf("Initialize in read-only mode: got %v, want ErrReadOnly", err)
+	}
+	if FileExists(bm.BackupDir) {
+		t.Error("read-only mode must not create the backup directory")
+	}
+}
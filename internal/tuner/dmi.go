@@ -0,0 +1,150 @@
+package tuner
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// DMIInfo is the SMBIOS identity of the VM (/sys/class/dmi/id)
+type DMIInfo struct {
+	Vendor      string   `json:"vendor"`
+	Product     string   `json:"product"`
+	Serial      string   `json:"serial,omitempty"`
+	UUID        string   `json:"uuid,omitempty"`
+	BIOSVendor  string   `json:"bios_vendor"`
+	BIOSVersion string   `json:"bios_version"`
+	BIOSDate    string   `json:"bios_date"`
+	Firmware    string   `json:"firmware"`
+	ESXiEra     string   `json:"esxi_era,omitempty"`
+	Hints       []string `json:"hints,omitempty"`
+}
+
+// esxiEras maps the firmware build date to the ESXi release that shipped it.
+// VMware refreshes the virtual firmware with each major release.
+var esxiEras = []struct {
+	Since time.Time
+	Era   string
+}{
+	{time.Date(2022, 7, 1, 0, 0, 0, 0, time.UTC), "ESXi 8.x"},
+	{time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC), "ESXi 7.x"},
+	{time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC), "ESXi 6.7"},
+	{time.Date(2016, 1, 1, 0, 0, 0, 0, time.UTC), "ESXi 6.5"},
+	{time.Time{}, "ESXi 6.0 or earlier"},
+}
+
+// ReadDMI reads and decodes the DMI fields. Serial and UUID are only
+// readable by root. fsRoot allows running against a fixture tree.
+func ReadDMI(fsRoot string) DMIInfo {
+	dir := filepath.Join(fsRoot, "/sys/class/dmi/id")
+	read := func(name string) string {
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			return ""
+		}
+		return strings.TrimSpace(string(data))
+	}
+
+	info := DMIInfo{
+		Vendor:      read("sys_vendor"),
+		Product:     read("product_name"),
+		Serial:      read("product_serial"),
+		UUID:        strings.ToLower(read("product_uuid")),
+		BIOSVendor:  read("bios_vendor"),
+		BIOSVersion: read("bios_version"),
+		BIOSDate:    read("bios_date"),
+	}
+
+	info.Firmware = "BIOS"
+	if strings.HasPrefix(info.BIOSVersion, "VMW") || FileExists(filepath.Join(fsRoot, "/sys/firmware/efi")) {
+		info.Firmware = "EFI"
+	}
+	if strings.Contains(info.Vendor, "VMware") {
+		info.ESXiEra = esxiEra(info.BIOSVersion, info.BIOSDate)
+		info.Hints = platformHints(info.Product)
+	}
+	return info
+}
+
+// esxiEra infers the ESXi release from the firmware: VMW201 EFI builds
+// come with ESXi 8, otherwise the BIOS date (MM/DD/YYYY) gives the era
+func esxiEra(version, date string) string {
+	if strings.HasPrefix(version, "VMW201") {
+		return "ESXi 8.x"
+	}
+	built, err := time.Parse("01/02/2006", date)
+	if err != nil {
+		return ""
+	}
+	for _, e := range esxiEras {
+		if !built.Before(e.Since) {
+			return e.Era
+		}
+	}
+	return ""
+}
+
+// platformHints describes the virtual hardware generation from the product name
+func platformHints(product string) []string {
+	var hints []string
+	switch {
+	case product == "VMware Virtual Platform":
+		hints = append(hints, "legacy i440BX platform (BIOS firmware, any hardware version)")
+	case strings.HasPrefix(product, "VMware") && strings.HasSuffix(product, ",1"):
+		gen := strings.TrimSuffix(strings.TrimPrefix(product, "VMware"), ",1")
+		hints = append(hints, fmt.Sprintf("platform generation %s (EFI firmware)", gen))
+		if gen == "20" || gen == "21" {
+			hints = append(hints, "hardware version 20 or later")
+		}
+	}
+	return hints
+}
+
+// hardwareHints adds the hints given by the virtual devices
+func hardwareHints(devices []PCIDevice) []string {
+	var hints []string
+	for _, dev := range devices {
+		switch dev.VendorID + ":" + dev.DeviceID {
+		case "15ad:07f0":
+			hints = append(hints, "NVMe controller: hardware version 13 or later")
+		case "15ad:0820":
+			hints = append(hints, "PVRDMA adapter: hardware version 13 or later")
+		case "15ad:0779":
+			hints = append(hints, "USB 3.1 controller: hardware version 17 or later")
+		}
+	}
+	return dedupe(hints)
+}
+
+// dedupe removes repeated strings, keeping the order
+func dedupe(values []string) []string {
+	seen := make(map[string]bool)
+	var out []string
+	for _, v := range values {
+		if !seen[v] {
+			seen[v] = true
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+// printDMI prints the platform identity for the hardware inspector
+func printDMI(info DMIInfo) {
+	PrintInfo("Platform:  %s %s", info.Vendor, info.Product)
+	PrintInfo("Firmware:  %s (%s, %s)", info.Firmware, info.BIOSVersion, info.BIOSDate)
+	if info.UUID != "" {
+		PrintInfo("UUID:      %s", info.UUID)
+	}
+	if info.Serial != "" {
+		PrintInfo("Serial:    %s", info.Serial)
+	}
+	if info.ESXiEra != "" {
+		PrintInfo("Firmware era: %s (virtual firmware of the host when the VM was last powered on)", info.ESXiEra)
+	}
+	for _, hint := range info.Hints {
+		PrintInfo("Hint: %s", hint)
+	}
+}
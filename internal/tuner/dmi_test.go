@@ -0,0 +1,112 @@
+package tuner
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestReadDMI(t *testing.T) {
+	root := t.TempDir()
+	dir := filepath.Join(root, "sys/class/dmi/id")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	fields := map[string]string{
+		"sys_vendor":     "VMware, Inc.",
+		"product_name":   "VMware20,1",
+		"product_uuid":   "4210ABCD-1234-5678-9ABC-DEF012345678",
+		"product_serial": "VMware-42 10 ab cd 12 34 56 78-9a bc de f0 12 34 56 78",
+		"bios_vendor":    "VMware, Inc.",
+		"bios_version":   "VMW201.00V.20192059.B64.2207280713",
+		"bios_date":      "07/28/2022",
+	}
+	for name, value := range fields {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(value+"\n"), 0444); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	info := ReadDMI(root)
+	if info.Firmware != "EFI" || info.ESXiEra != "ESXi 8.x" {
+		t.Errorf("firmware = %q, era = %q, want EFI / ESXi 8.x", info.Firmware, info.ESXiEra)
+	}
+	if info.UUID != "4210abcd-1234-5678-9abc-def012345678" {
+		t.Errorf("UUID = %q", info.UUID)
+	}
+	if len(info.Hints) != 2 {
+		t.Errorf("expected generation hints, got %v", info.Hints)
+	}
+}
+
+func TestESXiEra(t *testing.T) {
+	tests := []struct {
+		version, date, want string
+	}{
+		{"6.00", "12/12/2018", "ESXi 6.7"},
+		{"6.00", "11/12/2020", "ESXi 7.x"},
+		{"6.00", "04/05/2016", "ESXi 6.5"},
+		{"6.00", "07/02/2015", "ESXi 6.0 or earlier"},
+		{"VMW71.00V.16722896.B64.2008100651", "08/10/2020", "ESXi 7.x"},
+		{"6.00", "", ""},
+	}
+	for _, tt := range tests {
+		if got := esxiEra(tt.version, tt.date); got != tt.want {
+			t.Errorf("esxiEra(%q, %q) = %q, want %q", tt.version, tt.date, got, tt.want)
+		}
+	}
+}
+
+func TestParseHostVersion(t *testing.T) {
+	out := "session = 5a8f\nversion = VMware ESXi 7.0.3 build-21930508\n"
+	v, ok := parseHostVersion(out)
+	if !ok || v.Major != 7 || v.Minor != 0 || v.Estimated {
+		t.Fatalf("unexpected version %+v", v)
+	}
+	if !v.AtLeast(6, 7) || v.AtLeast(8, 0) {
+		t.Errorf("AtLeast comparisons wrong for %s", v)
+	}
+	if _, ok := parseHostVersion("no host info"); ok {
+		t.Error("expected no version")
+	}
+}
+
+func TestESXiAdvisories(t *testing.T) {
+	hw := HardwareInfo{NICs: []NICInfo{{Name: "ens192", Driver: "vmxnet3"}}}
+
+	old := ESXiAdvisories(ESXiVersion{Major: 6, Minor: 0}, hw, false)
+	warnings := 0
+	for _, a := range old {
+		if a.Warning {
+			warnings++
+		}
+	}
+	// end of support and no tunnel offload
+	if warnings != 2 {
+		t.Errorf("expected 2 warnings for ESXi 6.0, got %+v", old)
+	}
+
+	for _, a := range ESXiAdvisories(ESXiVersion{Major: 8, Minor: 0}, hw, true) {
+		if a.Warning {
+			t.Errorf("unexpected warning on ESXi 8.0: %s", a.Message)
+		}
+	}
+	if fastControllers(ESXiVersion{Major: 6, Minor: 0}) != "VMware Paravirtual (PVSCSI)" {
+		t.Error("NVMe should not be recommended before ESXi 6.5")
+	}
+}
+
+func TestArmQuirks(t *testing.T) {
+	hw := HardwareInfo{Arch: archArm64, DMI: DMIInfo{ESXiEra: "ESXi 6.0 or earlier", Hints: []string{"legacy i440BX platform (BIOS firmware, any hardware version)"}}}
+	applyArmQuirks(&hw)
+	if hw.DMI.ESXiEra != "" || len(hw.DMI.Hints) != 1 || strings.Contains(hw.DMI.Hints[0], "i440BX") {
+		t.Errorf("x86 firmware hints kept on ESXi-Arm: %+v", hw.DMI)
+	}
+
+	for _, a := range ESXiAdvisories(ESXiVersion{Major: 7, Minor: 0}, hw, false) {
+		if strings.Contains(a.Message, "Precision Clock") {
+			t.Errorf("x86-only advisory on ESXi-Arm: %s", a.Message)
+		}
+	}
+}
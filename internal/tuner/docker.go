@@ -15,6 +15,20 @@ func NewDockerTuner() *DockerTuner {
 	return &DockerTuner{}
 }
 
+func init() {
+	Register(Module{
+		Name:        "docker",
+		Label:       "Optimize Docker",
+		Description: "Container log rotation and cleanup",
+		Category:    CategoryMaintenance,
+		Menu:        15,
+		RequireRoot: true,
+		Run: func(ctx *ModuleContext) error {
+			return NewDockerTuner().Run()
+		},
+	})
+}
+
 // Run performs the optimization
 func (dt *DockerTuner) Run() error {
 	PrintStep("Docker Optimizer")
@@ -60,7 +74,9 @@ func (dt *DockerTuner) Run() error {
 					PrintWarning("Failed to write daemon.json: %v", err)
 				} else {
 					PrintSuccess("Configuration created. Restart Docker to apply.")
-					exec.Command("systemctl", "restart", "docker").Run()
+					if !SkipExcluded("docker", ExcludeService, "docker") {
+						exec.Command("systemctl", "restart", "docker").Run()
+					}
 				}
 			} else {
 				PrintWarning("daemon.json exists. Please add log-opts manually to avoid overwriting custom config.")
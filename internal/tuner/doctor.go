@@ -0,0 +1,301 @@
+package tuner
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/fatih/color"
+)
+
+// doctorCheck is one diagnostic whose output goes to a file of the bundle
+type doctorCheck struct {
+	File string
+	Run  func() error
+}
+
+// doctorCommands are the raw outputs included as-is (missing tools are noted)
+var doctorCommands = []struct {
+	File string
+	Args []string
+}{
+	{"raw/dmesg.txt", []string{"dmesg", "-T"}},
+	{"raw/journal-errors.txt", []string{"journalctl", "-b", "-p", "err", "--no-pager", "-n", "2000"}},
+	{"raw/journal-vmware-tuner.txt", []string{"journalctl", "-t", "vmware-tuner", "--no-pager", "-n", "2000"}},
+	{"raw/ip-addr.txt", []string{"ip", "-s", "addr"}},
+	{"raw/ip-route.txt", []string{"ip", "route"}},
+	{"raw/lsblk.txt", []string{"lsblk", "-o", "NAME,SIZE,TYPE,FSTYPE,MOUNTPOINT,SCHED,ROTA"}},
+	{"raw/lspci.txt", []string{"lspci", "-nnk"}},
+	{"raw/sysctl.txt", []string{"sysctl", "-a"}},
+	{"raw/systemctl-failed.txt", []string{"systemctl", "--failed", "--no-pager"}},
+	{"raw/vmware-toolbox.txt", []string{"vmware-toolbox-cmd", "-v"}},
+}
+
+// doctorFiles are copied into the bundle when present
+var doctorFiles = []string{
+	"/proc/cmdline",
+	"/proc/meminfo",
+	"/proc/mounts",
+	"/etc/fstab",
+	"/etc/os-release",
+	ActionLogPath,
+}
+
+// DoctorTuner runs every diagnostic and packages the outputs into a support bundle
+type DoctorTuner struct {
+	Distro     *DistroManager
+	VCenter    VCenterConfig
+	ConfigPath string
+	Version    string
+	OutputDir  string
+	Redactor   *Redactor // nil: IP addresses and host names are kept
+}
+
+// NewDoctorTuner creates a new doctor
+func NewDoctorTuner(distro *DistroManager, vcenter VCenterConfig, configPath, version, outputDir string, redactor *Redactor) *DoctorTuner {
+	return &DoctorTuner{
+		Distro:     distro,
+		VCenter:    vcenter,
+		ConfigPath: configPath,
+		Version:    version,
+		OutputDir:  outputDir,
+		Redactor:   redactor,
+	}
+}
+
+// checks returns the diagnostics of the bundle, in the order they run
+func (dt *DoctorTuner) checks() []doctorCheck {
+	return []doctorCheck{
+		{"info.txt", NewInfoTuner().Run},
+		{"hardware.txt", NewHardwareTuner(dt.Distro).Run},
+		{"audit.txt", NewAuditTuner(dt.Distro).RunAudit},
+		{"logdoctor.txt", dt.logDoctor},
+		{"failed-units.txt", func() error { return NewUnitDoctorTuner().Run(nil) }},
+		{"fshealth.txt", NewFSHealthTuner().Run},
+		{"netcheck.txt", NewNetCheckTuner(dt.Distro, dt.VCenter).Run},
+		{"packet-drops.txt", NewNetworkTuner(false).CheckPacketDrops},
+		{"backups.txt", printBackupList},
+	}
+}
+
+// logDoctor writes the findings into the bundle only (no findings file)
+func (dt *DoctorTuner) logDoctor() error {
+	ld := NewLogDoctorTuner(dt.Distro)
+	ld.Limit, ld.OutputPath = 1000, ""
+	return ld.Run()
+}
+
+// Run collects the bundle and returns the path of the archive
+func (dt *DoctorTuner) Run() (string, error) {
+	PrintStep("Doctor (Support Bundle)")
+
+	host, _ := os.Hostname()
+	name := fmt.Sprintf("vmware-tuner-doctor-%s-%s", host, time.Now().Format("20060102-150405"))
+	if dt.Redactor != nil {
+		name = fmt.Sprintf("vmware-tuner-doctor-%s", time.Now().Format("20060102-150405"))
+	}
+
+	tmp, err := os.MkdirTemp("", "vmware-tuner-doctor")
+	if err != nil {
+		return "", fmt.Errorf("failed to create temporary directory: %w", err)
+	}
+	defer os.RemoveAll(tmp)
+	staging := filepath.Join(tmp, name)
+	if err := os.MkdirAll(filepath.Join(staging, "raw"), 0700); err != nil {
+		return "", fmt.Errorf("failed to create %s: %w", staging, err)
+	}
+
+	var summary strings.Builder
+	fmt.Fprintf(&summary, "vmware-tuner %s doctor, %s\n\n", dt.Version, time.Now().Format(time.RFC3339))
+	note := func(file string, start time.Time, err error) {
+		status := "ok"
+		if err != nil {
+			status = "error: " + err.Error()
+		}
+		fmt.Fprintf(&summary, "%-36s %6.1fs  %s\n", file, time.Since(start).Seconds(), status)
+	}
+
+	for _, c := range dt.checks() {
+		PrintInfo("Running %s...", strings.TrimSuffix(c.File, ".txt"))
+		start := time.Now()
+		err := captureOutput(filepath.Join(staging, c.File), c.Run)
+		note(c.File, start, err)
+	}
+
+	start := time.Now()
+	err = writeJSONFile(filepath.Join(staging, "inventory.json"), CollectInventory(dt.Version))
+	note("inventory.json", start, err)
+
+	PrintInfo("Collecting raw outputs and logs...")
+	for _, c := range doctorCommands {
+		start := time.Now()
+		note(c.File, start, streamCommand(filepath.Join(staging, c.File), c.Args))
+	}
+	files := append(append([]string(nil), doctorFiles...), dt.ConfigPath)
+	for _, src := range files {
+		if src == "" || !FileExists(src) {
+			continue
+		}
+		dst := filepath.Join(staging, "files", strings.TrimPrefix(src, "/"))
+		start := time.Now()
+		err := os.MkdirAll(filepath.Dir(dst), 0700)
+		if err == nil {
+			err = copyProcFile(src, dst)
+		}
+		note("files"+src, start, err)
+	}
+
+	if err := os.WriteFile(filepath.Join(staging, "summary.txt"), []byte(summary.String()), 0600); err != nil {
+		return "", fmt.Errorf("failed to write the summary: %w", err)
+	}
+
+	// Proxy passwords and webhook tokens in the config or the environment
+	// never leave the VM
+	filter := maskSecrets
+	if dt.Redactor != nil {
+		PrintInfo("Redacting the bundle...")
+		filter = func(s string) string { return dt.Redactor.Redact(maskSecrets(s)) }
+	}
+	if err := filterTree(staging, filter); err != nil {
+		return "", err
+	}
+
+	if err := os.MkdirAll(dt.OutputDir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create %s: %w", dt.OutputDir, err)
+	}
+	archive := filepath.Join(dt.OutputDir, name+".tar.gz")
+	if out, err := exec.Command("tar", "-czf", archive, "-C", tmp, name).CombinedOutput(); err != nil {
+		return "", fmt.Errorf("failed to create archive: %v: %s", err, string(out))
+	}
+	if err := os.Chmod(archive, 0600); err != nil {
+		return "", fmt.Errorf("failed to protect %s: %w", archive, err)
+	}
+
+	PrintSuccess("Support bundle written to %s", archive)
+	if dt.Redactor == nil {
+		PrintWarning("The bundle contains IP addresses and host names: use --redact before sharing it outside your organization")
+	}
+	return archive, nil
+}
+
+// streamCommand writes the output of a command to path as it comes (dmesg or
+// the journal can be large). The file is removed when the command cannot run.
+func streamCommand(path string, args []string) error {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", path, err)
+	}
+	defer f.Close()
+
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Stdout, cmd.Stderr = f, f
+	if err := cmd.Start(); err != nil {
+		f.Close()
+		os.Remove(path)
+		return err
+	}
+	return cmd.Wait()
+}
+
+// captureOutput runs fn with the standard and colored outputs sent to path
+func captureOutput(path string, fn func() error) error {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", path, err)
+	}
+	defer f.Close()
+
+	stdout, colorOut, noColor := os.Stdout, color.Output, color.NoColor
+	os.Stdout, color.Output, color.NoColor = f, f, true
+	defer func() {
+		os.Stdout, color.Output, color.NoColor = stdout, colorOut, noColor
+	}()
+	return fn()
+}
+
+// printBackupList prints the backup sessions and their files
+func printBackupList() error {
+	backups, err := ListBackups()
+	if err != nil {
+		return err
+	}
+	if len(backups) == 0 {
+		fmt.Printf("No backups in %s\n", BackupRoot)
+		return nil
+	}
+	for _, ts := range backups {
+		m, err := LoadManifest(filepath.Join(BackupRoot, ts))
+		if err != nil {
+			fmt.Printf("%s: %v\n", ts, err)
+			continue
+		}
+		fmt.Printf("%s (%d files)\n", ts, len(m.Entries))
+		for _, e := range m.Entries {
+			fmt.Printf("  %s\n", e.OriginalPath)
+		}
+	}
+	return nil
+}
+
+// writeJSONFile writes v as indented JSON
+func writeJSONFile(path string, v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0600)
+}
+
+// copyProcFile copies a file that may be a /proc entry (size 0, so no io.Copy
+// assumptions on the length)
+func copyProcFile(src, dst string) error {
+	data, err := os.ReadFile(src)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(dst, data, 0600)
+}
+
+// urlCredentialsRe matches the password of user:password@host URLs
+var urlCredentialsRe = regexp.MustCompile(`(://[^/\s:@]+):[^/\s@]+@`)
+
+// maskURLCredentials hides the passwords embedded in URLs
+func maskURLCredentials(s string) string {
+	return urlCredentialsRe.ReplaceAllString(s, "$1:***@")
+}
+
+// webhookURLRe matches the webhook URL of the notify: section (YAML or
+// JSON): chat services carry the token in the path or the query string
+var webhookURLRe = regexp.MustCompile(`(webhook"?:\s*["']?)([a-zA-Z][a-zA-Z0-9+.-]*://[^/\s"'?#]+)[^\s"']*`)
+
+// maskWebhooks keeps the scheme and host of the webhook URLs only
+func maskWebhooks(s string) string {
+	return webhookURLRe.ReplaceAllString(s, "$1$2/***")
+}
+
+// maskSecrets hides the URL passwords and the webhook tokens
+func maskSecrets(s string) string {
+	return maskWebhooks(maskURLCredentials(s))
+}
+
+// filterTree rewrites every file under dir through filter
+func filterTree(dir string, filter func(string) string) error {
+	return filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", path, err)
+		}
+		if err := os.WriteFile(path, []byte(filter(string(data))), info.Mode()); err != nil {
+			return fmt.Errorf("failed to redact %s: %w", path, err)
+		}
+		return nil
+	})
+}
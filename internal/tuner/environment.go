@@ -0,0 +1,238 @@
+package tuner
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Environment kinds where the tuner does not own a (virtual) machine
+const (
+	EnvMachine   = ""
+	EnvContainer = "container"
+	EnvWSL       = "wsl"
+	EnvRescue    = "rescue" // chroot, initrd or shell as PID 1: the VM's system is not running
+)
+
+// Environment describes where the binary is running
+type Environment struct {
+	Kind         string // EnvMachine, EnvContainer, EnvWSL or EnvRescue
+	Detail       string // docker, podman, lxc, WSL2, chroot, initrd...
+	HasSystemd   bool
+	ReadOnlyRoot bool // / is mounted read-only (emergency mode, rescue media)
+}
+
+// moduleRequirement is what a module needs from the environment
+type moduleRequirement int
+
+const (
+	needsBoot     moduleRequirement = 1 << iota // bootloader, fstab and block devices
+	needsHardware                               // DMI, virtual NICs and disks, VMware backdoor
+	needsKernel                                 // writable kernel parameters
+	needsSystemd                                // systemd as PID 1
+)
+
+// moduleRequirements lists the modules that cannot run everywhere
+var moduleRequirements = map[string]moduleRequirement{
+	"grub":       needsBoot,
+	"fstab":      needsBoot,
+	"swap":       needsBoot,
+	"trim":       needsBoot | needsSystemd,
+	"disk":       needsBoot,
+	"template":   needsBoot,
+	"realtime":   needsBoot,
+	"isolation":  needsBoot,
+	"io":         needsHardware,
+	"pvscsi":     needsBoot | needsHardware,
+	"network":    needsHardware,
+	"hardware":   needsHardware,
+	"tools":      needsHardware | needsSystemd,
+	"sysctl":     needsKernel,
+	"ipv6":       needsKernel,
+	"debloat":    needsSystemd,
+	"timesync":   needsSystemd | needsKernel,
+	"ssh":        needsSystemd,
+	"syslog":     needsSystemd,
+	"snmp":       needsSystemd,
+	"monitoring": needsSystemd,
+	"restart":    needsSystemd,
+	"units":      needsSystemd,
+	"fshealth":   needsBoot,
+}
+
+// requirementReasons explains why a requirement is not met
+var requirementReasons = map[moduleRequirement]string{
+	needsBoot:     "no bootloader or block devices of its own",
+	needsHardware: "no virtual hardware (DMI, vmxnet3, PVSCSI)",
+	needsKernel:   "kernel parameters belong to the host",
+	needsSystemd:  "systemd is not running",
+}
+
+// rescueReasons replace requirementReasons in rescue environments
+var rescueReasons = map[moduleRequirement]string{
+	needsHardware: "devices are configured by the rescue kernel, not the VM's",
+	needsKernel:   "the running kernel is the rescue kernel",
+	needsSystemd:  "systemd is not running (services cannot be started)",
+}
+
+// DetectEnvironment detects containers, WSL and rescue environments. fsRoot is "" for /.
+func DetectEnvironment(fsRoot string) Environment {
+	env := Environment{
+		HasSystemd:   FileExists(filepath.Join(fsRoot, "/run/systemd/system")),
+		ReadOnlyRoot: rootReadOnly(fsRoot),
+	}
+
+	if data, err := os.ReadFile(filepath.Join(fsRoot, "/proc/sys/kernel/osrelease")); err == nil {
+		release := strings.ToLower(string(data))
+		if strings.Contains(release, "microsoft") || strings.Contains(release, "wsl") {
+			env.Kind = EnvWSL
+			env.Detail = "WSL1"
+			if strings.Contains(release, "wsl2") {
+				env.Detail = "WSL2"
+			}
+			return env
+		}
+	}
+
+	switch {
+	case FileExists(filepath.Join(fsRoot, "/.dockerenv")):
+		env.Detail = "docker"
+	case FileExists(filepath.Join(fsRoot, "/run/.containerenv")):
+		env.Detail = "podman"
+	default:
+		// Written by systemd and most runtimes (lxc, systemd-nspawn, podman)
+		if data, err := os.ReadFile(filepath.Join(fsRoot, "/run/systemd/container")); err == nil {
+			env.Detail = strings.TrimSpace(string(data))
+		} else if data, err := os.ReadFile(filepath.Join(fsRoot, "/proc/1/cgroup")); err == nil {
+			cgroup := string(data)
+			for _, runtime := range []string{"docker", "kubepods", "lxc", "containerd"} {
+				if strings.Contains(cgroup, runtime) {
+					env.Detail = runtime
+					break
+				}
+			}
+		}
+	}
+	if env.Detail != "" {
+		env.Kind = EnvContainer
+		return env
+	}
+
+	if env.Detail = rescueContext(fsRoot, env.HasSystemd); env.Detail != "" {
+		env.Kind = EnvRescue
+	}
+	return env
+}
+
+// rescueContext returns the kind of rescue environment, "" on a booted system
+func rescueContext(fsRoot string, hasSystemd bool) string {
+	if FileExists(filepath.Join(fsRoot, "/etc/initrd-release")) {
+		return "initrd"
+	}
+	// A chroot has another / than PID 1 (needs root to stat /proc/1/root)
+	root, err := os.Stat(filepath.Join(fsRoot, "/"))
+	pid1Root, err1 := os.Stat(filepath.Join(fsRoot, "/proc/1/root"))
+	if err == nil && err1 == nil && !os.SameFile(root, pid1Root) {
+		return "chroot"
+	}
+	// init=/bin/bash or a rescue shell
+	if data, err := os.ReadFile(filepath.Join(fsRoot, "/proc/1/comm")); err == nil && !hasSystemd {
+		if comm := strings.TrimSpace(string(data)); comm != "systemd" && comm != "init" {
+			return "PID 1 is " + comm
+		}
+	}
+	return ""
+}
+
+// rootReadOnly reports whether / is mounted read-only (last mount wins)
+func rootReadOnly(fsRoot string) bool {
+	data, err := os.ReadFile(filepath.Join(fsRoot, "/proc/mounts"))
+	if err != nil {
+		return false
+	}
+	readOnly := false
+	for _, line := range strings.Split(string(data), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 4 || fields[1] != "/" {
+			continue
+		}
+		readOnly = false
+		for _, opt := range strings.Split(fields[3], ",") {
+			readOnly = readOnly || opt == "ro"
+		}
+	}
+	return readOnly
+}
+
+// CheckWritable returns an error with the way out when / is read-only, so
+// changes are refused up front instead of failing one by one
+func (e Environment) CheckWritable() error {
+	if !e.ReadOnlyRoot {
+		return nil
+	}
+	return fmt.Errorf("/ is mounted read-only (%s): remount it with 'mount -o remount,rw /' or boot the VM normally", e.modeName())
+}
+
+// modeName names the context of a read-only root
+func (e Environment) modeName() string {
+	if e.Kind == EnvMachine {
+		return "emergency or rescue mode"
+	}
+	return e.String()
+}
+
+// unsupported returns the requirements the environment cannot satisfy
+func (e Environment) unsupported() moduleRequirement {
+	var missing moduleRequirement
+	switch e.Kind {
+	case EnvContainer:
+		missing = needsBoot | needsHardware | needsKernel
+	case EnvWSL:
+		missing = needsBoot | needsHardware
+	case EnvRescue:
+		// Files (GRUB, fstab) can be fixed from a chroot, live settings cannot
+		missing = needsHardware | needsKernel
+	default:
+		return 0
+	}
+	if !e.HasSystemd {
+		missing |= needsSystemd
+	}
+	return missing
+}
+
+// Check returns an error explaining why the module cannot run here
+func (e Environment) Check(module string) error {
+	missing := moduleRequirements[module] & e.unsupported()
+	if missing == 0 {
+		return nil
+	}
+
+	var reasons []string
+	for _, req := range []moduleRequirement{needsBoot, needsHardware, needsKernel, needsSystemd} {
+		if missing&req == 0 {
+			continue
+		}
+		if reason, ok := rescueReasons[req]; ok && e.Kind == EnvRescue {
+			reasons = append(reasons, reason)
+		} else {
+			reasons = append(reasons, requirementReasons[req])
+		}
+	}
+	return fmt.Errorf("module '%s' is not available in %s: %s", module, e, strings.Join(reasons, ", "))
+}
+
+// String returns a readable name, e.g. "a docker container"
+func (e Environment) String() string {
+	switch e.Kind {
+	case EnvContainer:
+		return "a " + e.Detail + " container"
+	case EnvWSL:
+		return e.Detail
+	case EnvRescue:
+		return "a rescue environment (" + e.Detail + ")"
+	default:
+		return "a virtual machine"
+	}
+}
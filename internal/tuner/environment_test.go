@@ -0,0 +1,97 @@
+package tuner
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDetectEnvironment(t *testing.T) {
+	root := t.TempDir()
+	if env := DetectEnvironment(root); env.Kind != EnvMachine {
+		t.Fatalf("empty root detected as %s", env)
+	}
+	if err := (Environment{}).Check("grub"); err != nil {
+		t.Errorf("grub refused on a machine: %v", err)
+	}
+
+	os.WriteFile(filepath.Join(root, ".dockerenv"), nil, 0644)
+	env := DetectEnvironment(root)
+	if env.Kind != EnvContainer || env.Detail != "docker" {
+		t.Fatalf("expected docker container, got %+v", env)
+	}
+	if env.Check("grub") == nil || env.Check("sysctl") == nil || env.Check("debloat") == nil {
+		t.Error("boot, kernel and systemd modules should be refused in a container without systemd")
+	}
+	if err := env.Check("audit"); err != nil {
+		t.Errorf("audit refused in a container: %v", err)
+	}
+
+	wsl := t.TempDir()
+	os.MkdirAll(filepath.Join(wsl, "proc", "sys", "kernel"), 0755)
+	os.WriteFile(filepath.Join(wsl, "proc", "sys", "kernel", "osrelease"), []byte("5.15.153.1-microsoft-standard-WSL2\n"), 0644)
+	os.MkdirAll(filepath.Join(wsl, "run", "systemd", "system"), 0755)
+	env = DetectEnvironment(wsl)
+	if env.Kind != EnvWSL || env.Detail != "WSL2" || !env.HasSystemd {
+		t.Fatalf("expected WSL2 with systemd, got %+v", env)
+	}
+	if env.Check("network") == nil {
+		t.Error("network tuning should be refused on WSL")
+	}
+	if err := env.Check("sysctl"); err != nil {
+		t.Errorf("sysctl refused on WSL: %v", err)
+	}
+}
+
+func TestDetectRescue(t *testing.T) {
+	initrd := t.TempDir()
+	os.MkdirAll(filepath.Join(initrd, "etc"), 0755)
+	os.WriteFile(filepath.Join(initrd, "etc", "initrd-release"), nil, 0644)
+	if env := DetectEnvironment(initrd); env.Kind != EnvRescue || env.Detail != "initrd" {
+		t.Errorf("expected initrd, got %+v", env)
+	}
+
+	// PID 1 sees another root than ours
+	chroot := t.TempDir()
+	os.MkdirAll(filepath.Join(chroot, "proc", "1"), 0755)
+	os.Symlink(t.TempDir(), filepath.Join(chroot, "proc", "1", "root"))
+	env := DetectEnvironment(chroot)
+	if env.Kind != EnvRescue || env.Detail != "chroot" {
+		t.Fatalf("expected chroot, got %+v", env)
+	}
+	if err := env.Check("grub"); err != nil {
+		t.Errorf("grub refused in a chroot: %v", err)
+	}
+	if env.Check("sysctl") == nil || env.Check("network") == nil || env.Check("debloat") == nil {
+		t.Error("kernel, hardware and systemd modules should be refused in a chroot")
+	}
+
+	shell := t.TempDir()
+	os.MkdirAll(filepath.Join(shell, "proc", "1"), 0755)
+	os.WriteFile(filepath.Join(shell, "proc", "1", "comm"), []byte("bash\n"), 0644)
+	if env := DetectEnvironment(shell); env.Kind != EnvRescue || env.Detail != "PID 1 is bash" {
+		t.Errorf("expected a shell as PID 1, got %+v", env)
+	}
+	os.WriteFile(filepath.Join(shell, "proc", "1", "comm"), []byte("systemd\n"), 0644)
+	if env := DetectEnvironment(shell); env.Kind != EnvMachine {
+		t.Errorf("systemd as PID 1 detected as %s", env)
+	}
+}
+
+func TestRootReadOnly(t *testing.T) {
+	root := t.TempDir()
+	os.MkdirAll(filepath.Join(root, "proc"), 0755)
+	mounts := "/dev/sda1 / ext4 ro,relatime 0 0\nproc /proc proc rw 0 0\n"
+	os.WriteFile(filepath.Join(root, "proc", "mounts"), []byte(mounts), 0644)
+	env := DetectEnvironment(root)
+	if !env.ReadOnlyRoot || env.CheckWritable() == nil {
+		t.Fatalf("read-only / not detected: %+v", env)
+	}
+
+	// Remounted read-write: the last entry wins
+	mounts += "/dev/sda1 / ext4 rw,relatime 0 0\n"
+	os.WriteFile(filepath.Join(root, "proc", "mounts"), []byte(mounts), 0644)
+	if env := DetectEnvironment(root); env.ReadOnlyRoot {
+		t.Error("remounted / still read-only")
+	}
+}
@@ -0,0 +1,104 @@
+package tuner
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Error categories returned by the tuners (wrapped with %w): the end of run
+// summary groups the failed modules by cause, so a missing prerequisite is
+// not mistaken for a broken module
+var (
+	ErrNeedsRoot         = errors.New("root privileges required")
+	ErrOffline           = errors.New("no internet access")
+	ErrUnsupportedDistro = errors.New("unsupported distribution")
+	ErrValidationFailed  = errors.New("validation failed")
+)
+
+// errorCategories lists the categories with the advice printed in the summary
+var errorCategories = []struct {
+	Err    error
+	Advice string
+}{
+	{ErrNeedsRoot, "run again with sudo"},
+	{ErrOffline, "use --pkg-dir or a package bundle (vmware-tuner bundle create), or configure proxy:"},
+	{ErrUnsupportedDistro, "only Debian/Ubuntu and the RHEL family are supported"},
+	{ErrValidationFailed, "fix the values reported above (config file or flags)"},
+	{ErrReadOnly, "run it from the tuning pipeline or its own command"},
+}
+
+// ModuleFailure is a module of the run that returned an error
+type ModuleFailure struct {
+	Module string
+	Err    error
+}
+
+// RunSummary collects the outcome of the modules of a tuning run: one failed
+// module does not stop the others, the summary reports them together
+type RunSummary struct {
+	Succeeded []string
+	Failed    []ModuleFailure
+	Durations map[string]time.Duration // modules run one after the other
+	mark      time.Time
+}
+
+// NewRunSummary starts timing the modules of a run
+func NewRunSummary() *RunSummary {
+	return &RunSummary{Durations: make(map[string]time.Duration), mark: time.Now()}
+}
+
+// Record adds the outcome of a module, timed since the previous one
+func (rs *RunSummary) Record(module string, err error) {
+	if !rs.mark.IsZero() {
+		now := time.Now()
+		rs.Durations[module] += now.Sub(rs.mark)
+		rs.mark = now
+	}
+	if err != nil {
+		rs.Failed = append(rs.Failed, ModuleFailure{Module: module, Err: err})
+		return
+	}
+	rs.Succeeded = append(rs.Succeeded, module)
+}
+
+// Err returns an error when a module failed
+func (rs *RunSummary) Err() error {
+	if len(rs.Failed) == 0 {
+		return nil
+	}
+	var modules []string
+	for _, f := range rs.Failed {
+		modules = append(modules, f.Module)
+	}
+	return fmt.Errorf("%d module(s) failed: %s", len(rs.Failed), strings.Join(modules, ", "))
+}
+
+// Print prints the failed modules grouped by category, with what to do
+func (rs *RunSummary) Print() {
+	if len(rs.Failed) == 0 {
+		return
+	}
+	PrintStep("Summary")
+	PrintInfo("%d module(s) applied, %d failed", len(rs.Succeeded), len(rs.Failed))
+
+	printed := make(map[int]bool)
+	for _, c := range errorCategories {
+		var modules []string
+		for i, f := range rs.Failed {
+			if errors.Is(f.Err, c.Err) {
+				modules = append(modules, f.Module)
+				printed[i] = true
+			}
+		}
+		if len(modules) > 0 {
+			PrintWarning("%s: %s (%s)", c.Err, strings.Join(modules, ", "), c.Advice)
+		}
+	}
+	for i, f := range rs.Failed {
+		if !printed[i] {
+			PrintError("%s: %v", f.Module, f.Err)
+		}
+	}
+}
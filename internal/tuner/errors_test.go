@@ -0,0 +1,34 @@
+package tuner
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestRunSummary(t *testing.T) {
+	var rs RunSummary
+	rs.Record("grub", nil)
+	if err := rs.Err(); err != nil {
+		t.Fatalf("no failure, got %v", err)
+	}
+
+	rs.Record("tools", fmt.Errorf("open-vm-tools cannot be installed: %w", ErrOffline))
+	rs.Record("grub", fmt.Errorf("%w: unsupported expert boot parameter", ErrValidationFailed))
+	if err := rs.Err(); err == nil || err.Error() != "2 module(s) failed: tools, grub" {
+		t.Errorf("Err() = %v", err)
+	}
+	if !errors.Is(rs.Failed[0].Err, ErrOffline) || !errors.Is(rs.Failed[1].Err, ErrValidationFailed) {
+		t.Errorf("categories lost: %+v", rs.Failed)
+	}
+}
+
+func TestTypedErrors(t *testing.T) {
+	if _, err := expertBootWarning("nohz_full"); !errors.Is(err, ErrValidationFailed) {
+		t.Errorf("expert boot parameter without CPU list: got %v, want ErrValidationFailed", err)
+	}
+	dm := &DistroManager{Type: DistroUnknown}
+	if err := dm.InstallPackage("ethtool"); !errors.Is(err, ErrUnsupportedDistro) {
+		t.Errorf("InstallPackage on an unknown distribution: got %v, want ErrUnsupportedDistro", err)
+	}
+}
@@ -0,0 +1,159 @@
+package tuner
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// ESXiVersion is the version of the host running the VM
+type ESXiVersion struct {
+	Major     int    `json:"major"`
+	Minor     int    `json:"minor"`
+	Full      string `json:"full,omitempty"`
+	Source    string `json:"source"`
+	Estimated bool   `json:"estimated"`
+}
+
+// Known reports whether a version was found
+func (v ESXiVersion) Known() bool {
+	return v.Major > 0
+}
+
+// AtLeast compares with major.minor
+func (v ESXiVersion) AtLeast(major, minor int) bool {
+	return v.Major > major || (v.Major == major && v.Minor >= minor)
+}
+
+func (v ESXiVersion) String() string {
+	if !v.Known() {
+		return "unknown"
+	}
+	s := v.Full
+	if s == "" {
+		s = fmt.Sprintf("ESXi %d.%d", v.Major, v.Minor)
+	}
+	if v.Estimated {
+		s += " (estimated from " + v.Source + ")"
+	}
+	return s
+}
+
+var hostVersionRe = regexp.MustCompile(`ESXi (\d+)\.(\d+)(?:\.\d+)?(?: build-\d+)?`)
+
+// parseHostVersion extracts the ESXi version from the guestlib session info
+func parseHostVersion(out string) (ESXiVersion, bool) {
+	m := hostVersionRe.FindStringSubmatch(out)
+	if m == nil {
+		return ESXiVersion{}, false
+	}
+	major, _ := strconv.Atoi(m[1])
+	minor, _ := strconv.Atoi(m[2])
+	return ESXiVersion{Major: major, Minor: minor, Full: "VMware " + m[0], Source: "VMware Tools host info"}, true
+}
+
+// eraVersions maps the firmware eras of ReadDMI to the oldest matching release
+var eraVersions = map[string][2]int{
+	"ESXi 8.x":            {8, 0},
+	"ESXi 7.x":            {7, 0},
+	"ESXi 6.7":            {6, 7},
+	"ESXi 6.5":            {6, 5},
+	"ESXi 6.0 or earlier": {6, 0},
+}
+
+// DetectESXiVersion asks VMware Tools for the host version (only exposed when
+// tools.guestlib.enableHostInfo is set on the VM), else estimates it from
+// the virtual firmware
+func DetectESXiVersion(dmi DMIInfo) ESXiVersion {
+	if out, err := exec.Command("vmware-toolbox-cmd", "stat", "raw", "text", "session").Output(); err == nil {
+		if v, ok := parseHostVersion(string(out)); ok {
+			return v
+		}
+	}
+	if mm, ok := eraVersions[dmi.ESXiEra]; ok {
+		return ESXiVersion{Major: mm[0], Minor: mm[1], Source: "virtual firmware", Estimated: true}
+	}
+	return ESXiVersion{}
+}
+
+// Advisory is a guest setting that depends on the ESXi version
+type Advisory struct {
+	Warning bool   `json:"warning"`
+	Message string `json:"message"`
+}
+
+// hasPrecisionClock reports the VMware precision clock (ptp_vmw) device
+func hasPrecisionClock() bool {
+	for _, name := range Sys.Glob("/sys/class/ptp/ptp*/clock_name") {
+		if data, err := os.ReadFile(name); err == nil && strings.Contains(string(data), "vmw") {
+			return true
+		}
+	}
+	return false
+}
+
+// ESXiAdvisories lists the settings that behave differently on this host version
+func ESXiAdvisories(v ESXiVersion, hw HardwareInfo, precisionClock bool) []Advisory {
+	if !v.Known() {
+		return []Advisory{{false, "ESXi version unknown: set tools.guestlib.enableHostInfo = TRUE in the VM advanced settings to expose it"}}
+	}
+
+	var adv []Advisory
+	if v.Estimated {
+		adv = append(adv, Advisory{false, "Exact version: set tools.guestlib.enableHostInfo = TRUE in the VM advanced settings"})
+	}
+	if !v.AtLeast(7, 0) {
+		adv = append(adv, Advisory{true, fmt.Sprintf("ESXi %d.%d is past end of general support: plan the host upgrade", v.Major, v.Minor)})
+	}
+
+	hasVmxnet3 := false
+	for _, nic := range hw.NICs {
+		if nic.Driver == "vmxnet3" {
+			hasVmxnet3 = true
+		}
+	}
+	if hasVmxnet3 {
+		if v.AtLeast(7, 0) {
+			adv = append(adv, Advisory{false, "vmxnet3: up to 32 RSS queues and Geneve/VXLAN offload with hardware version 19+"})
+		} else {
+			adv = append(adv, Advisory{false, "vmxnet3: RSS is capped at 8 queues; more than 8 vCPUs do not add receive queues"})
+			if !v.AtLeast(6, 7) {
+				adv = append(adv, Advisory{true, "vmxnet3: no Geneve/VXLAN offload before ESXi 6.7, keep tx-udp_tnl-segmentation off"})
+			}
+		}
+	}
+
+	// ESXi-Arm has neither the x86 precision clock nor the pre-6.5 hosts
+	if hw.IsArm() {
+		return adv
+	}
+
+	switch {
+	case precisionClock:
+		adv = append(adv, Advisory{false, "Precision Clock present: use it with chrony (refclock PHC /dev/ptp0 poll 3 dpoll -2)"})
+	case v.AtLeast(7, 0):
+		adv = append(adv, Advisory{false, "Precision Clock available (hardware version 17+): add it to the VM for sub-millisecond time instead of NTP"})
+	default:
+		adv = append(adv, Advisory{false, "No Precision Clock before ESXi 7.0: keep NTP (chrony) and disable VMware Tools periodic sync"})
+	}
+
+	if !v.AtLeast(6, 5) {
+		adv = append(adv, Advisory{false, "No virtual NVMe controller before ESXi 6.5: PVSCSI is the fastest option"})
+	}
+	return adv
+}
+
+// printESXi prints the host version and its advisories
+func printESXi(v ESXiVersion, advisories []Advisory) {
+	PrintInfo("ESXi host: %s", v)
+	for _, a := range advisories {
+		if a.Warning {
+			PrintWarning("%s", a.Message)
+		} else {
+			PrintInfo("%s", a.Message)
+		}
+	}
+}
@@ -0,0 +1,20 @@
+package tuner
+
+// archArm64 is the architecture of ESXi-Arm guests (Arm servers, Raspberry Pi)
+const archArm64 = "arm64"
+
+// IsArm reports an ESXi-Arm guest
+func (hw HardwareInfo) IsArm() bool {
+	return hw.Arch == archArm64
+}
+
+// applyArmQuirks adapts the inventory of an ESXi-Arm guest: the firmware
+// dates and product names follow the ESXi-Arm releases, not the x86 ones, so
+// the era and platform generation read from them would be wrong
+func applyArmQuirks(hw *HardwareInfo) {
+	if !hw.IsArm() {
+		return
+	}
+	hw.DMI.ESXiEra = ""
+	hw.DMI.Hints = append([]string{"ESXi-Arm guest (arm64): the virtual firmware does not tell the host release"}, hardwareHints(hw.PCIDevices)...)
+}
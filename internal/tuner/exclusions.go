@@ -0,0 +1,197 @@
+package tuner
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"strings"
+)
+
+// DefaultExclusionsPath lists what the tool must never touch: components
+// managed by a vendor agent or another team
+const DefaultExclusionsPath = "/etc/vmware-tuner/exclusions.yaml"
+
+// Kinds of excluded components
+const (
+	ExcludeMount     = "mount"
+	ExcludeService   = "service"
+	ExcludeInterface = "interface"
+	ExcludeSysctl    = "sysctl"
+)
+
+// Exclusions are the mounts, services, interfaces and sysctl keys (names or
+// patterns) every module leaves alone
+type Exclusions struct {
+	Path       string
+	Mounts     []string
+	Services   []string
+	Interfaces []string
+	Sysctl     []string
+}
+
+// Excluded holds the exclusions in effect for this run (set by LoadExclusions)
+var Excluded Exclusions
+
+// ExclusionSkip is a change left out because of the exclusions
+type ExclusionSkip struct {
+	Module string
+	Kind   string
+	Name   string
+}
+
+// exclusionSkips are the changes skipped during this run
+var exclusionSkips []ExclusionSkip
+
+// LoadExclusions reads the exclusions file and makes it the one in effect.
+// A missing file excludes nothing; an invalid one is an error, so a typo
+// never lets the tool touch what it protects.
+func LoadExclusions(filePath string) error {
+	ex := Exclusions{Path: filePath}
+	data, err := os.ReadFile(filePath)
+	if os.IsNotExist(err) {
+		Excluded = ex
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read exclusions %s: %w", filePath, err)
+	}
+	root, err := parseYAML(data)
+	if err != nil {
+		return fmt.Errorf("invalid exclusions %s: %w", filePath, err)
+	}
+	if err := ex.decode(root); err != nil {
+		return fmt.Errorf("invalid exclusions %s: %w", filePath, err)
+	}
+	Excluded = ex
+	return nil
+}
+
+// decode reads the mounts, services, interfaces and sysctl lists
+func (ex *Exclusions) decode(root map[string]interface{}) error {
+	lists := map[string]*[]string{
+		"mounts":     &ex.Mounts,
+		"services":   &ex.Services,
+		"interfaces": &ex.Interfaces,
+		"sysctl":     &ex.Sysctl,
+	}
+	for key := range root {
+		if _, ok := lists[key]; !ok {
+			return fmt.Errorf("unknown key %q (mounts, services, interfaces, sysctl)", key)
+		}
+	}
+	for key, list := range lists {
+		values, err := yamlStringList(root[key])
+		if err != nil {
+			return fmt.Errorf("%s: %w", key, err)
+		}
+		for _, v := range values {
+			if _, err := path.Match(v, ""); err != nil {
+				return fmt.Errorf("%s: invalid pattern %q", key, v)
+			}
+		}
+		*list = values
+	}
+	for _, m := range ex.Mounts {
+		if !strings.HasPrefix(m, "/") {
+			return fmt.Errorf("mounts: %q is not an absolute path", m)
+		}
+	}
+	return nil
+}
+
+// Empty reports whether nothing is excluded
+func (ex Exclusions) Empty() bool {
+	return len(ex.Mounts) == 0 && len(ex.Services) == 0 && len(ex.Interfaces) == 0 && len(ex.Sysctl) == 0
+}
+
+// matchAny reports whether name matches one of the patterns
+func matchAny(patterns []string, name string) bool {
+	for _, p := range patterns {
+		if ok, _ := path.Match(p, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// Matches reports whether a component is excluded. Services match with or
+// without their .service suffix, mounts also cover what is mounted below.
+func (ex Exclusions) Matches(kind, name string) bool {
+	switch kind {
+	case ExcludeMount:
+		name = path.Clean(name)
+		for _, m := range ex.Mounts {
+			if ok, _ := path.Match(m, name); ok || strings.HasPrefix(name, strings.TrimSuffix(m, "/")+"/") {
+				return true
+			}
+		}
+		return false
+	case ExcludeService:
+		return matchAny(ex.Services, name) || matchAny(ex.Services, strings.TrimSuffix(name, ".service")) ||
+			matchAny(ex.Services, name+".service")
+	case ExcludeInterface:
+		return matchAny(ex.Interfaces, name)
+	case ExcludeSysctl:
+		return matchAny(ex.Sysctl, name)
+	}
+	return false
+}
+
+// SkipExcluded reports whether a module must leave a component alone. The
+// skip is printed, written to the action log and listed at the end of the
+// run.
+func SkipExcluded(module, kind, name string) bool {
+	if !Excluded.Matches(kind, name) {
+		return false
+	}
+	PrintWarning("Skipping %s %s: listed in %s", kind, name, Excluded.Path)
+	for _, s := range exclusionSkips {
+		if s.Module == module && s.Kind == kind && s.Name == name {
+			return true
+		}
+	}
+	exclusionSkips = append(exclusionSkips, ExclusionSkip{Module: module, Kind: kind, Name: name})
+	LogAction(module, "exclude-"+kind, ResultSkipped, "name="+name+" exclusions="+Excluded.Path)
+	return true
+}
+
+// TakeExclusionSkips returns the skipped changes and forgets them
+func TakeExclusionSkips() []ExclusionSkip {
+	skips := exclusionSkips
+	exclusionSkips = nil
+	return skips
+}
+
+// PrintExclusionSkips lists the changes the exclusions kept the run from making
+func PrintExclusionSkips(skips []ExclusionSkip) {
+	if len(skips) == 0 {
+		return
+	}
+	fmt.Println()
+	PrintInfo("Left alone (%s):", Excluded.Path)
+	for _, s := range skips {
+		fmt.Printf("    %-10s %-10s %s\n", s.Module, s.Kind, s.Name)
+	}
+}
+
+// ShowExclusions prints the exclusions in effect
+func ShowExclusions() {
+	PrintStep("Exclusions")
+	if Excluded.Empty() {
+		PrintInfo("Nothing excluded (%s not found or empty)", Excluded.Path)
+		return
+	}
+	for _, l := range []struct {
+		name   string
+		values []string
+	}{
+		{"Mounts", Excluded.Mounts},
+		{"Services", Excluded.Services},
+		{"Interfaces", Excluded.Interfaces},
+		{"Sysctl keys", Excluded.Sysctl},
+	} {
+		if len(l.values) > 0 {
+			fmt.Printf("  %-12s %s\n", l.name+":", strings.Join(l.values, ", "))
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package tuner
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLoadExclusions(t *testing.T) {
+	saved := Excluded
+	defer func() { Excluded = saved }()
+	dir := t.TempDir()
+
+	missing := filepath.Join(dir, "none.yaml")
+	if err := LoadExclusions(missing); err != nil {
+		t.Fatalf("missing file: %v", err)
+	}
+	if !Excluded.Empty() || Excluded.Path != missing {
+		t.Errorf("missing file: exclusions = %+v, want empty", Excluded)
+	}
+
+	file := filepath.Join(dir, "exclusions.yaml")
+	os.WriteFile(file, []byte(`mounts:
+  - /opt/vendor
+services: [falcon-sensor, "splunk*"]
+interfaces:
+  - ens224
+sysctl:
+  - net.core.*
+`), 0644)
+	if err := LoadExclusions(file); err != nil {
+		t.Fatal(err)
+	}
+	want := Exclusions{
+		Path:       file,
+		Mounts:     []string{"/opt/vendor"},
+		Services:   []string{"falcon-sensor", "splunk*"},
+		Interfaces: []string{"ens224"},
+		Sysctl:     []string{"net.core.*"},
+	}
+	if !reflect.DeepEqual(Excluded, want) {
+		t.Errorf("exclusions = %+v, want %+v", Excluded, want)
+	}
+
+	for content, wantErr := range map[string]string{
+		"disks:\n  - sdb\n":        "unknown key",
+		"mounts:\n  - opt/x\n":     "not an absolute path",
+		"sysctl:\n  - \"net.[\"\n": "invalid pattern",
+	} {
+		os.WriteFile(file, []byte(content), 0644)
+		err := LoadExclusions(file)
+		if err == nil || !strings.Contains(err.Error(), wantErr) {
+			t.Errorf("%q: err = %v, want %q", content, err, wantErr)
+		}
+		if Excluded.Path != file || len(Excluded.Sysctl) != 1 {
+			t.Errorf("%q: the exclusions in effect changed on error", content)
+		}
+	}
+}
+
+func TestExclusionsMatches(t *testing.T) {
+	ex := Exclusions{
+		Mounts:     []string{"/opt/vendor", "/data/*"},
+		Services:   []string{"falcon-sensor", "splunk*", "tuned.service"},
+		Interfaces: []string{"ens2*"},
+		Sysctl:     []string{"vm.swappiness", "net.core.*"},
+	}
+	for _, c := range []struct {
+		kind, name string
+		want       bool
+	}{
+		{ExcludeMount, "/opt/vendor", true},
+		{ExcludeMount, "/opt/vendor/logs", true},
+		{ExcludeMount, "/opt/vendorx", false},
+		{ExcludeMount, "/data/db", true},
+		{ExcludeMount, "/", false},
+		{ExcludeService, "falcon-sensor.service", true},
+		{ExcludeService, "splunkd", true},
+		{ExcludeService, "tuned", true},
+		{ExcludeService, "sshd", false},
+		{ExcludeInterface, "ens224", true},
+		{ExcludeInterface, "ens192", false},
+		{ExcludeSysctl, "net.core.somaxconn", true},
+		{ExcludeSysctl, "vm.swappiness", true},
+		{ExcludeSysctl, "vm.dirty_ratio", false},
+	} {
+		if got := ex.Matches(c.kind, c.name); got != c.want {
+			t.Errorf("Matches(%s, %s) = %v, want %v", c.kind, c.name, got, c.want)
+		}
+	}
+}
+
+func TestExclusionsHonored(t *testing.T) {
+	saved := Excluded
+	defer func() { Excluded = saved }()
+	Excluded = Exclusions{Path: DefaultExclusionsPath, Interfaces: []string{"ens224"}, Sysctl: []string{"vm.swappiness"}}
+
+	all := []NetInterface{{"ens192", "vmxnet3"}, {"ens224", "vmxnet3"}, {"br0", "bridge"}}
+	selected, skipped := InterfaceSelection{}.Select(all)
+	if len(selected) != 1 || selected[0].Name != "ens192" {
+		t.Errorf("selected = %v, want ens192 (ens224 excluded)", selected)
+	}
+	if len(skipped) != 1 || skipped[0].Name != "br0" {
+		t.Errorf("skipped = %v, want br0 only (the excluded ones are reported apart)", skipped)
+	}
+
+	if !(TuningConfig{}).SysctlExcluded("vm.swappiness") {
+		t.Error("vm.swappiness not excluded")
+	}
+	got := excludeSysctlKeys("vm.swappiness = 10\nvm.dirty_ratio = 15", TuningConfig{})
+	want := "# Excluded in " + DefaultExclusionsPath + " (managed elsewhere)\n# vm.swappiness = 10\nvm.dirty_ratio = 15"
+	if got != want {
+		t.Errorf("sysctl config = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,72 @@
+package tuner
+
+import (
+	"fmt"
+	"strings"
+)
+
+// expertBootWarnings are the opt-in boot parameters for lab and benchmark
+// VMs, with what they cost. They are never part of a profile.
+var expertBootWarnings = map[string]string{
+	"mitigations=off":            "CPU vulnerability mitigations (Spectre, Meltdown, MDS...) are disabled: only for isolated lab and benchmark VMs",
+	"transparent_hugepage=never": "no transparent huge pages for any process: databases ask for it, other workloads may lose TLB efficiency",
+	"nohz_full":                  "no timer tick on these vCPUs: only helps pinned, busy workloads; the other vCPUs take the housekeeping",
+	"isolcpus":                   "the scheduler puts no task on these vCPUs unless pinned (taskset, CPUAffinity): unpinned workloads lose them",
+}
+
+// expertBootNames lists the accepted parameters for error messages
+const expertBootNames = "mitigations=off, transparent_hugepage=never, nohz_full=<cpus>, isolcpus=<cpus>"
+
+// expertBootWarning returns the warning of an expert parameter, or an error
+// when it is not one. CPU lists are checked by ExpertBootParams.
+func expertBootWarning(param string) (string, error) {
+	if warning, ok := expertBootWarnings[param]; ok && strings.Contains(param, "=") {
+		return warning, nil
+	}
+	// CPU list parameters: the key needs a value
+	key := paramKey(param)
+	if warning, ok := expertBootWarnings[key]; ok && key != param {
+		return warning, nil
+	}
+	return "", fmt.Errorf("%w: unsupported expert boot parameter %q (available: %s)", ErrValidationFailed, param, expertBootNames)
+}
+
+// ExpertBootParams validates the expert parameters against this VM (CPU lists
+// must leave CPU 0 and exist) and prints their warnings
+func ExpertBootParams(params []string) ([]string, error) {
+	var result []string
+	for _, param := range params {
+		warning, err := expertBootWarning(param)
+		if err != nil {
+			return nil, err
+		}
+		if key := paramKey(param); key == "nohz_full" || key == "isolcpus" {
+			cpus, err := ParseCPUList(strings.TrimPrefix(param, key+"="))
+			if err != nil {
+				return nil, fmt.Errorf("%w: %s: %v", ErrValidationFailed, key, err)
+			}
+			param = key + "=" + FormatCPUList(cpus)
+		}
+		PrintWarning("Expert boot parameter %s: %s", param, warning)
+		result = append(result, param)
+	}
+	return result, nil
+}
+
+// expertParams returns the expert parameters of the run (tuning.grub.expert or
+// --expert-boot). Production VMs need a typed confirmation.
+func (gt *GrubTuner) expertParams() ([]string, error) {
+	if len(Tuning.ExpertBoot) == 0 {
+		return nil, nil
+	}
+	params, err := ExpertBootParams(Tuning.ExpertBoot)
+	if err != nil {
+		return nil, err
+	}
+	if !gt.DryRun {
+		if err := GuardDestructive("Expert boot parameters " + strings.Join(params, " ")); err != nil {
+			return nil, err
+		}
+	}
+	return params, nil
+}
@@ -0,0 +1,33 @@
+package tuner
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Explain prints each command and file edit before it is made, with the
+// reason, so a run reads as a transcript admins can learn from or attach to
+// a change record (--explain)
+var Explain bool
+
+// ExplainCommand announces a system command
+func ExplainCommand(why, name string, args ...string) {
+	if !Explain {
+		return
+	}
+	explain(strings.TrimSpace("$ "+name+" "+strings.Join(args, " ")), why)
+}
+
+// ExplainEdit announces a file that is about to be written
+func ExplainEdit(why, path string) {
+	if !Explain {
+		return
+	}
+	explain("> write "+path, why)
+}
+
+// explain prints an action and its reason
+func explain(action, why string) {
+	fmt.Printf("  %s\n", action)
+	fmt.Printf("    why: %s\n", why)
+}
@@ -0,0 +1,31 @@
+package tuner
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestExplain(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "out.txt")
+	run := func() error {
+		ExplainCommand("load the new values now", "sysctl", "-p", "/etc/sysctl.d/99-vmware.conf")
+		ExplainEdit("persist the kernel parameters", "/etc/sysctl.d/99-vmware.conf")
+		return nil
+	}
+
+	captureOutput(out, run)
+	if data, _ := os.ReadFile(out); len(data) != 0 {
+		t.Errorf("output without --explain: %q", data)
+	}
+
+	Explain = true
+	defer func() { Explain = false }()
+	captureOutput(out, run)
+	data, _ := os.ReadFile(out)
+	want := "  $ sysctl -p /etc/sysctl.d/99-vmware.conf\n    why: load the new values now\n" +
+		"  > write /etc/sysctl.d/99-vmware.conf\n    why: persist the kernel parameters\n"
+	if string(data) != want {
+		t.Errorf("output = %q, want %q", data, want)
+	}
+}
@@ -0,0 +1,54 @@
+package tuner
+
+import (
+	"fmt"
+	"time"
+)
+
+// Output units, shared by every module so values read and parse the same way:
+// sizes in binary units (KiB, MiB...) with one decimal, disk rates in MiB/s,
+// network rates in Mbit/s (decimal, like link speeds).
+
+var byteUnits = []string{"KiB", "MiB", "GiB", "TiB", "PiB"}
+
+// FormatBytes formats a size: 512 B, 4.0 KiB, 1.5 GiB
+func FormatBytes(n int64) string {
+	if n < 1024 && n > -1024 {
+		return fmt.Sprintf("%d B", n)
+	}
+	v := float64(n) / 1024
+	unit := 0
+	for (v >= 1024 || v <= -1024) && unit < len(byteUnits)-1 {
+		v /= 1024
+		unit++
+	}
+	return fmt.Sprintf("%.1f %s", v, byteUnits[unit])
+}
+
+// FormatMiB formats a size counted in MiB (memory, filesystem usage)
+func FormatMiB(mb int64) string {
+	return FormatBytes(mb << 20)
+}
+
+// FormatByteRate formats a disk or file transfer rate in MiB/s
+func FormatByteRate(bytesPerSec float64) string {
+	return fmt.Sprintf("%.1f MiB/s", bytesPerSec/(1<<20))
+}
+
+// FormatBitRate formats a network rate in Mbit/s
+func FormatBitRate(bytesPerSec float64) string {
+	return fmt.Sprintf("%.1f Mbit/s", bytesPerSec*8/1e6)
+}
+
+// FormatDuration formats an elapsed time: 850ms, 12.3s, 2m05s, 1h02m
+func FormatDuration(d time.Duration) string {
+	switch {
+	case d < time.Second:
+		return fmt.Sprintf("%dms", d.Milliseconds())
+	case d < time.Minute:
+		return fmt.Sprintf("%.1fs", d.Seconds())
+	case d < time.Hour:
+		return fmt.Sprintf("%dm%02ds", int(d.Minutes()), int(d.Seconds())%60)
+	}
+	return fmt.Sprintf("%dh%02dm", int(d.Hours()), int(d.Minutes())%60)
+}
@@ -0,0 +1,55 @@
+package tuner
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFormatBytes(t *testing.T) {
+	tests := []struct {
+		n    int64
+		want string
+	}{
+		{0, "0 B"},
+		{512, "512 B"},
+		{4096, "4.0 KiB"},
+		{1536 << 10, "1.5 MiB"},
+		{6 << 30, "6.0 GiB"},
+		{3 << 40, "3.0 TiB"},
+	}
+	for _, tt := range tests {
+		if got := FormatBytes(tt.n); got != tt.want {
+			t.Errorf("FormatBytes(%d) = %q, want %q", tt.n, got, tt.want)
+		}
+	}
+	if got := FormatMiB(2048); got != "2.0 GiB" {
+		t.Errorf("FormatMiB(2048) = %q", got)
+	}
+}
+
+func TestFormatRates(t *testing.T) {
+	// 12.5 MB/s is a 100 Mbit/s link
+	if got := FormatBitRate(12.5e6); got != "100.0 Mbit/s" {
+		t.Errorf("FormatBitRate = %q", got)
+	}
+	if got := FormatByteRate(100 << 20); got != "100.0 MiB/s" {
+		t.Errorf("FormatByteRate = %q", got)
+	}
+}
+
+func TestFormatDuration(t *testing.T) {
+	tests := []struct {
+		d    time.Duration
+		want string
+	}{
+		{850 * time.Millisecond, "850ms"},
+		{12300 * time.Millisecond, "12.3s"},
+		{125 * time.Second, "2m05s"},
+		{62 * time.Minute, "1h02m"},
+	}
+	for _, tt := range tests {
+		if got := FormatDuration(tt.d); got != tt.want {
+			t.Errorf("FormatDuration(%s) = %q, want %q", tt.d, got, tt.want)
+		}
+	}
+}
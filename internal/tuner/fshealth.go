@@ -0,0 +1,305 @@
+package tuner
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// fsckStaleAfter is the age of the last check reported as worth a full fsck
+// at the next maintenance window
+const fsckStaleAfter = 365 * 24 * time.Hour
+
+// kernelIODeviceRes find the device of a kernel I/O error message
+var kernelIODeviceRes = []*regexp.Regexp{
+	regexp.MustCompile(`I/O error,? (?:on )?dev (\w[\w-]*)`), // blk_update_request / Buffer I/O error on dev sda1
+	regexp.MustCompile(`EXT4-fs (?:error|warning) \(device (\w[\w-]*)\)`),
+	regexp.MustCompile(`XFS \((\w[\w-]*)\): .*(?:error|[Cc]orruption|shutdown)`),
+	regexp.MustCompile(`sd \d+:\d+:\d+:\d+: \[(\w+)\] .*(?:FAILED|Sense Key|abort)`),
+}
+
+// FSMount is a mounted ext4 or XFS filesystem
+type FSMount struct {
+	Source     string // /dev/sda1, /dev/mapper/vg-root
+	MountPoint string
+	FSType     string
+	Device     string // kernel name: sda1, dm-0
+}
+
+// FSHealth is the health report of a filesystem
+type FSHealth struct {
+	FSMount
+	ErrorCount   int    // errors recorded in the superblock (ext4)
+	FirstError   string // time and function, as recorded
+	LastError    string
+	State        string    // "clean", "clean with errors", XFS health
+	LastChecked  time.Time // last fsck (ext4)
+	KernelErrors int       // I/O errors of its disks in the kernel log
+	Problems     []string  // XFS metadata reported sick
+}
+
+// Unhealthy reports whether the filesystem or its disks recorded errors
+func (h FSHealth) Unhealthy() bool {
+	return h.ErrorCount > 0 || h.KernelErrors > 0 || len(h.Problems) > 0 || strings.Contains(h.State, "error")
+}
+
+// ReadFSMounts returns the ext4 and XFS filesystems on block devices
+func ReadFSMounts(fsRoot string) ([]FSMount, error) {
+	data, err := os.ReadFile(filepath.Join(fsRoot, "/proc/mounts"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read /proc/mounts: %w", err)
+	}
+
+	seen := make(map[string]bool)
+	var mounts []FSMount
+	for _, line := range strings.Split(string(data), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 3 || !strings.HasPrefix(fields[0], "/dev/") || seen[fields[0]] {
+			continue
+		}
+		if fields[2] != "ext4" && fields[2] != "ext3" && fields[2] != "xfs" {
+			continue
+		}
+		seen[fields[0]] = true // bind mounts report the same filesystem again
+		device := filepath.Base(fields[0])
+		if resolved, err := filepath.EvalSymlinks(filepath.Join(fsRoot, fields[0])); err == nil {
+			device = filepath.Base(resolved)
+		}
+		mounts = append(mounts, FSMount{Source: fields[0], MountPoint: fields[1], FSType: fields[2], Device: device})
+	}
+	return mounts, nil
+}
+
+// ParseTune2fs reads the error counters and the last check of `tune2fs -l`
+func ParseTune2fs(out string) FSHealth {
+	var h FSHealth
+	for _, line := range strings.Split(out, "\n") {
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		value = strings.TrimSpace(value)
+		switch strings.TrimSpace(key) {
+		case "Filesystem state":
+			h.State = value
+		case "FS Error count":
+			h.ErrorCount, _ = strconv.Atoi(value)
+		case "First error time":
+			h.FirstError = value
+		case "First error function":
+			h.FirstError += " in " + value
+		case "Last error time":
+			h.LastError = value
+		case "Last error function":
+			h.LastError += " in " + value
+		case "Last checked":
+			h.LastChecked, _ = time.Parse(time.ANSIC, value)
+		}
+	}
+	return h
+}
+
+// readExt4Errors reads the error counter the kernel exposes for a mounted
+// ext4 filesystem (no root needed, unlike tune2fs)
+func readExt4Errors(fsRoot, device string) (int, bool) {
+	data, err := os.ReadFile(filepath.Join(fsRoot, "/sys/fs/ext4", device, "errors_count"))
+	if err != nil {
+		return 0, false
+	}
+	count, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	return count, err == nil
+}
+
+// ParseXFSHealth returns the sick metadata of `xfs_spaceman -c "health -c"`
+func ParseXFSHealth(out string) []string {
+	var problems []string
+	for _, line := range strings.Split(out, "\n") {
+		line = strings.TrimSpace(line)
+		lower := strings.ToLower(line)
+		if strings.Contains(lower, "unhealthy") || strings.Contains(lower, "sick") || strings.Contains(lower, "corrupt") {
+			problems = append(problems, line)
+		}
+	}
+	return problems
+}
+
+// CorrelateIOErrors counts the kernel I/O errors per device
+func CorrelateIOErrors(lines []string) map[string]int {
+	counts := make(map[string]int)
+	for _, line := range lines {
+		for _, re := range kernelIODeviceRes {
+			if m := re.FindStringSubmatch(line); m != nil {
+				counts[m[1]]++
+				break
+			}
+		}
+	}
+	return counts
+}
+
+// blockDevices returns the device, its parent disk when it is a partition
+// and the devices below a device-mapper volume (LVM, multipath)
+func blockDevices(fsRoot, device string) []string {
+	devices := []string{device}
+	class := filepath.Join(fsRoot, "/sys/class/block", device)
+	if FileExists(filepath.Join(class, "partition")) {
+		if resolved, err := filepath.EvalSymlinks(class); err == nil {
+			devices = append(devices, filepath.Base(filepath.Dir(resolved)))
+		}
+	}
+	slaves, _ := os.ReadDir(filepath.Join(class, "slaves"))
+	for _, slave := range slaves {
+		devices = append(devices, blockDevices(fsRoot, slave.Name())...)
+	}
+	return devices
+}
+
+// FSHealthTuner checks the filesystem error counters and the I/O errors
+// of their disks
+type FSHealthTuner struct {
+	FSRoot string // "" for /
+}
+
+// NewFSHealthTuner creates a new filesystem health check
+func NewFSHealthTuner() *FSHealthTuner {
+	return &FSHealthTuner{}
+}
+
+func init() {
+	Register(Module{
+		Name:        "fshealth",
+		Label:       "Filesystem Health",
+		Description: "Check the filesystems for errors and read-only remounts",
+		Category:    CategoryDiagnostics,
+		Menu:        32,
+		ReadOnly:    true,
+		Run: func(ctx *ModuleContext) error {
+			return NewFSHealthTuner().Run()
+		},
+	})
+}
+
+// Check returns the health of every ext4 and XFS filesystem
+func (ft *FSHealthTuner) Check(kernelLog []string) ([]FSHealth, error) {
+	mounts, err := ReadFSMounts(ft.FSRoot)
+	if err != nil {
+		return nil, err
+	}
+	ioErrors := CorrelateIOErrors(kernelLog)
+
+	var report []FSHealth
+	for _, m := range mounts {
+		h := FSHealth{FSMount: m}
+		if m.FSType == "xfs" {
+			if out, err := exec.Command("xfs_spaceman", "-c", "health -c", filepath.Join(ft.FSRoot, m.MountPoint)).CombinedOutput(); err == nil {
+				h.Problems = ParseXFSHealth(string(out))
+			}
+		} else {
+			if out, err := exec.Command("tune2fs", "-l", filepath.Join(ft.FSRoot, m.Source)).Output(); err == nil {
+				h = ParseTune2fs(string(out))
+				h.FSMount = m
+			}
+			if count, ok := readExt4Errors(ft.FSRoot, m.Device); ok && count > h.ErrorCount {
+				h.ErrorCount = count
+			}
+		}
+		for _, dev := range blockDevices(ft.FSRoot, m.Device) {
+			h.KernelErrors += ioErrors[dev]
+		}
+		report = append(report, h)
+	}
+	sort.Slice(report, func(i, j int) bool { return report[i].MountPoint < report[j].MountPoint })
+	return report, nil
+}
+
+// Run prints the health of the filesystems with what to do
+func (ft *FSHealthTuner) Run() error {
+	PrintStep("Filesystem Health")
+
+	kernelLog, err := ReadKernelLog(logDoctorKernelMax)
+	if err != nil {
+		PrintWarning("Could not read the kernel log, I/O errors are not correlated: %v", err)
+	}
+	report, err := ft.Check(kernelLog)
+	if err != nil {
+		return err
+	}
+	if len(report) == 0 {
+		PrintInfo("No ext4 or XFS filesystem mounted")
+		return nil
+	}
+	if os.Geteuid() != 0 {
+		PrintWarning("Not running as root: the last check dates and XFS health are not read")
+	}
+
+	unhealthy := 0
+	for _, h := range report {
+		fmt.Printf("\n  %s (%s on %s)\n", h.MountPoint, h.FSType, h.Source)
+		if !h.Unhealthy() {
+			PrintSuccess("No errors recorded")
+		} else {
+			unhealthy++
+		}
+		if h.ErrorCount > 0 {
+			PrintError("%d error(s) recorded in the superblock", h.ErrorCount)
+			if h.FirstError != "" {
+				PrintInfo("First error: %s", h.FirstError)
+			}
+			if h.LastError != "" {
+				PrintInfo("Last error: %s", h.LastError)
+			}
+		}
+		if h.State != "" && h.State != "clean" {
+			PrintWarning("Filesystem state: %s", h.State)
+		}
+		for _, p := range h.Problems {
+			PrintError("XFS health: %s", p)
+		}
+		if h.KernelErrors > 0 {
+			PrintError("%d I/O error(s) of its disks in the kernel log", h.KernelErrors)
+			RecordFinding(FindingIOError, h.MountPoint, fmt.Sprintf("I/O error, dev %s", h.Device))
+		}
+		if !h.LastChecked.IsZero() {
+			age := time.Since(h.LastChecked)
+			if age > fsckStaleAfter {
+				PrintWarning("Last checked %s (%d days ago)", h.LastChecked.Format("2006-01-02"), int(age.Hours()/24))
+			} else {
+				PrintInfo("Last checked %s", h.LastChecked.Format("2006-01-02"))
+			}
+		}
+		ft.printGuidance(h)
+	}
+
+	fmt.Println()
+	if unhealthy > 0 {
+		PrintWarning("%d filesystem(s) need attention", unhealthy)
+	} else {
+		PrintSuccess("All %d filesystem(s) are healthy", len(report))
+	}
+	return nil
+}
+
+// printGuidance tells what to do about a filesystem with errors
+func (ft *FSHealthTuner) printGuidance(h FSHealth) {
+	if h.KernelErrors > 0 {
+		PrintInfo("-> I/O errors come from the virtual disk or the datastore below it: send the timestamps of the errors (vmware-tuner logdoctor) to the storage admins to check the datastore for latency, APD or PDL events")
+	}
+	if h.ErrorCount == 0 && len(h.Problems) == 0 && !strings.Contains(h.State, "error") {
+		return
+	}
+	if h.FSType == "xfs" {
+		PrintInfo("-> Unmount %s (or boot a rescue ISO for /) and run: xfs_repair %s", h.MountPoint, h.Source)
+		return
+	}
+	if h.MountPoint == "/" {
+		PrintInfo("-> Schedule a check at the next reboot: add fsck.mode=force to the kernel command line for one boot (or touch /forcefsck on older systems)")
+	} else {
+		PrintInfo("-> Unmount %s and run: e2fsck -f %s (after a snapshot of the VM)", h.MountPoint, h.Source)
+	}
+}
@@ -0,0 +1,95 @@
+package tuner
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestParseTune2fs(t *testing.T) {
+	out := `tune2fs 1.46.5 (30-Dec-2021)
+Filesystem volume name:   <none>
+Filesystem state:         clean with errors
+Mount count:              12
+Last checked:             Mon Jan  1 10:00:00 2024
+FS Error count:           3
+First error time:         Tue Feb  6 11:22:33 2024
+First error function:     ext4_lookup
+Last error time:          Wed Feb  7 08:00:00 2024
+Last error function:      ext4_journal_check_start
+`
+	h := ParseTune2fs(out)
+	if h.ErrorCount != 3 || h.State != "clean with errors" || !h.Unhealthy() {
+		t.Errorf("unexpected health: %+v", h)
+	}
+	if h.FirstError != "Tue Feb  6 11:22:33 2024 in ext4_lookup" || h.LastError != "Wed Feb  7 08:00:00 2024 in ext4_journal_check_start" {
+		t.Errorf("unexpected errors: %q / %q", h.FirstError, h.LastError)
+	}
+	if !h.LastChecked.Equal(time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)) {
+		t.Errorf("last checked = %v", h.LastChecked)
+	}
+}
+
+func TestCorrelateIOErrors(t *testing.T) {
+	counts := CorrelateIOErrors([]string{
+		"[ 812.1] blk_update_request: I/O error, dev sdb, sector 2048 op 0x1:(WRITE)",
+		"[ 812.2] Buffer I/O error on dev sdb1, logical block 0, lost async page write",
+		"[ 812.3] EXT4-fs error (device dm-0): ext4_find_entry:1455: inode #2: comm ls: reading directory lblock 0",
+		"[ 812.4] XFS (sdc1): metadata I/O error in \"xfs_trans_read_buf_map\" at daddr 0x2",
+		"[ 812.5] sd 0:0:1:0: [sdb] tag#3 FAILED Result: hostbyte=DID_OK driverbyte=DRIVER_SENSE",
+		"[ 812.6] e1000: ens33 NIC Link is Up",
+	})
+	for dev, want := range map[string]int{"sdb": 2, "sdb1": 1, "dm-0": 1, "sdc1": 1} {
+		if counts[dev] != want {
+			t.Errorf("%s: %d error(s), want %d (%v)", dev, counts[dev], want, counts)
+		}
+	}
+}
+
+func TestFSHealthCheck(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{
+		"proc/mounts": `/dev/mapper/vg-root / ext4 rw,relatime 0 0
+/dev/sdb1 /data ext4 rw,relatime 0 0
+/dev/sdb1 /srv/bind ext4 rw,relatime 0 0
+tmpfs /run tmpfs rw 0 0
+`,
+		"dev/dm-0": "",
+		// dm-0 sits on sda2; sdb1 is a partition of sdb
+		"sys/class/block/dm-0/slaves/sda2":   "",
+		"sys/devices/pci/sdb/sdb1/partition": "1\n",
+		"sys/fs/ext4/sdb1/errors_count":      "2\n",
+		"sys/fs/ext4/dm-0/errors_count":      "0\n",
+	})
+	if err := os.MkdirAll(filepath.Join(root, "dev/mapper"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("../dm-0", filepath.Join(root, "dev/mapper/vg-root")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("../../devices/pci/sdb/sdb1", filepath.Join(root, "sys/class/block/sdb1")); err != nil {
+		t.Fatal(err)
+	}
+
+	ft := NewFSHealthTuner()
+	ft.FSRoot = root
+	report, err := ft.Check([]string{
+		"blk_update_request: I/O error, dev sda, sector 10",
+		"blk_update_request: I/O error, dev sdb, sector 2048",
+		"Buffer I/O error on dev sdb1, logical block 0",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report) != 2 {
+		t.Fatalf("expected / and /data (bind mount and tmpfs skipped), got %+v", report)
+	}
+	rootFS, data := report[0], report[1]
+	if rootFS.Device != "dm-0" || rootFS.ErrorCount != 0 || rootFS.KernelErrors != 0 || rootFS.Unhealthy() {
+		t.Errorf("/ on dm-0 over sda2 has no errors of its own: %+v", rootFS)
+	}
+	if data.ErrorCount != 2 || data.KernelErrors != 2 || !data.Unhealthy() {
+		t.Errorf("/data should have 2 superblock errors and 2 I/O errors of sdb: %+v", data)
+	}
+}
@@ -23,6 +23,23 @@ func NewFstabTuner(dryRun bool) *FstabTuner {
 	}
 }
 
+func init() {
+	Register(Module{
+		Name:        "fstab",
+		Description: "Filesystem mount options",
+		Category:    CategoryTuning,
+		Pipeline:    3,
+		RequireRoot: true,
+		Flag:        &ModuleFlag{Name: "no-fstab", Usage: "Skip fstab optimization", Negated: true},
+		Apply: func(ctx *ModuleContext) error {
+			return NewFstabTuner(ctx.DryRun).Apply(ctx.Backup)
+		},
+		Show: func(ctx *ModuleContext) error {
+			return NewFstabTuner(false).ShowCurrent()
+		},
+	})
+}
+
 // FstabEntry represents a line in /etc/fstab
 type FstabEntry struct {
 	Device     string
@@ -35,6 +52,13 @@ type FstabEntry struct {
 	IsComment  bool
 }
 
+// fstabFieldsRe separates the fields of an fstab line
+var fstabFieldsRe = regexp.MustCompile(`\s+`)
+
+// maxConfigLine is the longest line of a configuration file the parsers
+// accept (bufio.Scanner stops at 64 KiB by default)
+const maxConfigLine = 1024 * 1024
+
 // ParseFstab parses /etc/fstab and returns entries
 func (ft *FstabTuner) ParseFstab() ([]FstabEntry, error) {
 	file, err := os.Open(ft.FstabPath)
@@ -45,6 +69,7 @@ func (ft *FstabTuner) ParseFstab() ([]FstabEntry, error) {
 
 	var entries []FstabEntry
 	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxConfigLine)
 
 	for scanner.Scan() {
 		line := scanner.Text()
@@ -60,7 +85,7 @@ func (ft *FstabTuner) ParseFstab() ([]FstabEntry, error) {
 		}
 
 		// Parse fstab entry
-		fields := regexp.MustCompile(`\s+`).Split(trimmed, -1)
+		fields := fstabFieldsRe.Split(trimmed, -1)
 		if len(fields) < 4 {
 			// Malformed line, keep as comment
 			entries = append(entries, FstabEntry{
@@ -126,28 +151,23 @@ func (ft *FstabTuner) OptimizeEntry(entry *FstabEntry) bool {
 		modified = true
 	}
 
-	// Add performance options if not present
-	performanceOpts := []string{"noatime", "nodiratime"}
-	for _, opt := range performanceOpts {
-		if !options[opt] {
+	// Add performance options if not present (tuning.fstab.options).
+	// An option with a value (commit=60) is not added when already set.
+	for _, opt := range Tuning.MountOptions() {
+		name, _, _ := strings.Cut(opt, "=")
+		present := false
+		for existing := range options {
+			if existing == opt || strings.HasPrefix(existing, name+"=") {
+				present = true
+				break
+			}
+		}
+		if !present {
 			options[opt] = true
 			modified = true
 		}
 	}
 
-	// Add commit=60 if not present
-	hasCommit := false
-	for opt := range options {
-		if strings.HasPrefix(opt, "commit=") {
-			hasCommit = true
-			break
-		}
-	}
-	if !hasCommit {
-		options["commit=60"] = true
-		modified = true
-	}
-
 	// Rebuild options slice
 	if modified {
 		newOptions := []string{}
@@ -160,6 +180,43 @@ func (ft *FstabTuner) OptimizeEntry(entry *FstabEntry) bool {
 	return modified
 }
 
+// findmntVerify checks an fstab file with findmnt --verify and returns its report
+var findmntVerify = func(path string) (string, error) {
+	out, err := exec.Command("findmnt", "--verify", "--tab-file", path).CombinedOutput()
+	return strings.TrimSpace(string(out)), err
+}
+
+// verify refuses a new fstab content that findmnt --verify rejects, since
+// an invalid fstab stops the boot in emergency mode. Errors the current file
+// already has are only reported.
+func (ft *FstabTuner) verify(content string) error {
+	if _, err := exec.LookPath("findmnt"); err != nil {
+		PrintWarning("findmnt not found: the new %s is not verified", ft.FstabPath)
+		return nil
+	}
+	tmp, err := os.CreateTemp("", "fstab-verify-")
+	if err != nil {
+		return fmt.Errorf("failed to create a temporary fstab: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.WriteString(content); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write a temporary fstab: %w", err)
+	}
+	tmp.Close()
+
+	out, err := findmntVerify(tmp.Name())
+	if err == nil {
+		PrintSuccess("findmnt --verify accepts the new %s", ft.FstabPath)
+		return nil
+	}
+	if _, errCurrent := findmntVerify(ft.FstabPath); errCurrent != nil {
+		PrintWarning("findmnt --verify already reports errors in the current %s:\n%s", ft.FstabPath, out)
+		return nil
+	}
+	return fmt.Errorf("%w: findmnt --verify rejects the new %s: %s", ErrValidationFailed, ft.FstabPath, out)
+}
+
 // Apply applies fstab optimizations
 func (ft *FstabTuner) Apply(backup *BackupManager) error {
 	PrintStep("Optimizing /etc/fstab")
@@ -172,14 +229,20 @@ func (ft *FstabTuner) Apply(backup *BackupManager) error {
 
 	// Optimize entries
 	modified := false
+	var changes []string
 	for i := range entries {
-		if !entries[i].IsComment {
-			if ft.OptimizeEntry(&entries[i]) {
-				modified = true
-				PrintInfo("Optimizing: %s mounted at %s",
-					entries[i].Device, entries[i].MountPoint)
-			}
+		if entries[i].IsComment {
+			continue
 		}
+		candidate := entries[i]
+		if !ft.OptimizeEntry(&candidate) || SkipExcluded("fstab", ExcludeMount, candidate.MountPoint) {
+			continue
+		}
+		entries[i] = candidate
+		modified = true
+		PrintInfo("Optimizing: %s mounted at %s",
+			entries[i].Device, entries[i].MountPoint)
+		changes = append(changes, entries[i].MountPoint+" "+strings.Join(entries[i].Options, ","))
 	}
 
 	if !modified {
@@ -189,6 +252,9 @@ func (ft *FstabTuner) Apply(backup *BackupManager) error {
 
 	// Generate new fstab content
 	newContent := ft.GenerateFstab(entries)
+	if err := ft.verify(newContent); err != nil {
+		return err
+	}
 
 	if ft.DryRun {
 		PrintInfo("Would update: %s", ft.FstabPath)
@@ -201,8 +267,12 @@ func (ft *FstabTuner) Apply(backup *BackupManager) error {
 	if err := backup.BackupFile(ft.FstabPath); err != nil {
 		return fmt.Errorf("failed to backup fstab: %w", err)
 	}
+	if err := backup.AddRecoverySteps(backup.fstabRecoverySteps(ft.FstabPath, changes)); err != nil {
+		PrintWarning("%v", err)
+	}
 
 	// Write new fstab
+	ExplainEdit("add mount options that avoid needless writes (noatime)", ft.FstabPath)
 	if err := os.WriteFile(ft.FstabPath, []byte(newContent), 0644); err != nil {
 		return fmt.Errorf("failed to write fstab: %w", err)
 	}
@@ -212,7 +282,7 @@ func (ft *FstabTuner) Apply(backup *BackupManager) error {
 	// Remount filesystems with new options
 	PrintInfo("Remounting filesystems...")
 	for _, entry := range entries {
-		if !entry.IsComment && entry.FSType == "ext4" && entry.MountPoint != "none" {
+		if !entry.IsComment && entry.FSType == "ext4" && entry.MountPoint != "none" && !Excluded.Matches(ExcludeMount, entry.MountPoint) {
 			if err := ft.RemountFilesystem(entry.MountPoint); err != nil {
 				PrintWarning("Failed to remount %s: %v", entry.MountPoint, err)
 				PrintWarning("A reboot may be required for changes to take effect")
@@ -227,32 +297,35 @@ func (ft *FstabTuner) Apply(backup *BackupManager) error {
 
 // GenerateFstab generates fstab content from entries
 func (ft *FstabTuner) GenerateFstab(entries []FstabEntry) string {
-	var lines []string
+	var sb strings.Builder
 
 	for _, entry := range entries {
 		if entry.IsComment {
-			lines = append(lines, entry.Comment)
+			sb.WriteString(entry.Comment)
+			sb.WriteByte('\n')
 			continue
 		}
 
 		// Format the entry
 		optionsStr := strings.Join(entry.Options, ",")
-		line := fmt.Sprintf("%-45s %-15s %-7s %-30s %s %s",
+		fmt.Fprintf(&sb, "%-45s %-15s %-7s %-30s %s %s\n",
 			entry.Device,
 			entry.MountPoint,
 			entry.FSType,
 			optionsStr,
 			entry.Dump,
 			entry.Pass)
-
-		lines = append(lines, line)
 	}
 
-	return strings.Join(lines, "\n") + "\n"
+	if sb.Len() == 0 {
+		return "\n"
+	}
+	return sb.String()
 }
 
 // RemountFilesystem remounts a filesystem with new options
 func (ft *FstabTuner) RemountFilesystem(mountPoint string) error {
+	ExplainCommand("apply the new mount options without a reboot", "mount", "-o", "remount", mountPoint)
 	cmd := exec.Command("mount", "-o", "remount", mountPoint)
 	if output, err := cmd.CombinedOutput(); err != nil {
 		return fmt.Errorf("%s: %w", string(output), err)
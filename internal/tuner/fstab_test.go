@@ -0,0 +1,97 @@
+package tuner
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeGeneratedFstab writes an fstab as automount generators produce them:
+// thousands of entries, optionally with one very long comment line
+func writeGeneratedFstab(tb testing.TB, entries int, longLine bool) string {
+	tb.Helper()
+	var sb strings.Builder
+	sb.WriteString("# /etc/fstab\n/dev/sda1 / ext4 defaults 0 1\n")
+	if longLine {
+		sb.WriteString("# " + strings.Repeat("x", 200*1024) + "\n")
+	}
+	for i := 0; i < entries; i++ {
+		fmt.Fprintf(&sb, "server%d:/export/home%d /net/home%d nfs rw,soft,noauto,x-systemd.automount 0 0\n", i%50, i, i)
+	}
+	path := filepath.Join(tb.TempDir(), "fstab")
+	if err := os.WriteFile(path, []byte(sb.String()), 0644); err != nil {
+		tb.Fatal(err)
+	}
+	return path
+}
+
+func TestParseFstabLongLine(t *testing.T) {
+	ft := &FstabTuner{FstabPath: writeGeneratedFstab(t, 10, true)}
+	entries, err := ft.ParseFstab()
+	if err != nil {
+		t.Fatalf("a 200 KiB line should be accepted: %v", err)
+	}
+	if len(entries) != 13 {
+		t.Fatalf("expected 13 entries, got %d", len(entries))
+	}
+	if got := ft.GenerateFstab(entries); !strings.Contains(got, strings.Repeat("x", 200*1024)) {
+		t.Error("the long comment was not written back")
+	}
+}
+
+func BenchmarkParseFstab(b *testing.B) {
+	ft := &FstabTuner{FstabPath: writeGeneratedFstab(b, 5000, false)}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := ft.ParseFstab(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkGenerateFstab(b *testing.B) {
+	ft := &FstabTuner{FstabPath: writeGeneratedFstab(b, 5000, false)}
+	entries, err := ft.ParseFstab()
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		ft.GenerateFstab(entries)
+	}
+}
+
+func TestFstabVerify(t *testing.T) {
+	saved := findmntVerify
+	defer func() { findmntVerify = saved }()
+	findmntVerify = func(path string) (string, error) {
+		data, _ := os.ReadFile(path)
+		if strings.Contains(string(data), "bogus") {
+			return "[E] unsupported filesystem type", fmt.Errorf("exit status 1")
+		}
+		return "", nil
+	}
+	if _, err := exec.LookPath("findmnt"); err != nil {
+		t.Skip("findmnt not installed")
+	}
+
+	ft := &FstabTuner{FstabPath: filepath.Join(t.TempDir(), "fstab")}
+	os.WriteFile(ft.FstabPath, []byte("/dev/sda1 / ext4 defaults 0 1\n"), 0644)
+	if err := ft.verify("/dev/sda1 / ext4 defaults,noatime 0 1\n"); err != nil {
+		t.Errorf("valid fstab refused: %v", err)
+	}
+	if err := ft.verify("/dev/sda1 / bogus defaults 0 1\n"); !errors.Is(err, ErrValidationFailed) {
+		t.Errorf("invalid fstab accepted: %v", err)
+	}
+	// The errors of the current file are not blamed on the new content
+	os.WriteFile(ft.FstabPath, []byte("/dev/sda1 / bogus defaults 0 1\n"), 0644)
+	if err := ft.verify("/dev/sda1 / bogus noatime 0 1\n"); err != nil {
+		t.Errorf("errors already in the current fstab: %v", err)
+	}
+}
@@ -4,15 +4,22 @@ import (
 	"bufio"
 	"fmt"
 	"os"
+	"path/filepath"
 	"regexp"
 	"strings"
 )
 
 // GrubTuner handles GRUB boot parameter optimization
 type GrubTuner struct {
-	GrubPath string
-	DryRun   bool
-	Distro   *DistroManager
+	GrubPath      string
+	DryRun        bool
+	Distro        *DistroManager
+	Realtime      bool     // PREEMPT_RT kernel: cstate/timer params are left to the RT profile
+	ExtraParams   []string // Additional params (e.g. CPU isolation) merged on Apply
+	StatePath     string   // parameters written by the tool, for grub reset
+	BLSDir        string   // BootLoaderSpec entries updated with grubby ("" without BLS)
+	Bootloader    string   // BootloaderGRUB, BootloaderSystemdBoot... (DetectBootloader)
+	LoaderEntries string   // systemd-boot entries directory
 }
 
 // NewGrubTuner creates a new GRUB tuner
@@ -21,30 +28,90 @@ func NewGrubTuner(dryRun bool, distro *DistroManager) *GrubTuner {
 	if distro != nil {
 		path = distro.GetGrubConfigPath()
 	}
-	
-	return &GrubTuner{
-		GrubPath: path,
-		DryRun:   dryRun,
-		Distro:   distro,
+
+	gt := &GrubTuner{
+		GrubPath:  path,
+		DryRun:    dryRun,
+		Distro:    distro,
+		Realtime:  IsRealtimeKernel(Sys.Root),
+		StatePath: filepath.Join(StateDir, grubStateName),
+	}
+	if UsesBLS("") {
+		gt.BLSDir = blsEntriesDir
 	}
+	gt.Bootloader, gt.LoaderEntries = DetectBootloader("", path)
+	return gt
+}
+
+func init() {
+	Register(Module{
+		Name:        "grub",
+		Description: "GRUB boot parameters",
+		Category:    CategoryTuning,
+		Pipeline:    1,
+		RequireRoot: true,
+		Distros:     packagedDistros,
+		Flag:        &ModuleFlag{Name: "no-grub", Usage: "Skip GRUB boot parameter tuning", Negated: true},
+		Apply: func(ctx *ModuleContext) error {
+			if err := NewGrubTuner(ctx.DryRun, ctx.Distro).Apply(ctx.Backup); err != nil {
+				return err
+			}
+			ctx.RebootRequired = true
+			return nil
+		},
+		Show: func(ctx *ModuleContext) error {
+			return NewGrubTuner(false, ctx.Distro).ShowCurrent()
+		},
+		Verify: func(ctx *ModuleContext) error {
+			return NewGrubTuner(false, ctx.Distro).Verify()
+		},
+	})
 }
 
 // VMwareBootParams returns optimal boot parameters for VMware VMs
+// (tuning.grub.params in the config file replaces the defaults and the
+// parameters of the profile)
 func (gt *GrubTuner) VMwareBootParams() []string {
-	return []string{
-		"elevator=noop",                    // I/O scheduler for VMs
-		"transparent_hugepage=madvise",     // Reduce memory fragmentation
-		"vsyscall=emulate",                 // VMware compatibility
-		"clocksource=tsc",                  // Use TSC for time
-		"tsc=reliable",                     // Trust TSC
-		"intel_idle.max_cstate=0",          // Disable deep C-states
-		"processor.max_cstate=1",           // Keep CPU responsive
-		"nmi_watchdog=0",                   // Disable NMI watchdog (save CPU)
-		"pcie_aspm=off",                    // Disable PCIe power management
+	params := []string{
+		"elevator=noop",                         // I/O scheduler for VMs
+		"transparent_hugepage=madvise",          // Reduce memory fragmentation
+		"vsyscall=emulate",                      // VMware compatibility
+		"clocksource=tsc",                       // Use TSC for time
+		"tsc=reliable",                          // Trust TSC
+		"intel_idle.max_cstate=0",               // Disable deep C-states
+		"processor.max_cstate=1",                // Keep CPU responsive
+		"nmi_watchdog=0",                        // Disable NMI watchdog (save CPU)
+		"pcie_aspm=off",                         // Disable PCIe power management
 		"nvme_core.default_ps_max_latency_us=0", // Disable NVMe power save
 	}
+
+	if len(Tuning.GrubParams) > 0 {
+		params = Tuning.GrubParams
+	} else {
+		params = withProfileParams(params, Tuning.ActiveProfile())
+	}
+
+	if !gt.Realtime {
+		return params
+	}
+
+	// RT kernels: don't fight the realtime profile over cstates and timers
+	var filtered []string
+	for _, param := range params {
+		key := param
+		if idx := strings.Index(param, "="); idx != -1 {
+			key = param[:idx]
+		}
+		if !rtManagedParams[key] {
+			filtered = append(filtered, param)
+		}
+	}
+	return filtered
 }
 
+// grubVarRe matches a variable of /etc/default/grub
+var grubVarRe = regexp.MustCompile(`^([A-Z_]+)=(.*)$`)
+
 // ParseGrubConfig parses GRUB configuration
 func (gt *GrubTuner) ParseGrubConfig() (map[string]string, []string, error) {
 	file, err := os.Open(gt.GrubPath)
@@ -56,6 +123,7 @@ func (gt *GrubTuner) ParseGrubConfig() (map[string]string, []string, error) {
 	config := make(map[string]string)
 	var lines []string
 	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxConfigLine)
 
 	for scanner.Scan() {
 		line := scanner.Text()
@@ -67,8 +135,7 @@ func (gt *GrubTuner) ParseGrubConfig() (map[string]string, []string, error) {
 		}
 
 		// Match GRUB_* variables
-		re := regexp.MustCompile(`^([A-Z_]+)=(.*)$`)
-		matches := re.FindStringSubmatch(strings.TrimSpace(line))
+		matches := grubVarRe.FindStringSubmatch(strings.TrimSpace(line))
 		if len(matches) == 3 {
 			key := matches[1]
 			value := strings.Trim(matches[2], `"`)
@@ -87,6 +154,45 @@ func (gt *GrubTuner) ParseGrubConfig() (map[string]string, []string, error) {
 func (gt *GrubTuner) Apply(backup *BackupManager) error {
 	PrintStep("Optimizing GRUB boot parameters")
 
+	// Get VMware optimal params
+	params := gt.VMwareBootParams()
+	if gt.Realtime {
+		PrintInfo("PREEMPT_RT kernel detected: C-state and clocksource parameters left unchanged")
+	}
+	params = append(params, gt.ExtraParams...)
+
+	// Expert parameters come last: they win over the profile (transparent_hugepage)
+	expert, err := gt.expertParams()
+	if err != nil {
+		return err
+	}
+	return gt.applyParams(backup, append(params, expert...))
+}
+
+// AddParams sets only the given parameters (ipv6.disable=1, hugepages...)
+// in the bootloader configuration, the rest of the command line as it is.
+// The modules needing one parameter use it instead of Apply, which would
+// also write the VMware parameters, even under --no-grub.
+func (gt *GrubTuner) AddParams(backup *BackupManager, params []string) error {
+	return gt.applyParams(backup, params)
+}
+
+// applyParams merges params into the kernel command line of the bootloader
+func (gt *GrubTuner) applyParams(backup *BackupManager, params []string) error {
+	ostree := gt.Distro != nil && gt.Distro.Ostree
+	if ostree || gt.Bootloader != BootloaderGRUB {
+		switch {
+		case ostree:
+			return gt.applyKargs(params)
+		case gt.Bootloader == BootloaderSystemdBoot:
+			return gt.applyLoaderEntries(backup, params)
+		case gt.Bootloader == BootloaderKernelstub:
+			return gt.applyKernelstub(backup, params)
+		}
+		PrintWarning("No supported bootloader found (GRUB, systemd-boot, kernelstub): boot parameters left unchanged")
+		return nil
+	}
+
 	// Parse current GRUB config
 	config, lines, err := gt.ParseGrubConfig()
 	if err != nil {
@@ -97,17 +203,15 @@ func (gt *GrubTuner) Apply(backup *BackupManager) error {
 	currentCmdline := config["GRUB_CMDLINE_LINUX_DEFAULT"]
 	currentParams := gt.parseParams(currentCmdline)
 
-	// Get VMware optimal params
-	vmwareParams := gt.VMwareBootParams()
-
 	// Merge parameters
-	newParams := gt.mergeParams(currentParams, vmwareParams)
+	newParams := gt.mergeParams(currentParams, params)
 	newCmdline := strings.Join(newParams, " ")
 
-	// Check if modification is needed
-	if currentCmdline == newCmdline {
+	// Check if modification is needed (extra spaces are not a change)
+	if strings.Join(currentParams, " ") == newCmdline {
 		PrintSuccess("GRUB boot parameters already optimized")
-		return nil
+		// Entries written before BLS support may still lack them
+		return gt.syncBLS(backup, currentParams, newParams)
 	}
 
 	PrintInfo("Current cmdline: %s", currentCmdline)
@@ -115,23 +219,31 @@ func (gt *GrubTuner) Apply(backup *BackupManager) error {
 
 	if gt.DryRun {
 		PrintInfo("Would update: %s", gt.GrubPath)
-		return nil
+		return gt.syncBLS(backup, currentParams, newParams)
 	}
 
 	// Backup existing GRUB config
 	if err := backup.BackupFile(gt.GrubPath); err != nil {
 		return fmt.Errorf("failed to backup grub config: %w", err)
 	}
+	recovery := backup.grubRecoverySteps(gt.GrubPath, addedParams(currentParams, newParams), gt.Distro, gt.BLSDir != "")
+	if err := backup.AddRecoverySteps(recovery); err != nil {
+		PrintWarning("%v", err)
+	}
 
 	// Update GRUB configuration
 	newLines := gt.updateGrubLines(lines, newCmdline)
 	newContent := strings.Join(newLines, "\n") + "\n"
 
+	ExplainEdit("add the VMware boot parameters to GRUB_CMDLINE_LINUX_DEFAULT (used from the next boot)", gt.GrubPath)
 	if err := os.WriteFile(gt.GrubPath, []byte(newContent), 0644); err != nil {
 		return fmt.Errorf("failed to write grub config: %w", err)
 	}
 
 	PrintSuccess("Updated %s", gt.GrubPath)
+	if err := gt.recordGrubChanges(currentParams, newParams); err != nil {
+		PrintWarning("Could not record the boot parameters (grub reset will guess them): %v", err)
+	}
 
 	// Run update-grub
 	PrintInfo("Updating GRUB configuration...")
@@ -139,6 +251,9 @@ func (gt *GrubTuner) Apply(backup *BackupManager) error {
 		PrintWarning("Failed to update GRUB: %v", err)
 		return fmt.Errorf("grub update failed: %w", err)
 	}
+	if err := gt.syncBLS(backup, currentParams, newParams); err != nil {
+		return err
+	}
 
 	PrintSuccess("GRUB configuration updated")
 	PrintWarning("REBOOT REQUIRED for boot parameter changes to take effect")
@@ -157,35 +272,41 @@ func (gt *GrubTuner) parseParams(cmdline string) []string {
 	return params
 }
 
-// mergeParams merges existing and new parameters
+// mergeParams merges existing and new parameters. Existing parameters keep
+// their position (a new value replaces the old one in place), new ones are
+// appended in order, so merging twice gives the same cmdline.
 func (gt *GrubTuner) mergeParams(existing, new []string) []string {
-	// Create a map to track parameter keys
-	paramMap := make(map[string]string)
-
-	// Extract key from param (handle key=value and standalone params)
-	getKey := func(param string) string {
-		if idx := strings.Index(param, "="); idx != -1 {
-			return param[:idx]
+	// Last value wins when a key is given twice
+	override := make(map[string]string)
+	var added []string
+	for _, param := range new {
+		key := paramKey(param)
+		if _, ok := override[key]; !ok {
+			added = append(added, key)
 		}
-		return param
+		override[key] = param
 	}
 
-	// Add existing params
+	var result []string
+	placed := make(map[string]bool)
 	for _, param := range existing {
-		key := getKey(param)
-		paramMap[key] = param
-	}
-
-	// Add/override with new params
-	for _, param := range new {
-		key := getKey(param)
-		paramMap[key] = param
+		key := paramKey(param)
+		value, ok := override[key]
+		if !ok {
+			// Repeated keys not managed here (console=tty0 console=ttyS0) are kept
+			result = append(result, param)
+			continue
+		}
+		if !placed[key] {
+			placed[key] = true
+			result = append(result, value)
+		}
 	}
 
-	// Convert back to slice
-	var result []string
-	for _, param := range paramMap {
-		result = append(result, param)
+	for _, key := range added {
+		if !placed[key] {
+			result = append(result, override[key])
+		}
 	}
 
 	return result
@@ -209,6 +330,17 @@ func (gt *GrubTuner) updateGrubLines(lines []string, newCmdline string) []string
 
 // ShowCurrent displays current boot parameters
 func (gt *GrubTuner) ShowCurrent() error {
+	if gt.Bootloader != BootloaderGRUB {
+		PrintStep("Current boot parameters")
+		params, err := gt.configuredParams()
+		if err != nil {
+			return err
+		}
+		fmt.Printf("  Bootloader: %s\n", gt.Bootloader)
+		fmt.Printf("  Kernel options: %s\n", strings.Join(params, " "))
+		return nil
+	}
+
 	PrintStep("Current GRUB configuration")
 
 	config, _, err := gt.ParseGrubConfig()
@@ -227,12 +359,12 @@ func (gt *GrubTuner) ShowCurrent() error {
 
 	// Also show current running kernel parameters
 	PrintStep("Current running kernel parameters")
-	data, err := os.ReadFile("/proc/cmdline")
+	running, err := Sys.Cmdline()
 	if err != nil {
 		return err
 	}
 
-	fmt.Printf("  %s\n", strings.TrimSpace(string(data)))
+	fmt.Printf("  %s\n", running)
 
 	return nil
 }
@@ -0,0 +1,113 @@
+package tuner
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestMergeParams(t *testing.T) {
+	gt := &GrubTuner{}
+	existing := []string{"quiet", "console=tty0", "console=ttyS0", "elevator=cfq", "splash"}
+	merged := gt.mergeParams(existing, []string{"elevator=noop", "transparent_hugepage=madvise", "mitigations=auto"})
+	want := []string{"quiet", "console=tty0", "console=ttyS0", "elevator=noop", "splash", "transparent_hugepage=madvise", "mitigations=auto"}
+	if !reflect.DeepEqual(merged, want) {
+		t.Fatalf("merged = %v, want %v", merged, want)
+	}
+
+	// Idempotent: merging the result again changes nothing
+	if again := gt.mergeParams(merged, []string{"elevator=noop", "transparent_hugepage=madvise", "mitigations=auto"}); !reflect.DeepEqual(again, merged) {
+		t.Errorf("second merge = %v, want %v", again, merged)
+	}
+}
+
+func TestExpertBootConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	content := "tuning:\n  grub:\n    expert: [mitigations=off, transparent_hugepage=never, \"isolcpus=2-3\"]\n"
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+	if want := []string{"mitigations=off", "transparent_hugepage=never", "isolcpus=2-3"}; !reflect.DeepEqual(cfg.Tuning.ExpertBoot, want) {
+		t.Errorf("ExpertBoot = %v, want %v", cfg.Tuning.ExpertBoot, want)
+	}
+	if cfg.Tuning.GrubParams != nil {
+		t.Errorf("expert parameters replaced the defaults: %v", cfg.Tuning.GrubParams)
+	}
+
+	for _, bad := range []string{"mitigations=auto", "nohz_full", "quiet"} {
+		content := "tuning:\n  grub:\n    expert: [" + bad + "]\n"
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := LoadConfig(path); err == nil {
+			t.Errorf("expert parameter %q accepted", bad)
+		}
+	}
+
+	// The expert value wins over the profile's
+	merged := (&GrubTuner{}).mergeParams([]string{"quiet"}, []string{"transparent_hugepage=madvise", "transparent_hugepage=never"})
+	if want := []string{"quiet", "transparent_hugepage=never"}; !reflect.DeepEqual(merged, want) {
+		t.Errorf("merged = %v, want %v", merged, want)
+	}
+}
+
+func TestBLS(t *testing.T) {
+	root := t.TempDir()
+	entries := filepath.Join(root, blsEntriesDir)
+	os.MkdirAll(entries, 0755)
+	os.MkdirAll(filepath.Join(root, "etc", "default"), 0755)
+	os.WriteFile(filepath.Join(root, "etc", "default", "grub"), []byte("GRUB_CMDLINE_LINUX_DEFAULT=\"quiet\"\n"), 0644)
+	if UsesBLS(root) {
+		t.Error("BLS detected without entries")
+	}
+
+	os.WriteFile(filepath.Join(entries, "a-5.14.0-362.el9.x86_64.conf"), []byte("title RHEL\nlinux /vmlinuz\noptions root=/dev/sda2 ro quiet elevator=noop\n"), 0644)
+	os.WriteFile(filepath.Join(entries, "a-5.14.0-70.el9.x86_64.conf"), []byte("title RHEL\nlinux /vmlinuz\noptions root=/dev/sda2 ro quiet\n"), 0644)
+	os.WriteFile(filepath.Join(entries, "b-4.18.0.el8.x86_64.conf"), []byte("title RHEL 8\noptions $kernelopts $tuned_params\n"), 0644)
+	if UsesBLS(root) {
+		t.Error("BLS detected without GRUB_ENABLE_BLSCFG")
+	}
+	os.WriteFile(filepath.Join(root, "etc", "default", "grub"), []byte("GRUB_CMDLINE_LINUX_DEFAULT=\"quiet\"\nGRUB_ENABLE_BLSCFG=true\n"), 0644)
+	if !UsesBLS(root) {
+		t.Fatal("BLS not detected")
+	}
+
+	missing, err := blsMissing(entries, []string{"quiet", "elevator=noop", "clocksource=tsc"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"elevator=noop", "clocksource=tsc"}; !reflect.DeepEqual(missing, want) {
+		t.Errorf("missing = %v, want %v", missing, want)
+	}
+
+	if removed := removedParams([]string{"quiet", "elevator=noop", "nmi_watchdog=0"}, []string{"quiet", "elevator=cfq"}); !reflect.DeepEqual(removed, []string{"nmi_watchdog=0"}) {
+		t.Errorf("removed = %v", removed)
+	}
+}
+
+func BenchmarkParseGrubConfig(b *testing.B) {
+	var sb strings.Builder
+	sb.WriteString("GRUB_DEFAULT=0\nGRUB_CMDLINE_LINUX_DEFAULT=\"quiet splash\"\n")
+	for i := 0; i < 5000; i++ {
+		fmt.Fprintf(&sb, "# generated entry %d\nGRUB_EXTRA_%d=\"value %d\"\n", i, i, i)
+	}
+	path := filepath.Join(b.TempDir(), "grub")
+	if err := os.WriteFile(path, []byte(sb.String()), 0644); err != nil {
+		b.Fatal(err)
+	}
+	gt := &GrubTuner{GrubPath: path}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, _, err := gt.ParseGrubConfig(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
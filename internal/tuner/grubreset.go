@@ -0,0 +1,245 @@
+package tuner
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// grubStateName records the boot parameters set by the tool (in StateDir)
+const grubStateName = "grub-params.json"
+
+// GrubParamChange is a boot parameter written by the tool
+type GrubParamChange struct {
+	Param    string `json:"param"`              // parameter as written by the tool
+	Previous string `json:"previous,omitempty"` // parameter it replaced ("" when added)
+}
+
+// loadGrubChanges reads the recorded changes (none when never tuned)
+func loadGrubChanges(path string) ([]GrubParamChange, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	var changes []GrubParamChange
+	if err := json.Unmarshal(data, &changes); err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", path, err)
+	}
+	return changes, nil
+}
+
+// trackGrubChanges adds the parameters that differ between before and after
+// to the recorded changes. The value an admin had before the first run is
+// kept across runs.
+func trackGrubChanges(changes []GrubParamChange, before, after []string) []GrubParamChange {
+	prev := make(map[string]string)
+	for _, param := range before {
+		prev[paramKey(param)] = param
+	}
+	index := make(map[string]int)
+	for i, c := range changes {
+		index[paramKey(c.Param)] = i
+	}
+
+	for _, param := range after {
+		key := paramKey(param)
+		if prev[key] == param {
+			continue
+		}
+		if i, ok := index[key]; ok {
+			changes[i].Param = param
+			continue
+		}
+		index[key] = len(changes)
+		changes = append(changes, GrubParamChange{Param: param, Previous: prev[key]})
+	}
+	return changes
+}
+
+// recordGrubChanges saves the parameters written by Apply
+func (gt *GrubTuner) recordGrubChanges(before, after []string) error {
+	changes, err := loadGrubChanges(gt.StatePath)
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(trackGrubChanges(changes, before, after), "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(gt.StatePath), 0755); err != nil {
+		return fmt.Errorf("failed to create %s: %w", filepath.Dir(gt.StatePath), err)
+	}
+	return os.WriteFile(gt.StatePath, data, 0644)
+}
+
+// resetParams undoes the recorded changes: added parameters are removed and
+// replaced ones get their previous value back. A parameter changed by an
+// admin since is kept and returned in modified.
+func resetParams(current []string, changes []GrubParamChange) (result, modified []string) {
+	byKey := make(map[string]GrubParamChange)
+	for _, c := range changes {
+		byKey[paramKey(c.Param)] = c
+	}
+
+	for _, param := range current {
+		c, ok := byKey[paramKey(param)]
+		switch {
+		case !ok:
+			result = append(result, param)
+		case param != c.Param:
+			modified = append(modified, param)
+			result = append(result, param)
+		case c.Previous != "":
+			result = append(result, c.Previous)
+		}
+	}
+	return result, modified
+}
+
+// legacyGrubChanges guesses the changes of a run made before they were
+// recorded: the built-in parameters of every profile, without previous values
+func (gt *GrubTuner) legacyGrubChanges() []GrubParamChange {
+	saved := Tuning
+	defer func() { Tuning = saved }()
+
+	seen := make(map[string]bool)
+	var changes []GrubParamChange
+	for _, name := range ProfileNames() {
+		Tuning = TuningConfig{Profile: name}
+		for _, param := range gt.VMwareBootParams() {
+			if !seen[param] {
+				seen[param] = true
+				changes = append(changes, GrubParamChange{Param: param})
+			}
+		}
+	}
+	return changes
+}
+
+// presentChanges returns the changes whose parameter is on the command line
+func presentChanges(current []string, changes []GrubParamChange) []GrubParamChange {
+	on := make(map[string]bool)
+	for _, param := range current {
+		on[param] = true
+	}
+	var present []GrubParamChange
+	for _, c := range changes {
+		if on[c.Param] {
+			present = append(present, c)
+		}
+	}
+	return present
+}
+
+// confirmLegacyChanges asks, parameter by parameter, which of the guessed
+// changes to undo: the same value may have been set by an admin. Without a
+// terminal none is removed.
+func (gt *GrubTuner) confirmLegacyChanges(current []string, changes []GrubParamChange) []GrubParamChange {
+	present := presentChanges(current, changes)
+	if len(present) == 0 {
+		return nil
+	}
+	var params []string
+	for _, c := range present {
+		params = append(params, c.Param)
+	}
+	PrintWarning("Parameters with the vmware-tuner values, maybe set by an admin: %s", strings.Join(params, " "))
+	if gt.DryRun {
+		PrintInfo("Would ask before removing each of them")
+		return present
+	}
+	if !isInteractive() {
+		PrintWarning("Keeping them: run grub reset interactively to confirm their removal")
+		return nil
+	}
+
+	var confirmed []GrubParamChange
+	for _, c := range present {
+		fmt.Printf("Remove %s? (y/N): ", c.Param)
+		var response string
+		fmt.Scanln(&response)
+		if response == "y" || response == "yes" {
+			confirmed = append(confirmed, c)
+		} else {
+			PrintInfo("Keeping %s", c.Param)
+		}
+	}
+	return confirmed
+}
+
+// Reset removes the boot parameters added by the tool and restores the ones
+// it replaced, keeping the parameters added by admins
+func (gt *GrubTuner) Reset(backup *BackupManager) error {
+	PrintStep("Reset GRUB boot parameters")
+
+	if gt.Distro != nil && gt.Distro.Ostree {
+		return errOstreeUnsupported("grub reset", "use 'rpm-ostree rollback' or 'rpm-ostree kargs --delete'")
+	}
+	if gt.Bootloader != BootloaderGRUB {
+		return fmt.Errorf("grub reset only supports GRUB (this system uses %q): restore the boot entries with 'vmware-tuner rollback'", gt.Bootloader)
+	}
+
+	changes, err := loadGrubChanges(gt.StatePath)
+	if err != nil {
+		return err
+	}
+
+	config, lines, err := gt.ParseGrubConfig()
+	if err != nil {
+		return err
+	}
+	currentCmdline := config["GRUB_CMDLINE_LINUX_DEFAULT"]
+	if changes == nil {
+		PrintWarning("No record of the parameters set by vmware-tuner (%s)", gt.StatePath)
+		changes = gt.confirmLegacyChanges(gt.parseParams(currentCmdline), gt.legacyGrubChanges())
+	}
+	newParams, modified := resetParams(gt.parseParams(currentCmdline), changes)
+	newCmdline := strings.Join(newParams, " ")
+
+	for _, param := range modified {
+		PrintWarning("%s was changed since tuning: kept", param)
+	}
+	if newCmdline == currentCmdline {
+		PrintSuccess("No vmware-tuner boot parameters to remove")
+		return nil
+	}
+
+	PrintInfo("Current cmdline: %s", currentCmdline)
+	PrintInfo("New cmdline: %s", newCmdline)
+
+	if gt.DryRun {
+		PrintInfo("Would update: %s", gt.GrubPath)
+		return gt.syncBLS(backup, gt.parseParams(currentCmdline), newParams)
+	}
+
+	if err := backup.BackupFile(gt.GrubPath); err != nil {
+		return fmt.Errorf("failed to backup grub config: %w", err)
+	}
+	newContent := strings.Join(gt.updateGrubLines(lines, newCmdline), "\n") + "\n"
+	ExplainEdit("remove the boot parameters vmware-tuner added to GRUB_CMDLINE_LINUX_DEFAULT", gt.GrubPath)
+	if err := os.WriteFile(gt.GrubPath, []byte(newContent), 0644); err != nil {
+		return fmt.Errorf("failed to write grub config: %w", err)
+	}
+	PrintSuccess("Updated %s", gt.GrubPath)
+
+	PrintInfo("Updating GRUB configuration...")
+	if err := gt.Distro.UpdateGrub(); err != nil {
+		return fmt.Errorf("grub update failed: %w", err)
+	}
+	if err := gt.syncBLS(backup, gt.parseParams(currentCmdline), newParams); err != nil {
+		return err
+	}
+	if err := os.Remove(gt.StatePath); err != nil && !os.IsNotExist(err) {
+		PrintWarning("Could not remove %s: %v", gt.StatePath, err)
+	}
+	LogAction("grub", "reset", ResultSuccess, fmt.Sprintf("cmdline=%q", newCmdline))
+
+	PrintSuccess("GRUB configuration updated")
+	PrintWarning("REBOOT REQUIRED for boot parameter changes to take effect")
+	return nil
+}
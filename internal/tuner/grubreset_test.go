@@ -0,0 +1,69 @@
+package tuner
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestTrackGrubChanges(t *testing.T) {
+	before := []string{"quiet", "elevator=cfq", "console=ttyS0"}
+	after := []string{"quiet", "elevator=noop", "console=ttyS0", "transparent_hugepage=madvise"}
+	changes := trackGrubChanges(nil, before, after)
+	want := []GrubParamChange{
+		{Param: "elevator=noop", Previous: "elevator=cfq"},
+		{Param: "transparent_hugepage=madvise"},
+	}
+	if !reflect.DeepEqual(changes, want) {
+		t.Fatalf("changes = %+v, want %+v", changes, want)
+	}
+
+	// A second run keeps the value the admin had before the first one
+	changes = trackGrubChanges(changes, after, []string{"quiet", "elevator=none", "console=ttyS0", "transparent_hugepage=madvise"})
+	if changes[0] != (GrubParamChange{Param: "elevator=none", Previous: "elevator=cfq"}) {
+		t.Errorf("changes[0] = %+v", changes[0])
+	}
+}
+
+func TestResetParams(t *testing.T) {
+	changes := []GrubParamChange{
+		{Param: "elevator=noop", Previous: "elevator=cfq"},
+		{Param: "transparent_hugepage=madvise"},
+		{Param: "mitigations=off"},
+	}
+	current := []string{"quiet", "elevator=noop", "transparent_hugepage=madvise", "mitigations=auto", "crashkernel=256M"}
+
+	result, modified := resetParams(current, changes)
+	if want := []string{"quiet", "elevator=cfq", "mitigations=auto", "crashkernel=256M"}; !reflect.DeepEqual(result, want) {
+		t.Errorf("result = %v, want %v", result, want)
+	}
+	if want := []string{"mitigations=auto"}; !reflect.DeepEqual(modified, want) {
+		t.Errorf("modified = %v, want %v", modified, want)
+	}
+}
+
+func TestPresentChanges(t *testing.T) {
+	legacy := []GrubParamChange{{Param: "elevator=noop"}, {Param: "transparent_hugepage=madvise"}, {Param: "nmi_watchdog=0"}}
+	current := []string{"quiet", "elevator=noop", "transparent_hugepage=never"}
+	if got, want := presentChanges(current, legacy), []GrubParamChange{{Param: "elevator=noop"}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("present = %+v, want %+v", got, want)
+	}
+}
+
+func TestRecordGrubChanges(t *testing.T) {
+	gt := &GrubTuner{StatePath: filepath.Join(t.TempDir(), "state", grubStateName)}
+	if err := gt.recordGrubChanges([]string{"quiet"}, []string{"quiet", "elevator=noop"}); err != nil {
+		t.Fatal(err)
+	}
+	changes, err := loadGrubChanges(gt.StatePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []GrubParamChange{{Param: "elevator=noop"}}; !reflect.DeepEqual(changes, want) {
+		t.Errorf("changes = %+v, want %+v", changes, want)
+	}
+
+	if changes, err := loadGrubChanges(filepath.Join(t.TempDir(), grubStateName)); err != nil || changes != nil {
+		t.Errorf("missing state = %v, %v; want nil, nil", changes, err)
+	}
+}
@@ -0,0 +1,94 @@
+package tuner
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// guestInfoPrefix is the namespace of the keys published for vCenter, next
+// to ProductionGuestInfoKey which vSphere sets for us
+const guestInfoPrefix = "guestinfo.vmware-tuner."
+
+// GuestInfoStatus is the tuning summary published to guestinfo, so vSphere
+// admins can read it from vCenter (govc vm.info -e, PowerCLI) without
+// logging into the guest
+type GuestInfoStatus struct {
+	Version  string
+	Profile  string
+	Score    int
+	MaxScore int
+	LastRun  time.Time
+	Failed   []string // modules that failed during the run
+}
+
+// NewGuestInfoStatus builds the status of a run: the applied profile and a
+// fresh audit score
+func NewGuestInfoStatus(distro *DistroManager, version string, rs *RunSummary) GuestInfoStatus {
+	audit := NewAuditTuner(distro).Evaluate()
+	status := GuestInfoStatus{
+		Version:  version,
+		Profile:  AppliedProfile(NewSysctlTuner(false).ConfigPath),
+		Score:    audit.Score,
+		MaxScore: audit.MaxScore,
+		LastRun:  time.Now(),
+	}
+	if rs != nil {
+		for _, f := range rs.Failed {
+			status.Failed = append(status.Failed, f.Module)
+		}
+	}
+	return status
+}
+
+// Keys returns the guestinfo keys and values, in publishing order
+func (s GuestInfoStatus) Keys() [][2]string {
+	profile := s.Profile
+	if profile == "" {
+		profile = "default"
+	}
+	result := "ok"
+	if len(s.Failed) > 0 {
+		result = fmt.Sprintf("%d failed: %s", len(s.Failed), strings.Join(s.Failed, ","))
+	}
+	return [][2]string{
+		{guestInfoPrefix + "version", s.Version},
+		{guestInfoPrefix + "profile", profile},
+		{guestInfoPrefix + "audit-score", fmt.Sprintf("%d/%d", s.Score, s.MaxScore)},
+		{guestInfoPrefix + "last-run", s.LastRun.UTC().Format(time.RFC3339)},
+		{guestInfoPrefix + "result", result},
+	}
+}
+
+// PublishGuestInfo sets the status keys on the VM. Publishing is best effort:
+// without VMware Tools the tuning is still done, only not visible from vCenter.
+func PublishGuestInfo(status GuestInfoStatus) {
+	PrintStep("vCenter Status (guestinfo)")
+	for _, kv := range status.Keys() {
+		if err := writeGuestInfo(kv[0], kv[1]); err != nil {
+			PrintWarning("Tuning status not published to vCenter: %v", err)
+			return
+		}
+	}
+	PrintSuccess("Tuning status published to %s* (audit %d/%d)", guestInfoPrefix, status.Score, status.MaxScore)
+}
+
+// writeGuestInfo sets a guestinfo key through the tools RPC channel, the
+// counterpart of readGuestInfo. Variable so tests can stub it.
+var writeGuestInfo = func(key, value string) error {
+	var cmd *exec.Cmd
+	if _, err := exec.LookPath("vmware-rpctool"); err == nil {
+		cmd = exec.Command("vmware-rpctool", "info-set "+key+" "+value)
+	} else if _, err := exec.LookPath("vmtoolsd"); err == nil {
+		cmd = exec.Command("vmtoolsd", "--cmd", "info-set "+key+" "+value)
+	} else {
+		return fmt.Errorf("vmware-rpctool and vmtoolsd not found (install open-vm-tools)")
+	}
+
+	ExplainCommand("show the tuning status in vCenter", cmd.Args[0], cmd.Args[1:]...)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to set %s: %v: %s", key, err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
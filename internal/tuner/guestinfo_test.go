@@ -0,0 +1,58 @@
+package tuner
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestGuestInfoKeys(t *testing.T) {
+	status := GuestInfoStatus{
+		Version:  "1.1.0",
+		Score:    42,
+		MaxScore: 50,
+		LastRun:  time.Date(2024, 3, 1, 10, 30, 0, 0, time.FixedZone("CET", 3600)),
+		Failed:   []string{"grub", "swap"},
+	}
+	want := map[string]string{
+		"guestinfo.vmware-tuner.version":     "1.1.0",
+		"guestinfo.vmware-tuner.profile":     "default",
+		"guestinfo.vmware-tuner.audit-score": "42/50",
+		"guestinfo.vmware-tuner.last-run":    "2024-03-01T09:30:00Z",
+		"guestinfo.vmware-tuner.result":      "2 failed: grub,swap",
+	}
+	keys := status.Keys()
+	if len(keys) != len(want) {
+		t.Fatalf("got %d keys, want %d", len(keys), len(want))
+	}
+	for _, kv := range keys {
+		if want[kv[0]] != kv[1] {
+			t.Errorf("%s = %q, want %q", kv[0], kv[1], want[kv[0]])
+		}
+	}
+
+	status.Profile, status.Failed = "database", nil
+	for _, kv := range status.Keys() {
+		if kv[0] == "guestinfo.vmware-tuner.profile" && kv[1] != "database" {
+			t.Errorf("profile = %q, want database", kv[1])
+		}
+		if kv[0] == "guestinfo.vmware-tuner.result" && kv[1] != "ok" {
+			t.Errorf("result = %q, want ok", kv[1])
+		}
+	}
+}
+
+func TestPublishGuestInfoStopsOnError(t *testing.T) {
+	saved := writeGuestInfo
+	defer func() { writeGuestInfo = saved }()
+
+	calls := 0
+	writeGuestInfo = func(key, value string) error {
+		calls++
+		return errors.New("no RPC channel")
+	}
+	PublishGuestInfo(GuestInfoStatus{Version: "1.1.0"})
+	if calls != 1 {
+		t.Errorf("writeGuestInfo called %d times, want 1 (stop at the first failure)", calls)
+	}
+}
@@ -0,0 +1,158 @@
+package tuner
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// updateGolden rewrites the golden files from the current output:
+// go test ./internal/tuner -run TestGuestFixtures -update
+var updateGolden = flag.Bool("update", false, "rewrite the golden files of testdata/guests")
+
+// guestReports are the show, verify and audit output compared for every
+// guest tree of testdata/guests
+var guestReports = []struct {
+	name string
+	run  func(root string, cfg *Config) error
+}{
+	{"show", guestShow},
+	{"verify", guestVerify},
+	{"audit", guestAudit},
+}
+
+// TestGuestFixtures runs show, verify and audit against the /proc and /sys
+// trees captured from VMware guests (testdata/guests/<guest>/root) and
+// compares their output with testdata/guests/<guest>/<report>.golden
+func TestGuestFixtures(t *testing.T) {
+	guests, _ := filepath.Glob(filepath.Join("testdata", "guests", "*"))
+	if len(guests) == 0 {
+		t.Fatal("no guest fixtures in testdata/guests")
+	}
+	defer func(sys SysFS) { Sys = sys }(Sys)
+	defer SetTheme("default")
+	if err := SetTheme("ascii"); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, guest := range guests {
+		root, err := filepath.Abs(filepath.Join(guest, "root"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		cfg, err := LoadConfig(filepath.Join(root, DefaultConfigPath))
+		if err != nil {
+			t.Fatal(err)
+		}
+		Sys = SysFS{Root: root}
+
+		for _, report := range guestReports {
+			t.Run(filepath.Base(guest)+"/"+report.name, func(t *testing.T) {
+				out := filepath.Join(t.TempDir(), "out.txt")
+				captureOutput(out, func() error {
+					// PrintError writes to stderr: keep the lines in order
+					defer func(stderr *os.File) { os.Stderr = stderr }(os.Stderr)
+					os.Stderr = os.Stdout
+					return report.run(root, cfg)
+				})
+				got, _ := os.ReadFile(out)
+
+				golden := filepath.Join(guest, report.name+".golden")
+				if *updateGolden {
+					if err := os.WriteFile(golden, got, 0644); err != nil {
+						t.Fatal(err)
+					}
+					return
+				}
+				want, err := os.ReadFile(golden)
+				if err != nil {
+					t.Fatalf("%v (go test -run TestGuestFixtures -update writes it)", err)
+				}
+				if string(got) != string(want) {
+					t.Errorf("%s differs from %s:\n%s", report.name, golden, got)
+				}
+			})
+		}
+	}
+}
+
+// guestShow prints what show reads from the kernel
+func guestShow(root string, cfg *Config) error {
+	vmware, _ := IsVMware(root)
+	fmt.Printf("VMware: %v\n", vmware)
+	cmdline, err := Sys.Cmdline()
+	fmt.Printf("Kernel command line: %s (%v)\n", cmdline, err)
+	interfaces, err := ListInterfaces(root)
+	if err != nil {
+		return err
+	}
+	for _, iface := range interfaces {
+		fmt.Printf("Interface: %s (%s)\n", iface.Name, iface.Driver)
+	}
+	trim := NewTrimTuner(true)
+	trim.FSRoot = root
+	fmt.Printf("Discard: %s\n", strings.Join(trim.DiscardDisks(), ", "))
+
+	(&NUMATuner{FSRoot: root}).Run()
+	scheduler := NewSchedulerTuner(true)
+	scheduler.FSRoot = root
+	scheduler.ShowCurrent()
+	hugepages := NewHugePagesTuner(true, nil)
+	hugepages.FSRoot, hugepages.Config = root, cfg.Tuning.HugePages
+	if err := hugepages.ShowCurrent(); err != nil {
+		return err
+	}
+	tuned := NewTunedTuner(true, nil)
+	tuned.FSRoot = root
+	return tuned.ShowCurrent()
+}
+
+// guestVerify prints the outcome of the verify checks reading the kernel
+func guestVerify(root string, cfg *Config) error {
+	scheduler := NewSchedulerTuner(true)
+	scheduler.FSRoot = root
+	queue := NewQueueTuner(true)
+	queue.FSRoot = root
+	hugepages := NewHugePagesTuner(true, nil)
+	hugepages.FSRoot, hugepages.Config = root, cfg.Tuning.HugePages
+	tuned := NewTunedTuner(true, nil)
+	tuned.FSRoot = root
+
+	for _, check := range []struct {
+		name   string
+		verify func() error
+	}{
+		{"scheduler", scheduler.Verify},
+		{"queue", queue.Verify},
+		{"sysctl", NewSysctlTuner(true).Verify},
+		{"hugepages", hugepages.Verify},
+		{"tuned", tuned.Verify},
+	} {
+		if err := check.verify(); err != nil {
+			fmt.Printf("%s: %v\n", check.name, err)
+		} else {
+			fmt.Printf("%s: ok\n", check.name)
+		}
+	}
+	return nil
+}
+
+// guestAudit prints the audit items and latency checks computed from the kernel
+func guestAudit(root string, cfg *Config) error {
+	numa, _ := ReadNUMA(root)
+	for _, item := range []AuditItem{auditSysctl(NewSysctlTuner(true), root), auditNUMA(numa), auditTuned(ReadTunedStatus(root))} {
+		fmt.Printf("[%s] %s: %s\n", item.Status, item.Name, item.Message)
+		for _, detail := range item.Details {
+			fmt.Printf("    %s\n", detail)
+		}
+	}
+
+	cmdline, _ := Sys.Cmdline()
+	for _, c := range []LatencyCheck{checkCStates(cmdline, cstateParams()), checkClocksource(), checkTHP(), checkHugepages()} {
+		fmt.Printf("%s: passed=%v %s\n", c.Name, c.Passed, c.Detail)
+	}
+	return nil
+}
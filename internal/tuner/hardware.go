@@ -2,6 +2,7 @@ package tuner
 
 import (
 	"os/exec"
+	"runtime"
 	"strings"
 )
 
@@ -17,60 +18,150 @@ func NewHardwareTuner(distro *DistroManager) *HardwareTuner {
 	}
 }
 
+func init() {
+	Register(Module{
+		Name:        "hardware",
+		Label:       "Check Virtual Hardware",
+		Description: "Check the virtual NICs, disk controllers and hardware version",
+		Category:    CategoryDiagnostics,
+		Menu:        12,
+		ReadOnly:    true,
+		Run: func(ctx *ModuleContext) error {
+			return NewHardwareTuner(ctx.Distro).Run()
+		},
+	})
+}
+
+// NICInfo is a network interface, its driver and adapter model
+type NICInfo struct {
+	Name   string `json:"name"`
+	Driver string `json:"driver"`
+	Model  string `json:"model,omitempty"`
+}
+
+// HardwareInfo is the virtual hardware inventory used by the inspector and reports
+type HardwareInfo struct {
+	Arch          string           `json:"arch"` // arm64 on ESXi-Arm
+	NICs          []NICInfo        `json:"nics"`
+	StorageDriver string           `json:"storage_driver"`
+	Passthrough   []PassthroughNIC `json:"passthrough_nics,omitempty"`
+	PCIDevices    []PCIDevice      `json:"pci_devices,omitempty"`
+	DMI           DMIInfo          `json:"dmi"`
+	ESXi          ESXiVersion      `json:"esxi"`
+	Advisories    []Advisory       `json:"esxi_advisories,omitempty"`
+	CPU           CPUTopology      `json:"cpu_topology"`
+	NUMA          NUMALayout       `json:"numa"`
+}
+
+// CollectHardware inspects NIC drivers and the storage controller
+func CollectHardware() HardwareInfo {
+	hw := HardwareInfo{Arch: runtime.GOARCH}
+	hw.PCIDevices, _ = ListPCIDevices(Sys.Root)
+	hw.DMI = ReadDMI(Sys.Root)
+	hw.DMI.Hints = append(hw.DMI.Hints, hardwareHints(hw.PCIDevices)...)
+	applyArmQuirks(&hw)
+
+	// Interfaces backed by a device, with their driver
+	if interfaces, err := ListInterfaces(Sys.Root); err == nil {
+		for _, iface := range interfaces {
+			hw.NICs = append(hw.NICs, NICInfo{Name: iface.Name, Driver: iface.Driver, Model: pciModelFor(hw.PCIDevices, iface.Name)})
+		}
+	}
+
+	// Drivers bound to the PCI storage controllers, else the loaded modules
+	hw.StorageDriver = bestStorageDriver(hw.PCIDevices)
+	if out, err := exec.Command("lsmod").Output(); err == nil && hw.StorageDriver == "" {
+		output := string(out)
+		for _, driver := range []string{"vmw_pvscsi", "nvme", "mptspi", "mptsas"} {
+			if strings.Contains(output, driver) {
+				hw.StorageDriver = driver
+				break
+			}
+		}
+	}
+
+	hw.Passthrough, _ = DetectPassthroughNICs(Sys.Root)
+	hw.CPU = ReadCPUTopology(Sys.Root)
+	hw.NUMA, _ = ReadNUMA(Sys.Root)
+
+	if strings.Contains(hw.DMI.Vendor, "VMware") {
+		hw.ESXi = DetectESXiVersion(hw.DMI)
+		hw.Advisories = ESXiAdvisories(hw.ESXi, hw, hasPrecisionClock())
+	}
+
+	return hw
+}
+
 // Run performs the hardware check
 func (ht *HardwareTuner) Run() error {
 	PrintStep("Virtual Hardware Inspector")
 
+	hw := CollectHardware()
+
+	// 0. Platform identity (SMBIOS)
+	PrintInfo("Checking Platform...")
+	printDMI(hw.DMI)
+	if len(hw.Advisories) > 0 {
+		printESXi(hw.ESXi, hw.Advisories)
+	}
+
+	// 0b. vCPU layout
+	PrintInfo("Checking CPU Topology...")
+	printCPUTopology(hw.CPU)
+	PrintInfo("Checking NUMA Topology...")
+	printNUMA(hw.NUMA)
+
 	// 1. Check Network Adapter Type
 	PrintInfo("Checking Network Adapter...")
-	// Get interface name
-	cmd := exec.Command("ip", "-o", "link", "show")
-	out, err := cmd.Output()
-	if err == nil {
-		lines := strings.Split(string(out), "\n")
-		foundVmxnet3 := false
-		for _, line := range lines {
-			if strings.Contains(line, "link/ether") {
-				parts := strings.Fields(line)
-				if len(parts) > 1 {
-					iface := strings.Trim(parts[1], ":")
-					// Check driver
-					driverCmd := exec.Command("ethtool", "-i", iface)
-					driverOut, _ := driverCmd.Output()
-					if strings.Contains(string(driverOut), "driver: vmxnet3") {
-						foundVmxnet3 = true
-						PrintSuccess("Interface %s is using vmxnet3 driver", iface)
-					} else if strings.Contains(string(driverOut), "driver: e1000") {
-						PrintWarning("Interface %s is using legacy e1000 driver (Upgrade to vmxnet3 recommended)", iface)
-					}
-				}
-			}
+	foundVmxnet3 := false
+	for _, nic := range hw.NICs {
+		if nic.Model != "" {
+			PrintInfo("Interface %s: %s", nic.Name, nic.Model)
 		}
-		if !foundVmxnet3 {
-			PrintInfo("No vmxnet3 adapters found (or ethtool missing)")
+		if nic.Driver == "vmxnet3" {
+			foundVmxnet3 = true
+			PrintSuccess("Interface %s is using vmxnet3 driver", nic.Name)
+		} else if strings.HasPrefix(nic.Driver, "e1000") {
+			PrintWarning("Interface %s is using legacy e1000 driver (Upgrade to vmxnet3 recommended)", nic.Name)
 		}
 	}
+	if !foundVmxnet3 {
+		PrintInfo("No vmxnet3 adapters found (or ethtool missing)")
+	}
+
+	// 1b. Check for SR-IOV / DirectPath NICs
+	if len(hw.Passthrough) > 0 {
+		PrintInfo("Checking SR-IOV / DirectPath adapters...")
+		ReportPassthroughNICs(hw.Passthrough)
+	}
 
 	// 2. Check SCSI Controller
 	PrintInfo("Checking SCSI Controller...")
-	// lspci is best, but might not be installed.
-	// Try installing pciutils if missing? No, read-only check shouldn't install stuff ideally.
-	// Let's try to detect via sysfs or dmesg
-	
-	// Check for vmw_pvscsi or nvme module
-	if out, err := exec.Command("lsmod").Output(); err == nil {
-		output := string(out)
-		if strings.Contains(output, "vmw_pvscsi") {
-			PrintSuccess("VMware Paravirtual SCSI (PVSCSI) driver loaded")
-		} else if strings.Contains(output, "nvme") {
-			PrintSuccess("NVMe Controller detected (High Performance)")
-		} else if strings.Contains(output, "mptspi") || strings.Contains(output, "mptsas") {
-			PrintInfo("Detected LSI Logic Controller (Standard)")
-			PrintInfo("Recommendation: Upgrade to VMware Paravirtual (PVSCSI) for better I/O performance")
-		} else {
-			// Check if it's built-in or just not used
-			PrintWarning("Optimal Storage Controller not found (PVSCSI/NVMe)")
+	for _, dev := range hw.PCIDevices {
+		if dev.BaseClass() == pciClassStorage {
+			PrintInfo("Controller %s: %s (driver: %s)", dev.Address, dev.Model, orNone(dev.Driver))
+		}
+	}
+	switch hw.StorageDriver {
+	case "vmw_pvscsi":
+		PrintSuccess("VMware Paravirtual SCSI (PVSCSI) driver loaded")
+	case "nvme":
+		PrintSuccess("NVMe Controller detected (High Performance)")
+	case "ahci":
+		PrintInfo("Detected SATA AHCI Controller")
+		PrintInfo("Recommendation: Use VMware Paravirtual (PVSCSI) or NVMe for data disks")
+	case "mptspi", "mptsas":
+		PrintInfo("Detected LSI Logic Controller (Standard)")
+		PrintInfo("Recommendation: Upgrade to %s for better I/O performance", fastControllers(hw.ESXi))
+	default:
+		if hw.IsArm() {
+			// ESXi-Arm has no LSI Logic or BusLogic to upgrade from: the
+			// disks sit on whatever controller the Fling offers
+			PrintInfo("No PVSCSI or NVMe driver in use: ESXi-Arm offers NVMe, PVSCSI and SATA AHCI controllers")
+			break
 		}
+		// Check if it's built-in or just not used
+		PrintWarning("Optimal Storage Controller not found (%s)", fastControllers(hw.ESXi))
 	}
 
 	// 3. Check 3D Acceleration (often unnecessary on servers)
@@ -78,3 +169,20 @@ func (ht *HardwareTuner) Run() error {
 
 	return nil
 }
+
+// fastControllers names the recommended controllers for the host version
+// (no virtual NVMe before ESXi 6.5)
+func fastControllers(v ESXiVersion) string {
+	if v.Known() && !v.AtLeast(6, 5) {
+		return "VMware Paravirtual (PVSCSI)"
+	}
+	return "VMware Paravirtual (PVSCSI) or NVMe"
+}
+
+// orNone returns "none" for an empty value
+func orNone(value string) string {
+	if value == "" {
+		return "none"
+	}
+	return value
+}
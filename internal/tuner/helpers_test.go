@@ -0,0 +1,35 @@
+package tuner
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestMain keeps the action log of the tests out of /var/log and the system
+// journal
+func TestMain(m *testing.M) {
+	dir, err := os.MkdirTemp("", "vmware-tuner-test")
+	if err != nil {
+		panic(err)
+	}
+	ActionLogPath = filepath.Join(dir, "vmware-tuner.log")
+	JournalEnabled = false
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+// writeFiles creates empty or filled files in a fixture tree
+func writeFiles(t *testing.T, root string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
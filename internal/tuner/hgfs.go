@@ -0,0 +1,141 @@
+package tuner
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// hgfsAttrTimeout is how long the guest caches the attributes and names of
+// shared files, in seconds: builds and git status stat thousands of files,
+// each a round trip to the host otherwise. Edits made on the host show up in
+// the guest after up to this delay.
+const hgfsAttrTimeout = "5"
+
+// HGFSTuner mounts the Workstation/Fusion shared folders at boot with
+// attribute caching (--with-hgfs, or the developer profile)
+type HGFSTuner struct {
+	DryRun    bool
+	UnitPath  string
+	MountPath string
+	FSRoot    string // "" for /
+}
+
+// NewHGFSTuner creates a new shared folders tuner
+func NewHGFSTuner(dryRun bool) *HGFSTuner {
+	return &HGFSTuner{
+		DryRun:    dryRun,
+		UnitPath:  "/etc/systemd/system/mnt-hgfs.mount",
+		MountPath: "/mnt/hgfs",
+	}
+}
+
+func init() {
+	Register(Module{
+		Name:        "hgfs",
+		Description: "Shared folders (HGFS) mounted at boot with attribute caching",
+		Category:    CategoryTuning,
+		Pipeline:    14,
+		RequireRoot: true,
+		Flag:        &ModuleFlag{Name: "with-hgfs", Usage: "Mount the Workstation/Fusion shared folders at boot with attribute caching (on with the developer profile)"},
+		Apply: func(ctx *ModuleContext) error {
+			return NewHGFSTuner(ctx.DryRun).Apply(ctx.Backup)
+		},
+		Verify: func(ctx *ModuleContext) error {
+			return NewHGFSTuner(false).Verify()
+		},
+	})
+}
+
+// Unit returns the mount unit of the shared folders. nofail: a VM whose
+// shared folders are turned off still boots.
+func (ht *HGFSTuner) Unit() string {
+	return fmt.Sprintf(`# Generated by vmware-tuner: VMware Workstation/Fusion shared folders
+[Unit]
+Description=VMware shared folders (HGFS)
+ConditionVirtualization=vmware
+After=open-vm-tools.service vmtoolsd.service
+
+[Mount]
+What=.host:/
+Where=%s
+Type=fuse.vmhgfs-fuse
+Options=allow_other,nofail,attr_timeout=%s,entry_timeout=%s
+
+[Install]
+WantedBy=multi-user.target
+`, ht.MountPath, hgfsAttrTimeout, hgfsAttrTimeout)
+}
+
+// Apply writes and starts the mount unit
+func (ht *HGFSTuner) Apply(backup *BackupManager) error {
+	PrintStep("Shared Folders (HGFS)")
+
+	if guest := DetectDesktopGuest(ht.FSRoot); !guest.Detected() {
+		PrintInfo("Not a Workstation/Fusion guest: no shared folders, nothing to do")
+		return nil
+	}
+	if _, err := exec.LookPath("vmhgfs-fuse"); err != nil {
+		PrintWarning("vmhgfs-fuse not found: install open-vm-tools-desktop (Debian/Ubuntu) or open-vm-tools (RHEL, SUSE)")
+		return nil
+	}
+	if SkipExcluded("hgfs", ExcludeMount, ht.MountPath) {
+		return nil
+	}
+
+	unit := ht.Unit()
+	if current, err := os.ReadFile(ht.UnitPath); err == nil && string(current) == unit {
+		PrintSuccess("%s already configured", ht.UnitPath)
+		return nil
+	}
+	if ht.DryRun {
+		PrintInfo("Would create: %s", ht.UnitPath)
+		fmt.Print(unit)
+		PrintInfo("Would run: systemctl enable --now mnt-hgfs.mount")
+		return nil
+	}
+
+	if err := backup.BackupFile(ht.UnitPath); err != nil {
+		return fmt.Errorf("failed to backup %s: %w", ht.UnitPath, err)
+	}
+	if err := os.MkdirAll(ht.MountPath, 0755); err != nil {
+		return fmt.Errorf("failed to create %s: %w", ht.MountPath, err)
+	}
+	ExplainEdit("mount the shared folders at boot and cache file attributes for "+hgfsAttrTimeout+"s (builds stat every file)", ht.UnitPath)
+	if err := os.WriteFile(ht.UnitPath, []byte(unit), 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", ht.UnitPath, err)
+	}
+	PrintSuccess("Created %s", ht.UnitPath)
+
+	ExplainCommand("make systemd read the new unit", "systemctl", "daemon-reload")
+	exec.Command("systemctl", "daemon-reload").Run()
+
+	// Mounted by hand or through fstab: the unit takes over at the next boot
+	if hgfsMounted(ht.FSRoot) {
+		ExplainCommand("mount the shared folders at boot", "systemctl", "enable", "mnt-hgfs.mount")
+		if out, err := exec.Command("systemctl", "enable", "mnt-hgfs.mount").CombinedOutput(); err != nil {
+			return fmt.Errorf("failed to enable mnt-hgfs.mount: %v: %s", err, strings.TrimSpace(string(out)))
+		}
+		PrintInfo("Shared folders already mounted: the cached mount is used from the next boot")
+		return nil
+	}
+	ExplainCommand("mount the shared folders now and at boot", "systemctl", "enable", "--now", "mnt-hgfs.mount")
+	if out, err := exec.Command("systemctl", "enable", "--now", "mnt-hgfs.mount").CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to enable mnt-hgfs.mount: %v: %s", err, strings.TrimSpace(string(out)))
+	}
+	PrintSuccess("Shared folders mounted on %s", ht.MountPath)
+	return nil
+}
+
+// Verify checks that the shared folders are mounted by the unit
+func (ht *HGFSTuner) Verify() error {
+	if !FileExists(ht.UnitPath) {
+		return fmt.Errorf("%w: shared folders not configured (--with-hgfs)", ErrVerifySkipped)
+	}
+	if !hgfsMounted(ht.FSRoot) {
+		return fmt.Errorf("shared folders are not mounted on %s: check that they are enabled in the VM settings (systemctl status mnt-hgfs.mount)", ht.MountPath)
+	}
+	PrintSuccess("Shared folders mounted on %s", ht.MountPath)
+	return nil
+}
@@ -0,0 +1,77 @@
+package tuner
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// hotplugSCSITimeout is the SCSI timeout of hot-added disks (seconds, VMware
+// recommendation for SAN/vMotion pauses)
+const hotplugSCSITimeout = 180
+
+// GetHotplugRule returns the udev rule running the helper for every new disk
+func (st *SchedulerTuner) GetHotplugRule() string {
+	return `# Tune disks hot-added from vSphere at attach time
+# Generated by vmware-tuner
+ACTION=="add", SUBSYSTEM=="block", ENV{DEVTYPE}=="disk", KERNEL=="sd*|nvme*|vd*", RUN+="` + st.HotplugHelperPath + ` %k"
+`
+}
+
+// GetHotplugHelper returns the helper script: the static rules cannot set the
+// SCSI timeout of the parent device
+func (st *SchedulerTuner) GetHotplugHelper() string {
+	qs := Tuning.QueueSettings()
+	return fmt.Sprintf(`#!/bin/sh
+# Tune a newly attached disk - Generated by vmware-tuner
+dev="$1"
+sys="/sys/block/$dev"
+[ -d "$sys/queue" ] || exit 0
+
+case "$dev" in
+	nvme*) scheds="none" ;;
+	*) scheds="%s" ;;
+esac
+for sched in $scheds; do
+	if grep -qw "$sched" "$sys/queue/scheduler"; then
+		echo "$sched" > "$sys/queue/scheduler"
+		break
+	fi
+done
+echo %d > "$sys/queue/nr_requests" 2>/dev/null
+echo %d > "$sys/queue/read_ahead_kb" 2>/dev/null
+echo %d > "$sys/queue/rq_affinity" 2>/dev/null
+[ -w "$sys/device/timeout" ] && echo %d > "$sys/device/timeout"
+
+logger -t vmware-tuner "hot-added disk $dev tuned"
+exit 0
+`, strings.Join(schedulerChoices(Tuning.ActiveProfile().Scheduler), " "), qs.NrRequests, qs.ReadAheadKB, qs.RqAffinity, hotplugSCSITimeout)
+}
+
+// installHotplug writes the helper and its udev rule (rollback removes both)
+func (st *SchedulerTuner) installHotplug(backup *BackupManager) error {
+	files := []struct {
+		path    string
+		content string
+		mode    os.FileMode
+	}{
+		{st.HotplugHelperPath, st.GetHotplugHelper(), 0755},
+		{st.HotplugRulePath, st.GetHotplugRule(), 0644},
+	}
+
+	for _, f := range files {
+		if err := os.MkdirAll(filepath.Dir(f.path), 0755); err != nil {
+			return fmt.Errorf("failed to create %s: %w", filepath.Dir(f.path), err)
+		}
+		if err := backup.BackupFile(f.path); err != nil {
+			return fmt.Errorf("failed to backup %s: %w", f.path, err)
+		}
+		if err := os.WriteFile(f.path, []byte(f.content), f.mode); err != nil {
+			return fmt.Errorf("failed to write %s: %w", f.path, err)
+		}
+	}
+
+	PrintSuccess("Hot-added disks will be tuned at attach time (%s)", st.HotplugRulePath)
+	return nil
+}
@@ -0,0 +1,45 @@
+package tuner
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestHotplugRule(t *testing.T) {
+	st := NewSchedulerTuner(true)
+	rule := st.GetHotplugRule()
+	want := `ACTION=="add", SUBSYSTEM=="block", ENV{DEVTYPE}=="disk", KERNEL=="sd*|nvme*|vd*", RUN+="/usr/local/sbin/vmware-tuner-disk-hotplug %k"`
+	if !strings.Contains(rule, want+"\n") {
+		t.Errorf("rule lacks %s:\n%s", want, rule)
+	}
+	if strings.Count(rule, "RUN+=") != 1 {
+		t.Errorf("rule should run the helper once:\n%s", rule)
+	}
+
+	st.HotplugHelperPath = "/opt/tuner/hotplug"
+	if rule := st.GetHotplugRule(); !strings.Contains(rule, `RUN+="/opt/tuner/hotplug %k"`) {
+		t.Errorf("rule ignores the helper path:\n%s", rule)
+	}
+}
+
+func TestHotplugHelper(t *testing.T) {
+	defer func(saved TuningConfig) { Tuning = saved }(Tuning)
+	Tuning = TuningConfig{Profile: "database", Queue: QueueSettings{NrRequests: 64}}
+
+	helper := NewSchedulerTuner(true).GetHotplugHelper()
+	if !strings.HasPrefix(helper, "#!/bin/sh\n") {
+		t.Errorf("helper is not a shell script:\n%s", helper)
+	}
+	for _, want := range []string{
+		`nvme*) scheds="none" ;;`,
+		`*) scheds="mq-deadline deadline" ;;`,
+		`echo 64 > "$sys/queue/nr_requests"`,
+		`echo 1024 > "$sys/queue/read_ahead_kb"`,
+		`echo 1 > "$sys/queue/rq_affinity"`,
+		`[ -w "$sys/device/timeout" ] && echo 180 > "$sys/device/timeout"`,
+	} {
+		if !strings.Contains(helper, want) {
+			t.Errorf("helper lacks %s:\n%s", want, helper)
+		}
+	}
+}
@@ -0,0 +1,324 @@
+package tuner
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Static hugepage sizes (tuning.hugepages.size)
+const (
+	HugePage2M = "2M"
+	HugePage1G = "1G"
+)
+
+// hugePageSizesKB are the sizes in kB, as named in /sys/kernel/mm/hugepages
+var hugePageSizesKB = map[string]int64{HugePage2M: 2048, HugePage1G: 1048576}
+
+// hugePagesMaxPercent caps the reserved memory: hugepages are taken from the
+// page cache and every other process, which still need room
+const hugePagesMaxPercent = 75
+
+// HugePagesConfig sizes the static hugepages (tuning.hugepages)
+type HugePagesConfig struct {
+	Size     string // 2M (default) or 1G
+	Count    int    // pages, or
+	MemoryMB int    // memory to reserve, rounded up to whole pages
+}
+
+// decode reads the hugepages: section
+func (hc *HugePagesConfig) decode(fields map[string]interface{}) error {
+	var err error
+	if hc.Size, err = yamlString(fields["size"]); err != nil {
+		return fmt.Errorf("size: %w", err)
+	}
+	if _, ok := hugePageSizesKB[hc.Size]; hc.Size != "" && !ok {
+		return fmt.Errorf("size: must be %s or %s, got %q", HugePage2M, HugePage1G, hc.Size)
+	}
+	if hc.Count, err = yamlInt(fields["count"]); err != nil {
+		return fmt.Errorf("count: %w", err)
+	}
+	if hc.MemoryMB, err = yamlInt(fields["memory_mb"]); err != nil {
+		return fmt.Errorf("memory_mb: %w", err)
+	}
+	switch {
+	case hc.Count < 0 || hc.MemoryMB < 0:
+		return fmt.Errorf("count, memory_mb: must be positive")
+	case hc.Count > 0 && hc.MemoryMB > 0:
+		return fmt.Errorf("count, memory_mb: set one of them")
+	case hc.Count == 0 && hc.MemoryMB == 0:
+		return fmt.Errorf("count, memory_mb: one of them is required")
+	}
+	return nil
+}
+
+// Configured reports whether the config file sizes the hugepages
+func (hc HugePagesConfig) Configured() bool {
+	return hc.Count > 0 || hc.MemoryMB > 0
+}
+
+// PageSize returns the page size, 2M by default
+func (hc HugePagesConfig) PageSize() string {
+	if hc.Size == "" {
+		return HugePage2M
+	}
+	return hc.Size
+}
+
+// Pages returns the number of pages to reserve
+func (hc HugePagesConfig) Pages() int {
+	if hc.Count > 0 {
+		return hc.Count
+	}
+	pageMB := int(hugePageSizesKB[hc.PageSize()] >> 10)
+	return (hc.MemoryMB + pageMB - 1) / pageMB
+}
+
+// MemoryMBReserved returns the memory the pages take
+func (hc HugePagesConfig) MemoryMBReserved() int64 {
+	return int64(hc.Pages()) * hugePageSizesKB[hc.PageSize()] >> 10
+}
+
+// HugePagesStatus is the pool of one hugepage size
+type HugePagesStatus struct {
+	Size      string
+	Total     int // allocated pages (nr_hugepages)
+	Free      int
+	Reserved  int // promised to a mapping, not faulted in yet
+	Supported bool
+}
+
+// HugePagesTuner reserves static hugepages for databases and JVMs
+// (--with-hugepages, sized by tuning.hugepages): at boot with the kernel
+// command line, where memory is not fragmented yet, and in sysctl.d
+type HugePagesTuner struct {
+	DryRun     bool
+	Distro     *DistroManager
+	Config     HugePagesConfig
+	SysctlPath string
+	FSRoot     string // "" for /
+}
+
+// NewHugePagesTuner creates a new hugepages tuner for the sizing of the config file
+func NewHugePagesTuner(dryRun bool, distro *DistroManager) *HugePagesTuner {
+	return &HugePagesTuner{
+		DryRun:     dryRun,
+		Distro:     distro,
+		Config:     Tuning.HugePages,
+		SysctlPath: "/etc/sysctl.d/99-vmware-tuner-hugepages.conf",
+	}
+}
+
+func init() {
+	Register(Module{
+		Name:        "hugepages",
+		Description: "Static hugepages for databases and JVMs (tuning.hugepages, reboot)",
+		Category:    CategoryTuning,
+		Pipeline:    15,
+		RequireRoot: true,
+		Distros:     packagedDistros,
+		Flag:        &ModuleFlag{Name: "with-hugepages", Usage: "Reserve static hugepages sized by tuning.hugepages (vm.nr_hugepages and boot parameters, reboot)"},
+		Apply: func(ctx *ModuleContext) error {
+			hugepages := NewHugePagesTuner(ctx.DryRun, ctx.Distro)
+			if err := hugepages.Apply(ctx.Backup); err != nil {
+				return err
+			}
+			if hugepages.RebootPending() {
+				ctx.RebootRequired = true
+			}
+			return nil
+		},
+		Show: func(ctx *ModuleContext) error {
+			return NewHugePagesTuner(false, ctx.Distro).ShowCurrent()
+		},
+		Verify: func(ctx *ModuleContext) error {
+			return NewHugePagesTuner(false, ctx.Distro).Verify()
+		},
+	})
+}
+
+// BootParams returns the kernel parameters reserving the pages at boot
+func (ht *HugePagesTuner) BootParams() []string {
+	size := ht.Config.PageSize()
+	return []string{
+		"default_hugepagesz=" + size,
+		"hugepagesz=" + size,
+		"hugepages=" + strconv.Itoa(ht.Config.Pages()),
+	}
+}
+
+// SysctlContent returns the sysctl.d file keeping the pool at its size
+func (ht *HugePagesTuner) SysctlContent() string {
+	return fmt.Sprintf(`# Generated by vmware-tuner: static hugepages (tuning.hugepages, %s pages)
+# Reserved at boot by hugepages= on the kernel command line; kept here so
+# sysctl --system does not shrink the pool
+vm.nr_hugepages = %d
+`, ht.Config.PageSize(), ht.Config.Pages())
+}
+
+// sys reads the kernel interfaces below FSRoot
+func (ht *HugePagesTuner) sys() SysFS {
+	return SysFS{Root: ht.FSRoot}
+}
+
+// Status reads the pool of a page size
+func (ht *HugePagesTuner) Status(size string) HugePagesStatus {
+	st := HugePagesStatus{Size: size}
+	dir := filepath.Join("/sys/kernel/mm/hugepages", fmt.Sprintf("hugepages-%dkB", hugePageSizesKB[size]))
+	if !ht.sys().Exists(dir) {
+		return st
+	}
+	st.Supported = true
+	read := func(name string) int {
+		n, _ := ht.sys().ReadInt(dir, name)
+		return n
+	}
+	st.Total, st.Free, st.Reserved = read("nr_hugepages"), read("free_hugepages"), read("resv_hugepages")
+	return st
+}
+
+// RebootPending reports whether the boot parameters are configured but the
+// running kernel did not get them
+func (ht *HugePagesTuner) RebootPending() bool {
+	if !ht.Config.Configured() || ht.DryRun {
+		return false
+	}
+	cmdline, err := ht.sys().Cmdline()
+	if err != nil {
+		return false
+	}
+	cmdline = " " + cmdline + " "
+	for _, p := range ht.BootParams() {
+		if !strings.Contains(cmdline, " "+p+" ") {
+			return true
+		}
+	}
+	return false
+}
+
+// check refuses a sizing the VM cannot hold
+func (ht *HugePagesTuner) check() error {
+	if !ht.Config.Configured() {
+		return fmt.Errorf("%w: no hugepages sizing (set tuning.hugepages.count or memory_mb in %s)", ErrValidationFailed, DefaultConfigPath)
+	}
+	size := ht.Config.PageSize()
+	if !ht.Status(size).Supported {
+		hint := ""
+		if size == HugePage1G {
+			hint = " (the vCPU lacks pdpe1gb: check the EVC mode of the cluster)"
+		}
+		return fmt.Errorf("%w: %s hugepages are not supported by this kernel or vCPU%s", ErrValidationFailed, size, hint)
+	}
+	total := ht.sys().MemInfoMB()["MemTotal"]
+	if reserved := ht.Config.MemoryMBReserved(); total > 0 && reserved > total*hugePagesMaxPercent/100 {
+		return fmt.Errorf("%w: %d x %s hugepages take %s of %s of memory (%d%% at most)", ErrValidationFailed,
+			ht.Config.Pages(), size, FormatMiB(reserved), FormatMiB(total), hugePagesMaxPercent)
+	}
+	return nil
+}
+
+// Apply writes the sysctl file, allocates what it can now and adds the boot
+// parameters
+func (ht *HugePagesTuner) Apply(backup *BackupManager) error {
+	PrintStep("Static Hugepages")
+	if err := ht.check(); err != nil {
+		return err
+	}
+	if SkipExcluded("hugepages", ExcludeSysctl, "vm.nr_hugepages") || Tuning.SysctlExcluded("vm.nr_hugepages") {
+		PrintInfo("vm.nr_hugepages is managed elsewhere: hugepages left unchanged")
+		return nil
+	}
+
+	size, pages := ht.Config.PageSize(), ht.Config.Pages()
+	total := ht.sys().MemInfoMB()["MemTotal"]
+	PrintInfo("Requested: %d x %s pages = %s of %s", pages, size, FormatMiB(ht.Config.MemoryMBReserved()), FormatMiB(total))
+	PrintInfo("The memory is locked for hugepage users (shmget SHM_HUGETLB, mmap MAP_HUGETLB, hugetlbfs): set the database or JVM to use it")
+
+	content := ht.SysctlContent()
+	if current, err := os.ReadFile(ht.SysctlPath); err == nil && string(current) == content {
+		PrintSuccess("%s already configured", ht.SysctlPath)
+	} else if ht.DryRun {
+		PrintInfo("Would create: %s", ht.SysctlPath)
+		fmt.Print(content)
+	} else {
+		if err := backup.BackupFile(ht.SysctlPath); err != nil {
+			return fmt.Errorf("failed to backup %s: %w", ht.SysctlPath, err)
+		}
+		if err := os.MkdirAll(filepath.Dir(ht.SysctlPath), 0755); err != nil {
+			return fmt.Errorf("failed to create %s: %w", filepath.Dir(ht.SysctlPath), err)
+		}
+		ExplainEdit("keep the hugepage pool at its size when the sysctl files are loaded", ht.SysctlPath)
+		if err := os.WriteFile(ht.SysctlPath, []byte(content), 0644); err != nil {
+			return fmt.Errorf("failed to write %s: %w", ht.SysctlPath, err)
+		}
+		PrintSuccess("Created %s", ht.SysctlPath)
+		ht.allocateNow()
+	}
+
+	return NewGrubTuner(ht.DryRun, ht.Distro).AddParams(backup, ht.BootParams())
+}
+
+// allocateNow grows the pool of the running kernel. 1 GB pages and a
+// fragmented memory may not be found: the boot parameters reserve them.
+func (ht *HugePagesTuner) allocateNow() {
+	size, pages := ht.Config.PageSize(), ht.Config.Pages()
+	if current := ht.Status(size); current.Total >= pages {
+		return
+	}
+	path := fmt.Sprintf("/sys/kernel/mm/hugepages/hugepages-%dkB/nr_hugepages", hugePageSizesKB[size])
+	ExplainEdit("allocate the hugepages now, without waiting for the reboot", path)
+	if err := os.WriteFile(ht.sys().Path(path), []byte(strconv.Itoa(pages)), 0644); err != nil {
+		PrintWarning("Failed to allocate the hugepages now: %v", err)
+		return
+	}
+	if got := ht.Status(size).Total; got < pages {
+		PrintWarning("Allocated %d of %d %s pages now (memory fragmented): the rest is reserved at boot", got, pages, size)
+	} else {
+		PrintSuccess("Allocated %d %s pages", got, size)
+	}
+}
+
+// Verify checks that the pages are allocated, once they are configured
+func (ht *HugePagesTuner) Verify() error {
+	if !ht.Config.Configured() {
+		return fmt.Errorf("%w: hugepages not configured (tuning.hugepages)", ErrVerifySkipped)
+	}
+	size, pages := ht.Config.PageSize(), ht.Config.Pages()
+	st := ht.Status(size)
+	if !st.Supported {
+		return fmt.Errorf("%s hugepages are not supported by this kernel or vCPU", size)
+	}
+	if st.Total < pages {
+		reason := "memory fragmented or taken"
+		if ht.RebootPending() {
+			reason = "reboot pending"
+		}
+		return fmt.Errorf("%d of %d %s hugepages allocated (%s)", st.Total, pages, size, reason)
+	}
+	PrintSuccess("Hugepages: %d of %d %s pages allocated, %d free, %d reserved", st.Total, pages, size, st.Free, st.Reserved)
+	return nil
+}
+
+// ShowCurrent prints the pools and the requested size
+func (ht *HugePagesTuner) ShowCurrent() error {
+	PrintStep("Static Hugepages")
+	fmt.Printf("  %-6s %10s %10s %10s %10s\n", "Size", "Requested", "Allocated", "Free", "Reserved")
+	for _, size := range []string{HugePage2M, HugePage1G} {
+		st := ht.Status(size)
+		if !st.Supported {
+			fmt.Printf("  %-6s %10s\n", size, "not supported")
+			continue
+		}
+		requested := "-"
+		if ht.Config.Configured() && ht.Config.PageSize() == size {
+			requested = strconv.Itoa(ht.Config.Pages())
+		}
+		fmt.Printf("  %-6s %10s %10d %10d %10d\n", size, requested, st.Total, st.Free, st.Reserved)
+	}
+	if !ht.Config.Configured() {
+		PrintInfo("No hugepages requested (tuning.hugepages in %s)", DefaultConfigPath)
+	}
+	return nil
+}
@@ -0,0 +1,111 @@
+package tuner
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestLoadConfig_HugePages(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte("tuning:\n  hugepages:\n    memory_mb: 8191\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+	hc := cfg.Tuning.HugePages
+	if hc.PageSize() != HugePage2M || hc.Pages() != 4096 || hc.MemoryMBReserved() != 8192 {
+		t.Errorf("hugepages = %s x %d (%d MB), want 2M x 4096 (rounded up)", hc.PageSize(), hc.Pages(), hc.MemoryMBReserved())
+	}
+
+	for _, bad := range []string{
+		"tuning:\n  hugepages:\n    size: 4M\n    count: 8\n",
+		"tuning:\n  hugepages:\n    count: 8\n    memory_mb: 1024\n",
+		"tuning:\n  hugepages:\n    size: 1G\n",
+		"tuning:\n  hugepages:\n    count: -1\n",
+	} {
+		if err := os.WriteFile(path, []byte(bad), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := LoadConfig(path); err == nil || !strings.Contains(err.Error(), "hugepages.") {
+			t.Errorf("%q: err = %v, want a hugepages error", bad, err)
+		}
+	}
+}
+
+// hugePagesFixture builds the hugepage pools, /proc/meminfo and /proc/cmdline
+func hugePagesFixture(t *testing.T, memTotalMB int, cmdline string, pools map[string]string) string {
+	root := t.TempDir()
+	files := map[string]string{
+		"proc/meminfo": "MemTotal:       " + strconv.Itoa(memTotalMB*1024) + " kB\n",
+		"proc/cmdline": cmdline + "\n",
+	}
+	for dir, total := range pools {
+		files["sys/kernel/mm/hugepages/"+dir+"/nr_hugepages"] = total + "\n"
+		files["sys/kernel/mm/hugepages/"+dir+"/free_hugepages"] = total + "\n"
+		files["sys/kernel/mm/hugepages/"+dir+"/resv_hugepages"] = "0\n"
+	}
+	for name, content := range files {
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return root
+}
+
+func TestHugePagesCheck(t *testing.T) {
+	root := hugePagesFixture(t, 16384, "ro quiet", map[string]string{"hugepages-2048kB": "0"})
+	ht := &HugePagesTuner{FSRoot: root}
+	if err := ht.check(); !errors.Is(err, ErrValidationFailed) {
+		t.Errorf("no sizing: %v, want a validation error", err)
+	}
+
+	ht.Config = HugePagesConfig{Count: 4096}
+	if err := ht.check(); err != nil {
+		t.Errorf("8 GB of 16 GB: %v", err)
+	}
+	ht.Config = HugePagesConfig{MemoryMB: 14336}
+	if err := ht.check(); !errors.Is(err, ErrValidationFailed) || !strings.Contains(err.Error(), "75%") {
+		t.Errorf("14 GB of 16 GB: %v, want refused", err)
+	}
+	ht.Config = HugePagesConfig{Size: HugePage1G, Count: 4}
+	if err := ht.check(); !errors.Is(err, ErrValidationFailed) || !strings.Contains(err.Error(), "pdpe1gb") {
+		t.Errorf("1G pages without the pool: %v, want refused", err)
+	}
+}
+
+func TestHugePagesVerify(t *testing.T) {
+	ht := &HugePagesTuner{FSRoot: hugePagesFixture(t, 16384, "ro quiet", map[string]string{"hugepages-2048kB": "1000"})}
+	if err := ht.Verify(); !errors.Is(err, ErrVerifySkipped) {
+		t.Errorf("not configured: %v, want skipped", err)
+	}
+
+	ht.Config = HugePagesConfig{Count: 2048}
+	if got := strings.Join(ht.BootParams(), " "); got != "default_hugepagesz=2M hugepagesz=2M hugepages=2048" {
+		t.Errorf("boot parameters = %s", got)
+	}
+	if !ht.RebootPending() {
+		t.Error("boot parameters missing from /proc/cmdline: reboot pending")
+	}
+	err := ht.Verify()
+	if err == nil || !strings.Contains(err.Error(), "1000 of 2048 2M hugepages allocated (reboot pending)") {
+		t.Errorf("partial pool: %v", err)
+	}
+
+	ht.FSRoot = hugePagesFixture(t, 16384, "ro default_hugepagesz=2M hugepagesz=2M hugepages=2048 quiet", map[string]string{"hugepages-2048kB": "2048"})
+	if ht.RebootPending() {
+		t.Error("boot parameters in effect: no reboot pending")
+	}
+	if err := ht.Verify(); err != nil {
+		t.Errorf("full pool: %v", err)
+	}
+}
@@ -15,54 +15,110 @@ func NewInfoTuner() *InfoTuner {
 	return &InfoTuner{}
 }
 
-// Run displays the info
-func (it *InfoTuner) Run() error {
-	PrintStep("System Information")
+func init() {
+	Register(Module{
+		Name:        "info",
+		Label:       "System Info",
+		Description: "Show the system and VM information",
+		Category:    CategoryDiagnostics,
+		Menu:        9,
+		ReadOnly:    true,
+		Run: func(ctx *ModuleContext) error {
+			return NewInfoTuner().Run()
+		},
+	})
+}
+
+// SystemInfo is the system summary shown by the info module and reports
+type SystemInfo struct {
+	Hostname     string `json:"hostname"`
+	OS           string `json:"os"`
+	Kernel       string `json:"kernel"`
+	VCPUs        string `json:"vcpus"`
+	MemoryTotal  string `json:"memory_total"`
+	MemoryUsed   string `json:"memory_used"`
+	IPAddress    string `json:"ip_address"`
+	Environment  string `json:"environment"`
+	ToolsRunning bool   `json:"vmware_tools_running"`
+}
+
+// CollectSystemInfo gathers the system summary
+func CollectSystemInfo() SystemInfo {
+	info := SystemInfo{OS: "Unknown"}
+
+	info.Hostname, _ = os.Hostname()
 
 	// 1. OS Info
-	osInfo := "Unknown"
 	if data, err := os.ReadFile("/etc/os-release"); err == nil {
 		lines := strings.Split(string(data), "\n")
 		for _, line := range lines {
 			if strings.HasPrefix(line, "PRETTY_NAME=") {
-				osInfo = strings.Trim(line[12:], "\"")
+				info.OS = strings.Trim(line[12:], "\"")
 				break
 			}
 		}
 	}
-	fmt.Printf("  %-20s: %s\n", "OS", osInfo)
 
 	// 2. Kernel
 	if out, err := exec.Command("uname", "-r").Output(); err == nil {
-		fmt.Printf("  %-20s: %s", "Kernel", string(out))
+		info.Kernel = strings.TrimSpace(string(out))
 	}
 
 	// 3. CPU
 	// grep -c processor /proc/cpuinfo
 	if out, err := exec.Command("bash", "-c", "grep -c processor /proc/cpuinfo").Output(); err == nil {
-		fmt.Printf("  %-20s: %s", "vCPUs", string(out))
+		info.VCPUs = strings.TrimSpace(string(out))
 	}
 
 	// 4. Memory
-	// free -h | grep Mem | awk '{print $2}'
-	if out, err := exec.Command("bash", "-c", "free -h | grep Mem").Output(); err == nil {
-		parts := strings.Fields(string(out))
-		if len(parts) >= 3 {
-			fmt.Printf("  %-20s: %s (Used: %s)\n", "Memory", parts[1], parts[2])
-		}
+	if total, used, err := MemoryUsage(); err == nil {
+		info.MemoryTotal = FormatMiB(total)
+		info.MemoryUsed = FormatMiB(used)
 	}
 
 	// 5. IP Address
 	// hostname -I | awk '{print $1}'
 	if out, err := exec.Command("hostname", "-I").Output(); err == nil {
 		ips := strings.TrimSpace(string(out))
-		firstIp := strings.Split(ips, " ")[0]
-		fmt.Printf("  %-20s: %s\n", "IP Address", firstIp)
+		info.IPAddress = strings.Split(ips, " ")[0]
+	}
+
+	// 6. Environment
+	if production, source := IsProduction(); production {
+		info.Environment = fmt.Sprintf("Production (%s)", source)
+	} else {
+		info.Environment = "Standard"
+	}
+
+	// 7. VM Tools Status
+	info.ToolsRunning = exec.Command("systemctl", "is-active", "vmtoolsd").Run() == nil
+
+	return info
+}
+
+// Run displays the info
+func (it *InfoTuner) Run() error {
+	PrintStep("System Information")
+
+	info := CollectSystemInfo()
+
+	fmt.Printf("  %-20s: %s\n", "OS", info.OS)
+	if info.Kernel != "" {
+		fmt.Printf("  %-20s: %s\n", "Kernel", info.Kernel)
+	}
+	if info.VCPUs != "" {
+		fmt.Printf("  %-20s: %s\n", "vCPUs", info.VCPUs)
+	}
+	if info.MemoryTotal != "" {
+		fmt.Printf("  %-20s: %s (Used: %s)\n", "Memory", info.MemoryTotal, info.MemoryUsed)
+	}
+	if info.IPAddress != "" {
+		fmt.Printf("  %-20s: %s\n", "IP Address", info.IPAddress)
 	}
+	fmt.Printf("  %-20s: %s\n", "Environment", info.Environment)
 
-	// 6. VM Tools Status
 	fmt.Printf("  %-20s: ", "VMware Tools")
-	if err := exec.Command("systemctl", "is-active", "vmtoolsd").Run(); err == nil {
+	if info.ToolsRunning {
 		PrintSuccess("Running")
 	} else {
 		PrintWarning("Not Running")
@@ -0,0 +1,197 @@
+package tuner
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+)
+
+// TuningState is the tuning in effect on the VM, in an inventory: what drifts
+// between a template and its clones
+type TuningState struct {
+	Profile    string            `json:"profile,omitempty"` // applied profile
+	BootParams map[string]string `json:"boot_params"`       // kernel command line, "(set)" for flags
+	THP        string            `json:"transparent_hugepage,omitempty"`
+	Sysctl     map[string]string `json:"sysctl"`
+	Schedulers map[string]string `json:"schedulers,omitempty"` // disk: scheduler
+}
+
+// CollectTuningState reads the runtime values of the keys the tool manages.
+// The key set does not depend on the selected profile, so two VMs tuned
+// differently compare on the same keys.
+func CollectTuningState() TuningState {
+	state := TuningState{
+		Profile: AppliedProfile(NewSysctlTuner(false).ConfigPath),
+		THP:     currentTHP(),
+		Sysctl:  make(map[string]string),
+	}
+	if cmdline, err := Sys.Cmdline(); err == nil {
+		state.BootParams = parseBootParams(cmdline)
+	}
+
+	keys := parseSysctlFile(defaultSysctlConfig())
+	for _, p := range Profiles {
+		for key := range p.Sysctl {
+			keys[key] = ""
+		}
+	}
+	for key := range Tuning.Sysctl {
+		keys[key] = ""
+	}
+	for key := range keys {
+		if value, err := readSysctl(key); err == nil {
+			state.Sysctl[key] = value
+		}
+	}
+
+	if devices, err := ReadDeviceSchedulers(Sys.Root); err == nil && len(devices) > 0 {
+		state.Schedulers = make(map[string]string)
+		for _, d := range devices {
+			state.Schedulers[d.Device] = d.Current
+		}
+	}
+	return state
+}
+
+// parseBootParams splits a kernel command line into parameters, so a diff
+// names the parameter that differs. The init arguments (after --) are left out.
+func parseBootParams(cmdline string) map[string]string {
+	params := make(map[string]string)
+	for _, field := range strings.Fields(cmdline) {
+		if field == "--" {
+			break
+		}
+		key, value, ok := strings.Cut(field, "=")
+		if !ok {
+			value = "(set)"
+		}
+		if prev, dup := params[key]; dup {
+			value = prev + "," + value
+		}
+		params[key] = value
+	}
+	return params
+}
+
+// InventoryDiff is one setting that differs between two inventories. An
+// empty side means the setting is missing there.
+type InventoryDiff struct {
+	Key   string `json:"key"`
+	Left  string `json:"left"`
+	Right string `json:"right"`
+}
+
+// volatileInventoryKeys change on every run or identify the VM itself: a
+// clone differs from its template there by design
+var volatileInventoryKeys = map[string]bool{
+	"tool_version":        true,
+	"generated_at":        true,
+	"system.hostname":     true,
+	"system.ip_address":   true,
+	"system.memory_used":  true,
+	"hardware.dmi.serial": true,
+	"hardware.dmi.uuid":   true,
+}
+
+// inventoryListKeys identify the elements of the inventory lists (NICs by
+// name, PCI devices by address...), so a reordering is not a difference
+var inventoryListKeys = []string{"name", "address", "interface", "device", "message"}
+
+// DiffInventories compares two inventories (vmware-tuner inventory) and
+// returns the differing settings, sorted by key
+func DiffInventories(left, right []byte) ([]InventoryDiff, error) {
+	var l, r interface{}
+	if err := json.Unmarshal(left, &l); err != nil {
+		return nil, fmt.Errorf("failed to parse the inventory: %w", err)
+	}
+	if err := json.Unmarshal(right, &r); err != nil {
+		return nil, fmt.Errorf("failed to parse the baseline: %w", err)
+	}
+	lf, rf := make(map[string]string), make(map[string]string)
+	flattenJSON("", l, lf)
+	flattenJSON("", r, rf)
+
+	var diffs []InventoryDiff
+	for key, lv := range lf {
+		if rv := rf[key]; rv != lv && !volatileInventoryKeys[key] {
+			diffs = append(diffs, InventoryDiff{Key: key, Left: lv, Right: rv})
+		}
+	}
+	for key, rv := range rf {
+		if _, ok := lf[key]; !ok && !volatileInventoryKeys[key] {
+			diffs = append(diffs, InventoryDiff{Key: key, Right: rv})
+		}
+	}
+	sort.Slice(diffs, func(i, j int) bool { return diffs[i].Key < diffs[j].Key })
+	return diffs, nil
+}
+
+// flattenJSON turns a parsed JSON document into dotted keys and scalar values
+func flattenJSON(prefix string, v interface{}, out map[string]string) {
+	join := func(key string) string {
+		if prefix == "" {
+			return key
+		}
+		return prefix + "." + key
+	}
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for key, value := range v {
+			flattenJSON(join(key), value, out)
+		}
+	case []interface{}:
+		var scalars []string
+		for i, item := range v {
+			obj, ok := item.(map[string]interface{})
+			if !ok {
+				scalars = append(scalars, fmt.Sprint(item))
+				continue
+			}
+			id := fmt.Sprint(i)
+			for _, k := range inventoryListKeys {
+				if name, ok := obj[k].(string); ok && name != "" {
+					id = name
+					break
+				}
+			}
+			flattenJSON(prefix+"["+id+"]", obj, out)
+		}
+		if len(scalars) > 0 {
+			sort.Strings(scalars)
+			out[prefix] = strings.Join(scalars, ", ")
+		}
+	case nil:
+	default:
+		if s := fmt.Sprint(v); s != "" {
+			out[prefix] = s
+		}
+	}
+}
+
+// LoadInventory reads an inventory from a JSON file, or collects it from a
+// host over SSH (key authentication, vmware-tuner installed at remoteBin)
+func LoadInventory(source, remoteBin string) ([]byte, error) {
+	if FileExists(source) || strings.HasSuffix(source, ".json") {
+		data, err := os.ReadFile(source)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", source, err)
+		}
+		return data, nil
+	}
+	PrintInfo("Collecting the inventory of %s over SSH...", source)
+	out, err := exec.Command("ssh", "-o", "BatchMode=yes", "-o", "ConnectTimeout=10", source, remoteBin, "inventory").Output()
+	if err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			return nil, fmt.Errorf("failed to run %s inventory on %s: %s", remoteBin, source, strings.TrimSpace(string(exitErr.Stderr)))
+		}
+		return nil, fmt.Errorf("failed to run ssh: %w", err)
+	}
+	// The JSON document follows the banner and warnings, if any
+	if i := strings.Index(string(out), "{"); i > 0 {
+		out = out[i:]
+	}
+	return out, nil
+}
@@ -0,0 +1,53 @@
+package tuner
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDiffInventories(t *testing.T) {
+	clone := []byte(`{
+  "generated_at": "2024-03-01T10:00:00Z",
+  "system": {"hostname": "web-07", "kernel": "6.1.0-18"},
+  "hardware": {
+    "nics": [{"name": "ens224", "driver": "e1000e"}, {"name": "ens192", "driver": "vmxnet3"}],
+    "dmi": {"serial": "VMware-42 1a", "hints": ["b", "a"]}
+  },
+  "tuning": {"sysctl": {"vm.swappiness": "60"}, "boot_params": {"quiet": "(set)"}}
+}`)
+	golden := []byte(`{
+  "generated_at": "2024-01-01T10:00:00Z",
+  "system": {"hostname": "golden", "kernel": "6.1.0-18"},
+  "hardware": {
+    "nics": [{"name": "ens192", "driver": "vmxnet3"}],
+    "dmi": {"serial": "VMware-42 00", "hints": ["a", "b"]}
+  },
+  "tuning": {"sysctl": {"vm.swappiness": "10"}, "boot_params": {"quiet": "(set)", "elevator": "none"}}
+}`)
+
+	diffs, err := DiffInventories(clone, golden)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []InventoryDiff{
+		{Key: "hardware.nics[ens224].driver", Left: "e1000e"},
+		{Key: "hardware.nics[ens224].name", Left: "ens224"},
+		{Key: "tuning.boot_params.elevator", Right: "none"},
+		{Key: "tuning.sysctl.vm.swappiness", Left: "60", Right: "10"},
+	}
+	if !reflect.DeepEqual(diffs, want) {
+		t.Errorf("diffs = %+v\nwant %+v (NICs by name, lists unordered, host name and serial ignored)", diffs, want)
+	}
+
+	if _, err := DiffInventories(clone, []byte("not json")); err == nil {
+		t.Error("an invalid baseline should be refused")
+	}
+}
+
+func TestParseBootParams(t *testing.T) {
+	got := parseBootParams("BOOT_IMAGE=/vmlinuz root=/dev/sda1 ro quiet console=tty0 console=ttyS0 -- --init-arg\n")
+	want := map[string]string{"BOOT_IMAGE": "/vmlinuz", "root": "/dev/sda1", "ro": "(set)", "quiet": "(set)", "console": "tty0,ttyS0"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("params = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,223 @@
+package tuner
+
+import (
+	"fmt"
+	"net"
+	"os/exec"
+	"strings"
+)
+
+// vmwareOUIs are the MAC prefixes assigned to VMware virtual NICs
+var vmwareOUIs = map[string]string{
+	"00:50:56": "vCenter/static",
+	"00:0c:29": "ESXi host generated",
+	"00:05:69": "legacy VMware",
+	"00:1c:14": "VMware",
+}
+
+// MACOrigin describes where a MAC address comes from: vmware is false for
+// non-VMware (manually set or physical) addresses
+func MACOrigin(mac string) (string, bool) {
+	mac = strings.ToLower(mac)
+	if len(mac) < 8 {
+		return "invalid", false
+	}
+	origin, ok := vmwareOUIs[mac[:8]]
+	if !ok {
+		return "non-VMware OUI", false
+	}
+	// 00:50:56:00-3F is reserved for manually assigned static MACs
+	if mac[:8] == "00:50:56" && len(mac) >= 11 {
+		var b byte
+		fmt.Sscanf(mac[9:11], "%02x", &b)
+		if b <= 0x3f {
+			return "VMware static (manually assigned)", true
+		}
+		return "vCenter generated", true
+	}
+	return origin, true
+}
+
+// Neighbor is an entry of the kernel neighbor (ARP) table
+type Neighbor struct {
+	IP     string
+	Device string
+	MAC    string
+}
+
+// parseNeighbors parses `ip neigh show` output
+func parseNeighbors(out string) []Neighbor {
+	var neighbors []Neighbor
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.Fields(line)
+		n := Neighbor{}
+		if len(fields) > 0 {
+			n.IP = fields[0]
+		}
+		for i := 1; i+1 < len(fields); i++ {
+			switch fields[i] {
+			case "dev":
+				n.Device = fields[i+1]
+			case "lladdr":
+				n.MAC = strings.ToLower(fields[i+1])
+			}
+		}
+		if n.IP != "" && n.MAC != "" {
+			neighbors = append(neighbors, n)
+		}
+	}
+	return neighbors
+}
+
+// IPConflictTuner detects duplicate IPs and MACs (typically clones of a VM)
+type IPConflictTuner struct{}
+
+// NewIPConflictTuner creates a new IP conflict tuner
+func NewIPConflictTuner() *IPConflictTuner {
+	return &IPConflictTuner{}
+}
+
+func init() {
+	Register(Module{
+		Name:        "ipconflict",
+		Label:       "IP Conflict & Duplicate MAC Check",
+		Description: "Look for duplicate IP and MAC addresses on the network",
+		Category:    CategoryDiagnostics,
+		Menu:        28,
+		RequireRoot: true,
+		ReadOnly:    true,
+		Run: func(ctx *ModuleContext) error {
+			return NewIPConflictTuner().Run()
+		},
+	})
+}
+
+// Run checks every interface; it only reads state and sends ARP probes
+func (it *IPConflictTuner) Run() error {
+	PrintStep("IP Conflict & Duplicate MAC Check")
+
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return fmt.Errorf("failed to list interfaces: %w", err)
+	}
+
+	var neighbors []Neighbor
+	if out, err := exec.Command("ip", "neigh", "show").Output(); err == nil {
+		neighbors = parseNeighbors(string(out))
+	}
+
+	arping := iputilsArping()
+	if arping == "" {
+		PrintWarning("iputils arping not found: duplicate IPv4 addresses cannot be probed (duplicate MACs are still checked)")
+	}
+
+	problems := 0
+	for _, iface := range ifaces {
+		if iface.Flags&net.FlagLoopback != 0 || iface.Flags&net.FlagUp == 0 || len(iface.HardwareAddr) == 0 {
+			continue
+		}
+		// Bridges, bonds and veth pairs reuse or invent MACs
+		if !Sys.Exists("/sys/class/net", iface.Name, "device") {
+			continue
+		}
+
+		mac := iface.HardwareAddr.String()
+		fmt.Printf("\n  Interface: %s (%s)\n", iface.Name, mac)
+
+		origin, vmware := MACOrigin(mac)
+		if vmware {
+			PrintSuccess("  MAC OUI: %s", origin)
+		} else {
+			PrintWarning("  MAC OUI: %s (set by hand or copied from another machine?)", origin)
+		}
+		if strings.Contains(origin, "manually") {
+			PrintInfo("  Static MACs are kept by clones: make sure no other VM uses %s", mac)
+		}
+
+		for _, n := range neighbors {
+			if n.MAC == strings.ToLower(mac) {
+				PrintError("  Duplicate MAC: %s answers with this interface's MAC on %s", n.IP, n.Device)
+				problems++
+			}
+		}
+
+		addrs, _ := iface.Addrs()
+		for _, addr := range addrs {
+			ipnet, ok := addr.(*net.IPNet)
+			if !ok {
+				continue
+			}
+			if ipnet.IP.To4() == nil {
+				if dadFailed(iface.Name, ipnet.IP.String()) {
+					PrintError("  Duplicate IPv6 address %s (kernel DAD failed)", ipnet.IP)
+					problems++
+				}
+				continue
+			}
+			if arping == "" {
+				continue
+			}
+			if owner, dup := probeDuplicate(arping, iface.Name, ipnet.IP.String()); dup {
+				PrintError("  Duplicate IP %s: also answered by %s", ipnet.IP, owner)
+				problems++
+			} else {
+				PrintSuccess("  %s: no other host answers", ipnet.IP)
+			}
+		}
+	}
+
+	fmt.Println()
+	if problems > 0 {
+		PrintWarning("%d conflict(s) found: clones explain intermittent connectivity loss", problems)
+		PrintInfo("Regenerate the MAC in vSphere (network adapter > MAC address: Automatic) and fix the IP")
+		return nil
+	}
+	PrintSuccess("No IP or MAC conflict detected")
+	return nil
+}
+
+// iputilsArping returns the iputils arping binary (the only one with -D), or ""
+func iputilsArping() string {
+	path, err := exec.LookPath("arping")
+	if err != nil {
+		return ""
+	}
+	out, _ := exec.Command(path, "-V").CombinedOutput()
+	if !strings.Contains(string(out), "iputils") {
+		return ""
+	}
+	return path
+}
+
+// probeDuplicate runs arping duplicate address detection: exit status 1 means
+// another host replied. Returns the MAC of the other host.
+func probeDuplicate(arping, iface, ip string) (string, bool) {
+	out, err := exec.Command(arping, "-D", "-I", iface, "-c", "2", "-w", "3", ip).CombinedOutput()
+	if err == nil {
+		return "", false
+	}
+	if exitErr, ok := err.(*exec.ExitError); !ok || exitErr.ExitCode() != 1 {
+		return "", false
+	}
+	for _, line := range strings.Split(string(out), "\n") {
+		// Unicast reply from 10.0.0.5 [00:50:56:AA:BB:CC]  0.7ms
+		if start, end := strings.Index(line, "["), strings.Index(line, "]"); start != -1 && end > start {
+			return line[start+1 : end], true
+		}
+	}
+	return "another host", true
+}
+
+// dadFailed reports an IPv6 address flagged dadfailed by the kernel
+func dadFailed(iface, ip string) bool {
+	out, err := exec.Command("ip", "-6", "addr", "show", "dev", iface).Output()
+	if err != nil {
+		return false
+	}
+	for _, line := range strings.Split(string(out), "\n") {
+		if strings.Contains(line, ip+"/") && strings.Contains(line, "dadfailed") {
+			return true
+		}
+	}
+	return false
+}
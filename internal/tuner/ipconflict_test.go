@@ -0,0 +1,36 @@
+package tuner
+
+import "testing"
+
+func TestMACOrigin(t *testing.T) {
+	tests := []struct {
+		mac    string
+		vmware bool
+		origin string
+	}{
+		{"00:50:56:12:34:56", true, "VMware static (manually assigned)"},
+		{"00:50:56:9A:34:56", true, "vCenter generated"},
+		{"00:0c:29:aa:bb:cc", true, "ESXi host generated"},
+		{"52:54:00:12:34:56", false, "non-VMware OUI"},
+	}
+	for _, tt := range tests {
+		origin, vmware := MACOrigin(tt.mac)
+		if vmware != tt.vmware || origin != tt.origin {
+			t.Errorf("MACOrigin(%s) = %q, %v", tt.mac, origin, vmware)
+		}
+	}
+}
+
+func TestParseNeighbors(t *testing.T) {
+	out := `10.0.0.1 dev ens192 lladdr 00:50:56:AA:BB:CC REACHABLE
+10.0.0.7 dev ens192  FAILED
+fe80::1 dev ens192 lladdr 00:50:56:aa:bb:cc router STALE
+`
+	neighbors := parseNeighbors(out)
+	if len(neighbors) != 2 {
+		t.Fatalf("expected 2 neighbors, got %+v", neighbors)
+	}
+	if neighbors[0].IP != "10.0.0.1" || neighbors[0].Device != "ens192" || neighbors[0].MAC != "00:50:56:aa:bb:cc" {
+		t.Errorf("unexpected neighbor: %+v", neighbors[0])
+	}
+}
@@ -0,0 +1,319 @@
+package tuner
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// IPv6 modes (network.ipv6 in the config file, --ipv6-mode)
+const (
+	IPv6PreferIPv4  = "prefer-ipv4"  // IPv4 addresses first in name resolution, IPv6 kept
+	IPv6Disable     = "disable"      // disable_ipv6 on every interface but the loopback
+	IPv6DisableBoot = "disable-boot" // ipv6.disable=1: the IPv6 stack is not loaded (reboot)
+)
+
+// IPv6Modes lists the IPv6 modes, the default first
+var IPv6Modes = []string{IPv6PreferIPv4, IPv6Disable, IPv6DisableBoot}
+
+// ipv6BootParam keeps the kernel from loading the IPv6 stack
+const ipv6BootParam = "ipv6.disable=1"
+
+// ipv6DisableKeys are the keys the disable mode sets: all reaches every
+// interface, default the ones created later
+var ipv6DisableKeys = []string{"net.ipv6.conf.all.disable_ipv6", "net.ipv6.conf.default.disable_ipv6"}
+
+// gaiPreferIPv4 gives the IPv4-mapped addresses the highest precedence (RFC 6724)
+const gaiPreferIPv4 = "precedence ::ffff:0:0/96  100"
+
+// ipv6SysctlContent disables IPv6 on every interface, the loopback excepted:
+// services binding ::1 keep working
+const ipv6SysctlContent = `# Generated by vmware-tuner: IPv6 disabled (network.ipv6: disable)
+net.ipv6.conf.all.disable_ipv6 = 1
+net.ipv6.conf.default.disable_ipv6 = 1
+net.ipv6.conf.lo.disable_ipv6 = 0
+`
+
+// IPv6Tuner disables or deprioritizes IPv6 where it is not routed and name
+// resolution waits for AAAA answers that lead nowhere
+type IPv6Tuner struct {
+	DryRun      bool
+	Mode        string
+	Distro      *DistroManager
+	SysctlPath  string
+	GaiConfPath string
+	FSRoot      string // prefix of /proc and /etc/postfix (fixture tests)
+}
+
+// NewIPv6Tuner creates a new IPv6 tuner for the mode of the config file
+func NewIPv6Tuner(dryRun bool, distro *DistroManager) *IPv6Tuner {
+	mode := Tuning.IPv6
+	if mode == "" {
+		mode = IPv6PreferIPv4
+	}
+	return &IPv6Tuner{
+		DryRun:      dryRun,
+		Mode:        mode,
+		Distro:      distro,
+		SysctlPath:  "/etc/sysctl.d/99-vmware-tuner-ipv6.conf",
+		GaiConfPath: "/etc/gai.conf",
+	}
+}
+
+func init() {
+	Register(Module{
+		Name:        "ipv6",
+		Description: "IPv6 limited (network.ipv6: prefer-ipv4, disable or disable-boot)",
+		Category:    CategoryTuning,
+		Pipeline:    13,
+		RequireRoot: true,
+		Flag:        &ModuleFlag{Name: "with-ipv6-limit", Usage: "Prefer IPv4 or disable IPv6 where it is not routed (mode: --ipv6-mode or network.ipv6)"},
+		Apply: func(ctx *ModuleContext) error {
+			ipv6 := NewIPv6Tuner(ctx.DryRun, ctx.Distro)
+			if err := ipv6.Apply(ctx.Backup); err != nil {
+				return err
+			}
+			if ipv6.Mode == IPv6DisableBoot {
+				ctx.RebootRequired = true
+			}
+			return nil
+		},
+		Show: func(ctx *ModuleContext) error {
+			return NewIPv6Tuner(false, ctx.Distro).ShowCurrent()
+		},
+		Verify: func(ctx *ModuleContext) error {
+			return NewIPv6Tuner(false, ctx.Distro).Verify()
+		},
+	})
+}
+
+// ValidIPv6Mode checks an IPv6 mode
+func ValidIPv6Mode(mode string) error {
+	for _, m := range IPv6Modes {
+		if mode == m {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: unknown IPv6 mode %q (%s)", ErrValidationFailed, mode, strings.Join(IPv6Modes, ", "))
+}
+
+// Apply limits IPv6 as the mode says
+func (it *IPv6Tuner) Apply(backup *BackupManager) error {
+	PrintStep("IPv6 (" + it.Mode + ")")
+	if err := ValidIPv6Mode(it.Mode); err != nil {
+		return err
+	}
+
+	if it.Mode == IPv6PreferIPv4 {
+		return it.preferIPv4(backup)
+	}
+
+	// IPv6 is disabled on every interface at once: one left to the
+	// administrator keeps it enabled everywhere
+	if it.excluded() {
+		PrintWarning("IPv6 left enabled: %s disables it on every interface", it.Mode)
+		return nil
+	}
+
+	// Disabling a routed IPv6 cuts the connections that use it
+	if dev, err := it.defaultRoute(); err != nil {
+		PrintWarning("Could not read the IPv6 routes: %v", err)
+	} else if dev != "" {
+		return fmt.Errorf("%w: IPv6 has a default route via %s here; disabling it would cut the IPv6 traffic (network.ipv6: %s keeps it)", ErrValidationFailed, dev, IPv6PreferIPv4)
+	}
+	it.warnPostfix()
+
+	if it.Mode == IPv6DisableBoot {
+		return NewGrubTuner(it.DryRun, it.Distro).AddParams(backup, []string{ipv6BootParam})
+	}
+	return it.disable(backup)
+}
+
+// excluded reports whether the exclusions file protects a key of the
+// disable mode or an interface it would reach
+func (it *IPv6Tuner) excluded() bool {
+	skip := false
+	for _, key := range ipv6DisableKeys {
+		if SkipExcluded("ipv6", ExcludeSysctl, key) {
+			skip = true
+		}
+	}
+	ifaces, err := ListInterfaces(it.FSRoot)
+	if err != nil {
+		PrintWarning("Could not list the interfaces: %v", err)
+	}
+	for _, iface := range ifaces {
+		if SkipExcluded("ipv6", ExcludeInterface, iface.Name) {
+			skip = true
+		}
+	}
+	return skip
+}
+
+// preferIPv4 adds the IPv4 precedence line to gai.conf (glibc getaddrinfo)
+func (it *IPv6Tuner) preferIPv4(backup *BackupManager) error {
+	data, err := os.ReadFile(it.GaiConfPath)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to read %s: %w", it.GaiConfPath, err)
+	}
+	if gaiPrefersIPv4(string(data)) {
+		PrintSuccess("IPv4 already preferred (%s)", it.GaiConfPath)
+		return nil
+	}
+	if it.DryRun {
+		PrintInfo("Would add to %s: %s", it.GaiConfPath, gaiPreferIPv4)
+		return nil
+	}
+
+	if err := backup.BackupFile(it.GaiConfPath); err != nil {
+		return fmt.Errorf("failed to backup %s: %w", it.GaiConfPath, err)
+	}
+	content := string(data)
+	if content != "" && !strings.HasSuffix(content, "\n") {
+		content += "\n"
+	}
+	content += "# Added by vmware-tuner: IPv4 addresses first (network.ipv6: prefer-ipv4)\n" + gaiPreferIPv4 + "\n"
+	ExplainEdit("resolve names to IPv4 addresses first, IPv6 stays available", it.GaiConfPath)
+	if err := os.WriteFile(it.GaiConfPath, []byte(content), 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", it.GaiConfPath, err)
+	}
+	PrintSuccess("IPv4 preferred in %s (new processes)", it.GaiConfPath)
+	return nil
+}
+
+// gaiPrefersIPv4 reports whether gai.conf already gives IPv4 the precedence
+func gaiPrefersIPv4(content string) bool {
+	for _, line := range strings.Split(content, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 3 && fields[0] == "precedence" && fields[1] == "::ffff:0:0/96" && fields[2] == "100" {
+			return true
+		}
+	}
+	return false
+}
+
+// disable writes the disable_ipv6 keys and loads them
+func (it *IPv6Tuner) disable(backup *BackupManager) error {
+	if current, err := os.ReadFile(it.SysctlPath); err == nil && string(current) == ipv6SysctlContent {
+		PrintSuccess("IPv6 already disabled (%s)", it.SysctlPath)
+		return nil
+	}
+	if it.DryRun {
+		PrintInfo("Would create: %s", it.SysctlPath)
+		return nil
+	}
+
+	if err := backup.BackupFile(it.SysctlPath); err != nil {
+		return fmt.Errorf("failed to backup %s: %w", it.SysctlPath, err)
+	}
+	if err := os.MkdirAll(filepath.Dir(it.SysctlPath), 0755); err != nil {
+		return fmt.Errorf("failed to create %s: %w", filepath.Dir(it.SysctlPath), err)
+	}
+	ExplainEdit("disable IPv6 on every interface but the loopback, at every boot", it.SysctlPath)
+	if err := os.WriteFile(it.SysctlPath, []byte(ipv6SysctlContent), 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", it.SysctlPath, err)
+	}
+	PrintSuccess("Created %s", it.SysctlPath)
+
+	ExplainCommand("disable IPv6 now, without a reboot", "sysctl", "-p", it.SysctlPath)
+	if out, err := exec.Command("sysctl", "-p", it.SysctlPath).CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to load %s: %s: %w", it.SysctlPath, strings.TrimSpace(string(out)), err)
+	}
+	PrintSuccess("IPv6 disabled")
+	return nil
+}
+
+// defaultRoute returns the interface of the IPv6 default route, empty when
+// there is none (the unreachable route on lo does not count)
+func (it *IPv6Tuner) defaultRoute() (string, error) {
+	f, err := os.Open(filepath.Join(it.FSRoot, "/proc/net/ipv6_route"))
+	if os.IsNotExist(err) {
+		return "", nil // IPv6 already off (ipv6.disable=1)
+	}
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	// dest prefix src prefix nexthop metric refcnt use flags device
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 10 {
+			continue
+		}
+		if strings.Trim(fields[0], "0") == "" && fields[1] == "00" && fields[9] != "lo" {
+			return fields[9], nil
+		}
+	}
+	return "", scanner.Err()
+}
+
+// warnPostfix warns about Postfix listening on all protocols: it stops
+// starting without IPv6
+func (it *IPv6Tuner) warnPostfix() {
+	data, err := os.ReadFile(filepath.Join(it.FSRoot, "/etc/postfix/main.cf"))
+	if err != nil {
+		return
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		key, value, ok := strings.Cut(line, "=")
+		if ok && strings.TrimSpace(key) == "inet_protocols" && strings.TrimSpace(value) == "all" {
+			PrintWarning("Postfix listens on all protocols (inet_protocols = all): set inet_protocols = ipv4 in /etc/postfix/main.cf")
+			return
+		}
+	}
+}
+
+// Verify checks that the IPv6 mode is in effect
+func (it *IPv6Tuner) Verify() error {
+	gai, _ := os.ReadFile(it.GaiConfPath)
+	disabled, err := os.ReadFile(filepath.Join(it.FSRoot, "/proc/sys/net/ipv6/conf/all/disable_ipv6"))
+	loaded := !os.IsNotExist(err)
+
+	// Nothing asked and nothing applied: IPv6 is left as the distribution has it
+	if Tuning.IPv6 == "" && !gaiPrefersIPv4(string(gai)) && !FileExists(it.SysctlPath) {
+		return fmt.Errorf("%w: IPv6 not limited (network.ipv6 not set)", ErrVerifySkipped)
+	}
+
+	switch it.Mode {
+	case IPv6PreferIPv4:
+		if !gaiPrefersIPv4(string(gai)) {
+			return fmt.Errorf("IPv4 is not preferred in %s", it.GaiConfPath)
+		}
+	case IPv6Disable:
+		if loaded && strings.TrimSpace(string(disabled)) != "1" {
+			return fmt.Errorf("IPv6 is enabled (net.ipv6.conf.all.disable_ipv6 = 0)")
+		}
+	case IPv6DisableBoot:
+		if loaded {
+			return fmt.Errorf("the IPv6 stack is loaded: %s not in effect (reboot pending?)", ipv6BootParam)
+		}
+	}
+	PrintSuccess("IPv6: %s in effect", it.Mode)
+	return nil
+}
+
+// ShowCurrent prints the IPv6 state
+func (it *IPv6Tuner) ShowCurrent() error {
+	PrintStep("IPv6")
+	data, err := os.ReadFile(filepath.Join(it.FSRoot, "/proc/sys/net/ipv6/conf/all/disable_ipv6"))
+	switch {
+	case os.IsNotExist(err):
+		PrintInfo("IPv6 stack not loaded (%s)", ipv6BootParam)
+	case err != nil:
+		return err
+	case strings.TrimSpace(string(data)) == "1":
+		PrintInfo("IPv6 disabled (disable_ipv6)")
+	default:
+		PrintInfo("IPv6 enabled")
+	}
+	gai, _ := os.ReadFile(it.GaiConfPath)
+	if gaiPrefersIPv4(string(gai)) {
+		PrintInfo("IPv4 preferred in name resolution (%s)", it.GaiConfPath)
+	}
+	return nil
+}
@@ -0,0 +1,107 @@
+package tuner
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// ipv6Fixture writes /proc/net/ipv6_route with the given route lines
+func ipv6Fixture(t *testing.T, routes ...string) string {
+	t.Helper()
+	root := t.TempDir()
+	path := filepath.Join(root, "proc/net/ipv6_route")
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(strings.Join(routes, "\n")+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return root
+}
+
+const (
+	ipv6LinkLocalRoute   = "fe800000000000000000000000000000 40 00000000000000000000000000000000 00 00000000000000000000000000000000 00000100 00000001 00000000 00000001   ens192"
+	ipv6UnreachableRoute = "00000000000000000000000000000000 00 00000000000000000000000000000000 00 00000000000000000000000000000000 ffffffff 00000001 00000000 00200200       lo"
+	ipv6DefaultRoute     = "00000000000000000000000000000000 00 00000000000000000000000000000000 00 fe800000000000000000000000000001 00000400 00000001 00000000 00450003   ens192"
+)
+
+func TestIPv6PreferIPv4(t *testing.T) {
+	dir := t.TempDir()
+	it := &IPv6Tuner{Mode: IPv6PreferIPv4, GaiConfPath: filepath.Join(dir, "gai.conf")}
+	os.WriteFile(it.GaiConfPath, []byte("#precedence ::ffff:0:0/96  100\nlabel ::1/128 0"), 0644)
+	bm := &BackupManager{BackupDir: filepath.Join(dir, "backup")}
+	if err := bm.Initialize(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := it.Apply(bm); err != nil {
+		t.Fatal(err)
+	}
+	data, _ := os.ReadFile(it.GaiConfPath)
+	if !strings.HasPrefix(string(data), "#precedence ::ffff:0:0/96  100\nlabel ::1/128 0\n") || !gaiPrefersIPv4(string(data)) {
+		t.Errorf("gai.conf = %q, want the admin lines kept and the precedence added", data)
+	}
+	if err := it.Apply(bm); err != nil {
+		t.Fatal(err)
+	}
+	if again, _ := os.ReadFile(it.GaiConfPath); string(again) != string(data) {
+		t.Error("a second run should change nothing")
+	}
+	if m, err := LoadManifest(bm.BackupDir); err != nil || len(m.Entries) != 1 {
+		t.Errorf("gai.conf not backed up: %+v, %v", m, err)
+	}
+}
+
+func TestIPv6DisableRefusedWhenRouted(t *testing.T) {
+	dir := t.TempDir()
+	it := &IPv6Tuner{Mode: IPv6Disable, SysctlPath: filepath.Join(dir, "99-ipv6.conf"),
+		FSRoot: ipv6Fixture(t, ipv6LinkLocalRoute, ipv6DefaultRoute, ipv6UnreachableRoute)}
+	err := it.Apply(&BackupManager{BackupDir: t.TempDir()})
+	if !errors.Is(err, ErrValidationFailed) || !strings.Contains(err.Error(), "ens192") {
+		t.Errorf("err = %v, want a refusal naming the routed interface", err)
+	}
+	if FileExists(it.SysctlPath) {
+		t.Error("nothing should be written when refused")
+	}
+
+	it.FSRoot = ipv6Fixture(t, ipv6LinkLocalRoute, ipv6UnreachableRoute)
+	it.DryRun = true
+	if err := it.Apply(nil); err != nil {
+		t.Errorf("unrouted IPv6 (lo unreachable route only): %v", err)
+	}
+}
+
+func TestIPv6DisableExcluded(t *testing.T) {
+	saved := Excluded
+	defer func() { Excluded = saved }()
+	root := ipv6Fixture(t, ipv6LinkLocalRoute, ipv6UnreachableRoute)
+	writeFiles(t, root, map[string]string{"sys/class/net/ens224/device/vendor": "0x15ad\n"})
+	it := &IPv6Tuner{Mode: IPv6Disable, SysctlPath: filepath.Join(t.TempDir(), "99-ipv6.conf"), FSRoot: root}
+
+	for _, ex := range []Exclusions{
+		{Path: DefaultExclusionsPath, Sysctl: []string{"net.ipv6.conf.*"}},
+		{Path: DefaultExclusionsPath, Interfaces: []string{"ens224"}},
+	} {
+		Excluded = ex
+		if err := it.Apply(&BackupManager{BackupDir: t.TempDir()}); err != nil {
+			t.Fatal(err)
+		}
+		if FileExists(it.SysctlPath) {
+			t.Errorf("IPv6 disabled despite %+v", ex)
+		}
+	}
+	TakeExclusionSkips()
+}
+
+func TestIPv6Config(t *testing.T) {
+	var tc TuningConfig
+	if err := tc.decode(map[string]interface{}{"network": map[string]interface{}{"ipv6": "disable-boot"}}); err != nil || tc.IPv6 != IPv6DisableBoot {
+		t.Errorf("ipv6 = %q, %v", tc.IPv6, err)
+	}
+	if err := tc.decode(map[string]interface{}{"network": map[string]interface{}{"ipv6": "off"}}); err == nil {
+		t.Error("unknown IPv6 mode should be refused")
+	}
+}
@@ -0,0 +1,266 @@
+package tuner
+
+import (
+	"bufio"
+	"fmt"
+	"math/big"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// CPUIsolationTuner dedicates a set of vCPUs to an application
+// (GRUB isolation params + systemd CPUAffinity drop-ins)
+type CPUIsolationTuner struct {
+	Distro     *DistroManager
+	DryRun     bool
+	SystemdDir string
+}
+
+// isolationDropIn is the drop-in file name used for all CPUAffinity overrides
+const isolationDropIn = "50-vmware-tuner-cpuaffinity.conf"
+
+// NewCPUIsolationTuner creates a new CPU isolation tuner
+func NewCPUIsolationTuner(dryRun bool, distro *DistroManager) *CPUIsolationTuner {
+	return &CPUIsolationTuner{
+		Distro:     distro,
+		DryRun:     dryRun,
+		SystemdDir: "/etc/systemd",
+	}
+}
+
+func init() {
+	Register(Module{
+		Name:        "isolation",
+		Label:       "CPU Isolation Assistant",
+		Description: "Isolate vCPUs for a latency-sensitive application",
+		Category:    CategoryExpert,
+		Menu:        19,
+		RequireRoot: true,
+		Distros:     packagedDistros,
+		Run: func(ctx *ModuleContext) error {
+			return NewCPUIsolationTuner(false, ctx.Distro).Run()
+		},
+	})
+}
+
+// FormatCPUList renders CPUs in kernel list format ("1-3,6")
+func FormatCPUList(cpus []int) string {
+	sorted := append([]int(nil), cpus...)
+	sort.Ints(sorted)
+
+	var parts []string
+	for i := 0; i < len(sorted); {
+		j := i
+		for j+1 < len(sorted) && sorted[j+1] == sorted[j]+1 {
+			j++
+		}
+		if i == j {
+			parts = append(parts, strconv.Itoa(sorted[i]))
+		} else {
+			parts = append(parts, fmt.Sprintf("%d-%d", sorted[i], sorted[j]))
+		}
+		i = j + 1
+	}
+	return strings.Join(parts, ",")
+}
+
+// CPUMask renders CPUs as a hexadecimal affinity mask ("c" for CPUs 2,3)
+func CPUMask(cpus []int) string {
+	mask := new(big.Int)
+	for _, cpu := range cpus {
+		mask.SetBit(mask, cpu, 1)
+	}
+	return mask.Text(16)
+}
+
+// housekeepingCPUs returns the online CPUs not in the isolated set
+func housekeepingCPUs(isolated []int, online int) []int {
+	skip := make(map[int]bool)
+	for _, cpu := range isolated {
+		skip[cpu] = true
+	}
+	var cpus []int
+	for cpu := 0; cpu < online; cpu++ {
+		if !skip[cpu] {
+			cpus = append(cpus, cpu)
+		}
+	}
+	return cpus
+}
+
+// affinityValue renders CPUs in the space-separated form systemd expects
+func affinityValue(cpus []int) string {
+	var parts []string
+	for _, cpu := range cpus {
+		parts = append(parts, strconv.Itoa(cpu))
+	}
+	return strings.Join(parts, " ")
+}
+
+// Run guides the user through dedicating vCPUs to a workload
+func (ct *CPUIsolationTuner) Run() error {
+	PrintStep("CPU Isolation Assistant")
+
+	online := onlineCPUCount()
+	PrintInfo("%d vCPUs online", online)
+	if online < 2 {
+		return fmt.Errorf("CPU isolation requires at least 2 vCPUs")
+	}
+	if IsRealtimeKernel(Sys.Root) {
+		PrintSuccess("PREEMPT_RT kernel detected (best determinism for isolated CPUs)")
+	}
+
+	reader := bufio.NewReader(os.Stdin)
+	fmt.Printf("vCPUs to dedicate (e.g. 1-%d; CPU 0 must stay shared): ", online-1)
+	input, _ := reader.ReadString('\n')
+
+	isolated, err := ParseCPUList(strings.TrimSpace(input))
+	if err != nil {
+		return err
+	}
+	sort.Ints(isolated)
+	housekeeping := housekeepingCPUs(isolated, online)
+
+	fmt.Println()
+	fmt.Printf("  %-22s: %s (mask 0x%s)\n", "Isolated vCPUs", FormatCPUList(isolated), CPUMask(isolated))
+	fmt.Printf("  %-22s: %s (mask 0x%s)\n", "Housekeeping vCPUs", FormatCPUList(housekeeping), CPUMask(housekeeping))
+	fmt.Println()
+
+	fmt.Print("Services to pin on the isolated vCPUs (comma separated, empty for none): ")
+	input, _ = reader.ReadString('\n')
+	var services []string
+	for _, svc := range strings.Split(input, ",") {
+		if svc = strings.TrimSpace(svc); svc != "" {
+			if !strings.Contains(svc, ".") {
+				svc += ".service"
+			}
+			services = append(services, svc)
+		}
+	}
+
+	backup := NewBackupManager()
+	if !ct.DryRun {
+		if err := backup.Initialize(); err != nil {
+			return err
+		}
+	}
+
+	// 1. Kernel parameters
+	grub := NewGrubTuner(ct.DryRun, ct.Distro)
+	grub.ExtraParams = IsolationBootParams(FormatCPUList(isolated))
+	if err := grub.Apply(backup); err != nil {
+		return err
+	}
+
+	// 2. Keep every other unit on the housekeeping vCPUs
+	managerDropIn := filepath.Join(ct.SystemdDir, "system.conf.d", isolationDropIn)
+	if err := ct.writeDropIn(backup, managerDropIn, "[Manager]\nCPUAffinity="+affinityValue(housekeeping)+"\n"); err != nil {
+		return err
+	}
+
+	// 3. Pin the selected services on the isolated vCPUs
+	for _, svc := range services {
+		path := filepath.Join(ct.SystemdDir, "system", svc+".d", isolationDropIn)
+		if err := ct.writeDropIn(backup, path, "[Service]\nCPUAffinity="+affinityValue(isolated)+"\n"); err != nil {
+			return err
+		}
+	}
+
+	if !ct.DryRun {
+		exec.Command("systemctl", "daemon-reload").Run()
+	}
+
+	PrintWarning("REBOOT REQUIRED, then run 'vmware-tuner verify' to confirm isolation is active")
+	return nil
+}
+
+// writeDropIn writes a systemd drop-in with backup
+func (ct *CPUIsolationTuner) writeDropIn(backup *BackupManager, path, content string) error {
+	content = "# CPU isolation - Generated by vmware-tuner\n" + content
+
+	if ct.DryRun {
+		PrintInfo("Would create: %s", path)
+		fmt.Println(content)
+		return nil
+	}
+
+	if err := backup.BackupFile(path); err != nil {
+		return fmt.Errorf("failed to backup %s: %w", path, err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create %s: %w", filepath.Dir(path), err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+
+	PrintSuccess("Created %s", path)
+	return nil
+}
+
+// Verify checks that the configured isolation is active after reboot.
+// It is a no-op when no isolation is configured in GRUB.
+func (ct *CPUIsolationTuner) Verify() error {
+	grub := NewGrubTuner(true, ct.Distro)
+	config, _, err := grub.ParseGrubConfig()
+	if err != nil {
+		return nil
+	}
+
+	wanted := ""
+	for _, param := range strings.Fields(config["GRUB_CMDLINE_LINUX_DEFAULT"]) {
+		if strings.HasPrefix(param, "isolcpus=") {
+			wanted = strings.TrimPrefix(param, "isolcpus=")
+		}
+	}
+	if wanted == "" {
+		return nil
+	}
+
+	active, err := Sys.ReadString("/sys/devices/system/cpu/isolated")
+	if err != nil {
+		return fmt.Errorf("could not read isolated CPUs: %w", err)
+	}
+	if active != wanted {
+		return fmt.Errorf("isolcpus=%s configured but kernel reports isolated=%q (reboot pending?)", wanted, active)
+	}
+	PrintSuccess("CPU isolation active (isolated: %s)", active)
+
+	// Check pinned services actually run on the isolated CPUs
+	dropIns, _ := filepath.Glob(filepath.Join(ct.SystemdDir, "system", "*.d", isolationDropIn))
+	for _, dropIn := range dropIns {
+		unit := strings.TrimSuffix(filepath.Base(filepath.Dir(dropIn)), ".d")
+		out, err := exec.Command("systemctl", "show", "-p", "MainPID", "--value", unit).Output()
+		pid := strings.TrimSpace(string(out))
+		if err != nil || pid == "" || pid == "0" {
+			PrintWarning("%s is not running, cannot check its CPU affinity", unit)
+			continue
+		}
+		allowed := procCPUsAllowed(pid)
+		if allowed == wanted {
+			PrintSuccess("%s runs on isolated vCPUs %s", unit, allowed)
+		} else {
+			PrintWarning("%s runs on vCPUs %s (expected %s)", unit, allowed, wanted)
+		}
+	}
+
+	return nil
+}
+
+// procCPUsAllowed returns the Cpus_allowed_list of a process
+func procCPUsAllowed(pid string) string {
+	data, err := Sys.ReadFile("/proc", pid, "status")
+	if err != nil {
+		return "unknown"
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		if strings.HasPrefix(line, "Cpus_allowed_list:") {
+			return strings.TrimSpace(strings.TrimPrefix(line, "Cpus_allowed_list:"))
+		}
+	}
+	return "unknown"
+}
@@ -0,0 +1,58 @@
+package tuner
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestFormatCPUList(t *testing.T) {
+	cases := []struct {
+		cpus []int
+		want string
+	}{
+		{[]int{2, 3}, "2-3"},
+		{[]int{5, 1, 2, 3}, "1-3,5"},
+		{[]int{4}, "4"},
+		{[]int{1, 3, 5, 6, 7}, "1,3,5-7"},
+	}
+
+	for _, c := range cases {
+		if got := FormatCPUList(c.cpus); got != c.want {
+			t.Errorf("FormatCPUList(%v) = %q, want %q", c.cpus, got, c.want)
+		}
+	}
+}
+
+func TestCPUMask(t *testing.T) {
+	if got := CPUMask([]int{2, 3}); got != "c" {
+		t.Errorf("CPUMask([2 3]) = %q, want %q", got, "c")
+	}
+	if got := CPUMask([]int{0, 1}); got != "3" {
+		t.Errorf("CPUMask([0 1]) = %q, want %q", got, "3")
+	}
+	if got := CPUMask([]int{64}); got != "10000000000000000" {
+		t.Errorf("CPUMask([64]) = %q, want a 65-bit mask", got)
+	}
+}
+
+func TestParseCPUList(t *testing.T) {
+	defer func(sys SysFS) { Sys = sys }(Sys)
+	Sys = SysFS{Root: t.TempDir()}
+	writeFiles(t, Sys.Root, map[string]string{"/proc/cpuinfo": strings.Repeat("processor\t: 0\n\n", 8)})
+
+	for list, want := range map[string][]int{
+		"2-3":     {2, 3},
+		"1,3,5-6": {1, 3, 5, 6},
+		"2,2-3":   {2, 3},
+	} {
+		if got, err := ParseCPUList(list); err != nil || !reflect.DeepEqual(got, want) {
+			t.Errorf("ParseCPUList(%q) = %v, %v, want %v", list, got, err, want)
+		}
+	}
+	for _, list := range []string{"", "1-3x", "2x", "x", "1-", "-1", "3-1", "1,,2", "0-1", "8"} {
+		if got, err := ParseCPUList(list); err == nil {
+			t.Errorf("ParseCPUList(%q) = %v, want an error", list, got)
+		}
+	}
+}
@@ -0,0 +1,124 @@
+package tuner
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"log/syslog"
+	"net"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ActionLogPath is the file log of every change made by the tool. Variable so
+// tests can point it to a temporary file.
+var ActionLogPath = "/var/log/vmware-tuner.log"
+
+// JournalEnabled sends the entries to the system journal (or syslog) as well
+// as to ActionLogPath. Tests turn it off.
+var JournalEnabled = true
+
+// journalSocket is the native systemd-journald protocol socket
+const journalSocket = "/run/systemd/journal/socket"
+
+// JournalIdentifier is the SYSLOG_IDENTIFIER of the tool's journal entries
+const JournalIdentifier = "vmware-tuner"
+
+// Action results recorded in the logs
+const (
+	ResultSuccess = "success"
+	ResultFailed  = "failed"
+	ResultSkipped = "skipped"
+)
+
+// LogAction records a configuration change in the file log and the system journal.
+// Entries carry VMWARE_TUNER_MODULE/ACTION/RESULT fields so SIEMs can filter them.
+// Logging is best-effort and never interrupts tuning.
+func LogAction(module, action, result, detail string) {
+	message := fmt.Sprintf("module=%s action=%s result=%s", module, action, result)
+	if detail != "" {
+		message += " " + detail
+	}
+
+	if f, err := os.OpenFile(ActionLogPath, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0640); err == nil {
+		fmt.Fprintf(f, "%s %s\n", time.Now().Format(time.RFC3339), message)
+		f.Close()
+	}
+
+	if !JournalEnabled {
+		return
+	}
+
+	priority := "6" // info
+	if result == ResultFailed {
+		priority = "3" // err
+	}
+
+	fields := map[string]string{
+		"MESSAGE":             message,
+		"PRIORITY":            priority,
+		"SYSLOG_IDENTIFIER":   JournalIdentifier,
+		"VMWARE_TUNER_MODULE": module,
+		"VMWARE_TUNER_ACTION": action,
+		"VMWARE_TUNER_RESULT": result,
+	}
+	if detail != "" {
+		fields["VMWARE_TUNER_DETAIL"] = detail
+	}
+
+	if err := sendJournal(fields); err != nil {
+		// No journald (containers, older init): fall back to plain syslog
+		if w, err := syslog.New(syslog.LOG_INFO|syslog.LOG_DAEMON, JournalIdentifier); err == nil {
+			if result == ResultFailed {
+				w.Err(message)
+			} else {
+				w.Info(message)
+			}
+			w.Close()
+		}
+	}
+}
+
+// sendJournal submits one entry over the native journald socket
+func sendJournal(fields map[string]string) error {
+	conn, err := net.Dial("unixgram", journalSocket)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	_, err = conn.Write(encodeJournalEntry(fields))
+	return err
+}
+
+// encodeJournalEntry serializes fields in the journald native format.
+// Values containing newlines use the length-prefixed binary form.
+func encodeJournalEntry(fields map[string]string) []byte {
+	var names []string
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var buf bytes.Buffer
+	for _, name := range names {
+		value := fields[name]
+		if !strings.Contains(value, "\n") {
+			fmt.Fprintf(&buf, "%s=%s\n", name, value)
+			continue
+		}
+		buf.WriteString(name + "\n")
+		binary.Write(&buf, binary.LittleEndian, uint64(len(value)))
+		buf.WriteString(value + "\n")
+	}
+	return buf.Bytes()
+}
+
+// ModifiesSystem reports whether running the module changes the system
+// (everything except read-only inspection modules)
+func ModifiesSystem(module string) bool {
+	m, ok := LookupModule(module)
+	return !ok || !m.ReadOnly
+}
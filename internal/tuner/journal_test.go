@@ -0,0 +1,24 @@
+package tuner
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+func TestEncodeJournalEntry(t *testing.T) {
+	got := encodeJournalEntry(map[string]string{
+		"SYSLOG_IDENTIFIER":   "vmware-tuner",
+		"VMWARE_TUNER_DETAIL": "line1\nline2",
+	})
+
+	var want bytes.Buffer
+	want.WriteString("SYSLOG_IDENTIFIER=vmware-tuner\n")
+	want.WriteString("VMWARE_TUNER_DETAIL\n")
+	binary.Write(&want, binary.LittleEndian, uint64(len("line1\nline2")))
+	want.WriteString("line1\nline2\n")
+
+	if !bytes.Equal(got, want.Bytes()) {
+		t.Errorf("encodeJournalEntry() = %q, want %q", got, want.Bytes())
+	}
+}
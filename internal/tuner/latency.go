@@ -0,0 +1,250 @@
+package tuner
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// LatencyCheck is a single item of the latency-sensitive guest checklist
+type LatencyCheck struct {
+	Name   string
+	Passed bool
+	Detail string
+	Fix    string
+}
+
+// LatencyHostSettings lists the vSphere-side settings that complement the guest checklist
+var LatencyHostSettings = []string{
+	"VM Options > Advanced > Latency Sensitivity = High",
+	"100% CPU reservation (all vCPUs at full clock speed)",
+	"100% memory reservation (required by Latency Sensitivity = High)",
+	"ethernetX.coalescingScheme = disabled (per vNIC, VM advanced settings)",
+	"Host BIOS power profile = High Performance (C1E/C-states disabled)",
+	"ESXi power management policy = High Performance",
+	"Keep vCPU count within one physical NUMA node",
+	"Avoid CPU over-commitment on the host running this VM",
+}
+
+// RunLatencyAudit runs the latency-sensitive VM checklist (VMware best practices, guest side)
+func (at *AuditTuner) RunLatencyAudit() error {
+	PrintStep("Latency-Sensitive VM Audit")
+
+	cmdline, _ := Sys.Cmdline()
+
+	checks := []LatencyCheck{
+		checkTickless(cmdline),
+		checkCStates(cmdline, cstateParams()),
+		checkClocksource(),
+		checkIRQAffinity(),
+		checkTHP(),
+		checkHugepages(),
+	}
+	checks = append(checks, checkNICLatency()...)
+
+	passed := 0
+	for _, c := range checks {
+		if c.Passed {
+			passed++
+			PrintSuccess("%s: %s", c.Name, c.Detail)
+		} else {
+			PrintWarning("%s: %s", c.Name, c.Detail)
+			if c.Fix != "" {
+				fmt.Printf("    -> %s\n", c.Fix)
+			}
+		}
+	}
+
+	PrintStep("Audit Result")
+	fmt.Printf("Guest checklist: %d/%d checks passed\n", passed, len(checks))
+
+	PrintStep("Required vSphere host/VM settings (vSphere admin)")
+	for _, s := range LatencyHostSettings {
+		fmt.Printf("  - %s\n", s)
+	}
+
+	return nil
+}
+
+// kernelConfig returns the content of the running kernel's build config
+func kernelConfig() string {
+	release, err := exec.Command("uname", "-r").Output()
+	if err != nil {
+		return ""
+	}
+	data, err := os.ReadFile(filepath.Join("/boot", "config-"+strings.TrimSpace(string(release))))
+	if err != nil {
+		return ""
+	}
+	return string(data)
+}
+
+func checkTickless(cmdline string) LatencyCheck {
+	c := LatencyCheck{Name: "Tickless kernel"}
+	config := kernelConfig()
+
+	switch {
+	case strings.Contains(cmdline, "nohz=off"):
+		c.Detail = "dynamic ticks disabled on the command line (nohz=off)"
+		c.Fix = "Remove nohz=off from the kernel command line"
+	case strings.Contains(config, "CONFIG_NO_HZ_FULL=y"):
+		c.Passed = true
+		c.Detail = "full dynticks supported (CONFIG_NO_HZ_FULL)"
+	case strings.Contains(config, "CONFIG_NO_HZ_IDLE=y") || strings.Contains(config, "CONFIG_NO_HZ=y"):
+		c.Passed = true
+		c.Detail = "idle dynticks enabled (CONFIG_NO_HZ_IDLE)"
+	case config == "":
+		c.Detail = "kernel config not readable, cannot confirm tickless mode"
+	default:
+		c.Detail = "periodic tick kernel"
+		c.Fix = "Use a distribution kernel built with CONFIG_NO_HZ_IDLE or CONFIG_NO_HZ_FULL"
+	}
+	return c
+}
+
+// cstateParams returns the C-state boot parameters of the selected profile
+func cstateParams() []string {
+	var params []string
+	for _, param := range (&GrubTuner{}).VMwareBootParams() {
+		if key := paramKey(param); key == "intel_idle.max_cstate" || key == "processor.max_cstate" {
+			params = append(params, param)
+		}
+	}
+	return params
+}
+
+// checkCStates checks the C-state limits the profile applies (want) are on
+// the kernel command line
+func checkCStates(cmdline string, want []string) LatencyCheck {
+	c := LatencyCheck{Name: "CPU C-states"}
+	if len(want) == 0 {
+		c.Passed = true
+		c.Detail = "left to the host (no C-state limit in the selected boot parameters)"
+		return c
+	}
+	present := make(map[string]bool)
+	for _, param := range strings.Fields(cmdline) {
+		present[param] = true
+	}
+	for _, param := range want {
+		if !present[param] {
+			c.Detail = "deep C-states allowed"
+			c.Fix = "Apply GRUB tuning (" + strings.Join(want, " ") + ")"
+			return c
+		}
+	}
+	c.Passed = true
+	c.Detail = "deep C-states limited (" + strings.Join(want, " ") + ")"
+	return c
+}
+
+func checkClocksource() LatencyCheck {
+	c := LatencyCheck{Name: "Clocksource"}
+	current, err := Sys.ReadString("/sys/devices/system/clocksource/clocksource0/current_clocksource")
+	if err != nil {
+		c.Detail = "could not read current clocksource"
+		return c
+	}
+	c.Detail = current
+	if current == "tsc" {
+		c.Passed = true
+		return c
+	}
+	c.Fix = "Use clocksource=tsc tsc=reliable on the kernel command line"
+	return c
+}
+
+func checkIRQAffinity() LatencyCheck {
+	c := LatencyCheck{Name: "IRQ affinity"}
+	if exec.Command("systemctl", "is-active", "irqbalance").Run() == nil {
+		c.Detail = "irqbalance is running (IRQs may migrate between vCPUs)"
+		c.Fix = "Pin NIC/storage IRQs to dedicated vCPUs and stop irqbalance (or use IRQBALANCE_BANNED_CPUS)"
+		return c
+	}
+	c.Passed = true
+	c.Detail = "irqbalance not running (static IRQ affinity)"
+	return c
+}
+
+func checkTHP() LatencyCheck {
+	c := LatencyCheck{Name: "Transparent hugepages"}
+	data, err := Sys.ReadFile("/sys/kernel/mm/transparent_hugepage/enabled")
+	if err != nil {
+		c.Detail = "THP setting not readable"
+		return c
+	}
+	current := selectedBracketValue(string(data))
+	c.Detail = current
+	if current == "always" {
+		c.Fix = "Set transparent_hugepage=madvise (or never) to avoid khugepaged compaction stalls"
+		return c
+	}
+	c.Passed = true
+	return c
+}
+
+func checkHugepages() LatencyCheck {
+	c := LatencyCheck{Name: "Static hugepages"}
+	meminfo, err := Sys.MemInfo()
+	if err != nil {
+		c.Detail = "could not read /proc/meminfo"
+		return c
+	}
+	if total := meminfo["HugePages_Total"]; total > 0 {
+		c.Passed = true
+		c.Detail = fmt.Sprintf("%d pages reserved", total)
+		return c
+	}
+	c.Detail = "no static hugepages reserved"
+	c.Fix = "Reserve hugepages (vm.nr_hugepages) if the application supports them"
+	return c
+}
+
+// checkNICLatency verifies LRO and interrupt coalescing are off on vmxnet3 NICs
+func checkNICLatency() []LatencyCheck {
+	var checks []LatencyCheck
+
+	interfaces, err := VMXNET3Interfaces(Sys.Root)
+	if err != nil {
+		return checks
+	}
+
+	dev := ioctlEthtool{}
+	for _, iface := range interfaces {
+		lro := LatencyCheck{Name: iface + " LRO"}
+		if on, err := dev.LRO(iface); err == nil && on {
+			lro.Detail = "enabled (adds receive latency)"
+			lro.Fix = "ethtool -K " + iface + " lro off"
+		} else {
+			lro.Passed = true
+			lro.Detail = "disabled"
+		}
+		checks = append(checks, lro)
+
+		coal := LatencyCheck{Name: iface + " interrupt coalescing"}
+		coal.Detail = "unknown"
+		if c, err := dev.Coalesce(iface); err == nil {
+			coal.Detail = fmt.Sprintf("rx-usecs %d", c.RxCoalesceUsecs)
+			coal.Passed = c.RxCoalesceUsecs == 0
+		}
+		if !coal.Passed {
+			coal.Fix = "Set ethernetX.coalescingScheme=disabled on the VM (or ethtool -C " + iface + " rx-usecs 0)"
+		}
+		checks = append(checks, coal)
+	}
+
+	return checks
+}
+
+// selectedBracketValue returns the value in [brackets] of a sysfs multi-choice file
+func selectedBracketValue(s string) string {
+	s = strings.TrimSpace(s)
+	if start := strings.Index(s, "["); start != -1 {
+		if end := strings.Index(s[start:], "]"); end != -1 {
+			return s[start+1 : start+end]
+		}
+	}
+	return s
+}
@@ -0,0 +1,93 @@
+package tuner
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// limitsContent raises the open file limits of login sessions (PAM)
+const limitsContent = `# Generated by vmware-tuner: open file limits for servers
+*    soft nofile 65536
+*    hard nofile 1048576
+root soft nofile 65536
+root hard nofile 1048576
+`
+
+// systemdLimitsContent raises the same limits for services, which PAM does not cover
+const systemdLimitsContent = `# Generated by vmware-tuner: open file limits for services
+[Manager]
+DefaultLimitNOFILE=65536:1048576
+`
+
+// LimitsTuner raises the open file limits of sessions and services: the
+// defaults (1024) are too low for databases, proxies and web servers
+type LimitsTuner struct {
+	DryRun      bool
+	LimitsPath  string
+	SystemdPath string
+}
+
+// NewLimitsTuner creates a new limits tuner
+func NewLimitsTuner(dryRun bool) *LimitsTuner {
+	return &LimitsTuner{
+		DryRun:      dryRun,
+		LimitsPath:  "/etc/security/limits.d/90-vmware-tuner.conf",
+		SystemdPath: "/etc/systemd/system.conf.d/90-vmware-tuner-limits.conf",
+	}
+}
+
+func init() {
+	Register(Module{
+		Name:        "limits",
+		Description: "Open file limits",
+		Category:    CategoryTuning,
+		Pipeline:    11,
+		RequireRoot: true,
+		Flag:        &ModuleFlag{Name: "with-limits", Usage: "Raise the open file limits of sessions and services"},
+		Apply: func(ctx *ModuleContext) error {
+			return NewLimitsTuner(ctx.DryRun).Apply(ctx.Backup)
+		},
+	})
+}
+
+// Apply writes the limits files (--with-limits)
+func (lt *LimitsTuner) Apply(backup *BackupManager) error {
+	PrintStep("Open File Limits")
+
+	files := []struct {
+		Path, Content, Why string
+	}{
+		{lt.LimitsPath, limitsContent, "raise the open file limit of login sessions (PAM)"},
+		{lt.SystemdPath, systemdLimitsContent, "raise the open file limit of services started by systemd"},
+	}
+
+	changed := false
+	for _, f := range files {
+		if current, err := os.ReadFile(f.Path); err == nil && string(current) == f.Content {
+			PrintSuccess("%s already configured", f.Path)
+			continue
+		}
+		if lt.DryRun {
+			PrintInfo("Would create: %s", f.Path)
+			continue
+		}
+		if err := backup.BackupFile(f.Path); err != nil {
+			return fmt.Errorf("failed to backup %s: %w", f.Path, err)
+		}
+		if err := os.MkdirAll(filepath.Dir(f.Path), 0755); err != nil {
+			return fmt.Errorf("failed to create %s: %w", filepath.Dir(f.Path), err)
+		}
+		ExplainEdit(f.Why, f.Path)
+		if err := os.WriteFile(f.Path, []byte(f.Content), 0644); err != nil {
+			return fmt.Errorf("failed to write %s: %w", f.Path, err)
+		}
+		PrintSuccess("Created %s", f.Path)
+		changed = true
+	}
+
+	if changed {
+		PrintInfo("New limits apply to new sessions, and to services after a reboot or 'systemctl daemon-reexec'")
+	}
+	return nil
+}
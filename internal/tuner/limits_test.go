@@ -0,0 +1,33 @@
+package tuner
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLimitsApply(t *testing.T) {
+	dir := t.TempDir()
+	lt := &LimitsTuner{
+		LimitsPath:  filepath.Join(dir, "limits.d", "90-vmware-tuner.conf"),
+		SystemdPath: filepath.Join(dir, "system.conf.d", "90-vmware-tuner-limits.conf"),
+	}
+	bm := &BackupManager{BackupDir: filepath.Join(dir, "backup")}
+	if err := bm.Initialize(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := lt.Apply(bm); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(lt.SystemdPath); string(data) != systemdLimitsContent {
+		t.Errorf("systemd drop-in = %q", data)
+	}
+	m, err := LoadManifest(bm.BackupDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(m.Entries) != 2 || !m.Entries[0].Created {
+		t.Errorf("new files not recorded for rollback: %+v", m.Entries)
+	}
+}
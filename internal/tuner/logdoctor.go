@@ -1,93 +1,348 @@
 package tuner
 
 import (
+	"bufio"
 	"fmt"
+	"io"
 	"os"
-	"os/exec"
+	"path/filepath"
+	"strconv"
 	"strings"
+	"time"
 )
 
+// Log Doctor limits: the logs are streamed, only these lines are kept
+const (
+	logDoctorKernelRecords = 1000  // last kernel messages scanned without --since
+	logDoctorKernelMax     = 20000 // with --since (more than the ring buffer holds)
+	DefaultLogDoctorLimit  = 50    // findings shown per log
+)
+
+// LogSeverities are the severities of the findings, lowest first
+var LogSeverities = []string{"warning", "error", "critical"}
+
+// logKeyword is a message the Log Doctor looks for (case-insensitive)
+type logKeyword struct {
+	Text     string
+	Severity string
+	Finding  string // kind of finding for the remediation suggestions ("" for none)
+}
+
+// logKeywords are checked in order: a line is reported once, with the first
+// keyword it contains
+var logKeywords = []logKeyword{
+	{"Out of memory", "critical", FindingOOMKill},
+	{"Kill process", "critical", FindingOOMKill},
+	{"Call Trace", "critical", ""},
+	{"soft lockup", "critical", ""},
+	{"EXT4-fs error", "critical", FindingIOError},
+	{"XFS_WANT_CORRUPT", "critical", FindingIOError},
+	{"I/O error", "error", FindingIOError},
+	{"SCSI error", "error", FindingIOError},
+	{"segfault", "error", ""},
+	{"blocked for more than", "warning", FindingIOError}, // hung tasks: storage stalls
+	{"task abort", "warning", FindingIOError},            // PVSCSI aborts on a slow datastore
+	{"System clock wrong", "warning", FindingTimeDrift},  // chronyd after a suspend or a vMotion
+	{"as unstable", "warning", FindingTimeDrift},         // "Marking clocksource 'tsc' as unstable"
+}
+
+// severityRank returns the position of a severity in LogSeverities (-1: unknown)
+func severityRank(severity string) int {
+	for i, s := range LogSeverities {
+		if s == severity {
+			return i
+		}
+	}
+	return -1
+}
+
+// ParseSince reads --since: a duration back from now (24h, 90m) or a date
+// (2006-01-02, 2006-01-02 15:04)
+func ParseSince(value string, now time.Time) (time.Time, error) {
+	if d, err := time.ParseDuration(value); err == nil && d > 0 {
+		return now.Add(-d), nil
+	}
+	for _, layout := range []string{"2006-01-02 15:04:05", "2006-01-02 15:04", "2006-01-02"} {
+		if t, err := time.ParseInLocation(layout, value, now.Location()); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid --since %q: use a duration (24h) or a date (2006-01-02 15:04)", value)
+}
+
+// kernelLineTime returns the time of a "[ 5.140900] message" kernel line
+func kernelLineTime(line string, boot time.Time) (time.Time, bool) {
+	end := strings.Index(line, "]")
+	if !strings.HasPrefix(line, "[") || end < 0 || boot.IsZero() {
+		return time.Time{}, false
+	}
+	seconds, err := strconv.ParseFloat(strings.TrimSpace(line[1:end]), 64)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return boot.Add(time.Duration(seconds * float64(time.Second))), true
+}
+
+// syslogLineTime returns the time of a syslog line: RFC 3339 (rsyslog high
+// precision format) or the traditional "Jan  2 15:04:05" without a year
+func syslogLineTime(line string, now time.Time) (time.Time, bool) {
+	if field, _, _ := strings.Cut(line, " "); len(field) >= 19 && field[4] == '-' {
+		if t, err := time.Parse(time.RFC3339Nano, field); err == nil {
+			return t, true
+		}
+	}
+	if len(line) < len(time.Stamp) {
+		return time.Time{}, false
+	}
+	t, err := time.ParseInLocation(time.Stamp, line[:len(time.Stamp)], now.Location())
+	if err != nil {
+		return time.Time{}, false
+	}
+	t = t.AddDate(now.Year(), 0, 0)
+	if t.After(now.Add(24 * time.Hour)) {
+		t = t.AddDate(-1, 0, 0) // December lines read in January
+	}
+	return t, true
+}
+
 // LogDoctorTuner handles log scanning
 type LogDoctorTuner struct {
-	Distro *DistroManager
+	Distro     *DistroManager
+	Since      time.Time // zero: last kernel messages, journal of this boot, whole syslog
+	Severity   string    // lowest severity reported
+	Limit      int       // findings shown per log, 0 for all
+	OutputPath string    // every finding is written there ("" for none)
 }
 
 // NewLogDoctorTuner creates a new log doctor
 func NewLogDoctorTuner(distro *DistroManager) *LogDoctorTuner {
 	return &LogDoctorTuner{
-		Distro: distro,
+		Distro:     distro,
+		Severity:   LogSeverities[0],
+		Limit:      DefaultLogDoctorLimit,
+		OutputPath: filepath.Join(StateDir, "logdoctor-findings.txt"),
+	}
+}
+
+func init() {
+	Register(Module{
+		Name:        "logdoctor",
+		Label:       "Scan Logs for Errors",
+		Description: "Scan the kernel log and the journal for known errors",
+		Category:    CategoryDiagnostics,
+		Menu:        14,
+		RequireRoot: true,
+		ReadOnly:    true,
+		Run: func(ctx *ModuleContext) error {
+			return NewLogDoctorTuner(ctx.Distro).Run()
+		},
+	})
+}
+
+// logScan collects the findings of one log: all of them go to the findings
+// file, the last Limit are kept for the screen
+type logScan struct {
+	Source string
+	Total  int
+	Counts map[string]int // per severity
+	shown  *lineRing
+	all    []string // Limit 0
+	out    io.Writer
+}
+
+// newScan starts the scan of a log
+func (ld *LogDoctorTuner) newScan(source string, out io.Writer) *logScan {
+	scan := &logScan{Source: source, Counts: make(map[string]int), out: out}
+	if ld.Limit > 0 {
+		scan.shown = newLineRing(ld.Limit)
+	}
+	return scan
+}
+
+// Match returns the keyword of a line at or above the severity of the run
+func (ld *LogDoctorTuner) Match(line string) (logKeyword, bool) {
+	lower := strings.ToLower(line)
+	min := severityRank(ld.Severity)
+	for _, kw := range logKeywords {
+		if severityRank(kw.Severity) >= min && strings.Contains(lower, strings.ToLower(kw.Text)) {
+			return kw, true
+		}
+	}
+	return logKeyword{}, false
+}
+
+// add records a line when it is a finding
+func (ld *LogDoctorTuner) add(scan *logScan, line string) {
+	kw, ok := ld.Match(line)
+	if !ok {
+		return
+	}
+	finding := fmt.Sprintf("[%s] %s", kw.Severity, line)
+	if kw.Finding != "" {
+		RecordFinding(kw.Finding, scan.Source, line)
 	}
+	scan.Total++
+	scan.Counts[kw.Severity]++
+	if scan.out != nil {
+		fmt.Fprintf(scan.out, "%s: %s\n", scan.Source, finding)
+	}
+	if scan.shown != nil {
+		scan.shown.Add(finding)
+	} else {
+		scan.all = append(scan.all, finding)
+	}
+}
+
+// lines returns the report of the scan for the screen
+func (scan *logScan) lines() []string {
+	shown := scan.all
+	if scan.shown != nil {
+		shown = scan.shown.Lines()
+	}
+	header := fmt.Sprintf("== %s: %d finding(s)", scan.Source, scan.Total)
+	if len(shown) < scan.Total {
+		header += fmt.Sprintf(", last %d shown", len(shown))
+	}
+	return append([]string{"", header + " =="}, shown...)
+}
+
+// openFindings creates the findings file, in the temporary directory when the
+// state directory is not writable
+func (ld *LogDoctorTuner) openFindings() (*os.File, string) {
+	if ld.OutputPath == "" {
+		return nil, ""
+	}
+	for _, path := range []string{ld.OutputPath, filepath.Join(os.TempDir(), filepath.Base(ld.OutputPath))} {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			continue
+		}
+		if f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600); err == nil {
+			return f, path
+		}
+	}
+	PrintWarning("Could not create %s: the findings are only shown", ld.OutputPath)
+	return nil, ""
+}
+
+// scanKernel scans the kernel ring buffer
+func (ld *LogDoctorTuner) scanKernel(scan *logScan) error {
+	// Last records only, to avoid noise from boot time if uptime is long
+	max := logDoctorKernelRecords
+	if !ld.Since.IsZero() {
+		max = logDoctorKernelMax
+	}
+	lines, err := ReadKernelLog(max)
+	boot := bootTime()
+	for _, line := range lines {
+		if !ld.Since.IsZero() {
+			if t, ok := kernelLineTime(line, boot); ok && t.Before(ld.Since) {
+				continue
+			}
+		}
+		ld.add(scan, line)
+	}
+	return err
+}
+
+// scanSyslog streams a syslog file
+func (ld *LogDoctorTuner) scanSyslog(scan *logScan, r io.Reader) error {
+	now := time.Now()
+	return ScanLines(r, maxLineBytes, func(line string) {
+		if !ld.Since.IsZero() {
+			if t, ok := syslogLineTime(line, now); ok && t.Before(ld.Since) {
+				return
+			}
+		}
+		ld.add(scan, line)
+	})
+}
+
+// scanJournal streams the journal, of this boot unless Since is set
+func (ld *LogDoctorTuner) scanJournal(scan *logScan) error {
+	args := []string{"--no-pager", "-o", "short-iso"}
+	if ld.Since.IsZero() {
+		args = append(args, "-b")
+	} else {
+		args = append(args, "--since", ld.Since.Format("2006-01-02 15:04:05"))
+	}
+	return ScanCommandLines(func(line string) { ld.add(scan, line) }, "journalctl", args...)
 }
 
 // Run performs the log scan
 func (ld *LogDoctorTuner) Run() error {
 	PrintStep("Log Doctor (Troubleshoot)")
-
-	keywords := []string{
-		"Out of memory",
-		"Kill process",
-		"I/O error",
-		"SCSI error",
-		"Call Trace",
-		"soft lockup",
-		"segfault",
-		"EXT4-fs error",
-		"XFS_WANT_CORRUPT",
+	if severityRank(ld.Severity) < 0 {
+		return fmt.Errorf("%w: unknown severity %q (%s)", ErrValidationFailed, ld.Severity, strings.Join(LogSeverities, ", "))
+	}
+	if !ld.Since.IsZero() {
+		PrintInfo("Messages since %s", ld.Since.Format("2006-01-02 15:04"))
 	}
 
-	foundIssues := false
+	f, findingsPath := ld.openFindings()
+	var findings io.Writer
+	if f != nil {
+		defer f.Close()
+		buffered := bufio.NewWriter(f)
+		defer buffered.Flush()
+		findings = buffered
+	}
 
 	// 1. Check dmesg (Kernel Ring Buffer)
 	PrintInfo("Scanning kernel ring buffer (dmesg)...")
-	out, err := exec.Command("dmesg").Output()
-	if err == nil {
-		lines := strings.Split(string(out), "\n")
-		// Check last 1000 lines to avoid noise from boot time if uptime is long
-		start := 0
-		if len(lines) > 1000 {
-			start = len(lines) - 1000
-		}
-		
-		for i := start; i < len(lines); i++ {
-			line := lines[i]
-			for _, kw := range keywords {
-				if strings.Contains(line, kw) {
-					PrintWarning("Found in dmesg: %s", line)
-					foundIssues = true
-				}
-			}
-		}
+	kernel := ld.newScan("kernel", findings)
+	if err := ld.scanKernel(kernel); err != nil {
+		PrintWarning("Could not read the kernel log: %v", err)
 	}
 
 	// 2. Check System Log
 	logFile := "/var/log/syslog"
-	if ld.Distro.Type == DistroRHEL {
+	if ld.Distro != nil && ld.Distro.Type == DistroRHEL {
 		logFile = "/var/log/messages"
 	}
 
-	PrintInfo("Scanning system log (%s)...", logFile)
-	if _, err := os.Stat(logFile); err == nil {
-		// Use grep for efficiency
-		for _, kw := range keywords {
-			// grep -i "keyword" /var/log/syslog | tail -n 5
-			cmd := exec.Command("bash", "-c", fmt.Sprintf("grep -i \"%s\" %s | tail -n 5", kw, logFile))
-			out, err := cmd.Output()
-			if err == nil && len(out) > 0 {
-				PrintWarning("Found '%s' errors:", kw)
-				fmt.Println(string(out))
-				foundIssues = true
-			}
+	var system *logScan
+	if file, err := os.Open(logFile); err == nil {
+		PrintInfo("Scanning system log (%s)...", logFile)
+		system = ld.newScan(logFile, findings)
+		if err := ld.scanSyslog(system, file); err != nil {
+			PrintWarning("Could not read %s: %v", logFile, err)
 		}
+		file.Close()
 	} else {
-		PrintInfo("Log file not found: %s", logFile)
+		// journald only (no rsyslog)
+		PrintInfo("Log file not found: %s, scanning the journal...", logFile)
+		system = ld.newScan("journal", findings)
+		if err := ld.scanJournal(system); err != nil {
+			PrintWarning("Could not read the journal: %v", err)
+		}
 	}
 
-	if !foundIssues {
-		PrintSuccess("No critical errors found in recent logs.")
-	} else {
-		fmt.Println()
-		PrintInfo("Issues were found. Please investigate the logs further.")
+	total := kernel.Total + system.Total
+	if total == 0 {
+		PrintSuccess("No %s or worse messages found in recent logs.", ld.Severity)
+		return nil
+	}
+
+	var report []string
+	for _, scan := range []*logScan{kernel, system} {
+		if scan.Total > 0 {
+			report = append(report, scan.lines()...)
+		}
 	}
+	PageLines(report)
 
+	fmt.Println()
+	var counts []string
+	for i := len(LogSeverities) - 1; i >= 0; i-- {
+		severity := LogSeverities[i]
+		if n := kernel.Counts[severity] + system.Counts[severity]; n > 0 {
+			counts = append(counts, fmt.Sprintf("%d %s", n, severity))
+		}
+	}
+	PrintWarning("%d finding(s): %s", total, strings.Join(counts, ", "))
+	if findingsPath != "" {
+		PrintInfo("Full findings: %s", findingsPath)
+	}
+	PrintInfo("Issues were found. Please investigate the logs further.")
 	return nil
 }
@@ -0,0 +1,116 @@
+package tuner
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseSince(t *testing.T) {
+	now := time.Date(2024, 3, 10, 12, 0, 0, 0, time.UTC)
+	for value, want := range map[string]time.Time{
+		"24h":              now.Add(-24 * time.Hour),
+		"90m":              now.Add(-90 * time.Minute),
+		"2024-03-01":       time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC),
+		"2024-03-09 18:30": time.Date(2024, 3, 9, 18, 30, 0, 0, time.UTC),
+	} {
+		got, err := ParseSince(value, now)
+		if err != nil || !got.Equal(want) {
+			t.Errorf("ParseSince(%q) = %v, %v; want %v", value, got, err, want)
+		}
+	}
+	for _, bad := range []string{"yesterday", "-1h", "03/01/2024"} {
+		if _, err := ParseSince(bad, now); err == nil {
+			t.Errorf("ParseSince(%q) should fail", bad)
+		}
+	}
+}
+
+func TestLogLineTime(t *testing.T) {
+	now := time.Date(2024, 1, 2, 12, 0, 0, 0, time.UTC)
+	if got, ok := syslogLineTime("Dec 31 23:59:00 host kernel: I/O error", now); !ok || got.Year() != 2023 {
+		t.Errorf("December line read in January: %v %v", got, ok)
+	}
+	if got, ok := syslogLineTime("2024-01-02T10:00:00.123456+00:00 host app: segfault", now); !ok || got.Hour() != 10 {
+		t.Errorf("RFC 3339 line: %v %v", got, ok)
+	}
+	if _, ok := syslogLineTime("garbage", now); ok {
+		t.Error("a line without a timestamp has no time")
+	}
+
+	boot := time.Date(2024, 1, 2, 8, 0, 0, 0, time.UTC)
+	if got, ok := kernelLineTime("[ 3600.500000] sd 2:0:0:0: task abort", boot); !ok || !got.Equal(boot.Add(3600*time.Second+500*time.Millisecond)) {
+		t.Errorf("kernel line: %v %v", got, ok)
+	}
+}
+
+func TestLogDoctorScan(t *testing.T) {
+	now := time.Now()
+	old := now.Add(-48 * time.Hour).Format(time.RFC3339)
+	recent := now.Add(-time.Hour).Format(time.RFC3339)
+	log := strings.Join([]string{
+		old + " host kernel: Out of memory: Killed process 42",
+		recent + " host kernel: sd 0:0:0:0: [sda] i/o ERROR, dev sda",
+		recent + " host kernel: INFO: task jbd2 blocked for more than 120 seconds",
+		recent + " host kernel: Out of memory: Killed process 43",
+		recent + " host systemd[1]: Started Session 1",
+	}, "\n")
+
+	ld := NewLogDoctorTuner(nil)
+	var file strings.Builder
+	scan := ld.newScan("syslog", &file)
+	if err := ld.scanSyslog(scan, strings.NewReader(log)); err != nil {
+		t.Fatal(err)
+	}
+	if scan.Total != 4 || scan.Counts["critical"] != 2 || scan.Counts["error"] != 1 || scan.Counts["warning"] != 1 {
+		t.Errorf("unexpected counts: %d %v", scan.Total, scan.Counts)
+	}
+
+	// --since 24h --severity error --limit 1
+	ld.Since, ld.Severity, ld.Limit = now.Add(-24*time.Hour), "error", 1
+	file.Reset()
+	scan = ld.newScan("syslog", &file)
+	if err := ld.scanSyslog(scan, strings.NewReader(log)); err != nil {
+		t.Fatal(err)
+	}
+	if scan.Total != 2 || strings.Count(file.String(), "\n") != 2 {
+		t.Errorf("expected the recent I/O error and OOM in the file, got %d:\n%s", scan.Total, file.String())
+	}
+	lines := scan.lines()
+	if len(lines) != 3 || !strings.Contains(lines[1], "2 finding(s), last 1 shown") || !strings.Contains(lines[2], "[critical]") || !strings.Contains(lines[2], "process 43") {
+		t.Errorf("unexpected screen report: %q", lines)
+	}
+}
+
+func TestLogDoctorFindingsFile(t *testing.T) {
+	ld := NewLogDoctorTuner(nil)
+	ld.OutputPath = filepath.Join(t.TempDir(), "state", "findings.txt")
+	f, path := ld.openFindings()
+	if f == nil || path != ld.OutputPath {
+		t.Fatalf("findings file not created at %s: %s", ld.OutputPath, path)
+	}
+	f.Close()
+	if _, err := os.Stat(path); err != nil {
+		t.Error(err)
+	}
+}
+
+func BenchmarkLogDoctorScan(b *testing.B) {
+	var sb strings.Builder
+	for i := 0; i < 100000; i++ {
+		fmt.Fprintf(&sb, "2024-01-01T00:00:00+00:00 host systemd[1]: Started session %d of user root.\n", i)
+	}
+	journal := sb.String()
+	ld := NewLogDoctorTuner(nil)
+	b.SetBytes(int64(len(journal)))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := ld.scanSyslog(ld.newScan("journal", nil), strings.NewReader(journal)); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
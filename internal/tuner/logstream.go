@@ -0,0 +1,185 @@
+package tuner
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	"golang.org/x/sys/unix"
+)
+
+// Streaming readers for inputs of unbounded size (journal, kernel log,
+// generated fstab files): lines are handled one at a time and only a bounded
+// number of them is kept.
+
+// maxLineBytes is the longest line kept by ScanLines, the rest is dropped
+const maxLineBytes = 64 * 1024
+
+// kmsgPath is the kernel log device, read one record at a time
+var kmsgPath = "/dev/kmsg"
+
+// kmsgRecordMax is the read buffer of /dev/kmsg: a read smaller than the next
+// record fails with EINVAL
+const kmsgRecordMax = 8192
+
+// ScanLines calls fn for every line of r. Unlike bufio.Scanner, a line longer
+// than max bytes is cut instead of stopping the scan.
+func ScanLines(r io.Reader, max int, fn func(line string)) error {
+	reader := bufio.NewReaderSize(r, 4096)
+	var line []byte
+	for {
+		chunk, err := reader.ReadSlice('\n')
+		if room := max - len(line); room > 0 {
+			if len(chunk) > room {
+				line = append(line, chunk[:room]...)
+			} else {
+				line = append(line, chunk...)
+			}
+		}
+		if errors.Is(err, bufio.ErrBufferFull) {
+			continue
+		}
+		if len(line) > 0 || err == nil {
+			fn(strings.TrimRight(string(line), "\r\n"))
+		}
+		line = line[:0]
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// lineRing keeps the last lines of a stream
+type lineRing struct {
+	lines []string
+	next  int
+	full  bool
+}
+
+// newLineRing creates a ring keeping the last n lines
+func newLineRing(n int) *lineRing {
+	return &lineRing{lines: make([]string, n)}
+}
+
+// Add keeps line, dropping the oldest one when the ring is full
+func (lr *lineRing) Add(line string) {
+	if len(lr.lines) == 0 {
+		return
+	}
+	lr.lines[lr.next] = line
+	lr.next = (lr.next + 1) % len(lr.lines)
+	if lr.next == 0 {
+		lr.full = true
+	}
+}
+
+// Lines returns the kept lines, oldest first
+func (lr *lineRing) Lines() []string {
+	if !lr.full {
+		return append([]string(nil), lr.lines[:lr.next]...)
+	}
+	return append(append([]string(nil), lr.lines[lr.next:]...), lr.lines[:lr.next]...)
+}
+
+// ScanCommandLines calls fn for every line a command prints, as it prints them
+func ScanCommandLines(fn func(line string), name string, args ...string) error {
+	cmd := exec.Command(name, args...)
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return fmt.Errorf("failed to run %s: %w", name, err)
+	}
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("failed to run %s: %w", name, err)
+	}
+	scanErr := ScanLines(stdout, maxLineBytes, fn)
+	if err := cmd.Wait(); err != nil {
+		return fmt.Errorf("%s failed: %w", name, err)
+	}
+	return scanErr
+}
+
+// kmsgReader reads /dev/kmsg without blocking: one record per read, EAGAIN
+// once the buffer is drained
+type kmsgReader struct {
+	fd      int
+	buf     []byte
+	pending []byte
+}
+
+// Read implements io.Reader
+func (kr *kmsgReader) Read(p []byte) (int, error) {
+	for len(kr.pending) == 0 {
+		n, err := unix.Read(kr.fd, kr.buf)
+		switch {
+		case err == unix.EAGAIN:
+			return 0, io.EOF
+		case err == unix.EPIPE, err == unix.EINTR:
+			continue // records overwritten while reading: go on from the oldest left
+		case err != nil:
+			return 0, err
+		case n == 0:
+			return 0, io.EOF
+		}
+		kr.pending = kr.buf[:n]
+	}
+	n := copy(p, kr.pending)
+	kr.pending = kr.pending[n:]
+	return n, nil
+}
+
+// parseKmsgRecord formats a /dev/kmsg record ("6,339,5140900,-;message") like
+// dmesg. Continuation lines (" KEY=value") are not messages.
+func parseKmsgRecord(record string) (string, bool) {
+	prefix, message, ok := strings.Cut(record, ";")
+	if !ok || strings.HasPrefix(record, " ") {
+		return "", false
+	}
+	fields := strings.Split(prefix, ",")
+	if len(fields) < 3 {
+		return "", false
+	}
+	usec, err := strconv.ParseInt(fields[2], 10, 64)
+	if err != nil {
+		return "", false
+	}
+	return fmt.Sprintf("[%5d.%06d] %s", usec/1000000, usec%1000000, message), true
+}
+
+// readKmsg streams the records of a kmsg file into ring
+func readKmsg(path string, ring *lineRing) error {
+	fd, err := unix.Open(path, unix.O_RDONLY|unix.O_NONBLOCK|unix.O_CLOEXEC, 0)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", path, err)
+	}
+	defer unix.Close(fd)
+
+	reader := &kmsgReader{fd: fd, buf: make([]byte, kmsgRecordMax)}
+	return ScanLines(reader, kmsgRecordMax, func(line string) {
+		if message, ok := parseKmsgRecord(line); ok {
+			ring.Add(message)
+		}
+	})
+}
+
+// ReadKernelLog returns the last max messages of the kernel ring buffer. It
+// streams /dev/kmsg, or the output of dmesg when the device cannot be read
+// (kernel.dmesg_restrict, containers).
+func ReadKernelLog(max int) ([]string, error) {
+	ring := newLineRing(max)
+	if err := readKmsg(kmsgPath, ring); err == nil {
+		return ring.Lines(), nil
+	}
+
+	ring = newLineRing(max)
+	if err := ScanCommandLines(ring.Add, "dmesg"); err != nil {
+		return nil, err
+	}
+	return ring.Lines(), nil
+}
@@ -0,0 +1,55 @@
+package tuner
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestScanLines(t *testing.T) {
+	input := "short\n" + strings.Repeat("y", 10000) + "\n\nlast"
+	var lines []string
+	if err := ScanLines(strings.NewReader(input), 100, func(line string) { lines = append(lines, line) }); err != nil {
+		t.Fatal(err)
+	}
+	if len(lines) != 4 || lines[0] != "short" || len(lines[1]) != 100 || lines[2] != "" || lines[3] != "last" {
+		t.Errorf("unexpected lines: %d %q", len(lines), lines[0])
+	}
+}
+
+func TestLineRing(t *testing.T) {
+	ring := newLineRing(3)
+	ring.Add("a")
+	ring.Add("b")
+	if got := ring.Lines(); !reflect.DeepEqual(got, []string{"a", "b"}) {
+		t.Errorf("got %v", got)
+	}
+	for _, l := range []string{"c", "d", "e"} {
+		ring.Add(l)
+	}
+	if got := ring.Lines(); !reflect.DeepEqual(got, []string{"c", "d", "e"}) {
+		t.Errorf("got %v, want the last 3 lines", got)
+	}
+}
+
+func TestReadKmsg(t *testing.T) {
+	records := "6,1,1500000,-;Linux version 6.1.0\n" +
+		" SUBSYSTEM=cpu\n" +
+		"3,2,5140900,-;sd 2:0:0:0: [sda] I/O error\n" +
+		"4,3,7000001,-;EXT4-fs error (device sda1)\n"
+	path := filepath.Join(t.TempDir(), "kmsg")
+	if err := os.WriteFile(path, []byte(records), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	ring := newLineRing(2)
+	if err := readKmsg(path, ring); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"[    5.140900] sd 2:0:0:0: [sda] I/O error", "[    7.000001] EXT4-fs error (device sda1)"}
+	if got := ring.Lines(); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
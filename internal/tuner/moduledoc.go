@@ -0,0 +1,483 @@
+package tuner
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+)
+
+// HelpLanguages are the languages of the module documentation, default first
+var HelpLanguages = []string{"en", "fr"}
+
+// Text is a message in several languages, by language code
+type Text map[string]string
+
+// In returns the message in a language, in English when it is not translated
+func (t Text) In(lang string) string {
+	if s, ok := t[lang]; ok {
+		return s
+	}
+	return t["en"]
+}
+
+// ModuleDoc documents what a module changes: shown by `vmware-tuner help
+// <module>`, with the plan of apply-all and in the report
+type ModuleDoc struct {
+	Title    Text
+	Summary  Text
+	Changes  []Text
+	Files    []string // files written or edited
+	Risks    []Text
+	Rollback Text
+}
+
+// docHeadings are the sections of a module page
+var docHeadings = map[string]Text{
+	"name":     {"en": "NAME", "fr": "NOM"},
+	"desc":     {"en": "DESCRIPTION", "fr": "DESCRIPTION"},
+	"changes":  {"en": "CHANGES", "fr": "MODIFICATIONS"},
+	"files":    {"en": "FILES", "fr": "FICHIERS"},
+	"risks":    {"en": "RISKS", "fr": "RISQUES"},
+	"rollback": {"en": "ROLLBACK", "fr": "RETOUR ARRIÈRE"},
+	"none":     {"en": "None", "fr": "Aucun"},
+	"modules":  {"en": "Documented modules", "fr": "Modules documentés"},
+}
+
+// rollbackBackup is the rollback of the modules editing files in a backup session
+var rollbackBackup = Text{
+	"en": "vmware-tuner rollback restores the files from the backup session taken before the change (menu [2]).",
+	"fr": "vmware-tuner rollback restaure les fichiers depuis la sauvegarde prise avant la modification (menu [2]).",
+}
+
+// moduleDocs document the modules changing the system, by module name.
+// Inspection modules change nothing and have no page.
+var moduleDocs = map[string]ModuleDoc{
+	"grub": {
+		Title:   Text{"en": "Boot parameters", "fr": "Paramètres de démarrage"},
+		Summary: Text{"en": "Adds the VMware boot parameters to the kernel command line (GRUB_CMDLINE_LINUX_DEFAULT) and regenerates the GRUB configuration.", "fr": "Ajoute les paramètres de démarrage VMware à la ligne de commande du noyau (GRUB_CMDLINE_LINUX_DEFAULT) et régénère la configuration GRUB."},
+		Changes: []Text{
+			{"en": "Adds the parameters of the profile (or tuning.grub.params), keeps those set by admins", "fr": "Ajoute les paramètres du profil (ou tuning.grub.params), garde ceux posés par les administrateurs"},
+			{"en": "Runs update-grub or grub2-mkconfig (grubby on BLS systems)", "fr": "Lance update-grub ou grub2-mkconfig (grubby sur les systèmes BLS)"},
+		},
+		Files: []string{"/etc/default/grub", "/boot/grub/grub.cfg", "/boot/grub2/grub.cfg"},
+		Risks: []Text{
+			{"en": "Takes effect at the next reboot; a wrong parameter can keep the VM from booting (keep a snapshot)", "fr": "Pris en compte au prochain redémarrage ; un mauvais paramètre peut empêcher le démarrage (garder un snapshot)"},
+		},
+		Rollback: Text{"en": "vmware-tuner grub reset removes only the parameters added by the tool; vmware-tuner rollback restores /etc/default/grub.", "fr": "vmware-tuner grub reset retire uniquement les paramètres ajoutés par l'outil ; vmware-tuner rollback restaure /etc/default/grub."},
+	},
+	"sysctl": {
+		Title:   Text{"en": "Kernel parameters", "fr": "Paramètres du noyau"},
+		Summary: Text{"en": "Writes the memory, network and scheduler sysctl values sized for the VM and loads them.", "fr": "Écrit les valeurs sysctl mémoire, réseau et ordonnanceur dimensionnées pour la VM et les charge."},
+		Changes: []Text{
+			{"en": "vm.swappiness, dirty ratios, TCP buffers and backlog, sized from the RAM and the vCPUs", "fr": "vm.swappiness, ratios dirty, tampons et file TCP, dimensionnés selon la RAM et les vCPU"},
+			{"en": "Keys in tuning.sysctl_exclude are commented out, overrides from tuning.sysctl applied", "fr": "Les clés de tuning.sysctl_exclude sont commentées, les surcharges de tuning.sysctl appliquées"},
+		},
+		Files: []string{"/etc/sysctl.d/99-vmware-performance.conf"},
+		Risks: []Text{
+			{"en": "Applied immediately (sysctl --system); a later file in /etc/sysctl.d can override a value (reported as a conflict)", "fr": "Appliqué immédiatement (sysctl --system) ; un fichier chargé après dans /etc/sysctl.d peut écraser une valeur (signalé comme conflit)"},
+		},
+		Rollback: rollbackBackup,
+	},
+	"fstab": {
+		Title:   Text{"en": "Mount options", "fr": "Options de montage"},
+		Summary: Text{"en": "Adds noatime and the options of tuning.fstab.options to the ext4 and XFS entries of /etc/fstab and remounts them.", "fr": "Ajoute noatime et les options de tuning.fstab.options aux entrées ext4 et XFS de /etc/fstab et les remonte."},
+		Changes: []Text{
+			{"en": "Mount options of the local filesystems (network and swap entries are left alone)", "fr": "Options de montage des systèmes de fichiers locaux (entrées réseau et swap inchangées)"},
+		},
+		Files: []string{"/etc/fstab"},
+		Risks: []Text{
+			{"en": "An invalid fstab can stop the boot in emergency mode: the file is validated with findmnt --verify before it is written", "fr": "Un fstab invalide peut bloquer le démarrage en mode urgence : le fichier est validé par findmnt --verify avant écriture"},
+		},
+		Rollback: rollbackBackup,
+	},
+	"io": {
+		Title:   Text{"en": "I/O scheduler and queues", "fr": "Ordonnanceur d'E/S et files"},
+		Summary: Text{"en": "Sets the I/O scheduler (none/noop for virtual disks) and the read-ahead, nr_requests and rq_affinity of the disks with udev rules, also for disks added later.", "fr": "Règle l'ordonnanceur d'E/S (none/noop pour les disques virtuels) ainsi que read-ahead, nr_requests et rq_affinity des disques par des règles udev, y compris pour les disques ajoutés à chaud."},
+		Changes: []Text{
+			{"en": "Scheduler and queue settings of every sd, vd and nvme disk, applied now and at every boot", "fr": "Ordonnanceur et réglages de file de chaque disque sd, vd et nvme, appliqués maintenant et à chaque démarrage"},
+		},
+		Files: []string{"/etc/udev/rules.d/60-scheduler.rules", "/etc/udev/rules.d/61-vmware-tuner-hotplug.rules", "/etc/udev/rules.d/62-vmware-tuner-queue.rules", "/usr/local/sbin/vmware-tuner-disk-hotplug"},
+		Risks: []Text{
+			{"en": "Low: queue settings only change how requests are queued, not the data", "fr": "Faible : seule la mise en file des requêtes change, pas les données"},
+		},
+		Rollback: rollbackBackup,
+	},
+	"network": {
+		Title:   Text{"en": "Network (vmxnet3)", "fr": "Réseau (vmxnet3)"},
+		Summary: Text{"en": "Installs network-tuning.service, which sets the ring buffers, offloads and interrupt coalescing of the vmxnet3 interfaces at every boot (vmware-tuner net-apply) and places their interrupts and RPS/XPS masks on the vCPUs.", "fr": "Installe network-tuning.service, qui règle à chaque démarrage les tampons d'anneau, les délestages et la coalescence d'interruptions des interfaces vmxnet3 (vmware-tuner net-apply) et place leurs interruptions et masques RPS/XPS sur les vCPU."},
+		Changes: []Text{
+			{"en": "RX/TX rings (tuning.network), gso/gro/tso on, rx/tx-usecs 10", "fr": "Anneaux RX/TX (tuning.network), gso/gro/tso activés, rx/tx-usecs 10"},
+			{"en": "IRQ affinity and RPS/XPS masks (spread or pin); pin mode stops irqbalance", "fr": "Affinité des IRQ et masques RPS/XPS (spread ou pin) ; le mode pin arrête irqbalance"},
+		},
+		Files: []string{"/etc/systemd/system/network-tuning.service"},
+		Risks: []Text{
+			{"en": "Changing the rings resets the adapter: a link drop of a second when applied", "fr": "Changer les anneaux réinitialise la carte : coupure d'environ une seconde à l'application"},
+			{"en": "The unit runs the vmware-tuner binary: keep it where it was when the unit was written", "fr": "Le service lance le binaire vmware-tuner : le laisser à l'emplacement utilisé à l'écriture du service"},
+		},
+		Rollback: Text{"en": "vmware-tuner rollback removes the unit; the NIC settings return to the driver defaults at the next reboot.", "fr": "vmware-tuner rollback supprime le service ; les réglages des cartes reviennent aux valeurs du pilote au prochain redémarrage."},
+	},
+	"tools": {
+		Title:   Text{"en": "VMware Tools", "fr": "VMware Tools"},
+		Summary: Text{"en": "Installs open-vm-tools when missing and enables its service.", "fr": "Installe open-vm-tools s'il manque et active son service."},
+		Changes: []Text{
+			{"en": "Package open-vm-tools (from the repositories or --pkg-dir), vmtoolsd enabled and started", "fr": "Paquet open-vm-tools (dépôts ou --pkg-dir), vmtoolsd activé et démarré"},
+		},
+		Risks: []Text{
+			{"en": "Low: conflicts with the VMware Tools installed from the ISO, which are detected and left alone", "fr": "Faible : conflit avec les VMware Tools installés depuis l'ISO, détectés et laissés en place"},
+		},
+		Rollback: Text{"en": "Remove the open-vm-tools package with the package manager.", "fr": "Désinstaller le paquet open-vm-tools avec le gestionnaire de paquets."},
+	},
+	"debloat": {
+		Title:   Text{"en": "Server Slim", "fr": "Server Slim"},
+		Summary: Text{"en": "Stops and disables the services a server VM does not need (printing, Bluetooth, ModemManager...), from the catalog or tuning.debloat.services.", "fr": "Arrête et désactive les services inutiles sur une VM serveur (impression, Bluetooth, ModemManager...), selon le catalogue ou tuning.debloat.services."},
+		Changes: []Text{
+			{"en": "systemctl stop and disable of each candidate that is installed", "fr": "systemctl stop et disable de chaque candidat installé"},
+		},
+		Risks: []Text{
+			{"en": "An application may depend on a disabled service: review the list before confirming", "fr": "Une application peut dépendre d'un service désactivé : relire la liste avant de confirmer"},
+		},
+		Rollback: Text{"en": "vmware-tuner debloat undo [timestamp] restores the services of the backup session to their recorded state; or systemctl enable --now <service>.", "fr": "vmware-tuner debloat undo [horodatage] remet les services de la session de sauvegarde dans leur état enregistré ; ou systemctl enable --now <service>."},
+	},
+	"swap": {
+		Title:   Text{"en": "Swap", "fr": "Swap"},
+		Summary: Text{"en": "Creates and activates a swapfile when no swap is active, persisted in /etc/fstab.", "fr": "Crée et active un fichier d'échange quand aucun swap n'est actif, persistant via /etc/fstab."},
+		Changes: []Text{
+			{"en": "/swapfile created (fallocate, else dd), mkswap, swapon", "fr": "/swapfile créé (fallocate, sinon dd), mkswap, swapon"},
+		},
+		Files: []string{"/swapfile", "/etc/fstab"},
+		Risks: []Text{
+			{"en": "Uses disk space on the root filesystem; refused when it is too small", "fr": "Consomme de l'espace sur la racine ; refusé si elle est trop petite"},
+		},
+		Rollback: Text{"en": "vmware-tuner rollback turns the swapfile off, restores /etc/fstab and deletes /swapfile.", "fr": "vmware-tuner rollback désactive le swap, restaure /etc/fstab et supprime /swapfile."},
+	},
+	"timesync": {
+		Title:   Text{"en": "Time synchronization", "fr": "Synchronisation de l'heure"},
+		Summary: Text{"en": "Makes sure one clock source is active: chrony with NTP, or the VMware Tools host sync when offline.", "fr": "S'assure qu'une seule source d'heure est active : chrony avec NTP, ou la synchronisation par l'hôte des VMware Tools hors ligne."},
+		Changes: []Text{
+			{"en": "chrony installed and enabled, or vmware-toolbox-cmd timesync enable", "fr": "chrony installé et activé, ou vmware-toolbox-cmd timesync enable"},
+			{"en": "With NTP active, the periodic Tools host sync is disabled", "fr": "Avec NTP actif, la synchronisation périodique des Tools est désactivée"},
+		},
+		Risks: []Text{
+			{"en": "The clock may step when chrony first synchronizes", "fr": "L'horloge peut faire un saut à la première synchronisation de chrony"},
+		},
+		Rollback: Text{"en": "systemctl disable --now chronyd, or vmware-toolbox-cmd timesync disable.", "fr": "systemctl disable --now chronyd, ou vmware-toolbox-cmd timesync disable."},
+	},
+	"trim": {
+		Title:   Text{"en": "Periodic TRIM", "fr": "TRIM périodique"},
+		Summary: Text{"en": "Enables the weekly fstrim timer when a disk supports discard, to return freed blocks to thin datastores.", "fr": "Active le minuteur hebdomadaire fstrim si un disque gère discard, pour rendre les blocs libérés aux datastores thin."},
+		Changes: []Text{
+			{"en": "systemctl enable --now fstrim.timer", "fr": "systemctl enable --now fstrim.timer"},
+		},
+		Risks: []Text{
+			{"en": "A trim adds I/O once a week", "fr": "Un trim ajoute des E/S une fois par semaine"},
+		},
+		Rollback: Text{"en": "systemctl disable --now fstrim.timer.", "fr": "systemctl disable --now fstrim.timer."},
+	},
+	"limits": {
+		Title:   Text{"en": "Open file limits", "fr": "Limites de fichiers ouverts"},
+		Summary: Text{"en": "Raises the open file limits of sessions (limits.d) and services (systemd DefaultLimitNOFILE).", "fr": "Relève les limites de fichiers ouverts des sessions (limits.d) et des services (DefaultLimitNOFILE de systemd)."},
+		Changes: []Text{
+			{"en": "nofile for every user, DefaultLimitNOFILE for new services", "fr": "nofile pour tous les utilisateurs, DefaultLimitNOFILE pour les nouveaux services"},
+		},
+		Files: []string{"/etc/security/limits.d/90-vmware-tuner.conf", "/etc/systemd/system.conf.d/90-vmware-tuner-limits.conf"},
+		Risks: []Text{
+			{"en": "Running services keep their limit until they restart", "fr": "Les services déjà lancés gardent leur limite jusqu'à leur redémarrage"},
+		},
+		Rollback: rollbackBackup,
+	},
+	"pvscsi": {
+		Title:   Text{"en": "PVSCSI queue depth", "fr": "Profondeur de file PVSCSI"},
+		Summary: Text{"en": "Raises the PVSCSI command queue depth and ring size with module options and rebuilds the initramfs.", "fr": "Augmente la profondeur de file et la taille d'anneau PVSCSI par des options de module et reconstruit l'initramfs."},
+		Changes: []Text{
+			{"en": "vmw_pvscsi cmd_per_lun and ring_pages, used from the next boot", "fr": "cmd_per_lun et ring_pages de vmw_pvscsi, pris en compte au prochain démarrage"},
+		},
+		Files: []string{"/etc/modprobe.d/vmware-tuner-pvscsi.conf"},
+		Risks: []Text{
+			{"en": "Needs a reboot; deeper queues can move latency to a saturated datastore", "fr": "Redémarrage nécessaire ; des files plus profondes peuvent reporter la latence sur un datastore saturé"},
+		},
+		Rollback: Text{"en": "vmware-tuner rollback restores the modprobe.d file; rebuild the initramfs and reboot.", "fr": "vmware-tuner rollback restaure le fichier modprobe.d ; reconstruire l'initramfs et redémarrer."},
+	},
+	"hgfs": {
+		Title:   Text{"en": "Shared folders (HGFS)", "fr": "Dossiers partagés (HGFS)"},
+		Summary: Text{"en": "On VMware Workstation and Fusion guests, mounts the shared folders on /mnt/hgfs at boot with a systemd mount unit that caches file attributes, so builds and git stat the shared files without a round trip to the host each time.", "fr": "Sur les invités VMware Workstation et Fusion, monte les dossiers partagés sur /mnt/hgfs au démarrage par une unité mount systemd qui met en cache les attributs des fichiers, pour que les compilations et git consultent les fichiers partagés sans aller-retour vers l'hôte à chaque fois."},
+		Changes: []Text{
+			{"en": "mnt-hgfs.mount (vmhgfs-fuse, allow_other, attr_timeout and entry_timeout of 5 seconds), enabled", "fr": "mnt-hgfs.mount (vmhgfs-fuse, allow_other, attr_timeout et entry_timeout de 5 secondes), activée"},
+		},
+		Files: []string{"/etc/systemd/system/mnt-hgfs.mount"},
+		Risks: []Text{
+			{"en": "Files edited on the host show up in the guest after up to 5 seconds", "fr": "Les fichiers modifiés sur l'hôte apparaissent dans l'invité avec jusqu'à 5 secondes de retard"},
+		},
+		Rollback: Text{"en": "systemctl disable --now mnt-hgfs.mount; vmware-tuner rollback removes the unit.", "fr": "systemctl disable --now mnt-hgfs.mount ; vmware-tuner rollback supprime l'unité."},
+	},
+	"ipv6": {
+		Title:   Text{"en": "IPv6 limits", "fr": "Limitation d'IPv6"},
+		Summary: Text{"en": "For networks where IPv6 is not routed and connections wait for AAAA answers: prefers IPv4 in name resolution (prefer-ipv4, default), disables IPv6 on the interfaces (disable) or keeps the kernel from loading it (disable-boot).", "fr": "Pour les réseaux où IPv6 n'est pas routé et où les connexions attendent des réponses AAAA : préfère IPv4 pour la résolution de noms (prefer-ipv4, par défaut), désactive IPv6 sur les interfaces (disable) ou empêche le noyau de le charger (disable-boot)."},
+		Changes: []Text{
+			{"en": "prefer-ipv4: precedence ::ffff:0:0/96 100 in gai.conf", "fr": "prefer-ipv4 : precedence ::ffff:0:0/96 100 dans gai.conf"},
+			{"en": "disable: disable_ipv6 = 1 on all interfaces but lo, loaded now", "fr": "disable : disable_ipv6 = 1 sur toutes les interfaces sauf lo, appliqué immédiatement"},
+			{"en": "disable-boot: ipv6.disable=1 on the kernel command line", "fr": "disable-boot : ipv6.disable=1 sur la ligne de commande du noyau"},
+		},
+		Files: []string{"/etc/gai.conf", "/etc/sysctl.d/99-vmware-tuner-ipv6.conf", "/etc/default/grub"},
+		Risks: []Text{
+			{"en": "Refused when IPv6 has a default route; Postfix with inet_protocols = all no longer starts without IPv6", "fr": "Refusé si IPv6 a une route par défaut ; Postfix avec inet_protocols = all ne démarre plus sans IPv6"},
+			{"en": "disable-boot needs a reboot", "fr": "disable-boot nécessite un redémarrage"},
+		},
+		Rollback: Text{"en": "vmware-tuner rollback restores gai.conf, removes the sysctl file and writes 0 to disable_ipv6; vmware-tuner grub reset removes ipv6.disable=1 (reboot).", "fr": "vmware-tuner rollback restaure gai.conf, supprime le fichier sysctl et remet disable_ipv6 à 0 ; vmware-tuner grub reset retire ipv6.disable=1 (redémarrage)."},
+	},
+	"hugepages": {
+		Title:   Text{"en": "Static hugepages", "fr": "Pages énormes statiques"},
+		Summary: Text{"en": "Reserves the static hugepages sized by tuning.hugepages for databases and JVMs: at boot on the kernel command line, where memory is not fragmented yet, and with vm.nr_hugepages, allocated now as far as the free memory allows.", "fr": "Réserve les pages énormes statiques dimensionnées par tuning.hugepages pour les bases de données et les JVM : au démarrage par la ligne de commande du noyau, quand la mémoire n'est pas encore fragmentée, et par vm.nr_hugepages, alloué immédiatement dans la limite de la mémoire libre."},
+		Changes: []Text{
+			{"en": "vm.nr_hugepages in a sysctl.d file", "fr": "vm.nr_hugepages dans un fichier sysctl.d"},
+			{"en": "default_hugepagesz, hugepagesz and hugepages on the kernel command line, used from the next boot", "fr": "default_hugepagesz, hugepagesz et hugepages sur la ligne de commande du noyau, pris en compte au prochain démarrage"},
+		},
+		Files: []string{"/etc/sysctl.d/99-vmware-tuner-hugepages.conf", "/etc/default/grub"},
+		Risks: []Text{
+			{"en": "The pages are taken from the rest of the VM even when no application uses them; refused above 75% of the memory", "fr": "Les pages sont retirées au reste de la VM même si aucune application ne les utilise ; refusé au-delà de 75 % de la mémoire"},
+			{"en": "Needs a reboot for the pages the fragmented memory could not give now", "fr": "Redémarrage nécessaire pour les pages que la mémoire fragmentée n'a pas pu fournir immédiatement"},
+		},
+		Rollback: Text{"en": "vmware-tuner rollback removes the sysctl file and frees the pool (nr_hugepages = 0); vmware-tuner grub reset removes the hugepages parameters (reboot).", "fr": "vmware-tuner rollback supprime le fichier sysctl et libère le pool (nr_hugepages = 0) ; vmware-tuner grub reset retire les paramètres hugepages (redémarrage)."},
+	},
+	"tuned": {
+		Title:   Text{"en": "tuned profile", "fr": "Profil tuned"},
+		Summary: Text{"en": "Installs tuned on the RHEL family and activates virtual-guest, or the vmware-tuner profile: virtual-guest without the sysctl, transparent hugepage and disk settings vmware-tuner owns. tuned loads the sysctl.d files after its profile, so the two never fight over a value.", "fr": "Installe tuned sur la famille RHEL et active virtual-guest, ou le profil vmware-tuner : virtual-guest sans les réglages sysctl, pages énormes transparentes et disques gérés par vmware-tuner. tuned recharge les fichiers sysctl.d après son profil : les deux ne se disputent jamais une valeur."},
+		Changes: []Text{
+			{"en": "tuned package installed, service enabled and started", "fr": "Paquet tuned installé, service activé et démarré"},
+			{"en": "tuned-adm profile virtual-guest or vmware-tuner (tuning.tuned_profile)", "fr": "tuned-adm profile virtual-guest ou vmware-tuner (tuning.tuned_profile)"},
+			{"en": "reapply_sysctl = 1 in tuned-main.conf", "fr": "reapply_sysctl = 1 dans tuned-main.conf"},
+		},
+		Files: []string{"/etc/tuned/vmware-tuner/tuned.conf", "/etc/tuned/tuned-main.conf", "/etc/tuned/active_profile", "/etc/tuned/profile_mode"},
+		Risks: []Text{
+			{"en": "virtual-guest sets transparent hugepages and the disk read-ahead after boot, over the grub and queue modules: reported, use the vmware-tuner profile to avoid it", "fr": "virtual-guest règle les pages énormes transparentes et la lecture anticipée des disques après le démarrage, par-dessus les modules grub et queue : signalé, le profil vmware-tuner l'évite"},
+			{"en": "Replaces the profile chosen before (a database or SAP profile)", "fr": "Remplace le profil choisi auparavant (profil base de données ou SAP)"},
+		},
+		Rollback: Text{"en": "vmware-tuner rollback restores tuned-main.conf, the active profile files and the tuned service, removes the vmware-tuner profile and restarts a running tuned, which loads the previous profile again.", "fr": "vmware-tuner rollback restaure tuned-main.conf, les fichiers du profil actif et le service tuned, supprime le profil vmware-tuner et redémarre tuned s'il tourne, qui recharge le profil précédent."},
+	},
+	"disk": {
+		Title:   Text{"en": "Disk expansion", "fr": "Extension de disque"},
+		Summary: Text{"en": "Grows the root partition (growpart) and its filesystem after the virtual disk was enlarged.", "fr": "Agrandit la partition racine (growpart) et son système de fichiers après l'extension du disque virtuel."},
+		Changes: []Text{
+			{"en": "Partition table of the root disk, then resize2fs or xfs_growfs", "fr": "Table de partitions du disque racine, puis resize2fs ou xfs_growfs"},
+		},
+		Risks: []Text{
+			{"en": "Edits the partition table of a mounted disk: take a snapshot first", "fr": "Modifie la table de partitions d'un disque monté : prendre un snapshot avant"},
+		},
+		Rollback: Text{"en": "None: a filesystem cannot be shrunk back online, revert to the snapshot.", "fr": "Aucun : un système de fichiers ne se réduit pas à chaud, revenir au snapshot."},
+	},
+	"cleaner": {
+		Title:    Text{"en": "System cleaner", "fr": "Nettoyage du système"},
+		Summary:  Text{"en": "Frees disk space: package caches, unused packages and journal entries older than 3 days.", "fr": "Libère de l'espace disque : caches de paquets, paquets inutilisés et journal de plus de 3 jours."},
+		Changes:  []Text{{"en": "apt-get clean/autoremove or dnf clean/autoremove, journalctl --vacuum-time=3d", "fr": "apt-get clean/autoremove ou dnf clean/autoremove, journalctl --vacuum-time=3d"}},
+		Risks:    []Text{{"en": "Removed logs and packages are gone", "fr": "Les journaux et paquets supprimés sont perdus"}},
+		Rollback: Text{"en": "None: reinstall the packages needed.", "fr": "Aucun : réinstaller les paquets nécessaires."},
+	},
+	"ssh": {
+		Title:    Text{"en": "SSH hardening", "fr": "Durcissement SSH"},
+		Summary:  Text{"en": "Disables root login and, on request, password authentication in sshd_config, after validating it with sshd -t.", "fr": "Désactive la connexion root et, sur demande, l'authentification par mot de passe dans sshd_config, après validation par sshd -t."},
+		Changes:  []Text{{"en": "PermitRootLogin no, PasswordAuthentication no; sshd restarted", "fr": "PermitRootLogin no, PasswordAuthentication no ; sshd redémarré"}},
+		Files:    []string{"/etc/ssh/sshd_config"},
+		Risks:    []Text{{"en": "Without a working SSH key, password-only accounts lose access: keep the console open", "fr": "Sans clé SSH fonctionnelle, les comptes à mot de passe perdent l'accès : garder la console ouverte"}},
+		Rollback: rollbackBackup,
+	},
+	"cron": {
+		Title:    Text{"en": "Scheduled maintenance", "fr": "Maintenance planifiée"},
+		Summary:  Text{"en": "Schedules the nightly cleanup and time resynchronization.", "fr": "Planifie le nettoyage nocturne et la resynchronisation de l'heure."},
+		Changes:  []Text{{"en": "Cron jobs running the vmware-tuner binary", "fr": "Tâches cron lançant le binaire vmware-tuner"}},
+		Files:    []string{"/etc/cron.d/vmware-tuner"},
+		Risks:    []Text{{"en": "Low", "fr": "Faible"}},
+		Rollback: Text{"en": "Delete /etc/cron.d/vmware-tuner.", "fr": "Supprimer /etc/cron.d/vmware-tuner."},
+	},
+	"template": {
+		Title:    Text{"en": "Template sealing", "fr": "Scellement de modèle"},
+		Summary:  Text{"en": "Prepares the VM to become a template: clears the machine-id, the SSH host keys, old logs and shell history.", "fr": "Prépare la VM à devenir un modèle : vide le machine-id, les clés d'hôte SSH, les anciens journaux et l'historique du shell."},
+		Changes:  []Text{{"en": "Identity of the VM reset, regenerated at the next boot", "fr": "Identité de la VM réinitialisée, régénérée au prochain démarrage"}},
+		Files:    []string{"/etc/machine-id", "/var/lib/dbus/machine-id", "/etc/ssh/ssh_host_*"},
+		Risks:    []Text{{"en": "Destructive: only on a VM about to be shut down and converted", "fr": "Destructif : seulement sur une VM sur le point d'être arrêtée et convertie"}},
+		Rollback: Text{"en": "None: revert to a snapshot.", "fr": "Aucun : revenir à un snapshot."},
+	},
+	"docker": {
+		Title:    Text{"en": "Docker", "fr": "Docker"},
+		Summary:  Text{"en": "Configures container log rotation and prunes unused images and containers.", "fr": "Configure la rotation des journaux des conteneurs et supprime les images et conteneurs inutilisés."},
+		Changes:  []Text{{"en": "log-opts max-size=10m max-file=3 in daemon.json, docker restarted; docker system prune", "fr": "log-opts max-size=10m max-file=3 dans daemon.json, docker redémarré ; docker system prune"}},
+		Files:    []string{"/etc/docker/daemon.json"},
+		Risks:    []Text{{"en": "Restarting docker restarts the containers without a restart policy", "fr": "Redémarrer docker arrête les conteneurs sans politique de redémarrage"}},
+		Rollback: rollbackBackup,
+	},
+	"update": {
+		Title:    Text{"en": "System update", "fr": "Mise à jour du système"},
+		Summary:  Text{"en": "Updates the packages with the distribution package manager and offers a reboot.", "fr": "Met à jour les paquets avec le gestionnaire de la distribution et propose un redémarrage."},
+		Changes:  []Text{{"en": "apt-get upgrade or dnf update", "fr": "apt-get upgrade ou dnf update"}},
+		Risks:    []Text{{"en": "New package versions; a kernel update needs a reboot", "fr": "Nouvelles versions de paquets ; une mise à jour du noyau demande un redémarrage"}},
+		Rollback: Text{"en": "dnf history undo, or downgrade the packages with apt; a snapshot is safer.", "fr": "dnf history undo, ou rétrograder les paquets avec apt ; un snapshot est plus sûr."},
+	},
+	"realtime": {
+		Title:    Text{"en": "Real-time kernel assistant", "fr": "Assistant noyau temps réel"},
+		Summary:  Text{"en": "Detects a PREEMPT_RT kernel and adds CPU isolation boot parameters on request.", "fr": "Détecte un noyau PREEMPT_RT et ajoute sur demande les paramètres d'isolation de CPU au démarrage."},
+		Changes:  []Text{{"en": "isolcpus, nohz_full and rcu_nocbs in the GRUB command line", "fr": "isolcpus, nohz_full et rcu_nocbs dans la ligne de commande GRUB"}},
+		Files:    []string{"/etc/default/grub"},
+		Risks:    []Text{{"en": "Isolated vCPUs only run pinned tasks: too many leave the system short of CPU", "fr": "Les vCPU isolés n'exécutent que les tâches épinglées : trop d'isolation prive le système de CPU"}},
+		Rollback: Text{"en": "vmware-tuner grub reset, then reboot.", "fr": "vmware-tuner grub reset, puis redémarrer."},
+	},
+	"isolation": {
+		Title:    Text{"en": "CPU isolation", "fr": "Isolation de CPU"},
+		Summary:  Text{"en": "Isolates vCPUs for a latency-sensitive application: boot parameters and the CPU affinity of systemd.", "fr": "Isole des vCPU pour une application sensible à la latence : paramètres de démarrage et affinité CPU de systemd."},
+		Changes:  []Text{{"en": "isolcpus/nohz_full in GRUB, CPUAffinity in system.conf.d", "fr": "isolcpus/nohz_full dans GRUB, CPUAffinity dans system.conf.d"}},
+		Files:    []string{"/etc/default/grub", "/etc/systemd/system.conf.d/"},
+		Risks:    []Text{{"en": "Needs a reboot; services not pinned share the remaining vCPUs", "fr": "Redémarrage nécessaire ; les services non épinglés se partagent les vCPU restants"}},
+		Rollback: Text{"en": "vmware-tuner grub reset and vmware-tuner rollback, then reboot.", "fr": "vmware-tuner grub reset et vmware-tuner rollback, puis redémarrer."},
+	},
+	"syslog": {
+		Title:    Text{"en": "Syslog forwarding", "fr": "Transfert syslog"},
+		Summary:  Text{"en": "Forwards the logs to the syslog servers of the config file with rsyslog (journald forwarding enabled).", "fr": "Transfère les journaux vers les serveurs syslog du fichier de configuration avec rsyslog (transfert journald activé)."},
+		Changes:  []Text{{"en": "rsyslog installed if needed and restarted", "fr": "rsyslog installé si besoin et redémarré"}},
+		Files:    []string{"/etc/rsyslog.d/90-vmware-tuner-forward.conf", "/etc/systemd/journald.conf.d/vmware-tuner-forward.conf"},
+		Risks:    []Text{{"en": "Logs leave the VM: check the destination and the transport (TLS)", "fr": "Les journaux quittent la VM : vérifier la destination et le transport (TLS)"}},
+		Rollback: rollbackBackup,
+	},
+	"snmp": {
+		Title:    Text{"en": "SNMP agent", "fr": "Agent SNMP"},
+		Summary:  Text{"en": "Installs snmpd with an SNMPv3 user and a read-only view.", "fr": "Installe snmpd avec un utilisateur SNMPv3 et une vue en lecture seule."},
+		Changes:  []Text{{"en": "Package snmpd, SNMPv3 user created, snmpd enabled", "fr": "Paquet snmpd, utilisateur SNMPv3 créé, snmpd activé"}},
+		Files:    []string{"/etc/snmp/snmpd.conf", "/var/lib/snmp/snmpd.conf"},
+		Risks:    []Text{{"en": "Opens UDP port 161", "fr": "Ouvre le port UDP 161"}},
+		Rollback: rollbackBackup,
+	},
+	"monitoring": {
+		Title:    Text{"en": "Monitoring agent", "fr": "Agent de supervision"},
+		Summary:  Text{"en": "Installs node_exporter or configures Telegraf, with a timer exporting the VMware Tools statistics.", "fr": "Installe node_exporter ou configure Telegraf, avec un minuteur exportant les statistiques des VMware Tools."},
+		Changes:  []Text{{"en": "Agent binary and service, stats timer", "fr": "Binaire et service de l'agent, minuteur de statistiques"}},
+		Files:    []string{"/usr/local/bin/node_exporter", "/etc/systemd/system/node_exporter.service", "/etc/telegraf/telegraf.d/vmware-tuner.conf", "/etc/systemd/system/vmware-tools-stats.timer"},
+		Risks:    []Text{{"en": "Opens the exporter port (9100)", "fr": "Ouvre le port de l'exporteur (9100)"}},
+		Rollback: rollbackBackup,
+	},
+	"compliance": {
+		Title:    Text{"en": "Weekly compliance job", "fr": "Contrôle de conformité hebdomadaire"},
+		Summary:  Text{"en": "Schedules vmware-tuner compliance every week, with a notification on drift.", "fr": "Planifie vmware-tuner compliance chaque semaine, avec une notification en cas d'écart."},
+		Changes:  []Text{{"en": "Cron job", "fr": "Tâche cron"}},
+		Files:    []string{"/etc/cron.d/vmware-tuner-compliance"},
+		Risks:    []Text{{"en": "Low", "fr": "Faible"}},
+		Rollback: Text{"en": "Delete /etc/cron.d/vmware-tuner-compliance.", "fr": "Supprimer /etc/cron.d/vmware-tuner-compliance."},
+	},
+	"restart": {
+		Title:    Text{"en": "Services to restart", "fr": "Services à redémarrer"},
+		Summary:  Text{"en": "Lists the services still using deleted libraries after an update and restarts those chosen.", "fr": "Liste les services utilisant encore des bibliothèques supprimées après une mise à jour et redémarre ceux choisis."},
+		Changes:  []Text{{"en": "systemctl restart of the chosen services (never dbus or systemd)", "fr": "systemctl restart des services choisis (jamais dbus ni systemd)"}},
+		Risks:    []Text{{"en": "A short outage of each restarted service", "fr": "Courte interruption de chaque service redémarré"}},
+		Rollback: Text{"en": "None needed.", "fr": "Aucun nécessaire."},
+	},
+	"units": {
+		Title:    Text{"en": "Failed units repair", "fr": "Réparation des unités en échec"},
+		Summary:  Text{"en": "Lists the failed systemd units with their journal and restarts, disables or resets them one at a time.", "fr": "Liste les unités systemd en échec avec leur journal et les redémarre, désactive ou réinitialise une par une."},
+		Changes:  []Text{{"en": "systemctl restart, disable --now or reset-failed, each recorded in the action log", "fr": "systemctl restart, disable --now ou reset-failed, chacun consigné dans le journal des actions"}},
+		Risks:    []Text{{"en": "Disabling a unit stops it at every boot", "fr": "Désactiver une unité l'arrête à chaque démarrage"}},
+		Rollback: Text{"en": "systemctl enable --now <unit> for a disabled unit.", "fr": "systemctl enable --now <unité> pour une unité désactivée."},
+	},
+	"proxy": {
+		Title:    Text{"en": "Package proxy", "fr": "Proxy des paquets"},
+		Summary:  Text{"en": "Configures the proxy of the config file for apt or dnf.", "fr": "Configure le proxy du fichier de configuration pour apt ou dnf."},
+		Changes:  []Text{{"en": "Proxy line of the package manager", "fr": "Ligne proxy du gestionnaire de paquets"}},
+		Files:    []string{"/etc/apt/apt.conf.d/95vmware-tuner-proxy", "/etc/dnf/dnf.conf", "/etc/yum.conf"},
+		Risks:    []Text{{"en": "A wrong proxy breaks package installs", "fr": "Un proxy erroné empêche l'installation de paquets"}},
+		Rollback: rollbackBackup,
+	},
+	"ca": {
+		Title:    Text{"en": "Enterprise root CA", "fr": "AC racine d'entreprise"},
+		Summary:  Text{"en": "Adds a root certificate to the system trust store.", "fr": "Ajoute un certificat racine au magasin de confiance du système."},
+		Changes:  []Text{{"en": "Certificate copied to the anchors, update-ca-certificates or update-ca-trust", "fr": "Certificat copié dans les ancres, update-ca-certificates ou update-ca-trust"}},
+		Files:    []string{"/usr/local/share/ca-certificates/", "/etc/pki/ca-trust/source/anchors/"},
+		Risks:    []Text{{"en": "Every TLS connection trusts the new CA: check its fingerprint", "fr": "Toute connexion TLS fait confiance à la nouvelle AC : vérifier son empreinte"}},
+		Rollback: Text{"en": "Delete the certificate from the anchors and update the trust store again.", "fr": "Supprimer le certificat des ancres et mettre à jour le magasin de confiance."},
+	},
+}
+
+// LookupModuleDoc returns the documentation of a module
+func LookupModuleDoc(module string) (ModuleDoc, bool) {
+	doc, ok := moduleDocs[module]
+	return doc, ok
+}
+
+// DocumentedModules returns the modules with a documentation page, sorted
+func DocumentedModules() []string {
+	modules := make([]string, 0, len(moduleDocs))
+	for module := range moduleDocs {
+		modules = append(modules, module)
+	}
+	sort.Strings(modules)
+	return modules
+}
+
+// HelpLanguage returns the language of the documentation: the requested one,
+// else LC_ALL, LC_MESSAGES or LANG (fr_FR.UTF-8), else English
+func HelpLanguage(requested string) (string, error) {
+	if requested != "" {
+		for _, lang := range HelpLanguages {
+			if lang == requested {
+				return lang, nil
+			}
+		}
+		return "", fmt.Errorf("%w: unknown language %q (%s)", ErrValidationFailed, requested, strings.Join(HelpLanguages, ", "))
+	}
+	for _, env := range []string{"LC_ALL", "LC_MESSAGES", "LANG"} {
+		value := os.Getenv(env)
+		if value == "" {
+			continue
+		}
+		for _, lang := range HelpLanguages {
+			if strings.HasPrefix(value, lang) {
+				return lang, nil
+			}
+		}
+		break // the first variable set decides, like gettext
+	}
+	return HelpLanguages[0], nil
+}
+
+// RenderModuleDoc writes the page of a module, man style
+func RenderModuleDoc(w io.Writer, module string, doc ModuleDoc, lang string) {
+	section := func(key string) {
+		fmt.Fprintf(w, "\n%s\n", docHeadings[key].In(lang))
+	}
+	item := func(text string) {
+		fmt.Fprintf(w, "    %s\n", text)
+	}
+
+	section("name")
+	item(fmt.Sprintf("%s - %s", module, doc.Title.In(lang)))
+	section("desc")
+	item(doc.Summary.In(lang))
+	section("changes")
+	for _, c := range doc.Changes {
+		item("- " + c.In(lang))
+	}
+	section("files")
+	if len(doc.Files) == 0 {
+		item(docHeadings["none"].In(lang))
+	}
+	for _, f := range doc.Files {
+		item(f)
+	}
+	section("risks")
+	for _, r := range doc.Risks {
+		item("- " + r.In(lang))
+	}
+	section("rollback")
+	item(doc.Rollback.In(lang))
+}
+
+// PrintModuleDocs lists the files and the rollback of the documented modules
+// among those given (the plan of apply-all)
+func PrintModuleDocs(modules []string, lang string) {
+	for _, module := range modules {
+		doc, ok := moduleDocs[module]
+		if !ok {
+			continue
+		}
+		fmt.Printf("  %s (%s)\n", module, doc.Title.In(lang))
+		if len(doc.Files) > 0 {
+			fmt.Printf("      %s: %s\n", strings.ToLower(docHeadings["files"].In(lang)), strings.Join(doc.Files, ", "))
+		}
+		fmt.Printf("      %s: %s\n", strings.ToLower(docHeadings["rollback"].In(lang)), doc.Rollback.In(lang))
+	}
+}
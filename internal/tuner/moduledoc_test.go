@@ -0,0 +1,72 @@
+package tuner
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestModuleDocsComplete(t *testing.T) {
+	for _, module := range TuningModules() {
+		doc, ok := LookupModuleDoc(module)
+		if !ok {
+			t.Errorf("tuning module %s has no documentation", module)
+			continue
+		}
+		texts := []Text{doc.Title, doc.Summary, doc.Rollback}
+		texts = append(texts, doc.Changes...)
+		texts = append(texts, doc.Risks...)
+		if len(doc.Changes) == 0 || len(doc.Risks) == 0 {
+			t.Errorf("%s: changes and risks are required", module)
+		}
+		for _, text := range texts {
+			for _, lang := range HelpLanguages {
+				if text[lang] == "" {
+					t.Errorf("%s: text %q not translated in %s", module, text["en"], lang)
+				}
+			}
+		}
+	}
+	for module := range moduleDocs {
+		if m, ok := LookupModule(module); ok && m.ReadOnly {
+			t.Errorf("%s changes nothing and should have no page", module)
+		}
+	}
+}
+
+func TestHelpLanguage(t *testing.T) {
+	for _, tc := range []struct {
+		lcAll, lang, requested, want string
+	}{
+		{"", "fr_FR.UTF-8", "", "fr"},
+		{"C", "fr_FR.UTF-8", "", "en"}, // LC_ALL decides
+		{"", "de_DE.UTF-8", "", "en"},
+		{"", "fr_FR.UTF-8", "en", "en"},
+	} {
+		t.Setenv("LC_ALL", tc.lcAll)
+		t.Setenv("LC_MESSAGES", "")
+		t.Setenv("LANG", tc.lang)
+		if got, err := HelpLanguage(tc.requested); err != nil || got != tc.want {
+			t.Errorf("LC_ALL=%q LANG=%q --lang=%q: %q, %v, want %q", tc.lcAll, tc.lang, tc.requested, got, err, tc.want)
+		}
+	}
+	if _, err := HelpLanguage("xx"); err == nil {
+		t.Error("unknown language should be refused")
+	}
+}
+
+func TestRenderModuleDoc(t *testing.T) {
+	doc, _ := LookupModuleDoc("limits")
+	var out bytes.Buffer
+	RenderModuleDoc(&out, "limits", doc, "fr")
+	page := out.String()
+	for _, want := range []string{"NOM\n    limits - ", "FICHIERS\n    /etc/security/limits.d/90-vmware-tuner.conf", "RETOUR ARRIÈRE"} {
+		if !strings.Contains(page, want) {
+			t.Errorf("page missing %q:\n%s", want, page)
+		}
+	}
+
+	if got := (Text{"en": "only English"}).In("fr"); got != "only English" {
+		t.Errorf("untranslated text = %q, want the English one", got)
+	}
+}
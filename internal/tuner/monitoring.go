@@ -0,0 +1,407 @@
+package tuner
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// NodeExporterVersion is the release downloaded when no package is available
+const NodeExporterVersion = "1.8.2"
+
+// Paths of the VMware Tools statistics collector shared by both agents
+const (
+	toolsStatsScript = "/usr/local/lib/vmware-tuner/vmware-tools-stats.sh"
+	toolsStatsTimer  = "/etc/systemd/system/vmware-tools-stats.timer"
+	toolsStatsUnit   = "/etc/systemd/system/vmware-tools-stats.service"
+	nodeExporterUnit = "/etc/systemd/system/node_exporter.service"
+	nodeExporterBin  = "/usr/local/bin/node_exporter"
+	telegrafDropIn   = "/etc/telegraf/telegraf.d/vmware-tuner.conf"
+)
+
+// toolsStatsMetrics maps `vmware-toolbox-cmd stat` items to metric names
+var toolsStatsMetrics = []struct {
+	Stat   string
+	Metric string
+}{
+	{"balloon", "vmware_guest_balloon_mb"},
+	{"swap", "vmware_guest_swapped_mb"},
+	{"memlimit", "vmware_guest_mem_limit_mb"},
+	{"memres", "vmware_guest_mem_reservation_mb"},
+	{"cpures", "vmware_guest_cpu_reservation_mhz"},
+	{"cpulimit", "vmware_guest_cpu_limit_mhz"},
+	{"speed", "vmware_guest_cpu_speed_mhz"},
+}
+
+// MonitoringTuner installs a metrics agent with VMware guest inputs
+type MonitoringTuner struct {
+	Distro *DistroManager
+	Backup *BackupManager
+}
+
+// NewMonitoringTuner creates a new monitoring agent tuner
+func NewMonitoringTuner(distro *DistroManager, backup *BackupManager) *MonitoringTuner {
+	return &MonitoringTuner{
+		Distro: distro,
+		Backup: backup,
+	}
+}
+
+func init() {
+	Register(Module{
+		Name:        "monitoring",
+		Label:       "Install Monitoring Agent",
+		Description: "Install node_exporter or configure Telegraf",
+		Category:    CategoryIntegration,
+		Menu:        23,
+		RequireRoot: true,
+		Distros:     packagedDistros,
+		Run: func(ctx *ModuleContext) error {
+			backup, err := ctx.BackupSession()
+			if err != nil {
+				return err
+			}
+			return NewMonitoringTuner(ctx.Distro, backup).Run(ctx.HasInternet)
+		},
+	})
+}
+
+// Run asks which agent to install and bootstraps it
+func (mt *MonitoringTuner) Run(hasInternet bool) error {
+	PrintStep("Monitoring Agent Bootstrap")
+
+	if PackageDir != "" {
+		PrintInfo("Offline package directory: %s", PackageDir)
+	} else if !hasInternet {
+		PrintWarning("Mode Hors-Ligne: use --pkg-dir to install from a local package directory")
+	}
+
+	fmt.Println("Agent:")
+	fmt.Println("  [1] Prometheus node_exporter")
+	fmt.Println("  [2] Telegraf")
+	fmt.Println("  [3] Cancel")
+	fmt.Print("Choice: ")
+
+	var choice string
+	fmt.Scanln(&choice)
+
+	switch choice {
+	case "1":
+		return mt.InstallNodeExporter(hasInternet)
+	case "2":
+		return mt.InstallTelegraf(hasInternet)
+	default:
+		PrintInfo("Cancelled")
+		return nil
+	}
+}
+
+// ToolsStatsScript renders the collector printing VMware Tools statistics
+// (balloon, swap, limits, reservations) in the Prometheus text format
+func ToolsStatsScript() string {
+	var sb strings.Builder
+	sb.WriteString("#!/bin/sh\n# VMware Tools guest statistics - Generated by vmware-tuner\n")
+	sb.WriteString("if systemctl is-active -q vmtoolsd || systemctl is-active -q open-vm-tools; then\n")
+	sb.WriteString("  echo \"vmware_guest_tools_running 1\"\nelse\n  echo \"vmware_guest_tools_running 0\"\nfi\n")
+	for _, m := range toolsStatsMetrics {
+		fmt.Fprintf(&sb, "v=$(vmware-toolbox-cmd stat %s 2>/dev/null | awk '{print $1}')\n", m.Stat)
+		fmt.Fprintf(&sb, "case \"$v\" in ''|*[!0-9]*) ;; *) echo \"%s $v\" ;; esac\n", m.Metric)
+	}
+	return sb.String()
+}
+
+// InstallNodeExporter installs node_exporter (package, offline tarball or
+// release download) and feeds it VMware Tools statistics via the textfile collector
+func (mt *MonitoringTuner) InstallNodeExporter(hasInternet bool) error {
+	textfileDir := "/var/lib/node_exporter/textfile_collector"
+
+	// Debian ships node_exporter with the textfile collector preconfigured
+	if mt.Distro.Type == DistroDebian && mt.Distro.HasPackageSource("prometheus-node-exporter", hasInternet) {
+		if err := mt.Distro.InstallPackage("prometheus-node-exporter"); err != nil {
+			return err
+		}
+		textfileDir = "/var/lib/prometheus/node-exporter"
+	} else {
+		if err := mt.installNodeExporterBinary(hasInternet); err != nil {
+			return err
+		}
+		if err := mt.writeFile(nodeExporterUnit, nodeExporterService(textfileDir), 0644); err != nil {
+			return err
+		}
+		exec.Command("systemctl", "daemon-reload").Run()
+		if out, err := exec.Command("systemctl", "enable", "--now", "node_exporter").CombinedOutput(); err != nil {
+			return fmt.Errorf("failed to start node_exporter: %v: %s", err, string(out))
+		}
+	}
+	PrintSuccess("node_exporter is running (port 9100)")
+
+	if err := os.MkdirAll(textfileDir, 0755); err != nil {
+		return fmt.Errorf("failed to create %s: %w", textfileDir, err)
+	}
+	command := fmt.Sprintf("/bin/sh -c '%s > %s/vmware.prom.tmp && mv %s/vmware.prom.tmp %s/vmware.prom'",
+		toolsStatsScript, textfileDir, textfileDir, textfileDir)
+	return mt.installToolsStatsTimer(command)
+}
+
+// installNodeExporterBinary installs the release binary from PackageDir or GitHub
+func (mt *MonitoringTuner) installNodeExporterBinary(hasInternet bool) error {
+	arch := runtime.GOARCH
+	var tarball string
+
+	var sums string
+	if PackageDir != "" {
+		matches, _ := filepath.Glob(filepath.Join(PackageDir, "node_exporter-*.linux-"+arch+".tar.gz"))
+		tarball = newestPackageFile(matches)
+		if tarball != "" {
+			sums = filepath.Join(filepath.Dir(tarball), "sha256sums.txt")
+		}
+	}
+
+	if tarball == "" {
+		if !hasInternet {
+			return fmt.Errorf("no node_exporter-*.linux-%s.tar.gz in the package directory: %w", arch, ErrOffline)
+		}
+		name := fmt.Sprintf("node_exporter-%s.linux-%s.tar.gz", NodeExporterVersion, arch)
+		url := fmt.Sprintf("https://github.com/prometheus/node_exporter/releases/download/v%s/%s", NodeExporterVersion, name)
+		tarball = filepath.Join(os.TempDir(), name)
+		PrintInfo("Downloading %s...", url)
+		if err := downloadFile(url, tarball); err != nil {
+			return err
+		}
+		defer os.Remove(tarball)
+		sums = filepath.Join(os.TempDir(), "node_exporter-sha256sums.txt")
+		if err := downloadFile(path.Dir(url)+"/sha256sums.txt", sums); err != nil {
+			return fmt.Errorf("failed to download the checksums of %s: %w", name, err)
+		}
+		defer os.Remove(sums)
+	}
+
+	// The binary runs as a service: refuse a tarball that does not match the
+	// checksums published with the release
+	if FileExists(sums) {
+		if err := verifySHA256(tarball, sums); err != nil {
+			return err
+		}
+		PrintSuccess("%s matches %s", filepath.Base(tarball), filepath.Base(sums))
+	} else {
+		PrintWarning("No sha256sums.txt next to %s: the tarball cannot be verified", tarball)
+	}
+
+	tmpDir, err := os.MkdirTemp("", "node_exporter")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary directory: %w", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	if out, err := exec.Command("tar", "-xzf", tarball, "-C", tmpDir, "--strip-components=1").CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to extract %s: %v: %s", tarball, err, string(out))
+	}
+	binary, err := os.ReadFile(filepath.Join(tmpDir, "node_exporter"))
+	if err != nil {
+		return fmt.Errorf("node_exporter binary missing from %s: %w", tarball, err)
+	}
+
+	exec.Command("useradd", "--system", "--no-create-home", "--shell", "/usr/sbin/nologin", "node_exporter").Run()
+	return mt.writeFile(nodeExporterBin, string(binary), 0755)
+}
+
+// verifySHA256 checks a file against a sha256sums.txt listing ("<hash>  <name>")
+func verifySHA256(file, sumsPath string) error {
+	sums, err := os.ReadFile(sumsPath)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", sumsPath, err)
+	}
+	name := filepath.Base(file)
+	want := ""
+	for _, line := range strings.Split(string(sums), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 2 && strings.TrimPrefix(fields[1], "*") == name {
+			want = strings.ToLower(fields[0])
+		}
+	}
+	if want == "" {
+		return fmt.Errorf("%w: %s is not listed in %s", ErrValidationFailed, name, sumsPath)
+	}
+
+	f, err := os.Open(file)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", file, err)
+	}
+	defer f.Close()
+	hash := sha256.New()
+	if _, err := io.Copy(hash, f); err != nil {
+		return fmt.Errorf("failed to read %s: %w", file, err)
+	}
+	if got := hex.EncodeToString(hash.Sum(nil)); got != want {
+		return fmt.Errorf("%w: %s has SHA-256 %s, %s lists %s: refusing to install it", ErrValidationFailed, name, got, sumsPath, want)
+	}
+	return nil
+}
+
+// nodeExporterService renders the unit used for binary installs
+func nodeExporterService(textfileDir string) string {
+	return fmt.Sprintf(`# node_exporter - Generated by vmware-tuner
+[Unit]
+Description=Prometheus node_exporter
+After=network-online.target
+
+[Service]
+User=node_exporter
+ExecStart=%s --collector.systemd --collector.textfile.directory=%s
+Restart=on-failure
+
+[Install]
+WantedBy=multi-user.target
+`, nodeExporterBin, textfileDir)
+}
+
+// InstallTelegraf installs Telegraf (configured repository or PackageDir) with
+// system and VMware Tools inputs. Outputs stay in the main telegraf.conf.
+func (mt *MonitoringTuner) InstallTelegraf(hasInternet bool) error {
+	if !isPackageInstalled(mt.Distro, "telegraf") {
+		if !mt.Distro.HasPackageSource("telegraf", hasInternet) {
+			return fmt.Errorf("telegraf is not installed and no package source is available (see --pkg-dir)")
+		}
+		if err := mt.Distro.InstallPackage("telegraf"); err != nil {
+			PrintInfo("Telegraf comes from the InfluxData repository: configure it or provide the package with --pkg-dir")
+			return err
+		}
+	}
+
+	if err := mt.writeFile(toolsStatsScript, ToolsStatsScript(), 0755); err != nil {
+		return err
+	}
+	if err := mt.writeFile(telegrafDropIn, telegrafInputs(), 0644); err != nil {
+		return err
+	}
+
+	if out, err := exec.Command("telegraf", "--config-directory", filepath.Dir(telegrafDropIn), "--test", "--input-filter", "exec").CombinedOutput(); err != nil {
+		PrintWarning("Telegraf test run failed: %s", strings.TrimSpace(string(out)))
+	}
+
+	exec.Command("systemctl", "enable", "telegraf").Run()
+	if out, err := exec.Command("systemctl", "restart", "telegraf").CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to restart telegraf: %v: %s", err, string(out))
+	}
+	PrintSuccess("Telegraf is running with VMware guest inputs")
+	PrintInfo("Configure an output (InfluxDB, Prometheus client...) in /etc/telegraf/telegraf.conf if not done yet")
+	return nil
+}
+
+// telegrafInputs renders the VMware-guest-focused Telegraf input set
+func telegrafInputs() string {
+	return fmt.Sprintf(`# VMware guest inputs - Generated by vmware-tuner
+[[inputs.cpu]]
+  percpu = false
+  totalcpu = true
+  # usage_steal shows CPU contention on the ESXi host
+
+[[inputs.mem]]
+[[inputs.swap]]
+[[inputs.system]]
+[[inputs.kernel]]
+[[inputs.processes]]
+
+[[inputs.disk]]
+  ignore_fs = ["tmpfs", "devtmpfs", "overlay", "squashfs"]
+
+[[inputs.diskio]]
+[[inputs.net]]
+[[inputs.netstat]]
+
+# VMware Tools statistics (balloon, swap, limits, reservations)
+[[inputs.exec]]
+  commands = ["%s"]
+  interval = "60s"
+  timeout = "10s"
+  data_format = "prometheus"
+`, toolsStatsScript)
+}
+
+// installToolsStatsTimer runs the collector every minute for node_exporter
+func (mt *MonitoringTuner) installToolsStatsTimer(command string) error {
+	if err := mt.writeFile(toolsStatsScript, ToolsStatsScript(), 0755); err != nil {
+		return err
+	}
+
+	service := fmt.Sprintf(`# VMware Tools statistics - Generated by vmware-tuner
+[Unit]
+Description=Export VMware Tools statistics for node_exporter
+
+[Service]
+Type=oneshot
+ExecStart=%s
+`, command)
+	timer := `# VMware Tools statistics - Generated by vmware-tuner
+[Unit]
+Description=Export VMware Tools statistics every minute
+
+[Timer]
+OnBootSec=1min
+OnUnitActiveSec=1min
+
+[Install]
+WantedBy=timers.target
+`
+	if err := mt.writeFile(toolsStatsUnit, service, 0644); err != nil {
+		return err
+	}
+	if err := mt.writeFile(toolsStatsTimer, timer, 0644); err != nil {
+		return err
+	}
+
+	exec.Command("systemctl", "daemon-reload").Run()
+	if out, err := exec.Command("systemctl", "enable", "--now", "vmware-tools-stats.timer").CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to enable vmware-tools-stats.timer: %v: %s", err, string(out))
+	}
+	PrintSuccess("VMware Tools statistics exported every minute")
+	return nil
+}
+
+// writeFile backs up then writes a file
+func (mt *MonitoringTuner) writeFile(path, content string, mode os.FileMode) error {
+	if err := mt.Backup.BackupFile(path); err != nil {
+		return fmt.Errorf("failed to backup %s: %w", path, err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create %s: %w", filepath.Dir(path), err)
+	}
+	if err := os.WriteFile(path, []byte(content), mode); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	PrintSuccess("Created %s", path)
+	return nil
+}
+
+// downloadFile fetches url into path (honours HTTP(S)_PROXY)
+func downloadFile(url, path string) error {
+	client := &http.Client{Timeout: 5 * time.Minute}
+	resp, err := client.Get(url)
+	if err != nil {
+		return fmt.Errorf("download failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("download failed: %s", resp.Status)
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", path, err)
+	}
+	defer f.Close()
+
+	if _, err := io.Copy(f, resp.Body); err != nil {
+		return fmt.Errorf("download failed: %w", err)
+	}
+	return nil
+}
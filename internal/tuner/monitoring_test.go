@@ -0,0 +1,32 @@
+package tuner
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestVerifySHA256(t *testing.T) {
+	dir := t.TempDir()
+	tarball := filepath.Join(dir, "node_exporter-1.8.2.linux-amd64.tar.gz")
+	sums := filepath.Join(dir, "sha256sums.txt")
+	os.WriteFile(tarball, []byte("release"), 0644)
+
+	// sha256("release")
+	const sum = "a4d451ec23463726f72c43d64c710968f6b602cd653b4de8adee1b556240a829"
+	os.WriteFile(sums, []byte(sum+"  node_exporter-1.8.2.linux-arm64.tar.gz\n"), 0644)
+	if err := verifySHA256(tarball, sums); !errors.Is(err, ErrValidationFailed) {
+		t.Errorf("tarball not listed: %v", err)
+	}
+
+	os.WriteFile(sums, []byte(sum+"  node_exporter-1.8.2.linux-amd64.tar.gz\n"), 0644)
+	if err := verifySHA256(tarball, sums); err != nil {
+		t.Errorf("matching tarball: %v", err)
+	}
+
+	os.WriteFile(sums, []byte("0000  node_exporter-1.8.2.linux-amd64.tar.gz\n"), 0644)
+	if err := verifySHA256(tarball, sums); !errors.Is(err, ErrValidationFailed) {
+		t.Errorf("mismatch accepted: %v", err)
+	}
+}
@@ -0,0 +1,361 @@
+package tuner
+
+import (
+	"fmt"
+	"runtime"
+	"strings"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// defaultCoalesceUsecs is the vmxnet3 interrupt coalescing (rx-usecs, tx-usecs)
+const defaultCoalesceUsecs = 10
+
+// maxCoalesceUsecs bounds the configured coalescing: beyond a millisecond the
+// added latency is never worth the saved interrupts
+const maxCoalesceUsecs = 1000
+
+// CoalesceOff is the coalescing value turning it off (rx_usecs: 0 in the
+// config file): zero means unset, as for the other settings
+const CoalesceOff = -1
+
+// NICSettings are the vmxnet3 ring sizes and interrupt coalescing applied by
+// net-apply
+type NICSettings struct {
+	RxRing  int // ethtool -G rx, capped by the adapter maximum
+	TxRing  int
+	RxUsecs int // ethtool -C rx-usecs, CoalesceOff for none
+	TxUsecs int
+}
+
+// defaultNIC is used when neither the config file nor the profile sets a value
+var defaultNIC = NICSettings{RxRing: defaultRxRing, TxRing: defaultTxRing, RxUsecs: defaultCoalesceUsecs, TxUsecs: defaultCoalesceUsecs}
+
+// merge returns ns with the unset values taken from below
+func (ns NICSettings) merge(below NICSettings) NICSettings {
+	if ns.RxRing == 0 {
+		ns.RxRing = below.RxRing
+	}
+	if ns.TxRing == 0 {
+		ns.TxRing = below.TxRing
+	}
+	if ns.RxUsecs == 0 {
+		ns.RxUsecs = below.RxUsecs
+	}
+	if ns.TxUsecs == 0 {
+		ns.TxUsecs = below.TxUsecs
+	}
+	return ns
+}
+
+// Usecs returns the rx-usecs and tx-usecs to set
+func (ns NICSettings) Usecs() (int, int) {
+	usecs := func(v int) int {
+		if v == CoalesceOff {
+			return 0
+		}
+		return v
+	}
+	return usecs(ns.RxUsecs), usecs(ns.TxUsecs)
+}
+
+// vmxnet3Offloads are turned on by net-apply: segmentation and receive
+// aggregation in the virtual NIC instead of the guest CPU
+var vmxnet3Offloads = []struct {
+	Name string
+	Cmd  uint32
+}{
+	{"gso", unix.ETHTOOL_SGSO},
+	{"gro", unix.ETHTOOL_SGRO},
+	{"tso", unix.ETHTOOL_STSO},
+}
+
+// ethtoolRingparam is struct ethtool_ringparam
+type ethtoolRingparam struct {
+	Cmd               uint32
+	RxMaxPending      uint32
+	RxMiniMaxPending  uint32
+	RxJumboMaxPending uint32
+	TxMaxPending      uint32
+	RxPending         uint32
+	RxMiniPending     uint32
+	RxJumboPending    uint32
+	TxPending         uint32
+}
+
+// ethtoolValue is struct ethtool_value (offload on/off)
+type ethtoolValue struct {
+	Cmd  uint32
+	Data uint32
+}
+
+// ethtoolCoalesce is struct ethtool_coalesce: only the usecs are changed,
+// the other fields are written back as read
+type ethtoolCoalesce struct {
+	Cmd             uint32
+	RxCoalesceUsecs uint32
+	RxMaxFrames     uint32
+	RxUsecsIrq      uint32
+	RxMaxFramesIrq  uint32
+	TxCoalesceUsecs uint32
+	Rest            [17]uint32
+}
+
+// ethtoolIfreq is struct ifreq carrying a pointer to the ethtool command
+type ethtoolIfreq struct {
+	Name [unix.IFNAMSIZ]byte
+	Data unsafe.Pointer
+	_    [24]byte // the rest of the ifreq union, whatever the word size
+}
+
+// ethtool runs the SIOCETHTOOL requests of net-apply (replaced in tests)
+type ethtool interface {
+	Rings(iface string) (ethtoolRingparam, error)
+	SetRings(iface string, ring ethtoolRingparam) error
+	SetOffload(iface string, cmd uint32, on bool) error
+	Coalesce(iface string) (ethtoolCoalesce, error)
+	SetCoalesce(iface string, c ethtoolCoalesce) error
+}
+
+// ioctlEthtool sends the requests through an AF_INET socket, like ethtool
+type ioctlEthtool struct{}
+
+// request runs one SIOCETHTOOL ioctl; cmd points to the command structure
+func (ioctlEthtool) request(iface string, cmd unsafe.Pointer) error {
+	if len(iface) >= unix.IFNAMSIZ {
+		return fmt.Errorf("interface name too long: %s", iface)
+	}
+	fd, err := unix.Socket(unix.AF_INET, unix.SOCK_DGRAM|unix.SOCK_CLOEXEC, 0)
+	if err != nil {
+		return fmt.Errorf("failed to open a socket: %w", err)
+	}
+	defer unix.Close(fd)
+
+	ifr := ethtoolIfreq{Data: cmd}
+	copy(ifr.Name[:], iface)
+	_, _, errno := unix.Syscall(unix.SYS_IOCTL, uintptr(fd), uintptr(unix.SIOCETHTOOL), uintptr(unsafe.Pointer(&ifr)))
+	runtime.KeepAlive(&ifr)
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+func (e ioctlEthtool) Rings(iface string) (ethtoolRingparam, error) {
+	ring := ethtoolRingparam{Cmd: unix.ETHTOOL_GRINGPARAM}
+	err := e.request(iface, unsafe.Pointer(&ring))
+	return ring, err
+}
+
+func (e ioctlEthtool) SetRings(iface string, ring ethtoolRingparam) error {
+	ring.Cmd = unix.ETHTOOL_SRINGPARAM
+	return e.request(iface, unsafe.Pointer(&ring))
+}
+
+func (e ioctlEthtool) SetOffload(iface string, cmd uint32, on bool) error {
+	value := ethtoolValue{Cmd: cmd}
+	if on {
+		value.Data = 1
+	}
+	return e.request(iface, unsafe.Pointer(&value))
+}
+
+func (e ioctlEthtool) Coalesce(iface string) (ethtoolCoalesce, error) {
+	c := ethtoolCoalesce{Cmd: unix.ETHTOOL_GCOALESCE}
+	err := e.request(iface, unsafe.Pointer(&c))
+	return c, err
+}
+
+func (e ioctlEthtool) SetCoalesce(iface string, c ethtoolCoalesce) error {
+	c.Cmd = unix.ETHTOOL_SCOALESCE
+	return e.request(iface, unsafe.Pointer(&c))
+}
+
+// NetApplyTuner applies the ring buffers, offloads and interrupt coalescing
+// of the vmxnet3 NICs at boot, then places their queues on the vCPUs
+// (vmware-tuner net-apply, run by network-tuning.service)
+type NetApplyTuner struct {
+	RxRing    int
+	TxRing    int
+	RxUsecs   int // interrupt coalescing, 0 for none
+	TxUsecs   int
+	Affinity  string             // queue placement (spread or pin), "" to leave the queues alone
+	Selection InterfaceSelection // empty: every vmxnet3 interface
+	FSRoot    string             // "" for /
+	ethtool   ethtool
+}
+
+// NewNetApplyTuner creates the boot-time NIC configuration
+func NewNetApplyTuner(nic NICSettings) *NetApplyTuner {
+	rxUsecs, txUsecs := nic.Usecs()
+	return &NetApplyTuner{
+		RxRing:  nic.RxRing,
+		TxRing:  nic.TxRing,
+		RxUsecs: rxUsecs,
+		TxUsecs: txUsecs,
+		ethtool: ioctlEthtool{},
+	}
+}
+
+// VMXNET3Interfaces returns the interfaces driven by vmxnet3, whatever their
+// name, except the excluded ones. Passthrough NICs keep their driver defaults (see tunePassthroughNICs).
+func VMXNET3Interfaces(fsRoot string) ([]string, error) {
+	all, err := ListInterfaces(fsRoot)
+	if err != nil {
+		return nil, err
+	}
+	var interfaces []string
+	for _, iface := range all {
+		if iface.Driver == "vmxnet3" && !Excluded.Matches(ExcludeInterface, iface.Name) {
+			interfaces = append(interfaces, iface.Name)
+		}
+	}
+	return interfaces, nil
+}
+
+// ringSize caps a requested ring at what the adapter accepts
+func ringSize(want int, max uint32) uint32 {
+	if max > 0 && uint32(want) > max {
+		return max
+	}
+	return uint32(want)
+}
+
+// configure applies the settings to one interface and returns what failed
+func (na *NetApplyTuner) configure(iface string) []string {
+	var failed []string
+
+	if ring, err := na.ethtool.Rings(iface); err != nil {
+		failed = append(failed, fmt.Sprintf("rings: %v", err))
+	} else {
+		rx, tx := ringSize(na.RxRing, ring.RxMaxPending), ringSize(na.TxRing, ring.TxMaxPending)
+		if ring.RxPending != rx || ring.TxPending != tx {
+			ring.RxPending, ring.TxPending = rx, tx
+			if err := na.ethtool.SetRings(iface, ring); err != nil {
+				failed = append(failed, fmt.Sprintf("rings rx %d tx %d: %v", rx, tx, err))
+			}
+		}
+	}
+
+	for _, o := range vmxnet3Offloads {
+		if err := na.ethtool.SetOffload(iface, o.Cmd, true); err != nil {
+			failed = append(failed, fmt.Sprintf("%s on: %v", o.Name, err))
+		}
+	}
+
+	if c, err := na.ethtool.Coalesce(iface); err != nil {
+		failed = append(failed, fmt.Sprintf("coalescing: %v", err))
+	} else if c.RxCoalesceUsecs != uint32(na.RxUsecs) || c.TxCoalesceUsecs != uint32(na.TxUsecs) {
+		c.RxCoalesceUsecs, c.TxCoalesceUsecs = uint32(na.RxUsecs), uint32(na.TxUsecs)
+		if err := na.ethtool.SetCoalesce(iface, c); err != nil {
+			failed = append(failed, fmt.Sprintf("coalescing rx-usecs %d tx-usecs %d: %v", na.RxUsecs, na.TxUsecs, err))
+		}
+	}
+	return failed
+}
+
+// interfaces returns the selected vmxnet3 interfaces. Other drivers are
+// skipped even when selected: the ring settings hang some e1000 adapters.
+func (na *NetApplyTuner) interfaces() ([]string, error) {
+	return na.selected(true)
+}
+
+// selected returns the selected vmxnet3 interfaces, printing the missing and
+// skipped ones when report is set
+func (na *NetApplyTuner) selected(report bool) ([]string, error) {
+	all, err := ListInterfaces(na.FSRoot)
+	if err != nil {
+		return nil, err
+	}
+	if report {
+		reportExcludedInterfaces("network", all)
+	}
+	candidates := all
+	if !na.Selection.Empty() {
+		candidates, _ = na.Selection.Select(all)
+		for _, name := range na.Selection.Missing(all) {
+			if report {
+				PrintWarning("Interface %s not found", name)
+			}
+		}
+	}
+
+	var interfaces []string
+	for _, iface := range candidates {
+		if Excluded.Matches(ExcludeInterface, iface.Name) {
+			continue
+		}
+		if iface.Driver == "vmxnet3" {
+			interfaces = append(interfaces, iface.Name)
+		} else if report && !na.Selection.Empty() {
+			PrintInfo("Skipping %s: driver %s (only vmxnet3 is configured)", iface.Name, iface.Driver)
+		}
+	}
+	return interfaces, nil
+}
+
+// Validate checks the ring sizes against the maximums the adapters report
+// (ethtool -g), before the unit applying them at every boot is written
+func (na *NetApplyTuner) Validate() error {
+	interfaces, err := na.selected(false)
+	if err != nil {
+		return err
+	}
+	var problems []string
+	for _, iface := range interfaces {
+		ring, err := na.ethtool.Rings(iface)
+		if err != nil {
+			PrintWarning("%s: could not read the ring maximums: %v", iface, err)
+			continue
+		}
+		if ring.RxMaxPending > 0 && uint32(na.RxRing) > ring.RxMaxPending {
+			problems = append(problems, fmt.Sprintf("%s: rx ring %d above the maximum %d", iface, na.RxRing, ring.RxMaxPending))
+		}
+		if ring.TxMaxPending > 0 && uint32(na.TxRing) > ring.TxMaxPending {
+			problems = append(problems, fmt.Sprintf("%s: tx ring %d above the maximum %d", iface, na.TxRing, ring.TxMaxPending))
+		}
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("%w: %s (network.rx_ring, network.tx_ring)", ErrValidationFailed, strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// Run configures the vmxnet3 interfaces. A setting the adapter refuses is
+// reported and the others are still applied.
+func (na *NetApplyTuner) Run() error {
+	interfaces, err := na.interfaces()
+	if err != nil {
+		return err
+	}
+	if len(interfaces) == 0 {
+		PrintInfo("No vmxnet3 interface")
+		return nil
+	}
+
+	failCount := 0
+	for _, iface := range interfaces {
+		if failed := na.configure(iface); len(failed) > 0 {
+			for _, f := range failed {
+				PrintWarning("%s: %s", iface, f)
+			}
+			failCount++
+			continue
+		}
+		PrintSuccess("Configured %s (rings %d/%d, gso/gro/tso on, coalescing rx %dus tx %dus)", iface, na.RxRing, na.TxRing, na.RxUsecs, na.TxUsecs)
+	}
+
+	// Last: the ring settings reset the adapter and its interrupts
+	if na.Affinity != "" {
+		at := &AffinityTuner{Selection: na.Selection, FSRoot: na.FSRoot}
+		if err := at.applyPlan(na.Affinity, at.manageIRQs(na.Affinity)); err != nil {
+			PrintWarning("NIC affinity: %v", err)
+			failCount++
+		}
+	}
+	if failCount > 0 {
+		return fmt.Errorf("failed to configure %d interface(s)", failCount)
+	}
+	return nil
+}
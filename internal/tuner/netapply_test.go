@@ -0,0 +1,249 @@
+package tuner
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+
+	"golang.org/x/sys/unix"
+)
+
+// fakeEthtool records the settings applied per interface
+type fakeEthtool struct {
+	rings    map[string]ethtoolRingparam
+	coalesce map[string]ethtoolCoalesce
+	offloads map[string][]uint32
+	refuse   uint32 // offload command failing with EOPNOTSUPP
+}
+
+func newFakeEthtool() *fakeEthtool {
+	return &fakeEthtool{
+		rings:    make(map[string]ethtoolRingparam),
+		coalesce: make(map[string]ethtoolCoalesce),
+		offloads: make(map[string][]uint32),
+	}
+}
+
+func (f *fakeEthtool) Rings(iface string) (ethtoolRingparam, error) {
+	ring, ok := f.rings[iface]
+	if !ok {
+		return ring, unix.ENODEV
+	}
+	return ring, nil
+}
+
+func (f *fakeEthtool) SetRings(iface string, ring ethtoolRingparam) error {
+	f.rings[iface] = ring
+	return nil
+}
+
+func (f *fakeEthtool) SetOffload(iface string, cmd uint32, on bool) error {
+	if cmd == f.refuse {
+		return unix.EOPNOTSUPP
+	}
+	if on {
+		f.offloads[iface] = append(f.offloads[iface], cmd)
+	}
+	return nil
+}
+
+func (f *fakeEthtool) Coalesce(iface string) (ethtoolCoalesce, error) {
+	return f.coalesce[iface], nil
+}
+
+func (f *fakeEthtool) SetCoalesce(iface string, c ethtoolCoalesce) error {
+	f.coalesce[iface] = c
+	return nil
+}
+
+// netFixture creates /sys/class/net with the interfaces and their driver
+func netFixture(t *testing.T, drivers map[string]string) string {
+	t.Helper()
+	root := t.TempDir()
+	for iface, driver := range drivers {
+		dir := filepath.Join(root, "sys/class/net", iface)
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+		if driver == "" {
+			continue // virtual interface (lo, bridges): no device
+		}
+		if err := os.MkdirAll(filepath.Join(dir, "device"), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Symlink("../../../bus/pci/drivers/"+driver, filepath.Join(dir, "device", "driver")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return root
+}
+
+func TestVMXNET3Interfaces(t *testing.T) {
+	root := netFixture(t, map[string]string{
+		"lo": "", "ens192": "vmxnet3", "ens224": "e1000e", "enp11s0": "vmxnet3", "br0": "",
+	})
+	got, err := VMXNET3Interfaces(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"enp11s0", "ens192"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("interfaces = %v, want %v (any name, vmxnet3 only)", got, want)
+	}
+}
+
+func TestNetApplyRun(t *testing.T) {
+	root := netFixture(t, map[string]string{"ens192": "vmxnet3", "ens224": "e1000e"})
+	fake := newFakeEthtool()
+	fake.rings["ens192"] = ethtoolRingparam{RxMaxPending: 4096, TxMaxPending: 2048, RxPending: 1024, TxPending: 512}
+	fake.coalesce["ens192"] = ethtoolCoalesce{RxCoalesceUsecs: 50, TxCoalesceUsecs: 50, RxMaxFrames: 7}
+
+	na := &NetApplyTuner{RxRing: 4096, TxRing: 4096, RxUsecs: defaultCoalesceUsecs, TxUsecs: defaultCoalesceUsecs, FSRoot: root, ethtool: fake}
+	if err := na.Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	ring := fake.rings["ens192"]
+	if ring.RxPending != 4096 || ring.TxPending != 2048 {
+		t.Errorf("rings = %d/%d, want 4096/2048 (tx capped at the adapter maximum)", ring.RxPending, ring.TxPending)
+	}
+	want := []uint32{unix.ETHTOOL_SGSO, unix.ETHTOOL_SGRO, unix.ETHTOOL_STSO}
+	if got := fake.offloads["ens192"]; !reflect.DeepEqual(got, want) {
+		t.Errorf("offloads = %v, want %v", got, want)
+	}
+	c := fake.coalesce["ens192"]
+	if c.RxCoalesceUsecs != defaultCoalesceUsecs || c.TxCoalesceUsecs != defaultCoalesceUsecs || c.RxMaxFrames != 7 {
+		t.Errorf("coalescing = %+v, want %dus and the other fields kept", c, defaultCoalesceUsecs)
+	}
+	if _, ok := fake.rings["ens224"]; ok {
+		t.Error("e1000e interface should not be touched")
+	}
+}
+
+func TestNetApplyAffinity(t *testing.T) {
+	savedIrqbalance := irqbalanceActive
+	defer func() { irqbalanceActive = savedIrqbalance }()
+	irqbalanceActive = func() bool { return false }
+
+	root := affinityFixture(t)
+	fake := newFakeEthtool()
+	fake.rings["ens192"] = ethtoolRingparam{RxMaxPending: 4096, TxMaxPending: 4096}
+	na := &NetApplyTuner{RxRing: 1024, TxRing: 1024, Affinity: AffinityPin, FSRoot: root, ethtool: fake}
+	if err := na.Run(); err != nil {
+		t.Fatal(err)
+	}
+	for path, want := range map[string]string{
+		"proc/irq/57/smp_affinity_list":             "1",
+		"sys/class/net/ens192/queues/rx-0/rps_cpus": "00000000",
+	} {
+		if data, _ := os.ReadFile(filepath.Join(root, path)); string(data) != want {
+			t.Errorf("%s = %q, want %q", path, data, want)
+		}
+	}
+}
+
+func TestNetApplyPartialFailure(t *testing.T) {
+	root := netFixture(t, map[string]string{"ens192": "vmxnet3", "ens256": "vmxnet3"})
+	fake := newFakeEthtool()
+	fake.rings["ens192"] = ethtoolRingparam{RxMaxPending: 4096, TxMaxPending: 4096}
+	fake.rings["ens256"] = ethtoolRingparam{RxMaxPending: 4096, TxMaxPending: 4096}
+	fake.refuse = unix.ETHTOOL_STSO
+
+	na := &NetApplyTuner{RxRing: 2048, TxRing: 2048, RxUsecs: defaultCoalesceUsecs, TxUsecs: defaultCoalesceUsecs, FSRoot: root, ethtool: fake}
+	err := na.Run()
+	if err == nil || !strings.Contains(err.Error(), "2 interface(s)") {
+		t.Fatalf("err = %v, want both interfaces reported", err)
+	}
+	for _, iface := range []string{"ens192", "ens256"} {
+		if fake.rings[iface].RxPending != 2048 || fake.coalesce[iface].RxCoalesceUsecs != defaultCoalesceUsecs {
+			t.Errorf("%s: the other settings should still be applied", iface)
+		}
+	}
+}
+
+func TestNetApplySelection(t *testing.T) {
+	root := netFixture(t, map[string]string{"ens192": "vmxnet3", "ens256": "vmxnet3", "eno1": "igb"})
+	fake := newFakeEthtool()
+	fake.rings["ens192"] = ethtoolRingparam{RxMaxPending: 4096, TxMaxPending: 4096}
+	fake.rings["ens256"] = ethtoolRingparam{RxMaxPending: 4096, TxMaxPending: 4096}
+
+	na := &NetApplyTuner{RxRing: 2048, TxRing: 2048, FSRoot: root, ethtool: fake,
+		Selection: InterfaceSelection{Names: []string{"ens256", "eno1"}}}
+	if err := na.Run(); err != nil {
+		t.Fatal(err)
+	}
+	if fake.rings["ens256"].RxPending != 2048 {
+		t.Error("selected vmxnet3 interface should be configured")
+	}
+	if fake.rings["ens192"].RxPending != 0 {
+		t.Error("ens192 is not selected and should not be touched")
+	}
+	if len(fake.offloads["eno1"]) != 0 {
+		t.Error("igb interface should be skipped even when selected")
+	}
+}
+
+func TestNetworkServiceRunsNetApply(t *testing.T) {
+	unit := NewNetworkTuner(false).GetSystemdService("/opt/bin/vmware-tuner")
+	rx, tx := Tuning.Rings()
+	for _, want := range []string{
+		"RemainAfterExit=yes",
+		"ExecStart=-/opt/bin/vmware-tuner net-apply --rx-ring " + strconv.Itoa(rx) + " --tx-ring " + strconv.Itoa(tx) + " --rx-usecs 10 --tx-usecs 10 --affinity spread\n",
+	} {
+		if !strings.Contains(unit, want) {
+			t.Errorf("unit missing %q:\n%s", want, unit)
+		}
+	}
+	if strings.Contains(unit, "/bin/bash") || strings.Contains(unit, "grep") || strings.Contains(unit, legacyAffinityScript) {
+		t.Errorf("unit should not depend on bash, grep or the affinity script:\n%s", unit)
+	}
+
+	saved := Tuning
+	defer func() { Tuning = saved }()
+	Tuning.Interfaces = InterfaceSelection{Names: []string{"eno1"}}
+	unit = NewNetworkTuner(false).GetSystemdService("/opt/bin/vmware-tuner")
+	if !strings.Contains(unit, " --interfaces eno1\n") {
+		t.Errorf("unit should pass the interface selection to net-apply:\n%s", unit)
+	}
+}
+
+func TestNICSettings(t *testing.T) {
+	tc := TuningConfig{RxRing: 2048, TxUsecs: 25}
+	if got, want := tc.NICSettings(), (NICSettings{RxRing: 2048, TxRing: 4096, RxUsecs: 10, TxUsecs: 25}); got != want {
+		t.Errorf("default profile: %+v, want %+v", got, want)
+	}
+
+	tc = TuningConfig{Profile: "low-latency"}
+	if rx, tx := tc.NICSettings().Usecs(); rx != 0 || tx != 0 {
+		t.Errorf("low-latency coalescing = %d/%d, want off", rx, tx)
+	}
+	na := NewNetApplyTuner(tc.NICSettings())
+	if na.RxUsecs != 0 || na.RxRing != 4096 {
+		t.Errorf("net-apply = %+v, want rings 4096 and no coalescing", na)
+	}
+
+	tc.RxUsecs = 30
+	if rx, _ := tc.NICSettings().Usecs(); rx != 30 {
+		t.Errorf("the config file should win over the profile, got rx-usecs %d", rx)
+	}
+}
+
+func TestNetApplyValidate(t *testing.T) {
+	root := netFixture(t, map[string]string{"ens192": "vmxnet3", "ens224": "e1000e"})
+	fake := newFakeEthtool()
+	fake.rings["ens192"] = ethtoolRingparam{RxMaxPending: 4096, TxMaxPending: 2048}
+
+	na := &NetApplyTuner{RxRing: 4096, TxRing: 2048, FSRoot: root, ethtool: fake}
+	if err := na.Validate(); err != nil {
+		t.Errorf("rings within the maximums: %v", err)
+	}
+
+	na.TxRing = 4096
+	err := na.Validate()
+	if !errors.Is(err, ErrValidationFailed) || !strings.Contains(err.Error(), "ens192: tx ring 4096 above the maximum 2048") {
+		t.Errorf("err = %v, want the tx ring refused", err)
+	}
+}
@@ -0,0 +1,474 @@
+package tuner
+
+import (
+	"context"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// VCenterConfig is the vCenter endpoint checked by netcheck (vcenter: section of the config)
+type VCenterConfig struct {
+	Host string
+	Port int
+}
+
+// decode reads the vcenter: section
+func (vc *VCenterConfig) decode(fields map[string]interface{}) error {
+	var err error
+	if vc.Host, err = yamlString(fields["host"]); err != nil {
+		return fmt.Errorf("host: %w", err)
+	}
+	if vc.Port, err = yamlInt(fields["port"]); err != nil {
+		return fmt.Errorf("port: %w", err)
+	}
+	return nil
+}
+
+// NetCheckResult is one line of the connectivity matrix
+type NetCheckResult struct {
+	Check   string
+	Target  string
+	OK      bool
+	Latency time.Duration
+	Detail  string
+}
+
+// NetCheckTuner diagnoses why the VM is offline
+type NetCheckTuner struct {
+	Distro  *DistroManager
+	VCenter VCenterConfig
+	Timeout time.Duration
+}
+
+// NewNetCheckTuner creates a new connectivity diagnostics tuner
+func NewNetCheckTuner(distro *DistroManager, vcenter VCenterConfig) *NetCheckTuner {
+	return &NetCheckTuner{
+		Distro:  distro,
+		VCenter: vcenter,
+		Timeout: 3 * time.Second,
+	}
+}
+
+// Run prints the pass/fail matrix and fails when a check fails
+func (nt *NetCheckTuner) Run() error {
+	PrintStep("Connectivity Diagnostics")
+
+	results := nt.Collect()
+
+	fmt.Printf("  %-9s %-40s %-6s %9s  %s\n", "CHECK", "TARGET", "STATUS", "LATENCY", "DETAIL")
+	failed := 0
+	for _, r := range results {
+		status := colorSuccess.Sprint("PASS")
+		if !r.OK {
+			status = colorError.Sprint("FAIL")
+			failed++
+		}
+		latency := "-"
+		if r.Latency > 0 {
+			latency = fmt.Sprintf("%.1f ms", float64(r.Latency.Microseconds())/1000)
+		}
+		fmt.Printf("  %-9s %-40s %s   %9s  %s\n", r.Check, truncate(r.Target, 40), status, latency, r.Detail)
+	}
+	fmt.Println()
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d connectivity checks failed", failed, len(results))
+	}
+	PrintSuccess("All %d connectivity checks passed", len(results))
+	return nil
+}
+
+// Collect runs every check
+func (nt *NetCheckTuner) Collect() []NetCheckResult {
+	var results []NetCheckResult
+
+	repos := nt.repoURLs()
+
+	lookup := "deb.debian.org"
+	if len(repos) > 0 {
+		if u, err := url.Parse(repos[0]); err == nil {
+			lookup = u.Hostname()
+		}
+	}
+	results = append(results, nt.checkDNS(lookup)...)
+	results = append(results, nt.checkGateway())
+	if r, ok := nt.checkProxy(); ok {
+		results = append(results, r)
+	}
+	for _, repo := range repos {
+		results = append(results, nt.checkHTTP("repo", repo))
+	}
+	for _, server := range ntpServers() {
+		results = append(results, nt.checkNTP(server))
+	}
+	if nt.VCenter.Host != "" {
+		results = append(results, nt.checkVCenter())
+	}
+	return results
+}
+
+// checkDNS resolves name through each nameserver of /etc/resolv.conf
+func (nt *NetCheckTuner) checkDNS(name string) []NetCheckResult {
+	data, _ := os.ReadFile("/etc/resolv.conf")
+	var servers []string
+	for _, line := range strings.Split(string(data), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) >= 2 && fields[0] == "nameserver" {
+			servers = append(servers, fields[1])
+		}
+	}
+	if len(servers) == 0 {
+		return []NetCheckResult{{Check: "dns", Target: "/etc/resolv.conf", Detail: "no nameserver configured"}}
+	}
+
+	var results []NetCheckResult
+	for _, server := range servers {
+		server := server
+		resolver := &net.Resolver{
+			PreferGo: true,
+			Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+				d := net.Dialer{Timeout: nt.Timeout}
+				return d.DialContext(ctx, network, net.JoinHostPort(server, "53"))
+			},
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), nt.Timeout)
+		start := time.Now()
+		addrs, err := resolver.LookupHost(ctx, name)
+		cancel()
+
+		r := NetCheckResult{Check: "dns", Target: server, Latency: time.Since(start)}
+		if err != nil {
+			r.Latency = 0
+			r.Detail = fmt.Sprintf("%s: %v", name, shortError(err))
+		} else {
+			r.OK = true
+			r.Detail = fmt.Sprintf("%s -> %s", name, addrs[0])
+		}
+		results = append(results, r)
+	}
+	return results
+}
+
+var pingTime = regexp.MustCompile(`time=([0-9.]+) ms`)
+
+// checkGateway pings the default gateway once
+func (nt *NetCheckTuner) checkGateway() NetCheckResult {
+	r := NetCheckResult{Check: "gateway"}
+	gateway, err := getGateway()
+	if err != nil {
+		r.Target = "default route"
+		r.Detail = err.Error()
+		return r
+	}
+	r.Target = gateway
+
+	wait := strconv.Itoa(int(nt.Timeout.Seconds()))
+	out, err := exec.Command("ping", "-c", "1", "-W", wait, gateway).CombinedOutput()
+	if err != nil {
+		r.Detail = "no reply to ping (may be filtered)"
+		return r
+	}
+	r.OK = true
+	if m := pingTime.FindStringSubmatch(string(out)); m != nil {
+		ms, _ := strconv.ParseFloat(m[1], 64)
+		r.Latency = time.Duration(ms * float64(time.Millisecond))
+	}
+	return r
+}
+
+// checkProxy opens a TCP connection to the configured proxy, if any
+func (nt *NetCheckTuner) checkProxy() (NetCheckResult, bool) {
+	proxy := proxyEnv("HTTPS_PROXY")
+	if proxy == "" {
+		proxy = proxyEnv("HTTP_PROXY")
+	}
+	if proxy == "" {
+		return NetCheckResult{}, false
+	}
+
+	// The matrix shows the proxy without its password
+	r := NetCheckResult{Check: "proxy", Target: maskURLCredentials(proxy)}
+	u, err := url.Parse(proxy)
+	if err != nil || u.Host == "" {
+		r.Detail = "invalid proxy URL"
+		return r, true
+	}
+	host := u.Host
+	if u.Port() == "" {
+		host = net.JoinHostPort(u.Hostname(), "80")
+	}
+
+	start := time.Now()
+	conn, err := net.DialTimeout("tcp", host, nt.Timeout)
+	if err != nil {
+		r.Detail = shortError(err)
+		return r, true
+	}
+	conn.Close()
+	r.OK = true
+	r.Latency = time.Since(start)
+	r.Detail = "TCP connect OK"
+	return r, true
+}
+
+// checkHTTP sends a HEAD request (through the proxy, if any). Any HTTP answer
+// proves the endpoint is reachable.
+func (nt *NetCheckTuner) checkHTTP(check, target string) NetCheckResult {
+	r, _ := nt.head(check, target)
+	return r
+}
+
+// head performs the HEAD request and also returns the client error
+func (nt *NetCheckTuner) head(check, target string) (NetCheckResult, error) {
+	r := NetCheckResult{Check: check, Target: target}
+	client := &http.Client{Timeout: nt.Timeout}
+
+	start := time.Now()
+	resp, err := client.Head(target)
+	if err != nil {
+		// Errors of the proxy connection may quote its URL
+		r.Detail = maskURLCredentials(httpErrorDetail(err))
+		return r, err
+	}
+	resp.Body.Close()
+	r.OK = true
+	r.Latency = time.Since(start)
+	r.Detail = resp.Status
+	return r, nil
+}
+
+// checkNTP sends an SNTP client request to server:123
+func (nt *NetCheckTuner) checkNTP(server string) NetCheckResult {
+	r := NetCheckResult{Check: "ntp", Target: server}
+
+	conn, err := net.DialTimeout("udp", net.JoinHostPort(server, "123"), nt.Timeout)
+	if err != nil {
+		r.Detail = shortError(err)
+		return r
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(nt.Timeout))
+
+	// LI=0, VN=3, Mode=3 (client)
+	req := make([]byte, 48)
+	req[0] = 0x1b
+	start := time.Now()
+	if _, err := conn.Write(req); err != nil {
+		r.Detail = shortError(err)
+		return r
+	}
+	resp := make([]byte, 48)
+	n, err := conn.Read(resp)
+	if err != nil {
+		r.Detail = "no answer on UDP/123 (firewall?)"
+		return r
+	}
+	r.Latency = time.Since(start)
+	if n < 48 {
+		r.Detail = "short NTP answer"
+		return r
+	}
+	r.OK = true
+	r.Detail = fmt.Sprintf("stratum %d", resp[1])
+	return r
+}
+
+// checkVCenter checks the vCenter HTTPS endpoint. An untrusted certificate
+// still proves the endpoint is reachable.
+func (nt *NetCheckTuner) checkVCenter() NetCheckResult {
+	port := nt.VCenter.Port
+	if port == 0 {
+		port = 443
+	}
+	target := "https://" + net.JoinHostPort(nt.VCenter.Host, strconv.Itoa(port)) + "/"
+	r, err := nt.head("vcenter", target)
+
+	var unknown x509.UnknownAuthorityError
+	if err != nil && errors.As(err, &unknown) {
+		r.OK = true
+		r.Detail = "reachable, certificate not trusted (install the vCenter root CA)"
+	}
+	return r
+}
+
+// repoURLs returns the distinct package repository base URLs (at most 5)
+func (nt *NetCheckTuner) repoURLs() []string {
+	var candidates []string
+	switch nt.Distro.Type {
+	case DistroDebian:
+		files, _ := filepath.Glob("/etc/apt/sources.list.d/*")
+		for _, f := range append([]string{"/etc/apt/sources.list"}, files...) {
+			if data, err := os.ReadFile(f); err == nil {
+				candidates = append(candidates, aptSourceURLs(string(data))...)
+			}
+		}
+	case DistroRHEL:
+		files, _ := filepath.Glob("/etc/yum.repos.d/*.repo")
+		for _, f := range files {
+			if data, err := os.ReadFile(f); err == nil {
+				candidates = append(candidates, yumRepoURLs(string(data))...)
+			}
+		}
+	}
+	return repoBaseURLs(candidates)
+}
+
+// aptSourceURLs returns the URLs of a sources.list or deb822 .sources file
+func aptSourceURLs(content string) []string {
+	var urls []string
+	for _, line := range strings.Split(content, "\n") {
+		fields := strings.Fields(line)
+		switch {
+		case len(fields) >= 2 && (fields[0] == "deb" || fields[0] == "deb-src"):
+			// deb [arch=amd64 signed-by=...] http://... suite
+			for _, field := range fields[1:] {
+				if strings.Contains(field, "://") {
+					urls = append(urls, field)
+					break
+				}
+			}
+		case len(fields) >= 2 && fields[0] == "URIs:":
+			urls = append(urls, fields[1:]...)
+		}
+	}
+	return urls
+}
+
+// yumRepoURLs returns the URLs of the enabled repositories of a .repo file,
+// whatever the place of enabled= in the section. URLs with $releasever or
+// $basearch are left out.
+func yumRepoURLs(content string) []string {
+	var urls, section []string
+	enabled := true
+	flush := func() {
+		if enabled {
+			urls = append(urls, section...)
+		}
+		section, enabled = nil, true
+	}
+	for _, line := range strings.Split(content, "\n") {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "[") {
+			flush()
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			continue
+		}
+		key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+		switch key {
+		case "enabled":
+			enabled = value == "1"
+		case "baseurl", "mirrorlist", "metalink":
+			if value != "" && !strings.Contains(value, "$") {
+				section = append(section, strings.Fields(value)[0])
+			}
+		}
+	}
+	flush()
+	return urls
+}
+
+// repoBaseURLs keeps the distinct http(s) hosts of the URLs (at most 5), or
+// public endpoints when there is none
+func repoBaseURLs(candidates []string) []string {
+	seen := make(map[string]bool)
+	var urls []string
+	for _, c := range candidates {
+		u, err := url.Parse(c)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+			continue
+		}
+		base := u.Scheme + "://" + u.Host + "/"
+		if seen[base] {
+			continue
+		}
+		seen[base] = true
+		urls = append(urls, base)
+		if len(urls) == 5 {
+			break
+		}
+	}
+	if len(urls) == 0 {
+		// Same endpoints as CheckConnectivity
+		urls = []string{"http://deb.debian.org/", "http://mirror.centos.org/", "http://github.com/"}
+	}
+	return urls
+}
+
+// ntpServers returns the NTP servers of chrony or timesyncd (pool.ntp.org by default)
+func ntpServers() []string {
+	var servers []string
+	for _, path := range []string{"/etc/chrony.conf", "/etc/chrony/chrony.conf"} {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		for _, line := range strings.Split(string(data), "\n") {
+			fields := strings.Fields(line)
+			if len(fields) >= 2 && (fields[0] == "server" || fields[0] == "pool") {
+				servers = append(servers, fields[1])
+			}
+		}
+	}
+	if data, err := os.ReadFile("/etc/systemd/timesyncd.conf"); err == nil {
+		for _, line := range strings.Split(string(data), "\n") {
+			if key, value, ok := strings.Cut(strings.TrimSpace(line), "="); ok && key == "NTP" {
+				servers = append(servers, strings.Fields(value)...)
+			}
+		}
+	}
+	if len(servers) == 0 {
+		return []string{"pool.ntp.org"}
+	}
+	if len(servers) > 3 {
+		servers = servers[:3]
+	}
+	return servers
+}
+
+// httpErrorDetail turns an HTTP client error into a short diagnosis
+func httpErrorDetail(err error) string {
+	var unknown x509.UnknownAuthorityError
+	var dnsErr *net.DNSError
+	switch {
+	case errors.As(err, &unknown):
+		return "TLS certificate not trusted (TLS inspection? see Install Enterprise Root CA)"
+	case errors.As(err, &dnsErr):
+		return "DNS resolution failed"
+	case os.IsTimeout(err) || strings.Contains(err.Error(), "Client.Timeout"):
+		return "timeout (firewall or proxy required?)"
+	case strings.Contains(err.Error(), "connection refused"):
+		return "connection refused"
+	}
+	return shortError(err)
+}
+
+// shortError keeps the last part of a wrapped network error
+func shortError(err error) string {
+	msg := err.Error()
+	if idx := strings.LastIndex(msg, ": "); idx != -1 {
+		return msg[idx+2:]
+	}
+	return msg
+}
+
+// truncate shortens s to n characters
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n-3] + "..."
+}
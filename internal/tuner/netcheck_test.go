@@ -0,0 +1,104 @@
+package tuner
+
+import (
+	"errors"
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAptSourceURLs(t *testing.T) {
+	content := `# deb http://commented.example.com/ stable main
+deb [arch=amd64 signed-by=/usr/share/keyrings/docker.gpg] https://download.docker.com/linux/ubuntu jammy stable
+deb-src http://archive.ubuntu.com/ubuntu jammy main
+Types: deb
+URIs: http://mirror.example.com/ubuntu http://security.ubuntu.com/ubuntu
+`
+	want := []string{"https://download.docker.com/linux/ubuntu", "http://archive.ubuntu.com/ubuntu", "http://mirror.example.com/ubuntu", "http://security.ubuntu.com/ubuntu"}
+	if got := aptSourceURLs(content); !reflect.DeepEqual(got, want) {
+		t.Errorf("urls = %v, want %v", got, want)
+	}
+}
+
+func TestYumRepoURLs(t *testing.T) {
+	content := `[baseos]
+baseurl=http://repo.example.com/baseos/ http://backup.example.com/baseos/
+enabled=1
+
+[debug]
+baseurl=http://debug.example.com/
+enabled=0
+
+[appstream]
+mirrorlist=https://mirrors.rockylinux.org/mirrorlist?arch=$basearch
+metalink = https://mirrors.fedoraproject.org/metalink?repo=epel-9
+`
+	want := []string{"http://repo.example.com/baseos/", "https://mirrors.fedoraproject.org/metalink?repo=epel-9"}
+	if got := yumRepoURLs(content); !reflect.DeepEqual(got, want) {
+		t.Errorf("urls = %v, want %v (disabled and templated URLs left out)", got, want)
+	}
+}
+
+func TestRepoBaseURLs(t *testing.T) {
+	got := repoBaseURLs([]string{"http://a.example.com/x", "http://a.example.com/y", "ftp://b.example.com/", "https://c.example.com:8443/repo"})
+	if want := []string{"http://a.example.com/", "https://c.example.com:8443/"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("bases = %v, want %v", got, want)
+	}
+	var many []string
+	for _, host := range []string{"a", "b", "c", "d", "e", "f"} {
+		many = append(many, "http://"+host+".example.com/")
+	}
+	if got := repoBaseURLs(many); len(got) != 5 {
+		t.Errorf("bases = %v, want at most 5", got)
+	}
+	if got := repoBaseURLs(nil); len(got) == 0 {
+		t.Error("public endpoints expected without repositories")
+	}
+}
+
+func TestCheckProxy(t *testing.T) {
+	for _, name := range []string{"HTTPS_PROXY", "https_proxy", "HTTP_PROXY", "http_proxy"} {
+		t.Setenv(name, "")
+	}
+	nt := &NetCheckTuner{Timeout: time.Second}
+	if _, ok := nt.checkProxy(); ok {
+		t.Error("no proxy configured, no check expected")
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Skip(err)
+	}
+	defer listener.Close()
+	t.Setenv("HTTPS_PROXY", "http://svc:s3cret@"+listener.Addr().String())
+	r, ok := nt.checkProxy()
+	if !ok || !r.OK {
+		t.Errorf("reachable proxy: %+v", r)
+	}
+	if strings.Contains(r.Target, "s3cret") || !strings.Contains(r.Target, "svc:***@") {
+		t.Errorf("target %s should hide the password", r.Target)
+	}
+
+	t.Setenv("HTTPS_PROXY", "http://svc:s3cret@")
+	if r, _ := nt.checkProxy(); r.OK || r.Detail != "invalid proxy URL" || strings.Contains(r.Target, "s3cret") {
+		t.Errorf("invalid proxy URL: %+v", r)
+	}
+}
+
+func TestHTTPErrorDetail(t *testing.T) {
+	for _, tc := range []struct {
+		err  error
+		want string
+	}{
+		{&net.DNSError{Err: "no such host", Name: "repo.example.com"}, "DNS resolution failed"},
+		{errors.New("Head \"http://x/\": dial tcp 10.0.0.1:80: connect: connection refused"), "connection refused"},
+		{errors.New("Head \"http://x/\": context deadline exceeded (Client.Timeout exceeded while awaiting headers)"), "timeout (firewall or proxy required?)"},
+		{errors.New("proxyconnect tcp: dial tcp: lookup proxy: i/o error"), "i/o error"},
+	} {
+		if got := httpErrorDetail(tc.err); got != tc.want {
+			t.Errorf("httpErrorDetail(%v) = %q, want %q", tc.err, got, tc.want)
+		}
+	}
+}
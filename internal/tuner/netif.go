@@ -0,0 +1,286 @@
+package tuner
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// ethFlagLRO is ETH_FLAG_LRO of ETHTOOL_GFLAGS (large receive offload)
+const ethFlagLRO = 1 << 15
+
+// ethSSStats and ethGStringLen are ETH_SS_STATS (the string set of the
+// driver statistics) and ETH_GSTRING_LEN
+const (
+	ethSSStats    = 1
+	ethGStringLen = 32
+)
+
+// ethernetPrefixes are the names of ethernet interfaces: kernel names (eth)
+// and predictable names (ens: slot, enp: PCI path, eno: onboard, enx: MAC)
+var ethernetPrefixes = []string{"eth", "ens", "enp", "eno", "enx"}
+
+// NetInterface is a network interface backed by a device
+type NetInterface struct {
+	Name   string
+	Driver string
+}
+
+// isEthernetName reports whether an interface name is an ethernet name
+func isEthernetName(name string) bool {
+	for _, prefix := range ethernetPrefixes {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// ListInterfaces returns the interfaces backed by a device (loopback,
+// bridges, bonds and tunnels have none) with their driver, from sysfs.
+// fsRoot allows running against a fixture tree (empty string for /).
+func ListInterfaces(fsRoot string) ([]NetInterface, error) {
+	sys := SysFS{Root: fsRoot}
+	names, err := sys.NetDevices()
+	if err != nil {
+		return nil, err
+	}
+	var interfaces []NetInterface
+	for _, name := range names {
+		if !sys.Exists("/sys/class/net", name, "device") {
+			continue
+		}
+		interfaces = append(interfaces, NetInterface{Name: name, Driver: interfaceDriver(fsRoot, name)})
+	}
+	sort.Slice(interfaces, func(i, j int) bool { return interfaces[i].Name < interfaces[j].Name })
+	return interfaces, nil
+}
+
+// interfaceDriver returns the driver bound to an interface: the sysfs link,
+// else the driver reported by the kernel (ETHTOOL_GDRVINFO) on the live system
+func interfaceDriver(fsRoot, iface string) string {
+	if link, err := (SysFS{Root: fsRoot}).Readlink("/sys/class/net", iface, "device", "driver"); err == nil {
+		return filepath.Base(link)
+	}
+	if fsRoot != "" {
+		return ""
+	}
+	info, err := ioctlEthtool{}.DriverInfo(iface)
+	if err != nil {
+		return ""
+	}
+	return unix.ByteSliceToString(info.Driver[:])
+}
+
+// DriverInfo returns the driver, version and firmware of an interface
+func (e ioctlEthtool) DriverInfo(iface string) (unix.EthtoolDrvinfo, error) {
+	info := unix.EthtoolDrvinfo{Cmd: unix.ETHTOOL_GDRVINFO}
+	err := e.request(iface, unsafe.Pointer(&info))
+	return info, err
+}
+
+// Offload returns the state of an offload (ETHTOOL_GTSO, ETHTOOL_GGSO...)
+func (e ioctlEthtool) Offload(iface string, cmd uint32) (bool, error) {
+	value := ethtoolValue{Cmd: cmd}
+	err := e.request(iface, unsafe.Pointer(&value))
+	return value.Data != 0, err
+}
+
+// LRO returns whether large receive offload is on
+func (e ioctlEthtool) LRO(iface string) (bool, error) {
+	value := ethtoolValue{Cmd: unix.ETHTOOL_GFLAGS}
+	err := e.request(iface, unsafe.Pointer(&value))
+	return value.Data&ethFlagLRO != 0, err
+}
+
+// Stats returns the driver statistics (ETHTOOL_GSTRINGS, ETHTOOL_GSTATS)
+// in the format of ethtool -S, one "name: value" line each, in the order
+// of the driver
+func (e ioctlEthtool) Stats(iface string) (string, error) {
+	info, err := e.DriverInfo(iface)
+	if err != nil {
+		return "", err
+	}
+	n := int(info.N_stats)
+	if n == 0 {
+		return "", fmt.Errorf("%s reports no statistics", iface)
+	}
+
+	// struct ethtool_gstrings: cmd, string_set, len, then the names
+	names := make([]uint32, 3+n*ethGStringLen/4)
+	names[0], names[1], names[2] = unix.ETHTOOL_GSTRINGS, ethSSStats, uint32(n)
+	if err := e.request(iface, unsafe.Pointer(&names[0])); err != nil {
+		return "", err
+	}
+	// struct ethtool_stats: cmd, n_stats, then the u64 values
+	values := make([]uint64, 1+n)
+	header := (*[2]uint32)(unsafe.Pointer(&values[0]))
+	header[0], header[1] = unix.ETHTOOL_GSTATS, uint32(n)
+	if err := e.request(iface, unsafe.Pointer(&values[0])); err != nil {
+		return "", err
+	}
+
+	raw := unsafe.Slice((*byte)(unsafe.Pointer(&names[3])), n*ethGStringLen)
+	var sb strings.Builder
+	sb.WriteString("NIC statistics:\n")
+	for i := 0; i < n; i++ {
+		name := unix.ByteSliceToString(raw[i*ethGStringLen : (i+1)*ethGStringLen])
+		fmt.Fprintf(&sb, "     %s: %d\n", name, values[1+i])
+	}
+	return sb.String(), nil
+}
+
+// onOff formats a feature state like ethtool
+func onOff(on bool) string {
+	if on {
+		return "on"
+	}
+	return "off"
+}
+
+// ifacePatternRe accepts interface names and their wildcards (ens*, enp?s0)
+var ifacePatternRe = regexp.MustCompile(`^[A-Za-z0-9_.:@*?\[\]-]{1,15}$`)
+
+// InterfaceSelection chooses the interfaces tuned: names or patterns (eno1,
+// ens*) and drivers (vmxnet3). Empty selects the ethernet names.
+type InterfaceSelection struct {
+	Names   []string
+	Drivers []string
+}
+
+// Empty reports whether no interface was selected explicitly
+func (s InterfaceSelection) Empty() bool {
+	return len(s.Names) == 0 && len(s.Drivers) == 0
+}
+
+// Validate checks the names and patterns
+func (s InterfaceSelection) Validate() error {
+	for _, name := range s.Names {
+		if _, err := path.Match(name, ""); err != nil || !ifacePatternRe.MatchString(name) {
+			return fmt.Errorf("invalid interface name %q", name)
+		}
+	}
+	for _, driver := range s.Drivers {
+		if !ifacePatternRe.MatchString(driver) || strings.ContainsAny(driver, "*?[]") {
+			return fmt.Errorf("invalid driver name %q", driver)
+		}
+	}
+	return nil
+}
+
+// Args returns the selection as command line flags (network-tuning.service)
+func (s InterfaceSelection) Args() string {
+	var args []string
+	if len(s.Names) > 0 {
+		args = append(args, "--interfaces "+strings.Join(s.Names, ","))
+	}
+	if len(s.Drivers) > 0 {
+		args = append(args, "--nic-driver "+strings.Join(s.Drivers, ","))
+	}
+	return strings.Join(args, " ")
+}
+
+// String describes the selection for the reports
+func (s InterfaceSelection) String() string {
+	if s.Empty() {
+		return "ethernet names (" + strings.Join(ethernetPrefixes, "*, ") + "*)"
+	}
+	var parts []string
+	if len(s.Names) > 0 {
+		parts = append(parts, "interfaces "+strings.Join(s.Names, ", "))
+	}
+	if len(s.Drivers) > 0 {
+		parts = append(parts, "driver "+strings.Join(s.Drivers, ", "))
+	}
+	return strings.Join(parts, " or ")
+}
+
+// Match reports whether an interface is selected
+func (s InterfaceSelection) Match(iface NetInterface) bool {
+	if Excluded.Matches(ExcludeInterface, iface.Name) {
+		return false
+	}
+	if s.Empty() {
+		return isEthernetName(iface.Name)
+	}
+	for _, pattern := range s.Names {
+		if ok, _ := path.Match(pattern, iface.Name); ok {
+			return true
+		}
+	}
+	for _, driver := range s.Drivers {
+		if iface.Driver == driver {
+			return true
+		}
+	}
+	return false
+}
+
+// SkippedInterface is an interface left out by the selection
+type SkippedInterface struct {
+	Name   string
+	Driver string
+}
+
+// Select splits the interfaces into the selected and the skipped ones. The
+// excluded interfaces are in neither: reportExcludedInterfaces lists them.
+func (s InterfaceSelection) Select(all []NetInterface) ([]NetInterface, []SkippedInterface) {
+	var selected []NetInterface
+	var skipped []SkippedInterface
+	for _, iface := range all {
+		if s.Match(iface) {
+			selected = append(selected, iface)
+		} else if !Excluded.Matches(ExcludeInterface, iface.Name) {
+			skipped = append(skipped, SkippedInterface{Name: iface.Name, Driver: iface.Driver})
+		}
+	}
+	return selected, skipped
+}
+
+// Missing returns the interfaces named explicitly (not patterns) that do not exist
+func (s InterfaceSelection) Missing(all []NetInterface) []string {
+	present := make(map[string]bool)
+	for _, iface := range all {
+		present[iface.Name] = true
+	}
+	var missing []string
+	for _, name := range s.Names {
+		if !strings.ContainsAny(name, "*?[") && !present[name] {
+			missing = append(missing, name)
+		}
+	}
+	return missing
+}
+
+// reportExcludedInterfaces reports the interfaces of the exclusions file
+func reportExcludedInterfaces(module string, all []NetInterface) {
+	for _, iface := range all {
+		SkipExcluded(module, ExcludeInterface, iface.Name)
+	}
+}
+
+// printSkippedInterfaces reports the interfaces the selection leaves alone
+func printSkippedInterfaces(s InterfaceSelection, skipped []SkippedInterface, missing []string) {
+	for _, name := range missing {
+		PrintWarning("Interface %s not found", name)
+	}
+	if len(skipped) == 0 {
+		return
+	}
+	var names []string
+	for _, sk := range skipped {
+		if sk.Driver != "" {
+			names = append(names, fmt.Sprintf("%s (%s)", sk.Name, sk.Driver))
+		} else {
+			names = append(names, sk.Name)
+		}
+	}
+	PrintInfo("Skipped (not matching %s): %s", s, strings.Join(names, ", "))
+	PrintInfo("-> Select interfaces with --interfaces ens192,eno1 or --nic-driver vmxnet3 (network.interfaces / network.drivers in the config file)")
+}
@@ -0,0 +1,76 @@
+package tuner
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestListInterfaces(t *testing.T) {
+	root := netFixture(t, map[string]string{
+		"lo": "", "docker0": "", "ens192": "vmxnet3", "enp0s3": "e1000", "eno1": "igb",
+	})
+	got, err := ListInterfaces(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []NetInterface{{"eno1", "igb"}, {"enp0s3", "e1000"}, {"ens192", "vmxnet3"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("interfaces = %v, want %v (devices only, sorted)", got, want)
+	}
+}
+
+func TestIsEthernetName(t *testing.T) {
+	for name, want := range map[string]bool{
+		"eth0": true, "ens192": true, "enp0s3": true, "eno1": true, "enx001122334455": true,
+		"lo": false, "docker0": false, "wlan0": false, "virbr0": false, "bond0": false,
+	} {
+		if got := isEthernetName(name); got != want {
+			t.Errorf("isEthernetName(%q) = %v, want %v", name, got, want)
+		}
+	}
+}
+
+func TestInterfaceSelection(t *testing.T) {
+	all := []NetInterface{{"br0", "bridge"}, {"eno1", "igb"}, {"enp0s3", "e1000"}, {"ens192", "vmxnet3"}, {"ens224", "vmxnet3"}}
+	names := func(ifaces []NetInterface) []string {
+		var n []string
+		for _, i := range ifaces {
+			n = append(n, i.Name)
+		}
+		return n
+	}
+
+	selected, skipped := InterfaceSelection{}.Select(all)
+	if want := []string{"eno1", "enp0s3", "ens192", "ens224"}; !reflect.DeepEqual(names(selected), want) {
+		t.Errorf("default selection = %v, want %v", names(selected), want)
+	}
+	if len(skipped) != 1 || skipped[0].Name != "br0" {
+		t.Errorf("skipped = %v, want br0", skipped)
+	}
+
+	s := InterfaceSelection{Names: []string{"eno1", "ens1*", "ens999"}}
+	selected, skipped = s.Select(all)
+	if want := []string{"eno1", "ens192"}; !reflect.DeepEqual(names(selected), want) {
+		t.Errorf("selection by name = %v, want %v", names(selected), want)
+	}
+	if len(skipped) != 3 {
+		t.Errorf("skipped = %v, want the three others", skipped)
+	}
+	if missing := s.Missing(all); !reflect.DeepEqual(missing, []string{"ens999"}) {
+		t.Errorf("missing = %v, want ens999 (patterns are never missing)", missing)
+	}
+
+	selected, _ = InterfaceSelection{Drivers: []string{"vmxnet3"}}.Select(all)
+	if want := []string{"ens192", "ens224"}; !reflect.DeepEqual(names(selected), want) {
+		t.Errorf("selection by driver = %v, want %v", names(selected), want)
+	}
+
+	if args := (InterfaceSelection{Names: []string{"eno1", "ens*"}, Drivers: []string{"vmxnet3"}}).Args(); args != "--interfaces eno1,ens* --nic-driver vmxnet3" {
+		t.Errorf("args = %q", args)
+	}
+	for _, bad := range []InterfaceSelection{{Names: []string{"ens 192"}}, {Names: []string{"a-very-long-interface-name"}}, {Drivers: []string{"vmx*"}}} {
+		if bad.Validate() == nil {
+			t.Errorf("%+v should be refused", bad)
+		}
+	}
+}
@@ -0,0 +1,212 @@
+package tuner
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// netStatsBaselineName stores the counters of the previous drop check (in StateDir)
+const netStatsBaselineName = "netstats.json"
+
+// NetStatsSnapshot is a set of interface counters at one point in time
+type NetStatsSnapshot struct {
+	Timestamp  time.Time                    `json:"timestamp"`
+	Interfaces map[string]map[string]uint64 `json:"interfaces"`
+}
+
+// CounterDelta is the change of one drop/error counter between two snapshots
+type CounterDelta struct {
+	Interface string
+	Counter   string
+	Delta     uint64
+	PerSecond float64
+}
+
+// isDropCounter reports whether a counter tracks lost or rejected packets
+func isDropCounter(name string) bool {
+	name = strings.ToLower(name)
+	for _, keyword := range []string{"drop", "err", "discard", "miss", "fifo", "fail", "timeout"} {
+		if strings.Contains(name, keyword) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseEthtoolStats parses `ethtool -S` output. Counters repeated per queue
+// (vmxnet3 prints one block per queue) are summed.
+func parseEthtoolStats(out string) map[string]uint64 {
+	stats := make(map[string]uint64)
+	for _, line := range strings.Split(out, "\n") {
+		idx := strings.LastIndex(line, ":")
+		if idx == -1 {
+			continue
+		}
+		name := strings.TrimSpace(line[:idx])
+		value, err := strconv.ParseUint(strings.TrimSpace(line[idx+1:]), 10, 64)
+		if err != nil || name == "" || strings.Contains(name, "Queue#") {
+			continue
+		}
+		stats[name] += value
+	}
+	return stats
+}
+
+// readInterfaceCounters merges the kernel statistics of sysfs with the driver
+// counters (the ethtool -S ones, read without the tool)
+func readInterfaceCounters(iface string) map[string]uint64 {
+	counters := make(map[string]uint64)
+
+	dir := filepath.Join("/sys/class/net", iface, "statistics")
+	if entries, err := Sys.ReadDir(dir); err == nil {
+		for _, entry := range entries {
+			data, err := Sys.ReadString(dir, entry.Name())
+			if err != nil {
+				continue
+			}
+			if value, err := strconv.ParseUint(data, 10, 64); err == nil {
+				counters[entry.Name()] = value
+			}
+		}
+	}
+
+	if out, err := (ioctlEthtool{}).Stats(iface); err == nil {
+		for name, value := range parseEthtoolStats(out) {
+			counters[name] = value
+		}
+	}
+	return counters
+}
+
+// TakeNetStats reads the counters of the interfaces
+func TakeNetStats(interfaces []string) NetStatsSnapshot {
+	snap := NetStatsSnapshot{
+		Timestamp:  time.Now(),
+		Interfaces: make(map[string]map[string]uint64),
+	}
+	for _, iface := range interfaces {
+		snap.Interfaces[iface] = readInterfaceCounters(iface)
+	}
+	return snap
+}
+
+// DiffNetStats returns the drop/error counters that increased, sorted. ok is
+// false when a counter went backwards (reboot or driver reload): prev is then
+// not a valid baseline.
+func DiffNetStats(prev, cur NetStatsSnapshot) (deltas []CounterDelta, ok bool) {
+	seconds := cur.Timestamp.Sub(prev.Timestamp).Seconds()
+	for iface, counters := range cur.Interfaces {
+		old, found := prev.Interfaces[iface]
+		if !found {
+			continue
+		}
+		for name, value := range counters {
+			before, found := old[name]
+			if !found {
+				continue
+			}
+			if value < before {
+				return nil, false
+			}
+			if value == before || !isDropCounter(name) {
+				continue
+			}
+			d := CounterDelta{Interface: iface, Counter: name, Delta: value - before}
+			if seconds > 0 {
+				d.PerSecond = float64(d.Delta) / seconds
+			}
+			deltas = append(deltas, d)
+		}
+	}
+
+	sort.Slice(deltas, func(i, j int) bool {
+		if deltas[i].Interface != deltas[j].Interface {
+			return deltas[i].Interface < deltas[j].Interface
+		}
+		return deltas[i].Counter < deltas[j].Counter
+	})
+	return deltas, true
+}
+
+// loadNetStatsBaseline reads the previous snapshot, if any
+func loadNetStatsBaseline(path string) (NetStatsSnapshot, bool) {
+	var snap NetStatsSnapshot
+	data, err := os.ReadFile(path)
+	if err != nil || json.Unmarshal(data, &snap) != nil {
+		return snap, false
+	}
+	// Counters restart from zero at boot
+	if boot := bootTime(); !boot.IsZero() && snap.Timestamp.Before(boot) {
+		return snap, false
+	}
+	return snap, true
+}
+
+// saveNetStatsBaseline stores the snapshot for the next run
+func saveNetStatsBaseline(path string, snap NetStatsSnapshot) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(snap, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+// printDeltas prints the counter increases
+func printDeltas(deltas []CounterDelta) {
+	for _, d := range deltas {
+		PrintWarning("  %-10s %-32s +%-10d (%.2f/s)", d.Interface, d.Counter, d.Delta, d.PerSecond)
+	}
+}
+
+// WatchPacketDrops prints the drop/error rates every interval until Ctrl-C
+func (nt *NetworkTuner) WatchPacketDrops(interval time.Duration) error {
+	PrintStep("Watching network packet drops (Ctrl-C to stop)")
+
+	interfaces, err := nt.getNetworkInterfaces()
+	if err != nil {
+		return err
+	}
+
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(stop)
+
+	prev := TakeNetStats(interfaces)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			fmt.Println()
+			return nil
+		case <-ticker.C:
+		}
+
+		cur := TakeNetStats(interfaces)
+		deltas, ok := DiffNetStats(prev, cur)
+		prev = cur
+		stamp := cur.Timestamp.Format("15:04:05")
+		if !ok {
+			PrintInfo("%s counters reset (driver reload?)", stamp)
+			continue
+		}
+		if len(deltas) == 0 {
+			PrintSuccess("%s no drops or errors", stamp)
+			continue
+		}
+		fmt.Printf("%s\n", stamp)
+		printDeltas(deltas)
+	}
+}
@@ -0,0 +1,62 @@
+package tuner
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseEthtoolStats(t *testing.T) {
+	out := `NIC statistics:
+     Tx Queue#: 0
+       TSO pkts tx: 12
+       drv dropped tx total: 3
+     Tx Queue#: 1
+       TSO pkts tx: 8
+       drv dropped tx total: 2
+     tx timeout count: 0
+`
+	stats := parseEthtoolStats(out)
+	if stats["drv dropped tx total"] != 5 {
+		t.Errorf("per-queue counters should be summed, got %d", stats["drv dropped tx total"])
+	}
+	if stats["TSO pkts tx"] != 20 {
+		t.Errorf("TSO pkts tx = %d, want 20", stats["TSO pkts tx"])
+	}
+	if _, ok := stats["Tx Queue#"]; ok {
+		t.Error("queue headers must not be parsed as counters")
+	}
+}
+
+func TestDiffNetStats(t *testing.T) {
+	start := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	prev := NetStatsSnapshot{
+		Timestamp: start,
+		Interfaces: map[string]map[string]uint64{
+			"ens192": {"rx_dropped": 100, "rx_packets": 1000, "tx_errors": 4},
+		},
+	}
+	cur := NetStatsSnapshot{
+		Timestamp: start.Add(10 * time.Second),
+		Interfaces: map[string]map[string]uint64{
+			"ens192": {"rx_dropped": 150, "rx_packets": 5000, "tx_errors": 4},
+			"ens224": {"rx_dropped": 7},
+		},
+	}
+
+	deltas, ok := DiffNetStats(prev, cur)
+	if !ok {
+		t.Fatal("baseline should be valid")
+	}
+	if len(deltas) != 1 {
+		t.Fatalf("expected only the rx_dropped increase, got %+v", deltas)
+	}
+	if d := deltas[0]; d.Counter != "rx_dropped" || d.Delta != 50 || d.PerSecond != 5 {
+		t.Errorf("unexpected delta %+v", d)
+	}
+
+	// A counter going backwards means a reboot or driver reload
+	cur.Interfaces["ens192"]["rx_packets"] = 10
+	if _, ok := DiffNetStats(prev, cur); ok {
+		t.Error("reset counters should invalidate the baseline")
+	}
+}
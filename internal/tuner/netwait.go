@@ -0,0 +1,83 @@
+package tuner
+
+import (
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// NetworkStack is the service bringing the interfaces up at boot
+type NetworkStack struct {
+	Name       string
+	Service    string // unit configuring the interfaces
+	WaitOnline string // unit reaching network-online.target, "" when Service does
+	marker     string // file present when the stack is enabled
+}
+
+// networkStacks are checked in order: NetworkManager wins when several are enabled
+var networkStacks = []NetworkStack{
+	{"NetworkManager", "NetworkManager.service", "NetworkManager-wait-online.service", "/etc/systemd/system/multi-user.target.wants/NetworkManager.service"},
+	{"systemd-networkd", "systemd-networkd.service", "systemd-networkd-wait-online.service", "/etc/systemd/system/multi-user.target.wants/systemd-networkd.service"},
+	{"ifupdown", "networking.service", "", "/etc/systemd/system/multi-user.target.wants/networking.service"},
+	{"network-scripts", "network.service", "", "/etc/rc.d/init.d/network"},
+}
+
+// DetectNetworkStack returns the enabled network stack; ok is false when none
+// is recognized. fsRoot allows running against a fixture tree.
+func DetectNetworkStack(fsRoot string) (NetworkStack, bool) {
+	for _, stack := range networkStacks {
+		if FileExists(filepath.Join(fsRoot, stack.marker)) {
+			return stack, true
+		}
+	}
+	return NetworkStack{}, false
+}
+
+// networkOnlineDeps returns the [Unit] dependencies of a unit that needs the
+// interfaces up. network-online.target alone is reached at once when the
+// wait-online service of the stack is disabled: the unit then pulls it in.
+func networkOnlineDeps(stack NetworkStack, ok bool) string {
+	wants := []string{"network-online.target"}
+	after := []string{"network-online.target"}
+	if ok {
+		if stack.WaitOnline != "" {
+			wants = append(wants, stack.WaitOnline)
+			after = append(after, stack.WaitOnline)
+		} else {
+			after = append(after, stack.Service)
+		}
+	}
+	return "Wants=" + strings.Join(wants, " ") + "\nAfter=" + strings.Join(after, " ") + "\n"
+}
+
+// UnitBootRun is how a boot-time unit ended in the current boot
+type UnitBootRun struct {
+	Ran       bool   // started since boot
+	Result    string // success, exit-code...
+	StartedAt string
+}
+
+// unitBootRun reads the state of a unit in the current boot (systemctl show)
+func unitBootRun(unit string) (UnitBootRun, error) {
+	out, err := exec.Command("systemctl", "show", unit, "-p", "ActiveState", "-p", "Result", "-p", "ActiveEnterTimestamp").Output()
+	if err != nil {
+		return UnitBootRun{}, fmt.Errorf("failed to read the state of %s: %w", unit, err)
+	}
+	return parseUnitBootRun(string(out)), nil
+}
+
+// parseUnitBootRun reads the properties printed by systemctl show
+func parseUnitBootRun(out string) UnitBootRun {
+	props := make(map[string]string)
+	for _, line := range strings.Split(out, "\n") {
+		if key, value, ok := strings.Cut(strings.TrimSpace(line), "="); ok {
+			props[key] = value
+		}
+	}
+	return UnitBootRun{
+		Ran:       props["ActiveEnterTimestamp"] != "" && props["ActiveEnterTimestamp"] != "n/a",
+		Result:    props["Result"],
+		StartedAt: props["ActiveEnterTimestamp"],
+	}
+}
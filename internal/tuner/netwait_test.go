@@ -0,0 +1,60 @@
+package tuner
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDetectNetworkStack(t *testing.T) {
+	root := t.TempDir()
+	if _, ok := DetectNetworkStack(root); ok {
+		t.Fatal("stack detected in an empty tree")
+	}
+
+	wants := filepath.Join(root, "etc/systemd/system/multi-user.target.wants")
+	if err := os.MkdirAll(wants, 0755); err != nil {
+		t.Fatal(err)
+	}
+	for _, unit := range []string{"systemd-networkd.service", "NetworkManager.service"} {
+		if err := os.WriteFile(filepath.Join(wants, unit), nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// NetworkManager wins when both are enabled
+	if stack, ok := DetectNetworkStack(root); !ok || stack.Name != "NetworkManager" {
+		t.Errorf("stack = %+v, %v, want NetworkManager", stack, ok)
+	}
+
+	nt := &NetworkTuner{FSRoot: root}
+	unit := nt.GetSystemdService(defaultBinaryPath)
+	for _, want := range []string{
+		"Wants=network-online.target NetworkManager-wait-online.service\n",
+		"After=network-online.target NetworkManager-wait-online.service\n",
+	} {
+		if !strings.Contains(unit, want) {
+			t.Errorf("unit lacks %q:\n%s", want, unit)
+		}
+	}
+}
+
+func TestNetworkOnlineDeps(t *testing.T) {
+	ifupdown := NetworkStack{Name: "ifupdown", Service: "networking.service"}
+	if got, want := networkOnlineDeps(ifupdown, true), "Wants=network-online.target\nAfter=network-online.target networking.service\n"; got != want {
+		t.Errorf("ifupdown deps = %q, want %q", got, want)
+	}
+	if got, want := networkOnlineDeps(NetworkStack{}, false), "Wants=network-online.target\nAfter=network-online.target\n"; got != want {
+		t.Errorf("unknown stack deps = %q, want %q", got, want)
+	}
+}
+
+func TestParseUnitBootRun(t *testing.T) {
+	run := parseUnitBootRun("ActiveState=active\nResult=success\nActiveEnterTimestamp=Sat 2026-10-17 08:12:03 UTC\n")
+	if !run.Ran || run.Result != "success" || run.StartedAt != "Sat 2026-10-17 08:12:03 UTC" {
+		t.Errorf("ran unit = %+v", run)
+	}
+	if run := parseUnitBootRun("ActiveState=inactive\nResult=success\nActiveEnterTimestamp=\n"); run.Ran {
+		t.Errorf("unit never started reported as ran: %+v", run)
+	}
+}
@@ -1,16 +1,23 @@
 package tuner
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"os/exec"
+	"path/filepath"
+	"strconv"
 	"strings"
+	"time"
+
+	"golang.org/x/sys/unix"
 )
 
 // NetworkTuner handles network optimization
 type NetworkTuner struct {
 	ServicePath string
 	DryRun      bool
+	FSRoot      string // root of the tree read to detect the network stack, "" for /
 }
 
 // NewNetworkTuner creates a new network tuner
@@ -21,25 +28,87 @@ func NewNetworkTuner(dryRun bool) *NetworkTuner {
 	}
 }
 
-// GetSystemdService returns the systemd service for network tuning
-func (nt *NetworkTuner) GetSystemdService() string {
+func init() {
+	Register(Module{
+		Name:        "network",
+		Label:       "Tune Network (Rings, Offloads)",
+		Description: "Network interface optimization",
+		Category:    CategoryTuning,
+		Menu:        34,
+		Pipeline:    5,
+		RequireRoot: true,
+		Flag:        &ModuleFlag{Name: "no-network", Usage: "Skip network tuning", Negated: true},
+		Run: func(ctx *ModuleContext) error {
+			if _, err := ctx.BackupSession(); err != nil {
+				return err
+			}
+			return applyNetwork(ctx)
+		},
+		Apply: applyNetwork,
+		Show: func(ctx *ModuleContext) error {
+			return NewNetworkTuner(false).ShowCurrent()
+		},
+		Verify: func(ctx *ModuleContext) error {
+			if err := NewNetworkTuner(false).Verify(); err != nil {
+				return err
+			}
+			if err := NewAffinityTuner(false).Verify(); err != nil && !errors.Is(err, ErrVerifySkipped) {
+				return err
+			}
+			return nil
+		},
+	})
+	Register(Module{
+		Name:        "netstats",
+		Label:       "Network Drops Since Last Check",
+		Description: "Packet drops of the NICs since the last check",
+		Category:    CategoryDiagnostics,
+		Menu:        29,
+		RequireRoot: true,
+		ReadOnly:    true,
+		Run: func(ctx *ModuleContext) error {
+			return NewNetworkTuner(false).CheckPacketDrops()
+		},
+	})
+}
+
+// applyNetwork installs the network unit, then the IRQ and RPS/XPS affinity
+func applyNetwork(ctx *ModuleContext) error {
+	netErr := NewNetworkTuner(ctx.DryRun).Apply(ctx.Backup)
+	affinityErr := NewAffinityTuner(ctx.DryRun).Apply(ctx.Backup)
+	switch {
+	case affinityErr != nil && netErr != nil:
+		return fmt.Errorf("%v; NIC affinity: %w", netErr, affinityErr)
+	case affinityErr != nil:
+		return fmt.Errorf("NIC affinity: %w", affinityErr)
+	}
+	return netErr
+}
+
+// GetSystemdService returns the systemd service for network tuning.
+// binPath is the vmware-tuner binary configuring the NICs at boot (net-apply).
+func (nt *NetworkTuner) GetSystemdService(binPath string) string {
+	nic := Tuning.NICSettings()
+	rxUsecs, txUsecs := nic.Usecs()
+	netApply := binPath + " net-apply --rx-ring " + strconv.Itoa(nic.RxRing) + " --tx-ring " + strconv.Itoa(nic.TxRing) +
+		" --rx-usecs " + strconv.Itoa(rxUsecs) + " --tx-usecs " + strconv.Itoa(txUsecs) +
+		" --affinity " + Tuning.AffinityMode()
+	if selection := Tuning.Interfaces.Args(); selection != "" {
+		netApply += " " + selection
+	}
+	// Ordered after the wait-online unit of the stack: the interfaces exist
+	// and are configured when net-apply runs
 	return `[Unit]
 Description=Network Performance Tuning for VMware
-After=network-online.target
-Wants=network-online.target
-
+` + networkOnlineDeps(DetectNetworkStack(nt.FSRoot)) + `
 [Service]
 Type=oneshot
-RemainOnExit=yes
+RemainAfterExit=yes
 
-# Increase ring buffers (ONLY for vmxnet3 to avoid e1000 hangs)
-ExecStart=/bin/bash -c 'for iface in $(/usr/bin/ls /sys/class/net/ | /usr/bin/grep -E "^(ens|eth)"); do if /usr/sbin/ethtool -i $iface | /usr/bin/grep -q "driver: vmxnet3"; then /usr/sbin/ethtool -G $iface rx 4096 tx 4096 2>/dev/null || true; fi; done'
-
-# Enable hardware offloading features (ONLY for vmxnet3)
-ExecStart=/bin/bash -c 'for iface in $(/usr/bin/ls /sys/class/net/ | /usr/bin/grep -E "^(ens|eth)"); do if /usr/sbin/ethtool -i $iface | /usr/bin/grep -q "driver: vmxnet3"; then /usr/sbin/ethtool -K $iface gso on gro on tso on 2>/dev/null || true; fi; done'
-
-# Set interrupt coalescing (ONLY for vmxnet3)
-ExecStart=/bin/bash -c 'for iface in $(/usr/bin/ls /sys/class/net/ | /usr/bin/grep -E "^(ens|eth)"); do if /usr/sbin/ethtool -i $iface | /usr/bin/grep -q "driver: vmxnet3"; then /usr/sbin/ethtool -C $iface rx-usecs 10 tx-usecs 10 2>/dev/null || true; fi; done'
+# Ring buffers, offloads (gso/gro/tso) and interrupt coalescing
+# (ONLY for vmxnet3 to avoid e1000 hangs), then the interrupts and
+# RPS/XPS masks of the queues on the vCPUs
+ExecStart=-` + netApply + `
 
 [Install]
 WantedBy=multi-user.target
@@ -50,13 +119,27 @@ WantedBy=multi-user.target
 func (nt *NetworkTuner) Apply(backup *BackupManager) error {
 	PrintStep("Configuring network optimizations")
 
-	service := nt.GetSystemdService()
+	binPath, err := schedulableBinary()
+	if err != nil {
+		if !nt.DryRun {
+			return err
+		}
+		binPath = defaultBinaryPath
+	}
+	service := nt.GetSystemdService(binPath)
+
+	// A ring above the adapter maximum is capped at boot: refuse it now
+	na := NewNetApplyTuner(Tuning.NICSettings())
+	na.Selection = Tuning.Interfaces
+	if err := na.Validate(); err != nil {
+		return err
+	}
 
 	if nt.DryRun {
 		PrintInfo("Would create: %s", nt.ServicePath)
 		PrintInfo("Service file preview:")
 		fmt.Println(service)
-		return nil
+		return nt.tunePassthroughNICs(backup)
 	}
 
 	// Backup existing service if it exists
@@ -65,14 +148,17 @@ func (nt *NetworkTuner) Apply(backup *BackupManager) error {
 	}
 
 	// Write systemd service
+	ExplainEdit("reapply the ring buffer and offload settings at every boot (ethtool settings are lost on reboot)", nt.ServicePath)
 	if err := os.WriteFile(nt.ServicePath, []byte(service), 0644); err != nil {
 		return fmt.Errorf("failed to write network service: %w", err)
 	}
 
 	PrintSuccess("Created %s", nt.ServicePath)
+	PrintInfo("The service runs %s at boot: keep the binary there", binPath)
 
 	// Reload systemd
 	PrintInfo("Reloading systemd daemon...")
+	ExplainCommand("make systemd read the new unit", "systemctl", "daemon-reload")
 	cmd := exec.Command("systemctl", "daemon-reload")
 	if output, err := cmd.CombinedOutput(); err != nil {
 		PrintWarning("Failed to reload systemd: %v", err)
@@ -81,15 +167,18 @@ func (nt *NetworkTuner) Apply(backup *BackupManager) error {
 
 	// Enable the service
 	PrintInfo("Enabling network tuning service...")
+	ExplainCommand("run the unit at every boot", "systemctl", "enable", "network-tuning.service")
 	cmd = exec.Command("systemctl", "enable", "network-tuning.service")
 	if output, err := cmd.CombinedOutput(); err != nil {
 		PrintWarning("Failed to enable service: %v", err)
 		fmt.Println(string(output))
 	}
 
-	// Start the service (apply changes now)
+	// Run the service again (apply changes now): the oneshot unit stays
+	// active after its first run (RemainAfterExit), start would do nothing
 	PrintInfo("Starting network tuning service...")
-	cmd = exec.Command("systemctl", "start", "network-tuning.service")
+	ExplainCommand("apply the NIC settings now", "systemctl", "restart", "network-tuning.service")
+	cmd = exec.Command("systemctl", "restart", "network-tuning.service")
 	if output, err := cmd.CombinedOutput(); err != nil {
 		PrintWarning("Failed to start service: %v", err)
 		fmt.Println(string(output))
@@ -98,7 +187,7 @@ func (nt *NetworkTuner) Apply(backup *BackupManager) error {
 		PrintSuccess("Network tuning applied immediately")
 	}
 
-	return nil
+	return nt.tunePassthroughNICs(backup)
 }
 
 // ShowCurrent displays current network settings
@@ -110,32 +199,37 @@ func (nt *NetworkTuner) ShowCurrent() error {
 	if err != nil {
 		return err
 	}
+	nt.reportSkipped()
 
 	for _, iface := range interfaces {
 		fmt.Printf("\n  Interface: %s\n", iface)
 
 		// Get ring buffer settings
-		cmd := exec.Command("ethtool", "-g", iface)
-		if output, err := cmd.Output(); err == nil {
-			lines := strings.Split(string(output), "\n")
-			for _, line := range lines {
-				if strings.Contains(line, "Current") || strings.Contains(line, "RX") || strings.Contains(line, "TX") {
-					fmt.Printf("    %s\n", strings.TrimSpace(line))
-				}
-			}
+		dev := ioctlEthtool{}
+		if ring, err := dev.Rings(iface); err == nil {
+			fmt.Printf("    RX ring: %d (max %d)\n", ring.RxPending, ring.RxMaxPending)
+			fmt.Printf("    TX ring: %d (max %d)\n", ring.TxPending, ring.TxMaxPending)
 		}
 
 		// Get offload features
-		cmd = exec.Command("ethtool", "-k", iface)
-		if output, err := cmd.Output(); err == nil {
-			features := []string{"tcp-segmentation-offload", "generic-receive-offload", "generic-segmentation-offload"}
-			lines := strings.Split(string(output), "\n")
-			for _, line := range lines {
-				for _, feature := range features {
-					if strings.Contains(line, feature+":") {
-						fmt.Printf("    %s\n", strings.TrimSpace(line))
-					}
-				}
+		for _, feature := range []struct {
+			Name string
+			Cmd  uint32
+		}{
+			{"tcp-segmentation-offload", unix.ETHTOOL_GTSO},
+			{"generic-receive-offload", unix.ETHTOOL_GGRO},
+			{"generic-segmentation-offload", unix.ETHTOOL_GGSO},
+		} {
+			if on, err := dev.Offload(iface, feature.Cmd); err == nil {
+				fmt.Printf("    %s: %s\n", feature.Name, onOff(on))
+			}
+		}
+
+		// Named driver statistics (lifetime counters, see netstats for deltas)
+		if nicDriver(iface) == "vmxnet3" {
+			if out, err := dev.Stats(iface); err == nil {
+				fmt.Println("    vmxnet3 statistics:")
+				printVmxnet3Stats(ParseVmxnet3Stats(out))
 			}
 		}
 	}
@@ -143,24 +237,33 @@ func (nt *NetworkTuner) ShowCurrent() error {
 	return nil
 }
 
-// getNetworkInterfaces returns a list of network interfaces
+// getNetworkInterfaces returns the interfaces selected for tuning
+// (tuning.network.interfaces, --interfaces), by default the ethernet names
 func (nt *NetworkTuner) getNetworkInterfaces() ([]string, error) {
-	entries, err := os.ReadDir("/sys/class/net")
+	all, err := ListInterfaces(Sys.Root)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read /sys/class/net: %w", err)
+		return nil, err
 	}
 
+	selected, _ := Tuning.Interfaces.Select(all)
 	var interfaces []string
-	for _, entry := range entries {
-		name := entry.Name()
-		// Filter typical ethernet interfaces
-		if strings.HasPrefix(name, "ens") || strings.HasPrefix(name, "eth") {
-			interfaces = append(interfaces, name)
-		}
+	for _, iface := range selected {
+		interfaces = append(interfaces, iface.Name)
 	}
 	return interfaces, nil
 }
 
+// reportSkipped prints the interfaces left out by the selection
+func (nt *NetworkTuner) reportSkipped() {
+	all, err := ListInterfaces(Sys.Root)
+	if err != nil {
+		return
+	}
+	reportExcludedInterfaces("network", all)
+	_, skipped := Tuning.Interfaces.Select(all)
+	printSkippedInterfaces(Tuning.Interfaces, skipped, Tuning.Interfaces.Missing(all))
+}
+
 // Verify checks if the network tuning service exists
 func (nt *NetworkTuner) Verify() error {
 	if _, err := os.Stat(nt.ServicePath); os.IsNotExist(err) {
@@ -180,10 +283,30 @@ func (nt *NetworkTuner) Verify() error {
 		}
 	}
 
+	// Enabled is not enough: the unit must have run, after the interfaces came up
+	if run, err := unitBootRun("network-tuning.service"); err != nil {
+		PrintWarning("%v", err)
+	} else if !run.Ran {
+		return fmt.Errorf("network tuning service did not run in this boot")
+	} else if run.Result != "" && run.Result != "success" {
+		return fmt.Errorf("network tuning service failed in this boot (result: %s)", run.Result)
+	} else {
+		PrintSuccess("Network tuning service ran at %s", run.StartedAt)
+	}
+
+	if interfaces, err := nt.getNetworkInterfaces(); err == nil && len(interfaces) > 0 {
+		PrintInfo("Tuned interfaces: %s", strings.Join(interfaces, ", "))
+	} else if err == nil {
+		PrintWarning("No interface matches %s", Tuning.Interfaces)
+	}
+	nt.reportSkipped()
+
 	return nil
 }
 
-// CheckPacketDrops checks for packet drops on all interfaces using ethtool -S
+// CheckPacketDrops reports the drop and error counters that increased since
+// the previous check (baseline in StateDir). Lifetime counters are meaningless:
+// without a valid baseline the counters are sampled over a short window.
 func (nt *NetworkTuner) CheckPacketDrops() error {
 	PrintStep("Checking for network packet drops")
 
@@ -192,38 +315,31 @@ func (nt *NetworkTuner) CheckPacketDrops() error {
 		return err
 	}
 
-	for _, iface := range interfaces {
-		fmt.Printf("Interface: %s\n", iface)
-
-		// Use RunCommandSilent from exec_utils (we need to export it or duplicate logic if not exported?
-		// Actually I added RunCommandSilent to generic package, let's check if I can use it.
-		// It is in the same package 'tuner', so yes.)
-		output, err := RunCommandSilent("ethtool", "-S", iface)
-		if err != nil {
-			PrintWarning("  Could not get statistics: %v", err)
-			continue
-		}
+	baselinePath := filepath.Join(StateDir, netStatsBaselineName)
+	prev, ok := loadNetStatsBaseline(baselinePath)
+	if !ok {
+		window := 10 * time.Second
+		PrintInfo("No baseline since boot: sampling counters for %s...", window)
+		prev = TakeNetStats(interfaces)
+		time.Sleep(window)
+	}
 
-		lines := strings.Split(output, "\n")
-		dropsFound := false
-		for _, line := range lines {
-			// Look for drop or error keywords
-			if strings.Contains(line, "drop") || strings.Contains(line, "error") {
-				parts := strings.Fields(line)
-				if len(parts) >= 2 {
-					// format usually: "rx_dropped: 123"
-					valStr := parts[len(parts)-1]
-					if valStr != "0" {
-						PrintWarning("  %s", strings.TrimSpace(line))
-						dropsFound = true
-					}
-				}
-			}
+	cur := TakeNetStats(interfaces)
+	deltas, valid := DiffNetStats(prev, cur)
+	if !valid {
+		PrintInfo("Counters were reset since %s: baseline renewed", prev.Timestamp.Format("2006-01-02 15:04"))
+	} else if len(deltas) == 0 {
+		PrintSuccess("No new packet drops or errors since %s", prev.Timestamp.Format("2006-01-02 15:04:05"))
+	} else {
+		PrintWarning("New drops/errors since %s:", prev.Timestamp.Format("2006-01-02 15:04:05"))
+		printDeltas(deltas)
+		for _, d := range deltas {
+			RecordFinding(FindingPacketDrops, d.Interface, d.Counter)
 		}
+	}
 
-		if !dropsFound {
-			PrintSuccess("  No packet drops or errors detected")
-		}
+	if err := saveNetStatsBaseline(baselinePath, cur); err != nil {
+		PrintWarning("Could not save the baseline: %v", err)
 	}
 	return nil
 }
@@ -0,0 +1,135 @@
+package tuner
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/smtp"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// NotifyConfig holds the notification targets (notify: section of the config)
+type NotifyConfig struct {
+	Email   []string
+	From    string
+	SMTP    string // host:port of the relay; local sendmail when empty
+	Webhook string
+}
+
+// decode reads the notify: section
+func (nc *NotifyConfig) decode(fields map[string]interface{}) error {
+	var err error
+	if nc.Email, err = yamlStringList(fields["email"]); err != nil {
+		return fmt.Errorf("email: %w", err)
+	}
+	if nc.From, err = yamlString(fields["from"]); err != nil {
+		return fmt.Errorf("from: %w", err)
+	}
+	if nc.SMTP, err = yamlString(fields["smtp"]); err != nil {
+		return fmt.Errorf("smtp: %w", err)
+	}
+	if nc.Webhook, err = yamlString(fields["webhook"]); err != nil {
+		return fmt.Errorf("webhook: %w", err)
+	}
+	if nc.SMTP != "" && !strings.Contains(nc.SMTP, ":") {
+		nc.SMTP += ":25"
+	}
+	return nil
+}
+
+// Enabled reports whether at least one target is configured
+func (nc NotifyConfig) Enabled() bool {
+	return len(nc.Email) > 0 || nc.Webhook != ""
+}
+
+// Notifier sends messages to the configured email recipients and webhook
+type Notifier struct {
+	Config NotifyConfig
+}
+
+// NewNotifier creates a new notifier
+func NewNotifier(cfg NotifyConfig) *Notifier {
+	return &Notifier{Config: cfg}
+}
+
+// Send delivers the message to every target. All targets are tried;
+// the returned error lists the ones that failed.
+func (n *Notifier) Send(subject, body string) error {
+	var failures []string
+
+	if len(n.Config.Email) > 0 {
+		if err := n.sendEmail(subject, body); err != nil {
+			failures = append(failures, fmt.Sprintf("email: %v", err))
+		}
+	}
+	if n.Config.Webhook != "" {
+		if err := n.sendWebhook(subject, body); err != nil {
+			failures = append(failures, fmt.Sprintf("webhook: %v", err))
+		}
+	}
+
+	if len(failures) > 0 {
+		return fmt.Errorf("notification failed (%s)", strings.Join(failures, "; "))
+	}
+	return nil
+}
+
+// sendEmail sends a plain text mail through the SMTP relay or local sendmail
+func (n *Notifier) sendEmail(subject, body string) error {
+	from := n.Config.From
+	if from == "" {
+		hostname, _ := os.Hostname()
+		from = "vmware-tuner@" + hostname
+	}
+
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\n", from)
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(n.Config.Email, ", "))
+	fmt.Fprintf(&msg, "Subject: %s\r\n", subject)
+	fmt.Fprintf(&msg, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	msg.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	msg.WriteString(strings.ReplaceAll(body, "\n", "\r\n"))
+
+	if n.Config.SMTP != "" {
+		return smtp.SendMail(n.Config.SMTP, nil, from, n.Config.Email, msg.Bytes())
+	}
+
+	sendmail, err := exec.LookPath("sendmail")
+	if err != nil {
+		return fmt.Errorf("no smtp relay configured and sendmail not found")
+	}
+	cmd := exec.Command(sendmail, "-t", "-i")
+	cmd.Stdin = &msg
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("sendmail failed: %v: %s", err, string(out))
+	}
+	return nil
+}
+
+// sendWebhook posts a JSON payload; "text" is understood by Slack, Mattermost and Teams
+func (n *Notifier) sendWebhook(subject, body string) error {
+	hostname, _ := os.Hostname()
+	payload, err := json.Marshal(map[string]string{
+		"host":    hostname,
+		"subject": subject,
+		"text":    subject + "\n" + body,
+	})
+	if err != nil {
+		return err
+	}
+
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Post(n.Config.Webhook, "application/json", bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("server returned %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,242 @@
+package tuner
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// vNUMAMinCPUs is the vCPU count from which vSphere exposes a virtual NUMA
+// topology to the guest (numa.vcpu.min)
+const vNUMAMinCPUs = 9
+
+// NUMANode is a NUMA node the guest sees
+type NUMANode struct {
+	ID       int   `json:"id"`
+	CPUs     []int `json:"cpus"`
+	MemoryMB int64 `json:"memory_mb"`
+}
+
+// NUMALayout is the virtual NUMA topology of the VM, read from sysfs
+type NUMALayout struct {
+	Nodes           []NUMANode `json:"nodes"`
+	CPUs            int        `json:"cpus"`
+	PossibleCPUs    int        `json:"possible_cpus"` // above CPUs: CPU Hot Add
+	Sockets         int        `json:"sockets"`
+	CoresPerSocket  int        `json:"cores_per_socket"`
+	Findings        []string   `json:"findings,omitempty"`
+	Recommendations []string   `json:"recommendations,omitempty"`
+}
+
+// Known reports whether the layout could be read
+func (l NUMALayout) Known() bool {
+	return l.CPUs > 0
+}
+
+// HotAdd reports whether the VM can take more vCPUs than it has: the
+// firmware lists the hot-pluggable vCPUs as possible
+func (l NUMALayout) HotAdd() bool {
+	return l.PossibleCPUs > l.CPUs
+}
+
+// String prints the layout
+func (l NUMALayout) String() string {
+	return fmt.Sprintf("%d vCPU: %d socket(s) x %d core(s), %d NUMA node(s)", l.CPUs, l.Sockets, l.CoresPerSocket, len(l.Nodes))
+}
+
+// ReadNUMA reads the NUMA nodes, their vCPUs and memory, and the socket
+// layout of the vCPUs (the CPU topology reader). fsRoot allows running
+// against a fixture tree.
+func ReadNUMA(fsRoot string) (NUMALayout, error) {
+	var l NUMALayout
+	sys := SysFS{Root: fsRoot}
+	topo, present, err := readCPUTopology(sys)
+	if err != nil {
+		return l, err
+	}
+	l.CPUs, l.Sockets, l.CoresPerSocket = topo.CPUs, topo.Sockets, topo.CoresPerSocket
+	l.PossibleCPUs = l.CPUs
+	if data, err := sys.ReadString("/sys/devices/system/cpu/possible"); err == nil {
+		if possible, err := parseCPURanges(data); err == nil {
+			l.PossibleCPUs = len(possible)
+		}
+	}
+
+	// A kernel without CONFIG_NUMA has no node directory: one node
+	for _, dir := range sys.Glob("/sys/devices/system/node/node[0-9]*") {
+		id, err := strconv.Atoi(strings.TrimPrefix(filepath.Base(dir), "node"))
+		if err != nil {
+			continue
+		}
+		node := NUMANode{ID: id}
+		if data, err := os.ReadFile(filepath.Join(dir, "cpulist")); err == nil {
+			node.CPUs, _ = parseCPURanges(string(data))
+		}
+		node.MemoryMB = nodeMemoryMB(filepath.Join(dir, "meminfo"))
+		l.Nodes = append(l.Nodes, node)
+	}
+	sort.Slice(l.Nodes, func(i, j int) bool { return l.Nodes[i].ID < l.Nodes[j].ID })
+	if len(l.Nodes) == 0 {
+		l.Nodes = []NUMANode{{CPUs: present, MemoryMB: SysFS{Root: fsRoot}.MemInfoMB()["MemTotal"]}}
+	}
+
+	l.Findings, l.Recommendations = numaFindings(l)
+	return l, nil
+}
+
+// nodeMemoryMB reads the MemTotal line of a node meminfo
+// ("Node 0 MemTotal:       16305216 kB")
+func nodeMemoryMB(path string) int64 {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) >= 4 && fields[2] == "MemTotal:" {
+			kb, _ := strconv.ParseInt(fields[3], 10, 64)
+			return kb >> 10
+		}
+	}
+	return 0
+}
+
+// numaFindings flags the layouts that break or unbalance vNUMA, with the
+// virtual hardware settings to change. vSphere sizes vNUMA from the host
+// NUMA nodes when the VM has 9 vCPUs or more, and the guest scheduler and
+// memory allocator trust what it sees: a vCPU count that does not split
+// evenly or CPU Hot Add make threads run far from their memory. The socket
+// layout is reported once, by the CPU topology (topologyFindings).
+func numaFindings(l NUMALayout) (findings, recommendations []string) {
+	if !l.Known() || l.Sockets == 0 {
+		return nil, nil
+	}
+	nodes := len(l.Nodes)
+	wide := l.CPUs >= vNUMAMinCPUs
+
+	if wide && l.HotAdd() {
+		findings = append(findings, fmt.Sprintf("CPU Hot Add is enabled (%d possible vCPUs for %d): vSphere turns vNUMA off, the guest sees a single node whatever the host layout", l.PossibleCPUs, l.CPUs))
+		recommendations = append(recommendations, "Disable CPU Hot Add in VM Settings > CPU (VM powered off) so vNUMA is exposed")
+	}
+	if nodes > 1 {
+		if l.CPUs%nodes != 0 {
+			findings = append(findings, fmt.Sprintf("%d vCPUs on %d NUMA nodes: the nodes get unequal vCPU counts", l.CPUs, nodes))
+			recommendations = append(recommendations, fmt.Sprintf("Give the VM a multiple of %d vCPUs (%d or %d)", nodes, l.CPUs/nodes*nodes, (l.CPUs/nodes+1)*nodes))
+		}
+		var empty []string
+		var least, most int64 = -1, 0
+		for _, n := range l.Nodes {
+			if n.MemoryMB == 0 {
+				empty = append(empty, strconv.Itoa(n.ID))
+				continue
+			}
+			if least < 0 || n.MemoryMB < least {
+				least = n.MemoryMB
+			}
+			if n.MemoryMB > most {
+				most = n.MemoryMB
+			}
+		}
+		switch {
+		case len(empty) > 0:
+			findings = append(findings, fmt.Sprintf("NUMA node(s) %s without memory: their vCPUs reach all their memory remotely", strings.Join(empty, ", ")))
+			recommendations = append(recommendations, "Disable Memory Hot Add and size the memory as a multiple of the NUMA nodes (VM powered off)")
+		case least > 0 && most > least*3/2:
+			findings = append(findings, fmt.Sprintf("NUMA nodes with unbalanced memory (%s to %s)", FormatMiB(least), FormatMiB(most)))
+			recommendations = append(recommendations, "Disable Memory Hot Add and size the memory as a multiple of the NUMA nodes (VM powered off)")
+		}
+	}
+	if len(findings) > 0 {
+		recommendations = append(recommendations, "On vSphere 8 with virtual hardware 20, let the vNUMA topology be set automatically (Cores per socket: Assigned at power on)")
+	}
+	return findings, recommendations
+}
+
+// printNUMA prints the nodes, the findings and the settings to change
+func printNUMA(l NUMALayout) {
+	if !l.Known() {
+		PrintInfo("NUMA topology unknown (/sys/devices/system not readable)")
+		return
+	}
+	if len(l.Findings) == 0 {
+		PrintSuccess("NUMA topology: %s", l)
+	} else {
+		PrintWarning("NUMA topology: %s", l)
+	}
+	for _, n := range l.Nodes {
+		fmt.Printf("    node%d: vCPUs %-16s memory %s\n", n.ID, FormatCPUList(n.CPUs), FormatMiB(n.MemoryMB))
+	}
+	for _, f := range l.Findings {
+		fmt.Printf("    - %s\n", f)
+	}
+	if len(l.Recommendations) > 0 {
+		PrintInfo("Virtual hardware settings to change:")
+		for _, r := range l.Recommendations {
+			fmt.Printf("    -> %s\n", r)
+		}
+	}
+}
+
+// auditNUMA reports the vNUMA layout. Not scored: the fix is a VM setting
+// the guest cannot change.
+func auditNUMA(l NUMALayout) AuditItem {
+	item := AuditItem{Name: "numa"}
+	switch {
+	case !l.Known():
+		item.Status, item.Message = AuditWarn, "NUMA topology unknown"
+	case len(l.Findings) > 0:
+		item.Status, item.Message = AuditWarn, "vNUMA layout to review: "+l.String()
+		for _, f := range l.Findings {
+			item.Details = append(item.Details, "- "+f)
+		}
+		for _, r := range l.Recommendations {
+			item.Details = append(item.Details, "-> "+r)
+		}
+	default:
+		item.Status, item.Message = AuditOK, "NUMA topology: "+l.String()
+	}
+	return item
+}
+
+// NUMATuner checks the virtual NUMA topology
+type NUMATuner struct {
+	FSRoot string // "" for /
+}
+
+// NewNUMATuner creates a new NUMA topology checker
+func NewNUMATuner() *NUMATuner {
+	return &NUMATuner{}
+}
+
+func init() {
+	Register(Module{
+		Name:        "numa",
+		Label:       "NUMA / vNUMA Topology",
+		Description: "Check the virtual NUMA nodes against the vCPU and socket layout",
+		Category:    CategoryDiagnostics,
+		Menu:        38,
+		ReadOnly:    true,
+		Run: func(ctx *ModuleContext) error {
+			return NewNUMATuner().Run()
+		},
+	})
+}
+
+// Run prints the NUMA layout and the settings that would fix it
+func (nt *NUMATuner) Run() error {
+	PrintStep("NUMA / vNUMA Topology")
+	l, err := ReadNUMA(nt.FSRoot)
+	if err != nil {
+		return err
+	}
+	// The socket layout findings come with the CPU topology
+	printCPUTopology(ReadCPUTopology(nt.FSRoot))
+	printNUMA(l)
+	return nil
+}
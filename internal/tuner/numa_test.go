@@ -0,0 +1,95 @@
+package tuner
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// numaFixture builds /sys/devices/system for cpus vCPUs spread over sockets,
+// possible vCPUs (above cpus: CPU Hot Add) and one NUMA node per memory size
+// (MB), the vCPUs split between the nodes in order
+func numaFixture(t *testing.T, cpus, possible, sockets int, nodeMemMB ...int) string {
+	root := t.TempDir()
+	cpuDir := "/sys/devices/system/cpu"
+	files := map[string]string{
+		cpuDir + "/present":  fmt.Sprintf("0-%d\n", cpus-1),
+		cpuDir + "/possible": fmt.Sprintf("0-%d\n", possible-1),
+	}
+	perSocket := cpus / sockets
+	for cpu := 0; cpu < cpus; cpu++ {
+		files[fmt.Sprintf("%s/cpu%d/topology/physical_package_id", cpuDir, cpu)] = fmt.Sprintf("%d\n", cpu/perSocket)
+		files[fmt.Sprintf("%s/cpu%d/topology/core_id", cpuDir, cpu)] = fmt.Sprintf("%d\n", cpu%perSocket)
+	}
+	nodes := len(nodeMemMB)
+	for n, mem := range nodeMemMB {
+		first := n * cpus / nodes
+		last := (n+1)*cpus/nodes - 1
+		dir := fmt.Sprintf("/sys/devices/system/node/node%d", n)
+		files[dir+"/cpulist"] = fmt.Sprintf("%d-%d\n", first, last)
+		files[dir+"/meminfo"] = fmt.Sprintf("Node %d MemTotal:       %d kB\nNode %d MemFree:        1024 kB\n", n, mem*1024, n)
+	}
+	writeFiles(t, root, files)
+	return root
+}
+
+func TestReadNUMA(t *testing.T) {
+	root := numaFixture(t, 16, 16, 2, 32768, 32768)
+	l, err := ReadNUMA(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if l.CPUs != 16 || l.Sockets != 2 || l.CoresPerSocket != 8 || len(l.Nodes) != 2 {
+		t.Fatalf("layout = %s, want 16 vCPU: 2 sockets x 8 cores, 2 NUMA nodes", l)
+	}
+	if n := l.Nodes[1]; n.ID != 1 || len(n.CPUs) != 8 || n.CPUs[0] != 8 || n.MemoryMB != 32768 {
+		t.Errorf("node1 = %+v, want vCPUs 8-15 and 32768 MB", n)
+	}
+	if len(l.Findings) != 0 {
+		t.Errorf("findings = %q, want none", l.Findings)
+	}
+
+	if _, err := ReadNUMA(t.TempDir()); err == nil {
+		t.Error("missing sysfs: no error")
+	}
+}
+
+func TestNUMAFindings(t *testing.T) {
+	tests := []struct {
+		name     string
+		root     string
+		findings []string // substrings, one per finding
+		recs     []string
+	}{
+		{"one socket, one node", numaFixture(t, 8, 8, 1, 16384), nil, nil},
+		{"small VM with hot add", numaFixture(t, 4, 128, 1, 8192), nil, nil},
+		{"16 sockets x 1 core (cpu-topology)", numaFixture(t, 16, 16, 16, 65536), nil, nil},
+		{"CPU hot add", numaFixture(t, 24, 128, 1, 98304), []string{"CPU Hot Add"}, []string{"Disable CPU Hot Add"}},
+		{"sockets straddling nodes (cpu-topology)", numaFixture(t, 24, 24, 3, 32768, 32768), nil, nil},
+		{"memoryless node", numaFixture(t, 16, 16, 2, 65536, 0), []string{"node(s) 1 without memory"}, []string{"Memory Hot Add"}},
+		{"unbalanced memory", numaFixture(t, 16, 16, 2, 49152, 16384), []string{"unbalanced memory"}, []string{"Memory Hot Add"}},
+	}
+	for _, tt := range tests {
+		l, err := ReadNUMA(tt.root)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if len(l.Findings) != len(tt.findings) {
+			t.Errorf("%s: findings %q, want %d", tt.name, l.Findings, len(tt.findings))
+			continue
+		}
+		for i, want := range tt.findings {
+			if !strings.Contains(l.Findings[i], want) {
+				t.Errorf("%s: finding %q, want %q", tt.name, l.Findings[i], want)
+			}
+		}
+		for i, want := range tt.recs {
+			if i >= len(l.Recommendations) || !strings.Contains(l.Recommendations[i], want) {
+				t.Errorf("%s: recommendations %q, want %q", tt.name, l.Recommendations, want)
+			}
+		}
+		if item := auditNUMA(l); (item.Status == AuditOK) != (len(tt.findings) == 0) || item.Max != 0 {
+			t.Errorf("%s: audit item %+v", tt.name, item)
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package tuner
+
+import (
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// IsOstree reports whether the system boots an rpm-ostree/ostree deployment
+// (Fedora CoreOS, RHEL for Edge, Silverblue). fsRoot is "" for /.
+func IsOstree(fsRoot string) bool {
+	return FileExists(filepath.Join(fsRoot, "/run/ostree-booted"))
+}
+
+// errOstreeUnsupported explains why an operation is refused on ostree systems
+func errOstreeUnsupported(operation, alternative string) error {
+	return fmt.Errorf("%s is not supported on rpm-ostree systems (/usr is read-only): %s", operation, alternative)
+}
+
+// applyKargs sets boot parameters through `rpm-ostree kargs`, which stages a
+// new deployment (rollback: rpm-ostree rollback) instead of editing GRUB files
+func (gt *GrubTuner) applyKargs(params []string) error {
+	out, err := exec.Command("rpm-ostree", "kargs").Output()
+	if err != nil {
+		return fmt.Errorf("failed to read kernel arguments: %w", err)
+	}
+
+	current := make(map[string]string)
+	for _, param := range strings.Fields(string(out)) {
+		current[paramKey(param)] = param
+	}
+
+	var args []string
+	for _, param := range params {
+		existing, ok := current[paramKey(param)]
+		switch {
+		case !ok:
+			args = append(args, "--append="+param)
+		case existing != param:
+			args = append(args, "--replace="+param)
+		}
+	}
+
+	if len(args) == 0 {
+		PrintSuccess("Kernel arguments already optimized")
+		return nil
+	}
+
+	PrintInfo("rpm-ostree system: kernel arguments are set with rpm-ostree kargs")
+	PrintInfo("Changes: %s", strings.Join(args, " "))
+
+	if gt.DryRun {
+		PrintInfo("Would run: rpm-ostree kargs %s", strings.Join(args, " "))
+		return nil
+	}
+
+	ExplainCommand("stage a deployment with the VMware kernel arguments (the running one is kept for rollback)", "rpm-ostree", append([]string{"kargs"}, args...)...)
+	if out, err := exec.Command("rpm-ostree", append([]string{"kargs"}, args...)...).CombinedOutput(); err != nil {
+		return fmt.Errorf("rpm-ostree kargs failed: %v: %s", err, string(out))
+	}
+
+	PrintSuccess("New deployment staged with updated kernel arguments")
+	PrintInfo("Undo with 'rpm-ostree rollback' if needed")
+	PrintWarning("REBOOT REQUIRED for boot parameter changes to take effect")
+	return nil
+}
+
+// paramKey returns the name of a key=value kernel parameter
+func paramKey(param string) string {
+	if idx := strings.Index(param, "="); idx != -1 {
+		return param[:idx]
+	}
+	return param
+}
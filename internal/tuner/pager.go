@@ -0,0 +1,51 @@
+package tuner
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+
+	"golang.org/x/sys/unix"
+)
+
+// PagerDisabled prints long outputs at once (--no-pager)
+var PagerDisabled bool
+
+// terminalRows returns the height of the terminal on stdout, 0 when stdout
+// is not a terminal (redirected, captured by doctor)
+func terminalRows() int {
+	ws, err := unix.IoctlGetWinsize(int(os.Stdout.Fd()), unix.TIOCGWINSZ)
+	if err != nil {
+		return 0
+	}
+	return int(ws.Row)
+}
+
+// PageLines prints lines one screen at a time when stdin and stdout are
+// terminals: Enter shows the next screen, q skips the rest
+func PageLines(lines []string) {
+	rows := 0
+	if !PagerDisabled && isInteractive() {
+		rows = terminalRows()
+	}
+	if rows < 3 || len(lines) < rows {
+		for _, line := range lines {
+			fmt.Println(line)
+		}
+		return
+	}
+
+	reader := bufio.NewReader(os.Stdin)
+	page := rows - 1 // the last row holds the prompt
+	for i, line := range lines {
+		if i > 0 && i%page == 0 {
+			fmt.Printf("-- %d/%d lines -- Enter: more, q: skip the rest ", i, len(lines))
+			answer, _ := reader.ReadString('\n')
+			if strings.EqualFold(strings.TrimSpace(answer), "q") {
+				return
+			}
+		}
+		fmt.Println(line)
+	}
+}
@@ -0,0 +1,153 @@
+package tuner
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// pciVendorVMware is the PCI vendor ID of VMware virtual devices
+const pciVendorVMware = "15ad"
+
+// PCI base classes (first byte of the class code)
+const (
+	pciClassStorage = "01"
+	pciClassNetwork = "02"
+	pciClassDisplay = "03"
+)
+
+// pciModels names the devices ESXi presents to guests (vendor:device)
+var pciModels = map[string]string{
+	"15ad:0405": "VMware SVGA II",
+	"15ad:0406": "VMware SVGA 3",
+	"15ad:0710": "VMware SVGA",
+	"15ad:0720": "VMware VMXNET (legacy)",
+	"15ad:0740": "VMware VMCI",
+	"15ad:0770": "VMware USB 2.0 EHCI",
+	"15ad:0774": "VMware USB 1.1 UHCI",
+	"15ad:0778": "VMware USB 3.0 xHCI",
+	"15ad:0779": "VMware USB 3.1 xHCI",
+	"15ad:0790": "VMware PCI bridge",
+	"15ad:07a0": "VMware PCI Express Root Port",
+	"15ad:07b0": "VMware VMXNET3",
+	"15ad:07c0": "VMware PVSCSI",
+	"15ad:07e0": "VMware SATA AHCI",
+	"15ad:07f0": "VMware NVMe",
+	"15ad:0801": "VMware Virtual Machine Interface",
+	"15ad:0820": "VMware PVRDMA",
+	"15ad:1977": "VMware HD Audio",
+	"8086:100f": "Intel 82545EM (E1000, emulated)",
+	"8086:10d3": "Intel 82574L (E1000E, emulated)",
+	"1022:2000": "AMD PCnet32 (Flexible/Vlance, emulated)",
+	"1000:0030": "LSI Logic 53c1030 (LSI Logic Parallel)",
+	"1000:0054": "LSI Logic SAS1068 (LSI Logic SAS)",
+	"104b:1040": "BusLogic BT-958 (emulated)",
+	"8086:7111": "Intel PIIX4 IDE",
+	"8086:7190": "Intel 440BX host bridge",
+	"8086:7110": "Intel PIIX4 ISA bridge",
+	"8086:7113": "Intel PIIX4 ACPI",
+	"1274:1371": "Ensoniq ES1371 (emulated sound)",
+}
+
+// PCIDevice is a device of /sys/bus/pci/devices
+type PCIDevice struct {
+	Address  string `json:"address"`
+	VendorID string `json:"vendor_id"`
+	DeviceID string `json:"device_id"`
+	Class    string `json:"class"`
+	Driver   string `json:"driver,omitempty"`
+	Model    string `json:"model"`
+}
+
+// IsVMware reports a VMware virtual device
+func (d PCIDevice) IsVMware() bool {
+	return d.VendorID == pciVendorVMware
+}
+
+// BaseClass returns the first byte of the class code ("02" for network)
+func (d PCIDevice) BaseClass() string {
+	if len(d.Class) < 2 {
+		return ""
+	}
+	return d.Class[:2]
+}
+
+// ListPCIDevices reads the PCI devices from sysfs, without lspci.
+// fsRoot allows running against a fixture tree (empty string for /).
+func ListPCIDevices(fsRoot string) ([]PCIDevice, error) {
+	dir := filepath.Join(fsRoot, "/sys/bus/pci/devices")
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", dir, err)
+	}
+
+	var devices []PCIDevice
+	for _, entry := range entries {
+		path := filepath.Join(dir, entry.Name())
+		dev := PCIDevice{
+			Address:  entry.Name(),
+			VendorID: readPCIID(filepath.Join(path, "vendor")),
+			DeviceID: readPCIID(filepath.Join(path, "device")),
+			Class:    readPCIID(filepath.Join(path, "class")),
+		}
+		if dev.VendorID == "" {
+			continue
+		}
+		if target, err := os.Readlink(filepath.Join(path, "driver")); err == nil {
+			dev.Driver = filepath.Base(target)
+		}
+		dev.Model = pciModels[dev.VendorID+":"+dev.DeviceID]
+		if dev.Model == "" {
+			dev.Model = fmt.Sprintf("Unknown device %s:%s", dev.VendorID, dev.DeviceID)
+		}
+		devices = append(devices, dev)
+	}
+
+	sort.Slice(devices, func(i, j int) bool { return devices[i].Address < devices[j].Address })
+	return devices, nil
+}
+
+// readPCIID reads a sysfs ID file ("0x15ad") as lower case hex without prefix
+func readPCIID(path string) string {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimPrefix(strings.ToLower(strings.TrimSpace(string(data))), "0x")
+}
+
+// storageDriverRank orders storage drivers from best to worst for a VM
+var storageDriverRank = []string{"vmw_pvscsi", "nvme", "ahci", "mptsas", "mptspi", "BusLogic", "ata_piix"}
+
+// bestStorageDriver returns the best driver bound to a storage controller
+func bestStorageDriver(devices []PCIDevice) string {
+	bound := make(map[string]bool)
+	for _, dev := range devices {
+		if dev.BaseClass() == pciClassStorage && dev.Driver != "" {
+			bound[dev.Driver] = true
+		}
+	}
+	for _, driver := range storageDriverRank {
+		if bound[driver] {
+			return driver
+		}
+	}
+	return ""
+}
+
+// pciModelFor returns the model of the PCI device backing a network interface
+func pciModelFor(devices []PCIDevice, iface string) string {
+	target, err := Sys.Readlink("/sys/class/net", iface, "device")
+	if err != nil {
+		return ""
+	}
+	addr := filepath.Base(target)
+	for _, dev := range devices {
+		if dev.Address == addr {
+			return dev.Model
+		}
+	}
+	return ""
+}
@@ -0,0 +1,58 @@
+package tuner
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writePCIDevice creates a sysfs PCI device in a fixture tree
+func writePCIDevice(t *testing.T, root, addr, vendor, device, class, driver string) {
+	t.Helper()
+	dir := filepath.Join(root, "sys/bus/pci/devices", addr)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	for name, value := range map[string]string{"vendor": vendor, "device": device, "class": class} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(value+"\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if driver != "" {
+		if err := os.Symlink("../../../bus/pci/drivers/"+driver, filepath.Join(dir, "driver")); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestListPCIDevices(t *testing.T) {
+	root := t.TempDir()
+	writePCIDevice(t, root, "0000:03:00.0", "0x15ad", "0x07c0", "0x010700", "vmw_pvscsi")
+	writePCIDevice(t, root, "0000:0b:00.0", "0x15ad", "0x07b0", "0x020000", "vmxnet3")
+	writePCIDevice(t, root, "0000:00:10.0", "0x1000", "0x0030", "0x010000", "mptspi")
+	writePCIDevice(t, root, "0000:00:11.0", "0x8086", "0x1234", "0x088000", "")
+
+	devices, err := ListPCIDevices(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(devices) != 4 {
+		t.Fatalf("expected 4 devices, got %d", len(devices))
+	}
+
+	byAddr := make(map[string]PCIDevice)
+	for _, dev := range devices {
+		byAddr[dev.Address] = dev
+	}
+	nic := byAddr["0000:0b:00.0"]
+	if nic.Model != "VMware VMXNET3" || nic.Driver != "vmxnet3" || !nic.IsVMware() || nic.BaseClass() != pciClassNetwork {
+		t.Errorf("unexpected vmxnet3 device %+v", nic)
+	}
+	if unknown := byAddr["0000:00:11.0"]; unknown.Model != "Unknown device 8086:1234" || unknown.Driver != "" {
+		t.Errorf("unexpected unknown device %+v", unknown)
+	}
+
+	if got := bestStorageDriver(devices); got != "vmw_pvscsi" {
+		t.Errorf("bestStorageDriver = %q, want vmw_pvscsi over mptspi", got)
+	}
+}
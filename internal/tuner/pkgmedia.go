@@ -0,0 +1,183 @@
+package tuner
+
+import (
+	"bufio"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// mediaRepoID names the temporary repositories built on an installation medium
+const mediaRepoID = "vmware-tuner-media"
+
+// maxPackageDepth bounds the search for package files: distribution media
+// keep them a few levels down (pool/main/o/open-vm-tools, AppStream/Packages/o)
+const maxPackageDepth = 5
+
+// MountISO mounts a distribution or package ISO read-only on a temporary
+// directory, used as the package directory (--pkg-dir image.iso)
+func MountISO(image string) (string, error) {
+	dir, err := os.MkdirTemp("", "vmware-tuner-iso")
+	if err != nil {
+		return "", fmt.Errorf("failed to create mount point: %w", err)
+	}
+	ExplainCommand("read the packages of the ISO image", "mount", "-o", "loop,ro", image, dir)
+	if out, err := exec.Command("mount", "-o", "loop,ro", image, dir).CombinedOutput(); err != nil {
+		os.Remove(dir)
+		return "", fmt.Errorf("failed to mount %s: %v: %s", image, err, strings.TrimSpace(string(out)))
+	}
+	return dir, nil
+}
+
+// UnmountISO releases a mount made by MountISO
+func UnmountISO(dir string) {
+	if err := exec.Command("umount", dir).Run(); err != nil {
+		PrintWarning("Failed to unmount %s: %v", dir, err)
+		return
+	}
+	os.Remove(dir)
+}
+
+// findPackageFiles returns the files under dir whose name matches pattern,
+// sorted by name, down to maxPackageDepth levels
+func findPackageFiles(dir, pattern string) []string {
+	var matches []string
+	filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if d.IsDir() {
+			if rel, _ := filepath.Rel(dir, path); rel != "." && strings.Count(rel, string(filepath.Separator)) >= maxPackageDepth {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if ok, _ := filepath.Match(pattern, d.Name()); ok {
+			matches = append(matches, path)
+		}
+		return nil
+	})
+	sort.Slice(matches, func(i, j int) bool { return filepath.Base(matches[i]) < filepath.Base(matches[j]) })
+	return matches
+}
+
+// mediaRepos lists the package repositories of an installation medium:
+// the directories holding repodata (RHEL family) and the apt sources of the
+// dists/ suites (Debian family). Both are empty for a plain directory of
+// package files.
+func mediaRepos(dir string) (rpmDirs []string, aptSources []string) {
+	if FileExists(filepath.Join(dir, "repodata")) {
+		rpmDirs = append(rpmDirs, dir)
+	}
+	subdirs, _ := filepath.Glob(filepath.Join(dir, "*", "repodata"))
+	for _, repodata := range subdirs {
+		rpmDirs = append(rpmDirs, filepath.Dir(repodata))
+	}
+
+	releases, _ := filepath.Glob(filepath.Join(dir, "dists", "*", "Release"))
+	for _, release := range releases {
+		suite := filepath.Base(filepath.Dir(release))
+		if components := releaseComponents(release); len(components) > 0 {
+			aptSources = append(aptSources, fmt.Sprintf("deb [trusted=yes] file:%s %s %s", dir, suite, strings.Join(components, " ")))
+		}
+	}
+	return rpmDirs, aptSources
+}
+
+// releaseComponents reads the Components field of a Debian Release file
+func releaseComponents(path string) []string {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if line := scanner.Text(); strings.HasPrefix(line, "Components:") {
+			return strings.Fields(strings.TrimPrefix(line, "Components:"))
+		}
+	}
+	return nil
+}
+
+// mediaYumRepos returns the .repo file of the rpm repositories of the medium
+func mediaYumRepos(rpmDirs []string) string {
+	var b strings.Builder
+	for i, dir := range rpmDirs {
+		fmt.Fprintf(&b, "[%s-%d]\nname=Installation medium %d\nbaseurl=file://%s\nenabled=1\ngpgcheck=0\n\n", mediaRepoID, i, i, dir)
+	}
+	return b.String()
+}
+
+// installFromMedia installs pkg from the repositories of the installation
+// medium in PackageDir, so its dependencies come from the medium as well
+func (dm *DistroManager) installFromMedia(pkg string, rpmDirs, aptSources []string) error {
+	var cmd *exec.Cmd
+
+	switch dm.Type {
+	case DistroDebian:
+		if len(aptSources) == 0 {
+			return fmt.Errorf("no apt repository on the medium")
+		}
+		list, err := os.CreateTemp("", "vmware-tuner-media*.list")
+		if err != nil {
+			return fmt.Errorf("failed to create apt source list: %w", err)
+		}
+		defer os.Remove(list.Name())
+		fmt.Fprintln(list, strings.Join(aptSources, "\n"))
+		list.Close()
+
+		aptOpts := []string{
+			"-o", "Dir::Etc::SourceList=" + list.Name(),
+			"-o", "Dir::Etc::SourceParts=-",
+			"-o", "APT::Get::List-Cleanup=0",
+		}
+		if out, err := exec.Command("apt-get", append([]string{"update"}, aptOpts...)...).CombinedOutput(); err != nil {
+			return fmt.Errorf("failed to read the medium index: %v\nOutput: %s", err, string(out))
+		}
+		cmd = exec.Command("apt-get", append(append([]string{"install", "-y"}, aptOpts...), pkg)...)
+		cmd.Env = append(os.Environ(), "DEBIAN_FRONTEND=noninteractive")
+	case DistroRHEL:
+		if len(rpmDirs) == 0 {
+			return fmt.Errorf("no rpm repository on the medium")
+		}
+		args := []string{"install", "-y", "--disablerepo=*"}
+		if _, err := exec.LookPath("dnf"); err == nil {
+			for i, dir := range rpmDirs {
+				id := fmt.Sprintf("%s-%d", mediaRepoID, i)
+				args = append(args, "--repofrompath="+id+","+dir, "--setopt="+id+".gpgcheck=0")
+			}
+			cmd = exec.Command("dnf", append(args, pkg)...)
+			break
+		}
+
+		// yum (RHEL/CentOS 7) has no --repofrompath: the medium repositories
+		// go in a .repo file of their own directory
+		reposDir, err := os.MkdirTemp("", "vmware-tuner-media")
+		if err != nil {
+			return fmt.Errorf("failed to create yum repository directory: %w", err)
+		}
+		defer os.RemoveAll(reposDir)
+		if err := os.WriteFile(filepath.Join(reposDir, mediaRepoID+".repo"), []byte(mediaYumRepos(rpmDirs)), 0644); err != nil {
+			return fmt.Errorf("failed to write yum repository file: %w", err)
+		}
+		args = append(args, "--enablerepo="+mediaRepoID+"-*", "--setopt=reposdir="+reposDir)
+		cmd = exec.Command("yum", append(args, pkg)...)
+	default:
+		return fmt.Errorf("installing %s: %w", pkg, ErrUnsupportedDistro)
+	}
+
+	PrintInfo("Installing package %s from the installation medium...", pkg)
+	ExplainCommand("install "+pkg+" and its dependencies from the local medium (air-gapped)", cmd.Args[0], cmd.Args[1:]...)
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("failed to install %s: %v\nOutput: %s", pkg, err, string(output))
+	}
+
+	PrintSuccess("Installed %s (installation medium)", pkg)
+	return nil
+}
@@ -0,0 +1,95 @@
+package tuner
+
+import (
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestFindLocalPackageOnMedia(t *testing.T) {
+	saved := PackageDir
+	defer func() { PackageDir = saved }()
+
+	debian := t.TempDir()
+	writeFiles(t, debian, map[string]string{
+		"dists/bookworm/Release": "Origin: Debian\nSuite: stable\nComponents: main contrib\n",
+		"pool/main/o/open-vm-tools/open-vm-tools_12.1.5-3_amd64.deb":         "",
+		"pool/main/o/open-vm-tools/open-vm-tools-desktop_12.1.5-3_amd64.deb": "",
+		"pool/main/o/open-vm-tools/open-vm-tools_12.2.0-1_amd64.deb":         "",
+		"a/b/c/d/e/f/ethtool_6.1-1_amd64.deb":                                "", // too deep
+	})
+	PackageDir = debian
+	dm := &DistroManager{Type: DistroDebian}
+	if got, want := dm.FindLocalPackage("open-vm-tools"), filepath.Join(debian, "pool/main/o/open-vm-tools/open-vm-tools_12.2.0-1_amd64.deb"); got != want {
+		t.Errorf("open-vm-tools = %q, want %q", got, want)
+	}
+	if got := dm.FindLocalPackage("ethtool"); got != "" {
+		t.Errorf("package below the depth limit found: %s", got)
+	}
+	rpmDirs, aptSources := mediaRepos(debian)
+	if want := []string{"deb [trusted=yes] file:" + debian + " bookworm main contrib"}; len(rpmDirs) != 0 || !reflect.DeepEqual(aptSources, want) {
+		t.Errorf("debian medium: rpm %v, apt %v, want %v", rpmDirs, aptSources, want)
+	}
+
+	rhel := t.TempDir()
+	writeFiles(t, rhel, map[string]string{
+		"BaseOS/repodata/repomd.xml":                                     "",
+		"AppStream/repodata/repomd.xml":                                  "",
+		"AppStream/Packages/open-vm-tools-12.2.5-3.el9.x86_64.rpm":       "",
+		"AppStream/Packages/open-vm-tools-desktop-12.2.5.el9.x86_64.rpm": "",
+	})
+	PackageDir = rhel
+	dm = &DistroManager{Type: DistroRHEL}
+	if got, want := dm.FindLocalPackage("open-vm-tools"), filepath.Join(rhel, "AppStream/Packages/open-vm-tools-12.2.5-3.el9.x86_64.rpm"); got != want {
+		t.Errorf("open-vm-tools = %q, want %q", got, want)
+	}
+	rpmDirs, aptSources = mediaRepos(rhel)
+	if want := []string{filepath.Join(rhel, "AppStream"), filepath.Join(rhel, "BaseOS")}; !reflect.DeepEqual(rpmDirs, want) || len(aptSources) != 0 {
+		t.Errorf("rhel medium: rpm %v, apt %v, want %v", rpmDirs, aptSources, want)
+	}
+
+	// A plain directory of package files has no repository
+	if rpmDirs, aptSources := mediaRepos(t.TempDir()); len(rpmDirs)+len(aptSources) != 0 {
+		t.Errorf("plain directory: rpm %v, apt %v", rpmDirs, aptSources)
+	}
+}
+
+func TestNewestPackageFile(t *testing.T) {
+	files := []string{
+		"/media/node_exporter-1.9.1.linux-amd64.tar.gz",
+		"/media/node_exporter-1.10.2.linux-amd64.tar.gz",
+		"/media/node_exporter-1.8.2.linux-amd64.tar.gz",
+	}
+	if got := newestPackageFile(files); got != files[1] {
+		t.Errorf("newest = %s, want %s", got, files[1])
+	}
+	if got := newestPackageFile(nil); got != "" {
+		t.Errorf("newest of none = %q", got)
+	}
+	for _, tc := range []struct {
+		a, b string
+		want int
+	}{
+		{"12.2.0-1", "12.1.5-3", 1},
+		{"1.9", "1.10", -1},
+		{"1.010", "1.10", 0},
+		{"2.0", "2.0.1", -1},
+	} {
+		if got := compareVersions(tc.a, tc.b); got != tc.want {
+			t.Errorf("compareVersions(%s, %s) = %d, want %d", tc.a, tc.b, got, tc.want)
+		}
+	}
+}
+
+func TestMediaYumRepos(t *testing.T) {
+	repos := mediaYumRepos([]string{"/mnt/cdrom/BaseOS", "/mnt/cdrom/AppStream"})
+	for _, want := range []string{
+		"[vmware-tuner-media-0]\nname=Installation medium 0\nbaseurl=file:///mnt/cdrom/BaseOS\nenabled=1\ngpgcheck=0\n",
+		"[vmware-tuner-media-1]\nname=Installation medium 1\nbaseurl=file:///mnt/cdrom/AppStream\n",
+	} {
+		if !strings.Contains(repos, want) {
+			t.Errorf("repo file lacks %q:\n%s", want, repos)
+		}
+	}
+}
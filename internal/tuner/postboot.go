@@ -0,0 +1,165 @@
+package tuner
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Post-boot verification: a one-shot unit runs `verify --json --post-boot` on
+// the first boot after tuning. The pending file arms it for one boot only.
+const (
+	postBootUnitName    = "vmware-tuner-verify.service"
+	postBootPendingName = "post-boot-verify.pending"
+	postBootResultName  = "post-boot-verify.json"
+)
+
+// VerifyReport is the output of `verify --json`
+type VerifyReport struct {
+	Timestamp string        `json:"timestamp"`
+	Hostname  string        `json:"hostname"`
+	BootID    string        `json:"boot_id,omitempty"`
+	OK        bool          `json:"ok"`
+	Checks    []VerifyCheck `json:"checks"`
+}
+
+// NewVerifyReport runs the verify checks
+func NewVerifyReport(distro *DistroManager) VerifyReport {
+	hostname, _ := os.Hostname()
+	bootID, _ := Sys.ReadString("/proc/sys/kernel/random/boot_id")
+	report := VerifyReport{
+		Timestamp: time.Now().Format(time.RFC3339),
+		Hostname:  hostname,
+		BootID:    bootID,
+		OK:        true,
+		Checks:    CollectVerifyChecks(distro),
+	}
+	for _, check := range report.Checks {
+		if !check.OK {
+			report.OK = false
+		}
+	}
+	return report
+}
+
+// PostBootVerifier installs the post-boot verification unit and records its result
+type PostBootVerifier struct {
+	DryRun   bool
+	UnitPath string
+	StateDir string
+	Notifier *Notifier
+}
+
+// NewPostBootVerifier creates a new post-boot verifier
+func NewPostBootVerifier(dryRun bool, notify NotifyConfig) *PostBootVerifier {
+	return &PostBootVerifier{
+		DryRun:   dryRun,
+		UnitPath: filepath.Join("/etc/systemd/system", postBootUnitName),
+		StateDir: StateDir,
+		Notifier: NewNotifier(notify),
+	}
+}
+
+// Unit returns the one-shot unit running the verification
+func (pv *PostBootVerifier) Unit(binPath string) string {
+	return fmt.Sprintf(`[Unit]
+Description=vmware-tuner: verify the tuning after the first reboot
+%sAfter=multi-user.target
+ConditionPathExists=%s
+
+[Service]
+Type=oneshot
+ExecStart=%s verify --json --post-boot
+
+[Install]
+WantedBy=multi-user.target
+`, networkOnlineDeps(DetectNetworkStack("")), filepath.Join(pv.StateDir, postBootPendingName), binPath)
+}
+
+// Install writes and enables the unit and arms it for the next boot
+// (--verify-after-reboot)
+func (pv *PostBootVerifier) Install(backup *BackupManager) error {
+	PrintStep("Post-Reboot Verification")
+
+	binPath, err := schedulableBinary()
+	if err != nil {
+		return err
+	}
+	unit := pv.Unit(binPath)
+	pending := filepath.Join(pv.StateDir, postBootPendingName)
+
+	if pv.DryRun {
+		PrintInfo("Would create: %s", pv.UnitPath)
+		PrintInfo("Would run: systemctl enable %s", postBootUnitName)
+		return nil
+	}
+
+	if current, err := os.ReadFile(pv.UnitPath); err != nil || string(current) != unit {
+		if err := backup.BackupFile(pv.UnitPath); err != nil {
+			return fmt.Errorf("failed to backup %s: %w", pv.UnitPath, err)
+		}
+		ExplainEdit("check on the next boot that the boot-time changes (GRUB, udev, fstab) took effect", pv.UnitPath)
+		if err := os.WriteFile(pv.UnitPath, []byte(unit), 0644); err != nil {
+			return fmt.Errorf("failed to write %s: %w", pv.UnitPath, err)
+		}
+		PrintSuccess("Created %s", pv.UnitPath)
+		exec.Command("systemctl", "daemon-reload").Run()
+	}
+
+	ExplainCommand("run the verification at boot", "systemctl", "enable", postBootUnitName)
+	if out, err := exec.Command("systemctl", "enable", postBootUnitName).CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to enable %s: %v: %s", postBootUnitName, err, strings.TrimSpace(string(out)))
+	}
+
+	if err := os.MkdirAll(pv.StateDir, 0755); err != nil {
+		return fmt.Errorf("failed to create %s: %w", pv.StateDir, err)
+	}
+	if err := os.WriteFile(pending, []byte(time.Now().Format(time.RFC3339)+"\n"), 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", pending, err)
+	}
+	PrintSuccess("The next boot runs 'vmware-tuner verify'; result in %s", filepath.Join(pv.StateDir, postBootResultName))
+	return nil
+}
+
+// Record stores the result of the post-boot run, disarms the unit and
+// notifies when a notify: section is configured
+func (pv *PostBootVerifier) Record(report VerifyReport) error {
+	resultPath := filepath.Join(pv.StateDir, postBootResultName)
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal report: %w", err)
+	}
+	if err := os.MkdirAll(pv.StateDir, 0755); err != nil {
+		return fmt.Errorf("failed to create %s: %w", pv.StateDir, err)
+	}
+	if err := os.WriteFile(resultPath, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", resultPath, err)
+	}
+	if err := os.Remove(filepath.Join(pv.StateDir, postBootPendingName)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to disarm the post-boot verification: %w", err)
+	}
+
+	if !pv.Notifier.Config.Enabled() {
+		return nil
+	}
+	status := "all tuning applied"
+	var failed []string
+	for _, check := range report.Checks {
+		if !check.OK {
+			failed = append(failed, fmt.Sprintf("%s: %s", check.Name, check.Message))
+		}
+	}
+	if len(failed) > 0 {
+		status = fmt.Sprintf("%d check(s) failed", len(failed))
+	}
+	subject := fmt.Sprintf("[vmware-tuner] %s: post-reboot verification, %s", report.Hostname, status)
+	body := fmt.Sprintf("Verification after the reboot of %s (%s).\n", report.Hostname, report.Timestamp)
+	if len(failed) > 0 {
+		body += "\n- " + strings.Join(failed, "\n- ") + "\n"
+	}
+	return pv.Notifier.Send(subject, body)
+}
@@ -0,0 +1,50 @@
+package tuner
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestPostBootRecord(t *testing.T) {
+	pv := NewPostBootVerifier(false, NotifyConfig{})
+	pv.StateDir = t.TempDir()
+	pending := filepath.Join(pv.StateDir, postBootPendingName)
+	os.WriteFile(pending, []byte("armed\n"), 0644)
+
+	report := VerifyReport{Hostname: "web01", Checks: []VerifyCheck{
+		{Name: "Sysctl", OK: true},
+		{Name: "Boot Parameters", OK: false, Message: "missing from the grub configuration: elevator=noop"},
+	}}
+	if err := pv.Record(report); err != nil {
+		t.Fatalf("Record() = %v", err)
+	}
+
+	if FileExists(pending) {
+		t.Error("the unit is still armed after the post-boot run")
+	}
+	data, err := os.ReadFile(filepath.Join(pv.StateDir, postBootResultName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var saved VerifyReport
+	if err := json.Unmarshal(data, &saved); err != nil || len(saved.Checks) != 2 || saved.Checks[1].OK {
+		t.Errorf("result not recorded: %s", data)
+	}
+}
+
+func TestPostBootUnit(t *testing.T) {
+	pv := &PostBootVerifier{StateDir: "/var/lib/vmware-tuner"}
+	unit := pv.Unit("/usr/local/bin/vmware-tuner")
+	for _, want := range []string{
+		"ConditionPathExists=/var/lib/vmware-tuner/" + postBootPendingName,
+		"ExecStart=/usr/local/bin/vmware-tuner verify --json --post-boot",
+		"Type=oneshot",
+	} {
+		if !strings.Contains(unit, want) {
+			t.Errorf("unit lacks %q:\n%s", want, unit)
+		}
+	}
+}
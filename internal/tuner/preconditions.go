@@ -0,0 +1,152 @@
+package tuner
+
+import (
+	"fmt"
+
+	"golang.org/x/sys/unix"
+)
+
+// Precondition is the minimum free space (or available memory when Path is
+// empty) a module needs
+type Precondition struct {
+	Path   string
+	MinMB  int64
+	Reason string
+}
+
+// ModulePreconditions lists the resources each module needs before it starts
+var ModulePreconditions = map[string][]Precondition{
+	"update": {
+		{Path: "/var", MinMB: 1000, Reason: "package downloads"},
+		{Path: "/boot", MinMB: 100, Reason: "new kernel and initramfs"},
+		{MinMB: 256, Reason: "package manager metadata"},
+	},
+	"swap": {
+		{Path: "/", MinMB: 2560, Reason: "2 GB swapfile plus headroom"},
+	},
+	"benchmark": {
+		{Path: "/tmp", MinMB: 150, Reason: "100 MB download test file"},
+	},
+}
+
+// CheckPreconditions verifies the free space and memory a module needs.
+// The returned error is the reason to skip the module.
+func CheckPreconditions(module string) error {
+	for _, p := range ModulePreconditions[module] {
+		if err := p.Check(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Check verifies one precondition. A missing path (e.g. no /boot in a
+// container) is not checked.
+func (p Precondition) Check() error {
+	if p.Path == "" {
+		avail, err := AvailableMemoryMB()
+		if err != nil {
+			return fmt.Errorf("failed to check available memory: %w", err)
+		}
+		if avail < p.MinMB {
+			return fmt.Errorf("insufficient memory: %s available, %s required (%s)", FormatMiB(avail), FormatMiB(p.MinMB), p.Reason)
+		}
+		PrintSuccess("Memory OK (%s available)", FormatMiB(avail))
+		return nil
+	}
+
+	if !FileExists(p.Path) {
+		return nil
+	}
+	avail, err := FreeSpaceMB(p.Path)
+	if err != nil {
+		return fmt.Errorf("failed to check disk space on %s: %w", p.Path, err)
+	}
+	if avail < p.MinMB {
+		return fmt.Errorf("insufficient disk space on %s: %s free, %s required (%s)", p.Path, FormatMiB(avail), FormatMiB(p.MinMB), p.Reason)
+	}
+	PrintSuccess("Disk space OK (%s free on %s)", FormatMiB(avail), p.Path)
+	return nil
+}
+
+// FilesystemUsage is the size of the filesystem holding a path, in MB
+type FilesystemUsage struct {
+	TotalMB int64
+	FreeMB  int64 // including the blocks reserved for root
+	AvailMB int64 // available to unprivileged users
+}
+
+// UsedPercent returns the share of the filesystem in use, as df reports it
+func (u FilesystemUsage) UsedPercent() float64 {
+	used := u.TotalMB - u.FreeMB
+	if used+u.AvailMB == 0 {
+		return 0
+	}
+	return float64(used) * 100 / float64(used+u.AvailMB)
+}
+
+// StatFilesystem returns the usage of the filesystem holding path (statfs)
+func StatFilesystem(path string) (FilesystemUsage, error) {
+	var st unix.Statfs_t
+	if err := unix.Statfs(path, &st); err != nil {
+		return FilesystemUsage{}, err
+	}
+	bsize := uint64(st.Bsize)
+	return FilesystemUsage{
+		TotalMB: int64(st.Blocks * bsize >> 20),
+		FreeMB:  int64(st.Bfree * bsize >> 20),
+		AvailMB: int64(st.Bavail * bsize >> 20),
+	}, nil
+}
+
+// FreeSpaceMB returns the space available to unprivileged users on the
+// filesystem holding path
+func FreeSpaceMB(path string) (int64, error) {
+	usage, err := StatFilesystem(path)
+	if err != nil {
+		return 0, err
+	}
+	return usage.AvailMB, nil
+}
+
+// PrintFilesystemUsage shows the size and free space of the filesystem holding path
+func PrintFilesystemUsage(path string) {
+	usage, err := StatFilesystem(path)
+	if err != nil {
+		PrintWarning("Could not read disk usage of %s: %v", path, err)
+		return
+	}
+	PrintInfo("%s: %s total, %s available (%.0f%% used)",
+		path, FormatMiB(usage.TotalMB), FormatMiB(usage.AvailMB), usage.UsedPercent())
+}
+
+// MemoryUsage returns the total and used memory in MB (sysinfo). Used memory
+// excludes buffers and page cache, like free(1).
+func MemoryUsage() (int64, int64, error) {
+	var si unix.Sysinfo_t
+	if err := unix.Sysinfo(&si); err != nil {
+		return 0, 0, err
+	}
+	unit := uint64(si.Unit)
+	total := int64(uint64(si.Totalram) * unit >> 20)
+
+	avail, err := AvailableMemoryMB()
+	if err != nil {
+		avail = int64((uint64(si.Freeram) + uint64(si.Bufferram)) * unit >> 20)
+	}
+	return total, total - avail, nil
+}
+
+// AvailableMemoryMB returns MemAvailable from /proc/meminfo (free + reclaimable
+// cache, which sysinfo does not report)
+func AvailableMemoryMB() (int64, error) {
+	meminfo, err := Sys.MemInfo()
+	if err != nil {
+		return 0, err
+	}
+	kb, ok := meminfo["MemAvailable"]
+	if !ok {
+		return 0, fmt.Errorf("MemAvailable not found in /proc/meminfo")
+	}
+	return kb / 1024, nil
+}
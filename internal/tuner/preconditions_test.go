@@ -0,0 +1,25 @@
+package tuner
+
+import "testing"
+
+func TestStatFilesystem(t *testing.T) {
+	dir := t.TempDir()
+	usage, err := StatFilesystem(dir)
+	if err != nil {
+		t.Fatalf("StatFilesystem: %v", err)
+	}
+	if usage.TotalMB <= 0 || usage.AvailMB > usage.FreeMB || usage.FreeMB > usage.TotalMB {
+		t.Errorf("inconsistent usage: %+v", usage)
+	}
+	if pct := usage.UsedPercent(); pct < 0 || pct > 100 {
+		t.Errorf("UsedPercent = %.1f", pct)
+	}
+
+	p := Precondition{Path: dir, MinMB: usage.TotalMB + 1, Reason: "test"}
+	if err := p.Check(); err == nil {
+		t.Error("a precondition larger than the filesystem should fail")
+	}
+	if err := (Precondition{Path: "/nonexistent-vmware-tuner", MinMB: 1 << 40}).Check(); err != nil {
+		t.Errorf("missing paths should not be checked: %v", err)
+	}
+}
@@ -0,0 +1,95 @@
+package tuner
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+const (
+	// ProductionMarkerFile tags the VM as production when present
+	ProductionMarkerFile = "/etc/vmware-tuner/production"
+
+	// ProductionGuestInfoKey tags the VM as production from vSphere
+	// (VM advanced setting guestinfo.vmware-tuner.environment = production)
+	ProductionGuestInfoKey = "guestinfo.vmware-tuner.environment"
+)
+
+// ForceProduction is set by the --production flag
+var ForceProduction bool
+
+// IsProduction reports whether the VM is tagged as production and by which marker
+func IsProduction() (bool, string) {
+	if ForceProduction {
+		return true, "--production flag"
+	}
+
+	if FileExists(ProductionMarkerFile) {
+		return true, ProductionMarkerFile
+	}
+
+	if value := readGuestInfo(ProductionGuestInfoKey); strings.EqualFold(value, "production") {
+		return true, ProductionGuestInfoKey
+	}
+
+	return false, ""
+}
+
+// readGuestInfo reads a guestinfo key set on the VM (empty if unavailable)
+func readGuestInfo(key string) string {
+	var cmd *exec.Cmd
+	if _, err := exec.LookPath("vmware-rpctool"); err == nil {
+		cmd = exec.Command("vmware-rpctool", "info-get "+key)
+	} else if _, err := exec.LookPath("vmtoolsd"); err == nil {
+		cmd = exec.Command("vmtoolsd", "--cmd", "info-get "+key)
+	} else {
+		return ""
+	}
+
+	out, err := cmd.Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(out))
+}
+
+// isInteractive reports whether stdin is a terminal
+func isInteractive() bool {
+	info, err := os.Stdin.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
+
+// GuardDestructive protects risky actions on production VMs.
+// Non-production VMs pass through; production VMs require typing the hostname
+// and are blocked entirely when running non-interactively (automation).
+func GuardDestructive(action string) error {
+	production, source := IsProduction()
+	if !production {
+		return nil
+	}
+
+	PrintWarning("PRODUCTION VM (tagged by %s)", source)
+	PrintWarning("'%s' is a risky operation on a production system.", action)
+
+	if !isInteractive() {
+		return fmt.Errorf("'%s' blocked on production VM in non-interactive mode", action)
+	}
+
+	hostname, err := os.Hostname()
+	if err != nil || hostname == "" {
+		hostname = "production"
+	}
+
+	fmt.Printf("Type the hostname '%s' to confirm: ", hostname)
+	input, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	if strings.TrimSpace(input) != hostname {
+		return fmt.Errorf("'%s' cancelled (production confirmation failed)", action)
+	}
+
+	return nil
+}
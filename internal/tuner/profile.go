@@ -0,0 +1,301 @@
+package tuner
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// Profile bundles coherent tuning values for a workload
+type Profile struct {
+	Name        string
+	Description string
+	GrubParams  []string          // added to the boot parameters
+	Sysctl      map[string]string // applied over the defaults, under the config file
+	Scheduler   string            // I/O scheduler of the disks
+	THP         string            // transparent_hugepage: always, madvise or never
+	Queue       QueueSettings     // block queues of the disks (zero: defaults)
+	PVSCSI      bool              // deeper vmw_pvscsi queues, see PVSCSITuner
+	Affinity    string            // NIC queue placement: AffinitySpread or AffinityPin ("" for spread)
+	NIC         NICSettings       // vmxnet3 rings and coalescing (zero: defaults)
+	OmitParams  []string          // default boot parameters left out (keys)
+	DebloatKeep []string          // services Server Slim never disables
+	HGFS        bool              // shared folders mounted with caching, see HGFSTuner
+	Sched       bool              // scheduler knobs on wide VMs, see SchedSysctl
+}
+
+// Profiles are the built-in tuning profiles (--profile or tuning.profile)
+var Profiles = map[string]Profile{
+	"default": {
+		Name:        "default",
+		Description: "Balanced settings for general purpose VMs",
+		Scheduler:   "none",
+		THP:         "madvise",
+	},
+	"throughput": {
+		Name:        "throughput",
+		Description: "Batch and file servers: large buffers, more dirty pages in flight",
+		Sysctl: map[string]string{
+			"vm.dirty_ratio":              "40",
+			"vm.dirty_background_ratio":   "10",
+			"net.core.netdev_max_backlog": "30000",
+			"net.core.netdev_budget":      "600",
+		},
+		Scheduler: "none",
+		THP:       "always",
+		Queue:     QueueSettings{ReadAheadKB: 4096, RqAffinity: 2},
+		PVSCSI:    true,
+		NIC:       NICSettings{RxUsecs: 50, TxUsecs: 50},
+		Sched:     true,
+	},
+	"low-latency": {
+		Name:        "low-latency",
+		Description: "Trading, VoIP, real-time: busy polling, no THP compaction stalls",
+		GrubParams:  []string{"skew_tick=1"},
+		Sysctl: map[string]string{
+			"net.core.busy_poll":    "50",
+			"net.core.busy_read":    "50",
+			"kernel.numa_balancing": "0",
+			"vm.stat_interval":      "10",
+		},
+		Scheduler: "none",
+		THP:       "never",
+		Queue:     QueueSettings{ReadAheadKB: 128, RqAffinity: 2},
+		Affinity:  AffinityPin,
+		NIC:       NICSettings{RxUsecs: CoalesceOff, TxUsecs: CoalesceOff},
+	},
+	"database": {
+		Name:        "database",
+		Description: "PostgreSQL, MySQL, Oracle, MongoDB: no THP, minimal swapping, steady writeback",
+		Sysctl: map[string]string{
+			"vm.swappiness":                  "1",
+			"vm.dirty_ratio":                 "10",
+			"vm.dirty_background_ratio":      "3",
+			"kernel.numa_balancing":          "0",
+			"kernel.sched_autogroup_enabled": "0",
+		},
+		Scheduler: "mq-deadline",
+		THP:       "never",
+		Queue:     QueueSettings{ReadAheadKB: 1024},
+		PVSCSI:    true,
+		Sched:     true,
+	},
+	"developer": {
+		Name:        "developer",
+		Description: "Developer VMs on VMware Workstation/Fusion: desktop services kept, light power changes, cached shared folders",
+		// PCIe power saving stays on and the vCPUs may idle in C1: the host is
+		// a laptop or a desktop, and C1 is enough to keep the guest responsive
+		GrubParams: []string{"intel_idle.max_cstate=1"},
+		OmitParams: []string{"intel_idle.max_cstate", "processor.max_cstate", "pcie_aspm", "nvme_core.default_ps_max_latency_us"},
+		Sysctl: map[string]string{
+			"vm.swappiness":                 "10",
+			"fs.inotify.max_user_watches":   "524288",
+			"fs.inotify.max_user_instances": "1024",
+		},
+		Scheduler:   "mq-deadline",
+		THP:         "madvise",
+		DebloatKeep: []string{"cups", "cups-browsed", "avahi-daemon", "bluetooth", "wpa_supplicant", "modemmanager"},
+		HGFS:        true,
+	},
+	"web": {
+		Name:        "web",
+		Description: "Web and API servers: many short connections",
+		Sysctl: map[string]string{
+			"net.core.somaxconn":           "65535",
+			"net.ipv4.tcp_max_syn_backlog": "65535",
+			"net.ipv4.ip_local_port_range": "1024 65535",
+			"net.ipv4.tcp_tw_reuse":        "1",
+			"net.ipv4.tcp_fin_timeout":     "15",
+		},
+		Scheduler: "none",
+		THP:       "madvise",
+	},
+}
+
+// ProfileNames returns the profile names, sorted
+func ProfileNames() []string {
+	var names []string
+	for name := range Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// LookupProfile returns a profile by name ("" is the default profile)
+func LookupProfile(name string) (Profile, error) {
+	if name == "" {
+		name = "default"
+	}
+	p, ok := Profiles[name]
+	if !ok {
+		return Profile{}, fmt.Errorf("unknown profile %q (available: %s)", name, strings.Join(ProfileNames(), ", "))
+	}
+	return p, nil
+}
+
+// ActiveProfile returns the selected profile
+func (tc TuningConfig) ActiveProfile() Profile {
+	p, err := LookupProfile(tc.Profile)
+	if err != nil {
+		return Profiles["default"]
+	}
+	return p
+}
+
+// SysctlValues returns the profile values with the config file on top
+func (tc TuningConfig) SysctlValues() map[string]string {
+	values := make(map[string]string)
+	for k, v := range tc.ActiveProfile().Sysctl {
+		values[k] = v
+	}
+	for k, v := range tc.Sysctl {
+		values[k] = v
+	}
+	return values
+}
+
+// withProfileParams sets the THP mode of the profile, leaves out the
+// parameters it omits and appends its boot parameters that are not already
+// present
+func withProfileParams(params []string, p Profile) []string {
+	omitted := make(map[string]bool)
+	for _, key := range p.OmitParams {
+		omitted[key] = true
+	}
+	var result []string
+	present := make(map[string]bool)
+	for _, param := range params {
+		key := paramKey(param)
+		if omitted[key] {
+			continue
+		}
+		if key == "transparent_hugepage" && p.THP != "" {
+			param = "transparent_hugepage=" + p.THP
+		}
+		present[key] = true
+		result = append(result, param)
+	}
+	if !present["transparent_hugepage"] && p.THP != "" {
+		result = append(result, "transparent_hugepage="+p.THP)
+	}
+	for _, param := range p.GrubParams {
+		if !present[paramKey(param)] {
+			result = append(result, param)
+		}
+	}
+	return result
+}
+
+// profileHeader marks the profile in the generated sysctl file
+const profileHeader = "# Profile: "
+
+// AppliedProfile reads the profile recorded in the generated sysctl file
+func AppliedProfile(sysctlPath string) string {
+	file, err := os.Open(sysctlPath)
+	if err != nil {
+		return ""
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if line := scanner.Text(); strings.HasPrefix(line, profileHeader) {
+			return strings.TrimSpace(strings.TrimPrefix(line, profileHeader))
+		}
+	}
+	return ""
+}
+
+// readSysctl reads the runtime value of a key from /proc/sys
+func readSysctl(key string) (string, error) {
+	return readSysctlAt(Sys.Root, key)
+}
+
+// readSysctlAt reads a sysctl under fsRoot. Keys in the slash form are kept.
+func readSysctlAt(fsRoot, key string) (string, error) {
+	if !strings.Contains(key, "/") {
+		key = strings.ReplaceAll(key, ".", "/")
+	}
+	data, err := SysFS{Root: fsRoot}.ReadFile("/proc/sys", key)
+	if err != nil {
+		return "", err
+	}
+	return strings.Join(strings.Fields(string(data)), " "), nil
+}
+
+// currentTHP returns the active transparent hugepage mode
+func currentTHP() string {
+	data, err := Sys.ReadFile("/sys/kernel/mm/transparent_hugepage/enabled")
+	if err != nil {
+		return ""
+	}
+	current, _ := parseScheduler(string(data))
+	return current
+}
+
+// VerifyProfile checks that the selected profile was applied and is in effect
+func VerifyProfile() error {
+	p := Tuning.ActiveProfile()
+	path := NewSysctlTuner(false).ConfigPath
+	applied := AppliedProfile(path)
+	if applied == "" && FileExists(path) {
+		// Tuned by a version without profiles
+		applied = "default"
+	}
+	if applied != p.Name {
+		if applied == "" {
+			applied = "none"
+		}
+		return fmt.Errorf("profile %s selected but %s applied", p.Name, applied)
+	}
+
+	var problems []string
+	values := Tuning.SysctlValues()
+	for key := range p.Sysctl {
+		if Tuning.SysctlExcluded(key) {
+			continue
+		}
+		want := strings.Join(strings.Fields(values[key]), " ")
+		if got, err := readSysctl(key); err == nil && got != want {
+			problems = append(problems, fmt.Sprintf("%s = %s (want %s)", key, got, want))
+		}
+	}
+	if thp := currentTHP(); thp != "" && p.THP != "" && thp != p.THP {
+		problems = append(problems, fmt.Sprintf("transparent_hugepage = %s (want %s, reboot pending?)", thp, p.THP))
+	}
+	sort.Strings(problems)
+	if len(problems) > 0 {
+		return fmt.Errorf("profile %s not in effect: %s", p.Name, strings.Join(problems, ", "))
+	}
+
+	PrintSuccess("Profile %s applied and in effect", p.Name)
+	return nil
+}
+
+// ShowProfile prints the selected and applied profiles
+func ShowProfile() {
+	PrintStep("Tuning profile")
+	p := Tuning.ActiveProfile()
+	fmt.Printf("  Selected: %s - %s\n", p.Name, p.Description)
+	applied := AppliedProfile(NewSysctlTuner(false).ConfigPath)
+	if applied == "" {
+		applied = "none (not tuned yet)"
+	}
+	fmt.Printf("  Applied:  %s\n", applied)
+	fmt.Printf("  I/O scheduler: %s, transparent hugepages: %s\n", p.Scheduler, p.THP)
+	nic := Tuning.NICSettings()
+	rxUsecs, txUsecs := nic.Usecs()
+	fmt.Printf("  vmxnet3 rings: %d/%d, coalescing: rx %dus tx %dus\n", nic.RxRing, nic.TxRing, rxUsecs, txUsecs)
+	if len(p.OmitParams) > 0 {
+		fmt.Printf("  Default boot parameters left out: %s\n", strings.Join(p.OmitParams, ", "))
+	}
+	if len(p.DebloatKeep) > 0 {
+		fmt.Printf("  Kept by Server Slim: %s\n", strings.Join(p.DebloatKeep, ", "))
+	}
+	if p.Sched {
+		fmt.Printf("  Scheduler knobs: on from %d vCPUs\n", wideVMCPUs)
+	}
+}
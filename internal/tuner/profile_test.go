@@ -0,0 +1,94 @@
+package tuner
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestWithProfileParams(t *testing.T) {
+	params := []string{"elevator=noop", "transparent_hugepage=madvise", "skew_tick=1"}
+
+	got := withProfileParams(params, Profiles["low-latency"])
+	want := []string{"elevator=noop", "transparent_hugepage=never", "skew_tick=1"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("low-latency: got %v, want %v", got, want)
+	}
+
+	got = withProfileParams([]string{"elevator=noop"}, Profiles["throughput"])
+	want = []string{"elevator=noop", "transparent_hugepage=always"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("throughput: got %v, want %v", got, want)
+	}
+
+	got = withProfileParams([]string{"intel_idle.max_cstate=0", "processor.max_cstate=1", "pcie_aspm=off", "clocksource=tsc"}, Profiles["developer"])
+	want = []string{"clocksource=tsc", "transparent_hugepage=madvise", "intel_idle.max_cstate=1"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("developer: got %v, want %v", got, want)
+	}
+}
+
+func TestCStatesCheckFollowsProfile(t *testing.T) {
+	old := Tuning
+	defer func() { Tuning = old }()
+
+	Tuning = TuningConfig{}
+	if c := checkCStates("ro intel_idle.max_cstate=0 processor.max_cstate=1", cstateParams()); !c.Passed {
+		t.Errorf("default profile: %+v", c)
+	}
+	// The developer profile stops at C1: max_cstate=0 is not expected
+	Tuning = TuningConfig{Profile: "developer"}
+	want := cstateParams()
+	if !reflect.DeepEqual(want, []string{"intel_idle.max_cstate=1"}) {
+		t.Errorf("developer C-state params = %v", want)
+	}
+	if c := checkCStates("ro intel_idle.max_cstate=1", want); !c.Passed {
+		t.Errorf("developer profile applied: %+v", c)
+	}
+	if c := checkCStates("ro intel_idle.max_cstate=10", want); c.Passed || !strings.Contains(c.Fix, "intel_idle.max_cstate=1") {
+		t.Errorf("developer profile not applied: %+v", c)
+	}
+	if c := checkCStates("ro", nil); !c.Passed {
+		t.Errorf("no C-state limit selected: %+v", c)
+	}
+}
+
+func TestProfileSysctlLayering(t *testing.T) {
+	old := Tuning
+	defer func() { Tuning = old }()
+	Tuning = TuningConfig{Profile: "database", Sysctl: map[string]string{"vm.swappiness": "5"}}
+
+	values := Tuning.SysctlValues()
+	if values["vm.swappiness"] != "5" || values["vm.dirty_ratio"] != "10" {
+		t.Errorf("config file should win over the profile: %v", values)
+	}
+
+	content := NewSysctlTuner(false).GetOptimalConfig()
+	for _, line := range []string{"# Profile: database", "vm.swappiness = 5", "vm.dirty_ratio = 10", "kernel.sched_autogroup_enabled = 0"} {
+		if !strings.Contains(content, line+"\n") {
+			t.Errorf("generated sysctl file misses %q", line)
+		}
+	}
+	if strings.Contains(content, "vm.swappiness = 1\n") {
+		t.Error("profile value overridden by the config file still written")
+	}
+
+	path := filepath.Join(t.TempDir(), "99-vmware-performance.conf")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if got := AppliedProfile(path); got != "database" {
+		t.Errorf("AppliedProfile = %q, want database", got)
+	}
+}
+
+func TestLookupProfile(t *testing.T) {
+	if p, err := LookupProfile(""); err != nil || p.Name != "default" {
+		t.Errorf("empty name should select the default profile, got %v %v", p.Name, err)
+	}
+	if _, err := LookupProfile("gaming"); err == nil {
+		t.Error("unknown profile accepted")
+	}
+}
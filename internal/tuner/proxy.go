@@ -0,0 +1,275 @@
+package tuner
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// ProxyConfig is the outgoing HTTP proxy (proxy: section of the config,
+// or the HTTP_PROXY/HTTPS_PROXY/NO_PROXY environment)
+type ProxyConfig struct {
+	HTTP    string
+	HTTPS   string
+	NoProxy string
+}
+
+// decode reads the proxy: section
+func (pc *ProxyConfig) decode(fields map[string]interface{}) error {
+	var err error
+	if pc.HTTP, err = yamlString(fields["http"]); err != nil {
+		return fmt.Errorf("http: %w", err)
+	}
+	if pc.HTTPS, err = yamlString(fields["https"]); err != nil {
+		return fmt.Errorf("https: %w", err)
+	}
+	if pc.NoProxy, err = yamlString(fields["no_proxy"]); err != nil {
+		return fmt.Errorf("no_proxy: %w", err)
+	}
+	return nil
+}
+
+// proxyEnv returns the first non-empty variable (upper case wins)
+func proxyEnv(name string) string {
+	if value := os.Getenv(name); value != "" {
+		return value
+	}
+	return os.Getenv(strings.ToLower(name))
+}
+
+// Resolve completes the config with the environment; HTTPS defaults to HTTP
+func (pc ProxyConfig) Resolve() ProxyConfig {
+	if pc.HTTP == "" {
+		pc.HTTP = proxyEnv("HTTP_PROXY")
+	}
+	if pc.HTTPS == "" {
+		pc.HTTPS = proxyEnv("HTTPS_PROXY")
+	}
+	if pc.HTTPS == "" {
+		pc.HTTPS = pc.HTTP
+	}
+	if pc.NoProxy == "" {
+		pc.NoProxy = proxyEnv("NO_PROXY")
+	}
+	return pc
+}
+
+// Enabled reports whether a proxy is configured
+func (pc ProxyConfig) Enabled() bool {
+	return pc.HTTP != "" || pc.HTTPS != ""
+}
+
+// Export sets the proxy variables for this process and the commands it runs
+// (apt-get, dnf, curl), so a proxy from the config file works without
+// touching the system configuration
+func (pc ProxyConfig) Export() {
+	vars := map[string]string{
+		"HTTP_PROXY":  pc.HTTP,
+		"HTTPS_PROXY": pc.HTTPS,
+		"NO_PROXY":    pc.NoProxy,
+	}
+	for name, value := range vars {
+		if value == "" {
+			continue
+		}
+		os.Setenv(name, value)
+		os.Setenv(strings.ToLower(name), value)
+	}
+}
+
+// ProxyTuner persists the proxy in the package manager configuration
+type ProxyTuner struct {
+	Distro  *DistroManager
+	Config  ProxyConfig
+	Backup  *BackupManager
+	AptPath string
+	DnfPath string
+}
+
+// NewProxyTuner creates a new proxy tuner
+func NewProxyTuner(distro *DistroManager, cfg ProxyConfig, backup *BackupManager) *ProxyTuner {
+	dnfPath := "/etc/dnf/dnf.conf"
+	if !FileExists(dnfPath) {
+		dnfPath = "/etc/yum.conf"
+	}
+	return &ProxyTuner{
+		Distro:  distro,
+		Config:  cfg.Resolve(),
+		Backup:  backup,
+		AptPath: "/etc/apt/apt.conf.d/95vmware-tuner-proxy",
+		DnfPath: dnfPath,
+	}
+}
+
+func init() {
+	Register(Module{
+		Name:        "proxy",
+		Label:       "Configure Package Proxy",
+		Description: "Set the proxy of the config file for apt or dnf",
+		Category:    CategoryIntegration,
+		Menu:        26,
+		RequireRoot: true,
+		Distros:     packagedDistros,
+		Run: func(ctx *ModuleContext) error {
+			backup, err := ctx.BackupSession()
+			if err != nil {
+				return err
+			}
+			return NewProxyTuner(ctx.Distro, ctx.Config.Proxy, backup).Run()
+		},
+	})
+}
+
+// AptConfig returns the apt.conf.d snippet for the proxy
+func (pt *ProxyTuner) AptConfig() string {
+	var b strings.Builder
+	b.WriteString("// Package proxy - Generated by vmware-tuner\n")
+	if pt.Config.HTTP != "" {
+		fmt.Fprintf(&b, "Acquire::http::Proxy \"%s\";\n", pt.Config.HTTP)
+	}
+	if pt.Config.HTTPS != "" {
+		fmt.Fprintf(&b, "Acquire::https::Proxy \"%s\";\n", pt.Config.HTTPS)
+	}
+	// apt has no NO_PROXY: hosts are bypassed one by one
+	for _, host := range strings.Split(pt.Config.NoProxy, ",") {
+		host = strings.TrimSpace(host)
+		if host == "" || strings.ContainsAny(host, "/*") {
+			continue
+		}
+		host = strings.TrimPrefix(host, ".")
+		fmt.Fprintf(&b, "Acquire::http::Proxy::%s \"DIRECT\";\n", host)
+		fmt.Fprintf(&b, "Acquire::https::Proxy::%s \"DIRECT\";\n", host)
+	}
+	return b.String()
+}
+
+// Run writes the proxy to apt.conf.d or dnf.conf (rollback removes it)
+func (pt *ProxyTuner) Run() error {
+	PrintStep("Package Manager Proxy")
+
+	if !pt.Config.Enabled() {
+		PrintWarning("No proxy configured (proxy: section of %s or HTTP_PROXY)", DefaultConfigPath)
+		return nil
+	}
+	PrintInfo("Proxy: %s", maskURLCredentials(pt.Config.HTTP))
+
+	switch pt.Distro.Type {
+	case DistroDebian:
+		return pt.writeApt()
+	case DistroRHEL:
+		return pt.writeDnf()
+	default:
+		return ErrUnsupportedDistro
+	}
+}
+
+// writeApt writes the apt proxy snippet, readable by root only since the
+// proxy URL may carry a password
+func (pt *ProxyTuner) writeApt() error {
+	if err := pt.Backup.BackupFile(pt.AptPath); err != nil {
+		return fmt.Errorf("failed to backup %s: %w", pt.AptPath, err)
+	}
+	if err := os.WriteFile(pt.AptPath, []byte(pt.AptConfig()), 0600); err != nil {
+		return fmt.Errorf("failed to write %s: %w", pt.AptPath, err)
+	}
+	// WriteFile keeps the mode of an existing file
+	if err := os.Chmod(pt.AptPath, 0600); err != nil {
+		return fmt.Errorf("failed to restrict %s: %w", pt.AptPath, err)
+	}
+	PrintSuccess("apt proxy written to %s", pt.AptPath)
+	return nil
+}
+
+// splitProxyCredentials returns the proxy URL without its user information,
+// and the user name and password it carried
+func splitProxyCredentials(proxy string) (string, string, string) {
+	u, err := url.Parse(proxy)
+	if err != nil || u.User == nil {
+		return proxy, "", ""
+	}
+	username := u.User.Username()
+	password, _ := u.User.Password()
+	u.User = nil
+	return u.String(), username, password
+}
+
+// writeDnf sets proxy= in the [main] section of dnf.conf/yum.conf. dnf reads
+// no drop-ins: when the proxy needs credentials they go in proxy_username and
+// proxy_password and the file becomes readable by root only.
+func (pt *ProxyTuner) writeDnf() error {
+	data, err := os.ReadFile(pt.DnfPath)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", pt.DnfPath, err)
+	}
+	if err := pt.Backup.BackupFile(pt.DnfPath); err != nil {
+		return fmt.Errorf("failed to backup %s: %w", pt.DnfPath, err)
+	}
+
+	// dnf has a single proxy for all schemes
+	proxy := pt.Config.HTTPS
+	if proxy == "" {
+		proxy = pt.Config.HTTP
+	}
+	proxy, username, password := splitProxyCredentials(proxy)
+	content := setINIValue(string(data), "main", "proxy", proxy)
+	mode := os.FileMode(0644)
+	if username != "" {
+		content = setINIValue(content, "main", "proxy_username", username)
+		content = setINIValue(content, "main", "proxy_password", password)
+		mode = 0600
+	}
+	if err := os.WriteFile(pt.DnfPath, []byte(content), mode); err != nil {
+		return fmt.Errorf("failed to write %s: %w", pt.DnfPath, err)
+	}
+	// WriteFile keeps the mode of an existing file
+	if err := os.Chmod(pt.DnfPath, mode); err != nil {
+		return fmt.Errorf("failed to set the mode of %s: %w", pt.DnfPath, err)
+	}
+	PrintSuccess("dnf proxy set in %s", pt.DnfPath)
+	if mode == 0600 {
+		PrintInfo("%s is readable by root only: it holds the proxy password", pt.DnfPath)
+	}
+	return nil
+}
+
+// setINIValue sets key=value in an INI section, replacing an existing key and
+// creating the section when missing
+func setINIValue(content, section, key, value string) string {
+	lines := strings.Split(strings.TrimRight(content, "\n"), "\n")
+	header := "[" + section + "]"
+
+	inSection := false
+	insertAt := -1
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "[") {
+			if inSection {
+				break
+			}
+			inSection = trimmed == header
+			if inSection {
+				insertAt = i + 1
+			}
+			continue
+		}
+		if !inSection {
+			continue
+		}
+		if name, _, ok := strings.Cut(trimmed, "="); ok && strings.TrimSpace(name) == key {
+			lines[i] = key + "=" + value
+			return strings.Join(lines, "\n") + "\n"
+		}
+		if trimmed != "" {
+			insertAt = i + 1
+		}
+	}
+
+	entry := key + "=" + value
+	if insertAt == -1 {
+		lines = append(lines, header, entry)
+	} else {
+		lines = append(lines[:insertAt], append([]string{entry}, lines[insertAt:]...)...)
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
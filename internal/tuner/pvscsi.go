@@ -0,0 +1,165 @@
+package tuner
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// PVSCSI queue options recommended by VMware for high-IOPS guests: a deeper
+// per-LUN queue and larger request rings than the driver defaults
+var pvscsiOptions = []struct {
+	Name, Value string
+}{
+	{"cmd_per_lun", "254"},
+	{"ring_pages", "32"},
+}
+
+// PVSCSITuner sets the vmw_pvscsi module options (--with-pvscsi, or the
+// throughput and database profiles)
+type PVSCSITuner struct {
+	DryRun       bool
+	Distro       *DistroManager
+	ModprobePath string
+	FSRoot       string // "" for /
+}
+
+// NewPVSCSITuner creates a new PVSCSI tuner
+func NewPVSCSITuner(dryRun bool, distro *DistroManager) *PVSCSITuner {
+	return &PVSCSITuner{
+		DryRun:       dryRun,
+		Distro:       distro,
+		ModprobePath: "/etc/modprobe.d/vmware-tuner-pvscsi.conf",
+	}
+}
+
+func init() {
+	Register(Module{
+		Name:        "pvscsi",
+		Description: "PVSCSI queue depth (reboot)",
+		Category:    CategoryTuning,
+		Pipeline:    12,
+		RequireRoot: true,
+		Distros:     packagedDistros,
+		Flag:        &ModuleFlag{Name: "with-pvscsi", Usage: "Raise the PVSCSI queue depth and ring size (modprobe.d and initramfs, reboot; on with the throughput and database profiles)"},
+		Apply: func(ctx *ModuleContext) error {
+			pvscsi := NewPVSCSITuner(ctx.DryRun, ctx.Distro)
+			if err := pvscsi.Apply(ctx.Backup); err != nil {
+				return err
+			}
+			if pvscsi.RebootPending() {
+				ctx.RebootRequired = true
+			}
+			return nil
+		},
+		Verify: func(ctx *ModuleContext) error {
+			return NewPVSCSITuner(false, ctx.Distro).Verify()
+		},
+	})
+}
+
+// Content returns the modprobe.d file
+func (pt *PVSCSITuner) Content() string {
+	var options []string
+	for _, o := range pvscsiOptions {
+		options = append(options, o.Name+"="+o.Value)
+	}
+	return fmt.Sprintf(`# Generated by vmware-tuner: PVSCSI queue depth and ring size for high-IOPS guests
+# Takes effect when the driver loads (from the initramfs for the root disk)
+options vmw_pvscsi %s
+`, strings.Join(options, " "))
+}
+
+// Loaded reports whether the vmw_pvscsi driver is in use
+func (pt *PVSCSITuner) Loaded() bool {
+	return FileExists(filepath.Join(pt.FSRoot, "/sys/module/vmw_pvscsi"))
+}
+
+// Current returns the options of the loaded driver
+func (pt *PVSCSITuner) Current() map[string]string {
+	current := make(map[string]string)
+	for _, o := range pvscsiOptions {
+		data, err := os.ReadFile(filepath.Join(pt.FSRoot, "/sys/module/vmw_pvscsi/parameters", o.Name))
+		if err == nil {
+			current[o.Name] = strings.TrimSpace(string(data))
+		}
+	}
+	return current
+}
+
+// pending returns the options the loaded driver does not use yet
+func (pt *PVSCSITuner) pending() []string {
+	current := pt.Current()
+	var pending []string
+	for _, o := range pvscsiOptions {
+		if value, ok := current[o.Name]; ok && value != o.Value {
+			pending = append(pending, fmt.Sprintf("%s=%s (now %s)", o.Name, o.Value, value))
+		}
+	}
+	return pending
+}
+
+// RebootPending reports whether the options are configured but the loaded
+// driver does not use them yet
+func (pt *PVSCSITuner) RebootPending() bool {
+	return pt.Loaded() && FileExists(pt.ModprobePath) && len(pt.pending()) > 0
+}
+
+// Apply writes the module options and rebuilds the initramfs
+func (pt *PVSCSITuner) Apply(backup *BackupManager) error {
+	PrintStep("PVSCSI Queue Depth")
+
+	if !pt.Loaded() {
+		PrintInfo("No PVSCSI controller in use (vmw_pvscsi not loaded), nothing to do")
+		return nil
+	}
+
+	content := pt.Content()
+	if current, err := os.ReadFile(pt.ModprobePath); err == nil && string(current) == content {
+		PrintSuccess("%s already configured", pt.ModprobePath)
+		return nil
+	}
+
+	if pt.DryRun {
+		PrintInfo("Would create: %s", pt.ModprobePath)
+		fmt.Print(content)
+		PrintInfo("Would regenerate the initramfs")
+		return nil
+	}
+
+	if err := backup.BackupFile(pt.ModprobePath); err != nil {
+		return fmt.Errorf("failed to backup %s: %w", pt.ModprobePath, err)
+	}
+	if err := os.MkdirAll(filepath.Dir(pt.ModprobePath), 0755); err != nil {
+		return fmt.Errorf("failed to create %s: %w", filepath.Dir(pt.ModprobePath), err)
+	}
+	ExplainEdit("deepen the PVSCSI queues (cmd_per_lun) and request rings (ring_pages) for high IOPS", pt.ModprobePath)
+	if err := os.WriteFile(pt.ModprobePath, []byte(content), 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", pt.ModprobePath, err)
+	}
+	PrintSuccess("Created %s", pt.ModprobePath)
+
+	PrintInfo("Regenerating the initramfs (the root disk driver loads from it)...")
+	if err := pt.Distro.UpdateInitramfs(); err != nil {
+		return err
+	}
+	PrintSuccess("Initramfs updated")
+	PrintWarning("REBOOT REQUIRED for the PVSCSI options to take effect")
+	return nil
+}
+
+// Verify checks that the loaded driver uses the options, once they are configured
+func (pt *PVSCSITuner) Verify() error {
+	if !pt.Loaded() {
+		return fmt.Errorf("%w: vmw_pvscsi is not loaded", ErrVerifySkipped)
+	}
+	if !FileExists(pt.ModprobePath) {
+		return fmt.Errorf("%w: PVSCSI options not configured (--with-pvscsi)", ErrVerifySkipped)
+	}
+	if pending := pt.pending(); len(pending) > 0 {
+		return fmt.Errorf("vmw_pvscsi does not use %s: reboot, or check that the initramfs was regenerated", strings.Join(pending, ", "))
+	}
+	PrintSuccess("vmw_pvscsi uses the configured queue depth and ring size")
+	return nil
+}
@@ -0,0 +1,62 @@
+package tuner
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestPVSCSIVerify(t *testing.T) {
+	root := t.TempDir()
+	pt := &PVSCSITuner{FSRoot: root, ModprobePath: filepath.Join(root, "etc/modprobe.d/vmware-tuner-pvscsi.conf")}
+
+	if err := pt.Verify(); !errors.Is(err, ErrVerifySkipped) {
+		t.Errorf("driver not loaded: expected a skipped check, got %v", err)
+	}
+
+	params := filepath.Join(root, "sys/module/vmw_pvscsi/parameters")
+	if err := os.MkdirAll(params, 0755); err != nil {
+		t.Fatal(err)
+	}
+	for name, value := range map[string]string{"cmd_per_lun": "254", "ring_pages": "8"} {
+		if err := os.WriteFile(filepath.Join(params, name), []byte(value+"\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := pt.Verify(); !errors.Is(err, ErrVerifySkipped) {
+		t.Errorf("options not configured: expected a skipped check, got %v", err)
+	}
+	if pt.RebootPending() {
+		t.Error("no reboot pending before the options are configured")
+	}
+
+	if err := os.MkdirAll(filepath.Dir(pt.ModprobePath), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(pt.ModprobePath, []byte(pt.Content()), 0644); err != nil {
+		t.Fatal(err)
+	}
+	err := pt.Verify()
+	if err == nil || errors.Is(err, ErrVerifySkipped) || !strings.Contains(err.Error(), "ring_pages=32 (now 8)") {
+		t.Errorf("expected the pending ring_pages, got %v", err)
+	}
+	if !pt.RebootPending() {
+		t.Error("a reboot should be pending")
+	}
+
+	if err := os.WriteFile(filepath.Join(params, "ring_pages"), []byte("32\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := pt.Verify(); err != nil {
+		t.Errorf("options in use: %v", err)
+	}
+}
+
+func TestPVSCSIContent(t *testing.T) {
+	content := NewPVSCSITuner(false, nil).Content()
+	if !strings.Contains(content, "\noptions vmw_pvscsi cmd_per_lun=254 ring_pages=32\n") {
+		t.Errorf("unexpected modprobe.d file:\n%s", content)
+	}
+}
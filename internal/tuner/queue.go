@@ -0,0 +1,241 @@
+package tuner
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// QueueSettings are the block queue values of the disks. Zero values keep
+// the value of the level below (config file, profile, built-in defaults).
+type QueueSettings struct {
+	ReadAheadKB int // queue/read_ahead_kb, also set on device-mapper volumes
+	NrRequests  int // queue/nr_requests, capped by the device queue depth
+	RqAffinity  int // queue/rq_affinity: 1 completes on the submitting CPU group, 2 on the submitting CPU
+}
+
+// defaultQueue is used when neither the config file nor the profile sets a value
+var defaultQueue = QueueSettings{ReadAheadKB: 256, NrRequests: 256, RqAffinity: 1}
+
+// merge returns qs with the unset values taken from below
+func (qs QueueSettings) merge(below QueueSettings) QueueSettings {
+	if qs.ReadAheadKB == 0 {
+		qs.ReadAheadKB = below.ReadAheadKB
+	}
+	if qs.NrRequests == 0 {
+		qs.NrRequests = below.NrRequests
+	}
+	if qs.RqAffinity == 0 {
+		qs.RqAffinity = below.RqAffinity
+	}
+	return qs
+}
+
+// QueueTuner sets read-ahead, queue depth and completion affinity of the disks
+type QueueTuner struct {
+	UdevRulePath string
+	DryRun       bool
+	FSRoot       string // "" for /
+}
+
+// NewQueueTuner creates a new block queue tuner
+func NewQueueTuner(dryRun bool) *QueueTuner {
+	return &QueueTuner{
+		UdevRulePath: "/etc/udev/rules.d/62-vmware-tuner-queue.rules",
+		DryRun:       dryRun,
+	}
+}
+
+// sys reads the kernel interfaces below FSRoot
+func (qt *QueueTuner) sys() SysFS {
+	return SysFS{Root: qt.FSRoot}
+}
+
+// GetUdevRules returns the udev rules applying the queue settings at boot and
+// on hot-add. Device-mapper volumes only get the read-ahead: filesystems on LVM
+// read through them, while requests are queued by the disks below.
+func (qt *QueueTuner) GetUdevRules() string {
+	qs := Tuning.QueueSettings()
+	return fmt.Sprintf(`# Block queue settings for VMware VMs
+# Generated by vmware-tuner (profile %s)
+
+# SCSI and NVMe virtual disks: read-ahead, queue depth, completion affinity
+ACTION=="add|change", SUBSYSTEM=="block", ENV{DEVTYPE}=="disk", KERNEL=="sd*|vd*|xvd*|nvme*", ATTR{queue/read_ahead_kb}="%[2]d", ATTR{queue/nr_requests}="%[3]d", ATTR{queue/rq_affinity}="%[4]d"
+
+# device-mapper (LVM, multipath): read-ahead only
+ACTION=="add|change", SUBSYSTEM=="block", KERNEL=="dm-*", ATTR{queue/read_ahead_kb}="%[2]d"
+`, Tuning.ActiveProfile().Name, qs.ReadAheadKB, qs.NrRequests, qs.RqAffinity)
+}
+
+// DeviceQueue is the runtime queue settings of a disk (-1 when not exposed)
+type DeviceQueue struct {
+	Device      string
+	Class       string // DeviceDisk, DeviceNVMe or DeviceDM
+	ReadAheadKB int
+	NrRequests  int
+	RqAffinity  int
+}
+
+// readQueueValue reads an integer queue attribute, -1 when missing
+func readQueueValue(path string) int {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return -1
+	}
+	value, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil {
+		return -1
+	}
+	return value
+}
+
+// ReadDeviceQueues reads the queue settings of every disk and device-mapper
+// volume. fsRoot allows running against a fixture tree (empty string for /).
+func ReadDeviceQueues(fsRoot string) ([]DeviceQueue, error) {
+	sys := SysFS{Root: fsRoot}
+	names, err := sys.BlockDevices()
+	if err != nil {
+		return nil, err
+	}
+
+	var devices []DeviceQueue
+	for _, name := range names {
+		if !schedulerDiskRe.MatchString(name) {
+			continue
+		}
+		queue := sys.Path("/sys/block", name, "queue")
+		if !FileExists(queue) {
+			continue
+		}
+		devices = append(devices, DeviceQueue{
+			Device:      name,
+			Class:       deviceClass(name),
+			ReadAheadKB: readQueueValue(filepath.Join(queue, "read_ahead_kb")),
+			NrRequests:  readQueueValue(filepath.Join(queue, "nr_requests")),
+			RqAffinity:  readQueueValue(filepath.Join(queue, "rq_affinity")),
+		})
+	}
+	return devices, nil
+}
+
+// Problems returns how the device differs from qs. nr_requests is not
+// compared: the kernel refuses values above the queue depth of the adapter.
+func (d DeviceQueue) Problems(qs QueueSettings) []string {
+	var problems []string
+	if d.ReadAheadKB >= 0 && d.ReadAheadKB != qs.ReadAheadKB {
+		problems = append(problems, fmt.Sprintf("%s read_ahead_kb is %d instead of %d", d.Device, d.ReadAheadKB, qs.ReadAheadKB))
+	}
+	if d.Class != DeviceDM && d.RqAffinity >= 0 && d.RqAffinity != qs.RqAffinity {
+		problems = append(problems, fmt.Sprintf("%s rq_affinity is %d instead of %d", d.Device, d.RqAffinity, qs.RqAffinity))
+	}
+	return problems
+}
+
+// Apply writes the udev rules and applies the settings to the current disks
+func (qt *QueueTuner) Apply(backup *BackupManager) error {
+	PrintStep("Configuring block queues")
+
+	qs := Tuning.QueueSettings()
+	rules := qt.GetUdevRules()
+	PrintInfo("Read-ahead %d KiB, nr_requests %d, rq_affinity %d", qs.ReadAheadKB, qs.NrRequests, qs.RqAffinity)
+
+	if qt.DryRun {
+		PrintInfo("Would create: %s", qt.UdevRulePath)
+		PrintInfo("Udev rules preview:")
+		fmt.Println(rules)
+		return nil
+	}
+
+	if err := backup.BackupFile(qt.UdevRulePath); err != nil {
+		return fmt.Errorf("failed to backup udev rules: %w", err)
+	}
+	ExplainEdit("set the read-ahead and queue settings of virtual disks at boot and on hot-add", qt.UdevRulePath)
+	if err := os.WriteFile(qt.UdevRulePath, []byte(rules), 0644); err != nil {
+		return fmt.Errorf("failed to write udev rules: %w", err)
+	}
+	PrintSuccess("Created %s", qt.UdevRulePath)
+
+	ExplainCommand("make udev use the new rules without a reboot", "udevadm", "control", "--reload-rules")
+	if output, err := exec.Command("udevadm", "control", "--reload-rules").CombinedOutput(); err != nil {
+		PrintWarning("Failed to reload udev rules: %v", err)
+		fmt.Println(string(output))
+	}
+
+	if err := qt.ApplyToCurrentDevices(); err != nil {
+		PrintWarning("Some devices get the queue settings on the next boot: %v", err)
+	}
+	return nil
+}
+
+// ApplyToCurrentDevices writes the settings to /sys/block. An nr_requests
+// above the adapter queue depth is refused by the kernel and only reported.
+func (qt *QueueTuner) ApplyToCurrentDevices() error {
+	devices, err := ReadDeviceQueues(qt.FSRoot)
+	if err != nil {
+		return err
+	}
+
+	qs := Tuning.QueueSettings()
+	failCount := 0
+	for _, dev := range devices {
+		queue := qt.sys().Path("/sys/block", dev.Device, "queue")
+
+		readAheadPath := filepath.Join(queue, "read_ahead_kb")
+		ExplainEdit(fmt.Sprintf("read ahead %d KiB for sequential reads", qs.ReadAheadKB), readAheadPath)
+		if err := os.WriteFile(readAheadPath, []byte(strconv.Itoa(qs.ReadAheadKB)), 0644); err != nil {
+			PrintWarning("Could not set read_ahead_kb for %s: %v", dev.Device, err)
+			failCount++
+			continue
+		}
+		if dev.Class == DeviceDM {
+			PrintSuccess("Configured %s (read-ahead %d KiB)", dev.Device, qs.ReadAheadKB)
+			continue
+		}
+
+		nrRequestsPath := filepath.Join(queue, "nr_requests")
+		ExplainEdit("queue more requests per disk (the hypervisor reorders them anyway)", nrRequestsPath)
+		if err := os.WriteFile(nrRequestsPath, []byte(strconv.Itoa(qs.NrRequests)), 0644); err != nil {
+			PrintWarning("Could not set nr_requests for %s (above the adapter queue depth?), kept %d", dev.Device, dev.NrRequests)
+		}
+
+		rqAffinityPath := filepath.Join(queue, "rq_affinity")
+		ExplainEdit("complete I/O on the CPU that submitted it, keeping its caches warm", rqAffinityPath)
+		if err := os.WriteFile(rqAffinityPath, []byte(strconv.Itoa(qs.RqAffinity)), 0644); err != nil {
+			PrintWarning("Could not set rq_affinity for %s: %v", dev.Device, err)
+			failCount++
+			continue
+		}
+		PrintSuccess("Configured %s (read-ahead %d KiB, nr_requests %d, rq_affinity %d)", dev.Device, qs.ReadAheadKB, qs.NrRequests, qs.RqAffinity)
+	}
+
+	if failCount > 0 {
+		return fmt.Errorf("failed to configure %d device(s)", failCount)
+	}
+	return nil
+}
+
+// Verify checks the udev rules and the runtime settings of every disk
+func (qt *QueueTuner) Verify() error {
+	if !qt.sys().Exists(qt.UdevRulePath) {
+		return fmt.Errorf("udev rules file not found: %s", qt.UdevRulePath)
+	}
+
+	devices, err := ReadDeviceQueues(qt.FSRoot)
+	if err != nil {
+		return err
+	}
+	qs := Tuning.QueueSettings()
+	var problems []string
+	for _, dev := range devices {
+		problems = append(problems, dev.Problems(qs)...)
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("queue settings not applied: %s", strings.Join(problems, ", "))
+	}
+
+	PrintSuccess("Read-ahead %d KiB and rq_affinity %d active on %d device(s)", qs.ReadAheadKB, qs.RqAffinity, len(devices))
+	return nil
+}
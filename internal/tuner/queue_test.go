@@ -0,0 +1,79 @@
+package tuner
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestQueueSettings(t *testing.T) {
+	tc := TuningConfig{Profile: "throughput"}
+	if qs := tc.QueueSettings(); qs != (QueueSettings{ReadAheadKB: 4096, NrRequests: 256, RqAffinity: 2}) {
+		t.Errorf("throughput profile: got %+v", qs)
+	}
+	tc.Queue = QueueSettings{ReadAheadKB: 512}
+	if qs := tc.QueueSettings(); qs.ReadAheadKB != 512 || qs.RqAffinity != 2 {
+		t.Errorf("the config file should win over the profile: got %+v", qs)
+	}
+	if qs := (TuningConfig{}).QueueSettings(); qs != defaultQueue {
+		t.Errorf("default profile: got %+v", qs)
+	}
+}
+
+func TestReadDeviceQueues(t *testing.T) {
+	root := t.TempDir()
+	devices := map[string][3]string{
+		"sda":     {"4096", "256", "2"},
+		"nvme0n1": {"128", "1023", "1"},
+		"dm-0":    {"256", "128", "1"},
+		"sda1":    {"128", "128", "1"},
+		"loop0":   {"128", "128", "1"},
+	}
+	for name, values := range devices {
+		dir := filepath.Join(root, "sys/block", name, "queue")
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+		for i, attr := range []string{"read_ahead_kb", "nr_requests", "rq_affinity"} {
+			if err := os.WriteFile(filepath.Join(dir, attr), []byte(values[i]+"\n"), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	got, err := ReadDeviceQueues(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 3 {
+		t.Fatalf("expected sda, nvme0n1 and dm-0, got %+v", got)
+	}
+
+	want := QueueSettings{ReadAheadKB: 4096, NrRequests: 256, RqAffinity: 2}
+	var problems []string
+	for _, d := range got {
+		problems = append(problems, d.Problems(want)...)
+	}
+	joined := strings.Join(problems, ", ")
+	if len(problems) != 3 || !strings.Contains(joined, "nvme0n1 read_ahead_kb is 128") ||
+		!strings.Contains(joined, "nvme0n1 rq_affinity is 1") || !strings.Contains(joined, "dm-0 read_ahead_kb is 256") {
+		t.Errorf("unexpected problems (nr_requests and dm rq_affinity are not compared): %v", problems)
+	}
+}
+
+func TestQueueUdevRules(t *testing.T) {
+	saved := Tuning
+	defer func() { Tuning = saved }()
+	Tuning = TuningConfig{Profile: "database", Queue: QueueSettings{NrRequests: 64}}
+
+	rules := NewQueueTuner(false).GetUdevRules()
+	for _, want := range []string{
+		`KERNEL=="sd*|vd*|xvd*|nvme*", ATTR{queue/read_ahead_kb}="1024", ATTR{queue/nr_requests}="64", ATTR{queue/rq_affinity}="1"`,
+		`KERNEL=="dm-*", ATTR{queue/read_ahead_kb}="1024"`,
+	} {
+		if !strings.Contains(rules, want) {
+			t.Errorf("rules missing %s:\n%s", want, rules)
+		}
+	}
+}
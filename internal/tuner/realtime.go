@@ -0,0 +1,167 @@
+package tuner
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// rtManagedParams are cstate/timer boot parameters left to the RT tooling
+// (tuned realtime-virtual-guest profile) when a PREEMPT_RT kernel is running
+var rtManagedParams = map[string]bool{
+	"intel_idle.max_cstate": true,
+	"processor.max_cstate":  true,
+	"clocksource":           true,
+	"tsc":                   true,
+}
+
+// IsRealtimeKernel reports whether the running kernel is a PREEMPT_RT kernel.
+// fsRoot allows running against a fixture tree (empty string for /).
+func IsRealtimeKernel(fsRoot string) bool {
+	if data, err := os.ReadFile(filepath.Join(fsRoot, "/sys/kernel/realtime")); err == nil {
+		if strings.TrimSpace(string(data)) == "1" {
+			return true
+		}
+	}
+
+	if data, err := os.ReadFile(filepath.Join(fsRoot, "/proc/version")); err == nil {
+		version := string(data)
+		if strings.Contains(version, "PREEMPT_RT") || strings.Contains(version, "PREEMPT RT") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// IsolationBootParams returns the kernel parameters isolating a CPU list (e.g. "2-3")
+func IsolationBootParams(cpuList string) []string {
+	return []string{
+		"isolcpus=" + cpuList,
+		"nohz_full=" + cpuList,
+		"rcu_nocbs=" + cpuList,
+	}
+}
+
+// RealtimeTuner handles PREEMPT_RT kernel detection and guidance
+type RealtimeTuner struct {
+	Distro *DistroManager
+	DryRun bool
+}
+
+// NewRealtimeTuner creates a new real-time kernel tuner
+func NewRealtimeTuner(dryRun bool, distro *DistroManager) *RealtimeTuner {
+	return &RealtimeTuner{
+		Distro: distro,
+		DryRun: dryRun,
+	}
+}
+
+func init() {
+	Register(Module{
+		Name:        "realtime",
+		Label:       "Real-Time Kernel Assistant",
+		Description: "Detect a PREEMPT_RT kernel and set the CPU isolation boot parameters",
+		Category:    CategoryExpert,
+		Menu:        18,
+		RequireRoot: true,
+		Distros:     packagedDistros,
+		Run: func(ctx *ModuleContext) error {
+			return NewRealtimeTuner(false, ctx.Distro).Run()
+		},
+	})
+}
+
+// Run detects the RT kernel and optionally configures CPU isolation via GRUB
+func (rt *RealtimeTuner) Run() error {
+	PrintStep("Real-Time Kernel Assistant")
+
+	if !IsRealtimeKernel(Sys.Root) {
+		PrintInfo("Running kernel is not PREEMPT_RT")
+		PrintInfo("Standard tuning applies; CPU isolation is still possible but less deterministic")
+	} else {
+		PrintSuccess("PREEMPT_RT kernel detected")
+		PrintInfo("GRUB tuning will leave C-state and clocksource parameters to the RT profile")
+		PrintInfo("Recommended: tuned profile 'realtime-virtual-guest' and Latency Sensitivity = High on the VM")
+	}
+
+	cmdline, _ := Sys.Cmdline()
+	for _, key := range []string{"isolcpus=", "nohz_full=", "rcu_nocbs="} {
+		for _, param := range strings.Fields(cmdline) {
+			if strings.HasPrefix(param, key) {
+				PrintInfo("Active: %s", param)
+			}
+		}
+	}
+
+	fmt.Println()
+	if !AskUser("Configure CPU isolation (isolcpus/nohz_full/rcu_nocbs) for pinned workloads?") {
+		return nil
+	}
+
+	return NewCPUIsolationTuner(rt.DryRun, rt.Distro).Run()
+}
+
+// ParseCPUList validates a kernel CPU list ("0-3,6") against the online vCPUs
+func ParseCPUList(list string) ([]int, error) {
+	if list == "" {
+		return nil, fmt.Errorf("empty CPU list")
+	}
+
+	online := onlineCPUCount()
+	seen := make(map[int]bool)
+	var cpus []int
+
+	for _, part := range strings.Split(list, ",") {
+		// Atoi rejects trailing garbage ("1-3x") that Sscanf would ignore
+		first, last, isRange := strings.Cut(part, "-")
+		start, err := strconv.Atoi(first)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CPU %q", part)
+		}
+		end := start
+		if isRange {
+			if end, err = strconv.Atoi(last); err != nil {
+				return nil, fmt.Errorf("invalid CPU range %q", part)
+			}
+		}
+		if start > end || start < 0 {
+			return nil, fmt.Errorf("invalid CPU range %q", part)
+		}
+		for cpu := start; cpu <= end; cpu++ {
+			if cpu == 0 {
+				return nil, fmt.Errorf("CPU 0 cannot be isolated (housekeeping CPU)")
+			}
+			if online > 0 && cpu >= online {
+				return nil, fmt.Errorf("CPU %d does not exist (%d vCPUs online)", cpu, online)
+			}
+			if !seen[cpu] {
+				seen[cpu] = true
+				cpus = append(cpus, cpu)
+			}
+		}
+	}
+
+	if online > 0 && len(cpus) >= online {
+		return nil, fmt.Errorf("at least one vCPU must remain for housekeeping")
+	}
+
+	return cpus, nil
+}
+
+// onlineCPUCount returns the number of vCPUs listed in /proc/cpuinfo
+func onlineCPUCount() int {
+	data, err := Sys.ReadFile("/proc/cpuinfo")
+	if err != nil {
+		return 0
+	}
+	count := 0
+	for _, line := range strings.Split(string(data), "\n") {
+		if strings.HasPrefix(line, "processor") {
+			count++
+		}
+	}
+	return count
+}
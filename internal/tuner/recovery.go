@@ -0,0 +1,159 @@
+package tuner
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// recoveryCardName is the recovery card of a backup session
+const recoveryCardName = "RECOVERY.txt"
+
+// grubRegenCommand is the command regenerating grub.cfg from /etc/default/grub
+func grubRegenCommand(distro *DistroManager) string {
+	if distro != nil && distro.Type == DistroRHEL {
+		return "grub2-mkconfig -o /boot/grub2/grub.cfg"
+	}
+	return "update-grub"
+}
+
+// addedParams returns the params of after that before does not have as is
+func addedParams(before, after []string) []string {
+	present := make(map[string]bool)
+	for _, param := range before {
+		present[param] = true
+	}
+	var added []string
+	for _, param := range after {
+		if !present[param] {
+			added = append(added, param)
+		}
+	}
+	return added
+}
+
+// recoveryHeader starts the recovery card of a session
+func (bm *BackupManager) recoveryHeader() string {
+	hostname, _ := os.Hostname()
+	return fmt.Sprintf(`VMWARE-TUNER RECOVERY CARD
+Host: %s   Session: %s
+Backups: %s
+
+If the VM does not boot after this session, open its console (vSphere Client:
+Launch Web Console or Remote Console) and follow the section of the change.
+`, hostname, bm.Timestamp, bm.BackupDir)
+}
+
+// grubRecoverySteps explains how to boot once without the new parameters and
+// restore /etc/default/grub. With BootLoaderSpec entries (bls), the options
+// of every entry are also removed with grubby: grub2-mkconfig does not
+// always rewrite them.
+func (bm *BackupManager) grubRecoverySteps(path string, added []string, distro *DistroManager, bls bool) string {
+	regen := grubRegenCommand(distro)
+	if bls {
+		regen += fmt.Sprintf("\n     grubby --update-kernel=ALL --remove-args=\"%s\"", strings.Join(added, " "))
+	}
+	return fmt.Sprintf(`
+== Boot parameters (%[1]s) ==
+Added: %[2]s
+
+1. Reset the VM and show the GRUB menu: hold Shift (BIOS) or press Esc (UEFI)
+   while it starts. In the VM settings, "Force BIOS/EFI setup" or a boot delay
+   helps on fast consoles.
+2. Select the default entry and press 'e'.
+3. On the line starting with 'linux', delete the added parameters above.
+4. Press Ctrl+X (or F10) to boot once; the change is not saved.
+5. Log in as root and restore the file for good:
+     cp %[3]s %[1]s
+     %[4]s
+   or: vmware-tuner rollback %[5]s --only %[1]s
+`, path, strings.Join(added, " "), bm.GetBackupPath(filepath.Base(path)), regen, bm.Timestamp)
+}
+
+// systemdBootMenuSteps boot once without the new parameters from the
+// systemd-boot menu (kernelstub entries included)
+const systemdBootMenuSteps = `1. Reset the VM and show the systemd-boot menu: hold Space while it starts.
+   In the VM settings, "Force BIOS/EFI setup" or a boot delay helps on fast
+   consoles.
+2. Select the default entry and press 'e' to edit its options.
+3. Delete the added parameters above and press Enter to boot once; the
+   change is not saved.
+`
+
+// loaderRecoverySteps explains how to boot once without the new parameters
+// and restore the systemd-boot entries (and /etc/kernel/cmdline)
+func (bm *BackupManager) loaderRecoverySteps(paths []string, added []string) string {
+	var restore strings.Builder
+	for _, path := range paths {
+		fmt.Fprintf(&restore, "     cp %s %s\n", bm.GetBackupPath(filepath.Base(path)), path)
+	}
+	return fmt.Sprintf(`
+== Boot parameters (systemd-boot) ==
+Added: %s
+
+%s4. Log in as root and restore the entries for good:
+%s   or: vmware-tuner rollback %s --only %s
+`, strings.Join(added, " "), systemdBootMenuSteps, restore.String(), bm.Timestamp, strings.Join(paths, ","))
+}
+
+// kernelstubRecoverySteps explains how to boot once without the new
+// parameters and take them out with kernelstub, which rewrites the entries
+// (editing them by hand would be undone on the next kernel update)
+func (bm *BackupManager) kernelstubRecoverySteps(added, removed []string) string {
+	undo := fmt.Sprintf("kernelstub --delete-options \"%s\"", strings.Join(added, " "))
+	if len(removed) > 0 {
+		undo += fmt.Sprintf(" --add-options \"%s\"", strings.Join(removed, " "))
+	}
+	return fmt.Sprintf(`
+== Boot parameters (kernelstub) ==
+Added: %s
+
+%s4. Log in as root and remove them for good:
+     %s
+   or: vmware-tuner rollback %s --only %s
+`, strings.Join(added, " "), systemdBootMenuSteps, undo, bm.Timestamp, kernelstubConfig)
+}
+
+// fstabRecoverySteps explains how to restore /etc/fstab from the emergency shell
+func (bm *BackupManager) fstabRecoverySteps(path string, changes []string) string {
+	return fmt.Sprintf(`
+== Mount options (%[1]s) ==
+Changed: %[2]s
+
+1. When the boot stops in emergency mode ("Give root password for
+   maintenance"), enter the root password. Without one: in the GRUB menu
+   press 'e', add 'init=/bin/bash' at the end of the 'linux' line, Ctrl+X.
+2. Make / writable:
+     mount -o remount,rw /
+3. Restore the file:
+     cp %[3]s %[1]s
+4. Reboot: 'systemctl daemon-reload && reboot', or 'sync; reboot -f' from
+   init=/bin/bash.
+`, path, strings.Join(changes, "; "), bm.GetBackupPath(filepath.Base(path)))
+}
+
+// AddRecoverySteps adds a section to the recovery card of the session and
+// prints it. Called before a boot-critical file (GRUB, systemd-boot entries,
+// kernelstub, fstab) is written.
+func (bm *BackupManager) AddRecoverySteps(section string) error {
+	cardPath := bm.GetBackupPath(recoveryCardName)
+	content := section
+	if !FileExists(cardPath) {
+		content = bm.recoveryHeader() + section
+	}
+
+	f, err := os.OpenFile(cardPath, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to write the recovery card: %w", err)
+	}
+	defer f.Close()
+	if _, err := f.WriteString(content); err != nil {
+		return fmt.Errorf("failed to write the recovery card: %w", err)
+	}
+
+	PrintInfo("Recovery card (keep it at hand until the next boot): %s", cardPath)
+	fmt.Print(section)
+	fmt.Println()
+	return nil
+}
@@ -0,0 +1,71 @@
+package tuner
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestRecoveryCard(t *testing.T) {
+	bm := &BackupManager{BackupDir: t.TempDir(), Timestamp: "20240101-120000"}
+	grub := bm.grubRecoverySteps("/etc/default/grub", addedParams([]string{"quiet", "elevator=deadline"}, []string{"quiet", "elevator=noop", "nmi_watchdog=0"}), &DistroManager{Type: DistroRHEL}, true)
+	if err := bm.AddRecoverySteps(grub); err != nil {
+		t.Fatal(err)
+	}
+	if err := bm.AddRecoverySteps(bm.fstabRecoverySteps("/etc/fstab", []string{"/ defaults,noatime"})); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(bm.GetBackupPath(recoveryCardName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	card := string(data)
+	if n := strings.Count(card, "RECOVERY CARD"); n != 1 {
+		t.Errorf("header written %d times", n)
+	}
+	for _, want := range []string{
+		"Added: elevator=noop nmi_watchdog=0\n",
+		"cp " + bm.BackupDir + "/grub /etc/default/grub",
+		"grub2-mkconfig -o /boot/grub2/grub.cfg",
+		`grubby --update-kernel=ALL --remove-args="elevator=noop nmi_watchdog=0"`,
+		"vmware-tuner rollback 20240101-120000 --only /etc/default/grub",
+		"cp " + bm.BackupDir + "/fstab /etc/fstab",
+		"mount -o remount,rw /",
+	} {
+		if !strings.Contains(card, want) {
+			t.Errorf("card lacks %q:\n%s", want, card)
+		}
+	}
+}
+
+func TestRecoveryCardSystemdBoot(t *testing.T) {
+	bm := &BackupManager{BackupDir: t.TempDir(), Timestamp: "20240101-120000"}
+	entries := []string{"/boot/efi/loader/entries/linux.conf", kernelCmdlinePath}
+	if err := bm.AddRecoverySteps(bm.loaderRecoverySteps(entries, []string{"nmi_watchdog=0"})); err != nil {
+		t.Fatal(err)
+	}
+	if err := bm.AddRecoverySteps(bm.kernelstubRecoverySteps([]string{"elevator=noop"}, []string{"elevator=cfq"})); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(bm.GetBackupPath(recoveryCardName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	card := string(data)
+	for _, want := range []string{
+		"== Boot parameters (systemd-boot) ==\nAdded: nmi_watchdog=0\n",
+		"hold Space while it starts",
+		"cp " + bm.BackupDir + "/linux.conf /boot/efi/loader/entries/linux.conf\n",
+		"cp " + bm.BackupDir + "/cmdline /etc/kernel/cmdline\n",
+		"vmware-tuner rollback 20240101-120000 --only /boot/efi/loader/entries/linux.conf,/etc/kernel/cmdline",
+		"== Boot parameters (kernelstub) ==\nAdded: elevator=noop\n",
+		`kernelstub --delete-options "elevator=noop" --add-options "elevator=cfq"`,
+		"vmware-tuner rollback 20240101-120000 --only /etc/kernelstub/configuration",
+	} {
+		if !strings.Contains(card, want) {
+			t.Errorf("card lacks %q:\n%s", want, card)
+		}
+	}
+}
@@ -0,0 +1,254 @@
+package tuner
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// redactionCategories are the kinds of identifiers that can be redacted
+var redactionCategories = []string{"hostnames", "ips", "usernames", "serials"}
+
+// RedactionConfig selects what reports, inventories and support bundles hide
+// (redaction: section of the config)
+type RedactionConfig struct {
+	Always     bool     // redact without --redact
+	Categories []string // empty: all of redactionCategories
+	Terms      []string // extra strings (customer or project names)
+}
+
+// decode reads the redaction: section
+func (rc *RedactionConfig) decode(fields map[string]interface{}) error {
+	var err error
+	if rc.Always, err = yamlBool(fields["always"]); err != nil {
+		return fmt.Errorf("always: %w", err)
+	}
+	if rc.Categories, err = yamlStringList(fields["categories"]); err != nil {
+		return fmt.Errorf("categories: %w", err)
+	}
+	for _, c := range rc.Categories {
+		known := false
+		for _, k := range redactionCategories {
+			known = known || c == k
+		}
+		if !known {
+			return fmt.Errorf("categories: unknown category %q (available: %s)", c, strings.Join(redactionCategories, ", "))
+		}
+	}
+	if rc.Terms, err = yamlStringList(fields["terms"]); err != nil {
+		return fmt.Errorf("terms: %w", err)
+	}
+	return nil
+}
+
+// redacts reports whether a category is selected
+func (rc RedactionConfig) redacts(category string) bool {
+	if len(rc.Categories) == 0 {
+		return true
+	}
+	for _, c := range rc.Categories {
+		if c == category {
+			return true
+		}
+	}
+	return false
+}
+
+// NewRedactor creates a redactor for the identifiers of this machine: its
+// host names plus hosts (vCenter, proxies), local user names and DMI serials.
+// fsRoot allows running against a fixture tree.
+func (rc RedactionConfig) NewRedactor(fsRoot string, hosts []string) *Redactor {
+	r := &Redactor{IPs: rc.redacts("ips")}
+	if rc.redacts("hostnames") {
+		if host, err := os.Hostname(); err == nil {
+			hosts = append(hosts, host, strings.SplitN(host, ".", 2)[0])
+		}
+		r.Add("hostname", hosts...)
+	}
+	if rc.redacts("usernames") {
+		r.Add("user", localUsers(fsRoot)...)
+		r.Add("user", os.Getenv("SUDO_USER"))
+	}
+	if rc.redacts("serials") {
+		r.Add("serial", dmiSerials(fsRoot)...)
+	}
+	r.Add("term", rc.Terms...)
+	return r
+}
+
+// Redactor returns the redactor of the redaction: settings, also hiding the
+// vCenter and proxy hosts
+func (c *Config) Redactor() *Redactor {
+	hosts := []string{c.VCenter.Host}
+	for _, raw := range []string{c.Proxy.HTTP, c.Proxy.HTTPS} {
+		if u, err := url.Parse(raw); err == nil && u.Hostname() != "" {
+			hosts = append(hosts, u.Hostname())
+		}
+	}
+	return c.Redaction.NewRedactor("", hosts)
+}
+
+// localUsers returns the accounts of people (UID 1000 and above) from /etc/passwd
+func localUsers(fsRoot string) []string {
+	data, err := os.ReadFile(filepath.Join(fsRoot, "/etc/passwd"))
+	if err != nil {
+		return nil
+	}
+	var users []string
+	for _, line := range strings.Split(string(data), "\n") {
+		fields := strings.Split(line, ":")
+		if len(fields) < 3 {
+			continue
+		}
+		if uid, err := strconv.Atoi(fields[2]); err == nil && uid >= 1000 && uid < 65534 {
+			users = append(users, fields[0])
+		}
+	}
+	return users
+}
+
+// dmiSerials returns the serial numbers and UUID of the VM (root only)
+func dmiSerials(fsRoot string) []string {
+	var serials []string
+	for _, name := range []string{"product_serial", "product_uuid", "board_serial", "chassis_serial"} {
+		data, err := os.ReadFile(filepath.Join(fsRoot, "/sys/class/dmi/id", name))
+		if err != nil {
+			continue
+		}
+		if s := strings.TrimSpace(string(data)); s != "" && s != "None" && s != "Not Specified" {
+			serials = append(serials, s)
+		}
+	}
+	return serials
+}
+
+// ipCandidateRe matches anything that could be an IPv4 or IPv6 address;
+// candidates are confirmed with net.ParseIP (times and MAC addresses are not IPs)
+var ipCandidateRe = regexp.MustCompile(`[0-9A-Fa-f:.]*[:.][0-9A-Fa-f:.]+`)
+
+// Redactor replaces IP addresses and identifiers with stable placeholders,
+// so the same value keeps the same placeholder across a report or bundle
+type Redactor struct {
+	IPs bool // also replace IP addresses (loopback and unspecified are kept)
+
+	kinds        map[string]string // lowercased value -> kind
+	identifiers  *regexp.Regexp
+	placeholders map[string]string
+	counts       map[string]int
+}
+
+// Add registers values to replace with "[kind-N]" placeholders. Matching is
+// case-insensitive and on whole words: a user "web" does not hide "webhook".
+func (r *Redactor) Add(kind string, values ...string) {
+	if r.kinds == nil {
+		r.kinds = make(map[string]string)
+	}
+	for _, v := range values {
+		v = strings.ToLower(strings.TrimSpace(v))
+		if v == "" || v == "localhost" {
+			continue
+		}
+		if _, ok := r.kinds[v]; !ok {
+			r.kinds[v] = kind
+		}
+	}
+
+	// Longest first: the FQDN wins over the short host name it contains
+	var all []string
+	for v := range r.kinds {
+		all = append(all, v)
+	}
+	sort.Slice(all, func(a, b int) bool {
+		if len(all[a]) != len(all[b]) {
+			return len(all[a]) > len(all[b])
+		}
+		return all[a] < all[b]
+	})
+	var alternatives []string
+	for _, v := range all {
+		alternatives = append(alternatives, wordBounded(v))
+	}
+	r.identifiers = nil
+	if len(alternatives) > 0 {
+		r.identifiers = regexp.MustCompile(`(?i)` + strings.Join(alternatives, "|"))
+	}
+}
+
+// wordBounded quotes v and anchors its word-character ends on word boundaries
+func wordBounded(v string) string {
+	isWord := func(c byte) bool {
+		return c == '_' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+	}
+	re := regexp.QuoteMeta(v)
+	if isWord(v[0]) {
+		re = `\b` + re
+	}
+	if isWord(v[len(v)-1]) {
+		re += `\b`
+	}
+	return re
+}
+
+// Redact returns s with the addresses and identifiers replaced
+func (r *Redactor) Redact(s string) string {
+	if r.IPs {
+		s = ipCandidateRe.ReplaceAllStringFunc(s, func(m string) string {
+			// Trailing punctuation ("10.0.0.1." at the end of a sentence, "fe80::1:")
+			trimmed := strings.TrimRight(m, ".:")
+			ip := net.ParseIP(trimmed)
+			if ip == nil || ip.IsLoopback() || ip.IsUnspecified() {
+				return m
+			}
+			kind := "ipv6"
+			if ip.To4() != nil {
+				kind = "ipv4"
+			}
+			return r.placeholder(kind, ip.String()) + m[len(trimmed):]
+		})
+	}
+	if r.identifiers != nil {
+		s = r.identifiers.ReplaceAllStringFunc(s, func(m string) string {
+			key := strings.ToLower(m)
+			return r.placeholder(r.kinds[key], key)
+		})
+	}
+	return s
+}
+
+// placeholder returns the stable placeholder of a value
+func (r *Redactor) placeholder(kind, value string) string {
+	if r.placeholders == nil {
+		r.placeholders = make(map[string]string)
+		r.counts = make(map[string]int)
+	}
+	key := kind + "/" + value
+	if p, ok := r.placeholders[key]; ok {
+		return p
+	}
+	r.counts[kind]++
+	p := fmt.Sprintf("[%s-%d]", kind, r.counts[kind])
+	r.placeholders[key] = p
+	return p
+}
+
+// redactFile rewrites a generated file through the redactor
+func redactFile(path string, r *Redactor) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	if err := os.WriteFile(path, []byte(r.Redact(string(data))), info.Mode()); err != nil {
+		return fmt.Errorf("failed to redact %s: %w", path, err)
+	}
+	return nil
+}
@@ -0,0 +1,89 @@
+package tuner
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRedactor(t *testing.T) {
+	r := &Redactor{IPs: true}
+	r.Add("hostname", "web01", "web01.corp.example.com")
+	r.Add("user", "jdoe")
+
+	tests := []struct{ in, want string }{
+		{"inet 10.0.0.5/24 brd 10.0.0.255", "inet [ipv4-1]/24 brd [ipv4-2]"},
+		{"gateway 10.0.0.5.", "gateway [ipv4-1]."},
+		{"inet6 fe80::250:56ff:fe01:2/64", "inet6 [ipv6-1]/64"},
+		{"inet 127.0.0.1/8, listen 0.0.0.0:22", "inet 127.0.0.1/8, listen 0.0.0.0:22"},
+		{"link/ether 00:50:56:aa:bb:cc at 12:34:56", "link/ether 00:50:56:aa:bb:cc at 12:34:56"},
+		{"WEB01.corp.example.com and web01, not webhook", "[hostname-1] and [hostname-2], not webhook"},
+		{"/home/jdoe/.bashrc by JDOE", "/home/[user-1]/.bashrc by [user-1]"},
+	}
+	for _, tt := range tests {
+		if got := r.Redact(tt.in); got != tt.want {
+			t.Errorf("Redact(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestRedactionConfig(t *testing.T) {
+	root := t.TempDir()
+	files := map[string]string{
+		"etc/passwd":                      "root:x:0:0::/root:/bin/bash\nsshd:x:110:65534::/run/sshd:/usr/sbin/nologin\nalice:x:1000:1000::/home/alice:/bin/bash\nnobody:x:65534:65534::/:/usr/sbin/nologin\n",
+		"sys/class/dmi/id/product_serial": "VMware-42 1a 2b 3c\n",
+		"sys/class/dmi/id/product_uuid":   "421A2B3C-0000-1111-2222-333344445555\n",
+		"sys/class/dmi/id/chassis_serial": "None\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	in := `{"serial": "VMware-42 1a 2b 3c", "uuid": "421a2b3c-0000-1111-2222-333344445555", "owner": "alice", "user": "root", "ip": "192.168.1.10", "vcenter": "vc01.example.com", "project": "Apollo"}`
+
+	rc := RedactionConfig{Terms: []string{"apollo"}}
+	got := rc.NewRedactor(root, []string{"vc01.example.com"}).Redact(in)
+	for _, leak := range []string{"VMware-42", "421a2b3c", "alice", "192.168", "vc01", "Apollo"} {
+		if strings.Contains(got, leak) {
+			t.Errorf("%q not redacted: %s", leak, got)
+		}
+	}
+	if !strings.Contains(got, `"user": "root"`) {
+		t.Errorf("system account redacted: %s", got)
+	}
+
+	rc = RedactionConfig{Categories: []string{"serials"}}
+	got = rc.NewRedactor(root, nil).Redact(in)
+	if strings.Contains(got, "VMware-42") || !strings.Contains(got, "192.168.1.10") || !strings.Contains(got, "alice") {
+		t.Errorf("only serials should be redacted: %s", got)
+	}
+}
+
+func TestLoadConfig_Redaction(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte("redaction:\n  always: true\n  categories: [hostnames, ips]\n  terms: [acme]\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+	rc := cfg.Redaction
+	if !rc.Always || !rc.redacts("ips") || rc.redacts("serials") || len(rc.Terms) != 1 {
+		t.Errorf("unexpected redaction config: %+v", rc)
+	}
+
+	if err := os.WriteFile(path, []byte("redaction:\n  categories: [macs]\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadConfig(path); err == nil {
+		t.Error("unknown category accepted")
+	}
+}
@@ -0,0 +1,203 @@
+package tuner
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Module categories, in menu order
+const (
+	CategoryTuning      = "Tuning"
+	CategoryMaintenance = "Maintenance"
+	CategoryDiagnostics = "Diagnostics"
+	CategoryIntegration = "Integration"
+	CategoryExpert      = "Expert"
+)
+
+// Categories lists the module categories in display order
+var Categories = []string{CategoryTuning, CategoryMaintenance, CategoryDiagnostics, CategoryIntegration, CategoryExpert}
+
+// packagedDistros are the distributions with a known package manager, boot
+// loader and initramfs tooling
+var packagedDistros = []DistroType{DistroDebian, DistroRHEL}
+
+// ModuleFlag is the root command flag enabling a pipeline module
+type ModuleFlag struct {
+	Name    string // no-grub, with-swap...
+	Usage   string
+	Negated bool // the flag skips a module that runs by default
+	Default bool
+}
+
+// Enables reports whether a flag value enables the module
+func (f ModuleFlag) Enables(value bool) bool {
+	return value != f.Negated
+}
+
+// ModuleContext is what a module runs with
+type ModuleContext struct {
+	DryRun      bool
+	Distro      *DistroManager
+	Config      *Config
+	HasInternet bool
+	Version     string
+
+	// Backup is the session of the pipeline; menu entries start their own
+	// on first use (BackupSession)
+	Backup *BackupManager
+
+	// RebootRequired is set by the modules whose changes need a reboot
+	RebootRequired bool
+}
+
+// BackupSession returns the backup session of the run, started on first use
+func (c *ModuleContext) BackupSession() (*BackupManager, error) {
+	if c.Backup != nil {
+		return c.Backup, nil
+	}
+	backup := NewBackupManager()
+	if !c.DryRun {
+		if err := backup.Initialize(); err != nil {
+			return nil, err
+		}
+	}
+	c.Backup = backup
+	return backup, nil
+}
+
+// Module describes a tuner: the menu, the run subcommands, the tuning
+// pipeline, role gating and the module list are generated from it
+type Module struct {
+	Name        string
+	Label       string // menu entry
+	Description string // one line, also the plan entry of pipeline modules
+	Category    string
+	Menu        int  // menu number (stable: people type them), 0 for none
+	Pipeline    int  // apply order in "Optimize this VM", 0 for none
+	RequireRoot bool // refused without root (menu and run subcommand)
+	ReadOnly    bool // inspection only: runs read-only, always allowed by roles
+	Ungated     bool // changes the system but is always allowed by roles (rollback)
+	Distros     []DistroType
+	Flag        *ModuleFlag // pipeline modules
+
+	// Run is the menu entry and the run subcommand; nil leaves the menu for
+	// the tuning pipeline
+	Run func(ctx *ModuleContext) error
+	// Apply is the pipeline step, with ctx.Backup set
+	Apply func(ctx *ModuleContext) error
+	// Offer asks, in an interactive run, to apply a pipeline module whose
+	// flag is off. It returns false when declined.
+	Offer func(ctx *ModuleContext) (bool, error)
+	// Show prints the current state, Verify checks that the tuning is in
+	// effect (ErrVerifySkipped when it does not apply here)
+	Show   func(ctx *ModuleContext) error
+	Verify func(ctx *ModuleContext) error
+}
+
+// registry holds the registered modules by name
+var registry = make(map[string]*Module)
+
+// Register adds a module to the registry, from the init function of its tuner
+func Register(m Module) {
+	if m.Name == "" {
+		panic("tuner: module without a name")
+	}
+	if _, ok := registry[m.Name]; ok {
+		panic("tuner: module registered twice: " + m.Name)
+	}
+	for _, other := range registry {
+		if m.Menu != 0 && other.Menu == m.Menu {
+			panic(fmt.Sprintf("tuner: menu entry %d used by %s and %s", m.Menu, other.Name, m.Name))
+		}
+		if m.Pipeline != 0 && other.Pipeline == m.Pipeline {
+			panic(fmt.Sprintf("tuner: pipeline step %d used by %s and %s", m.Pipeline, other.Name, m.Name))
+		}
+	}
+	registry[m.Name] = &m
+}
+
+// LookupModule returns a registered module
+func LookupModule(name string) (*Module, bool) {
+	m, ok := registry[name]
+	return m, ok
+}
+
+// Modules returns the registered modules, sorted by name
+func Modules() []*Module {
+	return selectModules(func(*Module) bool { return true }, func(a, b *Module) bool { return a.Name < b.Name })
+}
+
+// MenuModules returns the modules of the menu, by menu number
+func MenuModules() []*Module {
+	return selectModules(func(m *Module) bool { return m.Menu != 0 }, func(a, b *Module) bool { return a.Menu < b.Menu })
+}
+
+// PipelineModules returns the modules of the tuning pipeline, in apply order
+func PipelineModules() []*Module {
+	return selectModules(func(m *Module) bool { return m.Pipeline != 0 }, func(a, b *Module) bool { return a.Pipeline < b.Pipeline })
+}
+
+// TuningModules returns the names of the tuning pipeline modules, in apply order
+func TuningModules() []string {
+	var names []string
+	for _, m := range PipelineModules() {
+		names = append(names, m.Name)
+	}
+	return names
+}
+
+func selectModules(keep func(*Module) bool, less func(a, b *Module) bool) []*Module {
+	var modules []*Module
+	for _, m := range registry {
+		if keep(m) {
+			modules = append(modules, m)
+		}
+	}
+	sort.Slice(modules, func(i, j int) bool { return less(modules[i], modules[j]) })
+	return modules
+}
+
+// ungated reports whether roles always allow a module
+func ungated(module string) bool {
+	m, ok := registry[module]
+	return ok && (m.ReadOnly || m.Ungated)
+}
+
+// Files returns the files the module writes (from its documentation): the
+// ones its rollback restores
+func (m *Module) Files() []string {
+	return moduleDocs[m.Name].Files
+}
+
+// CheckDistro returns an error when the module does not support the distribution
+func (m *Module) CheckDistro(distro *DistroManager) error {
+	if len(m.Distros) == 0 {
+		return nil
+	}
+	if distro != nil {
+		for _, t := range m.Distros {
+			if distro.Type == t {
+				return nil
+			}
+		}
+	}
+	return fmt.Errorf("%s: %w", m.Name, ErrUnsupportedDistro)
+}
+
+// DistroNames describes the supported distributions
+func (m *Module) DistroNames() string {
+	if len(m.Distros) == 0 {
+		return "all"
+	}
+	var names []string
+	for _, t := range m.Distros {
+		switch t {
+		case DistroDebian:
+			names = append(names, "Debian/Ubuntu")
+		case DistroRHEL:
+			names = append(names, "RHEL family")
+		}
+	}
+	return strings.Join(names, ", ")
+}
@@ -0,0 +1,67 @@
+package tuner
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestRegistry(t *testing.T) {
+	want := "grub sysctl fstab io network tools debloat swap timesync trim limits pvscsi ipv6 hgfs hugepages tuned"
+	if got := strings.Join(TuningModules(), " "); got != want {
+		t.Errorf("pipeline = %s, want %s", got, want)
+	}
+	for _, m := range PipelineModules() {
+		if m.Flag == nil || m.Apply == nil || m.Description == "" {
+			t.Errorf("%s: pipeline modules need a flag, an Apply step and a description", m.Name)
+		}
+	}
+	for _, m := range MenuModules() {
+		if m.Label == "" || m.Run == nil {
+			t.Errorf("%s: menu modules need a label and a Run action", m.Name)
+		}
+	}
+	for _, m := range Modules() {
+		found := false
+		for _, c := range Categories {
+			found = found || m.Category == c
+		}
+		if !found {
+			t.Errorf("%s: unknown category %q", m.Name, m.Category)
+		}
+	}
+	for module := range moduleDocs {
+		if _, ok := LookupModule(module); !ok {
+			t.Errorf("documented module %s is not registered", module)
+		}
+	}
+
+	if ModifiesSystem("diskusage") || !ModifiesSystem("sysctl") {
+		t.Error("read-only modules must not be reported as changing the system")
+	}
+	if gate := (&RoleGate{Allowed: map[string]bool{}}); !gate.Allows("fshealth") || gate.Allows("ssh") {
+		t.Error("roles should allow only the read-only modules by default")
+	}
+}
+
+func TestModuleCheckDistro(t *testing.T) {
+	grub, _ := LookupModule("grub")
+	if err := grub.CheckDistro(&DistroManager{Type: DistroRHEL}); err != nil {
+		t.Errorf("grub on RHEL: %v", err)
+	}
+	if err := grub.CheckDistro(&DistroManager{Type: DistroUnknown}); !errors.Is(err, ErrUnsupportedDistro) {
+		t.Errorf("grub on an unknown distribution: %v, want ErrUnsupportedDistro", err)
+	}
+	sysctl, _ := LookupModule("sysctl")
+	if err := sysctl.CheckDistro(nil); err != nil {
+		t.Errorf("sysctl runs everywhere: %v", err)
+	}
+}
+
+func TestModuleFlagEnables(t *testing.T) {
+	skip := ModuleFlag{Name: "no-grub", Negated: true}
+	opt := ModuleFlag{Name: "with-swap"}
+	if !skip.Enables(false) || skip.Enables(true) || !opt.Enables(true) || opt.Enables(false) {
+		t.Error("negated flags skip the module, the others enable it")
+	}
+}
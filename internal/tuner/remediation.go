@@ -0,0 +1,247 @@
+package tuner
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Finding kinds: problems found by a diagnostic that a module can fix
+const (
+	FindingPacketDrops = "packet-drops"
+	FindingOOMKill     = "oom-kill"
+	FindingTimeDrift   = "time-drift"
+	FindingIOError     = "io-error"
+)
+
+// oomVictimRe finds the process killed by the OOM killer
+var oomVictimRe = regexp.MustCompile(`Kill(?:ed)? process \d+ \(([^)]+)\)`)
+
+// Finding is a problem reported by a diagnostic (netstats, logdoctor, fshealth)
+type Finding struct {
+	Kind   string
+	Source string // interface, log or mount point
+	Detail string // counter or log line
+}
+
+// maxFindingsPerKind caps the findings kept of each kind: a flooded journal
+// repeats the same errors, a few of them are enough for the suggestions
+const maxFindingsPerKind = 100
+
+// recordedFindings are the findings of the module being run, in order;
+// seenFindings and findingCounts index them
+var (
+	recordedFindings []Finding
+	seenFindings     = make(map[Finding]bool)
+	findingCounts    = make(map[string]int)
+)
+
+// RecordFinding records a problem for the remediation suggestions
+func RecordFinding(kind, source, detail string) {
+	f := Finding{Kind: kind, Source: source, Detail: detail}
+	if seenFindings[f] || findingCounts[kind] >= maxFindingsPerKind {
+		return
+	}
+	seenFindings[f] = true
+	findingCounts[kind]++
+	recordedFindings = append(recordedFindings, f)
+}
+
+// TakeFindings returns the recorded findings and forgets them
+func TakeFindings() []Finding {
+	findings := recordedFindings
+	recordedFindings = nil
+	seenFindings = make(map[Finding]bool)
+	findingCounts = make(map[string]int)
+	return findings
+}
+
+// RemediationRule maps a kind of finding to the module fixing it
+type RemediationRule struct {
+	Kind    string
+	Module  string // menu module id
+	Label   string // menu label, for the CLI hints
+	Reason  string
+	Context func(findings []Finding) string // what the findings say, shown with the suggestion
+	Prefill func(findings []Finding) string // adjusts the tuning before the module runs, returns what changed
+}
+
+// remediationRules are suggested in this order
+var remediationRules = []RemediationRule{
+	{
+		Kind:    FindingPacketDrops,
+		Module:  "network",
+		Label:   "Tune Network (Rings, Offloads)",
+		Reason:  "packets dropped: larger vmxnet3 ring buffers absorb the bursts",
+		Context: dropContext,
+		Prefill: prefillRings,
+	},
+	{
+		Kind:    FindingOOMKill,
+		Module:  "swap",
+		Label:   "Manage Swap",
+		Reason:  "processes killed for lack of memory: add swap (and check the VM memory reservation)",
+		Context: oomContext,
+	},
+	{
+		Kind:    FindingTimeDrift,
+		Module:  "timesync",
+		Label:   "Fix Time Sync",
+		Reason:  "the clock drifted or jumped: synchronize it with NTP or the host",
+		Context: firstDetail,
+	},
+	{
+		Kind:    FindingIOError,
+		Module:  "hardware",
+		Label:   "Check Virtual Hardware",
+		Reason:  "disk I/O errors: check the controller (PVSCSI) and the disks of the VM",
+		Context: ioContext,
+	},
+	{
+		Kind:    FindingIOError,
+		Module:  "fshealth",
+		Label:   "Filesystem Health",
+		Reason:  "disk I/O errors: check the filesystems for recorded errors",
+		Context: ioContext,
+	},
+}
+
+// Remediation is a module suggested for the findings of a diagnostic
+type Remediation struct {
+	Module   string
+	Label    string
+	Reason   string
+	Context  string
+	Findings []Finding
+	prefill  func(findings []Finding) string
+}
+
+// Remediations returns the modules fixing the findings, in rule order
+func Remediations(findings []Finding) []Remediation {
+	var result []Remediation
+	for _, rule := range remediationRules {
+		var matched []Finding
+		for _, f := range findings {
+			if f.Kind == rule.Kind {
+				matched = append(matched, f)
+			}
+		}
+		if len(matched) == 0 {
+			continue
+		}
+		r := Remediation{
+			Module:   rule.Module,
+			Label:    rule.Label,
+			Reason:   rule.Reason,
+			Findings: matched,
+			prefill:  rule.Prefill,
+		}
+		if rule.Context != nil {
+			r.Context = rule.Context(matched)
+		}
+		result = append(result, r)
+	}
+	return result
+}
+
+// Prefill prepares the module with the context of the findings (before it runs)
+func (r Remediation) Prefill() {
+	if r.prefill == nil {
+		return
+	}
+	if changed := r.prefill(r.Findings); changed != "" {
+		PrintInfo("Prefilled from the findings: %s", changed)
+	}
+}
+
+// PrintRemediations prints the modules fixing the findings (CLI commands)
+func PrintRemediations(remediations []Remediation) {
+	if len(remediations) == 0 {
+		return
+	}
+	fmt.Println()
+	PrintInfo("Modules fixing these findings (vmware-tuner menu):")
+	for _, r := range remediations {
+		fmt.Printf("    %-32s %s\n", r.Label, r.Reason)
+		if r.Context != "" {
+			fmt.Printf("    %-32s (%s)\n", "", r.Context)
+		}
+	}
+}
+
+// dropContext lists the interfaces and counters with drops
+func dropContext(findings []Finding) string {
+	counters := make(map[string][]string)
+	for _, f := range findings {
+		counters[f.Source] = append(counters[f.Source], f.Detail)
+	}
+	var parts []string
+	for iface, c := range counters {
+		parts = append(parts, iface+": "+strings.Join(c, ", "))
+	}
+	sort.Strings(parts)
+	return strings.Join(parts, "; ")
+}
+
+// prefillRings raises the rings of the direction dropping packets to the
+// vmxnet3 maximum when the config file set them lower
+func prefillRings(findings []Finding) string {
+	var changed []string
+	for _, f := range findings {
+		counter := strings.ToLower(f.Detail)
+		if strings.Contains(counter, "rx") && Tuning.RxRing != 0 && Tuning.RxRing < vmxnet3MaxRing {
+			Tuning.RxRing = vmxnet3MaxRing
+			changed = append(changed, fmt.Sprintf("rx ring %d", vmxnet3MaxRing))
+		}
+		if strings.Contains(counter, "tx") && Tuning.TxRing != 0 && Tuning.TxRing < vmxnet3MaxRing {
+			Tuning.TxRing = vmxnet3MaxRing
+			changed = append(changed, fmt.Sprintf("tx ring %d", vmxnet3MaxRing))
+		}
+	}
+	return strings.Join(changed, ", ")
+}
+
+// oomContext counts the OOM kills and names their victims
+func oomContext(findings []Finding) string {
+	seen := make(map[string]bool)
+	var victims []string
+	for _, f := range findings {
+		if m := oomVictimRe.FindStringSubmatch(f.Detail); m != nil && !seen[m[1]] {
+			seen[m[1]] = true
+			victims = append(victims, m[1])
+		}
+	}
+	context := fmt.Sprintf("%d OOM message(s)", len(findings))
+	if len(victims) > 0 {
+		context += ", killed: " + strings.Join(victims, ", ")
+	}
+	return context
+}
+
+// ioContext names the devices with I/O errors
+func ioContext(findings []Finding) string {
+	lines := make([]string, 0, len(findings))
+	for _, f := range findings {
+		lines = append(lines, f.Detail)
+	}
+	counts := CorrelateIOErrors(lines)
+	if len(counts) == 0 {
+		return fmt.Sprintf("%d I/O message(s)", len(findings))
+	}
+	var devices []string
+	for dev, n := range counts {
+		devices = append(devices, fmt.Sprintf("%s (%d)", dev, n))
+	}
+	sort.Strings(devices)
+	return "devices: " + strings.Join(devices, ", ")
+}
+
+// firstDetail shows the first message
+func firstDetail(findings []Finding) string {
+	detail := findings[0].Detail
+	if len(detail) > 100 {
+		detail = detail[:100] + "..."
+	}
+	return detail
+}
@@ -0,0 +1,93 @@
+package tuner
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestRemediations(t *testing.T) {
+	findings := []Finding{
+		{FindingIOError, "kernel", "[ 812.1] blk_update_request: I/O error, dev sdb, sector 2048"},
+		{FindingOOMKill, "kernel", "[ 90.2] Out of memory: Killed process 1234 (java) total-vm:8GB"},
+		{FindingOOMKill, "kernel", "[ 95.7] Out of memory: Killed process 1290 (java) total-vm:8GB"},
+		{FindingPacketDrops, "ens192", "rx_dropped"},
+		{FindingPacketDrops, "ens192", "rx_no_buf"},
+	}
+
+	got := Remediations(findings)
+	var modules []string
+	for _, r := range got {
+		modules = append(modules, r.Module)
+	}
+	if want := "network swap hardware fshealth"; strings.Join(modules, " ") != want {
+		t.Fatalf("modules = %v, want %s (rule order, both I/O modules)", modules, want)
+	}
+	if got[0].Context != "ens192: rx_dropped, rx_no_buf" {
+		t.Errorf("drop context = %q", got[0].Context)
+	}
+	if got[1].Context != "2 OOM message(s), killed: java" {
+		t.Errorf("OOM context = %q", got[1].Context)
+	}
+	if got[2].Context != "devices: sdb (1)" {
+		t.Errorf("I/O context = %q", got[2].Context)
+	}
+
+	if len(Remediations(nil)) != 0 {
+		t.Error("no findings should suggest nothing")
+	}
+}
+
+func TestRecordFinding(t *testing.T) {
+	TakeFindings()
+	RecordFinding(FindingTimeDrift, "journal", "chronyd: System clock wrong by 3.2 seconds")
+	RecordFinding(FindingTimeDrift, "journal", "chronyd: System clock wrong by 3.2 seconds")
+	if got := TakeFindings(); len(got) != 1 {
+		t.Errorf("findings = %v, want the duplicate dropped", got)
+	}
+	if got := TakeFindings(); len(got) != 0 {
+		t.Errorf("findings = %v, want none after Take", got)
+	}
+
+	// A flooded journal keeps a bounded number of findings per kind
+	for i := 0; i < 3*maxFindingsPerKind; i++ {
+		RecordFinding(FindingIOError, "kernel", fmt.Sprintf("[%d.0] blk_update_request: I/O error, dev sdb, sector %d", i, i))
+	}
+	RecordFinding(FindingOOMKill, "kernel", "Out of memory: Killed process 77 (postgres)")
+	got := TakeFindings()
+	if len(got) != maxFindingsPerKind+1 || got[len(got)-1].Kind != FindingOOMKill {
+		t.Errorf("%d findings kept, want %d I/O errors and the OOM kill", len(got), maxFindingsPerKind)
+	}
+}
+
+func TestLogDoctorRecordsFindings(t *testing.T) {
+	TakeFindings()
+	ld := &LogDoctorTuner{Severity: LogSeverities[0]}
+	scan := ld.newScan("kernel", nil)
+	for _, line := range []string{
+		"[ 3.1] clocksource: Marking clocksource 'tsc' as unstable because the skew is too large",
+		"[ 4.0] segfault at 0 ip 0000 sp 0000 error 4 in libc.so",
+		"[ 9.9] Out of memory: Killed process 77 (postgres)",
+	} {
+		ld.add(scan, line)
+	}
+	var kinds []string
+	for _, f := range TakeFindings() {
+		kinds = append(kinds, f.Kind)
+	}
+	if want := FindingTimeDrift + " " + FindingOOMKill; strings.Join(kinds, " ") != want {
+		t.Errorf("kinds = %v, want %s (segfaults have no remediation)", kinds, want)
+	}
+}
+
+func TestPrefillRings(t *testing.T) {
+	saved := Tuning
+	defer func() { Tuning = saved }()
+	Tuning.RxRing, Tuning.TxRing = 1024, 1024
+
+	r := Remediations([]Finding{{FindingPacketDrops, "ens192", "rx_no_buf"}})[0]
+	r.Prefill()
+	if Tuning.RxRing != vmxnet3MaxRing || Tuning.TxRing != 1024 {
+		t.Errorf("rings = %d/%d, want only rx raised to %d", Tuning.RxRing, Tuning.TxRing, vmxnet3MaxRing)
+	}
+}
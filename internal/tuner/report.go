@@ -0,0 +1,365 @@
+package tuner
+
+import (
+	"fmt"
+	"html/template"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"time"
+)
+
+// Report is the data compiled into a tuning report
+type Report struct {
+	ToolVersion string          `json:"tool_version"`
+	GeneratedAt string          `json:"generated_at"`
+	System      SystemInfo      `json:"system"`
+	Hardware    HardwareInfo    `json:"hardware"`
+	NICStats    []NICStats      `json:"nic_stats,omitempty"`
+	Audit       AuditResult     `json:"audit"`
+	Benchmark   BenchmarkResult `json:"benchmark"`
+	Changes     []ChangeSet     `json:"changes"`
+	Modules     []ModuleSummary `json:"modules"`
+}
+
+// ModuleSummary is the reference of a tuning module in a report
+type ModuleSummary struct {
+	Module   string   `json:"module"`
+	Title    string   `json:"title"`
+	Files    []string `json:"files,omitempty"`
+	Rollback string   `json:"rollback"`
+}
+
+// moduleSummaries returns the reference of the tuning modules, in English
+func moduleSummaries() []ModuleSummary {
+	var summaries []ModuleSummary
+	for _, module := range TuningModules() {
+		doc, ok := moduleDocs[module]
+		if !ok {
+			continue
+		}
+		summaries = append(summaries, ModuleSummary{Module: module, Title: doc.Title.In("en"), Files: doc.Files, Rollback: doc.Rollback.In("en")})
+	}
+	return summaries
+}
+
+// Inventory is the machine-readable description of the VM (inventory command)
+type Inventory struct {
+	ToolVersion string       `json:"tool_version"`
+	GeneratedAt string       `json:"generated_at"`
+	System      SystemInfo   `json:"system"`
+	Hardware    HardwareInfo `json:"hardware"`
+	Tuning      TuningState  `json:"tuning"`
+}
+
+// CollectInventory gathers the system and virtual hardware description
+func CollectInventory(version string) Inventory {
+	return Inventory{
+		ToolVersion: version,
+		GeneratedAt: time.Now().Format(time.RFC3339),
+		System:      CollectSystemInfo(),
+		Hardware:    CollectHardware(),
+		Tuning:      CollectTuningState(),
+	}
+}
+
+// BenchmarkResult holds the network and disk benchmark numbers of a report
+type BenchmarkResult struct {
+	Gateway      string            `json:"gateway,omitempty"`
+	Latency      string            `json:"latency,omitempty"`
+	DownloadMBps float64           `json:"download_mbps,omitempty"`
+	Note         string            `json:"note,omitempty"`
+	Disk         []DiskBenchResult `json:"disk,omitempty"`
+	DiskNote     string            `json:"disk_note,omitempty"`
+}
+
+// reportDiskBench is the short disk test of a report
+var reportDiskBench = DiskBenchOptions{Dir: "/var/tmp", FileSize: 256 << 20, Runtime: 3 * time.Second}
+
+// ChangeSet lists the files changed by one tuning session (from its backup manifest)
+type ChangeSet struct {
+	Timestamp string   `json:"timestamp"`
+	Files     []string `json:"files"`
+}
+
+// ReportTuner compiles system state into change-management evidence
+type ReportTuner struct {
+	Distro   *DistroManager
+	Version  string
+	Redactor *Redactor // nil: the report is not redacted
+}
+
+// NewReportTuner creates a new report tuner
+func NewReportTuner(distro *DistroManager, version string) *ReportTuner {
+	return &ReportTuner{
+		Distro:  distro,
+		Version: version,
+	}
+}
+
+func init() {
+	Register(Module{
+		Name:        "report",
+		Label:       "Generate Report",
+		Description: "Write the HTML tuning report to the current directory",
+		Category:    CategoryDiagnostics,
+		Menu:        20,
+		ReadOnly:    true,
+		Run: func(ctx *ModuleContext) error {
+			return NewReportTuner(ctx.Distro, ctx.Version).Generate(".", false, ctx.HasInternet, false)
+		},
+	})
+}
+
+// Collect gathers all report sections. The download and disk tests only run
+// when requested.
+func (rt *ReportTuner) Collect(withDownload, hasInternet bool) Report {
+	report := Report{
+		ToolVersion: rt.Version,
+		GeneratedAt: time.Now().Format("2006-01-02 15:04:05 MST"),
+	}
+
+	PrintInfo("Collecting system information...")
+	report.System = CollectSystemInfo()
+
+	PrintInfo("Inspecting virtual hardware...")
+	report.Hardware = CollectHardware()
+	report.NICStats = CollectVmxnet3Stats(report.Hardware.NICs)
+
+	PrintInfo("Running audit...")
+	report.Audit = NewAuditTuner(rt.Distro).Evaluate()
+
+	PrintInfo("Measuring network latency...")
+	gateway, rtt, err := MeasureGatewayLatency()
+	report.Benchmark.Gateway = gateway
+	report.Benchmark.Latency = rtt
+	if err != nil {
+		report.Benchmark.Note = fmt.Sprintf("latency test failed: %v", err)
+	}
+
+	if withDownload {
+		if hasInternet {
+			PrintInfo("Measuring download speed...")
+			if written, elapsed, err := MeasureDownload(); err == nil && elapsed > 0 {
+				report.Benchmark.DownloadMBps = float64(written) / (1 << 20) / elapsed.Seconds()
+			} else if err != nil {
+				report.Benchmark.Note = fmt.Sprintf("download test failed: %v", err)
+			}
+		} else {
+			report.Benchmark.Note = "download test skipped (offline mode)"
+		}
+
+		PrintInfo("Measuring disk performance...")
+		disk, err := RunDiskBenchmark(reportDiskBench)
+		report.Benchmark.Disk = disk
+		if err != nil {
+			report.Benchmark.DiskNote = err.Error()
+		}
+	}
+
+	report.Changes = collectChanges()
+	report.Modules = moduleSummaries()
+
+	return report
+}
+
+// collectChanges lists applied changes from the backup manifests
+func collectChanges() []ChangeSet {
+	var changes []ChangeSet
+
+	backups, err := ListBackups()
+	if err != nil {
+		return changes
+	}
+
+	for _, ts := range backups {
+		manifest, err := LoadManifest(filepath.Join(BackupRoot, ts))
+		if err != nil {
+			continue
+		}
+		set := ChangeSet{Timestamp: ts}
+		for _, entry := range manifest.Entries {
+			set.Files = append(set.Files, entry.OriginalPath)
+		}
+		changes = append(changes, set)
+	}
+
+	return changes
+}
+
+// Generate writes the HTML report (and optionally a PDF) to outputDir
+func (rt *ReportTuner) Generate(outputDir string, withDownload, hasInternet, pdf bool) error {
+	PrintStep("Tuning Report")
+
+	report := rt.Collect(withDownload, hasInternet)
+
+	if err := os.MkdirAll(outputDir, 0755); err != nil {
+		return fmt.Errorf("failed to create output directory: %w", err)
+	}
+
+	host := report.System.Hostname
+	if rt.Redactor != nil {
+		host = "redacted"
+	}
+	name := fmt.Sprintf("vmware-tuner-report-%s-%s", host, getCurrentTimestamp())
+	htmlPath := filepath.Join(outputDir, name+".html")
+
+	if err := WriteHTMLReport(report, htmlPath); err != nil {
+		return err
+	}
+	// The PDF is converted from the redacted HTML
+	if rt.Redactor != nil {
+		if err := redactFile(htmlPath, rt.Redactor); err != nil {
+			return err
+		}
+	}
+	PrintSuccess("Report written to %s", htmlPath)
+
+	if pdf {
+		pdfPath := filepath.Join(outputDir, name+".pdf")
+		if err := convertToPDF(htmlPath, pdfPath); err != nil {
+			PrintWarning("PDF not generated: %v", err)
+		} else {
+			PrintSuccess("PDF written to %s", pdfPath)
+		}
+	}
+
+	return nil
+}
+
+// WriteHTMLReport renders the report as a standalone HTML file
+func WriteHTMLReport(report Report, path string) error {
+	tmpl, err := template.New("report").Parse(reportTemplate)
+	if err != nil {
+		return fmt.Errorf("invalid report template: %w", err)
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create report: %w", err)
+	}
+	defer f.Close()
+
+	if err := tmpl.Execute(f, report); err != nil {
+		return fmt.Errorf("failed to render report: %w", err)
+	}
+	return nil
+}
+
+// convertToPDF converts the HTML report using wkhtmltopdf or a headless Chromium
+func convertToPDF(htmlPath, pdfPath string) error {
+	if _, err := exec.LookPath("wkhtmltopdf"); err == nil {
+		if out, err := exec.Command("wkhtmltopdf", "--quiet", htmlPath, pdfPath).CombinedOutput(); err != nil {
+			return fmt.Errorf("wkhtmltopdf failed: %v: %s", err, string(out))
+		}
+		return nil
+	}
+
+	for _, browser := range []string{"chromium", "chromium-browser", "google-chrome"} {
+		if _, err := exec.LookPath(browser); err == nil {
+			cmd := exec.Command(browser, "--headless", "--disable-gpu", "--no-sandbox", "--print-to-pdf="+pdfPath, "file://"+htmlPath)
+			if out, err := cmd.CombinedOutput(); err != nil {
+				return fmt.Errorf("%s failed: %v: %s", browser, err, string(out))
+			}
+			return nil
+		}
+	}
+
+	return fmt.Errorf("no PDF converter found (install wkhtmltopdf or chromium)")
+}
+
+const reportTemplate = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>VMware Tuner Report - {{.System.Hostname}}</title>
+<style>
+body { font-family: sans-serif; margin: 2em; color: #222; }
+h1 { border-bottom: 2px solid #5a3d8a; padding-bottom: .3em; }
+h2 { color: #5a3d8a; margin-top: 1.5em; }
+table { border-collapse: collapse; width: 100%; margin-bottom: 1em; }
+th, td { border: 1px solid #ccc; padding: .4em .6em; text-align: left; vertical-align: top; }
+th { background: #f0ecf6; }
+.ok { color: #1a7f37; font-weight: bold; }
+.warn { color: #9a6700; font-weight: bold; }
+.fail { color: #cf222e; font-weight: bold; }
+.meta { color: #666; }
+</style>
+</head>
+<body>
+<h1>VMware Tuner Report</h1>
+<p class="meta">Host <b>{{.System.Hostname}}</b> &middot; generated {{.GeneratedAt}} &middot; vmware-tuner {{.ToolVersion}}</p>
+
+<h2>System</h2>
+<table>
+<tr><th>OS</th><td>{{.System.OS}}</td></tr>
+<tr><th>Kernel</th><td>{{.System.Kernel}}</td></tr>
+<tr><th>vCPUs</th><td>{{.System.VCPUs}}</td></tr>
+<tr><th>Memory</th><td>{{.System.MemoryTotal}} (used {{.System.MemoryUsed}})</td></tr>
+<tr><th>IP Address</th><td>{{.System.IPAddress}}</td></tr>
+<tr><th>Environment</th><td>{{.System.Environment}}</td></tr>
+<tr><th>VMware Tools</th><td>{{if .System.ToolsRunning}}<span class="ok">Running</span>{{else}}<span class="fail">Not running</span>{{end}}</td></tr>
+</table>
+
+<h2>Virtual Hardware</h2>
+<table>
+<tr><th>Platform</th><td>{{.Hardware.DMI.Vendor}} {{.Hardware.DMI.Product}}</td></tr>
+<tr><th>Firmware</th><td>{{.Hardware.DMI.Firmware}} {{.Hardware.DMI.BIOSVersion}} ({{.Hardware.DMI.BIOSDate}}){{if .Hardware.DMI.ESXiEra}} &middot; {{.Hardware.DMI.ESXiEra}} era{{end}}</td></tr>
+{{if .Hardware.DMI.UUID}}<tr><th>UUID</th><td>{{.Hardware.DMI.UUID}}</td></tr>
+{{end}}{{range .Hardware.DMI.Hints}}<tr><th>Hint</th><td>{{.}}</td></tr>
+{{end}}{{if .Hardware.ESXi.Known}}<tr><th>ESXi host</th><td>{{.Hardware.ESXi}}</td></tr>
+{{end}}{{range .Hardware.Advisories}}<tr><th>Advisory</th><td{{if .Warning}} class="warn"{{end}}>{{.Message}}</td></tr>
+{{end}}</table>
+<table>
+<tr><th>Interface</th><th>Driver</th><th>Adapter</th></tr>
+{{range .Hardware.NICs}}<tr><td>{{.Name}}</td><td>{{.Driver}}</td><td>{{.Model}}</td></tr>
+{{else}}<tr><td colspan="3">No network interfaces detected</td></tr>
+{{end}}</table>
+<table>
+<tr><th>Storage driver</th><td>{{if .Hardware.StorageDriver}}{{.Hardware.StorageDriver}}{{else}}not detected{{end}}</td></tr>
+{{range .Hardware.Passthrough}}<tr><th>{{.Kind}}</th><td>{{.Interface}} ({{.Driver}}, PCI {{.PCIAddr}})</td></tr>
+{{end}}</table>
+
+{{range .NICStats}}
+<h2>vmxnet3 Statistics: {{.Interface}}</h2>
+<table>
+<tr><th>Metric</th><th>Total</th><th>Per queue</th><th>Meaning</th></tr>
+{{range .Metrics}}<tr><td>{{.Name}}</td><td{{if .Alert}} class="warn"{{end}}>{{.Total}}</td><td>{{range .PerQueue}}{{.}} {{end}}</td><td>{{.Explanation}}</td></tr>
+{{end}}</table>
+{{end}}
+<h2>Audit ({{.Audit.Score}}/{{.Audit.MaxScore}})</h2>
+<table>
+<tr><th>Check</th><th>Status</th><th>Points</th><th>Details</th></tr>
+{{range .Audit.Items}}<tr><td>{{.Message}}</td><td class="{{.Status}}">{{.Status}}</td><td>{{.Points}}/{{.Max}}</td><td>{{range .Details}}{{.}}<br>{{end}}</td></tr>
+{{end}}</table>
+
+<h2>Network Benchmark</h2>
+<table>
+<tr><th>Gateway</th><td>{{.Benchmark.Gateway}}</td></tr>
+<tr><th>Latency</th><td>{{.Benchmark.Latency}}</td></tr>
+{{if .Benchmark.DownloadMBps}}<tr><th>Download</th><td>{{printf "%.1f" .Benchmark.DownloadMBps}} MiB/s</td></tr>{{end}}
+{{if .Benchmark.Note}}<tr><th>Note</th><td>{{.Benchmark.Note}}</td></tr>{{end}}
+</table>
+{{if or .Benchmark.Disk .Benchmark.DiskNote}}
+<h2>Disk Benchmark</h2>
+<table>
+<tr><th>Test</th><th>Block size</th><th>MiB/s</th><th>IOPS</th><th>Avg latency (us)</th><th>Max latency (us)</th><th>O_DIRECT</th></tr>
+{{range .Benchmark.Disk}}<tr><td>{{.Test}}</td><td>{{.BlockSize}}</td><td>{{printf "%.1f" .MBps}}</td><td>{{printf "%.0f" .IOPS}}</td><td>{{printf "%.0f" .AvgLatUs}}</td><td>{{printf "%.0f" .MaxLatUs}}</td><td>{{.Direct}}</td></tr>
+{{end}}</table>
+{{if .Benchmark.DiskNote}}<p>{{.Benchmark.DiskNote}}</p>{{end}}
+{{end}}
+
+<h2>Applied Changes</h2>
+{{range .Changes}}<h3>Session {{.Timestamp}}</h3>
+<ul>{{range .Files}}<li>{{.}}</li>{{end}}</ul>
+{{else}}<p>No tuning sessions recorded in the backup directory.</p>
+{{end}}
+
+<h2>Module Reference</h2>
+<table>
+<tr><th>Module</th><th>Files</th><th>Rollback</th></tr>
+{{range .Modules}}<tr><td>{{.Module}} ({{.Title}})</td><td>{{range .Files}}{{.}}<br>{{end}}</td><td>{{.Rollback}}</td></tr>
+{{end}}</table>
+</body>
+</html>
+`
@@ -0,0 +1,384 @@
+package tuner
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// StaleProcess is a process still mapping files deleted by a package update
+type StaleProcess struct {
+	PID     int
+	Command string
+	Unit    string // systemd service owning the process ("" outside system.slice)
+	Files   []string
+}
+
+// RestartStatus summarizes what must be restarted after updates
+type RestartStatus struct {
+	RebootRequired bool
+	RebootReason   string
+	Processes      []StaleProcess
+	Services       []string
+}
+
+// unsafeRestartUnits cannot be restarted without dropping sessions or the bus
+var unsafeRestartUnits = map[string]bool{
+	"auditd.service":          true, // RefuseManualStop
+	"dbus.service":            true,
+	"dbus-broker.service":     true,
+	"gdm.service":             true,
+	"lightdm.service":         true,
+	"sddm.service":            true,
+	"display-manager.service": true,
+}
+
+// restartPriority orders restarts: core and network services first so later
+// services start against them, remote access last so a failure elsewhere is
+// seen while the session is still up. Unlisted services use defaultRestartPriority.
+var restartPriority = map[string]int{
+	"systemd-journald.service":  0,
+	"systemd-udevd.service":     0,
+	"systemd-logind.service":    0,
+	"systemd-networkd.service":  1,
+	"systemd-resolved.service":  1,
+	"NetworkManager.service":    1,
+	"networking.service":        1,
+	"chronyd.service":           1,
+	"chrony.service":            1,
+	"systemd-timesyncd.service": 1,
+	"rsyslog.service":           2,
+	"syslog-ng.service":         2,
+	"vmtoolsd.service":          4,
+	"open-vm-tools.service":     4,
+	"ssh.service":               5,
+	"sshd.service":              5,
+}
+
+const defaultRestartPriority = 3
+
+// OrderRestarts sorts services by restart priority, then by name
+func OrderRestarts(services []string) []string {
+	ordered := append([]string(nil), services...)
+	priority := func(svc string) int {
+		if p, ok := restartPriority[svc]; ok {
+			return p
+		}
+		return defaultRestartPriority
+	}
+	sort.SliceStable(ordered, func(i, j int) bool {
+		pi, pj := priority(ordered[i]), priority(ordered[j])
+		if pi != pj {
+			return pi < pj
+		}
+		return ordered[i] < ordered[j]
+	})
+	return ordered
+}
+
+// RestartTuner finds services running outdated libraries (native needs-restarting)
+type RestartTuner struct {
+	ProcRoot string
+}
+
+// NewRestartTuner creates a new restart tuner
+func NewRestartTuner() *RestartTuner {
+	return &RestartTuner{
+		ProcRoot: Sys.Path("/proc"),
+	}
+}
+
+func init() {
+	Register(Module{
+		Name:        "restart",
+		Label:       "Services to Restart",
+		Description: "Restart the services still using deleted libraries",
+		Category:    CategoryMaintenance,
+		Menu:        25,
+		RequireRoot: true,
+		Run: func(ctx *ModuleContext) error {
+			return NewRestartTuner().Run()
+		},
+	})
+}
+
+// ScanDeletedLibraries lists the processes mapping deleted system files
+// (libraries or executables replaced by an update), from <procRoot>/*/maps
+func ScanDeletedLibraries(procRoot string) []StaleProcess {
+	entries, err := os.ReadDir(procRoot)
+	if err != nil {
+		return nil
+	}
+
+	var procs []StaleProcess
+	for _, entry := range entries {
+		pid, err := strconv.Atoi(entry.Name())
+		if err != nil {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(procRoot, entry.Name(), "maps"))
+		if err != nil {
+			continue // process gone or not readable
+		}
+
+		files := deletedSystemFiles(string(data))
+		if len(files) == 0 {
+			continue
+		}
+
+		comm, _ := os.ReadFile(filepath.Join(procRoot, entry.Name(), "comm"))
+		cgroup, _ := os.ReadFile(filepath.Join(procRoot, entry.Name(), "cgroup"))
+		procs = append(procs, StaleProcess{
+			PID:     pid,
+			Command: strings.TrimSpace(string(comm)),
+			Unit:    serviceFromCgroup(string(cgroup)),
+			Files:   files,
+		})
+	}
+
+	sort.Slice(procs, func(i, j int) bool { return procs[i].PID < procs[j].PID })
+	return procs
+}
+
+// deletedSystemFiles extracts the deleted files under system directories from a maps file
+func deletedSystemFiles(maps string) []string {
+	seen := make(map[string]bool)
+	var files []string
+	for _, line := range strings.Split(maps, "\n") {
+		if !strings.HasSuffix(line, " (deleted)") {
+			continue
+		}
+		idx := strings.Index(line, " /")
+		if idx == -1 {
+			continue
+		}
+		path := strings.TrimSuffix(strings.TrimSpace(line[idx:]), " (deleted)")
+		if !isSystemPath(path) || seen[path] {
+			continue
+		}
+		seen[path] = true
+		files = append(files, path)
+	}
+	return files
+}
+
+// isSystemPath reports whether path belongs to installed packages
+func isSystemPath(path string) bool {
+	for _, prefix := range []string{"/usr/", "/lib/", "/lib64/", "/bin/", "/sbin/"} {
+		if strings.HasPrefix(path, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// serviceFromCgroup returns the system service of a /proc/<pid>/cgroup content,
+// e.g. "0::/system.slice/sshd.service" -> "sshd.service"
+func serviceFromCgroup(cgroup string) string {
+	for _, line := range strings.Split(cgroup, "\n") {
+		idx := strings.Index(line, "/system.slice/")
+		if idx == -1 {
+			continue
+		}
+		unit := strings.SplitN(line[idx+len("/system.slice/"):], "/", 2)[0]
+		if strings.HasSuffix(unit, ".service") {
+			return unit
+		}
+	}
+	return ""
+}
+
+// Scan checks whether a reboot or service restarts are needed
+func (rt *RestartTuner) Scan() RestartStatus {
+	status := RestartStatus{Processes: ScanDeletedLibraries(rt.ProcRoot)}
+	status.RebootRequired, status.RebootReason = rebootRequired()
+
+	seen := make(map[string]bool)
+	for _, p := range status.Processes {
+		if p.PID == 1 && !status.RebootRequired {
+			status.RebootRequired = true
+			status.RebootReason = "systemd (PID 1) uses updated libraries"
+		}
+		if p.Unit != "" && !seen[p.Unit] {
+			seen[p.Unit] = true
+			status.Services = append(status.Services, p.Unit)
+		}
+	}
+	sort.Strings(status.Services)
+	return status
+}
+
+// needsRestarting runs needs-restarting -r (dnf-utils, RHEL family): exit
+// status 1 when the kernel or a core library was updated since boot. ok is
+// false when the tool is missing or fails. Variable so tests can stub it.
+var needsRestarting = func() (required, ok bool) {
+	if _, err := exec.LookPath("needs-restarting"); err != nil {
+		return false, false
+	}
+	err := exec.Command("needs-restarting", "-r").Run()
+	if exitErr, isExit := err.(*exec.ExitError); isExit && exitErr.ExitCode() == 1 {
+		return true, true
+	}
+	return false, err == nil
+}
+
+// newestKernel returns the newest kernel installed in /lib/modules, those
+// whose image is still there (/lib/modules/<version>/vmlinuz on the RHEL
+// family, /boot/vmlinuz-<version> on Debian)
+func newestKernel() string {
+	entries, err := Sys.ReadDir("/lib/modules")
+	if err != nil {
+		return ""
+	}
+	newest := ""
+	for _, entry := range entries {
+		version := entry.Name()
+		if !Sys.Exists("/lib/modules", version, "vmlinuz") && !Sys.Exists("/boot", "vmlinuz-"+version) {
+			continue
+		}
+		if newest == "" || compareVersions(version, newest) > 0 {
+			newest = version
+		}
+	}
+	return newest
+}
+
+// rebootRequired detects a kernel update since boot: the Debian flag file,
+// needs-restarting on the RHEL family, else the installed kernels
+func rebootRequired() (bool, string) {
+	if Sys.Exists("/var/run/reboot-required") {
+		return true, "/var/run/reboot-required is present"
+	}
+	if required, ok := needsRestarting(); ok {
+		if required {
+			return true, "needs-restarting -r reports core packages updated since boot"
+		}
+		return false, ""
+	}
+
+	// Containers have no /lib/modules at all
+	if running, err := Sys.ReadString("/proc/sys/kernel/osrelease"); err == nil && Sys.Exists("/lib/modules") {
+		if !Sys.Exists("/lib/modules", running) {
+			return true, fmt.Sprintf("the running kernel %s has been removed", running)
+		}
+		// rpm keeps the build date on /boot/vmlinuz-*: the newer kernel
+		// kept next to the running one is the sign of the update
+		if newest := newestKernel(); newest != "" && compareVersions(newest, running) > 0 {
+			return true, fmt.Sprintf("kernel %s is installed, %s is running", newest, running)
+		}
+	}
+
+	boot := bootTime()
+	if boot.IsZero() {
+		return false, ""
+	}
+	kernels := Sys.Glob("/boot/vmlinuz-*")
+	for _, kernel := range kernels {
+		if info, err := os.Stat(kernel); err == nil && info.ModTime().After(boot) {
+			return true, fmt.Sprintf("%s was installed after boot", filepath.Base(kernel))
+		}
+	}
+	return false, ""
+}
+
+// bootTime reads the boot time (btime) from /proc/stat
+func bootTime() time.Time {
+	data, err := Sys.ReadFile("/proc/stat")
+	if err != nil {
+		return time.Time{}
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		var btime int64
+		if _, err := fmt.Sscanf(line, "btime %d", &btime); err == nil {
+			return time.Unix(btime, 0)
+		}
+	}
+	return time.Time{}
+}
+
+// Run shows the reboot/restart status and offers to restart the services
+func (rt *RestartTuner) Run() error {
+	PrintStep("Services to Restart")
+
+	status := rt.Scan()
+
+	if status.RebootRequired {
+		PrintWarning("Reboot required: %s", status.RebootReason)
+	} else {
+		PrintSuccess("No kernel or core library update pending a reboot")
+	}
+
+	if len(status.Processes) == 0 {
+		PrintSuccess("No process uses outdated libraries")
+		return nil
+	}
+
+	PrintInfo("%d process(es) use deleted libraries or binaries:", len(status.Processes))
+	for _, p := range status.Processes {
+		unit := p.Unit
+		if unit == "" {
+			unit = "-"
+		}
+		fmt.Printf("    %-7d %-16s %-28s %s\n", p.PID, p.Command, unit, filepath.Base(p.Files[0]))
+	}
+
+	restartable := rt.restartable(status.Services)
+	if len(restartable) == 0 {
+		PrintInfo("No service can be restarted safely: log out/in or reboot to finish the update")
+		return nil
+	}
+
+	fmt.Println()
+	PrintInfo("Services to restart: %s", strings.Join(restartable, ", "))
+	fmt.Print("Restart them now? (y/n): ")
+	var resp string
+	fmt.Scanln(&resp)
+	if resp != "y" && resp != "yes" {
+		PrintInfo("Cancelled")
+		return nil
+	}
+
+	return rt.RestartServices(OrderRestarts(restartable))
+}
+
+// restartable filters out the services that cannot be restarted safely
+func (rt *RestartTuner) restartable(services []string) []string {
+	var result []string
+	for _, svc := range services {
+		if SkipExcluded("restart", ExcludeService, svc) {
+			continue
+		}
+		if unsafeRestartUnits[svc] {
+			PrintWarning("%s cannot be restarted safely (reboot to reload it)", svc)
+			continue
+		}
+		result = append(result, svc)
+	}
+	return result
+}
+
+// RestartServices restarts the services in the given order, recording each
+// restart in the action log, and reports failures
+func (rt *RestartTuner) RestartServices(services []string) error {
+	var failed []string
+	for _, svc := range services {
+		if out, err := exec.Command("systemctl", "restart", svc).CombinedOutput(); err != nil {
+			PrintError("Failed to restart %s: %s", svc, strings.TrimSpace(string(out)))
+			LogAction("restart", "restart-service", ResultFailed, "unit="+svc)
+			failed = append(failed, svc)
+			continue
+		}
+		PrintSuccess("Restarted %s", svc)
+		LogAction("restart", "restart-service", ResultSuccess, "unit="+svc)
+	}
+
+	if len(failed) > 0 {
+		return fmt.Errorf("failed to restart: %s", strings.Join(failed, ", "))
+	}
+	return nil
+}
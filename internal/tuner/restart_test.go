@@ -0,0 +1,103 @@
+package tuner
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestScanDeletedLibraries(t *testing.T) {
+	proc := t.TempDir()
+	write := func(pid, name, content string) {
+		dir := filepath.Join(proc, pid)
+		os.MkdirAll(dir, 0755)
+		os.WriteFile(filepath.Join(dir, name), []byte(content), 0644)
+	}
+
+	write("812", "maps", `55d0c000-55d0d000 r-xp 00000000 fd:00 1234   /usr/sbin/sshd
+7f1a0000-7f1b0000 r-xp 00000000 fd:00 5678   /usr/lib/x86_64-linux-gnu/libssl.so.3 (deleted)
+7f1b0000-7f1c0000 r--p 00010000 fd:00 5678   /usr/lib/x86_64-linux-gnu/libssl.so.3 (deleted)
+7f1c0000-7f1d0000 rw-s 00000000 00:01 42     /memfd:shm (deleted)
+`)
+	write("812", "comm", "sshd\n")
+	write("812", "cgroup", "0::/system.slice/ssh.service\n")
+
+	write("900", "maps", "7f1a0000-7f1b0000 r-xp 00000000 fd:00 1 /usr/lib/libc.so.6\n")
+	write("900", "comm", "bash\n")
+
+	write("self", "maps", "7f1a0000-7f1b0000 r-xp 00000000 fd:00 1 /usr/lib/libc.so.6 (deleted)\n")
+
+	procs := ScanDeletedLibraries(proc)
+	if len(procs) != 1 {
+		t.Fatalf("expected 1 stale process, got %+v", procs)
+	}
+	p := procs[0]
+	if p.PID != 812 || p.Command != "sshd" || p.Unit != "ssh.service" {
+		t.Errorf("unexpected process: %+v", p)
+	}
+	if len(p.Files) != 1 || p.Files[0] != "/usr/lib/x86_64-linux-gnu/libssl.so.3" {
+		t.Errorf("unexpected files: %v", p.Files)
+	}
+
+	if unit := serviceFromCgroup("0::/user.slice/user-1000.slice/session-3.scope\n"); unit != "" {
+		t.Errorf("user session mapped to service %q", unit)
+	}
+}
+
+func TestOrderRestarts(t *testing.T) {
+	got := OrderRestarts([]string{"sshd.service", "nginx.service", "systemd-journald.service", "crond.service", "NetworkManager.service"})
+	want := []string{"systemd-journald.service", "NetworkManager.service", "crond.service", "nginx.service", "sshd.service"}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("OrderRestarts = %v, want %v", got, want)
+		}
+	}
+}
+
+func TestNewestKernel(t *testing.T) {
+	saved := Sys
+	defer func() { Sys = saved }()
+	Sys = SysFS{Root: t.TempDir()}
+	writeFiles(t, Sys.Root, map[string]string{
+		"/lib/modules/5.14.0-362.el9.x86_64/vmlinuz":        "",
+		"/lib/modules/5.14.0-427.13.1.el9_4.x86_64/vmlinuz": "",
+		// Modules left behind by a removed kernel
+		"/lib/modules/5.14.0-503.el9.x86_64/extra/vmw.ko": "",
+		"/lib/modules/6.1.0-9-amd64/modules.dep":          "",
+		"/boot/vmlinuz-6.1.0-9-amd64":                     "",
+	})
+	if got := newestKernel(); got != "6.1.0-9-amd64" {
+		t.Errorf("newest = %q, want 6.1.0-9-amd64", got)
+	}
+	os.Remove(filepath.Join(Sys.Root, "/boot/vmlinuz-6.1.0-9-amd64"))
+	if got := newestKernel(); got != "5.14.0-427.13.1.el9_4.x86_64" {
+		t.Errorf("newest = %q, want the 427 kernel (the 503 image is gone)", got)
+	}
+}
+
+func TestRebootRequired(t *testing.T) {
+	saved, savedNeeds := Sys, needsRestarting
+	defer func() { Sys, needsRestarting = saved, savedNeeds }()
+	needsRestarting = func() (bool, bool) { return false, false }
+	Sys = SysFS{Root: t.TempDir()}
+	writeFiles(t, Sys.Root, map[string]string{
+		"/proc/sys/kernel/osrelease":                            "5.14.0-362.el9.x86_64\n",
+		"/proc/stat":                                            "cpu  1 2 3\nbtime 4102444800\n",
+		"/lib/modules/5.14.0-362.el9.x86_64/vmlinuz":            "",
+		"/lib/modules/5.14.0-427.13.1.el9_4.x86_64/modules.dep": "",
+	})
+	if required, reason := rebootRequired(); required {
+		t.Errorf("reboot required on an up to date guest: %s", reason)
+	}
+
+	writeFiles(t, Sys.Root, map[string]string{"/lib/modules/5.14.0-427.13.1.el9_4.x86_64/vmlinuz": ""})
+	if required, reason := rebootRequired(); !required || !strings.Contains(reason, "5.14.0-427.13.1.el9_4.x86_64 is installed") {
+		t.Errorf("newer kernel: required = %v (%s)", required, reason)
+	}
+
+	writeFiles(t, Sys.Root, map[string]string{"/var/run/reboot-required": ""})
+	if required, reason := rebootRequired(); !required || !strings.Contains(reason, "reboot-required") {
+		t.Errorf("flag file: required = %v (%s)", required, reason)
+	}
+}
@@ -0,0 +1,93 @@
+package tuner
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// RoleGate restricts modules to those sanctioned by the selected role
+type RoleGate struct {
+	Role     string
+	Allowed  map[string]bool
+	Override bool
+}
+
+// NewRoleGate builds the gate for a role defined in the config.
+// An empty role name returns nil, which allows every module; a module name
+// outside the registry is an error.
+func NewRoleGate(cfg *Config, role string, override bool) (*RoleGate, error) {
+	if role == "" {
+		return nil, nil
+	}
+
+	r, ok := cfg.Roles[role]
+	if !ok {
+		available := strings.Join(cfg.RoleNames(), ", ")
+		if available == "" {
+			available = "none defined in " + cfg.Path
+		}
+		return nil, fmt.Errorf("unknown role %q (available: %s)", role, available)
+	}
+
+	gate := &RoleGate{
+		Role:     role,
+		Allowed:  make(map[string]bool),
+		Override: override,
+	}
+	// A misspelled module would quietly stay denied
+	var unknown []string
+	for _, module := range r.Modules {
+		if _, ok := LookupModule(module); !ok {
+			unknown = append(unknown, module)
+			continue
+		}
+		gate.Allowed[module] = true
+	}
+	if len(unknown) > 0 {
+		var valid []string
+		for _, m := range Modules() {
+			valid = append(valid, m.Name)
+		}
+		return nil, fmt.Errorf("role %q: unknown module(s) %s (valid: %s)", role, strings.Join(unknown, ", "), strings.Join(valid, ", "))
+	}
+	return gate, nil
+}
+
+// Allows reports whether the role sanctions the module
+func (g *RoleGate) Allows(module string) bool {
+	if g == nil || ungated(module) {
+		return true
+	}
+	// The tuning pipeline is reachable when any of its modules is sanctioned
+	if module == "tune" {
+		for _, m := range TuningModules() {
+			if g.Allowed[m] {
+				return true
+			}
+		}
+	}
+	return g.Allowed[module]
+}
+
+// Check returns an error when the module is outside the role and not overridden
+func (g *RoleGate) Check(module string) error {
+	if g.Allows(module) {
+		return nil
+	}
+	if g.Override {
+		PrintWarning("Module '%s' is outside role '%s' (allowed by --override-role)", module, g.Role)
+		return nil
+	}
+	return fmt.Errorf("module '%s' is not sanctioned for role '%s' (use --override-role to force)", module, g.Role)
+}
+
+// Modules returns the sanctioned modules, sorted
+func (g *RoleGate) Modules() []string {
+	var modules []string
+	for module := range g.Allowed {
+		modules = append(modules, module)
+	}
+	sort.Strings(modules)
+	return modules
+}
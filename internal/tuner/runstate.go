@@ -0,0 +1,150 @@
+package tuner
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// RunStatePath records the plan and progress of the tuning run in progress
+// (in StateDir). It is removed when the run completes: a file left behind
+// means the run was interrupted (crash, SSH drop, power off).
+var RunStatePath = filepath.Join(StateDir, "run-state.json")
+
+// RunState is the plan of a tuning run and how far it went
+type RunState struct {
+	Version        string   `json:"version"`
+	Started        string   `json:"started"`
+	Args           []string `json:"args"`   // command line of the run, replayed by resume
+	Backup         string   `json:"backup"` // backup session directory
+	Plan           []string `json:"plan"`   // pipeline modules, in order
+	Done           []string `json:"done"`
+	Failed         []string `json:"failed,omitempty"`
+	RebootRequired bool     `json:"reboot_required"`
+}
+
+// NewRunState starts the state of a run applying plan with a backup session
+func NewRunState(version string, args []string, backupDir string, plan []string) *RunState {
+	return &RunState{
+		Version: version,
+		Started: time.Now().Format(time.RFC3339),
+		Args:    args,
+		Backup:  backupDir,
+		Plan:    plan,
+	}
+}
+
+// LoadRunState reads the state of an interrupted run, nil when there is none
+func LoadRunState(path string) (*RunState, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	var rs RunState
+	if err := json.Unmarshal(data, &rs); err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", path, err)
+	}
+	return &rs, nil
+}
+
+// Save writes the state, replacing the file atomically so an interruption
+// during the write leaves the previous state
+func (rs *RunState) Save(path string) error {
+	data, err := json.MarshalIndent(rs, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create %s: %w", filepath.Dir(path), err)
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, append(data, '\n'), 0600); err != nil {
+		return fmt.Errorf("failed to write %s: %w", tmp, err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return nil
+}
+
+// IsDone reports whether a module of the plan has run (failed included)
+func (rs *RunState) IsDone(module string) bool {
+	return listHas(rs.Done, module)
+}
+
+// listHas reports whether a module is in a list of the state
+func listHas(list []string, module string) bool {
+	for _, m := range list {
+		if m == module {
+			return true
+		}
+	}
+	return false
+}
+
+// Pending returns the modules of the plan not run yet, in order. The module
+// running when the run was interrupted is the first of them.
+func (rs *RunState) Pending() []string {
+	var pending []string
+	for _, m := range rs.Plan {
+		if !rs.IsDone(m) {
+			pending = append(pending, m)
+		}
+	}
+	return pending
+}
+
+// Record marks a module as run. A failed module is not retried by resume:
+// the summary of the first run reported it.
+func (rs *RunState) Record(module string, err error, rebootRequired bool) {
+	if !rs.IsDone(module) {
+		rs.Done = append(rs.Done, module)
+	}
+	if err != nil {
+		rs.Failed = append(rs.Failed, module)
+	}
+	rs.RebootRequired = rs.RebootRequired || rebootRequired
+}
+
+// ClearRunState removes the state of a completed run
+func ClearRunState(path string) error {
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove %s: %w", path, err)
+	}
+	return nil
+}
+
+// OpenBackupSession reopens the backup session of an interrupted run: files
+// already saved keep their original copy, the next ones join the same
+// manifest
+func OpenBackupSession(backupDir string) (*BackupManager, error) {
+	info, err := os.Stat(backupDir)
+	if err != nil {
+		return nil, fmt.Errorf("backup session %s not found: %w", backupDir, err)
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("backup session %s is not a directory", backupDir)
+	}
+	return &BackupManager{BackupDir: backupDir, Timestamp: filepath.Base(backupDir)}, nil
+}
+
+// PrintRunState describes an interrupted run
+func PrintRunState(rs *RunState) {
+	PrintInfo("Run started %s (vmware-tuner %s)", rs.Started, rs.Version)
+	PrintInfo("Backup session: %s", rs.Backup)
+	for _, m := range rs.Plan {
+		switch {
+		case listHas(rs.Failed, m):
+			fmt.Printf("    %-10s failed\n", m)
+		case rs.IsDone(m):
+			fmt.Printf("    %-10s done\n", m)
+		default:
+			fmt.Printf("    %-10s pending\n", m)
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package tuner
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestRunStateResume(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "run-state.json")
+
+	if rs, err := LoadRunState(path); err != nil || rs != nil {
+		t.Fatalf("no state: got %+v, %v", rs, err)
+	}
+
+	session := filepath.Join(dir, "backup", "20250101-120000")
+	if err := os.MkdirAll(session, 0700); err != nil {
+		t.Fatal(err)
+	}
+	rs := NewRunState("1.0", []string{"apply-all", "--yes"}, session, []string{"grub", "sysctl", "fstab", "io"})
+	rs.Record("grub", nil, true)
+	rs.Record("sysctl", errors.New("sysctl --system failed"), false)
+	if err := rs.Save(path); err != nil {
+		t.Fatal(err)
+	}
+
+	// The run is interrupted during fstab: resume starts there
+	loaded, err := LoadRunState(path)
+	if err != nil || loaded == nil {
+		t.Fatalf("load: %+v, %v", loaded, err)
+	}
+	if got, want := loaded.Pending(), []string{"fstab", "io"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("pending = %v, want %v", got, want)
+	}
+	if !loaded.RebootRequired || !reflect.DeepEqual(loaded.Failed, []string{"sysctl"}) {
+		t.Errorf("reboot = %v, failed = %v", loaded.RebootRequired, loaded.Failed)
+	}
+	if !reflect.DeepEqual(loaded.Args, []string{"apply-all", "--yes"}) {
+		t.Errorf("args = %v", loaded.Args)
+	}
+
+	backup, err := OpenBackupSession(loaded.Backup)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if backup.BackupDir != session || backup.Timestamp != "20250101-120000" {
+		t.Errorf("session = %s (%s)", backup.BackupDir, backup.Timestamp)
+	}
+	if _, err := OpenBackupSession(filepath.Join(dir, "backup", "gone")); err == nil {
+		t.Error("missing backup session accepted")
+	}
+
+	loaded.Record("fstab", nil, false)
+	loaded.Record("io", nil, false)
+	if len(loaded.Pending()) != 0 {
+		t.Errorf("pending after the last module = %v", loaded.Pending())
+	}
+	if err := ClearRunState(path); err != nil {
+		t.Fatal(err)
+	}
+	if FileExists(path) {
+		t.Error("state left after the run completed")
+	}
+	if err := ClearRunState(path); err != nil {
+		t.Errorf("clearing twice: %v", err)
+	}
+}
+
+func TestRunStateInvalid(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "run-state.json")
+	if err := os.WriteFile(path, []byte("{truncated"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadRunState(path); err == nil {
+		t.Error("invalid state accepted")
+	}
+}
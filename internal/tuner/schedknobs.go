@@ -0,0 +1,185 @@
+package tuner
+
+import (
+	"fmt"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// wideVMCPUs is the vCPU count from which the scheduler knobs pay off:
+// below it, there are few vCPUs to migrate between and few tasks to group
+const wideVMCPUs = 8
+
+// schedKnobs are the scheduler sysctls of the profiles with Sched set. Each
+// key is only written when the kernel has it: sched_migration_cost_ns moved
+// to debugfs in 5.13, autogroup needs CONFIG_SCHED_AUTOGROUP, and sysctl -p
+// fails on unknown keys.
+var schedKnobs = []SizedValue{
+	// A task that ran in the last 5 ms is cache-hot: the load balancer leaves
+	// it on its vCPU instead of moving it to one that may sit on another
+	// physical core, with cold caches. Default 0.5 ms.
+	{"kernel.sched_migration_cost_ns", "5000000", "keeps cache-hot tasks on their vCPU (5 ms, default 0.5 ms)"},
+	// Autogroup shares the CPU between login sessions, which is right for a
+	// desktop; on a server it gives a shell the same weight as every daemon
+	{"kernel.sched_autogroup_enabled", "0", "shares the CPU between processes, not login sessions"},
+}
+
+// SchedSysctl returns the scheduler knobs for a wide VM, limited to the keys
+// this kernel has. fsRoot allows running against a fixture tree.
+func SchedSysctl(fsRoot string, s VMSize) []SizedValue {
+	if s.CPUs < wideVMCPUs {
+		return nil
+	}
+	var values []SizedValue
+	for _, knob := range schedKnobs {
+		if FileExists(filepath.Join(fsRoot, "/proc/sys", strings.ReplaceAll(knob.Key, ".", "/"))) {
+			values = append(values, knob)
+		}
+	}
+	return values
+}
+
+// printSched lists the scheduler knobs written for this VM
+func (st *SysctlTuner) printSched(values []SizedValue) {
+	if len(values) == 0 {
+		return
+	}
+	PrintInfo("Scheduler knobs for this wide VM (%d vCPU, check with vmware-tuner schedbench):", st.Size.CPUs)
+	for _, v := range values {
+		if Tuning.SysctlExcluded(v.Key) {
+			continue
+		}
+		fmt.Printf("  %-34s %-28s %s\n", v.Key, v.Value, v.Reason)
+	}
+}
+
+// SchedBenchResult is the outcome of the scheduler benchmark
+type SchedBenchResult struct {
+	CPUs       int     `json:"cpus"`
+	RoundTrips int64   `json:"round_trips"`
+	Seconds    float64 `json:"seconds"`
+	AvgLatUs   float64 `json:"avg_latency_us"` // one wakeup, half a round trip
+	MaxLatUs   float64 `json:"max_latency_us"`
+}
+
+// RunSchedBenchmark measures the wakeup latency of the scheduler like perf
+// bench sched pipe: two threads pass a byte back and forth over blocking
+// pipes, so every message puts one to sleep and wakes the other, on the
+// same or another vCPU. Run it before and after the scheduler knobs.
+func RunSchedBenchmark(duration time.Duration) (SchedBenchResult, error) {
+	var ping, pong [2]int
+	if err := unix.Pipe(ping[:]); err != nil {
+		return SchedBenchResult{}, fmt.Errorf("failed to create pipe: %w", err)
+	}
+	defer unix.Close(ping[0])
+	defer unix.Close(ping[1])
+	if err := unix.Pipe(pong[:]); err != nil {
+		return SchedBenchResult{}, fmt.Errorf("failed to create pipe: %w", err)
+	}
+	defer unix.Close(pong[0])
+	defer unix.Close(pong[1])
+
+	// The echo thread stops on a zero byte
+	echoed := make(chan error, 1)
+	go func() {
+		runtime.LockOSThread()
+		defer runtime.UnlockOSThread()
+		buf := make([]byte, 1)
+		for {
+			if n, err := unix.Read(ping[0], buf); err != nil || n == 0 {
+				echoed <- err
+				return
+			}
+			if _, err := unix.Write(pong[1], buf); err != nil || buf[0] == 0 {
+				echoed <- err
+				return
+			}
+		}
+	}()
+
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	result := SchedBenchResult{CPUs: runtime.NumCPU()}
+	msg, reply := []byte{1}, make([]byte, 1)
+	var maxLat time.Duration
+	start := time.Now()
+	for time.Since(start) < duration {
+		tripStart := time.Now()
+		if _, err := unix.Write(ping[1], msg); err != nil {
+			return result, fmt.Errorf("benchmark failed: %w", err)
+		}
+		if _, err := unix.Read(pong[0], reply); err != nil {
+			return result, fmt.Errorf("benchmark failed: %w", err)
+		}
+		if lat := time.Since(tripStart); lat > maxLat {
+			maxLat = lat
+		}
+		result.RoundTrips++
+	}
+	elapsed := time.Since(start).Seconds()
+
+	unix.Write(ping[1], []byte{0})
+	unix.Read(pong[0], reply)
+	if err := <-echoed; err != nil {
+		return result, fmt.Errorf("benchmark failed: %w", err)
+	}
+
+	result.Seconds = elapsed
+	if result.RoundTrips > 0 {
+		result.AvgLatUs = elapsed * 1e6 / float64(result.RoundTrips) / 2
+	}
+	result.MaxLatUs = float64(maxLat.Microseconds()) / 2
+	return result, nil
+}
+
+// SchedBenchTuner runs the scheduler benchmark from the menu
+type SchedBenchTuner struct {
+	Duration time.Duration
+}
+
+// NewSchedBenchTuner creates a new scheduler benchmark tuner
+func NewSchedBenchTuner(duration time.Duration) *SchedBenchTuner {
+	return &SchedBenchTuner{Duration: duration}
+}
+
+func init() {
+	Register(Module{
+		Name:        "schedbench",
+		Label:       "Scheduler Benchmark",
+		Description: "Measure the wakeup latency of the process scheduler",
+		Category:    CategoryDiagnostics,
+		Menu:        35,
+		ReadOnly:    true,
+		Run: func(ctx *ModuleContext) error {
+			return NewSchedBenchTuner(10 * time.Second).Run()
+		},
+	})
+}
+
+// Run prints the scheduler knobs in effect and measures the wakeup latency
+func (sb *SchedBenchTuner) Run() error {
+	PrintStep("Scheduler Benchmark (pipe ping-pong)")
+
+	for _, knob := range schedKnobs {
+		value, err := readSysctl(knob.Key)
+		if err != nil {
+			value = "not available on this kernel"
+		}
+		fmt.Printf("  %-34s %s\n", knob.Key, value)
+	}
+	PrintInfo("Running for %s...", FormatDuration(sb.Duration))
+
+	r, err := RunSchedBenchmark(sb.Duration)
+	if err != nil {
+		return err
+	}
+	fmt.Println()
+	fmt.Printf("  %-14s %14s %14s %14s\n", "vCPUs", "Wakeups/s", "Avg lat(us)", "Max lat(us)")
+	fmt.Printf("  %-14d %14.0f %14.1f %14.0f\n", r.CPUs, float64(r.RoundTrips*2)/r.Seconds, r.AvgLatUs, r.MaxLatUs)
+	PrintInfo("Compare with a run before the scheduler knobs (profiles throughput and database on %d+ vCPUs)", wideVMCPUs)
+	return nil
+}
@@ -0,0 +1,46 @@
+package tuner
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestSchedSysctl(t *testing.T) {
+	root := t.TempDir()
+	dir := filepath.Join(root, "proc/sys/kernel")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "sched_autogroup_enabled"), []byte("1\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if values := SchedSysctl(root, VMSize{MemoryMB: 4096, CPUs: 4}); len(values) != 0 {
+		t.Errorf("knobs on a 4 vCPU VM: %v", values)
+	}
+
+	// 5.13 and later: sched_migration_cost_ns is in debugfs, not a sysctl
+	values := SchedSysctl(root, VMSize{MemoryMB: 65536, CPUs: 16})
+	if len(values) != 1 || values[0].Key != "kernel.sched_autogroup_enabled" || values[0].Value != "0" {
+		t.Errorf("values = %v, want only kernel.sched_autogroup_enabled = 0", values)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "sched_migration_cost_ns"), []byte("500000\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if values := SchedSysctl(root, VMSize{MemoryMB: 65536, CPUs: 16}); len(values) != 2 {
+		t.Errorf("values = %v, want both knobs", values)
+	}
+}
+
+func TestRunSchedBenchmark(t *testing.T) {
+	r, err := RunSchedBenchmark(50 * time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.RoundTrips == 0 || r.AvgLatUs <= 0 || r.CPUs == 0 {
+		t.Errorf("result = %+v, want round trips and a latency", r)
+	}
+}
@@ -5,48 +5,154 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"regexp"
 	"strings"
 )
 
 // SchedulerTuner handles I/O scheduler optimization
 type SchedulerTuner struct {
-	UdevRulePath string
-	DryRun       bool
+	UdevRulePath      string
+	HotplugRulePath   string
+	HotplugHelperPath string
+	DryRun            bool
+	FSRoot            string // "" for /
 }
 
 // NewSchedulerTuner creates a new scheduler tuner
 func NewSchedulerTuner(dryRun bool) *SchedulerTuner {
 	return &SchedulerTuner{
-		UdevRulePath: "/etc/udev/rules.d/60-scheduler.rules",
-		DryRun:       dryRun,
+		UdevRulePath:      "/etc/udev/rules.d/60-scheduler.rules",
+		HotplugRulePath:   "/etc/udev/rules.d/61-vmware-tuner-hotplug.rules",
+		HotplugHelperPath: "/usr/local/sbin/vmware-tuner-disk-hotplug",
+		DryRun:            dryRun,
 	}
 }
 
-// GetUdevRules returns the udev rules for I/O scheduler
-func (st *SchedulerTuner) GetUdevRules() string {
-	return `# I/O Scheduler optimization for VMware VMs
-# Generated by vmware-tuner
+// sys reads the kernel interfaces below FSRoot
+func (st *SchedulerTuner) sys() SysFS {
+	return SysFS{Root: st.FSRoot}
+}
 
-# Use 'none' (or 'noop' on older kernels) for all block devices
-# This is optimal for VMs as the hypervisor handles I/O scheduling
+func init() {
+	Register(Module{
+		Name:        "io",
+		Description: "I/O scheduler configuration",
+		Category:    CategoryTuning,
+		Pipeline:    4,
+		RequireRoot: true,
+		Flag:        &ModuleFlag{Name: "no-io", Usage: "Skip I/O scheduler tuning", Negated: true},
+		Apply: func(ctx *ModuleContext) error {
+			schedErr := NewSchedulerTuner(ctx.DryRun).Apply(ctx.Backup)
+			queueErr := NewQueueTuner(ctx.DryRun).Apply(ctx.Backup)
+			switch {
+			case queueErr != nil && schedErr != nil:
+				return fmt.Errorf("%v; block queues: %w", schedErr, queueErr)
+			case queueErr != nil:
+				return fmt.Errorf("block queues: %w", queueErr)
+			}
+			return schedErr
+		},
+		Show: func(ctx *ModuleContext) error {
+			return NewSchedulerTuner(false).ShowCurrent()
+		},
+		Verify: func(ctx *ModuleContext) error {
+			if err := NewSchedulerTuner(false).Verify(); err != nil {
+				return err
+			}
+			return NewQueueTuner(false).Verify()
+		},
+	})
+}
 
-# For SSDs and virtual disks (rotational=0)
-ACTION=="add|change", KERNEL=="sd[a-z]|nvme[0-9]n[0-9]", ATTR{queue/rotational}=="0", ATTR{queue/scheduler}="none"
+// schedulerChoices returns the scheduler of the profile followed by its
+// name on legacy (single queue) kernels
+func schedulerChoices(name string) []string {
+	switch name {
+	case "", "none":
+		return []string{"none", "noop"}
+	case "mq-deadline":
+		return []string{"mq-deadline", "deadline"}
+	}
+	return []string{name}
+}
+
+// Device classes the scheduler is chosen for
+const (
+	DeviceDisk = "disk" // SCSI/SATA virtual disks (PVSCSI, LSI Logic, AHCI)
+	DeviceNVMe = "nvme"
+	DeviceDM   = "dm" // device-mapper: LVM, multipath, dm-crypt
+)
 
-# For HDDs (rotational=1) - still use none in VMs
-ACTION=="add|change", KERNEL=="sd[a-z]|nvme[0-9]n[0-9]", ATTR{queue/rotational}=="1", ATTR{queue/scheduler}="none"
+// deviceClass returns the class of a block device name
+func deviceClass(name string) string {
+	switch {
+	case strings.HasPrefix(name, "nvme"):
+		return DeviceNVMe
+	case strings.HasPrefix(name, "dm-"):
+		return DeviceDM
+	}
+	return DeviceDisk
+}
 
-# VMware PVSCSI devices
-ACTION=="add|change", KERNEL=="sd[a-z]", DRIVERS=="vmw_pvscsi", ATTR{queue/scheduler}="none"
+// schedulerFor returns the blk-mq scheduler of a device class. NVMe queues
+// are deep enough and device-mapper requests are scheduled by the disks below:
+// both use none. SCSI disks use the scheduler of the profile.
+func schedulerFor(class, profile string) string {
+	if class == DeviceDisk {
+		return schedulerChoices(profile)[0]
+	}
+	return "none"
+}
 
-# Increase queue depth for better performance
-ACTION=="add|change", KERNEL=="sd[a-z]", ATTR{queue/nr_requests}="256"
-ACTION=="add|change", KERNEL=="nvme[0-9]n[0-9]", ATTR{queue/nr_requests}="256"
+// legacyScheduler returns the single queue name of a blk-mq scheduler
+func legacyScheduler(sched string) string {
+	if choices := schedulerChoices(sched); len(choices) > 1 {
+		return choices[1]
+	}
+	return sched
+}
 
-# Read-ahead optimization (in KB)
-ACTION=="add|change", KERNEL=="sd[a-z]", ATTR{bdi/read_ahead_kb}="256"
-ACTION=="add|change", KERNEL=="nvme[0-9]n[0-9]", ATTR{bdi/read_ahead_kb}="256"
-`
+// MultiQueue reports whether the disks use blk-mq (mq directory in sysfs).
+// elevator= is ignored by blk-mq kernels, the scheduler is set per device.
+// fsRoot is "" for /.
+func MultiQueue(fsRoot string) bool {
+	sys := SysFS{Root: fsRoot}
+	if len(sys.Glob("/sys/block/*/queue/scheduler")) == 0 {
+		return true
+	}
+	return len(sys.Glob("/sys/block/*/mq")) > 0
+}
+
+// GetUdevRules returns the udev rules for I/O scheduler: one rule per device
+// class, with the scheduler names of the running kernel (blk-mq or legacy)
+func (st *SchedulerTuner) GetUdevRules() string {
+	profile := Tuning.ActiveProfile().Scheduler
+	multiQueue := MultiQueue(st.FSRoot)
+	name := func(class string) string {
+		if multiQueue {
+			return schedulerFor(class, profile)
+		}
+		return legacyScheduler(schedulerFor(class, profile))
+	}
+	kernel := "blk-mq kernel: per-device mq schedulers, elevator= is ignored"
+	if !multiQueue {
+		kernel = "single queue kernel: legacy scheduler names"
+	}
+
+	return fmt.Sprintf(`# I/O Scheduler optimization for VMware VMs
+# Generated by vmware-tuner (%s)
+
+# SCSI virtual disks (PVSCSI, LSI Logic, SATA): scheduler of the profile
+ACTION=="add|change", SUBSYSTEM=="block", ENV{DEVTYPE}=="disk", KERNEL=="sd*|vd*|xvd*", ATTR{queue/scheduler}="%s"
+
+# NVMe: the controller queues are deep enough, no scheduler needed
+ACTION=="add|change", SUBSYSTEM=="block", ENV{DEVTYPE}=="disk", KERNEL=="nvme*", ATTR{queue/scheduler}="%s"
+
+# device-mapper (LVM, multipath): the disks below schedule the requests
+ACTION=="add|change", SUBSYSTEM=="block", KERNEL=="dm-*", ATTR{queue/scheduler}="%s"
+
+# Read-ahead, queue depth and rq_affinity: see the block queue rules
+`, kernel, name(DeviceDisk), name(DeviceNVMe), name(DeviceDM))
 }
 
 // Apply applies I/O scheduler optimizations
@@ -59,6 +165,8 @@ func (st *SchedulerTuner) Apply(backup *BackupManager) error {
 		PrintInfo("Would create: %s", st.UdevRulePath)
 		PrintInfo("Udev rules preview:")
 		fmt.Println(rules)
+		PrintInfo("Would create: %s and %s", st.HotplugRulePath, st.HotplugHelperPath)
+		fmt.Println(st.GetHotplugRule())
 		return nil
 	}
 
@@ -68,14 +176,20 @@ func (st *SchedulerTuner) Apply(backup *BackupManager) error {
 	}
 
 	// Write udev rules
+	ExplainEdit("set the I/O scheduler of virtual disks at boot and on hot-add", st.UdevRulePath)
 	if err := os.WriteFile(st.UdevRulePath, []byte(rules), 0644); err != nil {
 		return fmt.Errorf("failed to write udev rules: %w", err)
 	}
 
 	PrintSuccess("Created %s", st.UdevRulePath)
 
+	if err := st.installHotplug(backup); err != nil {
+		PrintWarning("Hot-add disk hook not installed: %v", err)
+	}
+
 	// Reload udev rules
 	PrintInfo("Reloading udev rules...")
+	ExplainCommand("make udev use the new rules without a reboot", "udevadm", "control", "--reload-rules")
 	cmd := exec.Command("udevadm", "control", "--reload-rules")
 	if output, err := cmd.CombinedOutput(); err != nil {
 		PrintWarning("Failed to reload udev rules: %v", err)
@@ -96,48 +210,33 @@ func (st *SchedulerTuner) Apply(backup *BackupManager) error {
 func (st *SchedulerTuner) ApplyToCurrentDevices() error {
 	PrintInfo("Applying I/O scheduler to current devices...")
 
-	// Find all block devices
-	devices, err := filepath.Glob("/sys/block/sd*")
+	devices, err := ReadDeviceSchedulers(st.FSRoot)
 	if err != nil {
 		return err
 	}
 
-	nvmeDevices, _ := filepath.Glob("/sys/block/nvme*")
-	devices = append(devices, nvmeDevices...)
-
+	profile := Tuning.ActiveProfile().Scheduler
 	successCount := 0
 	failCount := 0
 
-	for _, device := range devices {
-		deviceName := filepath.Base(device)
-		schedulerPath := filepath.Join(device, "queue", "scheduler")
-
-		// Set scheduler to 'none'
-		if err := st.setScheduler(schedulerPath, "none"); err != nil {
-			// Try 'noop' as fallback (older kernels)
-			if err := st.setScheduler(schedulerPath, "noop"); err != nil {
-				PrintWarning("Failed to set scheduler for %s: %v", deviceName, err)
-				failCount++
-				continue
-			}
-		}
+	for _, dev := range devices {
+		schedulerPath := st.sys().Path("/sys/block", dev.Device, "queue", "scheduler")
 
-		// Set nr_requests
-		nrRequestsPath := filepath.Join(device, "queue", "nr_requests")
-		if err := os.WriteFile(nrRequestsPath, []byte("256"), 0644); err != nil {
-			// Not critical, just warn
-			PrintWarning("Could not set nr_requests for %s", deviceName)
+		// Set the scheduler of the device class, falling back to its legacy name
+		var err error
+		for _, sched := range schedulerChoices(dev.Want(profile)) {
+			if err = st.setScheduler(schedulerPath, sched); err == nil {
+				break
+			}
 		}
-
-		// Set read_ahead_kb
-		readAheadPath := filepath.Join(device, "bdi", "read_ahead_kb")
-		if err := os.WriteFile(readAheadPath, []byte("256"), 0644); err != nil {
-			// Not critical, just warn
-			PrintWarning("Could not set read_ahead_kb for %s", deviceName)
+		if err != nil {
+			PrintWarning("Failed to set scheduler for %s: %v", dev.Device, err)
+			failCount++
+			continue
 		}
 
 		successCount++
-		PrintSuccess("Configured %s", deviceName)
+		PrintSuccess("Configured %s (%s)", dev.Device, dev.Want(profile))
 	}
 
 	if successCount > 0 {
@@ -152,77 +251,182 @@ func (st *SchedulerTuner) ApplyToCurrentDevices() error {
 
 // setScheduler sets the I/O scheduler for a device
 func (st *SchedulerTuner) setScheduler(schedulerPath, scheduler string) error {
+	ExplainEdit("use "+scheduler+" now: the hypervisor already schedules the physical disks", schedulerPath)
 	return os.WriteFile(schedulerPath, []byte(scheduler), 0644)
 }
 
-// ShowCurrent displays current I/O scheduler settings
+// ShowCurrent displays the runtime I/O scheduler of every device and the one
+// its class should use
 func (st *SchedulerTuner) ShowCurrent() error {
 	PrintStep("Current I/O scheduler settings")
 
-	// Find all block devices
-	devices, err := filepath.Glob("/sys/block/sd*")
+	devices, err := ReadDeviceSchedulers(st.FSRoot)
 	if err != nil {
 		return err
 	}
-
-	nvmeDevices, _ := filepath.Glob("/sys/block/nvme*")
-	devices = append(devices, nvmeDevices...)
-
 	if len(devices) == 0 {
 		PrintWarning("No block devices found")
 		return nil
 	}
 
-	for _, device := range devices {
-		deviceName := filepath.Base(device)
-		schedulerPath := filepath.Join(device, "queue", "scheduler")
-
-		data, err := os.ReadFile(schedulerPath)
-		if err != nil {
-			PrintWarning("Could not read scheduler for %s", deviceName)
-			continue
-		}
-
-		// Extract current scheduler (marked with [brackets])
-		schedulerLine := strings.TrimSpace(string(data))
-		current := "unknown"
+	queue := "blk-mq (per-device schedulers, elevator= ignored)"
+	if !MultiQueue(st.FSRoot) {
+		queue = "single queue (legacy schedulers)"
+	}
+	PrintInfo("Block layer: %s", queue)
 
-		// Find scheduler in brackets
-		if start := strings.Index(schedulerLine, "["); start != -1 {
-			if end := strings.Index(schedulerLine[start:], "]"); end != -1 {
-				current = schedulerLine[start+1 : start+end]
-			}
-		}
+	profile := Tuning.ActiveProfile().Scheduler
+	for _, dev := range devices {
+		queue := filepath.Join("/sys/block", dev.Device, "queue")
 
 		// Get read-ahead value
-		readAheadPath := filepath.Join(device, "bdi", "read_ahead_kb")
 		readAhead := "N/A"
-		if data, err := os.ReadFile(readAheadPath); err == nil {
-			readAhead = strings.TrimSpace(string(data)) + " KB"
+		if value, err := st.sys().ReadString(queue, "read_ahead_kb"); err == nil {
+			readAhead = value + " KB"
 		}
 
 		// Get queue depth
-		nrRequestsPath := filepath.Join(device, "queue", "nr_requests")
 		nrRequests := "N/A"
-		if data, err := os.ReadFile(nrRequestsPath); err == nil {
-			nrRequests = strings.TrimSpace(string(data))
+		if value, err := st.sys().ReadString(queue, "nr_requests"); err == nil {
+			nrRequests = value
 		}
 
-		fmt.Printf("\n  Device: %s\n", deviceName)
-		fmt.Printf("  Scheduler: %s\n", current)
+		// Get completion affinity
+		rqAffinity := "N/A"
+		if value, err := st.sys().ReadString(queue, "rq_affinity"); err == nil {
+			rqAffinity = value
+		}
+
+		status := "ok"
+		if !dev.Compliant(dev.Want(profile)) {
+			status = "expected " + dev.Want(profile)
+		}
+		fmt.Printf("\n  Device: %s (%s)\n", dev.Device, dev.Class)
+		fmt.Printf("  Scheduler: %s (%s; available: %s)\n", dev.Current, status, strings.Join(dev.Available, ", "))
 		fmt.Printf("  Read-ahead: %s\n", readAhead)
 		fmt.Printf("  Queue depth: %s\n", nrRequests)
+		fmt.Printf("  rq_affinity: %s\n", rqAffinity)
 	}
 
 	return nil
 }
 
-// Verify checks if the udev rules have been applied
+// parseScheduler returns the active scheduler (in [brackets]) and the
+// available ones from a queue/scheduler file
+func parseScheduler(line string) (string, []string) {
+	current := ""
+	var available []string
+	for _, field := range strings.Fields(line) {
+		if strings.HasPrefix(field, "[") && strings.HasSuffix(field, "]") {
+			field = strings.Trim(field, "[]")
+			current = field
+		}
+		available = append(available, field)
+	}
+	return current, available
+}
+
+// DeviceScheduler is the effective scheduler of a disk
+type DeviceScheduler struct {
+	Device     string
+	Class      string // DeviceDisk, DeviceNVMe or DeviceDM
+	Current    string
+	Available  []string
+	MultiQueue bool
+	Covered    bool // matched by the KERNEL patterns of the udev rules
+}
+
+// udevCoveredRe matches the device names handled by the udev rules
+var udevCoveredRe = regexp.MustCompile(`^(sd[a-z]+|vd[a-z]+|xvd[a-z]+|nvme[0-9]+n[0-9]+|dm-[0-9]+)$`)
+
+// schedulerDiskRe selects the disks and device-mapper volumes (not
+// partitions, loop or md devices)
+var schedulerDiskRe = regexp.MustCompile(`^(sd[a-z]+|nvme[0-9]+n[0-9]+|vd[a-z]+|xvd[a-z]+|dm-[0-9]+)$`)
+
+// ReadDeviceSchedulers reads the runtime scheduler of every disk.
+// fsRoot allows running against a fixture tree (empty string for /).
+func ReadDeviceSchedulers(fsRoot string) ([]DeviceScheduler, error) {
+	sys := SysFS{Root: fsRoot}
+	names, err := sys.BlockDevices()
+	if err != nil {
+		return nil, err
+	}
+
+	var devices []DeviceScheduler
+	for _, name := range names {
+		if !schedulerDiskRe.MatchString(name) {
+			continue
+		}
+		line, err := sys.ReadString("/sys/block", name, "queue", "scheduler")
+		if err != nil {
+			continue
+		}
+		current, available := parseScheduler(line)
+		if current == "" {
+			continue // bio-based device-mapper: no scheduler to choose
+		}
+		devices = append(devices, DeviceScheduler{
+			Device:     name,
+			Class:      deviceClass(name),
+			Current:    current,
+			Available:  available,
+			MultiQueue: sys.Exists("/sys/block", name, "mq"),
+			Covered:    udevCoveredRe.MatchString(name),
+		})
+	}
+	return devices, nil
+}
+
+// Want returns the scheduler the device should use with the profile scheduler
+func (d DeviceScheduler) Want(profile string) string {
+	return schedulerFor(d.Class, profile)
+}
+
+// Compliant reports whether the device uses want (see Want), or its name on
+// legacy kernels
+func (d DeviceScheduler) Compliant(want string) bool {
+	for _, sched := range schedulerChoices(want) {
+		if d.Current == sched {
+			return true
+		}
+	}
+	return false
+}
+
+// Verify checks the udev rules and the effective scheduler of every disk,
+// including disks hot-added since tuning
 func (st *SchedulerTuner) Verify() error {
-	if _, err := os.Stat(st.UdevRulePath); os.IsNotExist(err) {
+	if !st.sys().Exists(st.UdevRulePath) {
 		return fmt.Errorf("udev rules file not found: %s", st.UdevRulePath)
 	}
 
 	PrintSuccess("I/O scheduler udev rules exist")
+
+	var problems []string
+	hook := st.sys().Exists(st.HotplugRulePath) && st.sys().Exists(st.HotplugHelperPath)
+	if !hook {
+		problems = append(problems, "hot-add disk hook not installed")
+	}
+
+	devices, err := ReadDeviceSchedulers(st.FSRoot)
+	if err != nil {
+		return err
+	}
+
+	profile := Tuning.ActiveProfile().Scheduler
+	for _, dev := range devices {
+		switch {
+		case !dev.Compliant(dev.Want(profile)):
+			problems = append(problems, fmt.Sprintf("%s uses %s instead of %s", dev.Device, dev.Current, dev.Want(profile)))
+		case !dev.Covered && !hook:
+			// Compliant now, but the rule will not apply after a reboot
+			problems = append(problems, fmt.Sprintf("%s is not matched by the udev rules", dev.Device))
+		}
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("scheduler policy not met: %s", strings.Join(problems, ", "))
+	}
+
+	PrintSuccess("I/O scheduler of the profile ('%s', none for NVMe and device-mapper) active on %d device(s)", profile, len(devices))
 	return nil
 }
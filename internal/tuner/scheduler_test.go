@@ -0,0 +1,108 @@
+package tuner
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseScheduler(t *testing.T) {
+	current, available := parseScheduler("[mq-deadline] kyber bfq none\n")
+	if current != "mq-deadline" || len(available) != 4 || available[3] != "none" {
+		t.Errorf("got %q %v", current, available)
+	}
+	if current, _ := parseScheduler("none"); current != "" {
+		t.Errorf("no active scheduler expected, got %q", current)
+	}
+}
+
+func TestReadDeviceSchedulers(t *testing.T) {
+	root := t.TempDir()
+	devices := map[string]string{
+		"sda":     "[none] mq-deadline",
+		"sdb":     "[mq-deadline] none",
+		"sdaa":    "[none] mq-deadline",
+		"nvme0n1": "[none]",
+		"loop0":   "[none]",
+		"dm-0":    "none",
+		"dm-1":    "[mq-deadline] none",
+	}
+	for name, sched := range devices {
+		dir := filepath.Join(root, "sys/block", name, "queue")
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, "scheduler"), []byte(sched+"\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	got, err := ReadDeviceSchedulers(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	byName := make(map[string]DeviceScheduler)
+	for _, d := range got {
+		byName[d.Device] = d
+	}
+	if len(got) != 5 {
+		t.Fatalf("expected the 4 disks and dm-1 only, got %+v", got)
+	}
+	if byName["sdb"].Compliant("none") {
+		t.Error("sdb uses mq-deadline and should not be compliant")
+	}
+	if d := byName["sdaa"]; !d.Compliant("none") || !d.Covered {
+		t.Errorf("sdaa should be compliant and matched by the udev rules: %+v", d)
+	}
+	if d := byName["dm-1"]; d.Class != DeviceDM || d.Want("mq-deadline") != "none" || d.Compliant(d.Want("mq-deadline")) {
+		t.Errorf("dm-1 should want none whatever the profile: %+v", d)
+	}
+	if d := byName["nvme0n1"]; !d.Compliant("none") || !d.Covered {
+		t.Errorf("unexpected nvme0n1 state %+v", d)
+	}
+
+	// The audit grades the schedulers in effect, not the elevator= parameter
+	saved := Tuning
+	defer func() { Tuning = saved }()
+	Tuning = TuningConfig{}
+	item := auditScheduler(root)
+	if item.Status != AuditWarn || item.Points != 0 || len(item.Details) != 2 {
+		t.Errorf("sdb and dm-1 should be reported: %+v", item)
+	}
+	empty := t.TempDir()
+	os.MkdirAll(filepath.Join(empty, "sys/block"), 0755)
+	if item := auditScheduler(empty); item.Status != AuditOK || item.Points != item.Max {
+		t.Errorf("no disk to grade: %+v", item)
+	}
+}
+
+func TestSchedulerUdevRules(t *testing.T) {
+	defer func(saved TuningConfig) { Tuning = saved }(Tuning)
+	Tuning.Profile = "database" // mq-deadline
+
+	root := t.TempDir()
+	os.MkdirAll(filepath.Join(root, "sys/block/sda/queue"), 0755)
+	os.WriteFile(filepath.Join(root, "sys/block/sda/queue/scheduler"), []byte("noop [deadline] cfq\n"), 0644)
+	st := &SchedulerTuner{FSRoot: root}
+
+	if MultiQueue(root) {
+		t.Fatal("a disk without mq directory is single queue")
+	}
+	rules := st.GetUdevRules()
+	if !strings.Contains(rules, `KERNEL=="sd*|vd*|xvd*", ATTR{queue/scheduler}="deadline"`) || !strings.Contains(rules, `KERNEL=="nvme*", ATTR{queue/scheduler}="noop"`) {
+		t.Errorf("legacy kernel rules:\n%s", rules)
+	}
+
+	os.MkdirAll(filepath.Join(root, "sys/block/sda/mq"), 0755)
+	rules = st.GetUdevRules()
+	for _, want := range []string{
+		`KERNEL=="sd*|vd*|xvd*", ATTR{queue/scheduler}="mq-deadline"`,
+		`KERNEL=="nvme*", ATTR{queue/scheduler}="none"`,
+		`KERNEL=="dm-*", ATTR{queue/scheduler}="none"`,
+	} {
+		if !strings.Contains(rules, want) {
+			t.Errorf("blk-mq rules lack %s:\n%s", want, rules)
+		}
+	}
+}
@@ -0,0 +1,264 @@
+package tuner
+
+import (
+	"bufio"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"syscall"
+)
+
+// snmpUserPattern restricts SNMPv3 user names to characters safe in snmpd.conf
+var snmpUserPattern = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_.-]{0,31}$`)
+
+// snmpViewOIDs are the subtrees exposed to the monitoring user
+var snmpViewOIDs = []struct {
+	OID  string
+	Name string
+}{
+	{".1.3.6.1.2.1.1", "system"},
+	{".1.3.6.1.2.1.2", "interfaces"},
+	{".1.3.6.1.2.1.31", "ifMIB (64-bit counters)"},
+	{".1.3.6.1.2.1.25", "host resources (storage, processors)"},
+	{".1.3.6.1.4.1.2021", "UCD (load, memory, disk)"},
+}
+
+// SNMPTuner sets up a minimal SNMPv3-only net-snmp agent
+type SNMPTuner struct {
+	Distro      *DistroManager
+	Backup      *BackupManager
+	ConfigPath  string
+	PersistPath string
+}
+
+// NewSNMPTuner creates a new SNMP tuner
+func NewSNMPTuner(distro *DistroManager, backup *BackupManager) *SNMPTuner {
+	persist := "/var/lib/snmp/snmpd.conf"
+	if distro.Type == DistroRHEL {
+		persist = "/var/lib/net-snmp/snmpd.conf"
+	}
+	return &SNMPTuner{
+		Distro:      distro,
+		Backup:      backup,
+		ConfigPath:  "/etc/snmp/snmpd.conf",
+		PersistPath: persist,
+	}
+}
+
+func init() {
+	Register(Module{
+		Name:        "snmp",
+		Label:       "Setup SNMP Agent",
+		Description: "Install snmpd with an SNMPv3 user",
+		Category:    CategoryIntegration,
+		Menu:        22,
+		RequireRoot: true,
+		Distros:     packagedDistros,
+		Run: func(ctx *ModuleContext) error {
+			backup, err := ctx.BackupSession()
+			if err != nil {
+				return err
+			}
+			return NewSNMPTuner(ctx.Distro, backup).Run(ctx.HasInternet)
+		},
+	})
+}
+
+// snmpPackages returns the net-snmp packages of the distribution
+func (st *SNMPTuner) snmpPackages() []string {
+	if st.Distro.Type == DistroRHEL {
+		return []string{"net-snmp", "net-snmp-utils"}
+	}
+	return []string{"snmpd", "snmp"}
+}
+
+// SnmpdConfig renders snmpd.conf: no v1/v2c community, one read-only
+// authPriv user limited to the monitoring view
+func SnmpdConfig(user, location, contact string) string {
+	var sb strings.Builder
+	sb.WriteString("# SNMPv3 agent - Generated by vmware-tuner\n")
+	sb.WriteString("agentAddress udp:161,udp6:[::1]:161\n\n")
+	for _, view := range snmpViewOIDs {
+		fmt.Fprintf(&sb, "view monitoring included %-20s # %s\n", view.OID, view.Name)
+	}
+	fmt.Fprintf(&sb, "\nrouser -s usm %s priv -V monitoring\n\n", user)
+	if location != "" {
+		fmt.Fprintf(&sb, "sysLocation %s\n", location)
+	}
+	if contact != "" {
+		fmt.Fprintf(&sb, "sysContact %s\n", contact)
+	}
+	sb.WriteString("dontLogTCPWrappersConnects yes\n")
+	return sb.String()
+}
+
+// Run installs net-snmp and configures the v3 user.
+// All written files are recorded in the backup manifest.
+func (st *SNMPTuner) Run(hasInternet bool) error {
+	PrintStep("SNMP Agent Quick Setup")
+	if SkipExcluded("snmp", ExcludeService, "snmpd") {
+		return nil
+	}
+
+	installed := true
+	for _, pkg := range st.snmpPackages() {
+		if isPackageInstalled(st.Distro, pkg) {
+			continue
+		}
+		installed = false
+		if !st.Distro.HasPackageSource(pkg, hasInternet) {
+			return fmt.Errorf("%s is not installed and no repository is reachable (see --pkg-dir): %w", pkg, ErrOffline)
+		}
+	}
+
+	reader := bufio.NewReader(os.Stdin)
+	prompt := func(question, def string) string {
+		if def != "" {
+			fmt.Printf("%s [%s]: ", question, def)
+		} else {
+			fmt.Printf("%s: ", question)
+		}
+		input, _ := reader.ReadString('\n')
+		if input = strings.TrimSpace(input); input == "" {
+			return def
+		}
+		return input
+	}
+
+	user := prompt("SNMPv3 user name", "monitor")
+	if !snmpUserPattern.MatchString(user) {
+		return fmt.Errorf("invalid user name %q", user)
+	}
+
+	authPass := prompt("Authentication passphrase (SHA, min 8 chars, empty to generate)", "")
+	privPass := prompt("Privacy passphrase (AES, min 8 chars, empty to generate)", "")
+	generated := authPass == "" || privPass == ""
+	var err error
+	if authPass == "" {
+		if authPass, err = randomPassphrase(); err != nil {
+			return err
+		}
+	}
+	if privPass == "" {
+		if privPass, err = randomPassphrase(); err != nil {
+			return err
+		}
+	}
+	if len(authPass) < 8 || len(privPass) < 8 {
+		return fmt.Errorf("SNMPv3 passphrases must be at least 8 characters")
+	}
+	if strings.ContainsAny(authPass+privPass, " \t\"'") {
+		return fmt.Errorf("passphrases must not contain spaces or quotes")
+	}
+
+	location := prompt("sysLocation (optional)", "")
+	contact := prompt("sysContact (optional)", "")
+
+	// Record the pre-install state so rollback removes files the package creates
+	for _, path := range []string{st.ConfigPath, st.PersistPath} {
+		if err := st.Backup.BackupFile(path); err != nil {
+			return fmt.Errorf("failed to backup %s: %w", path, err)
+		}
+	}
+
+	if !installed {
+		for _, pkg := range st.snmpPackages() {
+			if err := st.Distro.InstallPackage(pkg); err != nil {
+				return err
+			}
+		}
+	}
+
+	// snmpd rewrites the persistent file on shutdown: stop it before adding the user
+	exec.Command("systemctl", "stop", "snmpd").Run()
+
+	if err := os.MkdirAll(filepath.Dir(st.ConfigPath), 0755); err != nil {
+		return fmt.Errorf("failed to create %s: %w", filepath.Dir(st.ConfigPath), err)
+	}
+	if err := os.WriteFile(st.ConfigPath, []byte(SnmpdConfig(user, location, contact)), 0600); err != nil {
+		return fmt.Errorf("failed to write %s: %w", st.ConfigPath, err)
+	}
+	PrintSuccess("Created %s (SNMPv3 only, read-only view)", st.ConfigPath)
+
+	if err := st.addUser(user, authPass, privPass); err != nil {
+		return err
+	}
+
+	if out, err := exec.Command("systemctl", "enable", "--now", "snmpd").CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to start snmpd: %v: %s", err, string(out))
+	}
+	PrintSuccess("snmpd started")
+
+	st.testQuery(user, authPass, privPass)
+
+	if generated {
+		fmt.Println()
+		PrintWarning("Generated credentials (store them in your monitoring vault, they are not shown again):")
+		fmt.Printf("  %-22s: %s\n", "User", user)
+		fmt.Printf("  %-22s: SHA / %s\n", "Auth", authPass)
+		fmt.Printf("  %-22s: AES / %s\n", "Privacy", privPass)
+	}
+	PrintInfo("Open UDP 161 to your monitoring server only")
+
+	return nil
+}
+
+// addUser appends a createUser directive to the persistent snmpd file.
+// snmpd converts it to a localized key and removes it on first start.
+func (st *SNMPTuner) addUser(user, authPass, privPass string) error {
+	dir := filepath.Dir(st.PersistPath)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return fmt.Errorf("failed to create %s: %w", dir, err)
+	}
+
+	f, err := os.OpenFile(st.PersistPath, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", st.PersistPath, err)
+	}
+	_, err = fmt.Fprintf(f, "createUser %s SHA %s AES %s\n", user, authPass, privPass)
+	f.Close()
+	if err != nil {
+		return fmt.Errorf("failed to write %s: %w", st.PersistPath, err)
+	}
+
+	// snmpd drops privileges (Debian-snmp): keep the file owned like its directory
+	if info, err := os.Stat(dir); err == nil {
+		if stat, ok := info.Sys().(*syscall.Stat_t); ok {
+			os.Chown(st.PersistPath, int(stat.Uid), int(stat.Gid))
+		}
+	}
+
+	PrintSuccess("SNMPv3 user '%s' added (authPriv)", user)
+	return nil
+}
+
+// testQuery reads sysName through the new user to confirm the setup
+func (st *SNMPTuner) testQuery(user, authPass, privPass string) {
+	if _, err := exec.LookPath("snmpget"); err != nil {
+		PrintInfo("snmpget not available, skipping local test query")
+		return
+	}
+
+	out, err := exec.Command("snmpget", "-v3", "-l", "authPriv", "-u", user,
+		"-a", "SHA", "-A", authPass, "-x", "AES", "-X", privPass,
+		"localhost", "1.3.6.1.2.1.1.5.0").CombinedOutput()
+	if err != nil {
+		PrintWarning("Test query failed: %s", strings.TrimSpace(string(out)))
+		return
+	}
+	PrintSuccess("Test query OK: %s", strings.TrimSpace(string(out)))
+}
+
+// randomPassphrase generates a 20-character passphrase
+func randomPassphrase() (string, error) {
+	buf := make([]byte, 15)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to generate passphrase: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
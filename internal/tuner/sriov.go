@@ -0,0 +1,217 @@
+package tuner
+
+import (
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/sys/unix"
+)
+
+// PassthroughNIC describes a NIC backed by an SR-IOV Virtual Function or a
+// DirectPath I/O (PCI passthrough) device instead of an emulated adapter
+type PassthroughNIC struct {
+	Interface string `json:"interface"`
+	Driver    string `json:"driver"`
+	PCIAddr   string `json:"pci_address"`
+	IsVF      bool   `json:"sriov_vf"`
+}
+
+// Kind returns a human readable passthrough type
+func (p PassthroughNIC) Kind() string {
+	if p.IsVF {
+		return "SR-IOV VF"
+	}
+	return "DirectPath I/O"
+}
+
+// sriovVFDrivers are guest drivers that only bind to SR-IOV Virtual Functions.
+// Drivers of both functions (mlx5_core, bnxt_en, qede...) are told apart by
+// the physfn link of the device.
+var sriovVFDrivers = map[string]bool{
+	"ixgbevf": true,
+	"iavf":    true,
+	"i40evf":  true,
+	"igbvf":   true,
+}
+
+// emulatedNICDrivers are the paravirtual/emulated adapters provided by ESXi
+var emulatedNICDrivers = map[string]bool{
+	"vmxnet3": true,
+	"vmxnet":  true,
+	"e1000":   true,
+	"e1000e":  true,
+	"pcnet32": true,
+}
+
+// DetectPassthroughNICs lists NICs that are not emulated by the hypervisor.
+// fsRoot allows running against a fixture tree (empty string for /).
+func DetectPassthroughNICs(fsRoot string) ([]PassthroughNIC, error) {
+	sys := SysFS{Root: fsRoot}
+	names, err := sys.NetDevices()
+	if err != nil {
+		return nil, err
+	}
+
+	var nics []PassthroughNIC
+	for _, name := range names {
+		deviceLink := filepath.Join("/sys/class/net", name, "device")
+
+		// Virtual interfaces (lo, bridges, bonds) have no backing device
+		devTarget, err := sys.Readlink(deviceLink)
+		if err != nil {
+			continue
+		}
+
+		driverTarget, err := sys.Readlink(deviceLink, "driver")
+		if err != nil {
+			continue
+		}
+		driver := filepath.Base(driverTarget)
+
+		if emulatedNICDrivers[driver] {
+			continue
+		}
+
+		// Only PCI devices can be passed through (skip virtio, usb, etc.)
+		pciAddr := filepath.Base(devTarget)
+		if strings.Count(pciAddr, ":") != 2 {
+			continue
+		}
+
+		nics = append(nics, PassthroughNIC{
+			Interface: name,
+			Driver:    driver,
+			PCIAddr:   pciAddr,
+			IsVF:      sriovVFDrivers[driver] || sys.Exists(deviceLink, "physfn"),
+		})
+	}
+
+	return nics, nil
+}
+
+// nicIRQs returns the IRQ numbers registered for an interface in /proc/interrupts
+func nicIRQs(iface, pciAddr string) []string {
+	data, err := Sys.ReadFile("/proc/interrupts")
+	if err != nil {
+		return nil
+	}
+
+	var irqs []string
+	for _, line := range strings.Split(string(data), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 2 || !irqOfNIC(fields[1:], iface, pciAddr) {
+			continue
+		}
+		irq := strings.TrimSuffix(fields[0], ":")
+		if Sys.Exists("/proc/irq", irq) {
+			irqs = append(irqs, irq)
+		}
+	}
+	return irqs
+}
+
+// irqOfNIC reports whether the fields of an interrupt line name the
+// interface as a whole ("ens1", "ens1-TxRx-0", not "ens192-TxRx-0") or its
+// PCI function ("mlx5_comp0@pci:0000:1b:00.0")
+func irqOfNIC(fields []string, iface, pciAddr string) bool {
+	for _, field := range fields {
+		if field == iface || strings.HasPrefix(field, iface+"-") {
+			return true
+		}
+		if pciAddr != "" && strings.Contains(field, pciAddr) {
+			return true
+		}
+	}
+	return false
+}
+
+// irqsSpread reports whether the given IRQs are allowed on more than one CPU set
+func irqsSpread(irqs []string) bool {
+	affinities := make(map[string]bool)
+	for _, irq := range irqs {
+		affinity, err := Sys.ReadString("/proc/irq", irq, "smp_affinity_list")
+		if err != nil {
+			continue
+		}
+		affinities[affinity] = true
+	}
+	return len(affinities) > 1
+}
+
+// ReportPassthroughNICs prints detected SR-IOV/DirectPath NICs with driver and IRQ details
+func ReportPassthroughNICs(nics []PassthroughNIC) {
+	for _, nic := range nics {
+		PrintInfo("Interface %s is a %s device (driver: %s, PCI %s)", nic.Interface, nic.Kind(), nic.Driver, nic.PCIAddr)
+
+		// Verify the driver is actually loaded and reports a version
+		if info, err := (ioctlEthtool{}).DriverInfo(nic.Interface); err == nil {
+			fmt.Printf("    version: %s\n", unix.ByteSliceToString(info.Version[:]))
+			fmt.Printf("    firmware-version: %s\n", unix.ByteSliceToString(info.Fw_version[:]))
+		} else {
+			PrintWarning("  Could not query driver info for %s: %v", nic.Interface, err)
+		}
+
+		irqs := nicIRQs(nic.Interface, nic.PCIAddr)
+		if len(irqs) > 1 && !irqsSpread(irqs) {
+			PrintWarning("  %d IRQs of %s share the same CPU affinity", len(irqs), nic.Interface)
+		} else if len(irqs) > 0 {
+			PrintSuccess("  %d IRQ(s) registered for %s", len(irqs), nic.Interface)
+		}
+	}
+
+	if len(nics) > 0 {
+		PrintWarning("vMotion constraints apply: VMs with SR-IOV/DirectPath NICs cannot be live-migrated")
+		PrintWarning("Snapshots, HA restarts and DRS balancing are also restricted (full memory reservation required)")
+	}
+}
+
+// tunePassthroughNICs applies the tuning relevant to SR-IOV/DirectPath NICs.
+// The vmxnet3-specific ethtool values are deliberately not applied to them.
+func (nt *NetworkTuner) tunePassthroughNICs(backup *BackupManager) error {
+	nics, err := DetectPassthroughNICs(Sys.Root)
+	if err != nil || len(nics) == 0 {
+		return nil
+	}
+
+	PrintStep("SR-IOV / DirectPath NICs")
+	PrintInfo("vmxnet3 ring buffer/coalescing settings are skipped for these interfaces")
+	ReportPassthroughNICs(nics)
+
+	// Spread interrupts of multi-queue physical NICs across vCPUs
+	if Tuning.AffinityMode() == AffinityPin {
+		PrintInfo("Affinity mode pin: irqbalance is left stopped")
+		return nil
+	}
+	if err := exec.Command("systemctl", "is-active", "irqbalance").Run(); err == nil {
+		PrintSuccess("irqbalance is active (IRQs spread across vCPUs)")
+		return nil
+	}
+
+	if _, err := exec.LookPath("irqbalance"); err != nil {
+		PrintWarning("irqbalance is not installed; NIC interrupts may stay on a single vCPU")
+		return nil
+	}
+
+	if SkipExcluded("network", ExcludeService, "irqbalance") {
+		return nil
+	}
+	if nt.DryRun {
+		PrintInfo("Would enable irqbalance to spread NIC interrupts")
+		return nil
+	}
+
+	// Recorded first: rollback puts its enable state back
+	if err := backup.BackupServices([]string{"irqbalance"}); err != nil {
+		return fmt.Errorf("failed to record the irqbalance service: %w", err)
+	}
+	ExplainCommand("spread the interrupts of the passthrough NICs across vCPUs", "systemctl", "enable", "--now", "irqbalance")
+	if out, err := exec.Command("systemctl", "enable", "--now", "irqbalance").CombinedOutput(); err != nil {
+		PrintWarning("Failed to enable irqbalance: %v", err)
+		fmt.Println(string(out))
+	} else {
+		PrintSuccess("Enabled irqbalance to spread NIC interrupts")
+	}
+	return nil
+}
@@ -0,0 +1,89 @@
+package tuner
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDetectPassthroughNICs(t *testing.T) {
+	root := t.TempDir()
+	nics := []struct {
+		iface, addr, driver string
+		physfn              bool
+	}{
+		{"ens192", "0000:0b:00.0", "vmxnet3", false},
+		{"ens224", "0000:13:00.0", "ixgbevf", false},
+		{"ens256", "0000:1b:00.0", "mlx5_core", true},
+		{"ens257", "0000:1b:00.1", "mlx5_core", false},
+		{"ens161", "0000:03:00.0", "bnxt_en", false},
+	}
+	for _, n := range nics {
+		dev := filepath.Join(root, "sys/devices/pci0000:00", n.addr)
+		writeFiles(t, root, map[string]string{"sys/bus/pci/drivers/" + n.driver + "/.keep": ""})
+		if n.physfn {
+			writeFiles(t, dev, map[string]string{"physfn/.keep": ""})
+		}
+		os.MkdirAll(dev, 0755)
+		os.MkdirAll(filepath.Join(root, "sys/class/net", n.iface), 0755)
+		if err := os.Symlink(dev, filepath.Join(root, "sys/class/net", n.iface, "device")); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Symlink(filepath.Join(root, "sys/bus/pci/drivers", n.driver), filepath.Join(dev, "driver")); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	found, err := DetectPassthroughNICs(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	vf := make(map[string]bool)
+	for _, nic := range found {
+		vf[nic.Interface] = nic.IsVF
+	}
+	want := map[string]bool{"ens224": true, "ens256": true, "ens257": false, "ens161": false}
+	if len(vf) != len(want) {
+		t.Fatalf("passthrough NICs = %+v, want %v", found, want)
+	}
+	for iface, isVF := range want {
+		if vf[iface] != isVF {
+			t.Errorf("%s: VF = %v, want %v", iface, vf[iface], isVF)
+		}
+	}
+}
+
+func TestNICIRQs(t *testing.T) {
+	saved := Sys
+	defer func() { Sys = saved }()
+	Sys = SysFS{Root: t.TempDir()}
+	writeFiles(t, Sys.Root, map[string]string{
+		"/proc/interrupts": `           CPU0       CPU1
+  40:        120          0   PCI-MSI 1572864-edge      ens1-TxRx-0
+  41:          0        118   PCI-MSI 1572865-edge      ens1-TxRx-1
+  42:        500          0   PCI-MSI 5767168-edge      ens192-rxtx-0
+  43:          3          0   PCI-MSI 1572866-edge      ens1
+  50:         10          0   PCI-MSI 2097152-edge      mlx5_comp0@pci:0000:1b:00.0
+`,
+		"/proc/irq/40/smp_affinity_list": "0\n",
+		"/proc/irq/41/smp_affinity_list": "1\n",
+		"/proc/irq/42/smp_affinity_list": "0\n",
+		"/proc/irq/43/smp_affinity_list": "0\n",
+		"/proc/irq/50/smp_affinity_list": "0-1\n",
+	})
+
+	irqs := nicIRQs("ens1", "0000:0c:00.0")
+	if strings.Join(irqs, ",") != "40,41,43" {
+		t.Errorf("ens1 IRQs = %v, want 40,41,43 (not the ens192 one)", irqs)
+	}
+	if !irqsSpread(irqs) {
+		t.Error("ens1 IRQs are on vCPUs 0 and 1")
+	}
+	if irqs := nicIRQs("ens256", "0000:1b:00.0"); strings.Join(irqs, ",") != "50" {
+		t.Errorf("mlx5 IRQs = %v, want 50 (matched by PCI address)", irqs)
+	}
+	if irqsSpread([]string{"42", "43"}) {
+		t.Error("IRQs 42 and 43 share vCPU 0")
+	}
+}
@@ -19,6 +19,24 @@ func NewSSHTuner(backup *BackupManager) *SSHTuner {
 	}
 }
 
+func init() {
+	Register(Module{
+		Name:        "ssh",
+		Label:       "Secure SSH",
+		Description: "Disable root login and password authentication",
+		Category:    CategoryMaintenance,
+		Menu:        7,
+		RequireRoot: true,
+		Run: func(ctx *ModuleContext) error {
+			backup, err := ctx.BackupSession()
+			if err != nil {
+				return err
+			}
+			return NewSSHTuner(backup).Run()
+		},
+	})
+}
+
 // Run performs the SSH hardening
 func (st *SSHTuner) Run() error {
 	PrintStep("SSH Hardening")
@@ -26,7 +44,7 @@ func (st *SSHTuner) Run() error {
 	PrintWarning("⚠️  WARNING: Incorrect SSH configuration can lock you out!")
 	PrintWarning("Ensure you have console access (VMware Remote Console) or a backup session.")
 	fmt.Println()
-	
+
 	configPath := "/etc/ssh/sshd_config"
 	if _, err := os.Stat(configPath); os.IsNotExist(err) {
 		return fmt.Errorf("sshd_config not found at %s", configPath)
@@ -74,8 +92,12 @@ func (st *SSHTuner) Run() error {
 		var resp string
 		fmt.Scanln(&resp)
 		if resp == "y" {
-			content += "\n# Added by vmware-tuner\nPasswordAuthentication no\n"
-			changes = true
+			if err := GuardDestructive("Disable SSH password authentication"); err != nil {
+				PrintWarning("%v", err)
+			} else {
+				content += "\n# Added by vmware-tuner\nPasswordAuthentication no\n"
+				changes = true
+			}
 		}
 	} else {
 		PrintSuccess("Password authentication already disabled")
@@ -98,7 +120,7 @@ func (st *SSHTuner) Run() error {
 		PrintError("Configuration check FAILED: %v", err)
 		PrintInfo("Output: %s", string(output))
 		PrintWarning("Restoring backup immediately...")
-		
+
 		// Restore
 		backupPath := st.Backup.GetBackupPath("sshd_config")
 		exec.Command("cp", backupPath, configPath).Run()
@@ -108,6 +130,10 @@ func (st *SSHTuner) Run() error {
 	PrintSuccess("Configuration syntax verified")
 
 	// Restart Service
+	if SkipExcluded("ssh", ExcludeService, "sshd") {
+		PrintInfo("Changes saved but service not restarted")
+		return nil
+	}
 	fmt.Print("Restart SSH service to apply? (y/n): ")
 	var resp string
 	fmt.Scanln(&resp)
@@ -0,0 +1,139 @@
+package tuner
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// StatsEnabled turns on the local usage statistics (stats.enabled in the
+// config file). Nothing is sent anywhere: the numbers stay in StatsPath.
+var StatsEnabled bool
+
+// StatsPath is the usage statistics file (in StateDir)
+var StatsPath = filepath.Join(StateDir, "usage-stats.json")
+
+// StatsConfig holds the stats: section of the config file
+type StatsConfig struct {
+	Enabled bool
+}
+
+// decode reads the stats: section
+func (sc *StatsConfig) decode(fields map[string]interface{}) error {
+	var err error
+	if sc.Enabled, err = yamlBool(fields["enabled"]); err != nil {
+		return fmt.Errorf("enabled: %w", err)
+	}
+	return nil
+}
+
+// ModuleStats are the counters of one module. Error messages are not kept:
+// they may name hosts, users or paths.
+type ModuleStats struct {
+	Runs         int     `json:"runs"`
+	Failures     int     `json:"failures"`
+	TotalSeconds float64 `json:"total_seconds"`
+	MaxSeconds   float64 `json:"max_seconds"`
+	LastRun      string  `json:"last_run"`
+}
+
+// UsageStats are the counters of every module since Since
+type UsageStats struct {
+	Since   string                  `json:"since"`
+	Runs    int                     `json:"runs"`
+	Modules map[string]*ModuleStats `json:"modules"`
+}
+
+// LoadUsageStats reads the statistics file; a missing file yields empty stats
+func LoadUsageStats(path string) (UsageStats, error) {
+	stats := UsageStats{Modules: make(map[string]*ModuleStats)}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return stats, nil
+	}
+	if err != nil {
+		return stats, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	if err := json.Unmarshal(data, &stats); err != nil {
+		return stats, fmt.Errorf("invalid %s: %w", path, err)
+	}
+	if stats.Modules == nil {
+		stats.Modules = make(map[string]*ModuleStats)
+	}
+	return stats, nil
+}
+
+// Add counts the modules of a run
+func (us *UsageStats) Add(rs *RunSummary, now time.Time) {
+	if us.Since == "" {
+		us.Since = now.Format(time.RFC3339)
+	}
+	us.Runs++
+	for module, d := range rs.Durations {
+		m, ok := us.Modules[module]
+		if !ok {
+			m = &ModuleStats{}
+			us.Modules[module] = m
+		}
+		m.Runs++
+		m.TotalSeconds += d.Seconds()
+		if d.Seconds() > m.MaxSeconds {
+			m.MaxSeconds = d.Seconds()
+		}
+		m.LastRun = now.Format(time.RFC3339)
+	}
+	for _, f := range rs.Failed {
+		if m, ok := us.Modules[f.Module]; ok {
+			m.Failures++
+		}
+	}
+}
+
+// RecordUsage adds a run to the statistics file when stats are enabled.
+// Best-effort: statistics never fail a run.
+func RecordUsage(rs *RunSummary) {
+	if !StatsEnabled || rs == nil || len(rs.Durations) == 0 {
+		return
+	}
+	stats, err := LoadUsageStats(StatsPath)
+	if err != nil {
+		return
+	}
+	stats.Add(rs, time.Now())
+	data, err := json.MarshalIndent(stats, "", "  ")
+	if err != nil {
+		return
+	}
+	if err := os.MkdirAll(filepath.Dir(StatsPath), 0755); err != nil {
+		return
+	}
+	os.WriteFile(StatsPath, append(data, '\n'), 0644)
+}
+
+// Print shows the counters, slowest modules first
+func (us UsageStats) Print() {
+	if us.Runs == 0 {
+		PrintInfo("No usage statistics recorded yet")
+		return
+	}
+	PrintInfo("%d run(s) since %s", us.Runs, us.Since)
+
+	var names []string
+	for name := range us.Modules {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		return us.Modules[names[i]].TotalSeconds > us.Modules[names[j]].TotalSeconds
+	})
+
+	fmt.Printf("\n  %-14s %6s %8s %10s %10s  %s\n", "MODULE", "RUNS", "FAILED", "AVERAGE", "SLOWEST", "LAST RUN")
+	for _, name := range names {
+		m := us.Modules[name]
+		average := time.Duration(m.TotalSeconds / float64(m.Runs) * float64(time.Second))
+		slowest := time.Duration(m.MaxSeconds * float64(time.Second))
+		fmt.Printf("  %-14s %6d %8d %10s %10s  %s\n", name, m.Runs, m.Failures, FormatDuration(average), FormatDuration(slowest), m.LastRun)
+	}
+}
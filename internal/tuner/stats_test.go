@@ -0,0 +1,56 @@
+package tuner
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestUsageStats(t *testing.T) {
+	defer func(enabled bool, path string) { StatsEnabled, StatsPath = enabled, path }(StatsEnabled, StatsPath)
+	StatsPath = filepath.Join(t.TempDir(), "usage-stats.json")
+
+	run := &RunSummary{Durations: map[string]time.Duration{"grub": 2 * time.Second, "network": 500 * time.Millisecond}}
+	run.Failed = []ModuleFailure{{Module: "network", Err: errors.New("ethtool failed")}}
+
+	StatsEnabled = false
+	RecordUsage(run)
+	if FileExists(StatsPath) {
+		t.Fatal("statistics recorded while disabled")
+	}
+
+	StatsEnabled = true
+	RecordUsage(run)
+	run.Durations["grub"] = 4 * time.Second
+	run.Failed = nil
+	RecordUsage(run)
+
+	stats, err := LoadUsageStats(StatsPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Runs != 2 || stats.Since == "" {
+		t.Errorf("runs = %d, since = %q", stats.Runs, stats.Since)
+	}
+	grub := stats.Modules["grub"]
+	if grub == nil || grub.Runs != 2 || grub.TotalSeconds != 6 || grub.MaxSeconds != 4 || grub.Failures != 0 {
+		t.Errorf("grub stats = %+v", grub)
+	}
+	if network := stats.Modules["network"]; network == nil || network.Failures != 1 {
+		t.Errorf("network stats = %+v", network)
+	}
+}
+
+func TestStatsConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	os.WriteFile(path, []byte("stats:\n  enabled: true\n"), 0644)
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !cfg.Stats.Enabled {
+		t.Error("stats.enabled not read")
+	}
+}
@@ -0,0 +1,46 @@
+package tuner
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+)
+
+// ShowStatus prints the tuning state of the VM: applied profile, backups and
+// the last post-reboot verification
+func ShowStatus() {
+	PrintStep("Tuning Status")
+
+	applied := AppliedProfile(NewSysctlTuner(false).ConfigPath)
+	if applied == "" {
+		applied = "none (not tuned)"
+	}
+	PrintInfo("Applied profile: %s", applied)
+
+	backups, err := ListBackups()
+	switch {
+	case err != nil:
+		PrintWarning("Backups: %v", err)
+	case len(backups) == 0:
+		PrintInfo("Backups: none")
+	default:
+		PrintInfo("Backups: %d, latest %s (%s)", len(backups), backups[len(backups)-1], BackupRoot)
+	}
+
+	var report VerifyReport
+	data, err := os.ReadFile(filepath.Join(StateDir, postBootResultName))
+	switch {
+	case err != nil:
+		PrintInfo("Post-reboot verification: never run (--verify-after-reboot)")
+	case json.Unmarshal(data, &report) != nil:
+		PrintWarning("Post-reboot verification: unreadable result in %s", StateDir)
+	case report.OK:
+		PrintSuccess("Post-reboot verification: all checks passed (%s)", report.Timestamp)
+	default:
+		PrintWarning("Post-reboot verification: some checks failed (%s), see 'vmware-tuner verify'", report.Timestamp)
+	}
+
+	if !StatsEnabled {
+		PrintInfo("Usage statistics: disabled (stats.enabled in %s)", DefaultConfigPath)
+	}
+}
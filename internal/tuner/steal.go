@@ -0,0 +1,206 @@
+package tuner
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Steal time levels, in % of the elapsed time: a vCPU ready to run but not
+// scheduled by the ESXi host (esxtop %RDY, %CSTP)
+const (
+	stealWarnPercent = 5.0
+	stealCritPercent = 10.0
+)
+
+// CPUTimes are the jiffies of one line of /proc/stat
+type CPUTimes struct {
+	CPU   string // "all" for the aggregate line, cpu0, cpu1...
+	Steal uint64
+	Total uint64
+}
+
+// ReadCPUTimes reads the aggregate and per-vCPU times of /proc/stat, the
+// aggregate first. fsRoot allows running against a fixture tree.
+func ReadCPUTimes(fsRoot string) ([]CPUTimes, error) {
+	data, err := os.ReadFile(filepath.Join(fsRoot, "/proc/stat"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read /proc/stat: %w", err)
+	}
+	var times []CPUTimes
+	for _, line := range strings.Split(string(data), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 2 || !strings.HasPrefix(fields[0], "cpu") {
+			continue
+		}
+		t := CPUTimes{CPU: fields[0]}
+		if t.CPU == "cpu" {
+			t.CPU = "all"
+		}
+		// user nice system idle iowait irq softirq steal guest guest_nice:
+		// guest time is already counted in user and nice
+		for i, field := range fields[1:] {
+			if i >= 8 {
+				break
+			}
+			value, err := strconv.ParseUint(field, 10, 64)
+			if err != nil {
+				continue
+			}
+			t.Total += value
+			if i == 7 {
+				t.Steal = value
+			}
+		}
+		times = append(times, t)
+	}
+	if len(times) == 0 {
+		return nil, fmt.Errorf("no CPU line in /proc/stat")
+	}
+	return times, nil
+}
+
+// StealUsage is the steal time of a vCPU over an interval
+type StealUsage struct {
+	CPU     string  `json:"cpu"`
+	Percent float64 `json:"steal_percent"`
+}
+
+// ComputeSteal returns the share of time stolen from each vCPU between two
+// readings. vCPUs missing from one of them (hot-added) are left out.
+func ComputeSteal(prev, cur []CPUTimes) []StealUsage {
+	before := make(map[string]CPUTimes)
+	for _, t := range prev {
+		before[t.CPU] = t
+	}
+	var usage []StealUsage
+	for _, t := range cur {
+		p, ok := before[t.CPU]
+		if !ok || t.Total <= p.Total || t.Steal < p.Steal {
+			continue
+		}
+		usage = append(usage, StealUsage{CPU: t.CPU, Percent: float64(t.Steal-p.Steal) * 100 / float64(t.Total-p.Total)})
+	}
+	return usage
+}
+
+// StealReported tells whether the kernel counts steal time: a VMware guest
+// gets it from the paravirtual steal clock only (stealclock.enable in the
+// .vmx), without it the counters stay at 0 from boot
+func StealReported(times []CPUTimes) bool {
+	for _, t := range times {
+		if t.Steal > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// StealTuner reports the CPU steal time of the vCPUs
+type StealTuner struct {
+	FSRoot   string // "" for /
+	Interval time.Duration
+}
+
+// NewStealTuner creates a new steal time checker
+func NewStealTuner() *StealTuner {
+	return &StealTuner{Interval: 5 * time.Second}
+}
+
+func init() {
+	Register(Module{
+		Name:        "steal",
+		Label:       "CPU Steal Time",
+		Description: "Measure the CPU time the ESXi host takes from each vCPU",
+		Category:    CategoryDiagnostics,
+		Menu:        37,
+		ReadOnly:    true,
+		Run: func(ctx *ModuleContext) error {
+			return NewStealTuner().Run()
+		},
+	})
+}
+
+// Measure samples /proc/stat over the interval
+func (st *StealTuner) Measure() ([]StealUsage, error) {
+	prev, err := ReadCPUTimes(st.FSRoot)
+	if err != nil {
+		return nil, err
+	}
+	time.Sleep(st.Interval)
+	cur, err := ReadCPUTimes(st.FSRoot)
+	if err != nil {
+		return nil, err
+	}
+	return ComputeSteal(prev, cur), nil
+}
+
+// Run prints the steal time of each vCPU and flags host CPU contention
+func (st *StealTuner) Run() error {
+	PrintStep("CPU Steal Time")
+	PrintInfo("Sampling /proc/stat for %s...", FormatDuration(st.Interval))
+
+	usage, err := st.Measure()
+	if err != nil {
+		return err
+	}
+	times, err := ReadCPUTimes(st.FSRoot)
+	if err != nil {
+		return err
+	}
+	PrintStealUsage(usage, StealReported(times))
+	return nil
+}
+
+// PrintStealUsage prints the table and the verdict. Without steal reported
+// since boot a 0% says nothing of the host: no verdict is given.
+func PrintStealUsage(usage []StealUsage, reported bool) {
+	fmt.Printf("  %-8s %8s\n", "vCPU", "Steal")
+	var worst float64
+	var contended []string
+	for _, u := range usage {
+		mark := ""
+		switch {
+		case u.Percent >= stealCritPercent:
+			mark = "  <- contention"
+		case u.Percent >= stealWarnPercent:
+			mark = "  <- high"
+		}
+		fmt.Printf("  %-8s %7.1f%%%s\n", u.CPU, u.Percent, mark)
+		if u.CPU == "all" {
+			continue
+		}
+		if u.Percent > worst {
+			worst = u.Percent
+		}
+		if u.Percent >= stealWarnPercent {
+			contended = append(contended, u.CPU)
+		}
+	}
+	fmt.Println()
+
+	if !reported {
+		PrintWarning("Steal time is not reported: the counters of /proc/stat stayed at 0 since boot")
+		fmt.Println("    - the paravirtual steal clock is off: set stealclock.enable = \"TRUE\" in the .vmx (VM powered off)")
+		fmt.Println("    - until then, check the CPU Ready of the VM in vCenter or %RDY in esxtop")
+		return
+	}
+
+	switch {
+	case worst >= stealCritPercent:
+		PrintError("Host CPU contention: up to %.1f%% of the time stolen (%s)", worst, strings.Join(contended, ", "))
+	case worst >= stealWarnPercent:
+		PrintWarning("Host CPU contention building up: up to %.1f%% of the time stolen (%s)", worst, strings.Join(contended, ", "))
+	default:
+		PrintSuccess("No host CPU contention (steal under %.0f%% on every vCPU)", stealWarnPercent)
+		return
+	}
+	PrintInfo("The vCPUs were ready to run but the ESXi host did not schedule them:")
+	fmt.Println("    - check the host in esxtop (%RDY, %CSTP of the VM) and the CPU Ready of the VM in vCenter")
+	fmt.Println("    - an oversized VM waits for its vCPUs to be co-scheduled: remove the vCPUs it does not use")
+	fmt.Println("    - look for a CPU limit on the VM or its resource pool, raise its shares or reservation")
+	fmt.Println("    - move the VM (DRS, vMotion) to a less loaded host")
+}
@@ -0,0 +1,56 @@
+package tuner
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestComputeSteal(t *testing.T) {
+	root := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(root, "proc"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	write := func(content string) []CPUTimes {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(root, "proc/stat"), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		times, err := ReadCPUTimes(root)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return times
+	}
+
+	prev := write("cpu  200 0 100 1600 0 0 0 100 50 0\ncpu0 100 0 50 800 0 0 0 50 50 0\ncpu1 100 0 50 800 0 0 0 50 0 0\nintr 12345\nbtime 1700000000\n")
+	if len(prev) != 3 || prev[0].CPU != "all" || prev[1].Total != 1000 || prev[1].Steal != 50 {
+		t.Fatalf("times = %+v, guest time must not be counted twice", prev)
+	}
+	// cpu0: 10 of 100 jiffies stolen, cpu1: none
+	cur := write("cpu  300 0 100 1690 0 0 0 110 50 0\ncpu0 140 0 50 850 0 0 0 60 50 0\ncpu1 160 0 50 840 0 0 0 50 0 0\n")
+
+	usage := ComputeSteal(prev, cur)
+	want := map[string]float64{"all": 5, "cpu0": 10, "cpu1": 0}
+	if len(usage) != len(want) {
+		t.Fatalf("usage = %+v", usage)
+	}
+	for _, u := range usage {
+		if u.Percent != want[u.CPU] {
+			t.Errorf("%s steal = %.1f%%, want %.1f%%", u.CPU, u.Percent, want[u.CPU])
+		}
+	}
+
+	if !StealReported(cur) {
+		t.Error("steal counters moved but not reported")
+	}
+	// Without the paravirtual steal clock the counters stay at 0
+	if StealReported(write("cpu  300 0 100 1690 0 0 0 0 0 0\ncpu0 140 0 50 850 0 0 0 0 0 0\n")) {
+		t.Error("steal reported by counters at 0")
+	}
+
+	// A vCPU hot-added between the readings has no baseline
+	if usage := ComputeSteal(prev[:2], cur); len(usage) != 2 {
+		t.Errorf("usage with a new vCPU = %+v, want all and cpu0", usage)
+	}
+}
@@ -5,16 +5,46 @@ import (
 	"os"
 	"os/exec"
 	"strings"
+	"time"
 )
 
+// swapFileMB is the size of the swapfile created when none is active
+const swapFileMB = 2048
+
+// swapFilePath is the swapfile created by the module
+const swapFilePath = "/swapfile"
+
 // SwapTuner handles swap management
-type SwapTuner struct{}
+type SwapTuner struct {
+	DryRun bool
+}
 
 // NewSwapTuner creates a new swap tuner
 func NewSwapTuner() *SwapTuner {
 	return &SwapTuner{}
 }
 
+func init() {
+	Register(Module{
+		Name:        "swap",
+		Label:       "Manage Swap",
+		Description: "Swapfile (when no swap is active)",
+		Category:    CategoryTuning,
+		Menu:        13,
+		Pipeline:    8,
+		RequireRoot: true,
+		Flag:        &ModuleFlag{Name: "with-swap", Usage: "Create a swapfile when no swap is active"},
+		Run: func(ctx *ModuleContext) error {
+			return NewSwapTuner().Run()
+		},
+		Apply: func(ctx *ModuleContext) error {
+			swap := NewSwapTuner()
+			swap.DryRun = ctx.DryRun
+			return swap.Apply(ctx.Backup)
+		},
+	})
+}
+
 // Run performs the swap check and creation
 func (st *SwapTuner) Run() error {
 	PrintStep("Swap Manager")
@@ -30,9 +60,13 @@ func (st *SwapTuner) Run() error {
 
 	PrintWarning("No active swap detected!")
 	PrintInfo("Running without swap can cause the OOM Killer to crash applications.")
+	if err := CheckPreconditions("swap"); err != nil {
+		PrintWarning("Cannot create a swapfile: %v", err)
+		return nil
+	}
 	fmt.Println()
-	fmt.Print("Create a 2GB swapfile? (y/n): ")
-	
+	fmt.Printf("Create a %s swapfile? (y/n): ", FormatMiB(swapFileMB))
+
 	var response string
 	fmt.Scanln(&response)
 	if response != "y" && response != "yes" {
@@ -40,19 +74,52 @@ func (st *SwapTuner) Run() error {
 		return nil
 	}
 
-	swapFile := "/swapfile"
+	return st.createSwapFile(nil)
+}
+
+// Apply creates the swapfile without asking when no swap is active (--with-swap)
+func (st *SwapTuner) Apply(backup *BackupManager) error {
+	PrintStep("Swap")
+
+	if out, err := exec.Command("swapon", "--show").Output(); err == nil && len(out) > 0 {
+		PrintSuccess("Swap is already active")
+		return nil
+	}
+	if err := CheckPreconditions("swap"); err != nil {
+		return fmt.Errorf("cannot create a swapfile: %w", err)
+	}
+	if st.DryRun {
+		PrintInfo("Would create a %s swapfile at /swapfile and add it to /etc/fstab", FormatMiB(swapFileMB))
+		return nil
+	}
+	return st.createSwapFile(backup)
+}
+
+// createSwapFile creates, activates and persists /swapfile. The swapfile is
+// recorded as created and the fstab backed up first when a backup session is
+// given.
+func (st *SwapTuner) createSwapFile(backup *BackupManager) error {
+	swapFile := swapFilePath
+	if backup != nil {
+		if err := backup.BackupFile(swapFile); err != nil {
+			return fmt.Errorf("failed to record swapfile: %w", err)
+		}
+	}
 
 	// 2. Create file
-	PrintInfo("Creating 2GB swapfile at %s...", swapFile)
+	PrintInfo("Creating %s swapfile at %s...", FormatMiB(swapFileMB), swapFile)
+	start := time.Now()
 	// Try fallocate first (fast)
-	if err := exec.Command("fallocate", "-l", "2G", swapFile).Run(); err != nil {
+	if err := exec.Command("fallocate", "-l", fmt.Sprintf("%dM", swapFileMB), swapFile).Run(); err != nil {
 		PrintInfo("fallocate failed, trying dd...")
 		// dd if=/dev/zero of=/swapfile bs=1M count=2048
-		if err := exec.Command("dd", "if=/dev/zero", "of="+swapFile, "bs=1M", "count=2048").Run(); err != nil {
+		if err := exec.Command("dd", "if=/dev/zero", "of="+swapFile, "bs=1M", fmt.Sprintf("count=%d", swapFileMB)).Run(); err != nil {
 			return fmt.Errorf("failed to create swapfile: %w", err)
 		}
 	}
 
+	PrintInfo("Swapfile written in %s", FormatDuration(time.Since(start)))
+
 	// 3. Permissions
 	os.Chmod(swapFile, 0600)
 
@@ -71,10 +138,16 @@ func (st *SwapTuner) Run() error {
 	// 6. Persist in fstab
 	PrintInfo("Updating /etc/fstab...")
 	fstabEntry := fmt.Sprintf("%s none swap sw 0 0\n", swapFile)
-	
+
 	// Read fstab to check if already exists
 	content, _ := os.ReadFile("/etc/fstab")
 	if !strings.Contains(string(content), swapFile) {
+		if backup != nil {
+			if err := backup.BackupFile("/etc/fstab"); err != nil {
+				return fmt.Errorf("failed to backup fstab: %w", err)
+			}
+		}
+		ExplainEdit("activate the swapfile at every boot", "/etc/fstab")
 		f, err := os.OpenFile("/etc/fstab", os.O_APPEND|os.O_WRONLY, 0644)
 		if err != nil {
 			PrintWarning("Failed to open fstab: %v", err)
@@ -4,6 +4,9 @@ import (
 	"fmt"
 	"os"
 	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
 )
 
@@ -11,6 +14,7 @@ import (
 type SysctlTuner struct {
 	ConfigPath string
 	DryRun     bool
+	Size       VMSize // memory and vCPUs the values are scaled to
 }
 
 // NewSysctlTuner creates a new sysctl tuner
@@ -18,11 +22,165 @@ func NewSysctlTuner(dryRun bool) *SysctlTuner {
 	return &SysctlTuner{
 		ConfigPath: "/etc/sysctl.d/99-vmware-performance.conf",
 		DryRun:     dryRun,
+		Size:       DetectVMSize(),
 	}
 }
 
-// GetOptimalConfig returns the optimal sysctl configuration for VMware VMs
+func init() {
+	Register(Module{
+		Name:        "sysctl",
+		Description: "Sysctl kernel parameters",
+		Category:    CategoryTuning,
+		Pipeline:    2,
+		RequireRoot: true,
+		Flag:        &ModuleFlag{Name: "no-sysctl", Usage: "Skip sysctl parameter tuning", Negated: true},
+		Apply: func(ctx *ModuleContext) error {
+			return NewSysctlTuner(ctx.DryRun).Apply(ctx.Backup)
+		},
+		Show: func(ctx *ModuleContext) error {
+			return NewSysctlTuner(false).ShowCurrent()
+		},
+		Verify: func(ctx *ModuleContext) error {
+			return NewSysctlTuner(false).Verify()
+		},
+	})
+}
+
+// GetOptimalConfig returns the optimal sysctl configuration for VMware VMs:
+// the defaults scaled to the VM size, then the profile, then the
+// tuning.sysctl values of the config file. Excluded keys are commented out.
+// The scheduler knobs of wide VMs come with the sized values when the
+// profile asks for them.
 func (st *SysctlTuner) GetOptimalConfig() string {
+	p := Tuning.ActiveProfile()
+	content := strings.Replace(defaultSysctlConfig(), "# Generated by vmware-tuner\n",
+		"# Generated by vmware-tuner\n"+profileHeader+p.Name+"\n", 1)
+
+	// Each layer only writes the keys the next ones leave alone
+	above := Tuning.SysctlValues()
+	sized := make(map[string]string)
+	for key, value := range sizedOverrides(SizeSysctl(st.Size)) {
+		if _, set := above[key]; !set {
+			sized[key] = value
+		}
+	}
+	profileValues := make(map[string]string)
+	for key, value := range p.Sysctl {
+		if _, set := Tuning.Sysctl[key]; !set {
+			profileValues[key] = value
+		}
+	}
+	sched := make(map[string]string)
+	if p.Sched {
+		for key, value := range sizedOverrides(SchedSysctl("", st.Size)) {
+			if _, set := above[key]; !set {
+				sched[key] = value
+			}
+		}
+	}
+	content = applySysctlOverrides(content, sized, "VM sizing ("+st.Size.String()+")")
+	content = applySysctlOverrides(content, sched, "scheduler knobs, "+strconv.Itoa(st.Size.CPUs)+" vCPU")
+	content = applySysctlOverrides(content, profileValues, "profile "+p.Name)
+	content = applySysctlOverrides(content, Tuning.Sysctl, "config.yaml")
+	return excludeSysctlKeys(content, Tuning)
+}
+
+// adminSysctlFiles are the sysctl files an administrator (or tuned,
+// configuration management) maintains
+var adminSysctlFiles = []string{"/etc/sysctl.conf", "/etc/sysctl.d/*.conf", "/run/sysctl.d/*.conf"}
+
+// SysctlConflict is a key of the generated file set to another value elsewhere
+type SysctlConflict struct {
+	Key   string
+	File  string
+	Value string // value in File
+	Ours  string
+}
+
+// parseSysctlFile returns the key = value settings of a sysctl.d file
+func parseSysctlFile(content string) map[string]string {
+	values := make(map[string]string)
+	for _, line := range strings.Split(content, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") {
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			continue
+		}
+		// "-key = value" ignores errors, the key is the same
+		key = strings.TrimPrefix(strings.TrimSpace(key), "-")
+		values[key] = strings.Join(strings.Fields(value), " ")
+	}
+	return values
+}
+
+// FindSysctlConflicts lists the keys of content that other sysctl files set
+// to a different value. fsRoot allows running against a fixture tree.
+func (st *SysctlTuner) FindSysctlConflicts(fsRoot, content string) []SysctlConflict {
+	ours := parseSysctlFile(content)
+	var conflicts []SysctlConflict
+	for _, pattern := range adminSysctlFiles {
+		files, _ := filepath.Glob(filepath.Join(fsRoot, pattern))
+		for _, file := range files {
+			name := strings.TrimPrefix(file, fsRoot)
+			if name == st.ConfigPath {
+				continue
+			}
+			data, err := os.ReadFile(file)
+			if err != nil {
+				continue
+			}
+			theirs := parseSysctlFile(string(data))
+			for key, value := range theirs {
+				if want, ok := ours[key]; ok && want != value {
+					conflicts = append(conflicts, SysctlConflict{key, name, value, want})
+				}
+			}
+		}
+	}
+	sort.Slice(conflicts, func(a, b int) bool {
+		if conflicts[a].Key != conflicts[b].Key {
+			return conflicts[a].Key < conflicts[b].Key
+		}
+		return conflicts[a].File < conflicts[b].File
+	})
+	return conflicts
+}
+
+// reportExcludedSysctl reports the keys the exclusions file left out of the
+// configuration (commented out by excludeSysctlKeys)
+func reportExcludedSysctl(config string) {
+	for _, line := range strings.Split(config, "\n") {
+		if !strings.HasPrefix(line, "# ") {
+			continue
+		}
+		key, _, ok := strings.Cut(line[2:], "=")
+		key = strings.TrimSpace(key)
+		if ok && !strings.ContainsAny(key, " \t") && Excluded.Matches(ExcludeSysctl, key) {
+			SkipExcluded("sysctl", ExcludeSysctl, key)
+		}
+	}
+}
+
+// printSizing lists the values computed from the VM size
+func (st *SysctlTuner) printSizing(values []SizedValue) {
+	if len(values) == 0 {
+		PrintWarning("VM size unknown: fixed values used")
+		return
+	}
+	PrintInfo("Values scaled to this VM (%s):", st.Size)
+	for _, v := range values {
+		if Tuning.SysctlExcluded(v.Key) {
+			continue
+		}
+		fmt.Printf("  %-34s %-28s %s\n", v.Key, v.Value, v.Reason)
+	}
+}
+
+// defaultSysctlConfig returns the built-in sysctl configuration
+func defaultSysctlConfig() string {
 	return `# VMware VM Performance Tuning Configuration
 # Generated by vmware-tuner
 # Date: ` + getCurrentTimestamp() + `
@@ -123,6 +281,23 @@ func (st *SysctlTuner) Apply(backup *BackupManager) error {
 	}
 
 	config := st.GetOptimalConfig()
+	reportExcludedSysctl(config)
+	sized := SizeSysctl(st.Size)
+	st.printSizing(sized)
+	var sched []SizedValue
+	if Tuning.ActiveProfile().Sched {
+		sched = SchedSysctl("", st.Size)
+		st.printSched(sched)
+	}
+
+	// Two files setting the same key fight at every boot: the last one read wins
+	if conflicts := st.FindSysctlConflicts("", config); len(conflicts) > 0 {
+		PrintWarning("Keys also set by other sysctl files:")
+		for _, c := range conflicts {
+			fmt.Printf("  %s = %s in %s (this tool: %s)\n", c.Key, c.Value, c.File, c.Ours)
+		}
+		PrintInfo("List the keys managed elsewhere in tuning.sysctl_exclude to leave them alone")
+	}
 
 	if st.DryRun {
 		PrintInfo("Would create: %s", st.ConfigPath)
@@ -132,14 +307,26 @@ func (st *SysctlTuner) Apply(backup *BackupManager) error {
 	}
 
 	// Write configuration file
+	ExplainEdit("persist the kernel parameters so they are loaded at every boot", st.ConfigPath)
 	if err := os.WriteFile(st.ConfigPath, []byte(config), 0644); err != nil {
 		return fmt.Errorf("failed to write sysctl config: %w", err)
 	}
 
 	PrintSuccess("Created %s", st.ConfigPath)
 
+	// Keep the inputs of the computed values next to the backup
+	record := struct {
+		Size   VMSize       `json:"size"`
+		Values []SizedValue `json:"values"`
+		Sched  []SizedValue `json:"sched,omitempty"`
+	}{st.Size, sized, sched}
+	if err := backup.WriteRecord("sysctl-sizing.json", record); err != nil {
+		PrintWarning("Could not record the sysctl sizing: %v", err)
+	}
+
 	// Apply sysctl settings immediately
 	PrintInfo("Applying sysctl settings...")
+	ExplainCommand("load the new values now, without a reboot", "sysctl", "-p", st.ConfigPath)
 	cmd := exec.Command("sysctl", "-p", st.ConfigPath)
 	output, err := cmd.CombinedOutput()
 	if err != nil {
@@ -183,12 +370,138 @@ func (st *SysctlTuner) ShowCurrent() error {
 	return nil
 }
 
-// Verify checks if the sysctl configuration has been applied
+// sysctlDirs are read by systemd-sysctl and sysctl --system; a file in an
+// earlier directory hides the files of the same name in the later ones
+var sysctlDirs = []string{"/etc/sysctl.d", "/run/sysctl.d", "/usr/local/lib/sysctl.d", "/usr/lib/sysctl.d", "/lib/sysctl.d"}
+
+// SysctlCheck compares a key of the generated file with the running kernel
+type SysctlCheck struct {
+	Key          string
+	Expected     string
+	Actual       string
+	OK           bool
+	Unavailable  bool   // key unknown to this kernel (module not loaded)
+	OverriddenBy string // later sysctl file setting another value
+}
+
+// laterSysctlFiles returns the sysctl files applied after path, in order.
+// /etc/sysctl.conf comes last (sysctl --system).
+func laterSysctlFiles(fsRoot, path string) []string {
+	own := filepath.Base(path)
+	byName := make(map[string]string)
+	for _, dir := range sysctlDirs {
+		files, _ := filepath.Glob(filepath.Join(fsRoot, dir, "*.conf"))
+		for _, file := range files {
+			name := filepath.Base(file)
+			if _, hidden := byName[name]; !hidden {
+				byName[name] = strings.TrimPrefix(file, fsRoot)
+			}
+		}
+	}
+	var names []string
+	for name := range byName {
+		if name > own {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	var later []string
+	for _, name := range names {
+		later = append(later, byName[name])
+	}
+	if FileExists(filepath.Join(fsRoot, "/etc/sysctl.conf")) {
+		later = append(later, "/etc/sysctl.conf")
+	}
+	return later
+}
+
+// CheckRuntime compares every key of the generated file with /proc/sys.
+// fsRoot allows running against a fixture tree.
+func (st *SysctlTuner) CheckRuntime(fsRoot string) ([]SysctlCheck, error) {
+	data, err := os.ReadFile(filepath.Join(fsRoot, st.ConfigPath))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("configuration file not found: %s", st.ConfigPath)
+		}
+		return nil, fmt.Errorf("failed to read %s: %w", st.ConfigPath, err)
+	}
+	expected := parseSysctlFile(string(data))
+
+	// The last file setting a key wins
+	overrides := make(map[string]string)
+	values := make(map[string]string)
+	for _, file := range laterSysctlFiles(fsRoot, st.ConfigPath) {
+		content, err := os.ReadFile(filepath.Join(fsRoot, file))
+		if err != nil {
+			continue
+		}
+		for key, value := range parseSysctlFile(string(content)) {
+			overrides[key], values[key] = file, value
+		}
+	}
+
+	var keys []string
+	for key := range expected {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var checks []SysctlCheck
+	for _, key := range keys {
+		c := SysctlCheck{Key: key, Expected: expected[key]}
+		actual, err := readSysctlAt(fsRoot, key)
+		switch {
+		case err != nil:
+			c.Unavailable = true
+		default:
+			c.Actual = actual
+			c.OK = actual == c.Expected
+		}
+		if file, ok := overrides[key]; ok && values[key] != c.Expected {
+			c.OverriddenBy = file
+		}
+		checks = append(checks, c)
+	}
+	return checks, nil
+}
+
+// Verify checks that every value of the generated file is in effect
 func (st *SysctlTuner) Verify() error {
-	if _, err := os.Stat(st.ConfigPath); os.IsNotExist(err) {
-		return fmt.Errorf("configuration file not found: %s", st.ConfigPath)
+	checks, err := st.CheckRuntime(Sys.Root)
+	if err != nil {
+		return err
 	}
 
-	PrintSuccess("Sysctl configuration file exists")
+	var failed []string
+	inEffect, available := 0, 0
+	for _, c := range checks {
+		switch {
+		case c.Unavailable:
+			PrintInfo("%s: not available on this kernel (skipped)", c.Key)
+		case c.OK:
+			available++
+			inEffect++
+			PrintSuccess("%s = %s", c.Key, c.Actual)
+		default:
+			available++
+			detail := fmt.Sprintf("%s = %s (want %s)", c.Key, c.Actual, c.Expected)
+			if c.OverriddenBy != "" {
+				detail += ", overridden by " + c.OverriddenBy
+			}
+			PrintError("%s", detail)
+			failed = append(failed, detail)
+		}
+	}
+	for _, c := range checks {
+		if c.OK && c.OverriddenBy != "" {
+			PrintWarning("%s is set to another value in %s: it only holds until the next reload", c.Key, c.OverriddenBy)
+		}
+	}
+
+	if len(failed) > 0 {
+		return fmt.Errorf("%d of %d sysctl values not in effect: %s", len(failed), available, strings.Join(failed, "; "))
+	}
+	PrintSuccess("All %d sysctl values in effect", inEffect)
 	return nil
 }
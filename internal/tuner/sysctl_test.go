@@ -0,0 +1,61 @@
+package tuner
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// sysctlFixture writes the files of a fixture tree
+func sysctlFixture(t *testing.T, files map[string]string) string {
+	root := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return root
+}
+
+func TestCheckRuntime(t *testing.T) {
+	root := sysctlFixture(t, map[string]string{
+		"etc/sysctl.d/99-vmware-performance.conf": "vm.swappiness = 10\nnet.ipv4.tcp_rmem = 4096 87380 33554432\nvm.dirty_ratio = 10\nnet.netfilter.nf_conntrack_max = 262144\n",
+		"etc/sysctl.d/99-zz-admin.conf":           "vm.dirty_ratio = 40\n",
+		"usr/lib/sysctl.d/99-zz-admin.conf":       "vm.swappiness = 60\n", // hidden by the /etc file of the same name
+		"etc/sysctl.d/50-early.conf":              "vm.swappiness = 30\n", // applied before ours
+		"proc/sys/vm/swappiness":                  "10\n",
+		"proc/sys/vm/dirty_ratio":                 "40\n",
+		"proc/sys/net/ipv4/tcp_rmem":              "4096\t87380\t33554432\n",
+	})
+
+	checks, err := NewSysctlTuner(false).CheckRuntime(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]SysctlCheck{
+		"net.ipv4.tcp_rmem":              {Key: "net.ipv4.tcp_rmem", Expected: "4096 87380 33554432", Actual: "4096 87380 33554432", OK: true},
+		"net.netfilter.nf_conntrack_max": {Key: "net.netfilter.nf_conntrack_max", Expected: "262144", Unavailable: true},
+		"vm.dirty_ratio":                 {Key: "vm.dirty_ratio", Expected: "10", Actual: "40", OverriddenBy: "/etc/sysctl.d/99-zz-admin.conf"},
+		"vm.swappiness":                  {Key: "vm.swappiness", Expected: "10", Actual: "10", OK: true},
+	}
+	if len(checks) != len(want) {
+		t.Fatalf("got %d checks: %+v", len(checks), checks)
+	}
+	for _, c := range checks {
+		if c != want[c.Key] {
+			t.Errorf("%s: got %+v, want %+v", c.Key, c, want[c.Key])
+		}
+	}
+
+	item := auditSysctl(NewSysctlTuner(false), root)
+	if item.Status != AuditWarn || item.Points != 13 || len(item.Details) != 1 {
+		t.Errorf("unexpected audit item: %+v", item)
+	}
+	if item := auditSysctl(NewSysctlTuner(false), t.TempDir()); item.Points != 0 {
+		t.Errorf("missing file scored %d", item.Points)
+	}
+}
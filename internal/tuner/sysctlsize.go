@@ -0,0 +1,108 @@
+package tuner
+
+import (
+	"fmt"
+	"runtime"
+	"strconv"
+)
+
+// VMSize is the memory and CPU count the sysctl values are scaled to
+type VMSize struct {
+	MemoryMB  int64 `json:"memory_mb"`
+	CPUs      int   `json:"cpus"`
+	Conntrack bool  `json:"conntrack"` // nf_conntrack loaded
+}
+
+// DetectVMSize reads the size of this VM
+func DetectVMSize() VMSize {
+	total, _, _ := MemoryUsage()
+	return VMSize{
+		MemoryMB:  total,
+		CPUs:      runtime.NumCPU(),
+		Conntrack: FileExists("/proc/sys/net/netfilter/nf_conntrack_max"),
+	}
+}
+
+func (s VMSize) String() string {
+	return fmt.Sprintf("%s RAM, %d vCPU", FormatMiB(s.MemoryMB), s.CPUs)
+}
+
+// SizedValue is a sysctl value computed from the VM size
+type SizedValue struct {
+	Key    string `json:"key"`
+	Value  string `json:"value"`
+	Reason string `json:"reason"`
+}
+
+func clamp(v, lo, hi int64) int64 {
+	if v < lo {
+		return lo
+	}
+	if v > hi {
+		return hi
+	}
+	return v
+}
+
+// SizeSysctl computes the values that depend on the VM size. An unknown
+// size (0 MB) keeps the fixed defaults.
+func SizeSysctl(s VMSize) []SizedValue {
+	if s.MemoryMB <= 0 {
+		return nil
+	}
+	var values []SizedValue
+	add := func(key string, value int64, reason string) {
+		values = append(values, SizedValue{key, strconv.FormatInt(value, 10), reason})
+	}
+
+	// A fixed percentage of a large VM is gigabytes of dirty pages flushed at once
+	dirty, background := int64(15), int64(5)
+	switch {
+	case s.MemoryMB > 128<<10:
+		dirty, background = 3, 1
+	case s.MemoryMB > 32<<10:
+		dirty, background = 5, 2
+	case s.MemoryMB > 8<<10:
+		dirty, background = 10, 3
+	}
+	add("vm.dirty_ratio", dirty, "bounds the dirty pages of large VMs")
+	add("vm.dirty_background_ratio", background, "starts writeback early on large VMs")
+
+	// 1% of RAM kept free for atomic allocations (network bursts), within 16 MB - 1 GB
+	add("vm.min_free_kbytes", clamp(s.MemoryMB*1024/100, 16<<10, 1<<20), "1% of RAM, 16 MB to 1 GB")
+
+	// Socket buffers: 128 MB would be a large share of a small VM
+	bufMax := int64(128 << 20)
+	switch {
+	case s.MemoryMB <= 2<<10:
+		bufMax = 16 << 20
+	case s.MemoryMB <= 8<<10:
+		bufMax = 64 << 20
+	}
+	add("net.core.rmem_max", bufMax, "socket buffers scaled to RAM")
+	add("net.core.wmem_max", bufMax, "socket buffers scaled to RAM")
+	add("net.core.rmem_default", bufMax/8, "1/8 of the maximum")
+	add("net.core.wmem_default", bufMax/8, "1/8 of the maximum")
+	values = append(values,
+		SizedValue{"net.ipv4.tcp_rmem", fmt.Sprintf("4096 87380 %d", bufMax/2), "half of the socket maximum"},
+		SizedValue{"net.ipv4.tcp_wmem", fmt.Sprintf("4096 65536 %d", bufMax/2), "half of the socket maximum"},
+	)
+
+	// Each vCPU drains its own backlog
+	add("net.core.netdev_max_backlog", clamp(int64(s.CPUs)*2500, 5000, 65536), "2500 packets per vCPU")
+
+	if s.Conntrack {
+		// About 300 bytes per entry: 64 entries per MB stays under 2% of RAM
+		add("net.netfilter.nf_conntrack_max", clamp(s.MemoryMB*64, 65536, 4194304), "64 entries per MB of RAM")
+	}
+	return values
+}
+
+// sizedOverrides returns the sized values as overrides
+func sizedOverrides(values []SizedValue) map[string]string {
+	m := make(map[string]string)
+	for _, v := range values {
+		m[v.Key] = v.Value
+	}
+	return m
+}
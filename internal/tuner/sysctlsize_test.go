@@ -0,0 +1,53 @@
+package tuner
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSizeSysctl(t *testing.T) {
+	small := sizedOverrides(SizeSysctl(VMSize{MemoryMB: 2048, CPUs: 1}))
+	if small["net.core.rmem_max"] != "16777216" || small["vm.dirty_ratio"] != "15" {
+		t.Errorf("unexpected small VM values %v", small)
+	}
+	if small["vm.min_free_kbytes"] != "20971" || small["net.core.netdev_max_backlog"] != "5000" {
+		t.Errorf("unexpected small VM values %v", small)
+	}
+	if _, ok := small["net.netfilter.nf_conntrack_max"]; ok {
+		t.Error("nf_conntrack_max set without nf_conntrack")
+	}
+
+	large := sizedOverrides(SizeSysctl(VMSize{MemoryMB: 256 << 10, CPUs: 32, Conntrack: true}))
+	if large["vm.dirty_ratio"] != "3" || large["vm.min_free_kbytes"] != "1048576" {
+		t.Errorf("unexpected large VM values %v", large)
+	}
+	if large["net.core.netdev_max_backlog"] != "65536" || large["net.netfilter.nf_conntrack_max"] != "4194304" {
+		t.Errorf("unexpected large VM values %v", large)
+	}
+
+	if SizeSysctl(VMSize{}) != nil {
+		t.Error("unknown size should keep the defaults")
+	}
+}
+
+func TestGetOptimalConfigSizing(t *testing.T) {
+	old := Tuning
+	defer func() { Tuning = old }()
+	Tuning = TuningConfig{Profile: "database", Sysctl: map[string]string{"net.core.rmem_max": "33554432"}}
+
+	st := &SysctlTuner{Size: VMSize{MemoryMB: 64 << 10, CPUs: 8}}
+	content := st.GetOptimalConfig()
+	for _, line := range []string{
+		"vm.min_free_kbytes = 671088",  // sizing
+		"vm.dirty_ratio = 10",          // profile wins over sizing (5)
+		"net.core.rmem_max = 33554432", // config wins over sizing
+		"net.core.netdev_max_backlog = 20000",
+	} {
+		if !strings.Contains(content, line+"\n") {
+			t.Errorf("generated sysctl file misses %q", line)
+		}
+	}
+	if strings.Contains(content, "vm.dirty_ratio = 5\n") {
+		t.Error("sized value written over the profile")
+	}
+}
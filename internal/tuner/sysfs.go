@@ -0,0 +1,136 @@
+package tuner
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// SysFS reads the kernel interfaces (/proc, /sys) below a root directory:
+// "" for the running system, a tree captured from a guest in the fixture
+// tests (testdata/guests). Paths are given as on the guest.
+type SysFS struct {
+	Root string
+}
+
+// Sys is the tree read by the code without an FSRoot of its own. Tests point
+// it to a fixture tree.
+var Sys SysFS
+
+// Path returns where a guest path is below the root
+func (fs SysFS) Path(elem ...string) string {
+	return filepath.Join(append([]string{fs.Root}, elem...)...)
+}
+
+// ReadFile reads a guest file
+func (fs SysFS) ReadFile(elem ...string) ([]byte, error) {
+	return os.ReadFile(fs.Path(elem...))
+}
+
+// ReadString reads a guest file without its surrounding blanks, as sysfs
+// attributes are read
+func (fs SysFS) ReadString(elem ...string) (string, error) {
+	data, err := fs.ReadFile(elem...)
+	return strings.TrimSpace(string(data)), err
+}
+
+// ReadInt reads an integer attribute
+func (fs SysFS) ReadInt(elem ...string) (int, error) {
+	value, err := fs.ReadString(elem...)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(value)
+}
+
+// ReadDir lists a guest directory
+func (fs SysFS) ReadDir(elem ...string) ([]os.DirEntry, error) {
+	return os.ReadDir(fs.Path(elem...))
+}
+
+// Readlink reads a guest symlink (sysfs device and driver links)
+func (fs SysFS) Readlink(elem ...string) (string, error) {
+	return os.Readlink(fs.Path(elem...))
+}
+
+// Exists reports whether a guest path exists
+func (fs SysFS) Exists(elem ...string) bool {
+	return FileExists(fs.Path(elem...))
+}
+
+// Glob returns the guest paths matching a pattern, below the root like Path
+func (fs SysFS) Glob(pattern string) []string {
+	matches, _ := filepath.Glob(fs.Path(pattern))
+	return matches
+}
+
+// Cmdline returns the parameters the running kernel was booted with
+func (fs SysFS) Cmdline() (string, error) {
+	cmdline, err := fs.ReadString("/proc/cmdline")
+	if err != nil {
+		return "", fmt.Errorf("failed to read /proc/cmdline: %w", err)
+	}
+	return cmdline, nil
+}
+
+// NetDevices returns the interfaces of /sys/class/net, virtual ones included
+func (fs SysFS) NetDevices() ([]string, error) {
+	entries, err := fs.ReadDir("/sys/class/net")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read /sys/class/net: %w", err)
+	}
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	return names, nil
+}
+
+// BlockDevices returns the devices of /sys/block (disks, dm, loop...)
+func (fs SysFS) BlockDevices() ([]string, error) {
+	entries, err := fs.ReadDir("/sys/block")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read /sys/block: %w", err)
+	}
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	return names, nil
+}
+
+// MemInfo returns the values of /proc/meminfo as written: kB for the
+// sizes, a count for HugePages_Total and the like
+func (fs SysFS) MemInfo() (map[string]int64, error) {
+	data, err := fs.ReadFile("/proc/meminfo")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read /proc/meminfo: %w", err)
+	}
+	values := make(map[string]int64)
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 {
+			continue
+		}
+		if value, err := strconv.ParseInt(fields[1], 10, 64); err == nil {
+			values[strings.TrimSuffix(fields[0], ":")] = value
+		}
+	}
+	return values, nil
+}
+
+// MemInfoMB returns the sizes of /proc/meminfo in MiB, empty when it cannot
+// be read
+func (fs SysFS) MemInfoMB() map[string]int64 {
+	values, _ := fs.MemInfo()
+	mb := make(map[string]int64, len(values))
+	for key, kb := range values {
+		mb[key] = kb >> 10
+	}
+	return mb
+}
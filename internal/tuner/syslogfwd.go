@@ -0,0 +1,296 @@
+package tuner
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SyslogConfig is the central syslog server settings (syslog: section of the config)
+type SyslogConfig struct {
+	Server   string
+	Port     int
+	Protocol string // udp or tcp
+	TLS      bool
+	CAFile   string
+}
+
+// decode reads the syslog: section
+func (sc *SyslogConfig) decode(fields map[string]interface{}) error {
+	var err error
+	if sc.Server, err = yamlString(fields["server"]); err != nil {
+		return fmt.Errorf("server: %w", err)
+	}
+	if sc.Port, err = yamlInt(fields["port"]); err != nil {
+		return fmt.Errorf("port: %w", err)
+	}
+	if sc.Protocol, err = yamlString(fields["protocol"]); err != nil {
+		return fmt.Errorf("protocol: %w", err)
+	}
+	if sc.TLS, err = yamlBool(fields["tls"]); err != nil {
+		return fmt.Errorf("tls: %w", err)
+	}
+	if sc.CAFile, err = yamlString(fields["ca_file"]); err != nil {
+		return fmt.Errorf("ca_file: %w", err)
+	}
+
+	sc.Protocol = strings.ToLower(sc.Protocol)
+	switch sc.Protocol {
+	case "", "udp", "tcp":
+	default:
+		return fmt.Errorf("protocol: expected udp or tcp, got %q", sc.Protocol)
+	}
+	if sc.TLS && sc.Protocol == "udp" {
+		return fmt.Errorf("tls: TLS requires protocol tcp")
+	}
+	return nil
+}
+
+// withDefaults fills in the protocol and port (TLS implies tcp/6514)
+func (sc SyslogConfig) withDefaults() SyslogConfig {
+	if sc.Protocol == "" {
+		sc.Protocol = "udp"
+		if sc.TLS {
+			sc.Protocol = "tcp"
+		}
+	}
+	if sc.Port == 0 {
+		sc.Port = 514
+		if sc.TLS {
+			sc.Port = 6514
+		}
+	}
+	return sc
+}
+
+// SyslogForwardTuner configures rsyslog to forward logs to a central server
+type SyslogForwardTuner struct {
+	Distro       *DistroManager
+	Config       SyslogConfig
+	Backup       *BackupManager
+	RsyslogPath  string
+	JournaldPath string
+	DryRun       bool
+}
+
+// NewSyslogForwardTuner creates a new syslog forwarding tuner
+func NewSyslogForwardTuner(distro *DistroManager, cfg SyslogConfig, backup *BackupManager) *SyslogForwardTuner {
+	return &SyslogForwardTuner{
+		Distro:       distro,
+		Config:       cfg.withDefaults(),
+		Backup:       backup,
+		RsyslogPath:  "/etc/rsyslog.d/90-vmware-tuner-forward.conf",
+		JournaldPath: "/etc/systemd/journald.conf.d/vmware-tuner-forward.conf",
+	}
+}
+
+func init() {
+	Register(Module{
+		Name:        "syslog",
+		Label:       "Configure Syslog Forwarding",
+		Description: "Forward the logs to the syslog servers of the config file",
+		Category:    CategoryIntegration,
+		Menu:        21,
+		RequireRoot: true,
+		Distros:     packagedDistros,
+		Run: func(ctx *ModuleContext) error {
+			backup, err := ctx.BackupSession()
+			if err != nil {
+				return err
+			}
+			return NewSyslogForwardTuner(ctx.Distro, ctx.Config.Syslog, backup).Run(ctx.HasInternet)
+		},
+	})
+}
+
+// RsyslogConfig renders the rsyslog forwarding rule (disk-assisted queue so
+// messages survive a server outage)
+func (st *SyslogForwardTuner) RsyslogConfig() string {
+	cfg := st.Config
+	var sb strings.Builder
+
+	sb.WriteString("# Central log forwarding - Generated by vmware-tuner\n")
+	if cfg.TLS {
+		sb.WriteString("global(DefaultNetstreamDriver=\"gtls\"")
+		if cfg.CAFile != "" {
+			fmt.Fprintf(&sb, " DefaultNetstreamDriverCAFile=%q", cfg.CAFile)
+		}
+		sb.WriteString(")\n")
+	}
+
+	fmt.Fprintf(&sb, "*.* action(type=\"omfwd\" target=%q port=\"%d\" protocol=%q\n", cfg.Server, cfg.Port, cfg.Protocol)
+	if cfg.TLS {
+		fmt.Fprintf(&sb, "       StreamDriver=\"gtls\" StreamDriverMode=\"1\" StreamDriverAuthMode=\"x509/name\" StreamDriverPermittedPeers=%q\n", cfg.Server)
+	}
+	sb.WriteString("       queue.type=\"LinkedList\" queue.filename=\"vmware_tuner_fwd\" queue.maxDiskSpace=\"256m\"\n")
+	sb.WriteString("       queue.saveOnShutdown=\"on\" action.resumeRetryCount=\"-1\")\n")
+
+	return sb.String()
+}
+
+// Run installs rsyslog if needed, writes the forwarding rule and sends a test message
+func (st *SyslogForwardTuner) Run(hasInternet bool) error {
+	PrintStep("Central Syslog Forwarding")
+	if SkipExcluded("syslog", ExcludeService, "rsyslog") {
+		return nil
+	}
+
+	cfg := st.Config
+	if cfg.Server == "" {
+		return fmt.Errorf("no syslog server configured (set syslog.server in %s)", DefaultConfigPath)
+	}
+
+	mode := strings.ToUpper(cfg.Protocol)
+	if cfg.TLS {
+		mode += "+TLS"
+	}
+	PrintInfo("Target: %s:%d (%s)", cfg.Server, cfg.Port, mode)
+
+	if cfg.TLS && cfg.CAFile != "" && !FileExists(cfg.CAFile) {
+		return fmt.Errorf("CA file not found: %s", cfg.CAFile)
+	}
+
+	// Reachability (TCP only; UDP is connectionless)
+	if cfg.Protocol == "tcp" {
+		address := net.JoinHostPort(cfg.Server, strconv.Itoa(cfg.Port))
+		if conn, err := net.DialTimeout("tcp", address, 3*time.Second); err != nil {
+			PrintWarning("Server %s is not reachable: %v", address, err)
+			PrintInfo("Messages will be queued on disk until the server is available")
+		} else {
+			conn.Close()
+			PrintSuccess("Server %s is reachable", address)
+		}
+	}
+
+	packages := []string{"rsyslog"}
+	if cfg.TLS {
+		packages = append(packages, "rsyslog-gnutls")
+	}
+	if err := st.ensurePackages(packages, hasInternet); err != nil {
+		return err
+	}
+
+	if st.DryRun {
+		PrintInfo("Would create: %s", st.RsyslogPath)
+		fmt.Println(st.RsyslogConfig())
+		return nil
+	}
+
+	if err := st.writeRsyslogConfig(); err != nil {
+		return err
+	}
+
+	// On Debian-family systems rsyslog reads the journal through its syslog socket
+	if st.Distro.Type == DistroDebian {
+		if err := st.writeJournaldDropIn(); err != nil {
+			PrintWarning("%v", err)
+		}
+	}
+
+	if out, err := exec.Command("systemctl", "enable", "--now", "rsyslog").CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to enable rsyslog: %v: %s", err, string(out))
+	}
+	if out, err := exec.Command("systemctl", "restart", "rsyslog").CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to restart rsyslog: %v: %s", err, string(out))
+	}
+	PrintSuccess("rsyslog restarted with forwarding to %s", cfg.Server)
+
+	return st.SendTestMessage()
+}
+
+// ensurePackages installs the missing rsyslog packages (online or from --pkg-dir)
+func (st *SyslogForwardTuner) ensurePackages(packages []string, hasInternet bool) error {
+	for _, pkg := range packages {
+		if isPackageInstalled(st.Distro, pkg) {
+			continue
+		}
+		if !st.Distro.HasPackageSource(pkg, hasInternet) {
+			return fmt.Errorf("package %s is not installed and no repository is reachable (see --pkg-dir): %w", pkg, ErrOffline)
+		}
+		if st.DryRun {
+			PrintInfo("Would install %s", pkg)
+			continue
+		}
+		if err := st.Distro.InstallPackage(pkg); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeRsyslogConfig writes the forwarding rule and validates it with rsyslogd -N1.
+// An invalid configuration is reverted before rsyslog is restarted.
+func (st *SyslogForwardTuner) writeRsyslogConfig() error {
+	previous, readErr := os.ReadFile(st.RsyslogPath)
+
+	if err := st.Backup.BackupFile(st.RsyslogPath); err != nil {
+		return fmt.Errorf("failed to backup %s: %w", st.RsyslogPath, err)
+	}
+	if err := os.WriteFile(st.RsyslogPath, []byte(st.RsyslogConfig()), 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", st.RsyslogPath, err)
+	}
+
+	if out, err := exec.Command("rsyslogd", "-N1").CombinedOutput(); err != nil {
+		if readErr == nil {
+			os.WriteFile(st.RsyslogPath, previous, 0644)
+		} else {
+			os.Remove(st.RsyslogPath)
+		}
+		return fmt.Errorf("%w: rsyslog rejected the configuration (reverted): %s", ErrValidationFailed, strings.TrimSpace(string(out)))
+	}
+
+	PrintSuccess("Created %s", st.RsyslogPath)
+	return nil
+}
+
+// writeJournaldDropIn makes sure journald hands messages to rsyslog
+func (st *SyslogForwardTuner) writeJournaldDropIn() error {
+	content := "# Central log forwarding - Generated by vmware-tuner\n[Journal]\nForwardToSyslog=yes\n"
+
+	if err := st.Backup.BackupFile(st.JournaldPath); err != nil {
+		return fmt.Errorf("failed to backup %s: %w", st.JournaldPath, err)
+	}
+	if err := os.MkdirAll(filepath.Dir(st.JournaldPath), 0755); err != nil {
+		return fmt.Errorf("failed to create %s: %w", filepath.Dir(st.JournaldPath), err)
+	}
+	if err := os.WriteFile(st.JournaldPath, []byte(content), 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", st.JournaldPath, err)
+	}
+	exec.Command("systemctl", "restart", "systemd-journald").Run()
+
+	PrintSuccess("journald forwards to syslog (%s)", st.JournaldPath)
+	return nil
+}
+
+// SendTestMessage logs a tagged message to verify the forwarding on the server side
+func (st *SyslogForwardTuner) SendTestMessage() error {
+	hostname, _ := os.Hostname()
+	message := fmt.Sprintf("vmware-tuner forwarding test from %s at %s", hostname, time.Now().Format(time.RFC3339))
+
+	if out, err := exec.Command("logger", "-t", JournalIdentifier, message).CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to send test message: %v: %s", err, string(out))
+	}
+
+	PrintSuccess("Test message sent")
+	PrintInfo("Search for it on %s: \"%s\"", st.Config.Server, message)
+	return nil
+}
+
+// isPackageInstalled queries the package database
+func isPackageInstalled(distro *DistroManager, pkg string) bool {
+	switch distro.Type {
+	case DistroDebian:
+		out, err := exec.Command("dpkg-query", "-W", "-f=${Status}", pkg).Output()
+		return err == nil && strings.Contains(string(out), "install ok installed")
+	case DistroRHEL:
+		return exec.Command("rpm", "-q", pkg).Run() == nil
+	default:
+		_, err := exec.LookPath(pkg)
+		return err == nil
+	}
+}
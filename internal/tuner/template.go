@@ -14,6 +14,20 @@ func NewTemplateTuner() *TemplateTuner {
 	return &TemplateTuner{}
 }
 
+func init() {
+	Register(Module{
+		Name:        "template",
+		Label:       "Seal VM for Template (Expert)",
+		Description: "Clear the identity of the VM before converting it to a template",
+		Category:    CategoryExpert,
+		Menu:        11,
+		RequireRoot: true,
+		Run: func(ctx *ModuleContext) error {
+			return NewTemplateTuner().Run()
+		},
+	})
+}
+
 // Run performs the sealing process
 func (tt *TemplateTuner) Run() error {
 	PrintStep("Seal VM for Template")
@@ -23,11 +37,15 @@ func (tt *TemplateTuner) Run() error {
 	PrintWarning("The VM will be shut down immediately after.")
 	PrintWarning("DO NOT RUN THIS if you are not creating a template/golden image.")
 	fmt.Println()
-	
+
+	if err := GuardDestructive("Seal VM for Template"); err != nil {
+		return err
+	}
+
 	fmt.Print("Type 'SEAL' to continue: ")
 	var response string
 	fmt.Scanln(&response)
-	
+
 	if response != "SEAL" {
 		PrintInfo("Operation cancelled (Safety check failed)")
 		return nil
@@ -65,7 +83,7 @@ func (tt *TemplateTuner) Run() error {
 
 	PrintSuccess("System sealed successfully!")
 	PrintInfo("Shutting down in 3 seconds...")
-	
+
 	exec.Command("sleep", "3").Run()
 	exec.Command("poweroff").Run()
 
@@ -0,0 +1,136 @@
+package tuner
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/fatih/color"
+)
+
+// Theme is the set of markers used by the Print functions
+type Theme struct {
+	Name     string
+	Success  string
+	Error    string
+	Warning  string
+	Info     string
+	Step     string
+	Rule     string
+	Banner   string
+	ASCII    bool // strip emoji and symbols from messages
+	Contrast bool // bold default colors instead of hues
+}
+
+const defaultBanner = `
+╔══════════════════════════════════════════════════════════╗
+║                                                          ║
+║           VMware VM Performance Tuner                    ║
+║                                                          ║
+║   Optimisé pour Environnements Enterprise (Air-Gapped)   ║
+║                                                          ║
+╚══════════════════════════════════════════════════════════╝
+`
+
+const asciiBanner = `
++==========================================================+
+|                                                          |
+|           VMware VM Performance Tuner                    |
+|                                                          |
+|   Optimise pour Environnements Enterprise (Air-Gapped)   |
+|                                                          |
++==========================================================+
+`
+
+// Themes are the available output themes. ascii suits terminals and log
+// collectors that mangle Unicode; high-contrast also drops the hues.
+var Themes = map[string]Theme{
+	"default": {
+		Name: "default", Success: "✓ ", Error: "✗ ", Warning: "⚠ ", Info: "ℹ ", Step: "▶ ",
+		Rule:   "────────────────────────────────────────────────────────",
+		Banner: defaultBanner,
+	},
+	"ascii": {
+		Name: "ascii", Success: "[OK] ", Error: "[FAIL] ", Warning: "[WARN] ", Info: "[INFO] ", Step: "==> ",
+		Rule:   "--------------------------------------------------------",
+		Banner: asciiBanner, ASCII: true,
+	},
+	"high-contrast": {
+		Name: "high-contrast", Success: "[OK] ", Error: "[FAIL] ", Warning: "[WARN] ", Info: "[INFO] ", Step: "==> ",
+		Rule:   "--------------------------------------------------------",
+		Banner: asciiBanner, ASCII: true, Contrast: true,
+	},
+}
+
+// theme is the active theme (SetTheme)
+var theme = Themes["default"]
+
+// ThemeNames returns the theme names, sorted
+func ThemeNames() []string {
+	var names []string
+	for name := range Themes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// SetTheme activates a theme ("" keeps the current one)
+func SetTheme(name string) error {
+	if name == "" {
+		return nil
+	}
+	t, ok := Themes[name]
+	if !ok {
+		return fmt.Errorf("unknown theme %q (available: %s)", name, strings.Join(ThemeNames(), ", "))
+	}
+	theme = t
+
+	colorSuccess, colorError, colorWarning, colorInfo, colorStep = defaultColors[0], defaultColors[1], defaultColors[2], defaultColors[3], defaultColors[4]
+	if t.Contrast {
+		// Hues like cyan and yellow wash out on light or projected screens
+		bold := color.New(color.Bold)
+		colorSuccess, colorError, colorWarning, colorInfo, colorStep = bold, color.New(color.Bold, color.ReverseVideo), bold, bold, bold
+	}
+	return nil
+}
+
+// defaultColors are the colors of the default themes
+var defaultColors = []*color.Color{colorSuccess, colorError, colorWarning, colorInfo, colorStep}
+
+// themed strips the emoji and symbols of a message in the ASCII themes
+// (accented letters are kept)
+func themed(s string) string {
+	if !theme.ASCII {
+		return s
+	}
+	stripped := strings.Map(func(r rune) rune {
+		if r == '\uFE0F' || unicode.Is(unicode.So, r) {
+			return -1
+		}
+		return r
+	}, s)
+	if stripped == s {
+		return s
+	}
+	// "⚠️  DANGER ZONE ⚠️" leaves the spacing of the removed symbols
+	return strings.TrimSpace(strings.ReplaceAll(stripped, "  ", " "))
+}
+
+// UIConfig holds the display settings (ui: section of the config)
+type UIConfig struct {
+	Theme string
+}
+
+// decode reads the ui: section
+func (uc *UIConfig) decode(fields map[string]interface{}) error {
+	var err error
+	if uc.Theme, err = yamlString(fields["theme"]); err != nil {
+		return fmt.Errorf("theme: %w", err)
+	}
+	if _, ok := Themes[uc.Theme]; uc.Theme != "" && !ok {
+		return fmt.Errorf("theme: unknown theme %q (available: %s)", uc.Theme, strings.Join(ThemeNames(), ", "))
+	}
+	return nil
+}
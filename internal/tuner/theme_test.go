@@ -0,0 +1,50 @@
+package tuner
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestASCIITheme(t *testing.T) {
+	defer SetTheme("default")
+	if err := SetTheme("ascii"); err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(t.TempDir(), "out.txt")
+	captureOutput(path, func() error {
+		PrintStep("Audit")
+		PrintSuccess("System is fully optimized! 🚀")
+		PrintWarning("⚠️  DANGER ZONE ⚠️")
+		PrintInfo("Opérations terminées: %d", 3)
+		return nil
+	})
+	data, _ := os.ReadFile(path)
+	got := string(data)
+
+	for _, want := range []string{"==> Audit\n", "[OK] System is fully optimized!\n", "[WARN] DANGER ZONE\n", "[INFO] Opérations terminées: 3\n"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("output missing %q:\n%s", want, got)
+		}
+	}
+	for _, r := range got {
+		if r > 0x7f && !strings.ContainsRune("é", r) {
+			t.Errorf("non-ASCII %q in output:\n%s", r, got)
+			break
+		}
+	}
+
+	if err := SetTheme("solarized"); err == nil {
+		t.Error("unknown theme accepted")
+	}
+}
+
+func TestDefaultThemeKeepsMessages(t *testing.T) {
+	msg := fmt.Sprintf("System is fully optimized! %s", "🚀")
+	if got := themed(msg); got != msg {
+		t.Errorf("default theme changed %q to %q", msg, got)
+	}
+}
@@ -8,6 +8,8 @@ import (
 // TimeSyncTuner handles time synchronization
 type TimeSyncTuner struct {
 	Distro *DistroManager
+	DryRun bool
+	Backup *BackupManager // records the state of chronyd before it is enabled
 }
 
 // NewTimeSyncTuner creates a new time sync tuner
@@ -17,38 +19,40 @@ func NewTimeSyncTuner(distro *DistroManager) *TimeSyncTuner {
 	}
 }
 
+func init() {
+	Register(Module{
+		Name:        "timesync",
+		Label:       "Fix Time Sync",
+		Description: "Time synchronization",
+		Category:    CategoryTuning,
+		Menu:        5,
+		Pipeline:    9,
+		RequireRoot: true,
+		Flag:        &ModuleFlag{Name: "with-timesync", Usage: "Make sure the clock is synchronized (NTP, or VMware Tools host sync offline)"},
+		Run: func(ctx *ModuleContext) error {
+			backup, err := ctx.BackupSession()
+			if err != nil {
+				return err
+			}
+			timesync := NewTimeSyncTuner(ctx.Distro)
+			timesync.Backup = backup
+			return timesync.Run(ctx.HasInternet)
+		},
+		Apply: func(ctx *ModuleContext) error {
+			timesync := NewTimeSyncTuner(ctx.Distro)
+			timesync.DryRun, timesync.Backup = ctx.DryRun, ctx.Backup
+			return timesync.Apply(ctx.HasInternet)
+		},
+	})
+}
+
 // Run performs the time sync check and fix
 func (t *TimeSyncTuner) Run(hasInternet bool) error {
 	PrintStep("Time Synchronization Doctor")
 
 	// 1. Check for existing NTP services
-	services := []string{"chronyd", "ntp", "systemd-timesyncd"}
-	activeService := ""
-
-	for _, svc := range services {
-		cmd := exec.Command("systemctl", "is-active", svc)
-		if err := cmd.Run(); err == nil {
-			activeService = svc
-			break
-		}
-	}
-
-	if activeService != "" {
-		PrintSuccess("Time synchronization is active via: %s", activeService)
-
-		// Force sync
-		PrintInfo("Forcing time synchronization...")
-		if activeService == "chronyd" {
-			exec.Command("chronyc", "makestep").Run()
-		} else if activeService == "systemd-timesyncd" {
-			// systemd-timesyncd doesn't have a simple force command, restart triggers it
-			exec.Command("systemctl", "restart", "systemd-timesyncd").Run()
-		}
-
-		// Ensure VMware Tools sync is disabled to avoid conflict
-		PrintInfo("Disabling VMware Tools periodic time sync (best practice with NTP)...")
-		exec.Command("vmware-toolbox-cmd", "timesync", "disable").Run()
-
+	if activeService := activeTimeService(); activeService != "" {
+		t.syncActive(activeService)
 		return nil
 	}
 
@@ -58,7 +62,8 @@ func (t *TimeSyncTuner) Run(hasInternet bool) error {
 	fmt.Println()
 	fmt.Println("Options:")
 
-	if hasInternet {
+	canInstall := t.Distro.HasPackageSource("chrony", hasInternet)
+	if canInstall {
 		fmt.Println("  [1] Install/Enable Chrony (Recommended)")
 	} else {
 		// Greyed out or hidden
@@ -72,7 +77,7 @@ func (t *TimeSyncTuner) Run(hasInternet bool) error {
 	fmt.Scanln(&choice)
 
 	if choice == "1" {
-		if !hasInternet {
+		if !canInstall {
 			PrintWarning("Cannot install Chrony in offline mode. Please use VMware Tools Sync.")
 			return nil
 		}
@@ -83,17 +88,109 @@ func (t *TimeSyncTuner) Run(hasInternet bool) error {
 		if err := t.Distro.InstallPackage(pkg); err != nil {
 			return err
 		}
-		exec.Command("systemctl", "enable", "--now", "chronyd").Run()
-		exec.Command("chronyc", "makestep").Run()
-		PrintSuccess("Chrony installed and synchronized")
+		t.enableChrony()
 	} else if choice == "2" {
-		if err := exec.Command("vmware-toolbox-cmd", "timesync", "enable").Run(); err != nil {
-			return fmt.Errorf("failed to enable vmtools sync: %v", err)
-		}
-		PrintSuccess("VMware Tools Host Sync enabled")
+		return t.enableToolsSync()
 	} else {
 		PrintInfo("Skipping time sync")
 	}
 
 	return nil
 }
+
+// Apply makes sure the clock is synchronized without asking (--with-timesync):
+// an active NTP service is kept, otherwise chrony is installed when a package
+// source is available, with VMware Tools host sync as the fallback
+func (t *TimeSyncTuner) Apply(hasInternet bool) error {
+	PrintStep("Time Synchronization")
+
+	if activeService := activeTimeService(); activeService != "" {
+		if t.DryRun {
+			PrintSuccess("Time synchronization is active via: %s", activeService)
+			PrintInfo("Would disable VMware Tools periodic time sync")
+			return nil
+		}
+		t.syncActive(activeService)
+		return nil
+	}
+
+	PrintWarning("No active NTP service found")
+	if t.Distro.HasPackageSource("chrony", hasInternet) {
+		if t.DryRun {
+			PrintInfo("Would install and enable chrony")
+			return nil
+		}
+		if err := t.Distro.InstallPackage("chrony"); err != nil {
+			return err
+		}
+		t.enableChrony()
+		return nil
+	}
+
+	PrintInfo("chrony cannot be installed (offline, no --pkg-dir): using VMware Tools host sync")
+	if t.DryRun {
+		PrintInfo("Would enable VMware Tools host sync")
+		return nil
+	}
+	return t.enableToolsSync()
+}
+
+// activeTimeService returns the running NTP service, "" when none
+func activeTimeService() string {
+	for _, svc := range []string{"chronyd", "ntp", "systemd-timesyncd"} {
+		if err := exec.Command("systemctl", "is-active", svc).Run(); err == nil {
+			return svc
+		}
+	}
+	return ""
+}
+
+// syncActive forces a sync with the active NTP service and turns off the
+// periodic VMware Tools sync that would fight it
+func (t *TimeSyncTuner) syncActive(activeService string) {
+	PrintSuccess("Time synchronization is active via: %s", activeService)
+
+	// Force sync
+	PrintInfo("Forcing time synchronization...")
+	if activeService == "chronyd" {
+		exec.Command("chronyc", "makestep").Run()
+	} else if activeService == "systemd-timesyncd" && !SkipExcluded("timesync", ExcludeService, activeService) {
+		// systemd-timesyncd doesn't have a simple force command, restart triggers it
+		exec.Command("systemctl", "restart", "systemd-timesyncd").Run()
+	}
+
+	// Ensure VMware Tools sync is disabled to avoid conflict
+	PrintInfo("Disabling VMware Tools periodic time sync (best practice with NTP)...")
+	ExplainCommand("two clock sources fight each other: NTP keeps the clock", "vmware-toolbox-cmd", "timesync", "disable")
+	exec.Command("vmware-toolbox-cmd", "timesync", "disable").Run()
+
+	if hasPrecisionClock() && activeService == "chronyd" {
+		PrintInfo("VMware Precision Clock detected: add 'refclock PHC /dev/ptp0 poll 3 dpoll -2' to chrony.conf for sub-millisecond accuracy")
+	}
+}
+
+// enableChrony starts chrony and steps the clock
+func (t *TimeSyncTuner) enableChrony() {
+	if SkipExcluded("timesync", ExcludeService, "chronyd") {
+		return
+	}
+	if t.Backup != nil {
+		if err := t.Backup.BackupServices([]string{"chronyd"}); err != nil {
+			PrintWarning("Failed to record the chronyd state (rollback will not restore it): %v", err)
+		}
+	}
+	ExplainCommand("synchronize the clock with NTP at every boot", "systemctl", "enable", "--now", "chronyd")
+	exec.Command("systemctl", "enable", "--now", "chronyd").Run()
+	exec.Command("chronyc", "makestep").Run()
+	PrintSuccess("Chrony installed and synchronized")
+}
+
+// enableToolsSync lets VMware Tools keep the clock in line with the ESXi host
+func (t *TimeSyncTuner) enableToolsSync() error {
+	ExplainCommand("no NTP service: follow the ESXi host clock", "vmware-toolbox-cmd", "timesync", "enable")
+	if err := exec.Command("vmware-toolbox-cmd", "timesync", "enable").Run(); err != nil {
+		return fmt.Errorf("failed to enable vmtools sync: %v", err)
+	}
+	PrintSuccess("VMware Tools Host Sync enabled")
+	return nil
+}
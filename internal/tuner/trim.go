@@ -0,0 +1,86 @@
+package tuner
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// TrimTuner enables the weekly fstrim timer so thin-provisioned disks give
+// freed blocks back to the datastore. A periodic trim is preferred over the
+// discard mount option, which slows down every delete.
+type TrimTuner struct {
+	DryRun bool
+	FSRoot string // "" for /
+}
+
+// NewTrimTuner creates a new trim tuner
+func NewTrimTuner(dryRun bool) *TrimTuner {
+	return &TrimTuner{DryRun: dryRun}
+}
+
+func init() {
+	Register(Module{
+		Name:        "trim",
+		Description: "Periodic TRIM (fstrim.timer)",
+		Category:    CategoryTuning,
+		Pipeline:    10,
+		RequireRoot: true,
+		Flag:        &ModuleFlag{Name: "with-trim", Usage: "Enable the weekly fstrim timer on disks that support discard"},
+		Apply: func(ctx *ModuleContext) error {
+			return NewTrimTuner(ctx.DryRun).Apply(ctx.Backup)
+		},
+	})
+}
+
+// DiscardDisks returns the disks that accept discard requests (thin VMDKs on
+// VMFS 6 or vSAN, virtual hardware 11+)
+func (tt *TrimTuner) DiscardDisks() []string {
+	sys := SysFS{Root: tt.FSRoot}
+	devices, _ := sys.BlockDevices()
+	var disks []string
+	for _, name := range devices {
+		if strings.HasPrefix(name, "loop") || strings.HasPrefix(name, "ram") || strings.HasPrefix(name, "sr") {
+			continue
+		}
+		value, err := sys.ReadString("/sys/block", name, "queue", "discard_max_bytes")
+		if err == nil && value != "0" {
+			disks = append(disks, name)
+		}
+	}
+	return disks
+}
+
+// Apply enables fstrim.timer when a disk supports discard (--with-trim)
+func (tt *TrimTuner) Apply(backup *BackupManager) error {
+	PrintStep("Periodic TRIM")
+
+	disks := tt.DiscardDisks()
+	if len(disks) == 0 {
+		PrintWarning("No disk accepts discard requests (thick VMDK, VMFS 5 or virtual hardware < 11): nothing to trim")
+		return nil
+	}
+	PrintInfo("Disks with discard support: %s", strings.Join(disks, ", "))
+
+	if err := exec.Command("systemctl", "is-enabled", "fstrim.timer").Run(); err == nil {
+		PrintSuccess("fstrim.timer is already enabled")
+		return nil
+	}
+	if SkipExcluded("trim", ExcludeService, "fstrim.timer") {
+		return nil
+	}
+	if tt.DryRun {
+		PrintInfo("Would run: systemctl enable --now fstrim.timer")
+		return nil
+	}
+
+	if err := backup.BackupServices([]string{"fstrim.timer"}); err != nil {
+		return fmt.Errorf("failed to record the fstrim.timer state: %w", err)
+	}
+	ExplainCommand("return the blocks freed by deletes to the datastore once a week", "systemctl", "enable", "--now", "fstrim.timer")
+	if out, err := exec.Command("systemctl", "enable", "--now", "fstrim.timer").CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to enable fstrim.timer: %v: %s", err, strings.TrimSpace(string(out)))
+	}
+	PrintSuccess("fstrim.timer enabled (weekly)")
+	return nil
+}
@@ -0,0 +1,22 @@
+package tuner
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestDiscardDisks(t *testing.T) {
+	root := t.TempDir()
+	for name, max := range map[string]string{"sda": "4294966784", "sdb": "0", "loop0": "4096", "nvme0n1": "2199023255040"} {
+		queue := filepath.Join(root, "sys", "block", name, "queue")
+		os.MkdirAll(queue, 0755)
+		os.WriteFile(filepath.Join(queue, "discard_max_bytes"), []byte(max+"\n"), 0644)
+	}
+
+	tt := &TrimTuner{FSRoot: root}
+	if disks, want := tt.DiscardDisks(), []string{"nvme0n1", "sda"}; !reflect.DeepEqual(disks, want) {
+		t.Errorf("DiscardDisks() = %v, want %v", disks, want)
+	}
+}
@@ -0,0 +1,627 @@
+package tuner
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// tuned profiles activated by the tuned module (tuning.tuned_profile)
+const (
+	TunedVirtualGuest  = "virtual-guest" // shipped with tuned: throughput-performance with less swapping
+	TunedCustomProfile = "vmware-tuner"  // virtual-guest without the settings vmware-tuner owns
+)
+
+// TunedProfiles lists the profiles the tuned module activates, the default first
+var TunedProfiles = []string{TunedVirtualGuest, TunedCustomProfile}
+
+// tunedProfileDirs are searched in order for a profile, as tuned does: the
+// administrator's profiles first
+var tunedProfileDirs = []string{"/etc/tuned/profiles", "/etc/tuned", "/usr/lib/tuned/profiles", "/usr/lib/tuned"}
+
+// tuned state files, under /etc/tuned and /run/tuned
+const (
+	tunedActiveProfilePath = "/etc/tuned/active_profile"
+	tunedProfileModePath   = "/etc/tuned/profile_mode"
+	tunedPIDPath           = "/run/tuned/tuned.pid"
+)
+
+// ValidTunedProfile checks a tuning.tuned_profile value
+func ValidTunedProfile(name string) error {
+	for _, p := range TunedProfiles {
+		if name == p {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: unknown tuned profile %q (%s)", ErrValidationFailed, name, strings.Join(TunedProfiles, ", "))
+}
+
+// TunedProfile is a tuned profile with its includes resolved: the options of
+// each plugin section ([sysctl], [vm], [disk]...)
+type TunedProfile struct {
+	Name     string
+	Files    []string // tuned.conf files read, the profile first
+	Sections map[string]map[string]string
+}
+
+// TunedStatus is what tuned does on the system
+type TunedStatus struct {
+	Installed     bool
+	Running       bool
+	Active        string // active profile(s), "" when none
+	Mode          string // auto (recommended profile) or manual
+	ReapplySysctl bool   // sysctl.d files loaded again after the profile
+	Profile       *TunedProfile
+	ProfileErr    error
+}
+
+// TunedConflict is a setting of the tuned profile that a vmware-tuner module
+// also sets to another value
+type TunedConflict struct {
+	Section   string
+	Key       string
+	Tuned     string
+	Ours      string
+	Owner     string // vmware-tuner file or module owning the setting
+	TunedWins bool   // tuned applies its value after ours
+}
+
+// TunedTuner installs tuned and activates a profile reconciled with the
+// sysctl file (--with-tuned, RHEL family)
+type TunedTuner struct {
+	DryRun       bool
+	Distro       *DistroManager
+	Profile      string
+	ProfilePath  string // tuned.conf of the vmware-tuner profile
+	MainConfPath string
+	FSRoot       string // "" for /
+}
+
+// NewTunedTuner creates a new tuned tuner for the profile of the config file
+func NewTunedTuner(dryRun bool, distro *DistroManager) *TunedTuner {
+	profile := Tuning.TunedProfile
+	if profile == "" {
+		profile = TunedVirtualGuest
+	}
+	return &TunedTuner{
+		DryRun:       dryRun,
+		Distro:       distro,
+		Profile:      profile,
+		ProfilePath:  filepath.Join("/etc/tuned", TunedCustomProfile, "tuned.conf"),
+		MainConfPath: "/etc/tuned/tuned-main.conf",
+	}
+}
+
+func init() {
+	Register(Module{
+		Name:        "tuned",
+		Description: "tuned daemon with the virtual-guest or vmware-tuner profile (tuning.tuned_profile)",
+		Category:    CategoryTuning,
+		Pipeline:    16,
+		RequireRoot: true,
+		Distros:     []DistroType{DistroRHEL},
+		Flag:        &ModuleFlag{Name: "with-tuned", Usage: "Install tuned and activate virtual-guest, or the vmware-tuner profile (tuning.tuned_profile)"},
+		Apply: func(ctx *ModuleContext) error {
+			return NewTunedTuner(ctx.DryRun, ctx.Distro).Apply(ctx.Backup, ctx.HasInternet)
+		},
+		Show: func(ctx *ModuleContext) error {
+			return NewTunedTuner(false, ctx.Distro).ShowCurrent()
+		},
+		Verify: func(ctx *ModuleContext) error {
+			return NewTunedTuner(false, ctx.Distro).Verify()
+		},
+	})
+}
+
+// parseTunedConf reads the sections of a tuned.conf file (INI, # comments)
+func parseTunedConf(content string) map[string]map[string]string {
+	sections := make(map[string]map[string]string)
+	var current map[string]string
+	scanner := bufio.NewScanner(strings.NewReader(content))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") {
+			continue
+		}
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			name := strings.TrimSpace(line[1 : len(line)-1])
+			if sections[name] == nil {
+				sections[name] = make(map[string]string)
+			}
+			current = sections[name]
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if !ok || current == nil {
+			continue
+		}
+		current[strings.TrimSpace(key)] = strings.Join(strings.Fields(value), " ")
+	}
+	return sections
+}
+
+// findTunedProfile returns the tuned.conf of a profile, "" when not found
+func findTunedProfile(fsRoot, name string) string {
+	for _, dir := range tunedProfileDirs {
+		path := filepath.Join(dir, name, "tuned.conf")
+		if FileExists(filepath.Join(fsRoot, path)) {
+			return path
+		}
+	}
+	return ""
+}
+
+// LoadTunedProfile reads a profile and the profiles it includes. As in tuned,
+// the options of the profile win over the included ones, replace=1 drops the
+// included section and enabled=false disables the plugin. name may list
+// several profiles, as active_profile does: the last one wins.
+func LoadTunedProfile(fsRoot, name string) (*TunedProfile, error) {
+	p := &TunedProfile{Name: name, Sections: make(map[string]map[string]string)}
+	profiles := strings.Fields(name)
+	for i := len(profiles) - 1; i >= 0; i-- {
+		if err := p.load(fsRoot, profiles[i], 0); err != nil {
+			return nil, err
+		}
+	}
+	for section, options := range p.Sections {
+		if options["enabled"] == "false" {
+			delete(p.Sections, section)
+		}
+	}
+	return p, nil
+}
+
+// load merges a profile below the options already read
+func (p *TunedProfile) load(fsRoot, name string, depth int) error {
+	if depth > 10 {
+		return fmt.Errorf("tuned profile %s: too many include levels", name)
+	}
+	path := findTunedProfile(fsRoot, name)
+	if path == "" {
+		return fmt.Errorf("tuned profile %s not found", name)
+	}
+	data, err := os.ReadFile(filepath.Join(fsRoot, path))
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	p.Files = append(p.Files, path)
+	sections := parseTunedConf(string(data))
+
+	// Included profiles are read after: options already set are kept
+	var includes []string
+	if main := sections["main"]; main != nil {
+		for _, include := range strings.Split(main["include"], ",") {
+			if include = strings.TrimSpace(include); include != "" {
+				includes = append(includes, include)
+			}
+		}
+	}
+	for section, options := range sections {
+		if section == "main" {
+			continue
+		}
+		merged := p.Sections[section]
+		if merged == nil {
+			merged = make(map[string]string)
+			p.Sections[section] = merged
+		}
+		if merged["replace"] == "1" {
+			continue
+		}
+		for key, value := range options {
+			if _, set := merged[key]; !set {
+				merged[key] = value
+			}
+		}
+	}
+	// A profile lists its includes by increasing priority: the last one wins
+	for i := len(includes) - 1; i >= 0; i-- {
+		if err := p.load(fsRoot, includes[i], depth+1); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ReadTunedStatus reads the state of tuned and its active profile
+func ReadTunedStatus(fsRoot string) TunedStatus {
+	fs := SysFS{Root: fsRoot}
+	st := TunedStatus{ReapplySysctl: true}
+	st.Installed = fs.Exists("/usr/sbin/tuned") || fs.Exists("/usr/lib/tuned")
+	if !st.Installed {
+		return st
+	}
+	st.Running = fs.Exists(tunedPIDPath)
+	st.Active, _ = fs.ReadString(tunedActiveProfilePath)
+	st.Mode, _ = fs.ReadString(tunedProfileModePath)
+	if data, err := fs.ReadFile("/etc/tuned/tuned-main.conf"); err == nil {
+		if value, ok := parseTunedConf("[main]\n" + string(data))["main"]["reapply_sysctl"]; ok {
+			st.ReapplySysctl = tunedBool(value)
+		}
+	}
+	if st.Active != "" {
+		st.Profile, st.ProfileErr = LoadTunedProfile(fsRoot, st.Active)
+	}
+	return st
+}
+
+// tunedBool reads a boolean option of tuned
+func tunedBool(value string) bool {
+	switch strings.ToLower(value) {
+	case "1", "true", "yes", "y", "on":
+		return true
+	}
+	return false
+}
+
+// wantedTHP returns the transparent_hugepage mode set on the kernel command
+// line by the grub module (expert parameters win), "" when none
+func wantedTHP() string {
+	mode := ""
+	params := append((&GrubTuner{}).VMwareBootParams(), Tuning.ExpertBoot...)
+	for _, param := range params {
+		if strings.HasPrefix(param, "transparent_hugepage=") {
+			mode = strings.TrimPrefix(param, "transparent_hugepage=")
+		}
+	}
+	return mode
+}
+
+// tunedReadAheadKB reads the readahead option of the disk plugin: KiB, or
+// 512-byte sectors with an s suffix. A leading > only raises the value.
+func tunedReadAheadKB(value string) (int, bool, error) {
+	value = strings.TrimSpace(value)
+	raiseOnly := strings.HasPrefix(value, ">")
+	value = strings.TrimSpace(strings.TrimPrefix(value, ">"))
+	sectors := strings.HasSuffix(value, "s")
+	n, err := strconv.Atoi(strings.TrimSpace(strings.TrimSuffix(value, "s")))
+	if err != nil {
+		return 0, false, err
+	}
+	if sectors {
+		n /= 2
+	}
+	return n, raiseOnly, nil
+}
+
+// TunedConflicts lists the settings of a profile that fight the ones of
+// vmware-tuner: sysctl keys of sysctlConfig, transparent hugepages of the
+// kernel command line and the read-ahead of the queue module. The sysctl.d
+// files win when tuned reapplies them; tuned sets the others after boot.
+func TunedConflicts(p *TunedProfile, sysctlConfig, sysctlPath string, reapplySysctl bool) []TunedConflict {
+	if p == nil {
+		return nil
+	}
+	var conflicts []TunedConflict
+	ours := parseSysctlFile(sysctlConfig)
+	for key, value := range p.Sections["sysctl"] {
+		if key == "replace" || key == "enabled" || key == "type" || strings.Contains(value, "${") {
+			continue
+		}
+		if want, ok := ours[key]; ok && want != value {
+			conflicts = append(conflicts, TunedConflict{"sysctl", key, value, want, sysctlPath, !reapplySysctl})
+		}
+	}
+
+	if thp := wantedTHP(); thp != "" {
+		for _, key := range []string{"transparent_hugepages", "transparent_hugepage"} {
+			if value, ok := p.Sections["vm"][key]; ok && value != thp {
+				conflicts = append(conflicts, TunedConflict{"vm", key, value, thp, "transparent_hugepage= (grub module)", true})
+			}
+		}
+	}
+
+	if value, ok := p.Sections["disk"]["readahead"]; ok {
+		want := Tuning.QueueSettings().ReadAheadKB
+		kb, raiseOnly, err := tunedReadAheadKB(value)
+		if err == nil && kb != want && !(raiseOnly && want >= kb) {
+			conflicts = append(conflicts, TunedConflict{"disk", "readahead", value, strconv.Itoa(want), "read_ahead_kb (queue module)", true})
+		}
+	}
+
+	sort.Slice(conflicts, func(a, b int) bool {
+		if conflicts[a].Section != conflicts[b].Section {
+			return conflicts[a].Section < conflicts[b].Section
+		}
+		return conflicts[a].Key < conflicts[b].Key
+	})
+	return conflicts
+}
+
+// CustomProfileContent returns the vmware-tuner profile: virtual-guest with
+// the plugins whose settings vmware-tuner owns disabled, so the two never
+// fight over a value
+func (tt *TunedTuner) CustomProfileContent() string {
+	return fmt.Sprintf(`# Generated by vmware-tuner: virtual-guest without the settings vmware-tuner owns
+[main]
+summary=virtual-guest reconciled with vmware-tuner
+include=%s
+
+# Kernel parameters: %s
+[sysctl]
+enabled=false
+
+# Transparent hugepages: transparent_hugepage= on the kernel command line
+[vm]
+enabled=false
+
+# Elevator and read-ahead: the scheduler and queue modules (udev rules)
+[disk]
+enabled=false
+`, TunedVirtualGuest, NewSysctlTuner(true).ConfigPath)
+}
+
+// status reads tuned below FSRoot
+func (tt *TunedTuner) status() TunedStatus {
+	return ReadTunedStatus(tt.FSRoot)
+}
+
+// conflicts returns the conflicts of the active profile with our settings
+func (tt *TunedTuner) conflicts(st TunedStatus) []TunedConflict {
+	sysctl := NewSysctlTuner(true)
+	return TunedConflicts(st.Profile, sysctl.GetOptimalConfig(), sysctl.ConfigPath, st.ReapplySysctl)
+}
+
+// setTunedMainOption sets an option of tuned-main.conf, in place of the
+// line (commented out or not) already there
+func setTunedMainOption(content, key, value string) string {
+	line := key + " = " + value
+	lines := strings.Split(strings.TrimRight(content, "\n"), "\n")
+	for i, l := range lines {
+		name, _, ok := strings.Cut(strings.TrimLeft(strings.TrimSpace(l), "#; "), "=")
+		if ok && strings.TrimSpace(name) == key {
+			lines[i] = line
+			return strings.Join(lines, "\n") + "\n"
+		}
+	}
+	if len(lines) == 1 && lines[0] == "" {
+		return line + "\n"
+	}
+	return strings.Join(append(lines, line), "\n") + "\n"
+}
+
+// Apply installs tuned, writes the vmware-tuner profile when selected, makes
+// tuned load the sysctl.d files after its profile and activates the profile
+func (tt *TunedTuner) Apply(backup *BackupManager, hasInternet bool) error {
+	PrintStep("Tuned Profile (" + tt.Profile + ")")
+	if err := ValidTunedProfile(tt.Profile); err != nil {
+		return err
+	}
+	if SkipExcluded("tuned", ExcludeService, "tuned") {
+		return nil
+	}
+
+	if !isPackageInstalled(tt.Distro, "tuned") {
+		if !tt.Distro.HasPackageSource("tuned", hasInternet) {
+			return fmt.Errorf("tuned is not installed and no repository is reachable (see --pkg-dir): %w", ErrOffline)
+		}
+		if tt.DryRun {
+			PrintInfo("Would install: tuned")
+		} else if err := tt.Distro.InstallPackage("tuned"); err != nil {
+			return err
+		}
+	}
+
+	// The daemon reads its profile at tuned-adm profile and tuned-main.conf
+	// at start only: a changed file is not used until then
+	profileChanged := false
+	if tt.Profile == TunedCustomProfile {
+		changed, err := tt.writeFile(backup, tt.ProfilePath, tt.CustomProfileContent(),
+			"virtual-guest without the sysctl, hugepage and disk settings vmware-tuner owns")
+		if err != nil {
+			return err
+		}
+		profileChanged = changed
+	}
+
+	current, _ := os.ReadFile(tt.MainConfPath)
+	mainChanged, err := tt.writeFile(backup, tt.MainConfPath, setTunedMainOption(string(current), "reapply_sysctl", "1"),
+		"load the sysctl.d files after the profile: our sysctl file wins over it")
+	if err != nil {
+		return err
+	}
+
+	st := tt.status()
+	restart := st.Running && mainChanged
+	if st.Running && st.Active == tt.Profile && st.Mode == "manual" && !profileChanged && !mainChanged {
+		PrintSuccess("tuned is running with the %s profile", tt.Profile)
+	} else if tt.DryRun {
+		PrintInfo("Would run: systemctl enable --now tuned")
+		if restart {
+			PrintInfo("Would run: systemctl restart tuned")
+		}
+		PrintInfo("Would run: tuned-adm profile %s", tt.Profile)
+	} else {
+		if err := backup.BackupFile(tunedActiveProfilePath); err != nil {
+			return fmt.Errorf("failed to backup %s: %w", tunedActiveProfilePath, err)
+		}
+		if err := backup.BackupFile(tunedProfileModePath); err != nil {
+			return fmt.Errorf("failed to backup %s: %w", tunedProfileModePath, err)
+		}
+		if err := backup.BackupServices([]string{"tuned"}); err != nil {
+			return fmt.Errorf("failed to record the tuned service: %w", err)
+		}
+		ExplainCommand("start tuned at boot: it applies the profile to every new device", "systemctl", "enable", "--now", "tuned")
+		if out, err := exec.Command("systemctl", "enable", "--now", "tuned").CombinedOutput(); err != nil {
+			return fmt.Errorf("failed to enable tuned: %v: %s", err, strings.TrimSpace(string(out)))
+		}
+		if restart {
+			ExplainCommand("reload tuned-main.conf: the running daemon read it at start", "systemctl", "restart", "tuned")
+			if out, err := exec.Command("systemctl", "restart", "tuned").CombinedOutput(); err != nil {
+				return fmt.Errorf("failed to restart tuned: %v: %s", err, strings.TrimSpace(string(out)))
+			}
+		}
+		ExplainCommand("activate the profile and keep it (manual mode)", "tuned-adm", "profile", tt.Profile)
+		if out, err := exec.Command("tuned-adm", "profile", tt.Profile).CombinedOutput(); err != nil {
+			return fmt.Errorf("failed to activate the tuned profile %s: %v: %s", tt.Profile, err, strings.TrimSpace(string(out)))
+		}
+		PrintSuccess("tuned profile %s active", tt.Profile)
+		st = tt.status()
+	}
+
+	// In a dry run the profile is not active yet: report what it would do.
+	// The vmware-tuner profile leaves the conflicting plugins disabled.
+	if tt.DryRun {
+		if tt.Profile == TunedCustomProfile {
+			return nil
+		}
+		st.ReapplySysctl = true
+		st.Profile, st.ProfileErr = LoadTunedProfile(tt.FSRoot, tt.Profile)
+	}
+	tt.reportConflicts(st)
+	return nil
+}
+
+// writeFile writes a file when its content changes, with a backup, and
+// tells whether it changed (or would in a dry run)
+func (tt *TunedTuner) writeFile(backup *BackupManager, path, content, why string) (bool, error) {
+	if current, err := os.ReadFile(path); err == nil && string(current) == content {
+		PrintSuccess("%s already configured", path)
+		return false, nil
+	}
+	if tt.DryRun {
+		PrintInfo("Would write: %s", path)
+		fmt.Print(content)
+		return true, nil
+	}
+	if err := backup.BackupFile(path); err != nil {
+		return false, fmt.Errorf("failed to backup %s: %w", path, err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return false, fmt.Errorf("failed to create %s: %w", filepath.Dir(path), err)
+	}
+	ExplainEdit(why, path)
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		return false, fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	PrintSuccess("Updated %s", path)
+	return true, nil
+}
+
+// reportConflicts prints the settings tuned and vmware-tuner both set
+func (tt *TunedTuner) reportConflicts(st TunedStatus) {
+	if st.ProfileErr != nil {
+		PrintWarning("Cannot read the tuned profile: %v", st.ProfileErr)
+		return
+	}
+	conflicts := tt.conflicts(st)
+	if len(conflicts) == 0 {
+		PrintSuccess("No setting of the tuned profile conflicts with vmware-tuner")
+		return
+	}
+	for _, c := range conflicts {
+		if c.TunedWins {
+			PrintWarning("[%s] %s = %s overrides %s from %s", c.Section, c.Key, c.Tuned, c.Ours, c.Owner)
+		} else {
+			PrintInfo("[%s] %s = %s: %s from %s is loaded after it", c.Section, c.Key, c.Tuned, c.Ours, c.Owner)
+		}
+	}
+	if tt.Profile != TunedCustomProfile {
+		PrintInfo("tuning.tuned_profile: %s keeps tuned away from the settings vmware-tuner owns", TunedCustomProfile)
+	}
+}
+
+// Verify checks that tuned runs the profile and does not override our settings
+func (tt *TunedTuner) Verify() error {
+	st := tt.status()
+	switch {
+	case !st.Installed:
+		return fmt.Errorf("%w: tuned is not installed", ErrVerifySkipped)
+	case !st.Running:
+		return fmt.Errorf("tuned is not running")
+	case st.Active != tt.Profile:
+		return fmt.Errorf("tuned profile is %q instead of %s", st.Active, tt.Profile)
+	case !st.ReapplySysctl:
+		return fmt.Errorf("reapply_sysctl is off in %s: the tuned profile overrides the sysctl.d files", tt.MainConfPath)
+	case st.ProfileErr != nil:
+		return st.ProfileErr
+	}
+	var overridden []string
+	for _, c := range tt.conflicts(st) {
+		if c.TunedWins {
+			overridden = append(overridden, fmt.Sprintf("%s = %s (want %s)", c.Key, c.Tuned, c.Ours))
+		}
+	}
+	if len(overridden) > 0 {
+		return fmt.Errorf("tuned profile %s overrides %s", st.Active, strings.Join(overridden, ", "))
+	}
+	PrintSuccess("tuned: %s profile active, no conflict with vmware-tuner", st.Active)
+	return nil
+}
+
+// ShowCurrent prints the tuned state and the conflicts of its profile
+func (tt *TunedTuner) ShowCurrent() error {
+	PrintStep("Tuned Profile")
+	st := tt.status()
+	if !st.Installed {
+		PrintInfo("tuned is not installed")
+		return nil
+	}
+	state := "stopped"
+	if st.Running {
+		state = "running"
+	}
+	active := st.Active
+	if active == "" {
+		active = "none"
+	}
+	fmt.Printf("  %-16s %s\n", "Service:", state)
+	fmt.Printf("  %-16s %s\n", "Active profile:", active)
+	if st.Mode != "" {
+		fmt.Printf("  %-16s %s\n", "Mode:", st.Mode)
+	}
+	fmt.Printf("  %-16s %v\n", "Reapply sysctl:", st.ReapplySysctl)
+	if st.Profile != nil {
+		fmt.Printf("  %-16s %s\n", "Profile files:", strings.Join(st.Profile.Files, ", "))
+	}
+	if st.Active != "" {
+		tt.reportConflicts(st)
+	}
+	PrintInfo("Selected: %s (tuning.tuned_profile)", tt.Profile)
+	return nil
+}
+
+// auditTuned reports the tuned profile. Not scored: tuned is optional.
+func auditTuned(st TunedStatus) AuditItem {
+	item := AuditItem{Name: "tuned"}
+	switch {
+	case !st.Installed:
+		item.Status, item.Message = AuditOK, "tuned not installed"
+		return item
+	case !st.Running:
+		item.Status, item.Message = AuditOK, "tuned installed, not running"
+		return item
+	case st.Active == "":
+		item.Status, item.Message = AuditWarn, "tuned running without a profile"
+		return item
+	case st.ProfileErr != nil:
+		item.Status, item.Message = AuditWarn, fmt.Sprintf("tuned profile %s: %v", st.Active, st.ProfileErr)
+		return item
+	}
+
+	sysctl := NewSysctlTuner(true)
+	var overridden int
+	for _, c := range TunedConflicts(st.Profile, sysctl.GetOptimalConfig(), sysctl.ConfigPath, st.ReapplySysctl) {
+		if !c.TunedWins {
+			continue
+		}
+		overridden++
+		item.Details = append(item.Details, fmt.Sprintf("- [%s] %s = %s (want %s, %s)", c.Section, c.Key, c.Tuned, c.Ours, c.Owner))
+	}
+	if overridden == 0 {
+		item.Status, item.Message = AuditOK, "tuned profile: "+st.Active
+		return item
+	}
+	item.Status = AuditWarn
+	item.Message = fmt.Sprintf("tuned profile %s overrides %d vmware-tuner settings", st.Active, overridden)
+	if !st.ReapplySysctl {
+		item.Details = append(item.Details, "Recommendation: reapply_sysctl = 1 in /etc/tuned/tuned-main.conf")
+	}
+	item.Details = append(item.Details, "Recommendation: tuning.tuned_profile: "+TunedCustomProfile+" (vmware-tuner --with-tuned)")
+	return item
+}
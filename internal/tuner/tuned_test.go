@@ -0,0 +1,194 @@
+package tuner
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// writeTunedProfile writes a tuned.conf below root
+func writeTunedProfile(t *testing.T, root, dir, name, content string) {
+	t.Helper()
+	path := filepath.Join(root, dir, name, "tuned.conf")
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLoadTunedProfileIncludes(t *testing.T) {
+	root := t.TempDir()
+	writeTunedProfile(t, root, "/usr/lib/tuned", "throughput-performance",
+		"[main]\nsummary=base\n[vm]\ntransparent_hugepages=always\n[disk]\nreadahead=>4096\n[sysctl]\nvm.dirty_ratio = 40\nvm.swappiness=10\n")
+	writeTunedProfile(t, root, "/usr/lib/tuned", "virtual-guest",
+		"[main]\ninclude=throughput-performance\n[sysctl]\nvm.dirty_ratio = 30\nvm.swappiness = 30\n")
+	// The administrator's copy in /etc/tuned wins over the shipped one
+	writeTunedProfile(t, root, "/etc/tuned", "virtual-guest",
+		"[main]\ninclude=throughput-performance\n[sysctl]\nreplace=1\nkernel.numa_balancing = 0\n[disk]\nenabled=false\n")
+	writeTunedProfile(t, root, "/usr/lib/tuned", "sap", "[main]\n[vm]\ntransparent_hugepages=never\n")
+
+	p, err := LoadTunedProfile(root, "virtual-guest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"/etc/tuned/virtual-guest/tuned.conf", "/usr/lib/tuned/throughput-performance/tuned.conf"}; !reflect.DeepEqual(p.Files, want) {
+		t.Errorf("files = %v, want %v", p.Files, want)
+	}
+	if want := map[string]string{"replace": "1", "kernel.numa_balancing": "0"}; !reflect.DeepEqual(p.Sections["sysctl"], want) {
+		t.Errorf("sysctl = %v, want %v (replace=1 drops the included keys)", p.Sections["sysctl"], want)
+	}
+	if _, ok := p.Sections["disk"]; ok {
+		t.Errorf("disk plugin disabled but kept: %v", p.Sections["disk"])
+	}
+	if got := p.Sections["vm"]["transparent_hugepages"]; got != "always" {
+		t.Errorf("vm.transparent_hugepages = %q, want the included value", got)
+	}
+
+	// Several active profiles: the last one wins
+	p, err = LoadTunedProfile(root, "virtual-guest sap")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := p.Sections["vm"]["transparent_hugepages"]; got != "never" {
+		t.Errorf("vm.transparent_hugepages = %q, want never", got)
+	}
+
+	if _, err := LoadTunedProfile(root, "missing"); err == nil {
+		t.Error("missing profile loaded")
+	}
+	writeTunedProfile(t, root, "/etc/tuned", "loop", "[main]\ninclude=loop\n")
+	if _, err := LoadTunedProfile(root, "loop"); err == nil {
+		t.Error("include loop loaded")
+	}
+}
+
+func TestTunedConflicts(t *testing.T) {
+	defer func(tc TuningConfig) { Tuning = tc }(Tuning)
+	Tuning = TuningConfig{}
+	p := &TunedProfile{Sections: map[string]map[string]string{
+		"sysctl": {"vm.swappiness": "30", "vm.dirty_ratio": "15", "kernel.sched_latency_ns": "${f:cpus}"},
+		"vm":     {"transparent_hugepages": "always"},
+		"disk":   {"readahead": ">128"},
+	}}
+	sysctl := "vm.swappiness = 10\nvm.dirty_ratio = 15\nkernel.sched_latency_ns = 1\n"
+
+	conflicts := TunedConflicts(p, sysctl, "/etc/sysctl.d/99-vmware-performance.conf", true)
+	want := []TunedConflict{
+		{"sysctl", "vm.swappiness", "30", "10", "/etc/sysctl.d/99-vmware-performance.conf", false},
+		{"vm", "transparent_hugepages", "always", "madvise", "transparent_hugepage= (grub module)", true},
+	}
+	if !reflect.DeepEqual(conflicts, want) {
+		t.Errorf("conflicts = %+v\nwant %+v", conflicts, want)
+	}
+
+	// Without reapply_sysctl the profile is loaded last
+	conflicts = TunedConflicts(p, sysctl, "/etc/sysctl.d/99-vmware-performance.conf", false)
+	if len(conflicts) != 2 || !conflicts[0].TunedWins {
+		t.Errorf("reapply_sysctl off: %+v", conflicts)
+	}
+
+	// The read-ahead is only raised by ">": a smaller value is kept
+	p.Sections["disk"]["readahead"] = ">4096"
+	conflicts = TunedConflicts(p, sysctl, "", true)
+	if len(conflicts) != 3 || conflicts[0].Key != "readahead" {
+		t.Errorf("readahead >4096: %+v", conflicts)
+	}
+	p.Sections["disk"]["readahead"] = "512s"
+	if conflicts = TunedConflicts(p, sysctl, "", true); len(conflicts) != 2 {
+		t.Errorf("readahead 512s (256 KiB) reported: %+v", conflicts)
+	}
+}
+
+func TestTunedCustomProfile(t *testing.T) {
+	root := t.TempDir()
+	writeTunedProfile(t, root, "/usr/lib/tuned", "throughput-performance",
+		"[main]\n[cpu]\ngovernor=performance\n[vm]\ntransparent_hugepages=always\n[disk]\nreadahead=>4096\n[sysctl]\nvm.dirty_ratio = 40\n")
+	writeTunedProfile(t, root, "/usr/lib/tuned", "virtual-guest", "[main]\ninclude=throughput-performance\n[sysctl]\nvm.swappiness = 30\n")
+	tt := NewTunedTuner(true, nil)
+	writeTunedProfile(t, root, "/etc/tuned", TunedCustomProfile, tt.CustomProfileContent())
+
+	p, err := LoadTunedProfile(root, TunedCustomProfile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := map[string]map[string]string{"cpu": {"governor": "performance"}}; !reflect.DeepEqual(p.Sections, want) {
+		t.Errorf("sections = %v, want only the cpu plugin of virtual-guest", p.Sections)
+	}
+	if conflicts := TunedConflicts(p, "vm.swappiness = 10\nvm.dirty_ratio = 15\n", "", false); len(conflicts) != 0 {
+		t.Errorf("conflicts = %+v", conflicts)
+	}
+}
+
+func TestReadTunedStatus(t *testing.T) {
+	root := t.TempDir()
+	if st := ReadTunedStatus(root); st.Installed || auditTuned(st).Status != AuditOK {
+		t.Errorf("not installed: %+v", st)
+	}
+
+	writeTunedProfile(t, root, "/usr/lib/tuned", "virtual-guest", "[main]\n[sysctl]\nvm.swappiness = 30\n")
+	files := map[string]string{
+		tunedActiveProfilePath:       "virtual-guest\n",
+		tunedProfileModePath:         "manual\n",
+		"/etc/tuned/tuned-main.conf": "daemon = 1\n# Whether to reapply sysctl\nreapply_sysctl = 0\n",
+		tunedPIDPath:                 "812\n",
+	}
+	for path, content := range files {
+		os.MkdirAll(filepath.Join(root, filepath.Dir(path)), 0755)
+		if err := os.WriteFile(filepath.Join(root, path), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	st := ReadTunedStatus(root)
+	if !st.Installed || !st.Running || st.Active != "virtual-guest" || st.Mode != "manual" || st.ReapplySysctl || st.ProfileErr != nil {
+		t.Fatalf("status = %+v", st)
+	}
+
+	tt := NewTunedTuner(false, nil)
+	tt.FSRoot = root
+	if err := tt.Verify(); err == nil || !strings.Contains(err.Error(), "reapply_sysctl") {
+		t.Errorf("verify with reapply_sysctl = 0: %v", err)
+	}
+	tt.Profile = TunedCustomProfile
+	if err := tt.Verify(); err == nil || !strings.Contains(err.Error(), "instead of vmware-tuner") {
+		t.Errorf("verify with another profile: %v", err)
+	}
+	os.Remove(filepath.Join(root, tunedPIDPath))
+	if err := tt.Verify(); err == nil || errors.Is(err, ErrVerifySkipped) {
+		t.Errorf("verify with tuned stopped: %v", err)
+	}
+}
+
+func TestSetTunedMainOption(t *testing.T) {
+	for _, tc := range []struct{ in, want string }{
+		{"", "reapply_sysctl = 1\n"},
+		{"daemon = 1\nreapply_sysctl = 0\n", "daemon = 1\nreapply_sysctl = 1\n"},
+		{"daemon = 1\n#reapply_sysctl = 0\n", "daemon = 1\nreapply_sysctl = 1\n"},
+		{"daemon = 1\n# Whether to reapply sysctl\n", "daemon = 1\n# Whether to reapply sysctl\nreapply_sysctl = 1\n"},
+	} {
+		if got := setTunedMainOption(tc.in, "reapply_sysctl", "1"); got != tc.want {
+			t.Errorf("setTunedMainOption(%q) = %q, want %q", tc.in, got, tc.want)
+		}
+	}
+}
+
+func TestTunedProfileConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	os.WriteFile(path, []byte("tuning:\n  tuned_profile: vmware-tuner\n"), 0644)
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Tuning.TunedProfile != TunedCustomProfile {
+		t.Errorf("tuned_profile = %q", cfg.Tuning.TunedProfile)
+	}
+
+	os.WriteFile(path, []byte("tuning:\n  tuned_profile: latency-performance\n"), 0644)
+	if _, err := LoadConfig(path); err == nil || !strings.Contains(err.Error(), "tuned_profile") {
+		t.Errorf("unknown profile: %v", err)
+	}
+}
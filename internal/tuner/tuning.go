@@ -0,0 +1,420 @@
+package tuner
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// TuningConfig overrides the built-in tuning values (tuning: section).
+// Empty fields keep the defaults.
+type TuningConfig struct {
+	Profile         string            // built-in profile, see Profiles
+	GrubParams      []string          // replaces the default boot parameters
+	ExpertBoot      []string          // opt-in lab parameters (mitigations=off...), see ExpertBootParams
+	Sysctl          map[string]string // overrides or adds sysctl keys
+	SysctlExclude   []string          // keys (or patterns) managed outside the tool
+	FstabOptions    []string          // mount options added to ext4 entries
+	RxRing          int               // vmxnet3 ring sizes
+	TxRing          int
+	RxUsecs         int // vmxnet3 interrupt coalescing, CoalesceOff for none
+	TxUsecs         int
+	Affinity        string             // NIC queue placement: spread or pin
+	Interfaces      InterfaceSelection // NICs tuned, by name or driver
+	IPv6            string             // IPv6 mode of the ipv6 module, see IPv6Modes
+	Queue           QueueSettings      // read-ahead, nr_requests, rq_affinity of the disks
+	DebloatServices []string           // replaces the Server Slim candidates
+	DebloatExtra    []string           // added to the Server Slim candidates
+	DebloatKeep     []string           // services (or patterns) never disabled
+	HugePages       HugePagesConfig    // static hugepages of the hugepages module
+	TunedProfile    string             // profile of the tuned module, see TunedProfiles
+	BackupDir       string
+}
+
+// Tuning holds the values in effect for this run (set by Activate)
+var Tuning TuningConfig
+
+// Built-in defaults, used when the config file does not override them
+var (
+	defaultFstabOptions = []string{"noatime", "nodiratime", "commit=60"}
+	defaultRxRing       = 4096
+	defaultTxRing       = 4096
+)
+
+// vmxnet3MaxRing is the largest ring the vmxnet3 adapter accepts
+const vmxnet3MaxRing = 4096
+
+var sysctlKeyRe = regexp.MustCompile(`^[a-z0-9_.-]+(/[a-z0-9_.-]+)*$`)
+
+// serviceNameRe matches systemd unit names; the wildcards are for debloat.keep
+var serviceNameRe = regexp.MustCompile(`^[A-Za-z0-9@._:*?-]+$`)
+
+// sysctlPatternRe also accepts the wildcards of sysctl_exclude (net.ipv4.tcp_*)
+var sysctlPatternRe = regexp.MustCompile(`^[a-z0-9_.*?\[\]-]+$`)
+
+// decode reads the tuning: section
+func (tc *TuningConfig) decode(fields map[string]interface{}) error {
+	if raw, ok := fields["grub"]; ok {
+		grub, ok := raw.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("grub: expected a mapping (params, expert)")
+		}
+		params, err := yamlStringList(grub["params"])
+		if err != nil {
+			return fmt.Errorf("grub.params: %w", err)
+		}
+		for _, p := range params {
+			if strings.ContainsAny(p, " \"'") {
+				return fmt.Errorf("grub.params: invalid parameter %q", p)
+			}
+		}
+		tc.GrubParams = params
+		if tc.ExpertBoot, err = yamlStringList(grub["expert"]); err != nil {
+			return fmt.Errorf("grub.expert: %w", err)
+		}
+		for _, p := range tc.ExpertBoot {
+			if _, err := expertBootWarning(p); err != nil {
+				return fmt.Errorf("grub.expert: %w", err)
+			}
+		}
+	}
+
+	if raw, ok := fields["sysctl"]; ok {
+		values, ok := raw.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("sysctl: expected a mapping of keys to values")
+		}
+		tc.Sysctl = make(map[string]string)
+		for key, rawValue := range values {
+			value, err := yamlString(rawValue)
+			if err != nil || value == "" {
+				return fmt.Errorf("sysctl.%s: expected a value", key)
+			}
+			if !sysctlKeyRe.MatchString(key) {
+				return fmt.Errorf("sysctl: invalid key %q", key)
+			}
+			tc.Sysctl[key] = value
+		}
+	}
+
+	if raw, ok := fields["sysctl_exclude"]; ok {
+		patterns, err := yamlStringList(raw)
+		if err != nil {
+			return fmt.Errorf("sysctl_exclude: %w", err)
+		}
+		for _, p := range patterns {
+			if _, err := path.Match(p, ""); err != nil || !sysctlPatternRe.MatchString(p) {
+				return fmt.Errorf("sysctl_exclude: invalid key %q", p)
+			}
+		}
+		tc.SysctlExclude = patterns
+		for key := range tc.Sysctl {
+			if tc.SysctlExcluded(key) {
+				return fmt.Errorf("sysctl.%s: key is also in sysctl_exclude", key)
+			}
+		}
+	}
+
+	if raw, ok := fields["fstab"]; ok {
+		fstab, ok := raw.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("fstab: expected a mapping (options)")
+		}
+		options, err := yamlStringList(fstab["options"])
+		if err != nil {
+			return fmt.Errorf("fstab.options: %w", err)
+		}
+		tc.FstabOptions = options
+	}
+
+	if raw, ok := fields["network"]; ok {
+		network, ok := raw.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("network: expected a mapping (rx_ring, tx_ring, rx_usecs, tx_usecs, affinity, interfaces, drivers, ipv6)")
+		}
+		var err error
+		if tc.RxRing, err = yamlInt(network["rx_ring"]); err != nil {
+			return fmt.Errorf("network.rx_ring: %w", err)
+		}
+		if tc.TxRing, err = yamlInt(network["tx_ring"]); err != nil {
+			return fmt.Errorf("network.tx_ring: %w", err)
+		}
+		for name, size := range map[string]int{"rx_ring": tc.RxRing, "tx_ring": tc.TxRing} {
+			if size < 0 || size > vmxnet3MaxRing {
+				return fmt.Errorf("network.%s: must be between 1 and %d", name, vmxnet3MaxRing)
+			}
+		}
+		for name, usecs := range map[string]*int{"rx_usecs": &tc.RxUsecs, "tx_usecs": &tc.TxUsecs} {
+			raw, ok := network[name]
+			if !ok {
+				continue
+			}
+			if *usecs, err = yamlInt(raw); err != nil {
+				return fmt.Errorf("network.%s: %w", name, err)
+			}
+			if *usecs < 0 || *usecs > maxCoalesceUsecs {
+				return fmt.Errorf("network.%s: must be between 0 and %d", name, maxCoalesceUsecs)
+			}
+			if *usecs == 0 {
+				*usecs = CoalesceOff
+			}
+		}
+		if tc.Affinity, err = yamlString(network["affinity"]); err != nil {
+			return fmt.Errorf("network.affinity: %w", err)
+		}
+		if tc.Affinity != "" && tc.Affinity != AffinitySpread && tc.Affinity != AffinityPin {
+			return fmt.Errorf("network.affinity: must be %s or %s", AffinitySpread, AffinityPin)
+		}
+		if tc.Interfaces.Names, err = yamlStringList(network["interfaces"]); err != nil {
+			return fmt.Errorf("network.interfaces: %w", err)
+		}
+		if tc.Interfaces.Drivers, err = yamlStringList(network["drivers"]); err != nil {
+			return fmt.Errorf("network.drivers: %w", err)
+		}
+		if err := tc.Interfaces.Validate(); err != nil {
+			return fmt.Errorf("network: %w", err)
+		}
+		if tc.IPv6, err = yamlString(network["ipv6"]); err != nil {
+			return fmt.Errorf("network.ipv6: %w", err)
+		}
+		if tc.IPv6 != "" {
+			if err := ValidIPv6Mode(tc.IPv6); err != nil {
+				return fmt.Errorf("network.ipv6: %w", err)
+			}
+		}
+	}
+
+	if raw, ok := fields["queue"]; ok {
+		queue, ok := raw.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("queue: expected a mapping (read_ahead_kb, nr_requests, rq_affinity)")
+		}
+		// 0 means unset in QueueSettings: a key that is present must be in range
+		for _, q := range []struct {
+			key      string
+			value    *int
+			max      int
+			rangeMsg string
+		}{
+			{"read_ahead_kb", &tc.Queue.ReadAheadKB, 65536, "between 1 and 65536"},
+			{"nr_requests", &tc.Queue.NrRequests, 4096, "between 1 and 4096"},
+			{"rq_affinity", &tc.Queue.RqAffinity, 2, "1 or 2"},
+		} {
+			raw, present := queue[q.key]
+			if !present {
+				continue
+			}
+			n, err := yamlInt(raw)
+			if err != nil {
+				return fmt.Errorf("queue.%s: %w", q.key, err)
+			}
+			if n < 1 || n > q.max {
+				return fmt.Errorf("queue.%s: must be %s", q.key, q.rangeMsg)
+			}
+			*q.value = n
+		}
+	}
+
+	if raw, ok := fields["debloat"]; ok {
+		debloat, ok := raw.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("debloat: expected a mapping (services, extra, keep)")
+		}
+		var err error
+		for key, list := range map[string]*[]string{"services": &tc.DebloatServices, "extra": &tc.DebloatExtra, "keep": &tc.DebloatKeep} {
+			if *list, err = yamlStringList(debloat[key]); err != nil {
+				return fmt.Errorf("debloat.%s: %w", key, err)
+			}
+			for _, name := range *list {
+				if _, err := path.Match(name, ""); err != nil || !serviceNameRe.MatchString(name) {
+					return fmt.Errorf("debloat.%s: invalid service %q", key, name)
+				}
+				if key != "keep" && strings.ContainsAny(name, "*?") {
+					return fmt.Errorf("debloat.%s: %q: wildcards are only allowed in debloat.keep", key, name)
+				}
+			}
+		}
+		for _, name := range append(append([]string{}, tc.DebloatServices...), tc.DebloatExtra...) {
+			if tc.DebloatKept(name) {
+				return fmt.Errorf("debloat.keep: %s is also listed to disable", name)
+			}
+		}
+	}
+
+	if raw, ok := fields["hugepages"]; ok {
+		hugepages, ok := raw.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("hugepages: expected a mapping (size, count, memory_mb)")
+		}
+		if err := tc.HugePages.decode(hugepages); err != nil {
+			return fmt.Errorf("hugepages.%w", err)
+		}
+	}
+
+	var err error
+	if tc.Profile, err = yamlString(fields["profile"]); err != nil {
+		return fmt.Errorf("profile: %w", err)
+	}
+	if _, err := LookupProfile(tc.Profile); err != nil {
+		return fmt.Errorf("profile: %w", err)
+	}
+	if tc.TunedProfile, err = yamlString(fields["tuned_profile"]); err != nil {
+		return fmt.Errorf("tuned_profile: %w", err)
+	}
+	if tc.TunedProfile != "" {
+		if err := ValidTunedProfile(tc.TunedProfile); err != nil {
+			return fmt.Errorf("tuned_profile: %w", err)
+		}
+	}
+	if tc.BackupDir, err = yamlString(fields["backup_dir"]); err != nil {
+		return fmt.Errorf("backup_dir: %w", err)
+	}
+	if tc.BackupDir != "" && !strings.HasPrefix(tc.BackupDir, "/") {
+		return fmt.Errorf("backup_dir: must be an absolute path")
+	}
+	return nil
+}
+
+// Activate makes the values effective for the tuners of this run
+func (tc TuningConfig) Activate() {
+	Tuning = tc
+	if tc.BackupDir != "" {
+		BackupRoot = tc.BackupDir
+	}
+}
+
+// MountOptions returns the mount options to add to ext4 entries
+func (tc TuningConfig) MountOptions() []string {
+	if len(tc.FstabOptions) > 0 {
+		return tc.FstabOptions
+	}
+	return defaultFstabOptions
+}
+
+// Rings returns the vmxnet3 RX and TX ring sizes
+func (tc TuningConfig) Rings() (int, int) {
+	nic := tc.NICSettings()
+	return nic.RxRing, nic.TxRing
+}
+
+// NICSettings returns the vmxnet3 rings and coalescing: config file, then
+// profile, then the built-in defaults
+func (tc TuningConfig) NICSettings() NICSettings {
+	nic := NICSettings{RxRing: tc.RxRing, TxRing: tc.TxRing, RxUsecs: tc.RxUsecs, TxUsecs: tc.TxUsecs}
+	return nic.merge(tc.ActiveProfile().NIC).merge(defaultNIC)
+}
+
+// AffinityMode returns the NIC queue placement: config file, then profile,
+// then spread
+func (tc TuningConfig) AffinityMode() string {
+	if tc.Affinity != "" {
+		return tc.Affinity
+	}
+	if mode := tc.ActiveProfile().Affinity; mode != "" {
+		return mode
+	}
+	return AffinitySpread
+}
+
+// QueueSettings returns the block queue values: config file, then profile,
+// then the built-in defaults
+func (tc TuningConfig) QueueSettings() QueueSettings {
+	return tc.Queue.merge(tc.ActiveProfile().Queue).merge(defaultQueue)
+}
+
+// DebloatKept reports whether a service must never be disabled (tuning.debloat.keep)
+func (tc TuningConfig) DebloatKept(name string) bool {
+	name = strings.TrimSuffix(name, ".service")
+	for _, p := range append(append([]string{}, tc.DebloatKeep...), tc.ActiveProfile().DebloatKeep...) {
+		if ok, _ := path.Match(strings.TrimSuffix(p, ".service"), name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// SysctlExcluded reports whether a key is left to the administrator
+// (tuning.sysctl_exclude) or protected by the exclusions file
+func (tc TuningConfig) SysctlExcluded(key string) bool {
+	if Excluded.Matches(ExcludeSysctl, key) {
+		return true
+	}
+	for _, p := range tc.SysctlExclude {
+		if ok, _ := path.Match(p, key); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// excludeSysctlKeys comments out the excluded keys of a sysctl.d file, so the
+// values managed elsewhere (tuned, configuration management) are not fought over
+func excludeSysctlKeys(content string, tc TuningConfig) string {
+	if len(tc.SysctlExclude) == 0 && len(Excluded.Sysctl) == 0 {
+		return content
+	}
+	lines := strings.Split(content, "\n")
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+		key, _, ok := strings.Cut(trimmed, "=")
+		if ok && tc.SysctlExcluded(strings.TrimSpace(key)) {
+			source := "config.yaml"
+			if Excluded.Matches(ExcludeSysctl, strings.TrimSpace(key)) {
+				source = Excluded.Path
+			}
+			lines[i] = "# Excluded in " + source + " (managed elsewhere)\n# " + trimmed
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// applySysctlOverrides replaces the values of overridden keys in a sysctl.d
+// file and appends the keys it does not contain. source names the origin of
+// the values in the generated comments.
+func applySysctlOverrides(content string, overrides map[string]string, source string) string {
+	if len(overrides) == 0 {
+		return content
+	}
+
+	done := make(map[string]bool)
+	lines := strings.Split(content, "\n")
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+		key, _, ok := strings.Cut(trimmed, "=")
+		if !ok {
+			continue
+		}
+		key = strings.TrimSpace(key)
+		if value, found := overrides[key]; found {
+			// sysctl.d has no trailing comments
+			lines[i] = fmt.Sprintf("# Overridden in %s\n%s = %s", source, key, value)
+			done[key] = true
+		}
+	}
+
+	var extra []string
+	for key := range overrides {
+		if !done[key] {
+			extra = append(extra, key)
+		}
+	}
+	if len(extra) == 0 {
+		return strings.Join(lines, "\n")
+	}
+
+	sort.Strings(extra)
+	out := strings.TrimRight(strings.Join(lines, "\n"), "\n")
+	out += "\n\n# ============================================\n# Local settings (" + source + ")\n# ============================================\n\n"
+	for _, key := range extra {
+		out += fmt.Sprintf("%s = %s\n", key, overrides[key])
+	}
+	return out
+}
@@ -0,0 +1,202 @@
+package tuner
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// unitJournalLines is the journal excerpt shown for each failed unit
+const unitJournalLines = 10
+
+// FailedUnit is a systemd unit in the failed state
+type FailedUnit struct {
+	Unit        string
+	Load        string
+	Active      string
+	Sub         string
+	Description string
+}
+
+// ParseFailedUnits reads `systemctl list-units --failed --no-legend` output.
+// Older systemd versions prefix the failed units with a bullet.
+func ParseFailedUnits(out string) []FailedUnit {
+	var units []FailedUnit
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.Fields(strings.TrimSpace(line))
+		if len(fields) > 0 && (fields[0] == "●" || fields[0] == "*") {
+			fields = fields[1:]
+		}
+		if len(fields) < 4 || !strings.Contains(fields[0], ".") {
+			continue
+		}
+		units = append(units, FailedUnit{
+			Unit:        fields[0],
+			Load:        fields[1],
+			Active:      fields[2],
+			Sub:         fields[3],
+			Description: strings.Join(fields[4:], " "),
+		})
+	}
+	return units
+}
+
+// UnitDoctorTuner lists the failed units with their recent journal and
+// offers to restart, disable or reset them, one unit at a time
+type UnitDoctorTuner struct {
+	JournalLines int
+}
+
+// NewUnitDoctorTuner creates a new failed units diagnostic
+func NewUnitDoctorTuner() *UnitDoctorTuner {
+	return &UnitDoctorTuner{
+		JournalLines: unitJournalLines,
+	}
+}
+
+func init() {
+	Register(Module{
+		Name:        "units",
+		Label:       "Repair Failed Units",
+		Description: "Restart, disable or reset the failed systemd units",
+		Category:    CategoryMaintenance,
+		Menu:        31,
+		RequireRoot: true,
+		Run: func(ctx *ModuleContext) error {
+			return NewUnitDoctorTuner().Run(ctx.BackupSession)
+		},
+	})
+}
+
+// Scan returns the failed units
+func (ut *UnitDoctorTuner) Scan() ([]FailedUnit, error) {
+	out, err := exec.Command("systemctl", "list-units", "--failed", "--all", "--no-legend", "--plain", "--no-pager").Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list the failed units: %w", err)
+	}
+	return ParseFailedUnits(string(out)), nil
+}
+
+// Excerpt returns the last journal lines of a unit in this boot
+func (ut *UnitDoctorTuner) Excerpt(unit string) ([]string, error) {
+	ring := newLineRing(ut.JournalLines)
+	err := ScanCommandLines(ring.Add, "journalctl", "-u", unit, "-b", "-n", fmt.Sprint(ut.JournalLines), "--no-pager", "-o", "short-iso")
+	return ring.Lines(), err
+}
+
+// unitActions are the repairs offered for a failed unit, by key
+var unitActions = []struct {
+	Key     string
+	Label   string
+	Command []string
+}{
+	{"r", "restart", []string{"restart"}},
+	{"d", "disable", []string{"disable", "--now"}},
+	{"c", "clear the failed state (reset-failed)", []string{"reset-failed"}},
+}
+
+// Repair runs an action on a unit ("restart", "disable" or "reset-failed"),
+// recording it in the action log. The state of a unit about to be disabled
+// is recorded in the backup session, so rollback enables it again.
+func (ut *UnitDoctorTuner) Repair(backup *BackupManager, unit string, command []string) error {
+	if ReadOnly {
+		return fmt.Errorf("systemctl %s %s: %w", command[0], unit, ErrReadOnly)
+	}
+	if command[0] != "reset-failed" && unsafeRestartUnits[unit] {
+		return fmt.Errorf("%s cannot be stopped safely (reboot instead)", unit)
+	}
+	if command[0] != "reset-failed" && Excluded.Matches(ExcludeService, unit) {
+		return fmt.Errorf("%s is listed in %s: left to the administrator", unit, Excluded.Path)
+	}
+
+	if command[0] == "disable" {
+		if err := backup.BackupServices([]string{unit}); err != nil {
+			return fmt.Errorf("failed to record the state of %s: %w", unit, err)
+		}
+	}
+
+	args := append(append([]string(nil), command...), unit)
+	ExplainCommand(fmt.Sprintf("%s the failed unit %s", command[0], unit), "systemctl", args...)
+	if out, err := exec.Command("systemctl", args...).CombinedOutput(); err != nil {
+		LogAction("units", command[0]+"-unit", ResultFailed, "unit="+unit)
+		return fmt.Errorf("systemctl %s %s failed: %s", command[0], unit, strings.TrimSpace(string(out)))
+	}
+	LogAction("units", command[0]+"-unit", ResultSuccess, "unit="+unit)
+	return nil
+}
+
+// Run shows the failed units and, on a terminal, offers a repair for each.
+// The backup session is started when a unit is disabled; without one (the
+// support bundle) the units are only listed.
+func (ut *UnitDoctorTuner) Run(session func() (*BackupManager, error)) error {
+	PrintStep("Failed Units")
+
+	units, err := ut.Scan()
+	if err != nil {
+		return err
+	}
+	if len(units) == 0 {
+		PrintSuccess("No failed units")
+		return nil
+	}
+
+	PrintWarning("%d failed unit(s):", len(units))
+	for _, u := range units {
+		fmt.Printf("    %-40s %-8s %s\n", u.Unit, u.Sub, u.Description)
+	}
+
+	interactive := !ReadOnly && session != nil && isInteractive()
+	reader := bufio.NewReader(os.Stdin)
+	failed := 0
+	for _, u := range units {
+		fmt.Println()
+		PrintInfo("%s (%s)", u.Unit, u.Description)
+		lines, err := ut.Excerpt(u.Unit)
+		if err != nil {
+			PrintWarning("Could not read the journal of %s: %v", u.Unit, err)
+		}
+		for _, line := range lines {
+			fmt.Printf("    %s\n", line)
+		}
+		if !interactive || SkipExcluded("units", ExcludeService, u.Unit) {
+			continue
+		}
+
+		var choices []string
+		for _, a := range unitActions {
+			choices = append(choices, fmt.Sprintf("[%s] %s", a.Key, a.Label))
+		}
+		fmt.Printf("%s, [s] skip: ", strings.Join(choices, ", "))
+		resp, _ := reader.ReadString('\n')
+		resp = strings.ToLower(strings.TrimSpace(resp))
+
+		for _, a := range unitActions {
+			if resp != a.Key {
+				continue
+			}
+			var backup *BackupManager
+			if a.Command[0] == "disable" {
+				if backup, err = session(); err != nil {
+					return err
+				}
+			}
+			if err := ut.Repair(backup, u.Unit, a.Command); err != nil {
+				PrintError("%v", err)
+				failed++
+			} else {
+				PrintSuccess("%s: %s done", u.Unit, a.Command[0])
+			}
+		}
+	}
+
+	if !interactive {
+		fmt.Println()
+		PrintInfo("Run vmware-tuner units as root on a terminal to repair them")
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d repair(s) failed", failed)
+	}
+	return nil
+}
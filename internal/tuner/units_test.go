@@ -0,0 +1,50 @@
+package tuner
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestParseFailedUnits(t *testing.T) {
+	out := `● snapd.service          loaded failed failed Snap Daemon
+* logrotate.timer        loaded failed failed Daily rotation of log files
+nfs-server.service      loaded failed failed NFS server and services
+
+`
+	units := ParseFailedUnits(out)
+	if len(units) != 3 {
+		t.Fatalf("expected 3 units, got %+v", units)
+	}
+	if units[0] != (FailedUnit{"snapd.service", "loaded", "failed", "failed", "Snap Daemon"}) {
+		t.Errorf("unexpected unit: %+v", units[0])
+	}
+	if units[1].Unit != "logrotate.timer" || units[2].Description != "NFS server and services" {
+		t.Errorf("unexpected units: %+v", units)
+	}
+	if len(ParseFailedUnits("0 loaded units listed.\n")) != 0 {
+		t.Error("the summary line is not a unit")
+	}
+}
+
+func TestUnitRepairRefused(t *testing.T) {
+	saved := ReadOnly
+	defer func() { ReadOnly = saved }()
+
+	ut := NewUnitDoctorTuner()
+	ReadOnly = true
+	if err := ut.Repair(nil, "snapd.service", []string{"restart"}); !errors.Is(err, ErrReadOnly) {
+		t.Errorf("repairs are changes: %v", err)
+	}
+	ReadOnly = false
+	if err := ut.Repair(nil, "dbus.service", []string{"disable", "--now"}); err == nil {
+		t.Error("dbus must not be stopped")
+	}
+
+	savedEx := Excluded
+	defer func() { Excluded = savedEx }()
+	Excluded = Exclusions{Path: DefaultExclusionsPath, Services: []string{"snapd"}}
+	if err := ut.Repair(nil, "snapd.service", []string{"restart"}); err == nil || !strings.Contains(err.Error(), DefaultExclusionsPath) {
+		t.Errorf("excluded unit restarted: %v", err)
+	}
+}
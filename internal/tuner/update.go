@@ -4,7 +4,7 @@ import (
 	"fmt"
 	"os"
 	"os/exec"
-	"strings"
+	"time"
 )
 
 // UpdateTuner handles system updates
@@ -19,43 +19,40 @@ func NewUpdateTuner(distro *DistroManager) *UpdateTuner {
 	}
 }
 
+func init() {
+	Register(Module{
+		Name:        "update",
+		Label:       "Safe System Update",
+		Description: "Update the packages and offer a reboot",
+		Category:    CategoryMaintenance,
+		Menu:        16,
+		RequireRoot: true,
+		Distros:     packagedDistros,
+		Run: func(ctx *ModuleContext) error {
+			return NewUpdateTuner(ctx.Distro).Run(ctx.HasInternet)
+		},
+	})
+}
+
 // Run performs the update
 func (ut *UpdateTuner) Run(hasInternet bool) error {
 	PrintStep("Safe System Update")
 
+	if ut.Distro.Ostree {
+		PrintInfo("rpm-ostree system: updates are atomic deployments (zincati on Fedora CoreOS)")
+		return errOstreeUnsupported("apt/dnf updates", "run 'rpm-ostree upgrade' and reboot")
+	}
+
 	if !hasInternet {
 		PrintWarning("Mode Hors-Ligne activé : Pas de mises à jour système possibles.")
-		return fmt.Errorf("offline mode")
+		return fmt.Errorf("system update: %w", ErrOffline)
 	}
 
-	// 1. Check Disk Space
+	// 1. Check Disk Space and Memory
 	PrintInfo("Checking disk space...")
-	cmd := exec.Command("df", "--output=avail", "/")
-	out, err := cmd.Output()
-	if err != nil {
-		return fmt.Errorf("failed to check disk space: %w", err)
-	}
-
-	// Simple check: ensure we have enough bytes (rough approximation from output)
-	// Real implementation would parse strictly. Here we rely on the user seeing the output if we just show it?
-	// No, let's try to be safer.
-	// Output is like:
-	// Avail
-	// 10240000
-	lines := strings.Split(strings.TrimSpace(string(out)), "\n")
-	if len(lines) >= 2 {
-		availStr := strings.TrimSpace(lines[1])
-		var avail int64
-		fmt.Sscanf(availStr, "%d", &avail)
-
-		// df outputs 1K blocks usually
-		availMB := avail / 1024
-		if availMB < 1000 {
-			PrintError("Insufficient disk space! Only %d MB free.", availMB)
-			PrintInfo("At least 1000 MB is recommended for safe updates.")
-			return fmt.Errorf("disk space check failed")
-		}
-		PrintSuccess("Disk space OK (%d MB free)", availMB)
+	if err := CheckPreconditions("update"); err != nil {
+		PrintError("%v", err)
+		return fmt.Errorf("precondition failed, update skipped: %w", err)
 	}
 
 	// 2. Run Update
@@ -81,43 +78,53 @@ func (ut *UpdateTuner) Run(hasInternet bool) error {
 			updateCmd = exec.Command("yum", "update")
 		}
 	} else {
-		return fmt.Errorf("unsupported distribution for auto-update")
+		return fmt.Errorf("auto-update: %w", ErrUnsupportedDistro)
 	}
 
 	updateCmd.Stdout = os.Stdout
 	updateCmd.Stderr = os.Stderr
 	updateCmd.Stdin = os.Stdin
 
+	start := time.Now()
 	if err := updateCmd.Run(); err != nil {
-		return fmt.Errorf("update failed: %w", err)
+		return fmt.Errorf("update failed after %s: %w", FormatDuration(time.Since(start)), err)
 	}
 
-	PrintSuccess("System updated successfully!")
+	PrintSuccess("System updated successfully in %s", FormatDuration(time.Since(start)))
+	PrintFilesystemUsage("/")
 
-	// 3. Check Reboot
-	rebootNeeded := false
-	if ut.Distro.Type == DistroDebian {
-		if _, err := os.Stat("/var/run/reboot-required"); err == nil {
-			rebootNeeded = true
-		}
-	} else if ut.Distro.Type == DistroRHEL {
-		// needs-restarting -r (yum-utils)
-		if err := exec.Command("needs-restarting", "-r").Run(); err != nil {
-			// Exit code 1 means reboot needed
-			rebootNeeded = true
-		}
-	}
+	// 3. Check Reboot (native scan: works without needs-restarting/yum-utils)
+	restart := NewRestartTuner()
+	status := restart.Scan()
 
-	if rebootNeeded {
-		PrintWarning("A reboot is required to apply updates.")
+	if status.RebootRequired {
+		PrintWarning("A reboot is required to apply updates (%s).", status.RebootReason)
 		fmt.Print("Reboot now? (y/n): ")
 		fmt.Scanln(&resp)
 		if resp == "y" {
 			exec.Command("reboot").Run()
 		}
-	} else {
-		PrintSuccess("No reboot required.")
+		return nil
 	}
 
-	return nil
+	PrintSuccess("No reboot required.")
+
+	// 4. Only userspace libraries changed: restarting the services is enough
+	services := restart.restartable(status.Services)
+	if len(services) == 0 {
+		return nil
+	}
+	services = OrderRestarts(services)
+	fmt.Println()
+	PrintInfo("Services still using the old libraries (restart order):")
+	for i, svc := range services {
+		fmt.Printf("    %d. %s\n", i+1, svc)
+	}
+	fmt.Print("Restart them now? (y/n): ")
+	fmt.Scanln(&resp)
+	if resp != "y" {
+		PrintInfo("Restart them later from the 'Services to Restart' menu")
+		return nil
+	}
+	return restart.RestartServices(services)
 }
@@ -4,7 +4,6 @@ import (
 	"fmt"
 	"net/http"
 	"os"
-	"path/filepath"
 	"strings"
 	"time"
 
@@ -20,29 +19,29 @@ var (
 )
 
 func PrintSuccess(format string, args ...interface{}) {
-	colorSuccess.Print("✓ ")
-	fmt.Printf(format+"\n", args...)
+	colorSuccess.Print(theme.Success)
+	fmt.Println(themed(fmt.Sprintf(format, args...)))
 }
 
 func PrintError(format string, args ...interface{}) {
-	colorError.Print("✗ ")
-	fmt.Fprintf(os.Stderr, format+"\n", args...)
+	colorError.Print(theme.Error)
+	fmt.Fprintln(os.Stderr, themed(fmt.Sprintf(format, args...)))
 }
 
 func PrintWarning(format string, args ...interface{}) {
-	colorWarning.Print("⚠ ")
-	fmt.Printf(format+"\n", args...)
+	colorWarning.Print(theme.Warning)
+	fmt.Println(themed(fmt.Sprintf(format, args...)))
 }
 
 func PrintInfo(format string, args ...interface{}) {
-	colorInfo.Print("ℹ ")
-	fmt.Printf(format+"\n", args...)
+	colorInfo.Print(theme.Info)
+	fmt.Println(themed(fmt.Sprintf(format, args...)))
 }
 
 func PrintStep(format string, args ...interface{}) {
 	fmt.Println()
-	colorStep.Printf("▶ "+format+"\n", args...)
-	fmt.Println("────────────────────────────────────────────────────────")
+	colorStep.Println(theme.Step + themed(fmt.Sprintf(format, args...)))
+	fmt.Println(theme.Rule)
 }
 
 // CheckConnectivity verifies internet access via HTTP HEAD requests
@@ -72,7 +71,7 @@ func CheckConnectivity() bool {
 
 func CheckRoot() error {
 	if os.Geteuid() != 0 {
-		return fmt.Errorf("ce programme doit être lancé en root (sudo)")
+		return fmt.Errorf("%w: ce programme doit être lancé en root (sudo)", ErrNeedsRoot)
 	}
 	return nil
 }
@@ -83,9 +82,10 @@ func FileExists(path string) bool {
 }
 
 func IsVMware(fsRoot string) (bool, error) {
+	sys := SysFS{Root: fsRoot}
+
 	// Check DMI product name
-	dmiPath := filepath.Join(fsRoot, "/sys/class/dmi/id/product_name")
-	data, err := os.ReadFile(dmiPath)
+	data, err := sys.ReadFile("/sys/class/dmi/id/product_name")
 	if err == nil {
 		if strings.Contains(string(data), "VMware") {
 			return true, nil
@@ -93,8 +93,7 @@ func IsVMware(fsRoot string) (bool, error) {
 	}
 
 	// Check /proc/cpuinfo
-	cpuInfoPath := filepath.Join(fsRoot, "/proc/cpuinfo")
-	data, err = os.ReadFile(cpuInfoPath)
+	data, err = sys.ReadFile("/proc/cpuinfo")
 	if err == nil {
 		content := string(data)
 		if strings.Contains(content, "VMware") || strings.Contains(content, "hypervisor") {
@@ -105,16 +104,7 @@ func IsVMware(fsRoot string) (bool, error) {
 }
 
 func Banner() {
-	banner := `
-╔══════════════════════════════════════════════════════════╗
-║                                                          ║
-║           VMware VM Performance Tuner                    ║
-║                                                          ║
-║   Optimisé pour Environnements Enterprise (Air-Gapped)   ║
-║                                                          ║
-╚══════════════════════════════════════════════════════════╝
-`
-	colorStep.Println(banner)
+	colorStep.Println(theme.Banner)
 }
 
 func Summary(modules []string) {
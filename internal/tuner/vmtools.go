@@ -22,6 +22,20 @@ func NewVMToolsTuner(dryRun bool, distro *DistroManager) *VMToolsTuner {
 	}
 }
 
+func init() {
+	Register(Module{
+		Name:        "tools",
+		Description: "VMware Tools verification/installation",
+		Category:    CategoryTuning,
+		Pipeline:    6,
+		RequireRoot: true,
+		Flag:        &ModuleFlag{Name: "install-tools", Usage: "Install open-vm-tools if missing", Default: true},
+		Apply: func(ctx *ModuleContext) error {
+			return NewVMToolsTuner(ctx.DryRun, ctx.Distro).Apply(ctx.HasInternet)
+		},
+	})
+}
+
 // CheckInstalled checks if open-vm-tools is installed
 func (vt *VMToolsTuner) CheckInstalled() bool {
 	_, err := exec.LookPath("vmtoolsd")
@@ -39,9 +53,12 @@ func (vt *VMToolsTuner) Apply(hasInternet bool) error {
 
 	PrintInfo("open-vm-tools is missing")
 
+	if !vt.Distro.HasPackageSource("open-vm-tools", hasInternet) {
+		PrintWarning("Mode Hors-Ligne: Impossible d'installer open-vm-tools (pas d'internet, pas de paquet dans --pkg-dir)")
+		return fmt.Errorf("open-vm-tools cannot be installed: %w", ErrOffline)
+	}
 	if !hasInternet {
-		PrintWarning("Mode Hors-Ligne: Impossible d'installer open-vm-tools (pas d'internet)")
-		return nil
+		PrintInfo("Installing from the local packages: %s", PackageDir)
 	}
 
 	if vt.DryRun {
@@ -70,12 +87,17 @@ func (vt *VMToolsTuner) ensureService() error {
 		serviceName = "vmtoolsd"
 	}
 
+	if SkipExcluded("tools", ExcludeService, serviceName) {
+		return nil
+	}
 	PrintInfo("Ensuring %s service is running...", serviceName)
 
 	// Enable
+	ExplainCommand("start the guest tools at boot: they report the VM state to vCenter and quiesce snapshots", "systemctl", "enable", serviceName)
 	exec.Command("systemctl", "enable", serviceName).Run()
 
 	// Start
+	ExplainCommand("start the guest tools now", "systemctl", "start", serviceName)
 	cmd := exec.Command("systemctl", "start", serviceName)
 	if err := cmd.Run(); err != nil {
 		// Try alternative name if failed
@@ -0,0 +1,150 @@
+package tuner
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// vmxnet3Counter maps a raw `ethtool -S` counter of the vmxnet3 driver to an
+// explained metric. Queue is "tx" or "rx" for per-queue counters, "" for
+// device-wide ones; Problem counters should stay at zero.
+type vmxnet3Counter struct {
+	Queue       string
+	Counter     string
+	Name        string
+	Explanation string
+	Problem     bool
+}
+
+var vmxnet3Counters = []vmxnet3Counter{
+	{"tx", "ring full", "Tx queue stops", "TX ring full: the queue stopped until the host drained it. Raise the TX ring (ethtool -G tx 4096)", true},
+	{"tx", "drv dropped tx total", "Tx drops (driver)", "Dropped by the guest driver before reaching the host (bad headers, too many fragments)", true},
+	{"tx", "pkts tx discard", "Tx discards (device)", "Discarded by the virtual device", true},
+	{"tx", "pkts tx err", "Tx errors (device)", "Transmit errors reported by the virtual device", true},
+	{"tx", "pkts linearized", "Tx linearized", "Copied into one buffer because of too many fragments: CPU cost, no loss", false},
+	{"tx", "TSO pkts tx", "TSO packets", "Large sends segmented by the host (TCP segmentation offload)", false},
+	{"rx", "pkts rx OOB", "Rx out-of-buffer drops", "The guest RX ring was full when the host delivered: raise the RX ring (ethtool -G rx 4096) or enable RSS", true},
+	{"rx", "drv dropped rx total", "Rx drops (driver)", "Dropped by the guest driver (errors, bad checksum)", true},
+	{"rx", "pkts rx err", "Rx errors (device)", "Receive errors reported by the virtual device", true},
+	{"rx", "rx buf alloc fail", "Rx buffer allocation failures", "The guest could not allocate receive buffers: memory pressure or ballooning", true},
+	{"rx", "LRO pkts rx", "LRO packets", "Packets merged by large receive offload; zero under load means LRO is off", false},
+	{"rx", "LRO byte rx", "LRO bytes", "Bytes merged by large receive offload", false},
+	{"", "tx timeout count", "Tx watchdog timeouts", "The TX queue hung long enough for the kernel to reset the adapter", true},
+}
+
+// Vmxnet3Metric is a named vmxnet3 counter, summed over the queues
+type Vmxnet3Metric struct {
+	Name        string   `json:"name"`
+	Counter     string   `json:"counter"`
+	Explanation string   `json:"explanation"`
+	Problem     bool     `json:"problem"`
+	Total       uint64   `json:"total"`
+	PerQueue    []uint64 `json:"per_queue,omitempty"`
+}
+
+// Alert reports a problem counter that is not zero
+func (m Vmxnet3Metric) Alert() bool {
+	return m.Problem && m.Total > 0
+}
+
+// NICStats holds the driver statistics of one interface for reports
+type NICStats struct {
+	Interface string          `json:"interface"`
+	Metrics   []Vmxnet3Metric `json:"metrics"`
+}
+
+// ParseVmxnet3Stats turns `ethtool -S` output of a vmxnet3 adapter into named
+// metrics. The driver prints one block per queue ("Tx Queue#: 0", ...) and
+// reuses counter names across TX and RX blocks, so the block is tracked.
+func ParseVmxnet3Stats(out string) []Vmxnet3Metric {
+	type key struct{ queue, counter string }
+	values := make(map[key][]uint64)
+	queue := ""
+
+	for _, line := range strings.Split(out, "\n") {
+		idx := strings.LastIndex(line, ":")
+		if idx == -1 {
+			continue
+		}
+		name := strings.TrimSpace(line[:idx])
+		value, err := strconv.ParseUint(strings.TrimSpace(line[idx+1:]), 10, 64)
+		if err != nil {
+			continue
+		}
+		switch name {
+		case "Tx Queue#":
+			queue = "tx"
+			continue
+		case "Rx Queue#":
+			queue = "rx"
+			continue
+		}
+		k := key{queue, name}
+		if queue != "" {
+			values[k] = append(values[k], value)
+		}
+		values[key{"", name}] = []uint64{value}
+	}
+
+	var metrics []Vmxnet3Metric
+	for _, c := range vmxnet3Counters {
+		perQueue, ok := values[key{c.Queue, c.Counter}]
+		if !ok {
+			continue
+		}
+		m := Vmxnet3Metric{
+			Name:        c.Name,
+			Counter:     c.Counter,
+			Explanation: c.Explanation,
+			Problem:     c.Problem,
+		}
+		for _, v := range perQueue {
+			m.Total += v
+		}
+		if c.Queue != "" && len(perQueue) > 1 {
+			m.PerQueue = perQueue
+		}
+		metrics = append(metrics, m)
+	}
+	return metrics
+}
+
+// nicDriver returns the kernel driver bound to an interface
+func nicDriver(iface string) string {
+	return interfaceDriver("", iface)
+}
+
+// CollectVmxnet3Stats reads the named metrics of every vmxnet3 interface
+func CollectVmxnet3Stats(nics []NICInfo) []NICStats {
+	var stats []NICStats
+	for _, nic := range nics {
+		if nic.Driver != "vmxnet3" {
+			continue
+		}
+		out, err := (ioctlEthtool{}).Stats(nic.Name)
+		if err != nil {
+			continue
+		}
+		if metrics := ParseVmxnet3Stats(out); len(metrics) > 0 {
+			stats = append(stats, NICStats{Interface: nic.Name, Metrics: metrics})
+		}
+	}
+	return stats
+}
+
+// printVmxnet3Stats prints the metrics, explaining the problem counters
+func printVmxnet3Stats(metrics []Vmxnet3Metric) {
+	for _, m := range metrics {
+		line := fmt.Sprintf("    %-30s %d", m.Name, m.Total)
+		if len(m.PerQueue) > 0 {
+			line += fmt.Sprintf(" (per queue: %v)", m.PerQueue)
+		}
+		if m.Alert() {
+			PrintWarning("%s", strings.TrimSpace(line))
+			fmt.Printf("      %s\n", m.Explanation)
+		} else {
+			fmt.Println(line)
+		}
+	}
+}
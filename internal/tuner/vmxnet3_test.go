@@ -0,0 +1,51 @@
+package tuner
+
+import "testing"
+
+const vmxnet3Sample = `NIC statistics:
+     Tx Queue#: 0
+       TSO pkts tx: 100
+       ring full: 4
+       drv dropped tx total: 0
+          giant hdr: 0
+       pkts linearized: 0
+     Tx Queue#: 1
+       TSO pkts tx: 50
+       ring full: 6
+       drv dropped tx total: 0
+       pkts linearized: 0
+     Rx Queue#: 0
+       LRO pkts rx: 9
+       pkts rx OOB: 12
+       drv dropped rx total: 0
+          err: 0
+       rx buf alloc fail: 0
+     tx timeout count: 0
+`
+
+func TestParseVmxnet3Stats(t *testing.T) {
+	metrics := make(map[string]Vmxnet3Metric)
+	for _, m := range ParseVmxnet3Stats(vmxnet3Sample) {
+		metrics[m.Counter] = m
+	}
+
+	stops := metrics["ring full"]
+	if stops.Name != "Tx queue stops" || stops.Total != 10 || !stops.Alert() {
+		t.Errorf("unexpected tx queue stops metric %+v", stops)
+	}
+	if len(stops.PerQueue) != 2 || stops.PerQueue[1] != 6 {
+		t.Errorf("per-queue values = %v, want [4 6]", stops.PerQueue)
+	}
+	if oob := metrics["pkts rx OOB"]; oob.Total != 12 || !oob.Alert() {
+		t.Errorf("unexpected OOB metric %+v", oob)
+	}
+	if tso := metrics["TSO pkts tx"]; tso.Total != 150 || tso.Alert() {
+		t.Errorf("TSO is informational, got %+v", tso)
+	}
+	if lro := metrics["LRO pkts rx"]; lro.Total != 9 || lro.PerQueue != nil {
+		t.Errorf("single queue should not list per-queue values, got %+v", lro)
+	}
+	if timeout, ok := metrics["tx timeout count"]; !ok || timeout.Alert() {
+		t.Errorf("unexpected timeout metric %+v", timeout)
+	}
+}
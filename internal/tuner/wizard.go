@@ -0,0 +1,264 @@
+package tuner
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// WizardRole is the role written by the wizard in the config file
+const WizardRole = "wizard"
+
+// WizardAnswers are the answers to the quick-start questions
+type WizardAnswers struct {
+	Workload     string // database, web, batch, latency, general
+	Priority     string // latency, throughput, balanced
+	MemoryMB     int64
+	Production   bool
+	RebootWindow bool
+}
+
+// WizardPlan is what the answers translate to
+type WizardPlan struct {
+	Profile string
+	Modules []string
+	Sysctl  map[string]string
+	Notes   []string
+}
+
+// wizardWorkloads are the choices of the first question
+// and their profile (general: from the priority)
+var wizardWorkloads = []struct {
+	Key     string
+	Label   string
+	Profile string
+}{
+	{"database", "Database (PostgreSQL, MySQL, Oracle, MongoDB)", "database"},
+	{"web", "Web or API server, reverse proxy", "web"},
+	{"batch", "File server, batch processing, backups", "throughput"},
+	{"latency", "Latency-sensitive (trading, VoIP, real-time)", "low-latency"},
+	{"general", "General purpose / not sure", ""},
+}
+
+// BuildWizardPlan turns the answers into a profile, a module list and notes
+func BuildWizardPlan(a WizardAnswers) WizardPlan {
+	plan := WizardPlan{Sysctl: make(map[string]string)}
+
+	for _, w := range wizardWorkloads {
+		if w.Key == a.Workload {
+			plan.Profile = w.Profile
+		}
+	}
+	if plan.Profile == "" {
+		switch a.Priority {
+		case "latency":
+			plan.Profile = "low-latency"
+		case "throughput":
+			plan.Profile = "throughput"
+		default:
+			plan.Profile = "default"
+		}
+	}
+
+	plan.Modules = []string{"sysctl", "fstab", "io", "network", "tools"}
+	if a.RebootWindow {
+		plan.Modules = append([]string{"grub"}, plan.Modules...)
+	} else {
+		plan.Notes = append(plan.Notes, "Boot parameters skipped (no reboot window): run again with --role "+WizardRole+" after adding grub to the role")
+	}
+	if a.Production {
+		plan.Notes = append(plan.Notes, "Production: preview with --dry-run first, and tag the VM with "+ProductionMarkerFile)
+	} else {
+		plan.Modules = append(plan.Modules, "debloat")
+		plan.Notes = append(plan.Notes, "Lab: Server Slim (debloat) included")
+	}
+
+	switch {
+	case a.MemoryMB > 0 && a.MemoryMB < 4096:
+		// Small VMs are better off swapping than being OOM-killed
+		plan.Sysctl["vm.swappiness"] = "30"
+		plan.Notes = append(plan.Notes, fmt.Sprintf("%d MB of RAM: swappiness kept at 30 so the VM swaps before the OOM killer runs", a.MemoryMB))
+	case a.MemoryMB >= 4096 && (plan.Profile == "database" || plan.Profile == "low-latency") && a.Production:
+		plan.Notes = append(plan.Notes, "Reserve all guest memory in vSphere: ballooning and host swapping hurt this workload")
+	}
+	if plan.Profile == "low-latency" {
+		plan.Notes = append(plan.Notes, "Set the VM Latency Sensitivity to High in vSphere for the full effect")
+	}
+	return plan
+}
+
+// ConfigYAML renders the plan as a config file
+func (p WizardPlan) ConfigYAML() string {
+	var b strings.Builder
+	b.WriteString("# Generated by vmware-tuner wizard\n")
+	b.WriteString("# Apply with: vmware-tuner --role " + WizardRole + "\n\n")
+	b.WriteString("roles:\n")
+	b.WriteString("  " + WizardRole + ":\n")
+	b.WriteString("    modules: [" + strings.Join(p.Modules, ", ") + "]\n\n")
+	b.WriteString("tuning:\n")
+	b.WriteString("  profile: " + p.Profile + "\n")
+	if len(p.Sysctl) > 0 {
+		var keys []string
+		for key := range p.Sysctl {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		b.WriteString("  sysctl:\n")
+		for _, key := range keys {
+			b.WriteString("    " + key + ": " + p.Sysctl[key] + "\n")
+		}
+	}
+	return b.String()
+}
+
+// WizardTuner asks the quick-start questions and writes the config file
+type WizardTuner struct {
+	ConfigPath string
+	Distro     *DistroManager
+	in         *bufio.Reader
+}
+
+// NewWizardTuner creates a new wizard tuner
+func NewWizardTuner(configPath string, distro *DistroManager) *WizardTuner {
+	return &WizardTuner{
+		ConfigPath: configPath,
+		Distro:     distro,
+		in:         bufio.NewReader(os.Stdin),
+	}
+}
+
+// ask prints a question and returns the answer, or def when empty
+func (wt *WizardTuner) ask(question, def string) string {
+	fmt.Printf("%s [%s]: ", question, def)
+	line, _ := wt.in.ReadString('\n')
+	if line = strings.TrimSpace(line); line == "" {
+		return def
+	}
+	return line
+}
+
+// askYesNo asks a yes/no question with a default
+func (wt *WizardTuner) askYesNo(question string, def bool) bool {
+	d := "n"
+	if def {
+		d = "y"
+	}
+	for {
+		switch strings.ToLower(wt.ask(question+" (y/n)", d)) {
+		case "y", "yes":
+			return true
+		case "n", "no":
+			return false
+		}
+		PrintWarning("Please answer 'y' or 'n'")
+	}
+}
+
+// askChoice asks for one of the numbered options and returns its index
+func (wt *WizardTuner) askChoice(question string, options []string, def int) int {
+	fmt.Println(question)
+	for i, opt := range options {
+		fmt.Printf("  [%d] %s\n", i+1, opt)
+	}
+	for {
+		n, err := strconv.Atoi(wt.ask("Choice", strconv.Itoa(def+1)))
+		if err == nil && n >= 1 && n <= len(options) {
+			fmt.Println()
+			return n - 1
+		}
+		PrintWarning("Please enter a number between 1 and %d", len(options))
+	}
+}
+
+// Run asks the questions, prints the plan and writes the config file
+func (wt *WizardTuner) Run() error {
+	PrintStep("Quick-Start Wizard")
+	fmt.Println("Six questions to build a config file and a tuning plan for this VM.")
+	fmt.Println()
+
+	var a WizardAnswers
+
+	// 1. Workload, with the detected one as default
+	def := len(wizardWorkloads) - 1
+	suggested := DetectProfile(wt.Distro)
+	fmt.Println()
+	var labels []string
+	for i, w := range wizardWorkloads {
+		labels = append(labels, w.Label)
+		if suggested != "" && w.Profile == suggested {
+			def = i
+		}
+	}
+	a.Workload = wizardWorkloads[wt.askChoice("1. What does this VM run?", labels, def)].Key
+
+	// 2. Latency vs throughput
+	priorities := []string{"latency", "throughput", "balanced"}
+	a.Priority = priorities[wt.askChoice("2. What matters most?", []string{
+		"Fast individual responses (latency)",
+		"Moving as much data as possible (throughput)",
+		"Balanced",
+	}, 2)]
+
+	// 3. Memory size confirmation
+	total, _, err := MemoryUsage()
+	if err == nil && wt.askYesNo(fmt.Sprintf("3. This VM has %d MB of RAM. Is that its final size?", total), true) {
+		a.MemoryMB = total
+	} else {
+		for a.MemoryMB <= 0 {
+			a.MemoryMB, _ = strconv.ParseInt(wt.ask("   Planned RAM in MB", strconv.FormatInt(total, 10)), 10, 64)
+		}
+	}
+
+	// 4. Production vs lab
+	production, _ := IsProduction()
+	a.Production = wt.askYesNo("4. Is this a production VM?", production)
+
+	// 5. Reboot window
+	a.RebootWindow = wt.askYesNo("5. Can the VM be rebooted after tuning (boot parameters)?", !a.Production)
+
+	plan := BuildWizardPlan(a)
+	content := plan.ConfigYAML()
+
+	fmt.Println()
+	PrintStep("Plan")
+	PrintInfo("Profile: %s - %s", plan.Profile, Profiles[plan.Profile].Description)
+	PrintInfo("Modules: %s", strings.Join(plan.Modules, ", "))
+	for _, note := range plan.Notes {
+		PrintInfo("%s", note)
+	}
+	fmt.Println()
+	fmt.Println(content)
+
+	// 6. Write the config file
+	path := wt.ask("6. Write this config to", wt.ConfigPath)
+	if FileExists(path) {
+		if !wt.askYesNo(fmt.Sprintf("   %s exists. Replace it (the current file is kept as %s.bak)?", path, path), false) {
+			PrintInfo("Nothing written")
+			return nil
+		}
+		if err := os.Rename(path, path+".bak"); err != nil {
+			return fmt.Errorf("failed to keep %s: %w", path, err)
+		}
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create %s: %w", filepath.Dir(path), err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	PrintSuccess("Config written to %s", path)
+
+	configFlag := ""
+	if path != DefaultConfigPath {
+		configFlag = " --config " + path
+	}
+	fmt.Println()
+	PrintInfo("Next steps:")
+	fmt.Printf("  sudo vmware-tuner%s --role %s --dry-run   # preview\n", configFlag, WizardRole)
+	fmt.Printf("  sudo vmware-tuner%s --role %s             # apply\n", configFlag, WizardRole)
+	return nil
+}
@@ -0,0 +1,46 @@
+package tuner
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestBuildWizardPlan(t *testing.T) {
+	plan := BuildWizardPlan(WizardAnswers{Workload: "general", Priority: "throughput", MemoryMB: 2048, RebootWindow: true})
+	if plan.Profile != "throughput" {
+		t.Errorf("general purpose + throughput should use the throughput profile, got %s", plan.Profile)
+	}
+	if plan.Modules[0] != "grub" || plan.Modules[len(plan.Modules)-1] != "debloat" {
+		t.Errorf("lab VM with a reboot window should get grub and debloat: %v", plan.Modules)
+	}
+	if plan.Sysctl["vm.swappiness"] != "30" {
+		t.Errorf("small VM should keep some swappiness: %v", plan.Sysctl)
+	}
+
+	plan = BuildWizardPlan(WizardAnswers{Workload: "database", Priority: "latency", MemoryMB: 65536, Production: true})
+	want := []string{"sysctl", "fstab", "io", "network", "tools"}
+	if plan.Profile != "database" || !reflect.DeepEqual(plan.Modules, want) {
+		t.Errorf("production database without reboot window: got %s %v", plan.Profile, plan.Modules)
+	}
+}
+
+func TestWizardConfigLoads(t *testing.T) {
+	plan := BuildWizardPlan(WizardAnswers{Workload: "web", MemoryMB: 1024, RebootWindow: true})
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(plan.ConfigYAML()), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("generated config does not load: %v", err)
+	}
+	if cfg.Tuning.Profile != "web" || cfg.Tuning.Sysctl["vm.swappiness"] != "30" {
+		t.Errorf("unexpected tuning section %+v", cfg.Tuning)
+	}
+	if !reflect.DeepEqual(cfg.Roles[WizardRole].Modules, plan.Modules) {
+		t.Errorf("role modules %v, want %v", cfg.Roles[WizardRole].Modules, plan.Modules)
+	}
+}
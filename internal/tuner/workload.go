@@ -0,0 +1,152 @@
+package tuner
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// workloadSignature recognizes a workload by its processes or packages
+type workloadSignature struct {
+	Workload  string
+	Processes []string // /proc/<pid>/comm (truncated to 15 characters by the kernel)
+	Packages  []string
+	Profile   string
+	Reason    string
+}
+
+// workloadSignatures are checked in order; the order also breaks ties
+// between equally supported profiles
+var workloadSignatures = []workloadSignature{
+	{"PostgreSQL", []string{"postgres", "postmaster"}, []string{"postgresql", "postgresql-server"}, "database", "no THP, minimal swapping, steady writeback for the shared buffers"},
+	{"MySQL/MariaDB", []string{"mysqld", "mariadbd"}, []string{"mysql-server", "mariadb-server"}, "database", "InnoDB recommends THP off and swappiness 1"},
+	{"MongoDB", []string{"mongod"}, []string{"mongodb-org-server", "mongodb-server"}, "database", "MongoDB warns at startup when THP is enabled"},
+	{"Oracle Database", []string{"ora_pmon"}, nil, "database", "Oracle requires THP off"},
+	{"Redis", []string{"redis-server"}, []string{"redis", "redis-server"}, "low-latency", "single-threaded in-memory store: latency spikes come from THP compaction and softirq"},
+	{"nginx", []string{"nginx"}, []string{"nginx"}, "web", "many short connections: large accept queues and fast TIME_WAIT reuse"},
+	{"Apache httpd", []string{"httpd", "apache2"}, []string{"httpd", "apache2"}, "web", "many short connections: large accept queues and fast TIME_WAIT reuse"},
+	{"HAProxy", []string{"haproxy"}, []string{"haproxy"}, "web", "proxy: ephemeral ports and accept queues run out first"},
+	{"Kubernetes node", []string{"kubelet"}, []string{"kubelet", "kubeadm"}, "throughput", "pods share the node: larger network backlog for the overlay traffic"},
+	{"Java application", []string{"java"}, nil, "throughput", "JVM heaps benefit from huge pages and batched writeback"},
+	{"Samba file server", []string{"smbd"}, []string{"samba"}, "throughput", "large sequential transfers: more dirty pages in flight"},
+	{"NFS server", []string{"nfsd"}, []string{"nfs-kernel-server"}, "throughput", "large sequential transfers: more dirty pages in flight"},
+}
+
+// Detection is a workload found on this VM
+type Detection struct {
+	Workload string
+	Profile  string
+	Evidence string // "running: postgres" or "installed: postgresql"
+	Reason   string
+	Running  bool
+}
+
+// ProfileSuggestion is a profile with the workloads that support it
+type ProfileSuggestion struct {
+	Profile    string
+	Score      int // 2 per running workload, 1 per installed one
+	Detections []Detection
+}
+
+// runningProcesses returns the command names of the running processes.
+// fsRoot allows running against a fixture tree.
+func runningProcesses(fsRoot string) map[string]bool {
+	procs := make(map[string]bool)
+	comms, _ := filepath.Glob(filepath.Join(fsRoot, "/proc/[0-9]*/comm"))
+	for _, path := range comms {
+		if data, err := os.ReadFile(path); err == nil {
+			procs[strings.TrimSpace(string(data))] = true
+		}
+	}
+	return procs
+}
+
+// DetectWorkloads matches the running processes, then the installed
+// packages (installed may be nil), against the known workloads
+func DetectWorkloads(fsRoot string, installed func(pkg string) bool) []Detection {
+	procs := runningProcesses(fsRoot)
+
+	var found []Detection
+	for _, sig := range workloadSignatures {
+		d := Detection{Workload: sig.Workload, Profile: sig.Profile, Reason: sig.Reason}
+		for _, p := range sig.Processes {
+			if procs[p] {
+				d.Evidence, d.Running = "running: "+p, true
+				break
+			}
+		}
+		if d.Evidence == "" && installed != nil {
+			for _, pkg := range sig.Packages {
+				if installed(pkg) {
+					d.Evidence = "installed: " + pkg
+					break
+				}
+			}
+		}
+		if d.Evidence != "" {
+			found = append(found, d)
+		}
+	}
+	return found
+}
+
+// SuggestProfiles ranks the profiles supported by the detected workloads
+func SuggestProfiles(detections []Detection) []ProfileSuggestion {
+	var suggestions []ProfileSuggestion
+	index := make(map[string]int)
+	for _, d := range detections {
+		i, ok := index[d.Profile]
+		if !ok {
+			i = len(suggestions)
+			index[d.Profile] = i
+			suggestions = append(suggestions, ProfileSuggestion{Profile: d.Profile})
+		}
+		suggestions[i].Detections = append(suggestions[i].Detections, d)
+		suggestions[i].Score++
+		if d.Running {
+			suggestions[i].Score++
+		}
+	}
+	sort.SliceStable(suggestions, func(a, b int) bool {
+		return suggestions[a].Score > suggestions[b].Score
+	})
+	return suggestions
+}
+
+// DetectProfile inspects the VM and prints the suggested profiles. It
+// returns the best suggestion ("" when no known workload was found).
+func DetectProfile(distro *DistroManager) string {
+	PrintStep("Workload Detection")
+
+	// A Workstation/Fusion guest is a developer desktop, whatever runs on it
+	if guest := DetectDesktopGuest(""); guest.Detected() {
+		PrintInfo("VMware Workstation/Fusion guest (%s)", guest)
+		PrintInfo("Profile developer (suggested): keeps the desktop services, lighter C-state changes, cached shared folders")
+		return "developer"
+	}
+
+	installed := func(pkg string) bool { return isPackageInstalled(distro, pkg) }
+	if distro == nil || distro.Type == DistroUnknown {
+		installed = nil
+	}
+
+	suggestions := SuggestProfiles(DetectWorkloads("", installed))
+	if len(suggestions) == 0 {
+		PrintInfo("No known workload found: the default profile fits")
+		return ""
+	}
+
+	for i, s := range suggestions {
+		label := "also fits"
+		if i == 0 {
+			label = "suggested"
+		}
+		PrintInfo("Profile %s (%s):", s.Profile, label)
+		for _, d := range s.Detections {
+			fmt.Printf("    - %s (%s): %s\n", d.Workload, d.Evidence, d.Reason)
+		}
+	}
+	return suggestions[0].Profile
+}
@@ -0,0 +1,40 @@
+package tuner
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDetectWorkloads(t *testing.T) {
+	root := t.TempDir()
+	for pid, comm := range map[string]string{"1": "systemd", "812": "postgres", "813": "postgres", "950": "nginx"} {
+		dir := filepath.Join(root, "proc", pid)
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, "comm"), []byte(comm+"\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	installed := func(pkg string) bool { return pkg == "mariadb-server" }
+
+	found := DetectWorkloads(root, installed)
+	if len(found) != 3 {
+		t.Fatalf("expected PostgreSQL, MariaDB and nginx, got %+v", found)
+	}
+	if found[0].Workload != "PostgreSQL" || !found[0].Running || found[0].Evidence != "running: postgres" {
+		t.Errorf("unexpected detection %+v", found[0])
+	}
+	if found[1].Running || found[1].Evidence != "installed: mariadb-server" {
+		t.Errorf("MariaDB is only installed: %+v", found[1])
+	}
+
+	suggestions := SuggestProfiles(found)
+	if len(suggestions) != 2 || suggestions[0].Profile != "database" || suggestions[0].Score != 3 {
+		t.Errorf("database should rank first: %+v", suggestions)
+	}
+	if suggestions[1].Profile != "web" {
+		t.Errorf("web should also fit: %+v", suggestions)
+	}
+}
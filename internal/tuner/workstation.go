@@ -0,0 +1,70 @@
+package tuner
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// pciClassMultimedia is the base class of sound cards. ESXi has no virtual
+// sound device: only Workstation and Fusion present one.
+const pciClassMultimedia = "04"
+
+// pciSVGA3 is the display adapter of Fusion on Apple silicon
+const pciSVGA3 = "15ad:0406"
+
+// DesktopGuest lists what tells a VMware Workstation or Fusion guest from a
+// vSphere one: the DMI strings are the same, the virtual devices are not
+type DesktopGuest struct {
+	Signals []string
+}
+
+// Detected reports a Workstation/Fusion guest
+func (g DesktopGuest) Detected() bool {
+	return len(g.Signals) > 0
+}
+
+// String lists the signals, for the messages
+func (g DesktopGuest) String() string {
+	return strings.Join(g.Signals, ", ")
+}
+
+// DetectDesktopGuest looks for the devices and mounts only the desktop
+// hypervisors provide. fsRoot allows running against a fixture tree.
+func DetectDesktopGuest(fsRoot string) DesktopGuest {
+	var guest DesktopGuest
+	if dmi := ReadDMI(fsRoot); !strings.Contains(dmi.Vendor, "VMware") {
+		return guest
+	}
+
+	devices, _ := ListPCIDevices(fsRoot)
+	for _, dev := range devices {
+		switch {
+		case dev.BaseClass() == pciClassMultimedia:
+			guest.Signals = append(guest.Signals, "sound card: "+dev.Model)
+		case dev.VendorID+":"+dev.DeviceID == pciSVGA3:
+			guest.Signals = append(guest.Signals, "SVGA 3 adapter (Fusion on Apple silicon)")
+		}
+	}
+	if hgfsMounted(fsRoot) {
+		guest.Signals = append(guest.Signals, "shared folders mounted (HGFS)")
+	}
+	guest.Signals = dedupe(guest.Signals)
+	return guest
+}
+
+// hgfsMounted reports whether shared folders are mounted (vmhgfs-fuse, or
+// the vmhgfs kernel module of older tools)
+func hgfsMounted(fsRoot string) bool {
+	data, err := os.ReadFile(filepath.Join(fsRoot, "/proc/mounts"))
+	if err != nil {
+		return false
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) > 2 && (fields[2] == "vmhgfs" || strings.HasPrefix(fields[2], "fuse.vmhgfs")) {
+			return true
+		}
+	}
+	return false
+}
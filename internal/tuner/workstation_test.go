@@ -0,0 +1,63 @@
+package tuner
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDetectDesktopGuest(t *testing.T) {
+	root := t.TempDir()
+	dmi := filepath.Join(root, "sys/class/dmi/id")
+	if err := os.MkdirAll(dmi, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dmi, "sys_vendor"), []byte("VMware, Inc.\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	writePCIDevice(t, root, "0000:00:0f.0", "0x15ad", "0x0405", "0x030000", "vmwgfx")
+	writePCIDevice(t, root, "0000:0b:00.0", "0x15ad", "0x07b0", "0x020000", "vmxnet3")
+
+	// The devices of a vSphere VM
+	if guest := DetectDesktopGuest(root); guest.Detected() {
+		t.Errorf("vSphere guest detected as a desktop one: %s", guest)
+	}
+
+	writePCIDevice(t, root, "0000:02:02.0", "0x1274", "0x1371", "0x040100", "snd_ens1371")
+	if err := os.MkdirAll(filepath.Join(root, "proc"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	mounts := "/dev/sda1 / ext4 rw 0 0\nvmhgfs-fuse /mnt/hgfs fuse.vmhgfs-fuse rw,allow_other 0 0\n"
+	if err := os.WriteFile(filepath.Join(root, "proc/mounts"), []byte(mounts), 0644); err != nil {
+		t.Fatal(err)
+	}
+	guest := DetectDesktopGuest(root)
+	if want := "sound card: Ensoniq ES1371 (emulated sound), shared folders mounted (HGFS)"; guest.String() != want {
+		t.Errorf("signals = %q, want %q", guest, want)
+	}
+}
+
+func TestDeveloperProfileKeepsDesktopServices(t *testing.T) {
+	old := Tuning
+	defer func() { Tuning = old }()
+	Tuning = TuningConfig{Profile: "developer"}
+
+	for _, svc := range bloatTargets() {
+		if desktopServices[svc.Name] {
+			t.Errorf("%s is a Server Slim candidate with the developer profile", svc.Name)
+		}
+	}
+	if !Tuning.DebloatKept("cups.service") || Tuning.DebloatKept("snapd") {
+		t.Error("the developer profile should keep cups and only the desktop services")
+	}
+}
+
+func TestHGFSUnit(t *testing.T) {
+	unit := NewHGFSTuner(false).Unit()
+	for _, want := range []string{"Where=/mnt/hgfs\n", "Type=fuse.vmhgfs-fuse\n", "Options=allow_other,nofail,attr_timeout=5,entry_timeout=5\n"} {
+		if !strings.Contains(unit, want) {
+			t.Errorf("unit lacks %q:\n%s", want, unit)
+		}
+	}
+}